@@ -1,20 +1,23 @@
 package app
 
 import (
+	"context"
 	apiManager "github.com/gostship/kunkka/pkg/apimanager"
 	"github.com/gostship/kunkka/pkg/controllers/k8smanager"
 	"github.com/gostship/kunkka/pkg/k8sclient"
+	"github.com/gostship/kunkka/pkg/timeouts"
+	"github.com/gostship/kunkka/pkg/tracing"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"k8s.io/klog"
+	ctrl "sigs.k8s.io/controller-runtime"
 	ctrlmanager "sigs.k8s.io/controller-runtime/pkg/manager"
-	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 	"sigs.k8s.io/controller-runtime/pkg/runtime/signals"
 	"time"
 )
 
 var (
-	logger = logf.KBLog.WithName("admin-api")
+	logger = ctrl.Log.WithName("admin-api")
 )
 
 // returns NewAPICmd
@@ -32,6 +35,20 @@ func NewAPICmd(cli *KunkkaCli) *cobra.Command {
 				klog.Fatalf("unable to get kubeconfig err: %v", err)
 			}
 
+			if err := opt.Leader.Validate(); err != nil {
+				klog.Fatalf("invalid leader election err: %v", err)
+			}
+			if err := opt.Trace.Validate(); err != nil {
+				klog.Fatalf("invalid tracing err: %v", err)
+			}
+			shutdownTracing, err := tracing.Setup(context.Background(), opt.Trace)
+			if err != nil {
+				klog.Fatalf("unable to setup tracing err: %v", err)
+			}
+			defer shutdownTracing(context.Background())
+
+			// all the replicas serve the apis behind the service, the api manager elects the leader
+			// of the loops which must not run twice at a time itself
 			rp := time.Second * 120
 			mgr, err := ctrlmanager.New(cfg, ctrlmanager.Options{
 				Scheme:             k8sclient.GetScheme(),
@@ -66,10 +83,39 @@ func NewAPICmd(cli *KunkkaCli) *cobra.Command {
 	}
 
 	cmd.PersistentFlags().IntVar(&opt.GoroutineThreshold, "goroutine-threshold", opt.GoroutineThreshold, "the max Goroutine Threshold")
+	cmd.PersistentFlags().DurationVar(&opt.HealthCheckTimeout, "health-check-timeout", opt.HealthCheckTimeout, "the timeout of each check of /healthz and /readyz.")
 	cmd.PersistentFlags().StringVar(&opt.HTTPAddr, "http-addr", opt.HTTPAddr, "HttpAddr for some info")
 	cmd.PersistentFlags().BoolVar(&opt.IsMeta, "is-meta", opt.IsMeta, "Whether it is a meta cluster")
 	cmd.PersistentFlags().BoolVar(&opt.GinLogEnabled, "enable-ginlog", opt.GinLogEnabled, "Enabled will open gin run log.")
 	cmd.PersistentFlags().BoolVar(&opt.PprofEnabled, "enable-pprof", opt.PprofEnabled, "Enabled will open endpoint for go pprof.")
+	cmd.PersistentFlags().StringVar(&opt.PprofToken, "pprof-token", opt.PprofToken, "The bearer token required by the pprof endpoint, empty means no auth.")
+	cmd.PersistentFlags().Int64Var(&opt.MaxBodySize, "max-body-size", opt.MaxBodySize, "the max size in bytes of the request body, 0 means no limit.")
+	cmd.PersistentFlags().StringArrayVar(&opt.RouteMaxBodySizes, "route-max-body-size", opt.RouteMaxBodySizes, "the max body size override of a route, e.g. \"POST /apis/cluster/addCluster=4194304\".")
+	cmd.PersistentFlags().Float32Var(&opt.RateLimitQPS, "rate-limit-qps", opt.RateLimitQPS, "the requests per second of each client on all the routes, 0 means no limit.")
+	cmd.PersistentFlags().IntVar(&opt.RateLimitBurst, "rate-limit-burst", opt.RateLimitBurst, "the burst of the requests of each client on all the routes.")
+	cmd.PersistentFlags().StringArrayVar(&opt.RouteRateLimits, "route-rate-limit", opt.RouteRateLimits, "the rate limit <qps>:<burst> of a route besides the limit of all the routes, e.g. \"POST /apis/cluster/addCluster=0.1:2\", 0 disables the default of the route.")
+	cmd.PersistentFlags().StringVar(&opt.TLSSecret, "tls-secret", opt.TLSSecret, "the <namespace>/<name> of the kubernetes.io/tls secret of the serving certificate, watched and reloaded on change.")
+	cmd.PersistentFlags().StringVar(&opt.TLSCertFile, "tls-cert-file", opt.TLSCertFile, "the file of the serving certificate if no --tls-secret, reloaded every minute.")
+	cmd.PersistentFlags().StringVar(&opt.TLSKeyFile, "tls-key-file", opt.TLSKeyFile, "the file of the private key of the serving certificate.")
+	cmd.PersistentFlags().StringVar(&opt.TLSClientCAFile, "tls-client-ca-file", opt.TLSClientCAFile, "the CA file verifying the client certificates, the CN is the user and the Os are the groups.")
+	cmd.PersistentFlags().StringVar(&opt.TLSClientAuth, "tls-client-auth", opt.TLSClientAuth, "the client certificate auth with --tls-client-ca-file: none, optional (bearer tokens or client certificates) or require.")
+	timeouts.AddFlags(cmd.PersistentFlags())
+	cmd.PersistentFlags().Float32Var(&cli.Opt.KubeAPIQPS, "kube-api-qps", cli.Opt.KubeAPIQPS, "the queries per second of the client of the meta cluster.")
+	cmd.PersistentFlags().IntVar(&cli.Opt.KubeAPIBurst, "kube-api-burst", cli.Opt.KubeAPIBurst, "the burst of the queries of the client of the meta cluster.")
+	k8sclient.AddFlags(cmd.PersistentFlags())
+	k8smanager.AddFlags(cmd.PersistentFlags())
+	cmd.PersistentFlags().StringVar(&opt.EscrowNamespace, "escrow-namespace", opt.EscrowNamespace, "the namespace of the escrowed cluster credentials and the break glass requests.")
+	cmd.PersistentFlags().StringVar(&opt.CredentialKeyFile, "credential-key-file", opt.CredentialKeyFile, "the key file the credential secrets are encrypted with, must be the same as the controller.")
+	cmd.PersistentFlags().StringSliceVar(&opt.CredentialPreviousKeyFiles, "credential-previous-key-files", opt.CredentialPreviousKeyFiles, "the key files the credential secrets were encrypted with before the rotation, must be the same as the controller.")
+	opt.Storage.AddFlags(cmd.PersistentFlags())
+	opt.Auth.AddFlags(cmd.PersistentFlags())
+	opt.Leader.AddFlags(cmd.PersistentFlags())
+	opt.Trace.AddFlags(cmd.PersistentFlags())
+	cmd.PersistentFlags().BoolVar(&opt.AuthzEnabled, "enable-authz", opt.AuthzEnabled, "Enabled authorizes the users by the role bindings of the kunkka-api/role-bindings configmap.")
+	cmd.PersistentFlags().DurationVar(&opt.AuditRetention, "audit-retention", opt.AuditRetention, "the age of the audit events pruned from the storage, 0 keeps them forever.")
+	cmd.PersistentFlags().StringSliceVar(&opt.PlatformAdmins, "platform-admins", opt.PlatformAdmins, "the users who are admin of all the clusters besides the role bindings.")
+	cmd.PersistentFlags().BoolVar(&opt.SSHHostKeyPinning, "ssh-host-key-pinning", opt.SSHHostKeyPinning, "pins the ssh host keys of the machines in the knownhosts-<cluster> configmaps on the first contact and verifies them afterwards, must be the same as the controller.")
+	cmd.PersistentFlags().StringSliceVar(&opt.ExpansionApprovers, "expansion-approvers", opt.ExpansionApprovers, "the platform admins who review the expansion requests beyond the tenant quota.")
 	return cmd
 }
 
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/gostship/kunkka/pkg/escrow"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdEscrowOpen returns a cobra command for decrypting the escrowed credential offline
+func NewCmdEscrowOpen() *cobra.Command {
+	var privateKey, envelope, output string
+	cmd := &cobra.Command{
+		Use:   "escrow-open",
+		Short: "Decrypt the escrowed cluster credential",
+		Long:  "Decrypt the envelope retrieved by the break glass api with the private key kept offline, no cluster access is required",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keyData, err := ioutil.ReadFile(privateKey)
+			if err != nil {
+				return err
+			}
+			priv, err := escrow.ParsePrivateKey(keyData)
+			if err != nil {
+				return err
+			}
+
+			envData, err := ioutil.ReadFile(envelope)
+			if err != nil {
+				return err
+			}
+			env, err := escrow.Unmarshal(envData)
+			if err != nil {
+				return err
+			}
+
+			plaintext, err := escrow.Open(priv, env)
+			if err != nil {
+				return err
+			}
+
+			if output == "" || output == "-" {
+				_, err = os.Stdout.Write(plaintext)
+				return err
+			}
+			err = ioutil.WriteFile(output, plaintext, 0600)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "cluster: %s kubeconfig written to %s\n", env.Cluster, output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&privateKey, "private-key", "", "the PEM encoded rsa private key of the escrow")
+	cmd.Flags().StringVar(&envelope, "envelope", "", "the envelope json file")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "the kubeconfig file to write, defaults to stdout")
+	cmd.MarkFlagRequired("private-key")
+	cmd.MarkFlagRequired("envelope")
+	return cmd
+}
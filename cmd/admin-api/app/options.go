@@ -18,6 +18,7 @@ package app
 
 import (
 	k8scli "github.com/gostship/kunkka/pkg/k8sclient"
+	"github.com/gostship/kunkka/pkg/logs"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	corev1 "k8s.io/api/core/v1"
@@ -32,12 +33,19 @@ type RootOption struct {
 	Namespace        string
 	DefaultNamespace string
 	DevelopmentMode  bool
+	// KubeAPIQPS and KubeAPIBurst the rate limits of the client of the meta cluster
+	KubeAPIQPS   float32
+	KubeAPIBurst int
+	Log          *logs.Options
 }
 
 func DefaultRootOption() *RootOption {
 	return &RootOption{
 		Namespace:       corev1.NamespaceAll,
 		DevelopmentMode: true,
+		KubeAPIQPS:      40,
+		KubeAPIBurst:    60,
+		Log:             logs.DefaultOptions(),
 	}
 }
 
@@ -58,6 +66,8 @@ func (c *KunkkaCli) GetK8sConfig() (*rest.Config, error) {
 		return nil, errors.Wrap(err, "could not get k8s config")
 	}
 
+	config.QPS = c.Opt.KubeAPIQPS
+	config.Burst = c.Opt.KubeAPIBurst
 	return config, nil
 }
 
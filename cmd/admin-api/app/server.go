@@ -2,9 +2,9 @@ package app
 
 import (
 	"flag"
+	"github.com/gostship/kunkka/pkg/logs"
 	"github.com/spf13/cobra"
 	"k8s.io/klog"
-	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 )
 
 // returns the api server of cobra
@@ -19,13 +19,22 @@ func GetApiCmd(args []string) *cobra.Command {
 		SilenceUsage:      true,
 		DisableAutoGenTag: true,
 		Run:               runHelp,
+		// the logger is set up once the flags are parsed
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			opt.Log.Development = opt.DevelopmentMode
+			if err := opt.Log.Validate(); err != nil {
+				return err
+			}
+			return logs.Setup(opt.Log)
+		},
 	}
 
 	apicmd.SetArgs(args)
+	apicmd.PersistentFlags().BoolVar(&opt.DevelopmentMode, "logger-dev-mode", opt.DevelopmentMode, "Enables the development mode of the logger.")
+	opt.Log.AddFlags(apicmd.PersistentFlags())
 
 	// Make sure that klog logging variables are initialized so that we can
 	klog.InitFlags(nil)
-	logf.SetLogger(logf.ZapLogger(opt.DevelopmentMode))
 
 	// Make sure klog (used by the client-go dependency) logs to stderr, as it
 	// will try to log to directories that may not exist in the cilium-operator
@@ -36,6 +45,7 @@ func GetApiCmd(args []string) *cobra.Command {
 	cli := NewKunkkaCli(opt)
 	apicmd.AddCommand(NewAPICmd(cli))
 	apicmd.AddCommand(NewCmdVersion(cli))
+	apicmd.AddCommand(NewCmdEscrowOpen())
 
 	return apicmd
 }
@@ -22,6 +22,10 @@ import (
 	"math/rand"
 	"os"
 	"time"
+
+	// the database/sql drivers of the sql storage backend
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 func main() {
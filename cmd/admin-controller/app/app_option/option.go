@@ -17,20 +17,35 @@ limitations under the License.
 package app_option
 
 import (
+	"github.com/gostship/kunkka/pkg/logs"
 	"github.com/gostship/kunkka/pkg/option"
+	"github.com/gostship/kunkka/pkg/tracing"
+	"github.com/gostship/kunkka/pkg/util/leader"
 	"github.com/spf13/pflag"
 )
 
 type Options struct {
 	Global *option.GlobalManagerOption
 	Ctrl   *option.ControllersManagerOption
+	Diag   *option.DiagnosticsOption
+	Leader *leader.Options
+	Trace  *tracing.Options
+	Log    *logs.Options
 }
 
 // NewOptions creates a new Options with a default config.
 func NewOptions() *Options {
+	global := option.DefaultGlobalManagerOption()
+	// the controllers share the client of the meta cluster
+	global.KubeAPIQPS = 2 * global.KubeAPIQPS
+	global.KubeAPIBurst = 2 * global.KubeAPIBurst
 	return &Options{
-		Global: option.DefaultGlobalManagerOption(),
+		Global: global,
 		Ctrl:   option.DefaultControllersManagerOption(),
+		Diag:   option.DefaultDiagnosticsOption(),
+		Leader: leader.DefaultOptions("kunkka-controller"),
+		Trace:  tracing.DefaultOptions("kunkka-controller"),
+		Log:    logs.DefaultOptions(),
 	}
 }
 
@@ -38,4 +53,8 @@ func NewOptions() *Options {
 func (o *Options) AddFlags(fs *pflag.FlagSet) {
 	o.Global.AddFlags(fs)
 	o.Ctrl.AddFlags(fs)
+	o.Diag.AddFlags(fs)
+	o.Leader.AddFlags(fs)
+	o.Trace.AddFlags(fs)
+	o.Log.AddFlags(fs)
 }
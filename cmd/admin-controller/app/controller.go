@@ -17,13 +17,18 @@ limitations under the License.
 package app
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/spf13/cobra"
 	"k8s.io/klog"
 
 	"github.com/gostship/kunkka/cmd/admin-controller/app/app_option"
 	"github.com/gostship/kunkka/pkg/controllers"
+	"github.com/gostship/kunkka/pkg/diagnostics"
 	"github.com/gostship/kunkka/pkg/k8sclient"
 	"github.com/gostship/kunkka/pkg/static"
+	"github.com/gostship/kunkka/pkg/tracing"
 	"github.com/gostship/kunkka/pkg/util/k8sutil"
 	ctrlmanager "sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/runtime/signals"
@@ -53,19 +58,41 @@ func NewControllerCmd(opt *app_option.Options) *cobra.Command {
 				}
 			}
 
-			// Adjust our client's rate limits based on the number of controllers we are running.
-			cfg.QPS = float32(2) * cfg.QPS
-			cfg.Burst = 2 * cfg.Burst
-
-			mgr, err := ctrlmanager.New(cfg, ctrlmanager.Options{
-				Scheme:                  k8sclient.GetScheme(),
-				LeaderElection:          opt.Global.EnableLeaderElection,
-				LeaderElectionNamespace: opt.Global.LeaderElectionNamespace,
-				SyncPeriod:              &opt.Global.ResyncPeriod,
-				MetricsBindAddress:      "0",
-				HealthProbeBindAddress:  ":8090",
-				// Port:               9443,
-			})
+			if err := opt.Leader.Validate(); err != nil {
+				klog.Fatalf("invalid leader election err: %v", err)
+			}
+			if err := opt.Ctrl.Validate(); err != nil {
+				klog.Fatalf("invalid controllers err: %v", err)
+			}
+			if err := opt.Ctrl.Sharding.Validate(); err != nil {
+				klog.Fatalf("invalid sharding err: %v", err)
+			}
+			if err := opt.Trace.Validate(); err != nil {
+				klog.Fatalf("invalid tracing err: %v", err)
+			}
+			shutdownTracing, err := tracing.Setup(context.Background(), opt.Trace)
+			if err != nil {
+				klog.Fatalf("unable to setup tracing err: %v", err)
+			}
+			defer shutdownTracing(context.Background())
+
+			// the replicas of a shard elect the leader of the shard
+			if opt.Ctrl.Sharding.Enabled() {
+				opt.Leader.ID = fmt.Sprintf("%s-shard-%d", opt.Leader.ID, opt.Ctrl.Sharding.Shard)
+			}
+
+			// the webhooks, the probes and the diagnostics are served by all the replicas,
+			// the controllers run on the leader only
+			mgrOpt := ctrlmanager.Options{
+				Scheme:                 k8sclient.GetScheme(),
+				SyncPeriod:             &opt.Global.ResyncPeriod,
+				MetricsBindAddress:     opt.Ctrl.MetricsAddr,
+				HealthProbeBindAddress: ":8090",
+				Port:                   opt.Ctrl.WebhookPort,
+				CertDir:                opt.Ctrl.WebhookCertDir,
+			}
+			opt.Leader.Apply(&mgrOpt)
+			mgr, err := ctrlmanager.New(cfg, mgrOpt)
 			if err != nil {
 				klog.Fatalf("unable to new manager err: %v", err)
 			}
@@ -76,6 +103,12 @@ func NewControllerCmd(opt *app_option.Options) *cobra.Command {
 				klog.Fatalf("unable to register controllers to the manager err: %v", err)
 			}
 
+			if opt.Diag.Addr != "" {
+				if err := mgr.Add(diagnostics.NewServer(opt.Diag)); err != nil {
+					klog.Fatalf("unable to add diagnostics server err: %v", err)
+				}
+			}
+
 			klog.Info("starting manager")
 			stopCh := signals.SetupSignalHandler()
 			if err := mgr.Start(stopCh); err != nil {
@@ -85,5 +118,8 @@ func NewControllerCmd(opt *app_option.Options) *cobra.Command {
 	}
 
 	opt.Ctrl.AddFlags(cmd.Flags())
+	opt.Diag.AddFlags(cmd.Flags())
+	opt.Leader.AddFlags(cmd.Flags())
+	opt.Trace.AddFlags(cmd.Flags())
 	return cmd
 }
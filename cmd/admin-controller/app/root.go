@@ -20,11 +20,10 @@ import (
 	"flag"
 
 	"github.com/gostship/kunkka/cmd/admin-controller/app/app_option"
+	"github.com/gostship/kunkka/pkg/logs"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"k8s.io/klog"
-	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
 
 func AddFlags(cmd *cobra.Command) {
@@ -51,15 +50,23 @@ func GetRootCmd(args []string) *cobra.Command {
 		SilenceUsage:      true,
 		DisableAutoGenTag: true,
 		Run:               runHelp,
+		// the logger is set up once the flags are parsed
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			opt.Log.Development = opt.Global.LoggerDevMode
+			if err := opt.Log.Validate(); err != nil {
+				return err
+			}
+			return logs.Setup(opt.Log)
+		},
 	}
 
 	rootCmd.SetArgs(args)
 	opt.Global.AddFlags(rootCmd.PersistentFlags())
+	opt.Log.AddFlags(rootCmd.PersistentFlags())
 
 	// Make sure that klog logging variables are initialized so that we can
 	// update them from this file.
 	klog.InitFlags(nil)
-	ctrl.SetLogger(zap.New(zap.UseDevMode(opt.Global.LoggerDevMode)))
 
 	// Make sure klog (used by the client-go dependency) logs to stderr, as it
 	// will try to log to directories that may not exist in the cilium-operator
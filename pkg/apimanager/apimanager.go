@@ -2,18 +2,38 @@ package apimanager
 
 import (
 	"context"
+	"github.com/gostship/kunkka/pkg/apimanager/apitoken"
+	"github.com/gostship/kunkka/pkg/apimanager/auditlog"
+	"github.com/gostship/kunkka/pkg/apimanager/clusterdeletion"
 	"github.com/gostship/kunkka/pkg/apimanager/healthcheck"
+	"github.com/gostship/kunkka/pkg/apimanager/keyrotation"
+	"github.com/gostship/kunkka/pkg/apimanager/noderemoval"
+	"github.com/gostship/kunkka/pkg/apimanager/progress"
 	"github.com/gostship/kunkka/pkg/apimanager/router"
 	apiv1 "github.com/gostship/kunkka/pkg/apimanager/v1"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/certexpiry"
+	"github.com/gostship/kunkka/pkg/constants"
 	"github.com/gostship/kunkka/pkg/controllers/apictl"
 	"github.com/gostship/kunkka/pkg/controllers/k8smanager"
+	"github.com/gostship/kunkka/pkg/credential"
 	"github.com/gostship/kunkka/pkg/gmanager"
+	"github.com/gostship/kunkka/pkg/hostkeys"
 	"github.com/gostship/kunkka/pkg/provider/monitoring/prometheus"
+	"github.com/gostship/kunkka/pkg/storage"
+	"github.com/gostship/kunkka/pkg/tracing"
+	"github.com/gostship/kunkka/pkg/util/authutil"
+	"github.com/gostship/kunkka/pkg/util/leader"
+	"github.com/gostship/kunkka/pkg/util/ssh"
 	"github.com/pkg/errors"
+	promclient "github.com/prometheus/client_golang/prometheus"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"strconv"
 	"time"
 )
 
@@ -21,6 +41,8 @@ import (
 type Option struct {
 	Threadiness        int
 	GoroutineThreshold int
+	// HealthCheckTimeout the timeout of each check of the liveness and the readiness probes
+	HealthCheckTimeout time.Duration
 	IsMeta             bool
 	ResyncPeriod       time.Duration
 	Features           []string
@@ -30,6 +52,45 @@ type Option struct {
 	GinLogEnabled  bool
 	GinLogSkipPath []string
 	PprofEnabled   bool
+	PprofToken     string
+	MaxBodySize    int64
+	// RateLimitQPS, RateLimitBurst the rate limit of each client, RouteRateLimits and RouteMaxBodySizes
+	// override the defaults of the routes by "<METHOD> <path>=<value>"
+	RateLimitQPS      float32
+	RateLimitBurst    int
+	RouteRateLimits   []string
+	RouteMaxBodySizes []string
+
+	// TLSSecret the "<namespace>/<name>" of the kubernetes.io/tls Secret of the serving certificate,
+	// or TLSCertFile and TLSKeyFile, both reloaded on change
+	TLSSecret   string
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSClientCAFile the CAs verifying the client certificates, TLSClientAuth none, optional or require
+	TLSClientCAFile string
+	TLSClientAuth   string
+
+	EscrowNamespace    string
+	ExpansionApprovers []string
+	CredentialKeyFile  string
+	// CredentialPreviousKeyFiles the keys the credential secrets were encrypted with before the rotation
+	CredentialPreviousKeyFiles []string
+
+	// Storage selects the backend of the operational data which doesn't fit into the CRDs
+	Storage *storage.Options
+	// Auth configures the bearer token authentication of the apis
+	Auth *authutil.Options
+	// AuthzEnabled authorizes the authenticated users by the role bindings
+	AuthzEnabled   bool
+	PlatformAdmins []string
+	// AuditRetention the age of the audit events pruned from the storage, 0 keeps them forever
+	AuditRetention time.Duration
+	// SSHHostKeyPinning pins and verifies the ssh host keys of the machines like the controller, e.g. on the key rotations
+	SSHHostKeyPinning bool
+	// Leader elects the replica running the audit pruner and the syncers, all the replicas serve the apis
+	Leader *leader.Options
+	// Trace exports the spans of the requests and of the creation of the Clusters and the Machines
+	Trace *tracing.Options
 }
 
 // APIManager ...
@@ -46,12 +107,62 @@ func DefaultOption() *Option {
 		HTTPAddr:           ":8888",
 		IsMeta:             true,
 		GoroutineThreshold: 1000,
-		GinLogSkipPath:     []string{"/ready", "/live"},
+		HealthCheckTimeout: 3 * time.Second,
+		GinLogSkipPath:     []string{router.ReadyPath, router.LivePath, router.ReadyzPath, router.HealthzPath},
 		GinLogEnabled:      true,
 		PprofEnabled:       true,
+		MaxBodySize:        router.DefaultMaxBodySize,
+		RateLimitQPS:       router.DefaultRateLimitQPS,
+		RateLimitBurst:     router.DefaultRateLimitBurst,
+		EscrowNamespace:    constants.EscrowNamespace,
+		Storage:            storage.DefaultOptions(),
+		Auth:               authutil.DefaultOptions(),
+		AuthzEnabled:       true,
+		PlatformAdmins:     []string{"admin"},
+		AuditRetention:     90 * 24 * time.Hour,
+		TLSClientAuth:      router.ClientAuthOptional,
+		SSHHostKeyPinning:  true,
+		Leader:             leader.DefaultOptions("kunkka-api"),
+		Trace:              tracing.DefaultOptions("kunkka-api"),
 	}
 }
 
+// routeLimits returns the rate limits and the max body sizes of the routes, the defaults overridden by the options.
+func routeLimits(opt *Option) (map[string]router.RateLimit, map[string]int64, error) {
+	rateLimits := make(map[string]router.RateLimit, len(apiv1.RouteRateLimits))
+	for route, l := range apiv1.RouteRateLimits {
+		rateLimits[route] = l
+	}
+	for _, s := range opt.RouteRateLimits {
+		route, value, err := router.ParseRouteLimit(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		l, err := router.ParseRateLimit(value)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "route rate limit: %s", route)
+		}
+		rateLimits[route] = l
+	}
+
+	bodySizes := make(map[string]int64, len(apiv1.RouteMaxBodySizes))
+	for route, size := range apiv1.RouteMaxBodySizes {
+		bodySizes[route] = size
+	}
+	for _, s := range opt.RouteMaxBodySizes {
+		route, value, err := router.ParseRouteLimit(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		size, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "route max body size: %s", route)
+		}
+		bodySizes[route] = size
+	}
+	return rateLimits, bodySizes, nil
+}
+
 // NewAPIManager ...
 func NewAPIManager(mgr manager.Manager, cli k8smanager.MasterClient, opt *Option, componentName string) (*APIManager, error) {
 	healthHandler := healthcheck.GetHealthHandler()
@@ -63,26 +174,111 @@ func NewAPIManager(mgr manager.Manager, cli k8smanager.MasterClient, opt *Option
 		HealthHandler: healthHandler,
 	}
 
-	v1 := apiv1.Manager{}
+	err := credential.LoadKeyFiles(opt.CredentialKeyFile, opt.CredentialPreviousKeyFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimits, bodySizes, err := routeLimits(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	authn, err := authutil.New(opt.Auth)
+	if err != nil {
+		return nil, errors.Wrapf(err, "new authenticator")
+	}
+	if opt.Auth.JWTSecretFile == "" && opt.Auth.Enabled && opt.Leader.Enabled {
+		return nil, errors.New("the replicas must share the --jwt-secret-file, the tokens issued by a replica are rejected by the others")
+	} else if opt.Auth.JWTSecretFile == "" {
+		klog.Warning("no --jwt-secret-file, the issued tokens are signed with a random secret and invalid after restart")
+	}
+	if !authutil.PasswordLoginEnabled() {
+		klog.Info("no --login-password-file, the password login of /oauth/authorize is disabled")
+	}
+
+	v1 := apiv1.Manager{
+		EscrowNamespace:    opt.EscrowNamespace,
+		ExpansionApprovers: opt.ExpansionApprovers,
+		PlatformAdmins:     opt.PlatformAdmins,
+		AuthModes:          []string{apiv1.AuthModeAPIToken},
+		Features: map[string]bool{
+			"credentialEncryption": opt.CredentialKeyFile != "",
+			"expansionApprovers":   len(opt.ExpansionApprovers) > 0,
+			"pprof":                opt.PprofEnabled,
+			"authentication":       opt.Auth.Enabled,
+			"authorization":        opt.Auth.Enabled && opt.AuthzEnabled,
+			"rateLimit":            opt.RateLimitQPS > 0,
+			"tls":                  opt.TLSEnabled(),
+			"clientCert":           opt.TLSEnabled() && opt.TLSClientCAFile != "",
+		},
+	}
+	if authutil.PasswordLoginEnabled() {
+		v1.AuthModes = append(v1.AuthModes, apiv1.AuthModeOAuthToken)
+	}
+	if opt.Auth.OIDCIssuerURL != "" {
+		v1.AuthModes = append(v1.AuthModes, apiv1.AuthModeOIDC)
+	}
+	if opt.PprofEnabled && opt.PprofToken != "" {
+		v1.AuthModes = append(v1.AuthModes, apiv1.AuthModePprofToken)
+	}
+	if opt.TLSEnabled() && opt.TLSClientCAFile != "" {
+		v1.AuthModes = append(v1.AuthModes, apiv1.AuthModeClientCert)
+	}
 
 	klog.Info("start init kunkka api manager... ")
 	k8sMgr, err := k8smanager.NewManager(cli)
 	if err != nil {
 		klog.Fatalf("unable to new k8s manager err: %v", err)
 	}
+	v1.Tokens = apitoken.NewStore(k8sMgr.GetClient(), apiv1.ConfigMapName)
 
 	routerOptions := &router.Options{
-		GinLogEnabled:    opt.GinLogEnabled,
-		GinLogSkipPath:   opt.GinLogSkipPath,
-		MetricsEnabled:   true,
-		PprofEnabled:     opt.PprofEnabled,
-		Addr:             opt.HTTPAddr,
-		MetricsPath:      "metrics",
-		MetricsSubsystem: componentName,
+		GinLogEnabled:     opt.GinLogEnabled,
+		GinLogSkipPath:    opt.GinLogSkipPath,
+		MetricsEnabled:    true,
+		TracingEnabled:    opt.Trace.Enabled(),
+		PprofEnabled:      opt.PprofEnabled,
+		PprofToken:        opt.PprofToken,
+		Addr:              opt.HTTPAddr,
+		MetricsPath:       "metrics",
+		MetricsSubsystem:  componentName,
+		MaxBodySize:       opt.MaxBodySize,
+		RouteMaxBodySizes: bodySizes,
+		RateLimit:         router.RateLimit{QPS: opt.RateLimitQPS, Burst: opt.RateLimitBurst},
+		RouteRateLimits:   rateLimits,
+		ContentTypes:      router.DefaultContentTypes,
+		RouteContentTypes: apiv1.RouteContentTypes,
+		NameParams:        router.DefaultNameParams,
+		LabelParams:       router.DefaultLabelParams,
+		PublicPaths:       router.DefaultPublicPaths,
+		Aliases:           apiv1.V2Aliases,
+		TypedErrorPrefix:  apiv1.V2Prefix,
+	}
+	if opt.TLSEnabled() {
+		routerOptions.TLS, err = newCertReloader(mgr, cli.KubeCli, opt)
+		if err != nil {
+			return nil, errors.Wrapf(err, "load tls certificates")
+		}
+	} else {
+		klog.Warning("tls is disabled, the apis and the tokens are served over plain http")
+	}
+	if opt.Auth.Enabled {
+		routerOptions.Authenticator = authutil.Union(authn, v1.Tokens)
+	} else {
+		klog.Warning("authentication is disabled, the apis are open to anyone who can reach them")
 	}
 	rt := router.NewRouter(routerOptions)
+	// the denied requests are audited as well
+	rt.Use(v1.AuditTrail())
+	if opt.Auth.Enabled && opt.AuthzEnabled {
+		rt.Use(v1.Authorize())
+	} else if opt.AuthzEnabled {
+		klog.Warning("authorization needs the authentication, any client can create or delete the clusters")
+	}
 
 	rt.AddRoutes("kapi", v1.Routes())
+	rt.AddRoutes("health", healthHandler.Routes())
 	apiMgr.Router = rt
 
 	// run api ctrl
@@ -95,9 +291,92 @@ func NewAPIManager(mgr manager.Manager, cli k8smanager.MasterClient, opt *Option
 	apiMgr.Cluster = k8sMgr
 	v1.Cluster = k8sMgr
 
+	// a wedged meta client fails the liveness, the unreachable meta cluster and the unsynced caches fail the readiness
+	healthHandler.AddLivenessCheck("meta_client", healthcheck.ClientCheck(k8sMgr.GetClient(),
+		func() runtime.Object { return &devopsv1.ClusterList{} }, opt.HealthCheckTimeout))
+	healthHandler.AddReadinessCheck("meta_apiserver", healthcheck.KubeAPICheck(cli.KubeCli, opt.HealthCheckTimeout))
+	healthHandler.AddReadinessCheck("meta_cache", healthcheck.CacheSyncCheck(k8sMgr.GetCache(), opt.HealthCheckTimeout))
+	healthHandler.AddReadinessCheck("cluster_caches", func() error {
+		return k8sMgr.CheckCaches(opt.HealthCheckTimeout)
+	})
+
+	v1.Store, err = storage.New(opt.Storage, k8sMgr.GetClient())
+	if err != nil {
+		return nil, errors.Wrapf(err, "new %s storage", opt.Storage.Backend)
+	}
+	v1.Audit = auditlog.NewRecorder(v1.Store)
+	v1.KeyRotator = keyrotation.NewRotator(k8sMgr.GetClient(), v1.Store)
+	v1.Deleter = clusterdeletion.NewDeleter(k8sMgr.GetClient(), v1.Store,
+		types.NamespacedName{Namespace: apiv1.ConfigMapName, Name: apiv1.ConfigMapName})
+	v1.Remover = noderemoval.NewRemover(k8sMgr.GetClient(), v1.Store,
+		types.NamespacedName{Namespace: apiv1.ConfigMapName, Name: apiv1.ConfigMapName})
+	v1.Progress = progress.NewHub()
+	for _, obj := range []runtime.Object{&devopsv1.Cluster{}, &devopsv1.Machine{}} {
+		informer, err := mgr.GetCache().GetInformer(context.Background(), obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get %T informer", obj)
+		}
+		informer.AddEventHandler(v1.Progress)
+	}
+	if opt.SSHHostKeyPinning {
+		ssh.SetHostKeyStore(hostkeys.NewStore(mgr.GetClient(), mgr.GetAPIReader(), mgr.GetScheme()))
+	}
+	// the loops below must not run twice at a time, they run on the leader of the replicas
+	elector := leader.NewElector(mgr.GetConfig(), opt.Leader)
+	if opt.AuditRetention > 0 {
+		elector.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+			wait.Until(func() {
+				n, err := v1.Audit.Prune(context.Background(), time.Now().Add(-opt.AuditRetention))
+				if err != nil {
+					klog.Errorf("prune audit events error: %v", err)
+				} else if n > 0 {
+					klog.Infof("pruned %d audit events older than %s", n, opt.AuditRetention)
+				}
+			}, time.Hour, stop)
+			return nil
+		}))
+	}
+
+	// release the rack addresses of the deleted clusters once they are cleaned up
+	elector.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		wait.Until(func() {
+			if err := v1.Deleter.Sync(context.Background()); err != nil {
+				klog.Errorf("sync cluster deletions error: %v", err)
+			}
+		}, time.Minute, stop)
+		return nil
+	}))
+
+	// release the rack addresses of the removed nodes once their machines are cleaned up
+	elector.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		wait.Until(func() {
+			if err := v1.Remover.Sync(context.Background()); err != nil {
+				klog.Errorf("sync node removals error: %v", err)
+			}
+		}, time.Minute, stop)
+		return nil
+	}))
+
+	err = mgr.Add(elector)
+	if err != nil {
+		return nil, errors.Wrapf(err, "add leader elector")
+	}
+
+	// probe the member cluster clients, the offline clusters are unavailable until a probe succeeds again
+	err = mgr.Add(k8sMgr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "add cluster client prober")
+	}
+
+	// export the expiry of the cluster certs on /metrics
+	err = promclient.Register(certexpiry.NewCollector(k8sMgr.GetClient()))
+	if err != nil {
+		klog.Warningf("register cert expiry collector err: %v", err)
+	}
+
 	err = preStart(k8sMgr)
 	if err != nil {
-		klog.Error("cluster: host client preStart error:%s", err)
+		klog.Errorf("cluster: host client preStart error:%s", err)
 		return nil, err
 	}
 
@@ -115,7 +394,7 @@ func preStart(cli *k8smanager.ClusterManager) error {
 		pod := object.(*corev1.Pod)
 		return []string{pod.Status.HostIP}
 	}); err != nil {
-		klog.Warning("cluster: host add field index pod status.hostIP, err: %#v", err)
+		klog.Warningf("cluster: host add field index pod status.hostIP, err: %#v", err)
 		return errors.New("cluster add field index pod spec.nodeName failed")
 	} else {
 		klog.Warning("########### cluster: host add field index pod status.hostIP, successfully ##################")
@@ -125,7 +404,7 @@ func preStart(cli *k8smanager.ClusterManager) error {
 		event := object.(*corev1.Event)
 		return []string{event.Source.Host}
 	}); err != nil {
-		klog.Warning("cluster: host add field index pod source.host, err: %#v", err)
+		klog.Warningf("cluster: host add field index pod source.host, err: %#v", err)
 		return errors.New("cluster add field index pod involvedObject.name failed")
 	} else {
 		klog.Warning("########### cluster: host add field index pod source.host, successfully ##################")
@@ -135,7 +414,7 @@ func preStart(cli *k8smanager.ClusterManager) error {
 		event := object.(*corev1.Event)
 		return []string{event.InvolvedObject.Kind}
 	}); err != nil {
-		klog.Warning("cluster: host add field index pod involvedObject.kind, err: %#v", err)
+		klog.Warningf("cluster: host add field index pod involvedObject.kind, err: %#v", err)
 		return errors.New("cluster add field index pod involvedObject.kind failed")
 	} else {
 		klog.Warning("########### cluster: host add field index pod involvedObject.kind, successfully ##################")
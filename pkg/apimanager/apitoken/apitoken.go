@@ -0,0 +1,224 @@
+// Package apitoken issues the long-lived api tokens of the automation clients, e.g. the CI pipelines,
+// only the sha256 of the tokens is kept in the Secrets, they're revoked by deleting the Secrets.
+package apitoken
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/apimanager/rbac"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/util/authutil"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// Prefix the prefix of the api tokens, it tells them from the jwt tokens and the secret scanners find them
+	Prefix = "kka_"
+	// Issuer the issuer of the users of the api tokens
+	Issuer = "kunkka-apitoken"
+	// Group the group of all the api tokens
+	Group = "kunkka:apitokens"
+
+	// ExtraOwner, ExtraRole, ExtraClusters, ExtraTenants the owner and the scope of the token in the user extra
+	ExtraOwner    = "apitoken.kunkka.io/owner"
+	ExtraRole     = "apitoken.kunkka.io/role"
+	ExtraClusters = "apitoken.kunkka.io/clusters"
+	ExtraTenants  = "apitoken.kunkka.io/tenants"
+
+	hashKey  = "hash"
+	tokenKey = "token.json"
+	idSize   = 8
+)
+
+// SecretName returns the name of the Secret of the token.
+func SecretName(id string) string {
+	return fmt.Sprintf("apitoken-%s", id)
+}
+
+// UserName returns the name of the user of the token.
+func UserName(id string) string {
+	return fmt.Sprintf("apitoken:%s", id)
+}
+
+// Store keeps the api tokens in the Secrets of the namespace.
+type Store struct {
+	cli       client.Client
+	namespace string
+}
+
+// NewStore ...
+func NewStore(cli client.Client, namespace string) *Store {
+	return &Store{cli: cli, namespace: namespace}
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func random(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, errors.Wrap(err, "generate token")
+	}
+	return b, nil
+}
+
+// Create saves the token with a new id and returns the plaintext token, which can't be got again.
+func (s *Store) Create(ctx context.Context, t *model.APIToken) (string, error) {
+	id, err := random(idSize)
+	if err != nil {
+		return "", err
+	}
+	secret, err := random(32)
+	if err != nil {
+		return "", err
+	}
+	t.ID = hex.EncodeToString(id)
+	token := Prefix + t.ID + "_" + base64.RawURLEncoding.EncodeToString(secret)
+
+	data, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	err = s.cli.Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      SecretName(t.ID),
+			Namespace: s.namespace,
+			Labels:    map[string]string{constants.APITokenLabel: "true"},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			hashKey:  []byte(hash(token)),
+			tokenKey: data,
+		},
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "create api token: %s", t.ID)
+	}
+	return token, nil
+}
+
+// Get returns the token of the id and its hash.
+func (s *Store) Get(ctx context.Context, id string) (*model.APIToken, string, error) {
+	secret := &corev1.Secret{}
+	err := s.cli.Get(ctx, types.NamespacedName{Namespace: s.namespace, Name: SecretName(id)}, secret)
+	if err != nil {
+		return nil, "", err
+	}
+	t, err := decode(secret)
+	if err != nil {
+		return nil, "", err
+	}
+	return t, string(secret.Data[hashKey]), nil
+}
+
+// List returns the tokens of the owner, all the tokens if the owner is empty, the latest first.
+func (s *Store) List(ctx context.Context, owner string) ([]*model.APIToken, error) {
+	secrets := &corev1.SecretList{}
+	err := s.cli.List(ctx, secrets, client.InNamespace(s.namespace), client.MatchingLabels{constants.APITokenLabel: "true"})
+	if err != nil {
+		return nil, errors.Wrapf(err, "list api tokens")
+	}
+
+	list := make([]*model.APIToken, 0, len(secrets.Items))
+	for i := range secrets.Items {
+		t, err := decode(&secrets.Items[i])
+		if err != nil {
+			continue
+		}
+		if owner == "" || t.Owner == owner {
+			list = append(list, t)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	return list, nil
+}
+
+// Revoke deletes the token, it's rejected right away.
+func (s *Store) Revoke(ctx context.Context, id string) error {
+	err := s.cli.Delete(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: s.namespace, Name: SecretName(id)}})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "revoke api token: %s", id)
+	}
+	return nil
+}
+
+func decode(secret *corev1.Secret) (*model.APIToken, error) {
+	t := &model.APIToken{}
+	err := json.Unmarshal(secret.Data[tokenKey], t)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decode api token: %s", secret.Name)
+	}
+	return t, nil
+}
+
+// Authenticate verifies the api token, the user is scoped to the role of the token by Binding.
+func (s *Store) Authenticate(token string) (*authutil.User, error) {
+	token = authutil.BearerToken(token)
+	if !strings.HasPrefix(token, Prefix) {
+		return nil, errors.New("not an api token")
+	}
+	parts := strings.SplitN(strings.TrimPrefix(token, Prefix), "_", 2)
+	if len(parts) != 2 || len(parts[0]) != 2*idSize {
+		return nil, errors.New("malformed api token")
+	}
+
+	t, h, err := s.Get(context.Background(), parts[0])
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, errors.New("api token is revoked or not found")
+		}
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(h), []byte(hash(token))) != 1 {
+		return nil, errors.New("invalid api token")
+	}
+	if t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt) {
+		return nil, errors.Errorf("api token: %s expired at %s", t.ID, t.ExpiresAt.Format(time.RFC3339))
+	}
+
+	return &authutil.User{
+		Name:   UserName(t.ID),
+		Groups: []string{Group},
+		Issuer: Issuer,
+		Extra: map[string][]string{
+			ExtraOwner:    {t.Owner},
+			ExtraRole:     {t.Role},
+			ExtraClusters: t.Clusters,
+			ExtraTenants:  t.Tenants,
+		},
+	}, nil
+}
+
+// Binding returns the role binding of the scope of the api token user, nil for the other users.
+func Binding(user *authutil.User) *rbac.Binding {
+	if user.Issuer != Issuer || len(user.Extra[ExtraRole]) == 0 {
+		return nil
+	}
+	return &rbac.Binding{
+		Name:     user.Name,
+		Role:     rbac.Role(user.Extra[ExtraRole][0]),
+		Users:    []string{user.Name},
+		Clusters: user.Extra[ExtraClusters],
+		Tenants:  user.Extra[ExtraTenants],
+	}
+}
@@ -0,0 +1,67 @@
+package apitoken
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/apimanager/rbac"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAuthenticate(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	s := NewStore(fake.NewFakeClientWithScheme(scheme), "kunkka-api")
+	ctx := context.TODO()
+
+	ci := &model.APIToken{Name: "ci", Owner: "alice", Role: "operator", Clusters: []string{"c1"}, CreatedAt: time.Now()}
+	token, err := s.Create(ctx, ci)
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Minute)
+	expired, err := s.Create(ctx, &model.APIToken{Name: "old", Owner: "bob", Role: "viewer", Clusters: []string{"*"}, ExpiresAt: &past})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := s.Authenticate("Bearer " + token)
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	b := Binding(user)
+	if user.Name != UserName(ci.ID) || b == nil || b.Role != rbac.RoleOperator {
+		t.Errorf("Authenticate() = %+v, binding %+v", user, b)
+	}
+	sub := &rbac.Subject{User: user.Name, Groups: user.Groups}
+	if rbac.Authorize([]*rbac.Binding{b}, sub, &rbac.Resource{Cluster: "c1"}, rbac.RoleOperator) != nil ||
+		rbac.Authorize([]*rbac.Binding{b}, sub, &rbac.Resource{Cluster: "c2"}, rbac.RoleViewer) == nil {
+		t.Errorf("the token must be limited to operator of c1")
+	}
+
+	for name, tok := range map[string]string{
+		"forged":  token[:len(token)-2] + "xx",
+		"expired": expired,
+		"jwt":     "eyJhbGciOiJIUzI1NiJ9.e30.sig",
+	} {
+		if _, err := s.Authenticate(tok); err == nil {
+			t.Errorf("%s: Authenticate() expected error", name)
+		}
+	}
+
+	list, err := s.List(ctx, "alice")
+	if err != nil || len(list) != 1 || list[0].Token != "" {
+		t.Errorf("List() = %v, %v, want only the token of alice without the plaintext", list, err)
+	}
+
+	if err := s.Revoke(ctx, ci.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Authenticate(token); err == nil {
+		t.Errorf("Authenticate() the revoked token expected error")
+	}
+}
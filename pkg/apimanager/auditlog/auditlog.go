@@ -0,0 +1,161 @@
+// Package auditlog records the mutating and the sensitive apimanager operations in the storage backend,
+// so the incidents can be traced back to the users, e.g. who added a node or downloaded a kubeconfig.
+package auditlog
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/storage"
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+)
+
+const (
+	// Kind the storage kind of the audit events
+	Kind = "audit"
+
+	// ResultSucceeded, ResultFailed the result of the operation by the http status
+	ResultSucceeded = "Succeeded"
+	ResultFailed    = "Failed"
+
+	// keyTimeFormat has a fixed width so the keys are sorted by time
+	keyTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+	// DefaultLimit the events returned by Query if no limit
+	DefaultLimit = 100
+
+	// noneSegment the segment of the key of the event without the cluster or the user
+	noneSegment = "-"
+)
+
+// Key returns the storage key of the event, "<time>/<cluster>/<user>/<id>", so the events are filtered and
+// limited by their keys before they are read.
+func Key(e *model.AuditEvent) string {
+	return strings.Join([]string{e.Time.UTC().Format(keyTimeFormat), keySegment(e.Cluster), keySegment(e.User), e.ID}, "/")
+}
+
+func keySegment(s string) string {
+	if s == "" {
+		return noneSegment
+	}
+	return url.PathEscape(s)
+}
+
+// Result returns the result of the http status.
+func Result(status int) string {
+	if status >= 400 {
+		return ResultFailed
+	}
+	return ResultSucceeded
+}
+
+// Recorder keeps the audit events in the store.
+type Recorder struct {
+	store storage.Store
+}
+
+// NewRecorder ...
+func NewRecorder(store storage.Store) *Recorder {
+	return &Recorder{store: store}
+}
+
+// Record saves the event, it's logged as well so it's not lost if the store fails.
+func (r *Recorder) Record(ctx context.Context, e *model.AuditEvent) error {
+	klog.Infof("audit: user: %s %s %s cluster: %s status: %d source: %s payload: %s",
+		e.User, e.Method, e.Path, e.Cluster, e.Status, e.Source, e.PayloadHash)
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return r.store.Put(ctx, Kind, Key(e), data)
+}
+
+// Query filters the audit events, empty fields match all.
+type Query struct {
+	// Date the UTC date of the events, e.g. 2020-10-01, or any prefix of the time, e.g. 2020-10
+	Date    string
+	Cluster string
+	User    string
+	Limit   int
+}
+
+// matchKey returns whether the event of the key is matched, the keys of "<time>/<id>" recorded before the
+// cluster and the user were in the keys are matched once the events are read, known is false for them.
+func (q *Query) matchKey(key string) (match, known bool) {
+	parts := strings.SplitN(key, "/", 4)
+	if len(parts) != 4 {
+		return true, false
+	}
+	return (q.Cluster == "" || parts[1] == keySegment(q.Cluster)) && (q.User == "" || parts[2] == keySegment(q.User)), true
+}
+
+func (q *Query) match(e *model.AuditEvent) bool {
+	return (q.Cluster == "" || e.Cluster == q.Cluster) && (q.User == "" || e.User == q.User)
+}
+
+// Query returns the matched events, the latest first. The events are matched by the keys listed with the date
+// prefix, only the events returned are read.
+func (r *Recorder) Query(ctx context.Context, q *Query) ([]*model.AuditEvent, error) {
+	keys, err := r.store.List(ctx, Kind, q.Date)
+	if err != nil {
+		return nil, errors.Wrapf(err, "list audit events")
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	events := []*model.AuditEvent{}
+	for i := len(keys) - 1; i >= 0 && len(events) < limit; i-- {
+		match, known := q.matchKey(keys[i])
+		if !match {
+			continue
+		}
+
+		data, err := r.store.Get(ctx, Kind, keys[i])
+		if err != nil {
+			if storage.IsNotFound(err) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "get audit event: %s", keys[i])
+		}
+
+		e := &model.AuditEvent{}
+		if err := json.Unmarshal(data, e); err != nil {
+			klog.Warningf("decode audit event: %s error: %v", keys[i], err)
+			continue
+		}
+		if !known && !q.match(e) {
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// Prune deletes the events before the time, returns the number of the deleted events.
+func (r *Recorder) Prune(ctx context.Context, before time.Time) (int, error) {
+	keys, err := r.store.List(ctx, Kind, "")
+	if err != nil {
+		return 0, errors.Wrapf(err, "list audit events")
+	}
+
+	until := before.UTC().Format(keyTimeFormat)
+	deleted := 0
+	for _, key := range keys {
+		if strings.SplitN(key, "/", 2)[0] >= until {
+			break
+		}
+		if err := r.store.Delete(ctx, Kind, key); err != nil {
+			return deleted, errors.Wrapf(err, "delete audit event: %s", key)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
@@ -0,0 +1,99 @@
+package auditlog
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/storage"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// countingStore counts the reads of the events.
+type countingStore struct {
+	storage.Store
+	gets int
+}
+
+func (s *countingStore) Get(ctx context.Context, kind, key string) ([]byte, error) {
+	s.gets++
+	return s.Store.Get(ctx, kind, key)
+}
+
+func TestRecorder(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	store := &countingStore{Store: storage.NewConfigMapStore(fake.NewFakeClientWithScheme(scheme), "kunkka-storage")}
+	r := NewRecorder(store)
+	ctx := context.TODO()
+
+	day := time.Date(2020, 10, 1, 8, 0, 0, 0, time.UTC)
+	for i, e := range []*model.AuditEvent{
+		{ID: "1", Time: day, User: "alice", Cluster: "c1", Method: "POST", Status: 200},
+		{ID: "2", Time: day.Add(time.Hour), User: "bob", Cluster: "c2", Method: "POST", Status: 403},
+		{ID: "3", Time: day.Add(24 * time.Hour), User: "alice", Cluster: "c2", Method: "DELETE", Status: 200},
+		{ID: "4", Time: day.Add(25 * time.Hour), User: "system:serviceaccount:ns/sa", Method: "GET", Status: 200},
+	} {
+		e.Result = Result(e.Status)
+		if err := r.Record(ctx, e); err != nil {
+			t.Fatalf("Record(%d) error = %v", i, err)
+		}
+	}
+
+	// an event recorded before the cluster and the user were in the keys
+	legacy := &model.AuditEvent{ID: "0", Time: day.Add(-time.Hour), User: "bob", Cluster: "c2", Method: "POST", Status: 200}
+	data, _ := json.Marshal(legacy)
+	if err := store.Put(ctx, Kind, legacy.Time.UTC().Format(keyTimeFormat)+"/"+legacy.ID, data); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		q    *Query
+		want []string
+		gets int
+	}{
+		{"all latest first", &Query{}, []string{"4", "3", "2", "1", "0"}, 5},
+		{"date", &Query{Date: "2020-10-01"}, []string{"2", "1", "0"}, 3},
+		{"cluster", &Query{Cluster: "c2"}, []string{"3", "2", "0"}, 3},
+		{"user and limit", &Query{User: "alice", Limit: 1}, []string{"3"}, 1},
+		{"user of slash", &Query{User: "system:serviceaccount:ns/sa"}, []string{"4"}, 2},
+		{"legacy user", &Query{User: "bob"}, []string{"2", "0"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store.gets = 0
+			events, err := r.Query(ctx, tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if store.gets != tt.gets {
+				t.Errorf("Query() read %d events, want %d", store.gets, tt.gets)
+			}
+			var got []string
+			for _, e := range events {
+				got = append(got, e.ID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Query() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Query() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	n, err := r.Prune(ctx, day.Add(2*time.Hour))
+	if err != nil || n != 3 {
+		t.Errorf("Prune() = %d, %v, want 3", n, err)
+	}
+	if events, _ := r.Query(ctx, &Query{}); len(events) != 2 || events[0].ID != "4" || events[1].ID != "3" {
+		t.Errorf("Prune() kept %v, want the events 4 and 3 only", events)
+	}
+}
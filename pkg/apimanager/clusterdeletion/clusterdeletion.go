@@ -0,0 +1,380 @@
+// Package clusterdeletion deletes the clusters in two steps: a deletion is requested with the preview of the
+// machines, the hosted control plane and the rack addresses going away and a short-lived confirmation token,
+// and confirmed by the same user with the token. The rack addresses are released once the Cluster and its
+// Machines are cleaned up by the controllers, by Sync, so they are never handed out while still in use.
+package clusterdeletion
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/apimanager/rackpool"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/storage"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// Kind the storage kind of the deletions, keyed by the cluster
+	Kind = "clusterdeletion"
+	// TokenTTL the lifetime of the confirmation tokens
+	TokenTTL = 10 * time.Minute
+
+	// the phases of the deletion
+	PhaseConfirming = "Confirming"
+	PhaseDeleting   = "Deleting"
+	PhaseReleased   = "Released"
+
+	// hostedType the type of the clusters whose control plane runs on the meta cluster
+	hostedType = "Hosted"
+)
+
+var (
+	// ErrDeleting the cluster is being deleted.
+	ErrDeleting = errors.New("the cluster is being deleted")
+	// ErrNotRequested no deletion of the cluster waits for the confirmation.
+	ErrNotRequested = errors.New("no deletion of the cluster to confirm, request one first")
+	// ErrExpired the confirmation token is expired.
+	ErrExpired = errors.New("the confirmation token is expired, request a new one")
+	// ErrInvalidToken the confirmation token is not the one issued to the user.
+	ErrInvalidToken = errors.New("invalid confirmation token")
+	// ErrChanged the cluster was recreated since the deletion was requested.
+	ErrChanged = errors.New("the cluster was recreated since the deletion was requested, request a new one")
+)
+
+// Deleter requests, confirms and finishes the deletions of the clusters, kept in the store.
+type Deleter struct {
+	cli   client.Client
+	store storage.Store
+	// racks the ConfigMap of the racks the machines are allocated from
+	racks types.NamespacedName
+
+	mu sync.Mutex
+}
+
+// NewDeleter ...
+func NewDeleter(cli client.Client, store storage.Store, racks types.NamespacedName) *Deleter {
+	return &Deleter{cli: cli, store: store, racks: racks}
+}
+
+// Get returns the deletion of the cluster without its token, storage.ErrNotFound if there's none.
+func (d *Deleter) Get(ctx context.Context, cluster string) (*model.ClusterDeletion, error) {
+	del, err := d.get(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+	del.TokenHash = ""
+	return del, nil
+}
+
+func (d *Deleter) get(ctx context.Context, cluster string) (*model.ClusterDeletion, error) {
+	data, err := d.store.Get(ctx, Kind, cluster)
+	if err != nil {
+		return nil, err
+	}
+	del := &model.ClusterDeletion{}
+	if err := json.Unmarshal(data, del); err != nil {
+		return nil, errors.Wrapf(err, "decode deletion of cluster: %s", cluster)
+	}
+	return del, nil
+}
+
+func (d *Deleter) save(ctx context.Context, del *model.ClusterDeletion) error {
+	token := del.Token
+	del.Token = ""
+	data, err := json.Marshal(del)
+	del.Token = token
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(d.store.Put(ctx, Kind, del.Cluster, data), "save deletion of cluster: %s", del.Cluster)
+}
+
+// Request previews the deletion of the cluster by the user and issues the confirmation token,
+// a pending request of the cluster is replaced.
+func (d *Deleter) Request(ctx context.Context, cluster, user string) (*model.ClusterDeletion, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c := &devopsv1.Cluster{}
+	err := d.cli.Get(ctx, types.NamespacedName{Namespace: cluster, Name: cluster}, c)
+	if err != nil {
+		return nil, err
+	}
+	if !c.DeletionTimestamp.IsZero() {
+		return nil, ErrDeleting
+	}
+	machines := &devopsv1.MachineList{}
+	err = d.cli.List(ctx, machines, client.InNamespace(cluster))
+	if err != nil {
+		return nil, errors.Wrapf(err, "list machines of cluster: %s", cluster)
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	del := &model.ClusterDeletion{
+		Cluster:   cluster,
+		UID:       string(c.UID),
+		Type:      c.Spec.Type,
+		Requester: user,
+		Phase:     PhaseConfirming,
+		Token:     token,
+		TokenHash: hash(token),
+		ExpiresAt: now.Add(TokenTTL),
+		CreatedAt: now,
+	}
+	all := append([]*devopsv1.ClusterMachine{}, c.Spec.Machines...)
+	for i := range machines.Items {
+		all = append(all, machines.Items[i].Spec.Machine)
+	}
+	del.Racks = model.NewRackReleases(all)
+	for _, r := range del.Racks {
+		del.Machines = append(del.Machines, r.IP)
+	}
+	for _, obj := range controlPlane(c) {
+		del.ControlPlane = append(del.ControlPlane, objectName(obj))
+	}
+
+	if err := d.save(ctx, del); err != nil {
+		return nil, err
+	}
+	return del, nil
+}
+
+// Confirm verifies the token of the deletion requested by the user, runs the check of the cluster and deletes it:
+// the hosted control plane is removed and the Cluster is deleted, the controllers clean its Machines up.
+// The force deletion annotates the Cluster and the Machines so the nodes which can't be cleaned are skipped.
+func (d *Deleter) Confirm(ctx context.Context, cluster, user, token string, force bool, check func(*devopsv1.Cluster) error) (*model.ClusterDeletion, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	del, err := d.get(ctx, cluster)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			return nil, ErrNotRequested
+		}
+		return nil, err
+	}
+	switch {
+	case del.Phase == PhaseDeleting:
+		return nil, ErrDeleting
+	case del.Phase != PhaseConfirming:
+		return nil, ErrNotRequested
+	case time.Now().After(del.ExpiresAt):
+		return nil, ErrExpired
+	case del.Requester != user || subtle.ConstantTimeCompare([]byte(hash(token)), []byte(del.TokenHash)) != 1:
+		return nil, ErrInvalidToken
+	}
+
+	c := &devopsv1.Cluster{}
+	err = d.cli.Get(ctx, types.NamespacedName{Namespace: cluster, Name: cluster}, c)
+	if err != nil {
+		return nil, err
+	}
+	if string(c.UID) != del.UID {
+		return nil, ErrChanged
+	}
+	if check != nil {
+		if err := check(c); err != nil {
+			return nil, err
+		}
+	}
+
+	if force {
+		err = d.annotateForce(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, obj := range controlPlane(c) {
+		err := d.cli.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, errors.Wrapf(err, "delete %s of cluster: %s", objectName(obj), cluster)
+		}
+	}
+	err = d.cli.Delete(ctx, c, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, errors.Wrapf(err, "delete cluster: %s", cluster)
+	}
+
+	now := time.Now().UTC()
+	del.Phase = PhaseDeleting
+	del.Force = force
+	del.TokenHash = ""
+	del.DeletedAt = &now
+	if err := d.save(ctx, del); err != nil {
+		return nil, err
+	}
+	return del, nil
+}
+
+// annotateForce annotates the Cluster and its Machines with the force deletion.
+func (d *Deleter) annotateForce(ctx context.Context, c *devopsv1.Cluster) error {
+	machines := &devopsv1.MachineList{}
+	err := d.cli.List(ctx, machines, client.InNamespace(c.Name))
+	if err != nil {
+		return errors.Wrapf(err, "list machines of cluster: %s", c.Name)
+	}
+	objs := []runtime.Object{c}
+	for i := range machines.Items {
+		objs = append(objs, &machines.Items[i])
+	}
+
+	for _, obj := range objs {
+		key, _ := client.ObjectKeyFromObject(obj)
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			if err := d.cli.Get(ctx, key, obj); err != nil {
+				return err
+			}
+			accessor, err := metaAccessor(obj)
+			if err != nil {
+				return err
+			}
+			annotations := accessor.GetAnnotations()
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[constants.ForceDeletion] = "true"
+			accessor.SetAnnotations(annotations)
+			return d.cli.Update(ctx, obj)
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "annotate %s with force deletion", key)
+		}
+	}
+	return nil
+}
+
+// Sync drops the expired requests and releases the rack addresses of the deleted clusters once the
+// Cluster and its Machines are gone.
+func (d *Deleter) Sync(ctx context.Context) error {
+	keys, err := d.store.List(ctx, Kind, "")
+	if err != nil {
+		return errors.Wrapf(err, "list cluster deletions")
+	}
+	sort.Strings(keys)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, key := range keys {
+		del, err := d.get(ctx, key)
+		if err != nil {
+			if !storage.IsNotFound(err) {
+				klog.Errorf("get deletion of cluster: %s error: %v", key, err)
+			}
+			continue
+		}
+
+		switch del.Phase {
+		case PhaseConfirming:
+			if time.Now().After(del.ExpiresAt) {
+				if err := d.store.Delete(ctx, Kind, key); err != nil {
+					klog.Errorf("delete expired deletion of cluster: %s error: %v", key, err)
+				}
+			}
+		case PhaseDeleting:
+			if err := d.release(ctx, del); err != nil {
+				klog.Errorf("release racks of deleted cluster: %s error: %v", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// release releases the rack addresses of the deletion once the cluster is cleaned up.
+func (d *Deleter) release(ctx context.Context, del *model.ClusterDeletion) error {
+	err := d.cli.Get(ctx, types.NamespacedName{Namespace: del.Cluster, Name: del.Cluster}, &devopsv1.Cluster{})
+	if !apierrors.IsNotFound(err) {
+		return err
+	}
+	machines := &devopsv1.MachineList{}
+	err = d.cli.List(ctx, machines, client.InNamespace(del.Cluster))
+	if err != nil {
+		return err
+	}
+	for i := range machines.Items {
+		if machines.Items[i].Spec.ClusterName == del.Cluster {
+			return nil
+		}
+	}
+
+	released, err := rackpool.Release(ctx, d.cli, d.racks, del.Racks)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	now := time.Now().UTC()
+	del.Phase = PhaseReleased
+	del.FinishedAt = &now
+	if apierrors.IsNotFound(err) {
+		del.Message = "no rack cfg, nothing released"
+	}
+	klog.Infof("cluster: %s deleted, released %d of %d rack addresses", del.Cluster, released, len(del.Racks))
+	return d.save(ctx, del)
+}
+
+// controlPlane returns the objects of the control plane of the hosted cluster on the meta cluster.
+func controlPlane(c *devopsv1.Cluster) []runtime.Object {
+	if c.Spec.Type != hostedType {
+		return nil
+	}
+	objs := []runtime.Object{}
+	for _, name := range []string{constants.KubeApiServer, constants.KubeControllerManager, constants.KubeKubeScheduler} {
+		objs = append(objs, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: c.Namespace, Name: name}})
+	}
+	return append(objs, &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: c.Namespace, Name: constants.KubeApiServer}})
+}
+
+func objectName(obj runtime.Object) string {
+	accessor, _ := metaAccessor(obj)
+	switch obj.(type) {
+	case *appsv1.Deployment:
+		return "Deployment/" + accessor.GetName()
+	case *corev1.Service:
+		return "Service/" + accessor.GetName()
+	default:
+		return accessor.GetName()
+	}
+}
+
+func metaAccessor(obj runtime.Object) (metav1.Object, error) {
+	accessor, ok := obj.(metav1.Object)
+	if !ok {
+		return nil, errors.Errorf("%T has no object meta", obj)
+	}
+	return accessor, nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrapf(err, "generate confirmation token")
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
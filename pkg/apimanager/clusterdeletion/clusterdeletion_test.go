@@ -0,0 +1,156 @@
+package clusterdeletion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/apimanager/rackpool"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/storage"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const rackCfg = `
+- rackTag: r1
+  hostAddr:
+  - ipAddr: 10.0.0.1
+    useState: 1
+    isMeta: 0
+  - ipAddr: 10.0.0.2
+    useState: 1
+    isMeta: 0
+  - ipAddr: 10.0.0.9
+    useState: 1
+    isMeta: 1
+  podCidr:
+  - id: cidr-1
+    useState: 1
+  - id: cidr-2
+    useState: 1
+`
+
+func TestDeleter(t *testing.T) {
+	cluster := &devopsv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "c1", Name: "c1", UID: "uid-1"},
+		Spec: devopsv1.ClusterSpec{Type: hostedType, Machines: []*devopsv1.ClusterMachine{
+			{IP: "10.0.0.9"},
+		}},
+	}
+	worker := &devopsv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "c1", Name: "10.0.0.1"},
+		Spec: devopsv1.MachineSpec{ClusterName: "c1", Machine: &devopsv1.ClusterMachine{
+			IP: "10.0.0.1", HostCni: &devopsv1.ClusterCni{ID: "cidr-1", RackTag: "r1"},
+		}},
+	}
+	apiserver := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "c1", Name: constants.KubeApiServer}}
+	racks := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kunkka-api", Name: "kunkka-api"},
+		Data:       map[string]string{rackpool.DataKey: rackCfg},
+	}
+
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	devopsv1.AddToScheme(scheme)
+	cli := fake.NewFakeClientWithScheme(scheme, cluster, worker, apiserver, racks)
+	d := NewDeleter(cli, storage.NewConfigMapStore(cli, "kunkka-storage"), types.NamespacedName{Namespace: "kunkka-api", Name: "kunkka-api"})
+	ctx := context.Background()
+
+	if _, err := d.Confirm(ctx, "c1", "admin", "token", false, nil); err != ErrNotRequested {
+		t.Fatalf("confirm without request: got %v, want %v", err, ErrNotRequested)
+	}
+	del, err := d.Request(ctx, "c1", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if del.Token == "" || del.Phase != PhaseConfirming || len(del.Machines) != 2 || len(del.ControlPlane) != 4 {
+		t.Fatalf("got request %+v", del)
+	}
+	got, err := d.Get(ctx, "c1")
+	if err != nil || got.Token != "" || got.TokenHash != "" {
+		t.Fatalf("get: token leaked %+v %v", got, err)
+	}
+
+	tests := []struct {
+		user  string
+		token string
+		check func(*devopsv1.Cluster) error
+		want  error
+	}{
+		{user: "admin", token: "wrong", want: ErrInvalidToken},
+		{user: "other", token: del.Token, want: ErrInvalidToken},
+		{user: "admin", token: del.Token, check: func(*devopsv1.Cluster) error { return errors.New("blocked") }},
+	}
+	for _, tt := range tests {
+		_, err := d.Confirm(ctx, "c1", tt.user, tt.token, false, tt.check)
+		if err == nil || (tt.want != nil && err != tt.want) {
+			t.Errorf("confirm by %s: got %v, want %v", tt.user, err, tt.want)
+		}
+	}
+
+	del, err = d.Confirm(ctx, "c1", "admin", del.Token, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if del.Phase != PhaseDeleting || !del.Force || del.DeletedAt == nil {
+		t.Fatalf("got confirmed %+v", del)
+	}
+	m := &devopsv1.Machine{}
+	cli.Get(ctx, types.NamespacedName{Namespace: "c1", Name: "10.0.0.1"}, m)
+	if m.Annotations[constants.ForceDeletion] != "true" {
+		t.Errorf("machine not annotated with force deletion: %v", m.Annotations)
+	}
+	err = cli.Get(ctx, types.NamespacedName{Namespace: "c1", Name: constants.KubeApiServer}, &appsv1.Deployment{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("control plane not deleted: %v", err)
+	}
+	if _, err := d.Confirm(ctx, "c1", "admin", "token", false, nil); err != ErrDeleting {
+		t.Errorf("confirm twice: got %v, want %v", err, ErrDeleting)
+	}
+
+	// the racks are kept until the machines are cleaned up
+	if err := d.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := d.Get(ctx, "c1"); got.Phase != PhaseDeleting {
+		t.Fatalf("released with the machines left: %+v", got)
+	}
+	cli.Delete(ctx, m)
+	if err := d.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = d.Get(ctx, "c1")
+	if got.Phase != PhaseReleased || got.FinishedAt == nil {
+		t.Fatalf("not released: %+v", got)
+	}
+
+	cm := &corev1.ConfigMap{}
+	cli.Get(ctx, types.NamespacedName{Namespace: "kunkka-api", Name: "kunkka-api"}, cm)
+	data, _ := yaml.YAMLToJSON([]byte(cm.Data[rackpool.DataKey]))
+	list := []*model.Rack{}
+	json.Unmarshal(data, &list)
+	state := []string{}
+	for _, h := range list[0].HostAddr {
+		state = append(state, fmt.Sprintf("%s=%d", h.IPADDR, h.UseState))
+	}
+	for _, c := range list[0].PodCidr {
+		state = append(state, fmt.Sprintf("%s=%d", c.ID, c.UseState))
+	}
+	want := "10.0.0.1=0,10.0.0.2=1,10.0.0.9=1,cidr-1=0,cidr-2=1"
+	if strings.Join(state, ",") != want {
+		t.Errorf("got racks %s, want %s", strings.Join(state, ","), want)
+	}
+}
@@ -7,7 +7,13 @@ import (
 	"net"
 	"net/http"
 	"runtime"
+	"strings"
 	"time"
+
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // TCPDialCheck returns a Check that checks TCP connectivity to the provided endpoint.
@@ -87,3 +93,61 @@ func GoroutineCountCheck(threshold int) Check {
 		return nil
 	}
 }
+
+// KubeAPICheck returns a Check that the apiserver of the client answers ok
+// on /healthz within the timeout.
+func KubeAPICheck(cli kubernetes.Interface, timeout time.Duration) Check {
+	return func() error {
+		return withTimeout(timeout, func(ctx context.Context) error {
+			body, err := cli.Discovery().RESTClient().Get().AbsPath("/healthz").Do(ctx).Raw()
+			if err != nil {
+				return err
+			}
+			if !strings.EqualFold(string(body), "ok") {
+				return fmt.Errorf("apiserver healthz: %s", body)
+			}
+			return nil
+		})
+	}
+}
+
+// CacheSyncCheck returns a Check that the informers of the cache are started
+// and synced within the timeout.
+func CacheSyncCheck(c cache.Cache, timeout time.Duration) Check {
+	return func() error {
+		stop := make(chan struct{})
+		timer := time.AfterFunc(timeout, func() { close(stop) })
+		defer timer.Stop()
+		if !c.WaitForCacheSync(stop) {
+			return fmt.Errorf("informers not synced in %s", timeout)
+		}
+		return nil
+	}
+}
+
+// ClientCheck returns a Check that the client lists the objects of newList
+// within the timeout, a wedged client fails it.
+func ClientCheck(cli client.Client, newList func() k8sruntime.Object, timeout time.Duration) Check {
+	return func() error {
+		return withTimeout(timeout, func(ctx context.Context) error {
+			return cli.List(ctx, newList(), client.Limit(1))
+		})
+	}
+}
+
+// withTimeout runs fn and fails once the timeout is reached even if fn
+// doesn't return on the cancel of its context.
+func withTimeout(timeout time.Duration, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+}
@@ -71,6 +71,16 @@ func (s *basicHandler) Routes() []*router.Route {
 			Path:    "/ready",
 			Handler: s.ReadyEndpoint,
 		},
+		&router.Route{
+			Method:  "GET",
+			Path:    router.HealthzPath,
+			Handler: s.LiveEndpoint,
+		},
+		&router.Route{
+			Method:  "GET",
+			Path:    router.ReadyzPath,
+			Handler: s.ReadyEndpoint,
+		},
 	}
 
 	routes = append(routes, ctlRoutes...)
@@ -119,25 +129,44 @@ func (s *basicHandler) RemoveReadinessCheck(name string) {
 	}
 }
 
-func (s *basicHandler) collectChecks(checks map[string]Check, resultsOut map[string]string, statusOut *int) {
+// collectChecks runs all the checks in parallel so the probes take the time of the slowest check.
+func (s *basicHandler) collectChecks(checks []map[string]Check, resultsOut map[string]string, statusOut *int) {
 	s.checksMutex.RLock()
 	defer s.checksMutex.RUnlock()
-	for name, check := range checks {
-		if err := check(); err != nil {
-			*statusOut = http.StatusServiceUnavailable
-			resultsOut[name] = err.Error()
-		} else {
-			resultsOut[name] = "OK"
+
+	var (
+		wg       sync.WaitGroup
+		resultMu sync.Mutex
+	)
+	all := map[string]Check{}
+	for _, m := range checks {
+		for name, check := range m {
+			all[name] = check
 		}
 	}
+	for name, check := range all {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			err := check()
+
+			resultMu.Lock()
+			defer resultMu.Unlock()
+			if err != nil {
+				*statusOut = http.StatusServiceUnavailable
+				resultsOut[name] = err.Error()
+			} else {
+				resultsOut[name] = "OK"
+			}
+		}(name, check)
+	}
+	wg.Wait()
 }
 
 func (s *basicHandler) handle(ctx *gin.Context, checks ...map[string]Check) {
 	checkResults := make(map[string]string)
 	status := http.StatusOK
-	for _, checks := range checks {
-		s.collectChecks(checks, checkResults, &status)
-	}
+	s.collectChecks(checks, checkResults, &status)
 
 	// unless ?full=true, return an empty body. Kubernetes only cares about the
 	// HTTP status code, so we won't waste bytes on the full body.
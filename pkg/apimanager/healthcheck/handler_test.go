@@ -0,0 +1,85 @@
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHandler(t *testing.T) {
+	h := &basicHandler{
+		livenessChecks:  make(map[string]Check),
+		readinessChecks: make(map[string]Check),
+	}
+	slow := func() error {
+		time.Sleep(200 * time.Millisecond)
+		return nil
+	}
+	h.AddLivenessCheck("live", slow)
+	h.AddReadinessCheck("slow", slow)
+	h.AddReadinessCheck("cache", func() error { return errors.New("informers not synced") })
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	for _, r := range h.Routes() {
+		e.Handle(r.Method, r.Path, r.Handler)
+	}
+
+	tests := []struct {
+		path   string
+		status int
+		want   map[string]string
+	}{
+		{path: "/healthz?full=true", status: http.StatusOK, want: map[string]string{"live": "OK"}},
+		{path: "/live", status: http.StatusOK},
+		{path: "/readyz?full=true", status: http.StatusServiceUnavailable,
+			want: map[string]string{"live": "OK", "slow": "OK", "cache": "informers not synced"}},
+		{path: "/ready", status: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		start := time.Now()
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: got status %d, want %d", tt.path, w.Code, tt.status)
+		}
+		// the checks run in parallel
+		if d := time.Since(start); d > 350*time.Millisecond {
+			t.Errorf("%s: took %s", tt.path, d)
+		}
+		if tt.want == nil {
+			continue
+		}
+		got := map[string]string{}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: %v", tt.path, err)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.path, got, tt.want)
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("%s: got %s=%q, want %q", tt.path, k, got[k], v)
+			}
+		}
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	err := withTimeout(50*time.Millisecond, func(ctx context.Context) error {
+		// a wedged call ignoring the context
+		<-block
+		return nil
+	})
+	if err == nil {
+		t.Fatal("wedged call not timed out")
+	}
+}
@@ -0,0 +1,523 @@
+// Package keyrotation rotates the ssh keys of all the machines of a cluster: the new key is authorized
+// on every machine and verified before the specs are updated and the old key is removed,
+// so a failure on any machine rolls back and leaves the machines reachable with the old credentials.
+package keyrotation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/credential"
+	"github.com/gostship/kunkka/pkg/storage"
+	"github.com/gostship/kunkka/pkg/util/ssh"
+	"github.com/gostship/kunkka/pkg/util/uidutil"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// Kind the storage kind of the rotations
+	Kind = "sshkeyrotation"
+	// KeyBits the size of the generated rsa keys
+	KeyBits = 4096
+
+	// the phases of the rotation
+	PhaseDistributing = "Distributing"
+	PhaseVerifying    = "Verifying"
+	PhaseUpdating     = "Updating"
+	PhaseRemovingOld  = "RemovingOld"
+	PhaseSucceeded    = "Succeeded"
+	PhaseFailed       = "Failed"
+
+	// the phases of the machines
+	MachinePending    = "Pending"
+	MachineAuthorized = "Authorized"
+	MachineVerified   = "Verified"
+	MachineUpdated    = "Updated"
+	MachineRotated    = "Rotated"
+	MachineRolledBack = "RolledBack"
+	MachineFailed     = "Failed"
+
+	// parallelism the machines connected at the same time
+	parallelism = 10
+)
+
+// ErrRunning another rotation of the cluster is running.
+var ErrRunning = errors.New("another ssh key rotation of the cluster is running")
+
+// Key returns the storage key of the rotation.
+func Key(cluster, id string) string {
+	return cluster + "/" + id
+}
+
+// Finished returns whether the rotation is done.
+func Finished(rot *model.SSHKeyRotation) bool {
+	return rot.Phase == PhaseSucceeded || rot.Phase == PhaseFailed
+}
+
+// target a machine of the cluster and its current credentials.
+type target struct {
+	status  *model.SSHKeyRotationMachine
+	machine devopsv1.ClusterMachine
+	oldKey  string
+}
+
+// Rotator runs the rotations in the background and keeps them in the store.
+type Rotator struct {
+	cli   client.Client
+	store storage.Store
+
+	mu sync.Mutex
+	// running the id of the running rotation of the clusters
+	running map[string]string
+}
+
+// NewRotator ...
+func NewRotator(cli client.Client, store storage.Store) *Rotator {
+	return &Rotator{cli: cli, store: store, running: make(map[string]string)}
+}
+
+// targets returns the masters of the cluster spec and the machines of the cluster, the same ip only once.
+func (r *Rotator) targets(ctx context.Context, cluster string) ([]*target, error) {
+	c := &devopsv1.Cluster{}
+	err := r.cli.Get(ctx, types.NamespacedName{Namespace: cluster, Name: cluster}, c)
+	if err != nil {
+		return nil, err
+	}
+	machines := &devopsv1.MachineList{}
+	err = r.cli.List(ctx, machines, client.InNamespace(cluster))
+	if err != nil {
+		return nil, errors.Wrapf(err, "list machines of cluster: %s", cluster)
+	}
+
+	var targets []*target
+	seen := map[string]bool{}
+	add := func(name string, m *devopsv1.ClusterMachine, master bool) error {
+		if m == nil || seen[m.IP] {
+			return nil
+		}
+		seen[m.IP] = true
+		if ref := m.CredentialRef; ref != nil && ref.SecretName == "" {
+			return errors.Errorf("machine: %s(%s) ssh credential is kept in vault, rotate it in vault", name, m.IP)
+		}
+		if err := credential.LoadSSH(ctx, r.cli, cluster, m); err != nil {
+			return err
+		}
+		cred, err := m.SSHCredential()
+		if err != nil {
+			return err
+		}
+		if cred.Password == "" && len(cred.PrivateKey) == 0 {
+			return errors.Errorf("machine: %s(%s) has no ssh credential", name, m.IP)
+		}
+		c.DefaultBastions(m)
+		m.Audit = devopsv1.SSHAudit{Cluster: cluster, Phase: "SSHKeyRotation"}
+		t := &target{
+			status:  &model.SSHKeyRotationMachine{Name: name, IP: m.IP, Master: master, Phase: MachinePending},
+			machine: *m,
+		}
+		if len(cred.PrivateKey) != 0 {
+			key, err := ssh.AuthorizedKey(cred.PrivateKey, cred.PassPhrase, "")
+			if err != nil {
+				return errors.Wrapf(err, "machine: %s(%s) private key", name, m.IP)
+			}
+			t.oldKey = key
+			t.status.OldFingerprint, _ = ssh.Fingerprint(key)
+		}
+		targets = append(targets, t)
+		return nil
+	}
+
+	for _, m := range c.Spec.Machines {
+		if err := add(cluster, m, true); err != nil {
+			return nil, err
+		}
+	}
+	for i := range machines.Items {
+		if machines.Items[i].Spec.ClusterName != cluster {
+			continue
+		}
+		if err := add(machines.Items[i].Name, machines.Items[i].Spec.Machine, false); err != nil {
+			return nil, err
+		}
+	}
+	if len(targets) == 0 {
+		return nil, errors.Errorf("cluster: %s has no machine", cluster)
+	}
+	return targets, nil
+}
+
+// Start starts the rotation of the machines of the cluster to the private key, a new rsa key if it's empty.
+func (r *Rotator) Start(ctx context.Context, cluster, user string, privateKey, passPhrase []byte) (*model.SSHKeyRotation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.running[cluster]; ok {
+		return nil, ErrRunning
+	}
+
+	targets, err := r.targets(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	comment := fmt.Sprintf("kunkka-%s-%s", cluster, now.Format("20060102"))
+	var newKey string
+	if len(privateKey) == 0 {
+		privateKey, newKey, err = ssh.GenerateKey(KeyBits, comment)
+		passPhrase = nil
+	} else {
+		newKey, err = ssh.AuthorizedKey(privateKey, passPhrase, comment)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rot := &model.SSHKeyRotation{
+		ID:        uidutil.GenerateId(),
+		Cluster:   cluster,
+		User:      user,
+		Phase:     PhaseDistributing,
+		CreatedAt: now,
+	}
+	rot.Fingerprint, _ = ssh.Fingerprint(newKey)
+	for _, t := range targets {
+		rot.Machines = append(rot.Machines, t.status)
+	}
+	if err := r.save(ctx, rot); err != nil {
+		return nil, err
+	}
+	// the rotation is updated by run, a copy is returned
+	data, err := json.Marshal(rot)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &model.SSHKeyRotation{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, err
+	}
+
+	r.running[cluster] = rot.ID
+	go r.run(rot, targets, privateKey, passPhrase, newKey)
+	return snapshot, nil
+}
+
+func (r *Rotator) save(ctx context.Context, rot *model.SSHKeyRotation) error {
+	data, err := json.Marshal(rot)
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(r.store.Put(ctx, Kind, Key(rot.Cluster, rot.ID), data), "save ssh key rotation: %s", rot.ID)
+}
+
+// each runs fn on the targets in the phase in parallel, the succeeded ones move to the next phase.
+func each(targets []*target, phase, next string, fn func(t *target) error) bool {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallelism)
+	for _, t := range targets {
+		if t.status.Phase != phase {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(t *target) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := fn(t); err != nil {
+				t.status.Phase = MachineFailed
+				t.status.Message = err.Error()
+				return
+			}
+			t.status.Phase = next
+		}(t)
+	}
+	wg.Wait()
+
+	for _, t := range targets {
+		if t.status.Phase != next {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Rotator) run(rot *model.SSHKeyRotation, targets []*target, privateKey, passPhrase []byte, newKey string) {
+	ctx := context.Background()
+	defer func() {
+		now := time.Now().UTC()
+		rot.FinishedAt = &now
+		if err := r.save(ctx, rot); err != nil {
+			klog.Errorf("ssh key rotation: %s of cluster: %s error: %v", rot.ID, rot.Cluster, err)
+		}
+		klog.Infof("ssh key rotation: %s of cluster: %s %s: %s", rot.ID, rot.Cluster, rot.Phase, rot.Message)
+
+		r.mu.Lock()
+		delete(r.running, rot.Cluster)
+		r.mu.Unlock()
+	}()
+	setPhase := func(phase string) {
+		rot.Phase = phase
+		if err := r.save(ctx, rot); err != nil {
+			klog.Warningf("ssh key rotation: %s of cluster: %s phase: %s error: %v", rot.ID, rot.Cluster, phase, err)
+		}
+	}
+	newCredential := func(t *target) devopsv1.ClusterMachine {
+		m := t.machine
+		m.CredentialRef = nil
+		m.Credential = nil
+		m.Password = ""
+		m.PrivateKey = privateKey
+		m.PassPhrase = passPhrase
+		return m
+	}
+
+	// the machines keep the old credentials till the new key works on all of them
+	ok := each(targets, MachinePending, MachineAuthorized, func(t *target) error {
+		sh, err := t.machine.SSH()
+		if err != nil {
+			return err
+		}
+		return ssh.AddAuthorizedKey(sh, newKey)
+	})
+	if ok {
+		setPhase(PhaseVerifying)
+		ok = each(targets, MachineAuthorized, MachineVerified, func(t *target) error {
+			m := newCredential(t)
+			sh, err := m.SSH()
+			if err != nil {
+				return err
+			}
+			return errors.Wrap(sh.Ping(), "connect with the new key")
+		})
+	}
+	if !ok {
+		r.rollback(rot, targets, newKey)
+		return
+	}
+
+	setPhase(PhaseUpdating)
+	if err := r.updateSpecs(ctx, rot.Cluster, targets, privateKey, passPhrase); err != nil {
+		// both the keys are authorized, the machines are reachable whichever the specs have
+		rot.Phase = PhaseFailed
+		rot.Message = fmt.Sprintf("the new key is authorized on all the machines but the specs are not updated, retry the rotation: %v", err)
+		return
+	}
+
+	setPhase(PhaseRemovingOld)
+	newBlob := strings.Join(strings.Fields(newKey)[:2], " ")
+	left := 0
+	for _, t := range targets {
+		t.status.Phase = MachineUpdated
+	}
+	each(targets, MachineUpdated, MachineRotated, func(t *target) error {
+		if t.oldKey == "" || t.oldKey == newBlob {
+			return nil
+		}
+		m := newCredential(t)
+		sh, err := m.SSH()
+		if err != nil {
+			return err
+		}
+		return ssh.RemoveAuthorizedKey(sh, t.oldKey)
+	})
+	for _, t := range targets {
+		if t.status.Phase == MachineFailed {
+			// the specs have the new key, only the old key is left behind
+			t.status.Phase = MachineUpdated
+			t.status.Message = "old key is not removed: " + t.status.Message
+			left++
+		}
+	}
+
+	rot.Phase = PhaseSucceeded
+	if left > 0 {
+		rot.Message = fmt.Sprintf("the old key is left on %d machines", left)
+	}
+}
+
+// rollback removes the new key from the machines it's authorized on, with the old credentials.
+func (r *Rotator) rollback(rot *model.SSHKeyRotation, targets []*target, newKey string) {
+	var failed []string
+	for _, t := range targets {
+		if t.status.Phase == MachineFailed {
+			failed = append(failed, fmt.Sprintf("%s(%s): %s", t.status.Name, t.status.IP, t.status.Message))
+		}
+	}
+
+	for _, t := range targets {
+		switch t.status.Phase {
+		case MachineAuthorized, MachineVerified:
+		case MachineFailed:
+			// the key may be added before the failure, e.g. the new key is authorized but doesn't work
+			if !strings.HasPrefix(t.status.Message, "connect with the new key") {
+				continue
+			}
+		default:
+			continue
+		}
+		sh, err := t.machine.SSH()
+		if err == nil {
+			err = ssh.RemoveAuthorizedKey(sh, newKey)
+		}
+		if err != nil {
+			t.status.Message = strings.TrimPrefix(t.status.Message+"; ", "; ") + "rollback: " + err.Error()
+			t.status.Phase = MachineFailed
+			continue
+		}
+		if t.status.Phase != MachineFailed {
+			t.status.Phase = MachineRolledBack
+		}
+	}
+
+	rot.Phase = PhaseFailed
+	rot.Message = "rolled back, the machines keep the old credentials, failed: " + strings.Join(failed, "; ")
+}
+
+// updateSpecs replaces the ssh credentials of the masters in the cluster spec and of the machines of the cluster.
+func (r *Rotator) updateSpecs(ctx context.Context, cluster string, targets []*target, privateKey, passPhrase []byte) error {
+	ips := map[string]bool{}
+	for _, t := range targets {
+		ips[t.status.IP] = true
+	}
+	// the credentials referenced by the machines are replaced in the Secrets instead
+	secrets := map[string]bool{}
+	set := func(m *devopsv1.ClusterMachine) bool {
+		if m == nil || !ips[m.IP] {
+			return false
+		}
+		if m.CredentialRef != nil {
+			secrets[m.CredentialRef.SecretName] = true
+			return false
+		}
+		m.PrivateKey = privateKey
+		m.PassPhrase = passPhrase
+		return true
+	}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		c := &devopsv1.Cluster{}
+		if err := r.cli.Get(ctx, types.NamespacedName{Namespace: cluster, Name: cluster}, c); err != nil {
+			return err
+		}
+		changed := false
+		for _, m := range c.Spec.Machines {
+			changed = set(m) || changed
+		}
+		if !changed {
+			return nil
+		}
+		return r.cli.Update(ctx, c)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "update cluster: %s", cluster)
+	}
+
+	machines := &devopsv1.MachineList{}
+	err = r.cli.List(ctx, machines, client.InNamespace(cluster))
+	if err != nil {
+		return errors.Wrapf(err, "list machines of cluster: %s", cluster)
+	}
+	for i := range machines.Items {
+		key := types.NamespacedName{Namespace: machines.Items[i].Namespace, Name: machines.Items[i].Name}
+		if machines.Items[i].Spec.ClusterName != cluster || machines.Items[i].Spec.Machine == nil || !ips[machines.Items[i].Spec.Machine.IP] {
+			continue
+		}
+		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			m := &devopsv1.Machine{}
+			if err := r.cli.Get(ctx, key, m); err != nil {
+				return err
+			}
+			if !set(m.Spec.Machine) {
+				return nil
+			}
+			return r.cli.Update(ctx, m)
+		})
+		if err != nil {
+			return errors.Wrapf(err, "update machine: %s", key.Name)
+		}
+	}
+
+	for name := range secrets {
+		key := types.NamespacedName{Namespace: cluster, Name: name}
+		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			s := &corev1.Secret{}
+			if err := r.cli.Get(ctx, key, s); err != nil {
+				return err
+			}
+			if s.Data == nil {
+				s.Data = map[string][]byte{}
+			}
+			s.Data[credential.SSHPrivateKeyKey] = privateKey
+			if len(passPhrase) != 0 {
+				s.Data[credential.SSHPassPhraseKey] = passPhrase
+			} else {
+				delete(s.Data, credential.SSHPassPhraseKey)
+			}
+			return r.cli.Update(ctx, s)
+		})
+		if err != nil {
+			return errors.Wrapf(err, "update ssh credential secret: %s", key.Name)
+		}
+	}
+	return nil
+}
+
+// interrupted reports the rotations left unfinished by a restart of the apimanager as failed.
+func (r *Rotator) interrupted(rot *model.SSHKeyRotation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !Finished(rot) && r.running[rot.Cluster] != rot.ID {
+		rot.Phase = PhaseFailed
+		rot.Message = "interrupted by the restart of the apimanager, the machines may have both the keys authorized, retry the rotation"
+	}
+}
+
+// Get returns the rotation of the cluster.
+func (r *Rotator) Get(ctx context.Context, cluster, id string) (*model.SSHKeyRotation, error) {
+	data, err := r.store.Get(ctx, Kind, Key(cluster, id))
+	if err != nil {
+		return nil, err
+	}
+	rot := &model.SSHKeyRotation{}
+	if err := json.Unmarshal(data, rot); err != nil {
+		return nil, errors.Wrapf(err, "decode ssh key rotation: %s", id)
+	}
+	r.interrupted(rot)
+	return rot, nil
+}
+
+// List returns the rotations of the cluster, the latest first.
+func (r *Rotator) List(ctx context.Context, cluster string) ([]*model.SSHKeyRotation, error) {
+	keys, err := r.store.List(ctx, Kind, cluster+"/")
+	if err != nil {
+		return nil, errors.Wrapf(err, "list ssh key rotations of cluster: %s", cluster)
+	}
+	list := make([]*model.SSHKeyRotation, 0, len(keys))
+	for _, key := range keys {
+		rot, err := r.Get(ctx, cluster, strings.TrimPrefix(key, cluster+"/"))
+		if err != nil {
+			if storage.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		list = append(list, rot)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	return list, nil
+}
@@ -0,0 +1,162 @@
+package keyrotation
+
+import (
+	"context"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/credential"
+	"github.com/gostship/kunkka/pkg/storage"
+	"github.com/gostship/kunkka/pkg/util/ssh"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestTargetsAndUpdateSpecs(t *testing.T) {
+	oldKey, _, err := ssh.GenerateKey(1024, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, _, err := ssh.GenerateKey(1024, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := &devopsv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "c1", Name: "c1"},
+		Spec: devopsv1.ClusterSpec{Machines: []*devopsv1.ClusterMachine{
+			{IP: "10.0.0.1", Port: 22, Username: "root", Password: "secret"},
+			{IP: "10.0.0.2", Port: 22, Username: "root", PrivateKey: oldKey},
+		}},
+	}
+	worker := &devopsv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "c1", Name: "10.0.0.3"},
+		Spec: devopsv1.MachineSpec{ClusterName: "c1", Machine: &devopsv1.ClusterMachine{
+			IP: "10.0.0.3", Port: 22, Username: "root", PrivateKey: oldKey,
+		}},
+	}
+	// the master has a Machine as well
+	master := &devopsv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "c1", Name: "10.0.0.1"},
+		Spec: devopsv1.MachineSpec{ClusterName: "c1", Machine: &devopsv1.ClusterMachine{
+			IP: "10.0.0.1", Port: 22, Username: "root", Password: "secret",
+		}},
+	}
+
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	devopsv1.AddToScheme(scheme)
+	cli := fake.NewFakeClientWithScheme(scheme, cluster, worker, master)
+	r := NewRotator(cli, storage.NewConfigMapStore(cli, "kunkka-storage"))
+	ctx := context.Background()
+
+	targets, err := r.targets(ctx, "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 3 {
+		t.Fatalf("got %d targets, want 3", len(targets))
+	}
+	for _, tg := range targets {
+		master := tg.status.IP != "10.0.0.3"
+		if tg.status.Master != master {
+			t.Errorf("%s: master: %v, want %v", tg.status.IP, tg.status.Master, master)
+		}
+		if (tg.oldKey == "") != (tg.status.IP == "10.0.0.1") {
+			t.Errorf("%s: old key: %q", tg.status.IP, tg.oldKey)
+		}
+	}
+
+	err = r.updateSpecs(ctx, "c1", targets, newKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &devopsv1.Cluster{}
+	cli.Get(ctx, types.NamespacedName{Namespace: "c1", Name: "c1"}, got)
+	for _, m := range got.Spec.Machines {
+		if string(m.PrivateKey) != string(newKey) {
+			t.Errorf("master: %s private key is not rotated", m.IP)
+		}
+	}
+	if got.Spec.Machines[0].Password != "secret" {
+		t.Errorf("master: %s password changed", got.Spec.Machines[0].IP)
+	}
+	for _, name := range []string{"10.0.0.1", "10.0.0.3"} {
+		m := &devopsv1.Machine{}
+		cli.Get(ctx, types.NamespacedName{Namespace: "c1", Name: name}, m)
+		if string(m.Spec.Machine.PrivateKey) != string(newKey) {
+			t.Errorf("machine: %s private key is not rotated", name)
+		}
+	}
+
+	worker.Spec.Machine.PrivateKey = nil
+	cli = fake.NewFakeClientWithScheme(scheme, cluster, worker)
+	r = NewRotator(cli, storage.NewConfigMapStore(cli, "kunkka-storage"))
+	if _, err := r.Start(ctx, "c1", "alice", nil, nil); err == nil {
+		t.Error("machine without ssh credential rotated")
+	}
+}
+
+func TestRotateReferencedCredential(t *testing.T) {
+	oldKey, _, err := ssh.GenerateKey(1024, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, _, err := ssh.GenerateKey(1024, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := &devopsv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "c1", Name: "c1"},
+		Spec: devopsv1.ClusterSpec{Machines: []*devopsv1.ClusterMachine{
+			{IP: "10.0.0.1", Port: 22, Username: "root", CredentialRef: &devopsv1.SSHCredentialRef{SecretName: "ssh"}},
+		}},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "c1", Name: "ssh"},
+		Data:       map[string][]byte{credential.SSHPrivateKeyKey: oldKey, credential.SSHPasswordKey: []byte("secret")},
+	}
+
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	devopsv1.AddToScheme(scheme)
+	cli := fake.NewFakeClientWithScheme(scheme, cluster, secret)
+	r := NewRotator(cli, storage.NewConfigMapStore(cli, "kunkka-storage"))
+	ctx := context.Background()
+
+	targets, err := r.targets(ctx, "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 1 || targets[0].oldKey == "" {
+		t.Fatalf("targets: %v", targets)
+	}
+	if err := r.updateSpecs(ctx, "c1", targets, newKey, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the key is replaced in the Secret, the spec keeps referencing it
+	got := &corev1.Secret{}
+	cli.Get(ctx, types.NamespacedName{Namespace: "c1", Name: "ssh"}, got)
+	if string(got.Data[credential.SSHPrivateKeyKey]) != string(newKey) || string(got.Data[credential.SSHPasswordKey]) != "secret" {
+		t.Errorf("secret is not rotated: %v", got.Data)
+	}
+	c := &devopsv1.Cluster{}
+	cli.Get(ctx, types.NamespacedName{Namespace: "c1", Name: "c1"}, c)
+	if len(c.Spec.Machines[0].PrivateKey) != 0 || c.Spec.Machines[0].CredentialRef == nil {
+		t.Errorf("spec changed: %+v", c.Spec.Machines[0])
+	}
+
+	// the credentials in vault are rotated in vault
+	cluster.Spec.Machines[0].CredentialRef = &devopsv1.SSHCredentialRef{Vault: &devopsv1.VaultSecretRef{Path: "secret/data/c1"}}
+	cli = fake.NewFakeClientWithScheme(scheme, cluster)
+	r = NewRotator(cli, storage.NewConfigMapStore(cli, "kunkka-storage"))
+	if _, err := r.targets(ctx, "c1"); err == nil {
+		t.Error("machine with the credential in vault rotated")
+	}
+}
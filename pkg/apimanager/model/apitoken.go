@@ -0,0 +1,65 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gostship/kunkka/pkg/util/validation"
+)
+
+// API token, 供 CI 等自动化客户端调用 api, 权限为创建时指定的角色及集群/租户, 只保存 token 的 sha256
+type APIToken struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Owner       string     `json:"owner"`
+	Role        string     `json:"role"`
+	Clusters    []string   `json:"clusters,omitempty"`
+	Tenants     []string   `json:"tenants,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	// Token 明文, 只在创建时返回一次
+	Token string `json:"token,omitempty"`
+}
+
+// 创建 API token 的参数, ttlSeconds 为 0 不过期
+type APITokenRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Role        string   `json:"role"`
+	Clusters    []string `json:"clusters"`
+	Tenants     []string `json:"tenants"`
+	TTLSeconds  int64    `json:"ttlSeconds"`
+}
+
+// Sanitize requires the name and the scope of the token, the clusters end up in the role binding of the token.
+func (r *APITokenRequest) Sanitize() error {
+	name, err := validation.SanitizeName(r.Name)
+	if err != nil {
+		return fmt.Errorf("name: %v", err)
+	}
+	r.Name = name
+	r.Description = strings.TrimSpace(r.Description)
+
+	if len(r.Clusters) == 0 && len(r.Tenants) == 0 {
+		return fmt.Errorf("clusters or tenants: must be specified")
+	}
+	for i, c := range r.Clusters {
+		if c == "*" {
+			continue
+		}
+		if r.Clusters[i], err = validation.SanitizeName(c); err != nil {
+			return fmt.Errorf("clusters: %v", err)
+		}
+	}
+	for i, t := range r.Tenants {
+		if r.Tenants[i] = strings.TrimSpace(t); r.Tenants[i] == "" {
+			return fmt.Errorf("tenants: must not be empty")
+		}
+	}
+	if r.TTLSeconds < 0 {
+		return fmt.Errorf("ttlSeconds: must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// 变更操作的审计记录, payloadHash 为请求体的 sha256, 请求体本身可能包含密码等敏感信息因此不保存
+type AuditEvent struct {
+	ID          string    `json:"id"`
+	Time        time.Time `json:"time"`
+	User        string    `json:"user"`
+	Groups      []string  `json:"groups,omitempty"`
+	Source      string    `json:"source"`
+	Method      string    `json:"method"`
+	Path        string    `json:"path"`
+	Route       string    `json:"route"`
+	Cluster     string    `json:"cluster,omitempty"`
+	PayloadHash string    `json:"payloadHash,omitempty"`
+	Status      int       `json:"status"`
+	Result      string    `json:"result"`
+	LatencyMs   int64     `json:"latencyMs"`
+	// Detail set by the handler, e.g. the lifetime of the credential handed out
+	Detail string `json:"detail,omitempty"`
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gostship/kunkka/pkg/util/validation"
+)
+
+const (
+	BreakGlassPending  = "Pending"
+	BreakGlassApproved = "Approved"
+	BreakGlassExpired  = "Expired"
+)
+
+// 紧急访问申请, 需要申请人之外的另一个管理员批准后才能取回托管的集群凭证
+type BreakGlassRequest struct {
+	ID         string             `json:"id"`
+	Cluster    string             `json:"cluster"`
+	Reason     string             `json:"reason"`
+	Requester  string             `json:"requester"`
+	Approver   string             `json:"approver"`
+	Status     string             `json:"status"`
+	CreatedAt  time.Time          `json:"createdAt"`
+	ApprovedAt *time.Time         `json:"approvedAt,omitempty"`
+	ExpiresAt  *time.Time         `json:"expiresAt,omitempty"`
+	Audit      []*BreakGlassAudit `json:"audit"`
+}
+
+// 紧急访问审计记录
+type BreakGlassAudit struct {
+	Time   time.Time `json:"time"`
+	User   string    `json:"user"`
+	Action string    `json:"action"`
+	Source string    `json:"source"`
+}
+
+// Sanitize trims the cluster name which ends up in labels and requires the reason for the audit.
+func (r *BreakGlassRequest) Sanitize() error {
+	name, err := validation.SanitizeName(r.Cluster)
+	if err != nil {
+		return fmt.Errorf("cluster: %v", err)
+	}
+	r.Cluster = name
+
+	r.Reason = strings.TrimSpace(r.Reason)
+	if r.Reason == "" {
+		return fmt.Errorf("reason: must be specified")
+	}
+	return nil
+}
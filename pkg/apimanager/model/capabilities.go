@@ -0,0 +1,27 @@
+package model
+
+// 部署支持的能力, UI 及 CLI 据此动态调整而不是写死
+type Capabilities struct {
+	// 集群类型
+	Providers []string `json:"providers"`
+	// 组件及版本, 版本为空表示跟随集群版本
+	Addons []*AddonCapability `json:"addons"`
+	// 支持的 kubernetes 版本
+	KubernetesVersions []string `json:"kubernetesVersions"`
+	// 支持的 docker 版本
+	DockerVersions []string `json:"dockerVersions"`
+	// 启用的认证方式
+	AuthModes []string `json:"authModes"`
+	// 支持的 api 版本, v1 中有 v2 替代的路由已废弃
+	APIVersions []string `json:"apiVersions"`
+	// 功能开关
+	Features map[string]bool `json:"features"`
+}
+
+// 组件
+type AddonCapability struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	// 是否需要在集群中显式开启
+	Optional bool `json:"optional"`
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"time"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+)
+
+// 集群删除, 确认 token 只在申请删除时返回一次, 删除后等集群及机器清理完成再释放机柜中的机器及 pod 地址段
+type ClusterDeletion struct {
+	Cluster   string `json:"cluster"`
+	UID       string `json:"uid"`
+	Type      string `json:"type"`
+	Requester string `json:"requester"`
+	// Phase Confirming, Deleting, Released
+	Phase   string `json:"phase"`
+	Message string `json:"message,omitempty"`
+	Force   bool   `json:"force"`
+	// Token 确认删除的 token, 只在申请时返回, TokenHash 为其 sha256
+	Token     string    `json:"token,omitempty"`
+	TokenHash string    `json:"tokenHash,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// Machines 待清理的机器, ControlPlane 待删除的托管集群控制面, 如 Deployment/kube-apiserver
+	Machines     []string       `json:"machines"`
+	ControlPlane []string       `json:"controlPlane,omitempty"`
+	Racks        []*RackRelease `json:"racks"`
+	CreatedAt    time.Time      `json:"createdAt"`
+	DeletedAt    *time.Time     `json:"deletedAt,omitempty"`
+	FinishedAt   *time.Time     `json:"finishedAt,omitempty"`
+}
+
+// 集群删除后释放的机柜机器及 pod 地址段
+type RackRelease struct {
+	Rack     string `json:"rack,omitempty"`
+	IP       string `json:"ip"`
+	PodCidr  string `json:"podCidr,omitempty"`
+	Released bool   `json:"released"`
+}
+
+// NewRackReleases returns the rack hosts and the pod cidrs of the machines, the same ip only once.
+func NewRackReleases(machines []*devopsv1.ClusterMachine) []*RackRelease {
+	releases := []*RackRelease{}
+	seen := map[string]bool{}
+	for _, m := range machines {
+		if m == nil || m.IP == "" || seen[m.IP] {
+			continue
+		}
+		seen[m.IP] = true
+		r := &RackRelease{IP: m.IP}
+		if m.HostCni != nil {
+			r.Rack = m.HostCni.RackTag
+			r.PodCidr = m.HostCni.ID
+		}
+		releases = append(releases, r)
+	}
+	return releases
+}
+
+// ReleaseRacks marks the hosts and the pod cidrs of the releases unused in the racks, the hosts of the meta
+// cluster are never released. The pod cidr is looked up in the rack of the host when its rack is not known.
+// It returns the number of the releases newly released.
+func ReleaseRacks(racks []*Rack, releases []*RackRelease) int {
+	n := 0
+	for _, r := range releases {
+		if r.Released {
+			continue
+		}
+		for _, rack := range racks {
+			if r.Rack != "" && rack.RackTag != r.Rack {
+				continue
+			}
+			found := false
+			for _, host := range rack.HostAddr {
+				if host.IPADDR == r.IP && host.IsMeta == 0 {
+					host.UseState = 0
+					found = true
+				}
+			}
+			if !found && r.Rack == "" {
+				continue
+			}
+			for _, cidr := range rack.PodCidr {
+				if r.PodCidr != "" && cidr.ID == r.PodCidr {
+					cidr.UseState = 0
+				}
+			}
+			r.Released = true
+		}
+		if r.Released {
+			n++
+		}
+	}
+	return n
+}
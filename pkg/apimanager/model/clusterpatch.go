@@ -0,0 +1,133 @@
+package model
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/util/apiclient"
+)
+
+// ClusterPatchFields 集群 spec 可修改的字段, 其余字段创建后不可修改
+var ClusterPatchFields = []string{"version", "registryMirrors", "features.multus", "features.ha"}
+
+// 集群 spec 的部分更新, 未设置的字段保持不变, 由 controller 同步到集群:
+// version 只支持托管集群升级, registryMirrors 按仓库合并(值为 null 时删除该仓库的配置),
+// features.multus 只能开启, features.ha 修改 VIP(及第三方负载均衡的端口), HA 类型不可修改
+type ClusterSpecPatch struct {
+	Version         *string                             `json:"version,omitempty"`
+	RegistryMirrors map[string]*devopsv1.RegistryMirror `json:"registryMirrors,omitempty"`
+	Features        *ClusterFeaturesPatch               `json:"features,omitempty"`
+}
+
+// 可修改的集群功能开关
+type ClusterFeaturesPatch struct {
+	Multus *bool        `json:"multus,omitempty"`
+	HA     *devopsv1.HA `json:"ha,omitempty"`
+}
+
+// 集群 spec 更新的结果, Changed 为修改了的字段, 没有修改时为空
+type ClusterSpecUpdate struct {
+	Cluster string   `json:"cluster"`
+	Changed []string `json:"changed"`
+}
+
+// 校验变更对集群是否可行, 集群的阶段及完整 spec 的校验由调用方负责
+func (p *ClusterSpecPatch) Validate(cluster *devopsv1.Cluster) error {
+	features := p.Features
+	if features == nil {
+		features = &ClusterFeaturesPatch{}
+	}
+	if p.Version == nil && len(p.RegistryMirrors) == 0 && features.Multus == nil && features.HA == nil {
+		return fmt.Errorf("one of %v: must be specified", ClusterPatchFields)
+	}
+
+	if p.Version != nil && *p.Version != cluster.Spec.Version {
+		if cluster.Spec.Type != "Hosted" {
+			return fmt.Errorf("version: only the hosted clusters can be upgraded")
+		}
+		if !constants.IsK8sSupport(*p.Version) {
+			return fmt.Errorf("version: %s unsupported, supported versions: %v", *p.Version, constants.K8sVersions)
+		}
+		downgrade, err := apiclient.CheckVersion(*p.Version, "< "+cluster.Spec.Version)
+		if err != nil {
+			return fmt.Errorf("version: %v", err)
+		}
+		if downgrade {
+			return fmt.Errorf("version: %s can't be downgraded to %s", cluster.Spec.Version, *p.Version)
+		}
+	}
+
+	if features.Multus != nil && !*features.Multus && cluster.Spec.Features.Multus {
+		return fmt.Errorf("features.multus: can't be disabled once enabled")
+	}
+	if ha := features.HA; ha != nil {
+		if (ha.DKEHA == nil) == (ha.ThirdPartyHA == nil) {
+			return fmt.Errorf("features.ha: exactly one of dke and thirdParty must be specified")
+		}
+		if old := cluster.Spec.Features.HA; old != nil && (old.DKEHA != nil) != (ha.DKEHA != nil) {
+			return fmt.Errorf("features.ha: the type of ha is immutable")
+		}
+		if ha.DKEHA != nil && net.ParseIP(ha.DKEHA.VIP) == nil {
+			return fmt.Errorf("features.ha.dke.vip: %q must be an ip", ha.DKEHA.VIP)
+		}
+		if ha.ThirdPartyHA != nil {
+			if net.ParseIP(ha.ThirdPartyHA.VIP) == nil {
+				return fmt.Errorf("features.ha.thirdParty.vip: %q must be an ip", ha.ThirdPartyHA.VIP)
+			}
+			if ha.ThirdPartyHA.VPort <= 0 || ha.ThirdPartyHA.VPort > 65535 {
+				return fmt.Errorf("features.ha.thirdParty.vport: %d must be between 1 and 65535", ha.ThirdPartyHA.VPort)
+			}
+		}
+	}
+	return nil
+}
+
+// Apply applies the patch to the spec and returns the fields changed, the registry mirrors by host.
+func (p *ClusterSpecPatch) Apply(spec *devopsv1.ClusterSpec) []string {
+	changed := []string{}
+	if p.Version != nil && *p.Version != spec.Version {
+		spec.Version = *p.Version
+		changed = append(changed, "version")
+	}
+
+	hosts := make([]string, 0, len(p.RegistryMirrors))
+	for host := range p.RegistryMirrors {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		m := p.RegistryMirrors[host]
+		old, ok := spec.RegistryMirrors[host]
+		switch {
+		case m == nil && !ok:
+			continue
+		case m == nil:
+			delete(spec.RegistryMirrors, host)
+		case ok && reflect.DeepEqual(old, *m):
+			continue
+		default:
+			if spec.RegistryMirrors == nil {
+				spec.RegistryMirrors = map[string]devopsv1.RegistryMirror{}
+			}
+			spec.RegistryMirrors[host] = *m
+		}
+		changed = append(changed, fmt.Sprintf("registryMirrors[%s]", host))
+	}
+
+	if p.Features == nil {
+		return changed
+	}
+	if multus := p.Features.Multus; multus != nil && *multus != spec.Features.Multus {
+		spec.Features.Multus = *multus
+		changed = append(changed, "features.multus")
+	}
+	if ha := p.Features.HA; ha != nil && !reflect.DeepEqual(ha, spec.Features.HA) {
+		spec.Features.HA = ha
+		changed = append(changed, "features.ha")
+	}
+	return changed
+}
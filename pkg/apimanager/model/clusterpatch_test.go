@@ -0,0 +1,90 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+)
+
+func TestClusterSpecPatchValidate(t *testing.T) {
+	hosted := &devopsv1.Cluster{Spec: devopsv1.ClusterSpec{Type: "Hosted", Version: "v1.16.14"}}
+	baremetal := &devopsv1.Cluster{Spec: devopsv1.ClusterSpec{
+		Type:     "Baremetal",
+		Version:  "v1.18.5",
+		Features: devopsv1.ClusterFeature{Multus: true, HA: &devopsv1.HA{DKEHA: &devopsv1.DKEHA{VIP: "10.0.0.100"}}},
+	}}
+	str := func(s string) *string { return &s }
+	boolean := func(b bool) *bool { return &b }
+
+	tests := []struct {
+		name    string
+		cluster *devopsv1.Cluster
+		patch   ClusterSpecPatch
+		wantErr bool
+	}{
+		{name: "empty", cluster: hosted, patch: ClusterSpecPatch{Features: &ClusterFeaturesPatch{}}, wantErr: true},
+		{name: "upgrade hosted", cluster: hosted, patch: ClusterSpecPatch{Version: str("v1.18.5")}},
+		{name: "unsupported version", cluster: hosted, patch: ClusterSpecPatch{Version: str("v1.17.0")}, wantErr: true},
+		{name: "upgrade baremetal", cluster: baremetal, patch: ClusterSpecPatch{Version: str("v1.16.14")}, wantErr: true},
+		{name: "same version of baremetal", cluster: baremetal, patch: ClusterSpecPatch{Version: str("v1.18.5")}},
+		{name: "downgrade", cluster: &devopsv1.Cluster{Spec: devopsv1.ClusterSpec{Type: "Hosted", Version: "v1.18.5"}}, patch: ClusterSpecPatch{Version: str("v1.16.14")}, wantErr: true},
+		{name: "registry mirror", cluster: hosted, patch: ClusterSpecPatch{RegistryMirrors: map[string]*devopsv1.RegistryMirror{"docker.io": nil}}},
+		{name: "enable multus", cluster: hosted, patch: ClusterSpecPatch{Features: &ClusterFeaturesPatch{Multus: boolean(true)}}},
+		{name: "disable multus", cluster: baremetal, patch: ClusterSpecPatch{Features: &ClusterFeaturesPatch{Multus: boolean(false)}}, wantErr: true},
+		{name: "dke vip", cluster: baremetal, patch: ClusterSpecPatch{Features: &ClusterFeaturesPatch{HA: &devopsv1.HA{DKEHA: &devopsv1.DKEHA{VIP: "10.0.0.101"}}}}},
+		{name: "invalid vip", cluster: baremetal, patch: ClusterSpecPatch{Features: &ClusterFeaturesPatch{HA: &devopsv1.HA{DKEHA: &devopsv1.DKEHA{VIP: "vip"}}}}, wantErr: true},
+		{name: "ha type", cluster: baremetal, patch: ClusterSpecPatch{Features: &ClusterFeaturesPatch{HA: &devopsv1.HA{ThirdPartyHA: &devopsv1.ThirdPartyHA{VIP: "10.0.0.101", VPort: 6443}}}}, wantErr: true},
+		{name: "third party", cluster: hosted, patch: ClusterSpecPatch{Features: &ClusterFeaturesPatch{HA: &devopsv1.HA{ThirdPartyHA: &devopsv1.ThirdPartyHA{VIP: "10.0.0.101", VPort: 6443}}}}},
+		{name: "third party without port", cluster: hosted, patch: ClusterSpecPatch{Features: &ClusterFeaturesPatch{HA: &devopsv1.HA{ThirdPartyHA: &devopsv1.ThirdPartyHA{VIP: "10.0.0.101"}}}}, wantErr: true},
+		{name: "both ha", cluster: hosted, patch: ClusterSpecPatch{Features: &ClusterFeaturesPatch{HA: &devopsv1.HA{}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.patch.Validate(tt.cluster); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClusterSpecPatchApply(t *testing.T) {
+	multus := true
+	version := "v1.18.5"
+	spec := &devopsv1.ClusterSpec{
+		Version: version,
+		RegistryMirrors: map[string]devopsv1.RegistryMirror{
+			"docker.io":            {Endpoints: []string{"https://a.example.com"}},
+			"registry.example.com": {Insecure: true},
+			"quay.io":              {Insecure: true},
+		},
+	}
+	patch := &ClusterSpecPatch{
+		Version: &version,
+		RegistryMirrors: map[string]*devopsv1.RegistryMirror{
+			"docker.io":            {Endpoints: []string{"https://b.example.com"}},
+			"registry.example.com": nil,
+			"quay.io":              {Insecure: true},
+			"gcr.io":               {Endpoints: []string{"https://c.example.com"}},
+			"missing.example.com":  nil,
+		},
+		Features: &ClusterFeaturesPatch{Multus: &multus},
+	}
+
+	changed := patch.Apply(spec)
+	want := []string{"registryMirrors[docker.io]", "registryMirrors[gcr.io]", "registryMirrors[registry.example.com]", "features.multus"}
+	if !reflect.DeepEqual(changed, want) {
+		t.Errorf("Apply() changed = %v, want %v", changed, want)
+	}
+	wantMirrors := map[string]devopsv1.RegistryMirror{
+		"docker.io": {Endpoints: []string{"https://b.example.com"}},
+		"quay.io":   {Insecure: true},
+		"gcr.io":    {Endpoints: []string{"https://c.example.com"}},
+	}
+	if !reflect.DeepEqual(spec.RegistryMirrors, wantMirrors) || !spec.Features.Multus {
+		t.Errorf("Apply() spec = %+v", spec)
+	}
+	if changed := patch.Apply(spec); len(changed) != 0 {
+		t.Errorf("Apply() again changed = %v, want none", changed)
+	}
+}
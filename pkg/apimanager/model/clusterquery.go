@@ -0,0 +1,146 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+)
+
+// 集群列表可排序的字段, 前缀 "-" 表示降序
+const (
+	ClusterSortName         = "name"
+	ClusterSortCreationTime = "creationTime"
+	ClusterSortPhase        = "phase"
+	ClusterSortVersion      = "version"
+	ClusterSortNodeCount    = "nodeCount"
+)
+
+// 集群列表查询, 先按字段过滤, 再排序, 最后分页, Page 从 1 开始, Limit 为 0 时返回全部
+type ClusterQuery struct {
+	Page    int
+	Limit   int
+	SortBy  string
+	Phase   string
+	Version string
+	Rack    string
+	// Reachable "true" 或 "false", 按健康检查的 Reachable condition 过滤, 未检查的集群不匹配
+	Reachable string
+}
+
+// 校验分页及排序参数
+func (q *ClusterQuery) Validate() error {
+	if q.Page < 1 {
+		return fmt.Errorf("page: must be greater than 0")
+	}
+	if q.Limit < 0 || q.Limit > 1000 {
+		return fmt.Errorf("limit: must be 0-1000")
+	}
+	switch q.Reachable {
+	case "", "true", "false":
+	default:
+		return fmt.Errorf("reachable: must be true or false")
+	}
+	switch strings.TrimPrefix(q.SortBy, "-") {
+	case "", ClusterSortName, ClusterSortCreationTime, ClusterSortPhase, ClusterSortVersion, ClusterSortNodeCount:
+	default:
+		return fmt.Errorf("sortBy: unsupported field %s", q.SortBy)
+	}
+	return nil
+}
+
+// 是否需要全部集群的节点数才能排序
+func (q *ClusterQuery) SortByNodeCount() bool {
+	return strings.TrimPrefix(q.SortBy, "-") == ClusterSortNodeCount
+}
+
+// clusterVersion 集群的版本, 优先取 spec 中的版本
+func clusterVersion(c *devopsv1.Cluster) string {
+	if c.Spec.Version != "" {
+		return c.Spec.Version
+	}
+	return c.Status.Version
+}
+
+// clusterInRack 集群的机器是否有在该机柜中的
+func clusterInRack(c *devopsv1.Cluster, rack string) bool {
+	for _, m := range c.Spec.Machines {
+		if m != nil && m.HostCni != nil && m.HostCni.RackTag == rack {
+			return true
+		}
+	}
+	return false
+}
+
+// 按 phase、version、rack 及 reachable 过滤集群
+func (q *ClusterQuery) Filter(clusters []*devopsv1.Cluster) []*devopsv1.Cluster {
+	list := []*devopsv1.Cluster{}
+	for _, c := range clusters {
+		if q.Phase != "" && !strings.EqualFold(string(c.Status.Phase), q.Phase) {
+			continue
+		}
+		if q.Version != "" && clusterVersion(c) != q.Version {
+			continue
+		}
+		if q.Rack != "" && !clusterInRack(c, q.Rack) {
+			continue
+		}
+		if reachable, known := c.Reachable(); q.Reachable != "" && (!known || strconv.FormatBool(reachable) != q.Reachable) {
+			continue
+		}
+		list = append(list, c)
+	}
+	return list
+}
+
+// 按 SortBy 排序, 值相同的按名称排序, 未指定时按名称排序
+func (q *ClusterQuery) Sort(clusters []*devopsv1.Cluster) {
+	field := strings.TrimPrefix(q.SortBy, "-")
+	desc := strings.HasPrefix(q.SortBy, "-")
+	sort.SliceStable(clusters, func(i, j int) bool {
+		a, b := clusters[i], clusters[j]
+		var less, equal bool
+		switch field {
+		case ClusterSortCreationTime:
+			less = a.CreationTimestamp.Before(&b.CreationTimestamp)
+			equal = a.CreationTimestamp.Equal(&b.CreationTimestamp)
+		case ClusterSortPhase:
+			less, equal = a.Status.Phase < b.Status.Phase, a.Status.Phase == b.Status.Phase
+		case ClusterSortVersion:
+			less, equal = clusterVersion(a) < clusterVersion(b), clusterVersion(a) == clusterVersion(b)
+		case ClusterSortNodeCount:
+			less, equal = a.Status.NodeCount < b.Status.NodeCount, a.Status.NodeCount == b.Status.NodeCount
+		default:
+			less, equal = a.Name < b.Name, a.Name == b.Name
+		}
+		if equal {
+			return a.Name < b.Name
+		}
+		if desc {
+			return !less
+		}
+		return less
+	})
+}
+
+// 返回当前页的集群
+func (q *ClusterQuery) Paginate(clusters []*devopsv1.Cluster) []*devopsv1.Cluster {
+	if q.Limit == 0 {
+		return clusters
+	}
+	page := q.Page
+	if page < 1 {
+		page = 1
+	}
+	start := (page - 1) * q.Limit
+	if start >= len(clusters) {
+		return []*devopsv1.Cluster{}
+	}
+	end := start + q.Limit
+	if end > len(clusters) {
+		end = len(clusters)
+	}
+	return clusters[start:end]
+}
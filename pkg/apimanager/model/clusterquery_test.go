@@ -0,0 +1,80 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestClusterQuery(t *testing.T) {
+	now := time.Now()
+	newCluster := func(name string, age int, phase devopsv1.ClusterPhase, version string, nodes int, rack string) *devopsv1.Cluster {
+		c := &devopsv1.Cluster{}
+		c.Name = name
+		c.CreationTimestamp = metav1.NewTime(now.Add(-time.Duration(age) * time.Hour))
+		c.Status.Phase = phase
+		c.Spec.Version = version
+		c.Status.NodeCount = nodes
+		if rack != "" {
+			c.Spec.Machines = []*devopsv1.ClusterMachine{{HostCni: &devopsv1.ClusterCni{RackTag: rack}}}
+		}
+		return c
+	}
+	newClusters := func() []*devopsv1.Cluster {
+		clusters := []*devopsv1.Cluster{
+			newCluster("c", 1, devopsv1.ClusterRunning, "1.18.4", 5, "rack1"),
+			newCluster("a", 3, devopsv1.ClusterFailed, "1.18.4", 3, "rack2"),
+			newCluster("d", 2, devopsv1.ClusterRunning, "1.16.9", 3, "rack1"),
+			newCluster("b", 4, devopsv1.ClusterRunning, "1.18.4", 10, ""),
+		}
+		clusters[0].SetCondition(devopsv1.ClusterCondition{Type: devopsv1.ClusterConditionReachable, Status: devopsv1.ConditionTrue})
+		clusters[2].SetCondition(devopsv1.ClusterCondition{Type: devopsv1.ClusterConditionReachable, Status: devopsv1.ConditionFalse})
+		return clusters
+	}
+
+	tests := []struct {
+		name      string
+		query     ClusterQuery
+		want      []string
+		wantTotal int
+		wantErr   bool
+	}{
+		{name: "default", query: ClusterQuery{Page: 1}, want: []string{"a", "b", "c", "d"}, wantTotal: 4},
+		{name: "page", query: ClusterQuery{Page: 2, Limit: 3}, want: []string{"d"}, wantTotal: 4},
+		{name: "page out of range", query: ClusterQuery{Page: 3, Limit: 3}, want: []string{}, wantTotal: 4},
+		{name: "creation time desc", query: ClusterQuery{Page: 1, SortBy: "-creationTime"}, want: []string{"c", "d", "a", "b"}, wantTotal: 4},
+		{name: "node count ties by name", query: ClusterQuery{Page: 1, SortBy: "nodeCount"}, want: []string{"a", "d", "c", "b"}, wantTotal: 4},
+		{name: "phase", query: ClusterQuery{Page: 1, Phase: "running", SortBy: "-name"}, want: []string{"d", "c", "b"}, wantTotal: 3},
+		{name: "version and rack", query: ClusterQuery{Page: 1, Limit: 1, Version: "1.18.4", Rack: "rack1"}, want: []string{"c"}, wantTotal: 1},
+		{name: "reachable", query: ClusterQuery{Page: 1, Reachable: "true"}, want: []string{"c"}, wantTotal: 1},
+		{name: "unreachable", query: ClusterQuery{Page: 1, Reachable: "false"}, want: []string{"d"}, wantTotal: 1},
+		{name: "invalid reachable", query: ClusterQuery{Page: 1, Reachable: "maybe"}, wantErr: true},
+		{name: "invalid page", query: ClusterQuery{Page: 0}, wantErr: true},
+		{name: "invalid limit", query: ClusterQuery{Page: 1, Limit: 1001}, wantErr: true},
+		{name: "invalid sort", query: ClusterQuery{Page: 1, SortBy: "-labels"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.query.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			list := tt.query.Filter(newClusters())
+			tt.query.Sort(list)
+			got := []string{}
+			for _, c := range tt.query.Paginate(list) {
+				got = append(got, c.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) || len(list) != tt.wantTotal {
+				t.Errorf("got %v of %d, want %v of %d", got, len(list), tt.want, tt.wantTotal)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// 事件来源: meta 集群中 Cluster、Machine 等 CR 的事件, 或集群自身的事件
+const (
+	EventSourceMeta   = "meta"
+	EventSourceMember = "member"
+)
+
+// 集群事件, 合并了 meta 集群及集群自身的事件
+type ClusterEvent struct {
+	Source    string    `json:"source"`
+	Namespace string    `json:"namespace"`
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Reason    string    `json:"reason"`
+	Message   string    `json:"message"`
+	Count     int32     `json:"count"`
+	Component string    `json:"component,omitempty"`
+	Host      string    `json:"host,omitempty"`
+	FirstTime time.Time `json:"firstTime"`
+	LastTime  time.Time `json:"lastTime"`
+}
+
+// NewClusterEvent ...
+func NewClusterEvent(source string, ev *corev1.Event) *ClusterEvent {
+	e := &ClusterEvent{
+		Source:    source,
+		Namespace: ev.InvolvedObject.Namespace,
+		Kind:      ev.InvolvedObject.Kind,
+		Name:      ev.InvolvedObject.Name,
+		Type:      ev.Type,
+		Reason:    ev.Reason,
+		Message:   ev.Message,
+		Count:     ev.Count,
+		Component: ev.Source.Component,
+		Host:      ev.Source.Host,
+		FirstTime: ev.FirstTimestamp.Time,
+		LastTime:  ev.LastTimestamp.Time,
+	}
+	// the events.k8s.io clients only set the eventTime and the series
+	if e.LastTime.IsZero() {
+		e.LastTime = ev.EventTime.Time
+		if ev.Series != nil {
+			e.LastTime = ev.Series.LastObservedTime.Time
+		}
+	}
+	if e.LastTime.IsZero() {
+		e.LastTime = ev.CreationTimestamp.Time
+	}
+	if e.FirstTime.IsZero() {
+		e.FirstTime = e.LastTime
+	}
+	if e.Count == 0 {
+		e.Count = 1
+	}
+	return e
+}
+
+// 集群事件查询, Namespace 只过滤集群自身的事件, Since 为 0 时不限时间, 结果按最后发生时间倒序, 最多 Limit 个
+type EventQuery struct {
+	Source    string
+	Namespace string
+	Kind      string
+	Name      string
+	Type      string
+	Reason    string
+	Since     time.Duration
+	Limit     int
+}
+
+// 校验查询参数
+func (q *EventQuery) Validate() error {
+	switch q.Source {
+	case "", EventSourceMeta, EventSourceMember:
+	default:
+		return fmt.Errorf("source: must be %s or %s", EventSourceMeta, EventSourceMember)
+	}
+	switch q.Type {
+	case "", corev1.EventTypeNormal, corev1.EventTypeWarning:
+	default:
+		return fmt.Errorf("type: must be %s or %s", corev1.EventTypeNormal, corev1.EventTypeWarning)
+	}
+	if q.Since < 0 {
+		return fmt.Errorf("since: must be positive")
+	}
+	if q.Limit < 1 || q.Limit > 1000 {
+		return fmt.Errorf("limit: must be 1-1000")
+	}
+	return nil
+}
+
+// 是否查询该来源的事件
+func (q *EventQuery) Includes(source string) bool {
+	return q.Source == "" || q.Source == source
+}
+
+// 过滤并按最后发生时间倒序排列事件
+func (q *EventQuery) Apply(events []*ClusterEvent, now time.Time) []*ClusterEvent {
+	list := []*ClusterEvent{}
+	for _, e := range events {
+		if !q.Includes(e.Source) ||
+			(q.Namespace != "" && e.Source == EventSourceMember && e.Namespace != q.Namespace) ||
+			(q.Kind != "" && e.Kind != q.Kind) ||
+			(q.Name != "" && e.Name != q.Name) ||
+			(q.Type != "" && e.Type != q.Type) ||
+			(q.Reason != "" && e.Reason != q.Reason) ||
+			(q.Since > 0 && e.LastTime.Before(now.Add(-q.Since))) {
+			continue
+		}
+		list = append(list, e)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].LastTime.After(list[j].LastTime)
+	})
+	if len(list) > q.Limit {
+		list = list[:q.Limit]
+	}
+	return list
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewClusterEvent(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	ev := &corev1.Event{
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "p1"},
+		EventTime:      metav1.NewMicroTime(now.Add(-time.Minute)),
+		Series:         &corev1.EventSeries{Count: 3, LastObservedTime: metav1.NewMicroTime(now)},
+	}
+	e := NewClusterEvent(EventSourceMember, ev)
+	if !e.LastTime.Equal(now) || !e.FirstTime.Equal(now) || e.Count != 1 {
+		t.Errorf("NewClusterEvent() = %+v", e)
+	}
+}
+
+func TestEventQuery(t *testing.T) {
+	now := time.Now()
+	newEvents := func() []*ClusterEvent {
+		return []*ClusterEvent{
+			{Source: EventSourceMeta, Namespace: "c1", Kind: "Cluster", Name: "c1", Type: "Warning", Reason: "FailedInit", LastTime: now.Add(-3 * time.Hour)},
+			{Source: EventSourceMember, Namespace: "kube-system", Kind: "Pod", Name: "coredns", Type: "Warning", Reason: "BackOff", LastTime: now.Add(-time.Minute)},
+			{Source: EventSourceMeta, Namespace: "c1", Kind: "Machine", Name: "m1", Type: "Normal", Reason: "PhaseChanged", LastTime: now.Add(-time.Hour)},
+			{Source: EventSourceMember, Namespace: "default", Kind: "Pod", Name: "web", Type: "Normal", Reason: "Pulled", LastTime: now},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		query   EventQuery
+		want    []string
+		wantErr bool
+	}{
+		{name: "merged and sorted", query: EventQuery{Limit: 10}, want: []string{"web", "coredns", "m1", "c1"}},
+		{name: "limit", query: EventQuery{Limit: 2}, want: []string{"web", "coredns"}},
+		{name: "source", query: EventQuery{Source: EventSourceMeta, Limit: 10}, want: []string{"m1", "c1"}},
+		{name: "namespace keeps meta", query: EventQuery{Namespace: "kube-system", Limit: 10}, want: []string{"coredns", "m1", "c1"}},
+		{name: "type and since", query: EventQuery{Type: "Warning", Since: 2 * time.Hour, Limit: 10}, want: []string{"coredns"}},
+		{name: "kind", query: EventQuery{Kind: "Machine", Limit: 10}, want: []string{"m1"}},
+		{name: "invalid source", query: EventQuery{Source: "all", Limit: 10}, wantErr: true},
+		{name: "invalid type", query: EventQuery{Type: "Error", Limit: 10}, wantErr: true},
+		{name: "invalid limit", query: EventQuery{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.query.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got := tt.query.Apply(newEvents(), now)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Apply() got %d events, want %v", len(got), tt.want)
+			}
+			for i := range got {
+				if got[i].Name != tt.want[i] {
+					t.Errorf("Apply()[%d] = %s, want %s", i, got[i].Name, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gostship/kunkka/pkg/util/validation"
+)
+
+const (
+	ExpansionPending     = "Pending"
+	ExpansionRejected    = "Rejected"
+	ExpansionProvisioned = "Provisioned"
+	ExpansionFailed      = "Failed"
+)
+
+// 扩容申请, 超出租户配额的部分需要平台管理员批准, 批准后从机柜自动分配机器并加入集群
+type ExpansionRequest struct {
+	ID            string     `json:"id"`
+	Cluster       string     `json:"cluster"`
+	Tenant        string     `json:"tenant"`
+	Count         int        `json:"count"`
+	NodeRack      []string   `json:"nodeRack"`
+	Reason        string     `json:"reason"`
+	UserName      string     `json:"userName"`
+	Password      string     `json:"password,omitempty"`
+	DockerVersion string     `json:"dockerVersion"`
+	NodeVersion   string     `json:"nodeVersion"`
+	Requester     string     `json:"requester"`
+	Approver      string     `json:"approver"`
+	Status        string     `json:"status"`
+	Message       string     `json:"message"`
+	Machines      []string   `json:"machines"`
+	Quota         int        `json:"quota"`
+	Used          int        `json:"used"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	ApprovedAt    *time.Time `json:"approvedAt,omitempty"`
+}
+
+// Sanitize trims the cluster name and the rack tags which end up in labels.
+func (r *ExpansionRequest) Sanitize() error {
+	name, err := validation.SanitizeName(r.Cluster)
+	if err != nil {
+		return fmt.Errorf("cluster: %v", err)
+	}
+	r.Cluster = name
+
+	if r.Count <= 0 {
+		return fmt.Errorf("count: must be greater than 0")
+	}
+	for i := range r.NodeRack {
+		tag, err := validation.SanitizeLabelValue(r.NodeRack[i])
+		if err != nil {
+			return fmt.Errorf("nodeRack: %v", err)
+		}
+		r.NodeRack[i] = tag
+	}
+
+	r.Reason = strings.TrimSpace(r.Reason)
+	if r.Reason == "" {
+		return fmt.Errorf("reason: must be specified")
+	}
+	if r.UserName == "" || r.Password == "" {
+		return fmt.Errorf("userName and password: must be specified")
+	}
+	return nil
+}
+
+// NotEnoughMachinesError the racks have less free machines than requested, it's the details of the error response.
+type NotEnoughMachinesError struct {
+	Free      int      `json:"free"`
+	Requested int      `json:"requested"`
+	Racks     []string `json:"racks"`
+}
+
+func (e *NotEnoughMachinesError) Error() string {
+	return fmt.Sprintf("only %d free machines in racks: %v, %d requested", e.Free, e.Racks, e.Requested)
+}
+
+// AllocateMachines picks count unused hosts with an unused pod cidr of the same rack, racks are limited to tags if any.
+// The picked hosts and cidrs are marked used in racks, nothing is marked when there are not enough.
+func AllocateMachines(racks []*Rack, tags []string, count int) ([]*CniOption, error) {
+	type pick struct {
+		host *HostAddr
+		cidr int
+		rack *Rack
+	}
+
+	var picks []*pick
+	for _, rack := range racks {
+		if len(tags) > 0 && !contains(tags, rack.RackTag) {
+			continue
+		}
+
+		cidrs := []int{}
+		for i, cni := range rack.PodCidr {
+			if cni.UseState == 0 {
+				cidrs = append(cidrs, i)
+			}
+		}
+		for _, host := range rack.HostAddr {
+			if len(picks) == count || len(cidrs) == 0 {
+				break
+			}
+			if host.UseState != 0 || host.IsMeta != 0 {
+				continue
+			}
+			picks = append(picks, &pick{host: host, cidr: cidrs[0], rack: rack})
+			cidrs = cidrs[1:]
+		}
+	}
+	if len(picks) < count {
+		return nil, &NotEnoughMachinesError{Free: len(picks), Requested: count, Racks: tags}
+	}
+
+	opts := make([]*CniOption, 0, count)
+	for _, p := range picks {
+		p.host.UseState = 1
+		p.rack.PodCidr[p.cidr].UseState = 1
+		opts = append(opts, &CniOption{
+			Racks:       p.rack.RackTag,
+			Machine:     p.host.IPADDR,
+			ClusterCIDR: p.rack.ProviderCidr,
+			Cni:         p.rack.PodCidr[p.cidr],
+		})
+	}
+	return opts, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+)
+
+func TestAllocateMachines(t *testing.T) {
+	newRacks := func() []*Rack {
+		return []*Rack{
+			{
+				RackTag: "rack1",
+				HostAddr: []*HostAddr{
+					{IPADDR: "10.0.0.1", IsMeta: 1},
+					{IPADDR: "10.0.0.2", UseState: 1},
+					{IPADDR: "10.0.0.3"},
+					{IPADDR: "10.0.0.4"},
+				},
+				PodCidr: []*devopsv1.ClusterCni{{ID: "p1", UseState: 1}, {ID: "p2"}},
+			},
+			{
+				RackTag:  "rack2",
+				HostAddr: []*HostAddr{{IPADDR: "10.0.1.1"}, {IPADDR: "10.0.1.2"}},
+				PodCidr:  []*devopsv1.ClusterCni{{ID: "p3"}, {ID: "p4"}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		tags    []string
+		count   int
+		want    []string
+		wantErr bool
+	}{
+		{name: "any rack", count: 2, want: []string{"10.0.0.3", "10.0.1.1"}},
+		{name: "tagged rack", tags: []string{"rack2"}, count: 2, want: []string{"10.0.1.1", "10.0.1.2"}},
+		{name: "not enough cidr", tags: []string{"rack1"}, count: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			racks := newRacks()
+			opts, err := AllocateMachines(racks, tt.tags, tt.count)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AllocateMachines() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if racks[0].HostAddr[2].UseState != 0 || racks[0].PodCidr[1].UseState != 0 {
+					t.Errorf("AllocateMachines() marked racks on error")
+				}
+				return
+			}
+			if len(opts) != len(tt.want) {
+				t.Fatalf("AllocateMachines() = %d machines, want %d", len(opts), len(tt.want))
+			}
+			for i, opt := range opts {
+				if opt.Machine != tt.want[i] || opt.Cni == nil || opt.Cni.UseState != 1 {
+					t.Errorf("AllocateMachines()[%d] = %s %v, want %s", i, opt.Machine, opt.Cni, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultScopedKubeconfigTTL the lifetime of the scoped kubeconfig if not specified
+	DefaultScopedKubeconfigTTL = 8 * time.Hour
+	// MaxScopedKubeconfigTTL the longest lifetime of the scoped kubeconfig
+	MaxScopedKubeconfigTTL = 24 * time.Hour
+	// MaxAdminKubeconfigTTL the longest lifetime of the expiring admin kubeconfig
+	MaxAdminKubeconfigTTL = 24 * time.Hour
+)
+
+// 受限 kubeconfig 申请, 只具有指定 ClusterRole 的权限并在 ttl 后过期
+type ScopedKubeconfigRequest struct {
+	ClusterRole string `json:"clusterRole"`
+	TTLSeconds  int64  `json:"ttlSeconds,omitempty"`
+}
+
+// 受限 kubeconfig
+type ScopedKubeconfig struct {
+	User        string    `json:"user"`
+	ClusterRole string    `json:"clusterRole"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	Kubeconfig  string    `json:"kubeconfig"`
+}
+
+// 集群的 admin kubeconfig, expiresAt 为空时为 controller 生成的 kubeconfig, 重新生成 kubeconfig 后失效
+type ClusterKubeconfig struct {
+	Cluster    string     `json:"cluster"`
+	Generation int        `json:"generation"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	Kubeconfig string     `json:"kubeconfig"`
+}
+
+// TTL returns the lifetime of the kubeconfig, the default if not specified.
+func (r *ScopedKubeconfigRequest) TTL() time.Duration {
+	if r.TTLSeconds == 0 {
+		return DefaultScopedKubeconfigTTL
+	}
+	return time.Duration(r.TTLSeconds) * time.Second
+}
+
+// Sanitize requires the ClusterRole and limits the ttl.
+func (r *ScopedKubeconfigRequest) Sanitize() error {
+	r.ClusterRole = strings.TrimSpace(r.ClusterRole)
+	if r.ClusterRole == "" || strings.ContainsAny(r.ClusterRole, "/%") {
+		return fmt.Errorf("clusterRole: %q is invalid", r.ClusterRole)
+	}
+	if r.TTLSeconds < 0 || r.TTL() > MaxScopedKubeconfigTTL {
+		return fmt.Errorf("ttlSeconds: must be between 1 and %d", int64(MaxScopedKubeconfigTTL.Seconds()))
+	}
+	return nil
+}
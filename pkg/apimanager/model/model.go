@@ -1,7 +1,9 @@
 package model
 
 import (
+	"fmt"
 	v1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/util/validation"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"time"
 )
@@ -100,6 +102,12 @@ type RuntimeCondition struct {
 	Name   string             `json:"name"`
 	Status v1.ConditionStatus `json:"status"`
 	Time   metav1.Time        `json:"time"`
+	// 状态变化的时间, 失败的原因及信息, 连续失败次数及最近一次执行的耗时
+	LastTransitionTime metav1.Time     `json:"lastTransitionTime"`
+	Reason             string          `json:"reason,omitempty"`
+	Message            string          `json:"message,omitempty"`
+	Attempts           int32           `json:"attempts,omitempty"`
+	Duration           metav1.Duration `json:"duration"`
 }
 
 // cluster role model
@@ -157,3 +165,69 @@ type PodInfo struct {
 	Pod       string `json:"pod" description:"pod name"`
 	Container string `json:"container" description:"container name"`
 }
+
+// Sanitize trims the user provided cluster name and rack tags which end up in object names and labels.
+func (c *AddCluster) Sanitize() error {
+	name, err := validation.SanitizeName(c.ClusterName)
+	if err != nil {
+		return fmt.Errorf("clusterName: %v", err)
+	}
+	c.ClusterName = name
+
+	for i := range c.ClusterRack {
+		tag, err := validation.SanitizeLabelValue(c.ClusterRack[i])
+		if err != nil {
+			return fmt.Errorf("clusterRack: %v", err)
+		}
+		c.ClusterRack[i] = tag
+	}
+	return nil
+}
+
+// Sanitize trims the user provided cluster name and rack tags which end up in object names and labels.
+func (n *ClusterNode) Sanitize() error {
+	name, err := validation.SanitizeName(n.ClusterName)
+	if err != nil {
+		return fmt.Errorf("clusterName: %v", err)
+	}
+	n.ClusterName = name
+
+	for i := range n.NodeRack {
+		tag, err := validation.SanitizeLabelValue(n.NodeRack[i])
+		if err != nil {
+			return fmt.Errorf("nodeRack: %v", err)
+		}
+		n.NodeRack[i] = tag
+	}
+	return nil
+}
+
+// Sanitize trims the user provided rack tag which ends up in labels.
+func (r *Rack) Sanitize() error {
+	tag, err := validation.SanitizeLabelValue(r.RackTag)
+	if err != nil {
+		return fmt.Errorf("rackTag: %v", err)
+	}
+	r.RackTag = tag
+	return nil
+}
+
+// 等待参数, source 为生效值的来源: default, global, cluster
+type WaitParam struct {
+	Name     string `json:"name"`
+	Interval string `json:"interval"`
+	Timeout  string `json:"timeout"`
+	Source   string `json:"source"`
+}
+
+// 集群证书, expiresInSeconds 为负数表示已过期
+type ClusterCert struct {
+	Name             string    `json:"name"`
+	Subject          string    `json:"subject"`
+	Issuer           string    `json:"issuer"`
+	SANs             []string  `json:"sans"`
+	IsCA             bool      `json:"isCA"`
+	NotBefore        time.Time `json:"notBefore"`
+	NotAfter         time.Time `json:"notAfter"`
+	ExpiresInSeconds int64     `json:"expiresInSeconds"`
+}
@@ -0,0 +1,131 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gostship/kunkka/pkg/util/validation"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// NamespaceQuotaName the name of the ResourceQuota applied to the namespaces
+	NamespaceQuotaName = "kunkka-quota"
+	// NamespaceLimitRangeName the name of the LimitRange applied to the namespaces
+	NamespaceLimitRangeName = "kunkka-limits"
+
+	// MaxNamespaceClusters the max number of clusters of a request
+	MaxNamespaceClusters = 100
+)
+
+const (
+	NamespaceCreated  = "Created"
+	NamespaceUpdated  = "Updated"
+	NamespaceDeleted  = "Deleted"
+	NamespaceNotFound = "NotFound"
+	NamespaceFailed   = "Failed"
+)
+
+// 多集群创建命名空间, ResourceQuota 及 LimitRange 为可选的模板, 应用到每个集群的命名空间
+type NamespaceRequest struct {
+	Clusters      []string                  `json:"clusters"`
+	Name          string                    `json:"name"`
+	Labels        map[string]string         `json:"labels"`
+	Annotations   map[string]string         `json:"annotations"`
+	ResourceQuota *corev1.ResourceQuotaSpec `json:"resourceQuota"`
+	LimitRange    *corev1.LimitRangeSpec    `json:"limitRange"`
+}
+
+// 命名空间在单个集群中的操作结果
+type NamespaceResult struct {
+	Cluster string `json:"cluster"`
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// 集群中的命名空间
+type ClusterNamespace struct {
+	Cluster      string            `json:"cluster"`
+	Name         string            `json:"name"`
+	Phase        string            `json:"phase"`
+	Labels       map[string]string `json:"labels"`
+	CreationTime metav1.Time       `json:"creationTime"`
+}
+
+// Sanitize trims the names of the namespace and the clusters and drops the duplicated clusters.
+func (r *NamespaceRequest) Sanitize() error {
+	name, err := SanitizeNamespace(r.Name)
+	if err != nil {
+		return err
+	}
+	r.Name = name
+
+	r.Clusters, err = SanitizeClusters(r.Clusters)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range r.Labels {
+		if errs := k8svalidation.IsQualifiedName(k); len(errs) > 0 {
+			return fmt.Errorf("labels: %s: %s", k, strings.Join(errs, ", "))
+		}
+		if errs := k8svalidation.IsValidLabelValue(v); len(errs) > 0 {
+			return fmt.Errorf("labels: %s: %s", k, strings.Join(errs, ", "))
+		}
+	}
+	for k := range r.Annotations {
+		if errs := k8svalidation.IsQualifiedName(k); len(errs) > 0 {
+			return fmt.Errorf("annotations: %s: %s", k, strings.Join(errs, ", "))
+		}
+	}
+	if r.ResourceQuota != nil && len(r.ResourceQuota.Hard) == 0 {
+		return fmt.Errorf("resourceQuota: hard must be specified")
+	}
+	if r.LimitRange != nil && len(r.LimitRange.Limits) == 0 {
+		return fmt.Errorf("limitRange: limits must be specified")
+	}
+	return nil
+}
+
+// SanitizeNamespace trims the name of the namespace, the system namespaces are rejected.
+func SanitizeNamespace(name string) (string, error) {
+	name, err := validation.SanitizeName(name)
+	if err != nil {
+		return "", fmt.Errorf("name: %v", err)
+	}
+	if IsSystemNamespace(name) {
+		return "", fmt.Errorf("name: %s is a system namespace", name)
+	}
+	return name, nil
+}
+
+// SanitizeClusters trims the names of the clusters and drops the duplicated ones.
+func SanitizeClusters(clusters []string) ([]string, error) {
+	if len(clusters) == 0 {
+		return nil, fmt.Errorf("clusters: must be specified")
+	}
+	seen := map[string]bool{}
+	result := []string{}
+	for _, c := range clusters {
+		name, err := validation.SanitizeName(c)
+		if err != nil {
+			return nil, fmt.Errorf("clusters: %v", err)
+		}
+		if !seen[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+	}
+	if len(result) > MaxNamespaceClusters {
+		return nil, fmt.Errorf("clusters: at most %d clusters", MaxNamespaceClusters)
+	}
+	return result, nil
+}
+
+// IsSystemNamespace reports whether the namespace is created by kubernetes, i.e. default or kube-*.
+func IsSystemNamespace(name string) bool {
+	return name == metav1.NamespaceDefault || strings.HasPrefix(name, "kube-")
+}
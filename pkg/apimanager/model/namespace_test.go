@@ -0,0 +1,57 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestNamespaceRequestSanitize(t *testing.T) {
+	quota := &corev1.ResourceQuotaSpec{Hard: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("10")}}
+	tests := []struct {
+		name         string
+		req          NamespaceRequest
+		wantName     string
+		wantClusters []string
+		wantErr      bool
+	}{
+		{name: "valid", req: NamespaceRequest{Name: " Team-A ", Clusters: []string{"c1", " C2", "c1"}, ResourceQuota: quota}, wantName: "team-a", wantClusters: []string{"c1", "c2"}},
+		{name: "no clusters", req: NamespaceRequest{Name: "team-a"}, wantErr: true},
+		{name: "invalid cluster", req: NamespaceRequest{Name: "team-a", Clusters: []string{"c_1"}}, wantErr: true},
+		{name: "system namespace", req: NamespaceRequest{Name: "kube-system", Clusters: []string{"c1"}}, wantErr: true},
+		{name: "default namespace", req: NamespaceRequest{Name: "default", Clusters: []string{"c1"}}, wantErr: true},
+		{name: "invalid label", req: NamespaceRequest{Name: "team-a", Clusters: []string{"c1"}, Labels: map[string]string{"team": "a b"}}, wantErr: true},
+		{name: "empty quota", req: NamespaceRequest{Name: "team-a", Clusters: []string{"c1"}, ResourceQuota: &corev1.ResourceQuotaSpec{}}, wantErr: true},
+		{name: "empty limit range", req: NamespaceRequest{Name: "team-a", Clusters: []string{"c1"}, LimitRange: &corev1.LimitRangeSpec{}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Sanitize()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Sanitize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tt.req.Name != tt.wantName || !reflect.DeepEqual(tt.req.Clusters, tt.wantClusters) {
+				t.Errorf("Sanitize() = %s %v, want %s %v", tt.req.Name, tt.req.Clusters, tt.wantName, tt.wantClusters)
+			}
+		})
+	}
+}
+
+func TestSanitizeClustersLimit(t *testing.T) {
+	clusters := []string{}
+	for i := 0; i <= MaxNamespaceClusters; i++ {
+		clusters = append(clusters, fmt.Sprintf("c%d", i))
+	}
+	if _, err := SanitizeClusters(clusters); err == nil {
+		t.Errorf("SanitizeClusters() of %d clusters: expected error", len(clusters))
+	}
+	if _, err := SanitizeClusters(clusters[:MaxNamespaceClusters]); err != nil {
+		t.Errorf("SanitizeClusters() of %d clusters: %v", MaxNamespaceClusters, err)
+	}
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	nodeRoleLabelPrefix = "node-role.kubernetes.io/"
+	nodeRoleLabel       = "kubernetes.io/role"
+)
+
+// 节点清单, 包括容量、可分配资源、kubelet 及系统版本、conditions 及污点
+type NodeInfo struct {
+	Name             string                 `json:"name"`
+	Roles            []string               `json:"roles"`
+	InternalIP       string                 `json:"internalIP"`
+	Ready            bool                   `json:"ready"`
+	Unschedulable    bool                   `json:"unschedulable"`
+	KubeletVersion   string                 `json:"kubeletVersion"`
+	KubeProxyVersion string                 `json:"kubeProxyVersion"`
+	OSImage          string                 `json:"osImage"`
+	KernelVersion    string                 `json:"kernelVersion"`
+	ContainerRuntime string                 `json:"containerRuntime"`
+	Architecture     string                 `json:"architecture"`
+	Capacity         corev1.ResourceList    `json:"capacity"`
+	Allocatable      corev1.ResourceList    `json:"allocatable"`
+	Conditions       []corev1.NodeCondition `json:"conditions"`
+	Taints           []corev1.Taint         `json:"taints"`
+	Labels           map[string]string      `json:"labels"`
+	CreationTime     time.Time              `json:"creationTime"`
+}
+
+// 节点详情, 附带节点上的 pod 数
+type NodeDetail struct {
+	NodeInfo
+	Pods int `json:"pods"`
+}
+
+// NewNodeInfo ...
+func NewNodeInfo(n *corev1.Node) *NodeInfo {
+	info := &NodeInfo{
+		Name:             n.Name,
+		Roles:            NodeRoles(n),
+		Unschedulable:    n.Spec.Unschedulable,
+		KubeletVersion:   n.Status.NodeInfo.KubeletVersion,
+		KubeProxyVersion: n.Status.NodeInfo.KubeProxyVersion,
+		OSImage:          n.Status.NodeInfo.OSImage,
+		KernelVersion:    n.Status.NodeInfo.KernelVersion,
+		ContainerRuntime: n.Status.NodeInfo.ContainerRuntimeVersion,
+		Architecture:     n.Status.NodeInfo.Architecture,
+		Capacity:         n.Status.Capacity,
+		Allocatable:      n.Status.Allocatable,
+		Conditions:       n.Status.Conditions,
+		Taints:           n.Spec.Taints,
+		Labels:           n.Labels,
+		CreationTime:     n.CreationTimestamp.Time,
+	}
+	for _, addr := range n.Status.Addresses {
+		if addr.Type == corev1.NodeInternalIP {
+			info.InternalIP = addr.Address
+			break
+		}
+	}
+	for _, cond := range n.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			info.Ready = cond.Status == corev1.ConditionTrue
+		}
+	}
+	return info
+}
+
+// NodeRoles returns the sorted roles of the node labels, e.g. master of node-role.kubernetes.io/master.
+func NodeRoles(n *corev1.Node) []string {
+	roles := []string{}
+	for k, v := range n.Labels {
+		switch {
+		case strings.HasPrefix(k, nodeRoleLabelPrefix) && k != nodeRoleLabelPrefix:
+			roles = append(roles, strings.TrimPrefix(k, nodeRoleLabelPrefix))
+		case k == nodeRoleLabel && v != "":
+			roles = append(roles, v)
+		}
+	}
+	sort.Strings(roles)
+
+	uniq := roles[:0]
+	for i, r := range roles {
+		if i == 0 || r != roles[i-1] {
+			uniq = append(uniq, r)
+		}
+	}
+	return uniq
+}
+
+// NewNodeInfos returns the infos of the nodes sorted by name.
+func NewNodeInfos(nodes []corev1.Node) []*NodeInfo {
+	infos := make([]*NodeInfo, 0, len(nodes))
+	for i := range nodes {
+		infos = append(infos, NewNodeInfo(&nodes[i]))
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
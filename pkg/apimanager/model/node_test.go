@@ -0,0 +1,64 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNodeRoles(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   []string
+	}{
+		{name: "none", labels: map[string]string{"kubernetes.io/hostname": "n1"}, want: []string{}},
+		{name: "node-role", labels: map[string]string{"node-role.kubernetes.io/master": "", "node-role.kubernetes.io/etcd": ""}, want: []string{"etcd", "master"}},
+		{name: "legacy and duplicated", labels: map[string]string{"kubernetes.io/role": "master", "node-role.kubernetes.io/master": ""}, want: []string{"master"}},
+		{name: "empty role", labels: map[string]string{"node-role.kubernetes.io/": "", "kubernetes.io/role": ""}, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Labels: tt.labels}}
+			if got := NodeRoles(n); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NodeRoles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewNodeInfos(t *testing.T) {
+	newNode := func(name string, ready corev1.ConditionStatus) corev1.Node {
+		return corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       corev1.NodeSpec{Taints: []corev1.Taint{{Key: "k", Effect: corev1.TaintEffectNoSchedule}}},
+			Status: corev1.NodeStatus{
+				Capacity: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeHostName, Address: name},
+					{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+				},
+				Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}},
+				NodeInfo:   corev1.NodeSystemInfo{KubeletVersion: "v1.18.4", ContainerRuntimeVersion: "docker://19.3.12"},
+			},
+		}
+	}
+
+	infos := NewNodeInfos([]corev1.Node{newNode("n2", corev1.ConditionFalse), newNode("n1", corev1.ConditionTrue)})
+	if len(infos) != 2 || infos[0].Name != "n1" || infos[1].Name != "n2" {
+		t.Fatalf("NewNodeInfos() not sorted by name: %+v", infos)
+	}
+	n1 := infos[0]
+	if !n1.Ready || infos[1].Ready {
+		t.Errorf("Ready = %v, %v, want true, false", n1.Ready, infos[1].Ready)
+	}
+	if n1.InternalIP != "10.0.0.1" || n1.KubeletVersion != "v1.18.4" || n1.ContainerRuntime != "docker://19.3.12" {
+		t.Errorf("NewNodeInfo() = %+v", n1)
+	}
+	if len(n1.Taints) != 1 || n1.Capacity.Cpu().String() != "4" {
+		t.Errorf("NewNodeInfo() taints: %v, capacity: %v", n1.Taints, n1.Capacity)
+	}
+}
@@ -0,0 +1,102 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/gostship/kunkka/pkg/util/validation"
+)
+
+// MaxNodeBatch the max number of nodes added by a request
+const MaxNodeBatch = 50
+
+// 批量添加集群 worker 节点, 从 nodeRack(为空时为所有机柜)中自动分配 count 台空闲机器及同机柜的 pod 地址段,
+// dryRun 时只返回分配结果, 不占用机器也不创建节点
+type NodeBatchRequest struct {
+	Count         int      `json:"count"`
+	NodeRack      []string `json:"nodeRack"`
+	UserName      string   `json:"userName"`
+	Password      string   `json:"password"`
+	DockerVersion string   `json:"dockerVersion"`
+	NodeVersion   string   `json:"nodeVersion"`
+	DryRun        bool     `json:"dryRun"`
+}
+
+// 批量添加节点的分配结果
+type NodeAllocation struct {
+	Cluster  string              `json:"cluster"`
+	DryRun   bool                `json:"dryRun"`
+	Machines []*AllocatedMachine `json:"machines"`
+}
+
+// 分配的机器及 pod 地址段
+type AllocatedMachine struct {
+	IP      string `json:"ip"`
+	Rack    string `json:"rack"`
+	PodCidr string `json:"podCidr"`
+	Subnet  string `json:"subnet"`
+}
+
+// Sanitize validates the request and trims the rack tags which end up in labels.
+func (r *NodeBatchRequest) Sanitize() error {
+	if r.Count <= 0 || r.Count > MaxNodeBatch {
+		return fmt.Errorf("count: must be between 1 and %d", MaxNodeBatch)
+	}
+	for i := range r.NodeRack {
+		tag, err := validation.SanitizeLabelValue(r.NodeRack[i])
+		if err != nil {
+			return fmt.Errorf("nodeRack: %v", err)
+		}
+		r.NodeRack[i] = tag
+	}
+	if !r.DryRun && (r.UserName == "" || r.Password == "") {
+		return fmt.Errorf("userName and password: must be specified")
+	}
+	return nil
+}
+
+// ClusterNode returns the nodes of the cluster to create on the allocated machines.
+func (r *NodeBatchRequest) ClusterNode(cluster string, opts []*CniOption) *ClusterNode {
+	node := &ClusterNode{
+		ClusterName:   cluster,
+		DockerVersion: r.DockerVersion,
+		NodeVersion:   r.NodeVersion,
+		NodeRack:      r.NodeRack,
+		UserName:      r.UserName,
+		Password:      r.Password,
+	}
+	for _, opt := range opts {
+		node.AddressList = append(node.AddressList, opt.Machine)
+		if opt.Cni != nil {
+			node.PodPool = append(node.PodPool, opt.Cni.ID)
+		}
+	}
+	return node
+}
+
+// NewNodeAllocation returns the allocation of the machines picked by AllocateMachines.
+func NewNodeAllocation(cluster string, dryRun bool, opts []*CniOption) *NodeAllocation {
+	a := &NodeAllocation{Cluster: cluster, DryRun: dryRun, Machines: []*AllocatedMachine{}}
+	for _, opt := range opts {
+		m := &AllocatedMachine{IP: opt.Machine, Rack: opt.Racks}
+		if opt.Cni != nil {
+			m.PodCidr = opt.Cni.ID
+			m.Subnet = opt.Cni.Subnet
+		}
+		a.Machines = append(a.Machines, m)
+	}
+	return a
+}
+
+// NewCniReleases returns the releases of the machines picked by AllocateMachines, to give them back
+// to the racks when they can't be used.
+func NewCniReleases(opts []*CniOption) []*RackRelease {
+	releases := make([]*RackRelease, 0, len(opts))
+	for _, opt := range opts {
+		r := &RackRelease{Rack: opt.Racks, IP: opt.Machine}
+		if opt.Cni != nil {
+			r.PodCidr = opt.Cni.ID
+		}
+		releases = append(releases, r)
+	}
+	return releases
+}
@@ -0,0 +1,75 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+)
+
+func TestNodeBatchRequestSanitize(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     NodeBatchRequest
+		wantErr bool
+	}{
+		{name: "valid", req: NodeBatchRequest{Count: 3, NodeRack: []string{" rack1 "}, UserName: "root", Password: "secret"}},
+		{name: "no count", req: NodeBatchRequest{UserName: "root", Password: "secret"}, wantErr: true},
+		{name: "too many", req: NodeBatchRequest{Count: MaxNodeBatch + 1, UserName: "root", Password: "secret"}, wantErr: true},
+		{name: "no password", req: NodeBatchRequest{Count: 1, UserName: "root"}, wantErr: true},
+		{name: "dry run without password", req: NodeBatchRequest{Count: 1, DryRun: true}},
+		{name: "invalid rack", req: NodeBatchRequest{Count: 1, NodeRack: []string{"rack 1"}, DryRun: true}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.Sanitize(); (err != nil) != tt.wantErr {
+				t.Errorf("Sanitize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNodeAllocationRelease(t *testing.T) {
+	racks := []*Rack{{
+		RackTag:  "rack1",
+		HostAddr: []*HostAddr{{IPADDR: "10.0.0.1"}, {IPADDR: "10.0.0.2"}, {IPADDR: "10.0.0.3"}},
+		PodCidr:  []*devopsv1.ClusterCni{{ID: "p1", Subnet: "10.1.0.0/24"}, {ID: "p2", Subnet: "10.1.1.0/24"}},
+	}}
+	opts, err := AllocateMachines(racks, nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &NodeBatchRequest{Count: 2, UserName: "root", Password: "secret"}
+	node := req.ClusterNode("c1", opts)
+	if !reflect.DeepEqual(node.AddressList, []string{"10.0.0.1", "10.0.0.2"}) || !reflect.DeepEqual(node.PodPool, []string{"p1", "p2"}) {
+		t.Errorf("ClusterNode() = %v %v", node.AddressList, node.PodPool)
+	}
+	got := NewNodeAllocation("c1", false, opts)
+	want := []*AllocatedMachine{
+		{IP: "10.0.0.1", Rack: "rack1", PodCidr: "p1", Subnet: "10.1.0.0/24"},
+		{IP: "10.0.0.2", Rack: "rack1", PodCidr: "p2", Subnet: "10.1.1.0/24"},
+	}
+	if !reflect.DeepEqual(got.Machines, want) {
+		t.Errorf("NewNodeAllocation() = %+v, want %+v", got.Machines, want)
+	}
+
+	if n := ReleaseRacks(racks, NewCniReleases(opts)); n != 2 {
+		t.Errorf("ReleaseRacks() = %d, want 2", n)
+	}
+	for _, host := range racks[0].HostAddr {
+		if host.UseState != 0 {
+			t.Errorf("host: %s not released", host.IPADDR)
+		}
+	}
+	for _, cidr := range racks[0].PodCidr {
+		if cidr.UseState != 0 {
+			t.Errorf("pod cidr: %s not released", cidr.ID)
+		}
+	}
+	if _, err := AllocateMachines(racks, nil, 4); err == nil {
+		t.Errorf("AllocateMachines() want error")
+	} else if _, ok := err.(*NotEnoughMachinesError); !ok {
+		t.Errorf("AllocateMachines() error = %T, want *NotEnoughMachinesError", err)
+	}
+}
@@ -0,0 +1,138 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// 节点 labels 及污点的变更, 同时应用于节点及其 Machine(或集群 master)的 spec, 避免被 MarkNode 覆盖;
+// RemoveTaints 的 Effect 为空时删除该 Key 的全部污点
+type NodeMarksPatch struct {
+	Labels       map[string]string `json:"labels"`
+	RemoveLabels []string          `json:"removeLabels"`
+	Taints       []corev1.Taint    `json:"taints"`
+	RemoveTaints []corev1.Taint    `json:"removeTaints"`
+}
+
+// 校验变更, kubelet 管理的 kubernetes.io、k8s.io 域的 labels 不可修改
+func (p *NodeMarksPatch) Validate() error {
+	if len(p.Labels) == 0 && len(p.RemoveLabels) == 0 && len(p.Taints) == 0 && len(p.RemoveTaints) == 0 {
+		return fmt.Errorf("labels, removeLabels, taints or removeTaints: must be specified")
+	}
+	for k, v := range p.Labels {
+		if err := validateNodeLabelKey(k); err != nil {
+			return fmt.Errorf("labels: %v", err)
+		}
+		if errs := validation.IsValidLabelValue(v); len(errs) > 0 {
+			return fmt.Errorf("labels: %s: %s", k, strings.Join(errs, ", "))
+		}
+	}
+	for _, k := range p.RemoveLabels {
+		if err := validateNodeLabelKey(k); err != nil {
+			return fmt.Errorf("removeLabels: %v", err)
+		}
+	}
+	for _, t := range p.Taints {
+		if err := validateTaint(t, false); err != nil {
+			return fmt.Errorf("taints: %v", err)
+		}
+	}
+	for _, t := range p.RemoveTaints {
+		if err := validateTaint(t, true); err != nil {
+			return fmt.Errorf("removeTaints: %v", err)
+		}
+	}
+	return nil
+}
+
+// Apply returns the labels and the taints changed by the patch, the removals are applied first
+// and the taints of the same key and effect are replaced. The arguments are not modified.
+func (p *NodeMarksPatch) Apply(labels map[string]string, taints []corev1.Taint) (map[string]string, []corev1.Taint) {
+	newLabels := make(map[string]string, len(labels)+len(p.Labels))
+	for k, v := range labels {
+		newLabels[k] = v
+	}
+	for _, k := range p.RemoveLabels {
+		delete(newLabels, k)
+	}
+	for k, v := range p.Labels {
+		newLabels[k] = v
+	}
+
+	newTaints := []corev1.Taint{}
+	for _, t := range taints {
+		if !p.removesTaint(t) && !p.replacesTaint(t) {
+			newTaints = append(newTaints, t)
+		}
+	}
+	newTaints = append(newTaints, p.Taints...)
+	return newLabels, newTaints
+}
+
+func (p *NodeMarksPatch) removesTaint(t corev1.Taint) bool {
+	for _, r := range p.RemoveTaints {
+		if r.Key == t.Key && (r.Effect == "" || r.Effect == t.Effect) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *NodeMarksPatch) replacesTaint(t corev1.Taint) bool {
+	for i := range p.Taints {
+		if p.Taints[i].MatchTaint(&t) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateNodeLabelKey(key string) error {
+	if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+		return fmt.Errorf("%s: %s", key, strings.Join(errs, ", "))
+	}
+	if isKubeletLabel(key) {
+		return fmt.Errorf("%s: managed by the kubelet", key)
+	}
+	return nil
+}
+
+// isKubeletLabel reports whether the label is in the kubernetes.io or k8s.io domain reserved for
+// the kubelet, except the node-role and node-restriction labels which are set by the admins.
+func isKubeletLabel(key string) bool {
+	i := strings.Index(key, "/")
+	if i < 0 {
+		return false
+	}
+	domain := key[:i]
+	switch domain {
+	case "node-role.kubernetes.io", "node-restriction.kubernetes.io":
+		return false
+	}
+	return domain == "kubernetes.io" || domain == "k8s.io" ||
+		strings.HasSuffix(domain, ".kubernetes.io") || strings.HasSuffix(domain, ".k8s.io")
+}
+
+func validateTaint(t corev1.Taint, removal bool) error {
+	if errs := validation.IsQualifiedName(t.Key); len(errs) > 0 {
+		return fmt.Errorf("%s: %s", t.Key, strings.Join(errs, ", "))
+	}
+	if !removal && t.Value != "" {
+		if errs := validation.IsValidLabelValue(t.Value); len(errs) > 0 {
+			return fmt.Errorf("%s: %s", t.Key, strings.Join(errs, ", "))
+		}
+	}
+	switch t.Effect {
+	case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+	case "":
+		if !removal {
+			return fmt.Errorf("%s: effect must be specified", t.Key)
+		}
+	default:
+		return fmt.Errorf("%s: unsupported effect: %s", t.Key, t.Effect)
+	}
+	return nil
+}
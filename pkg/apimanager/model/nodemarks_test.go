@@ -0,0 +1,72 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestNodeMarksPatchValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   NodeMarksPatch
+		wantErr bool
+	}{
+		{name: "empty", patch: NodeMarksPatch{}, wantErr: true},
+		{name: "label", patch: NodeMarksPatch{Labels: map[string]string{"example.com/pool": "gpu"}}},
+		{name: "node role", patch: NodeMarksPatch{Labels: map[string]string{"node-role.kubernetes.io/ingress": ""}}},
+		{name: "invalid label value", patch: NodeMarksPatch{Labels: map[string]string{"pool": "a b"}}, wantErr: true},
+		{name: "kubelet label", patch: NodeMarksPatch{Labels: map[string]string{"kubernetes.io/hostname": "n1"}}, wantErr: true},
+		{name: "remove kubelet label", patch: NodeMarksPatch{RemoveLabels: []string{"topology.kubernetes.io/zone"}}, wantErr: true},
+		{name: "taint", patch: NodeMarksPatch{Taints: []corev1.Taint{{Key: "gpu", Value: "true", Effect: corev1.TaintEffectNoSchedule}}}},
+		{name: "taint without effect", patch: NodeMarksPatch{Taints: []corev1.Taint{{Key: "gpu"}}}, wantErr: true},
+		{name: "taint with invalid effect", patch: NodeMarksPatch{Taints: []corev1.Taint{{Key: "gpu", Effect: "Never"}}}, wantErr: true},
+		{name: "remove taint by key", patch: NodeMarksPatch{RemoveTaints: []corev1.Taint{{Key: "gpu"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.patch.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNodeMarksPatchApply(t *testing.T) {
+	labels := map[string]string{"kubernetes.io/hostname": "n1", "pool": "cpu", "old": "x"}
+	taints := []corev1.Taint{
+		{Key: "gpu", Value: "false", Effect: corev1.TaintEffectNoSchedule},
+		{Key: "gpu", Effect: corev1.TaintEffectNoExecute},
+		{Key: "dedicated", Value: "db", Effect: corev1.TaintEffectNoSchedule},
+		{Key: "maintenance", Effect: corev1.TaintEffectPreferNoSchedule},
+	}
+	patch := &NodeMarksPatch{
+		Labels:       map[string]string{"pool": "gpu"},
+		RemoveLabels: []string{"old", "missing"},
+		Taints:       []corev1.Taint{{Key: "gpu", Value: "true", Effect: corev1.TaintEffectNoSchedule}},
+		RemoveTaints: []corev1.Taint{{Key: "dedicated"}, {Key: "maintenance", Effect: corev1.TaintEffectNoSchedule}},
+	}
+
+	gotLabels, gotTaints := patch.Apply(labels, taints)
+	wantLabels := map[string]string{"kubernetes.io/hostname": "n1", "pool": "gpu"}
+	if !reflect.DeepEqual(gotLabels, wantLabels) {
+		t.Errorf("Apply() labels = %v, want %v", gotLabels, wantLabels)
+	}
+	wantTaints := []corev1.Taint{
+		{Key: "gpu", Effect: corev1.TaintEffectNoExecute},
+		{Key: "maintenance", Effect: corev1.TaintEffectPreferNoSchedule},
+		{Key: "gpu", Value: "true", Effect: corev1.TaintEffectNoSchedule},
+	}
+	if !reflect.DeepEqual(gotTaints, wantTaints) {
+		t.Errorf("Apply() taints = %v, want %v", gotTaints, wantTaints)
+	}
+	if labels["old"] != "x" || len(taints) != 4 {
+		t.Errorf("Apply() modified the arguments")
+	}
+
+	gotLabels, gotTaints = (&NodeMarksPatch{Labels: map[string]string{"a": "b"}}).Apply(nil, nil)
+	if gotLabels["a"] != "b" || gotTaints == nil {
+		t.Errorf("Apply() of nil = %v, %v", gotLabels, gotTaints)
+	}
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// 节点下线: 禁止调度并驱逐节点上的 pod, 删除 Node 及 Machine, 机器清理完成后释放机柜中的机器及 pod 地址段
+type NodeRemoval struct {
+	Cluster   string `json:"cluster"`
+	IP        string `json:"ip"`
+	Requester string `json:"requester"`
+	// Phase Draining 驱逐中, Deleting 机器清理中, Released 已释放机柜地址, Failed 下线失败(节点保持禁止调度)
+	Phase   string `json:"phase"`
+	Message string `json:"message,omitempty"`
+	Force   bool   `json:"force"`
+	// Evicted 已驱逐的 pod, Remaining 超时未驱逐的 pod, 均为 namespace/name
+	Evicted    []string       `json:"evicted"`
+	Remaining  []string       `json:"remaining,omitempty"`
+	Racks      []*RackRelease `json:"racks"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	DeletedAt  *time.Time     `json:"deletedAt,omitempty"`
+	FinishedAt *time.Time     `json:"finishedAt,omitempty"`
+}
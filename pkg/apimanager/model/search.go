@@ -0,0 +1,145 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SearchKinds the kinds of the resources searchable across the clusters, keyed by their lower case names and short names
+var SearchKinds = map[string]string{
+	"pod":                   "Pod",
+	"po":                    "Pod",
+	"service":               "Service",
+	"svc":                   "Service",
+	"deployment":            "Deployment",
+	"deploy":                "Deployment",
+	"statefulset":           "StatefulSet",
+	"sts":                   "StatefulSet",
+	"daemonset":             "DaemonSet",
+	"ds":                    "DaemonSet",
+	"job":                   "Job",
+	"cronjob":               "CronJob",
+	"cj":                    "CronJob",
+	"configmap":             "ConfigMap",
+	"cm":                    "ConfigMap",
+	"ingress":               "Ingress",
+	"ing":                   "Ingress",
+	"persistentvolumeclaim": "PersistentVolumeClaim",
+	"pvc":                   "PersistentVolumeClaim",
+	"namespace":             "Namespace",
+	"ns":                    "Namespace",
+	"node":                  "Node",
+	"no":                    "Node",
+}
+
+const (
+	DefaultSearchLimit = 500
+	MaxSearchLimit     = 1000
+)
+
+// 多集群资源搜索, Kinds 必须指定, Name 为子串匹配, Label 为 kubernetes label selector
+type SearchQuery struct {
+	Kinds     []string
+	Namespace string
+	Name      string
+	Label     string
+	Limit     int
+}
+
+// 搜索到的资源, 附带所在集群
+type SearchItem struct {
+	Cluster      string            `json:"cluster"`
+	Kind         string            `json:"kind"`
+	Namespace    string            `json:"namespace,omitempty"`
+	Name         string            `json:"name"`
+	Labels       map[string]string `json:"labels"`
+	CreationTime metav1.Time       `json:"creationTime"`
+}
+
+// 搜索失败或超时的集群
+type SearchFailure struct {
+	Cluster string `json:"cluster"`
+	Message string `json:"message"`
+}
+
+// 搜索结果, Partial 表示有集群失败或超时, 结果不完整; Truncated 表示结果超过 limit 被截断
+type SearchResult struct {
+	Items     []*SearchItem    `json:"items"`
+	Failed    []*SearchFailure `json:"failed"`
+	Partial   bool             `json:"partial"`
+	Truncated bool             `json:"truncated"`
+}
+
+// Validate normalizes the kinds to their names, e.g. svc to Service, and defaults the limit.
+func (q *SearchQuery) Validate() error {
+	if len(q.Kinds) == 0 {
+		return fmt.Errorf("kind: must be specified")
+	}
+	seen := map[string]bool{}
+	kinds := []string{}
+	for _, k := range q.Kinds {
+		kind, ok := searchKind(k)
+		if !ok {
+			return fmt.Errorf("kind: unsupported kind: %s", k)
+		}
+		if !seen[kind] {
+			seen[kind] = true
+			kinds = append(kinds, kind)
+		}
+	}
+	q.Kinds = kinds
+
+	if q.Limit == 0 {
+		q.Limit = DefaultSearchLimit
+	}
+	if q.Limit < 0 || q.Limit > MaxSearchLimit {
+		return fmt.Errorf("limit: must be 1-%d", MaxSearchLimit)
+	}
+	return nil
+}
+
+// searchKind returns the kind of the name, short name or plural of a searchable kind.
+func searchKind(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, n := range []string{name, strings.TrimSuffix(name, "es"), strings.TrimSuffix(name, "s")} {
+		if kind, ok := SearchKinds[n]; ok {
+			return kind, true
+		}
+	}
+	return "", false
+}
+
+// Matches reports whether the name contains the name of the query.
+func (q *SearchQuery) Matches(name string) bool {
+	return q.Name == "" || strings.Contains(name, q.Name)
+}
+
+// NewSearchResult sorts the items by cluster, kind, namespace and name, and truncates them to the limit.
+func NewSearchResult(items []*SearchItem, failed []*SearchFailure, limit int) *SearchResult {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	r := &SearchResult{Items: items, Failed: failed, Partial: len(failed) > 0}
+	if r.Failed == nil {
+		r.Failed = []*SearchFailure{}
+	}
+	if len(items) > limit {
+		r.Items = items[:limit]
+		r.Truncated = true
+	}
+	return r
+}
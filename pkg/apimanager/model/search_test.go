@@ -0,0 +1,59 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchQueryValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     SearchQuery
+		wantKinds []string
+		wantLimit int
+		wantErr   bool
+	}{
+		{name: "kinds", query: SearchQuery{Kinds: []string{"svc", "Deployments", "ingresses", "service", "NS"}}, wantKinds: []string{"Service", "Deployment", "Ingress", "Namespace"}, wantLimit: DefaultSearchLimit},
+		{name: "limit", query: SearchQuery{Kinds: []string{"pods"}, Limit: 10}, wantKinds: []string{"Pod"}, wantLimit: 10},
+		{name: "no kind", query: SearchQuery{Name: "nginx"}, wantErr: true},
+		{name: "unsupported kind", query: SearchQuery{Kinds: []string{"secret"}}, wantErr: true},
+		{name: "limit too large", query: SearchQuery{Kinds: []string{"pod"}, Limit: MaxSearchLimit + 1}, wantErr: true},
+		{name: "negative limit", query: SearchQuery{Kinds: []string{"pod"}, Limit: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.query.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(tt.query.Kinds, tt.wantKinds) || tt.query.Limit != tt.wantLimit {
+				t.Errorf("Validate() = %v %d, want %v %d", tt.query.Kinds, tt.query.Limit, tt.wantKinds, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestNewSearchResult(t *testing.T) {
+	items := []*SearchItem{
+		{Cluster: "c2", Kind: "Service", Namespace: "web", Name: "nginx"},
+		{Cluster: "c1", Kind: "Service", Namespace: "web", Name: "nginx"},
+		{Cluster: "c1", Kind: "Deployment", Namespace: "web", Name: "nginx"},
+	}
+
+	r := NewSearchResult(items, nil, 2)
+	got := []string{}
+	for _, i := range r.Items {
+		got = append(got, i.Cluster+"/"+i.Kind)
+	}
+	if !reflect.DeepEqual(got, []string{"c1/Deployment", "c1/Service"}) || !r.Truncated || r.Partial || r.Failed == nil {
+		t.Errorf("NewSearchResult() = %v, truncated: %v, partial: %v", got, r.Truncated, r.Partial)
+	}
+
+	r = NewSearchResult(items, []*SearchFailure{{Cluster: "c3", Message: "timeout"}}, DefaultSearchLimit)
+	if len(r.Items) != 3 || r.Truncated || !r.Partial {
+		t.Errorf("NewSearchResult() items: %d, truncated: %v, partial: %v", len(r.Items), r.Truncated, r.Partial)
+	}
+}
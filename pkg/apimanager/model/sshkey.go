@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// SSH 密钥轮换, 新密钥下发到集群所有机器并用新密钥验证连通后, 才更新机器配置并删除旧密钥, 任一机器失败则回滚
+type SSHKeyRotation struct {
+	ID      string `json:"id"`
+	Cluster string `json:"cluster"`
+	User    string `json:"user"`
+	// Phase Distributing, Verifying, Updating, RemovingOld, Succeeded, Failed
+	Phase   string `json:"phase"`
+	Message string `json:"message,omitempty"`
+	// Fingerprint 新公钥的 SHA256 指纹
+	Fingerprint string                   `json:"fingerprint"`
+	Machines    []*SSHKeyRotationMachine `json:"machines"`
+	CreatedAt   time.Time                `json:"createdAt"`
+	FinishedAt  *time.Time               `json:"finishedAt,omitempty"`
+}
+
+// 密钥轮换中单台机器的状态, oldFingerprint 为空表示旧凭证只有密码, 无需删除旧密钥
+type SSHKeyRotationMachine struct {
+	Name           string `json:"name"`
+	IP             string `json:"ip"`
+	Master         bool   `json:"master,omitempty"`
+	OldFingerprint string `json:"oldFingerprint,omitempty"`
+	// Phase Pending, Authorized, Verified, Updated, Rotated, RolledBack, Failed
+	Phase   string `json:"phase"`
+	Message string `json:"message,omitempty"`
+}
+
+// 密钥轮换的参数, privateKey 为空时生成 rsa 4096 密钥
+type SSHKeyRotationRequest struct {
+	PrivateKey string `json:"privateKey"`
+	PassPhrase string `json:"passPhrase"`
+}
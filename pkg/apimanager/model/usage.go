@@ -0,0 +1,200 @@
+package model
+
+import (
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// UsageResources the resources summed up by the usage summaries
+var UsageResources = []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory}
+
+// 资源的 requests、limits 及 metrics-server 采集的实际用量, 只统计 cpu 及 memory
+type ResourceUsage struct {
+	Requests corev1.ResourceList `json:"requests"`
+	Limits   corev1.ResourceList `json:"limits"`
+	Usage    corev1.ResourceList `json:"usage"`
+}
+
+// 命名空间的资源用量, 不包括已结束的 pod
+type NamespaceUsage struct {
+	Namespace string `json:"namespace"`
+	Pods      int    `json:"pods"`
+	ResourceUsage
+}
+
+// 集群的资源用量及各命名空间的用量, metrics 为 false 时集群没有可用的 metrics-server, usage 为空
+type ClusterUsage struct {
+	Cluster     string              `json:"cluster"`
+	Nodes       int                 `json:"nodes"`
+	Pods        int                 `json:"pods"`
+	Allocatable corev1.ResourceList `json:"allocatable"`
+	Metrics     bool                `json:"metrics"`
+	ResourceUsage
+	Namespaces []*NamespaceUsage `json:"namespaces"`
+}
+
+// 多个集群的资源用量, 以及所有集群及同名命名空间的汇总
+type FleetUsage struct {
+	Nodes       int                 `json:"nodes"`
+	Pods        int                 `json:"pods"`
+	Allocatable corev1.ResourceList `json:"allocatable"`
+	ResourceUsage
+	Namespaces []*NamespaceUsage `json:"namespaces"`
+	Clusters   []*ClusterUsage   `json:"clusters"`
+}
+
+// PodMetricsList the pod metrics of metrics.k8s.io/v1beta1, only the fields summed up.
+type PodMetricsList struct {
+	Items []PodMetrics `json:"items"`
+}
+
+// PodMetrics ...
+type PodMetrics struct {
+	metav1.ObjectMeta `json:"metadata"`
+	Containers        []ContainerMetrics `json:"containers"`
+}
+
+// ContainerMetrics ...
+type ContainerMetrics struct {
+	Name  string              `json:"name"`
+	Usage corev1.ResourceList `json:"usage"`
+}
+
+// NewClusterUsage sums up the requests and the limits of the pods not terminated and the usage of the pod
+// metrics by namespace, metrics is nil if the cluster has no metrics-server.
+func NewClusterUsage(cluster string, nodes []corev1.Node, pods []corev1.Pod, metrics *PodMetricsList) *ClusterUsage {
+	u := &ClusterUsage{
+		Cluster:       cluster,
+		Nodes:         len(nodes),
+		Allocatable:   corev1.ResourceList{},
+		Metrics:       metrics != nil,
+		ResourceUsage: newResourceUsage(),
+	}
+	for i := range nodes {
+		addResources(u.Allocatable, nodes[i].Status.Allocatable)
+	}
+
+	namespaces := map[string]*NamespaceUsage{}
+	namespace := func(name string) *NamespaceUsage {
+		ns, ok := namespaces[name]
+		if !ok {
+			ns = &NamespaceUsage{Namespace: name, ResourceUsage: newResourceUsage()}
+			namespaces[name] = ns
+		}
+		return ns
+	}
+	for i := range pods {
+		if pods[i].Status.Phase == corev1.PodSucceeded || pods[i].Status.Phase == corev1.PodFailed {
+			continue
+		}
+		requests, limits := PodResources(&pods[i])
+		ns := namespace(pods[i].Namespace)
+		ns.Pods++
+		addResources(ns.Requests, requests)
+		addResources(ns.Limits, limits)
+	}
+	if metrics != nil {
+		for i := range metrics.Items {
+			ns := namespace(metrics.Items[i].Namespace)
+			for _, c := range metrics.Items[i].Containers {
+				addResources(ns.Usage, c.Usage)
+			}
+		}
+	}
+
+	u.Namespaces = sortedNamespaceUsages(namespaces)
+	for _, ns := range u.Namespaces {
+		u.Pods += ns.Pods
+		u.ResourceUsage.add(&ns.ResourceUsage)
+	}
+	return u
+}
+
+// NewFleetUsage sums up the usage of the clusters, the namespaces of the same name are summed up together.
+func NewFleetUsage(clusters []*ClusterUsage) *FleetUsage {
+	f := &FleetUsage{
+		Allocatable:   corev1.ResourceList{},
+		ResourceUsage: newResourceUsage(),
+		Clusters:      clusters,
+	}
+	namespaces := map[string]*NamespaceUsage{}
+	for _, cluster := range clusters {
+		f.Nodes += cluster.Nodes
+		f.Pods += cluster.Pods
+		addResources(f.Allocatable, cluster.Allocatable)
+		f.ResourceUsage.add(&cluster.ResourceUsage)
+		for _, ns := range cluster.Namespaces {
+			sum, ok := namespaces[ns.Namespace]
+			if !ok {
+				sum = &NamespaceUsage{Namespace: ns.Namespace, ResourceUsage: newResourceUsage()}
+				namespaces[ns.Namespace] = sum
+			}
+			sum.Pods += ns.Pods
+			sum.ResourceUsage.add(&ns.ResourceUsage)
+		}
+	}
+	f.Namespaces = sortedNamespaceUsages(namespaces)
+	return f
+}
+
+// PodResources returns the effective requests and limits of the pod as the scheduler sees them, the larger
+// of the sum of the containers and of any init container.
+func PodResources(pod *corev1.Pod) (corev1.ResourceList, corev1.ResourceList) {
+	requests, limits := corev1.ResourceList{}, corev1.ResourceList{}
+	for _, c := range pod.Spec.Containers {
+		addResources(requests, c.Resources.Requests)
+		addResources(limits, c.Resources.Limits)
+	}
+	for _, c := range pod.Spec.InitContainers {
+		maxResources(requests, c.Resources.Requests)
+		maxResources(limits, c.Resources.Limits)
+	}
+	return requests, limits
+}
+
+func newResourceUsage() ResourceUsage {
+	return ResourceUsage{Requests: corev1.ResourceList{}, Limits: corev1.ResourceList{}, Usage: corev1.ResourceList{}}
+}
+
+func (u *ResourceUsage) add(o *ResourceUsage) {
+	addResources(u.Requests, o.Requests)
+	addResources(u.Limits, o.Limits)
+	addResources(u.Usage, o.Usage)
+}
+
+func addResources(dst, src corev1.ResourceList) {
+	for _, name := range UsageResources {
+		q, ok := src[name]
+		if !ok {
+			continue
+		}
+		sum := dst[name]
+		sum.Add(q)
+		dst[name] = sum
+	}
+}
+
+func maxResources(dst, src corev1.ResourceList) {
+	for _, name := range UsageResources {
+		q, ok := src[name]
+		if !ok {
+			continue
+		}
+		if cur, ok := dst[name]; !ok || q.Cmp(cur) > 0 {
+			dst[name] = q.DeepCopy()
+		}
+	}
+}
+
+func sortedNamespaceUsages(namespaces map[string]*NamespaceUsage) []*NamespaceUsage {
+	list := make([]*NamespaceUsage, 0, len(namespaces))
+	for _, ns := range namespaces {
+		list = append(list, ns)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Namespace < list[j].Namespace
+	})
+	return list
+}
@@ -0,0 +1,106 @@
+package model
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func resources(cpu, memory string) corev1.ResourceList {
+	list := corev1.ResourceList{}
+	if cpu != "" {
+		list[corev1.ResourceCPU] = resource.MustParse(cpu)
+	}
+	if memory != "" {
+		list[corev1.ResourceMemory] = resource.MustParse(memory)
+	}
+	return list
+}
+
+func usagePod(namespace string, phase corev1.PodPhase, init corev1.ResourceList, containers ...corev1.ResourceList) corev1.Pod {
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace},
+		Status:     corev1.PodStatus{Phase: phase},
+	}
+	if init != nil {
+		pod.Spec.InitContainers = []corev1.Container{{Resources: corev1.ResourceRequirements{Requests: init, Limits: init}}}
+	}
+	for _, r := range containers {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{
+			Resources: corev1.ResourceRequirements{Requests: r, Limits: r},
+		})
+	}
+	return pod
+}
+
+func wantResources(t *testing.T, what string, got, want corev1.ResourceList) {
+	t.Helper()
+	for _, name := range UsageResources {
+		g, w := got[name], want[name]
+		if g.Cmp(w) != 0 {
+			t.Errorf("%s %s = %s, want %s", what, name, g.String(), w.String())
+		}
+	}
+}
+
+func TestPodResources(t *testing.T) {
+	tests := []struct {
+		name string
+		pod  corev1.Pod
+		want corev1.ResourceList
+	}{
+		{name: "containers", pod: usagePod("a", corev1.PodRunning, nil, resources("100m", "64Mi"), resources("200m", "")), want: resources("300m", "64Mi")},
+		{name: "larger init container", pod: usagePod("a", corev1.PodRunning, resources("1", "32Mi"), resources("100m", "64Mi")), want: resources("1", "64Mi")},
+		{name: "no resources", pod: usagePod("a", corev1.PodRunning, nil, corev1.ResourceList{}), want: corev1.ResourceList{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests, limits := PodResources(&tt.pod)
+			wantResources(t, "requests", requests, tt.want)
+			wantResources(t, "limits", limits, tt.want)
+		})
+	}
+}
+
+func TestNewClusterUsage(t *testing.T) {
+	nodes := []corev1.Node{
+		{Status: corev1.NodeStatus{Allocatable: resources("4", "8Gi")}},
+		{Status: corev1.NodeStatus{Allocatable: resources("4", "8Gi")}},
+	}
+	pods := []corev1.Pod{
+		usagePod("a", corev1.PodRunning, nil, resources("500m", "1Gi")),
+		usagePod("a", corev1.PodPending, nil, resources("500m", "1Gi")),
+		usagePod("a", corev1.PodSucceeded, nil, resources("2", "2Gi")),
+		usagePod("b", corev1.PodRunning, nil, resources("1", "")),
+	}
+	metrics := &PodMetricsList{Items: []PodMetrics{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "a"}, Containers: []ContainerMetrics{{Usage: resources("200m", "512Mi")}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "b"}, Containers: []ContainerMetrics{{Usage: resources("50m", "100Mi")}, {Usage: resources("50m", "")}}},
+	}}
+
+	u := NewClusterUsage("c1", nodes, pods, metrics)
+	if u.Nodes != 2 || u.Pods != 3 || !u.Metrics || len(u.Namespaces) != 2 {
+		t.Fatalf("NewClusterUsage() = nodes %d pods %d metrics %v namespaces %d, want 2 3 true 2", u.Nodes, u.Pods, u.Metrics, len(u.Namespaces))
+	}
+	wantResources(t, "allocatable", u.Allocatable, resources("8", "16Gi"))
+	wantResources(t, "requests", u.Requests, resources("2", "2Gi"))
+	wantResources(t, "usage", u.Usage, resources("300m", "612Mi"))
+	if a := u.Namespaces[0]; a.Namespace != "a" || a.Pods != 2 {
+		t.Errorf("namespace = %s pods %d, want a pods 2", a.Namespace, a.Pods)
+	}
+	wantResources(t, "namespace b usage", u.Namespaces[1].Usage, resources("100m", "100Mi"))
+
+	noMetrics := NewClusterUsage("c2", nodes, pods, nil)
+	if noMetrics.Metrics || len(noMetrics.Usage) != 0 {
+		t.Errorf("NewClusterUsage() without metrics = metrics %v usage %v, want false and empty", noMetrics.Metrics, noMetrics.Usage)
+	}
+
+	f := NewFleetUsage([]*ClusterUsage{u, noMetrics})
+	if f.Nodes != 4 || f.Pods != 6 || len(f.Namespaces) != 2 || f.Namespaces[0].Pods != 4 {
+		t.Errorf("NewFleetUsage() = nodes %d pods %d namespaces %d, want 4 6 2", f.Nodes, f.Pods, len(f.Namespaces))
+	}
+	wantResources(t, "fleet requests", f.Requests, resources("4", "4Gi"))
+	wantResources(t, "fleet namespace a limits", f.Namespaces[0].Limits, resources("2", "4Gi"))
+}
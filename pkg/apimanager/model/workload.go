@@ -0,0 +1,164 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	WorkloadDeployment  = "Deployment"
+	WorkloadStatefulSet = "StatefulSet"
+	WorkloadDaemonSet   = "DaemonSet"
+)
+
+// WorkloadKinds the kinds of the workloads of the inventory
+var WorkloadKinds = []string{WorkloadDeployment, WorkloadStatefulSet, WorkloadDaemonSet}
+
+// 工作负载清单, DaemonSet 的副本数为期望调度的节点数
+type WorkloadSummary struct {
+	Cluster           string            `json:"cluster"`
+	Namespace         string            `json:"namespace"`
+	Kind              string            `json:"kind"`
+	Name              string            `json:"name"`
+	Replicas          int32             `json:"replicas"`
+	ReadyReplicas     int32             `json:"readyReplicas"`
+	UpdatedReplicas   int32             `json:"updatedReplicas"`
+	AvailableReplicas int32             `json:"availableReplicas"`
+	Images            []string          `json:"images"`
+	Labels            map[string]string `json:"labels"`
+	CreationTime      metav1.Time       `json:"creationTime"`
+}
+
+// DeploymentSummary ...
+func DeploymentSummary(cluster string, d *appsv1.Deployment) *WorkloadSummary {
+	w := newWorkloadSummary(cluster, WorkloadDeployment, &d.ObjectMeta, &d.Spec.Template.Spec)
+	if d.Spec.Replicas != nil {
+		w.Replicas = *d.Spec.Replicas
+	}
+	w.ReadyReplicas = d.Status.ReadyReplicas
+	w.UpdatedReplicas = d.Status.UpdatedReplicas
+	w.AvailableReplicas = d.Status.AvailableReplicas
+	return w
+}
+
+// StatefulSetSummary ...
+func StatefulSetSummary(cluster string, s *appsv1.StatefulSet) *WorkloadSummary {
+	w := newWorkloadSummary(cluster, WorkloadStatefulSet, &s.ObjectMeta, &s.Spec.Template.Spec)
+	if s.Spec.Replicas != nil {
+		w.Replicas = *s.Spec.Replicas
+	}
+	w.ReadyReplicas = s.Status.ReadyReplicas
+	w.UpdatedReplicas = s.Status.UpdatedReplicas
+	// the available replicas of the statefulsets are not reported before 1.22
+	w.AvailableReplicas = s.Status.ReadyReplicas
+	return w
+}
+
+// DaemonSetSummary ...
+func DaemonSetSummary(cluster string, ds *appsv1.DaemonSet) *WorkloadSummary {
+	w := newWorkloadSummary(cluster, WorkloadDaemonSet, &ds.ObjectMeta, &ds.Spec.Template.Spec)
+	w.Replicas = ds.Status.DesiredNumberScheduled
+	w.ReadyReplicas = ds.Status.NumberReady
+	w.UpdatedReplicas = ds.Status.UpdatedNumberScheduled
+	w.AvailableReplicas = ds.Status.NumberAvailable
+	return w
+}
+
+func newWorkloadSummary(cluster, kind string, meta *metav1.ObjectMeta, pod *corev1.PodSpec) *WorkloadSummary {
+	images := []string{}
+	for _, c := range pod.Containers {
+		images = append(images, c.Image)
+	}
+	return &WorkloadSummary{
+		Cluster:      cluster,
+		Namespace:    meta.Namespace,
+		Kind:         kind,
+		Name:         meta.Name,
+		Images:       images,
+		Labels:       meta.Labels,
+		CreationTime: meta.CreationTimestamp,
+	}
+}
+
+// 工作负载查询, Name 及 Image 为子串匹配, Kinds 为空时查询全部类型
+type WorkloadQuery struct {
+	Kinds     []string
+	Namespace string
+	Name      string
+	Image     string
+}
+
+// 校验查询参数
+func (q *WorkloadQuery) Validate() error {
+	for _, kind := range q.Kinds {
+		if !q.validKind(kind) {
+			return fmt.Errorf("kind: must be %s", strings.Join(WorkloadKinds, ", "))
+		}
+	}
+	return nil
+}
+
+func (q *WorkloadQuery) validKind(kind string) bool {
+	for _, k := range WorkloadKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Includes reports whether the workloads of the kind are queried.
+func (q *WorkloadQuery) Includes(kind string) bool {
+	if len(q.Kinds) == 0 {
+		return true
+	}
+	for _, k := range q.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// 过滤并按集群、命名空间、类型及名称排列工作负载
+func (q *WorkloadQuery) Apply(workloads []*WorkloadSummary) []*WorkloadSummary {
+	list := []*WorkloadSummary{}
+	for _, w := range workloads {
+		if !q.Includes(w.Kind) ||
+			(q.Namespace != "" && w.Namespace != q.Namespace) ||
+			(q.Name != "" && !strings.Contains(w.Name, q.Name)) ||
+			(q.Image != "" && !hasImage(w.Images, q.Image)) {
+			continue
+		}
+		list = append(list, w)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return list
+}
+
+func hasImage(images []string, image string) bool {
+	for _, i := range images {
+		if strings.Contains(i, image) {
+			return true
+		}
+	}
+	return false
+}
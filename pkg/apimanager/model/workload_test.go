@@ -0,0 +1,78 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWorkloadSummary(t *testing.T) {
+	replicas := int32(3)
+	template := corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Image: "nginx:1.19"}, {Image: "envoy:1.16"}}}}
+
+	d := DeploymentSummary("c1", &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "web", Name: "nginx"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas, Template: template},
+		Status:     appsv1.DeploymentStatus{ReadyReplicas: 2, UpdatedReplicas: 3, AvailableReplicas: 2},
+	})
+	if d.Kind != WorkloadDeployment || d.Replicas != 3 || d.ReadyReplicas != 2 || !reflect.DeepEqual(d.Images, []string{"nginx:1.19", "envoy:1.16"}) {
+		t.Errorf("DeploymentSummary() = %+v", d)
+	}
+
+	ds := DaemonSetSummary("c1", &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "calico-node"},
+		Spec:       appsv1.DaemonSetSpec{Template: template},
+		Status:     appsv1.DaemonSetStatus{DesiredNumberScheduled: 5, NumberReady: 4, NumberAvailable: 4, UpdatedNumberScheduled: 5},
+	})
+	if ds.Kind != WorkloadDaemonSet || ds.Replicas != 5 || ds.ReadyReplicas != 4 || ds.UpdatedReplicas != 5 {
+		t.Errorf("DaemonSetSummary() = %+v", ds)
+	}
+
+	sts := StatefulSetSummary("c1", &appsv1.StatefulSet{Status: appsv1.StatefulSetStatus{ReadyReplicas: 1}})
+	if sts.Replicas != 0 || sts.AvailableReplicas != 1 {
+		t.Errorf("StatefulSetSummary() = %+v", sts)
+	}
+}
+
+func TestWorkloadQuery(t *testing.T) {
+	workloads := []*WorkloadSummary{
+		{Cluster: "c2", Namespace: "web", Kind: WorkloadDeployment, Name: "nginx", Images: []string{"nginx:1.19"}},
+		{Cluster: "c1", Namespace: "web", Kind: WorkloadDeployment, Name: "nginx", Images: []string{"nginx:1.18"}},
+		{Cluster: "c1", Namespace: "db", Kind: WorkloadStatefulSet, Name: "mysql", Images: []string{"mysql:5.7"}},
+		{Cluster: "c1", Namespace: "kube-system", Kind: WorkloadDaemonSet, Name: "calico-node", Images: []string{"calico/node:v3.16"}},
+	}
+
+	tests := []struct {
+		name    string
+		query   WorkloadQuery
+		want    []string
+		wantErr bool
+	}{
+		{name: "all", query: WorkloadQuery{}, want: []string{"c1/db/mysql", "c1/kube-system/calico-node", "c1/web/nginx", "c2/web/nginx"}},
+		{name: "kinds", query: WorkloadQuery{Kinds: []string{WorkloadStatefulSet, WorkloadDaemonSet}}, want: []string{"c1/db/mysql", "c1/kube-system/calico-node"}},
+		{name: "namespace", query: WorkloadQuery{Namespace: "web"}, want: []string{"c1/web/nginx", "c2/web/nginx"}},
+		{name: "name", query: WorkloadQuery{Name: "calico"}, want: []string{"c1/kube-system/calico-node"}},
+		{name: "image", query: WorkloadQuery{Image: "nginx:1.19"}, want: []string{"c2/web/nginx"}},
+		{name: "invalid kind", query: WorkloadQuery{Kinds: []string{"Pod"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.query.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := []string{}
+			for _, w := range tt.query.Apply(workloads) {
+				got = append(got, w.Cluster+"/"+w.Namespace+"/"+w.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
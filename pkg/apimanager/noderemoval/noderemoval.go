@@ -0,0 +1,355 @@
+// Package noderemoval decommissions the worker nodes of the clusters in the background: the node is cordoned
+// and drained, the Node and the Machine are deleted, and the rack address of the machine is released by Sync
+// once the machine controller has cleaned the Machine up, so it's never handed out while still in use.
+package noderemoval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/apimanager/rackpool"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/storage"
+	"github.com/gostship/kunkka/pkg/util/apiclient"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// Kind the storage kind of the removals, keyed by the cluster and the ip of the node
+	Kind = "noderemoval"
+	// DefaultDrainTimeout the default time the pods of the node have to be evicted
+	DefaultDrainTimeout = 5 * time.Minute
+	// MaxDrainTimeout the max drain timeout of a removal
+	MaxDrainTimeout = 30 * time.Minute
+
+	// the phases of the removal
+	PhaseDraining = "Draining"
+	PhaseDeleting = "Deleting"
+	PhaseReleased = "Released"
+	PhaseFailed   = "Failed"
+)
+
+var (
+	// ErrRemoving the node is being removed.
+	ErrRemoving = errors.New("the node is being removed")
+	// ErrMaster the node is a master of the cluster.
+	ErrMaster = errors.New("the master nodes can't be removed")
+	// ErrNoMachine the cluster has no machine of the ip.
+	ErrNoMachine = errors.New("the cluster has no machine of the ip")
+	// ErrClusterDeleting the cluster of the node is being deleted.
+	ErrClusterDeleting = errors.New("the cluster is being deleted")
+	// ErrUnreachable the cluster can't be reached to drain the node.
+	ErrUnreachable = errors.New("the cluster is unreachable, remove the node with force")
+)
+
+// Key returns the storage key of the removal.
+func Key(cluster, ip string) string {
+	return cluster + "/" + ip
+}
+
+// Finished returns whether the removal is done.
+func Finished(rm *model.NodeRemoval) bool {
+	return rm.Phase == PhaseReleased || rm.Phase == PhaseFailed
+}
+
+// Remover drains and deletes the nodes of the clusters, the removals are kept in the store.
+type Remover struct {
+	cli   client.Client
+	store storage.Store
+	// racks the ConfigMap of the racks the machines are allocated from
+	racks types.NamespacedName
+
+	mu sync.Mutex
+	// running the keys of the removals draining in this process
+	running map[string]bool
+}
+
+// NewRemover ...
+func NewRemover(cli client.Client, store storage.Store, racks types.NamespacedName) *Remover {
+	return &Remover{cli: cli, store: store, racks: racks, running: map[string]bool{}}
+}
+
+// Get returns the removal of the node of the cluster, storage.ErrNotFound if there's none.
+func (r *Remover) Get(ctx context.Context, cluster, ip string) (*model.NodeRemoval, error) {
+	return r.get(ctx, Key(cluster, ip))
+}
+
+func (r *Remover) get(ctx context.Context, key string) (*model.NodeRemoval, error) {
+	data, err := r.store.Get(ctx, Kind, key)
+	if err != nil {
+		return nil, err
+	}
+	rm := &model.NodeRemoval{}
+	if err := json.Unmarshal(data, rm); err != nil {
+		return nil, errors.Wrapf(err, "decode node removal: %s", key)
+	}
+	return rm, nil
+}
+
+func (r *Remover) save(ctx context.Context, rm *model.NodeRemoval) error {
+	data, err := json.Marshal(rm)
+	if err != nil {
+		return err
+	}
+	key := Key(rm.Cluster, rm.IP)
+	return errors.Wrapf(r.store.Put(ctx, Kind, key, data), "save node removal: %s", key)
+}
+
+// Start starts the removal of the worker node of the ip by the user. The node is drained through kube, the client
+// of the cluster, within the timeout; the force removal goes on when the drain fails or kube is nil, the cluster
+// being unreachable, and skips the cleanup of the node by the machine controller if it fails.
+func (r *Remover) Start(ctx context.Context, cluster, ip, user string, kube kubernetes.Interface, force bool, timeout time.Duration) (*model.NodeRemoval, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := Key(cluster, ip)
+	if r.running[key] {
+		return nil, ErrRemoving
+	}
+	old, err := r.get(ctx, key)
+	if err != nil && !storage.IsNotFound(err) {
+		return nil, err
+	}
+	if old != nil && old.Phase == PhaseDeleting {
+		return nil, ErrRemoving
+	}
+
+	c := &devopsv1.Cluster{}
+	err = r.cli.Get(ctx, types.NamespacedName{Namespace: cluster, Name: cluster}, c)
+	if err != nil {
+		return nil, err
+	}
+	if !c.DeletionTimestamp.IsZero() {
+		return nil, ErrClusterDeleting
+	}
+	for _, m := range c.Spec.Machines {
+		if m.IP == ip {
+			return nil, ErrMaster
+		}
+	}
+	machine, err := r.machine(ctx, cluster, ip)
+	if err != nil {
+		return nil, err
+	}
+	if !machine.DeletionTimestamp.IsZero() {
+		return nil, ErrRemoving
+	}
+	if kube == nil && !force {
+		return nil, ErrUnreachable
+	}
+
+	rm := &model.NodeRemoval{
+		Cluster:   cluster,
+		IP:        ip,
+		Requester: user,
+		Phase:     PhaseDraining,
+		Force:     force,
+		Evicted:   []string{},
+		Racks:     model.NewRackReleases([]*devopsv1.ClusterMachine{machine.Spec.Machine}),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := r.save(ctx, rm); err != nil {
+		return nil, err
+	}
+	snapshot := *rm
+
+	r.running[key] = true
+	go r.run(rm, machine, kube, timeout)
+	return &snapshot, nil
+}
+
+// machine returns the Machine of the ip in the cluster, ErrNoMachine if there's none.
+func (r *Remover) machine(ctx context.Context, cluster, ip string) (*devopsv1.Machine, error) {
+	machines := &devopsv1.MachineList{}
+	err := r.cli.List(ctx, machines, client.InNamespace(cluster))
+	if err != nil {
+		return nil, errors.Wrapf(err, "list machines of cluster: %s", cluster)
+	}
+	for i := range machines.Items {
+		m := &machines.Items[i]
+		if m.Spec.ClusterName == cluster && m.Spec.Machine != nil && m.Spec.Machine.IP == ip {
+			return m, nil
+		}
+	}
+	return nil, ErrNoMachine
+}
+
+func (r *Remover) run(rm *model.NodeRemoval, machine *devopsv1.Machine, kube kubernetes.Interface, timeout time.Duration) {
+	ctx := context.Background()
+	defer func() {
+		if err := r.save(ctx, rm); err != nil {
+			klog.Errorf("node: %s of cluster: %s removal error: %v", rm.IP, rm.Cluster, err)
+		}
+		klog.Infof("node: %s of cluster: %s removal %s: %s", rm.IP, rm.Cluster, rm.Phase, rm.Message)
+
+		r.mu.Lock()
+		delete(r.running, Key(rm.Cluster, rm.IP))
+		r.mu.Unlock()
+	}()
+	fail := func(err error) {
+		now := time.Now().UTC()
+		rm.Phase = PhaseFailed
+		rm.Message = err.Error()
+		rm.FinishedAt = &now
+	}
+
+	if kube == nil {
+		rm.Message = "the cluster is unreachable, the drain is skipped"
+	} else if err := r.drain(ctx, rm, kube, timeout); err != nil {
+		if !rm.Force {
+			fail(errors.Wrapf(err, "the node is left cordoned"))
+			return
+		}
+		rm.Message = fmt.Sprintf("the drain is skipped by force: %v", err)
+	}
+
+	if rm.Force {
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			m := &devopsv1.Machine{}
+			if err := r.cli.Get(ctx, types.NamespacedName{Namespace: machine.Namespace, Name: machine.Name}, m); err != nil {
+				return err
+			}
+			if m.Annotations == nil {
+				m.Annotations = map[string]string{}
+			}
+			m.Annotations[constants.ForceDeletion] = "true"
+			return r.cli.Update(ctx, m)
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			fail(errors.Wrapf(err, "annotate machine: %s with force deletion", machine.Name))
+			return
+		}
+	}
+	err := r.cli.Delete(ctx, machine, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	if err != nil && !apierrors.IsNotFound(err) {
+		fail(errors.Wrapf(err, "delete machine: %s", machine.Name))
+		return
+	}
+
+	now := time.Now().UTC()
+	rm.Phase = PhaseDeleting
+	rm.DeletedAt = &now
+}
+
+// drain cordons and drains the node of the removal and deletes the Node, there's nothing to drain if the node
+// never joined the cluster.
+func (r *Remover) drain(ctx context.Context, rm *model.NodeRemoval, kube kubernetes.Interface, timeout time.Duration) error {
+	nodes, err := kube.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "list nodes")
+	}
+	var node *corev1.Node
+	for i := range nodes.Items {
+		if nodeOf(&nodes.Items[i], rm.IP) {
+			node = &nodes.Items[i]
+			break
+		}
+	}
+	if node == nil {
+		return nil
+	}
+
+	if err := apiclient.CordonNode(ctx, kube, node.Name); err != nil {
+		return err
+	}
+	rm.Evicted, err = apiclient.DrainNode(ctx, kube, node.Name, timeout)
+	if err != nil {
+		if e, ok := err.(*apiclient.DrainTimeoutError); ok {
+			rm.Remaining = e.Pods
+		}
+		return err
+	}
+	err = kube.CoreV1().Nodes().Delete(ctx, node.Name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "delete node: %s", node.Name)
+	}
+	return nil
+}
+
+// nodeOf returns whether the node is named after or addressed by the ip.
+func nodeOf(n *corev1.Node, ip string) bool {
+	if n.Name == ip {
+		return true
+	}
+	for _, addr := range n.Status.Addresses {
+		if addr.Type == corev1.NodeInternalIP && addr.Address == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// Sync fails the removals interrupted by a restart while draining and releases the rack addresses of the
+// removed nodes once their Machines are gone.
+func (r *Remover) Sync(ctx context.Context) error {
+	keys, err := r.store.List(ctx, Kind, "")
+	if err != nil {
+		return errors.Wrapf(err, "list node removals")
+	}
+	sort.Strings(keys)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range keys {
+		rm, err := r.get(ctx, key)
+		if err != nil {
+			if !storage.IsNotFound(err) {
+				klog.Errorf("get node removal: %s error: %v", key, err)
+			}
+			continue
+		}
+
+		switch rm.Phase {
+		case PhaseDraining:
+			if r.running[key] {
+				continue
+			}
+			now := time.Now().UTC()
+			rm.Phase = PhaseFailed
+			rm.Message = "interrupted by the restart of the api server, the node may be left cordoned, remove it again"
+			rm.FinishedAt = &now
+			if err := r.save(ctx, rm); err != nil {
+				klog.Errorf("fail interrupted node removal: %s error: %v", key, err)
+			}
+		case PhaseDeleting:
+			if err := r.release(ctx, rm); err != nil {
+				klog.Errorf("release rack address of removed node: %s error: %v", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// release releases the rack address of the removal once the Machine is cleaned up.
+func (r *Remover) release(ctx context.Context, rm *model.NodeRemoval) error {
+	if _, err := r.machine(ctx, rm.Cluster, rm.IP); err != ErrNoMachine {
+		return err
+	}
+
+	released, err := rackpool.Release(ctx, r.cli, r.racks, rm.Racks)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	now := time.Now().UTC()
+	rm.Phase = PhaseReleased
+	rm.FinishedAt = &now
+	if apierrors.IsNotFound(err) {
+		rm.Message = "no rack cfg, nothing released"
+	}
+	klog.Infof("node: %s of cluster: %s removed, released %d rack addresses", rm.IP, rm.Cluster, released)
+	return r.save(ctx, rm)
+}
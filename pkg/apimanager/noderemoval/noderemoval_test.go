@@ -0,0 +1,183 @@
+package noderemoval
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/apimanager/rackpool"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/storage"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const rackCfg = `
+- rackTag: r1
+  hostAddr:
+  - ipAddr: 10.0.0.1
+    useState: 1
+    isMeta: 0
+  - ipAddr: 10.0.0.2
+    useState: 1
+    isMeta: 0
+  podCidr:
+  - id: cidr-1
+    useState: 1
+  - id: cidr-2
+    useState: 1
+`
+
+func newMachine(ip, cidr string) *devopsv1.Machine {
+	return &devopsv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "c1", Name: ip},
+		Spec: devopsv1.MachineSpec{ClusterName: "c1", Machine: &devopsv1.ClusterMachine{
+			IP: ip, HostCni: &devopsv1.ClusterCni{ID: cidr, RackTag: "r1"},
+		}},
+	}
+}
+
+// newKube returns the client of the member cluster running a pod on the node, the eviction of the pod is
+// refused if blocked.
+func newKube(blocked bool) *kubefake.Clientset {
+	kube := kubefake.NewSimpleClientset(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "10.0.0.1"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", UID: "uid-web"}, Spec: corev1.PodSpec{NodeName: "10.0.0.1"}},
+	)
+	kube.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		if blocked {
+			return true, nil, apierrors.NewTooManyRequests("disruption budget", 1)
+		}
+		gvr := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+		return true, nil, kube.Tracker().Delete(gvr, action.GetNamespace(), "web")
+	})
+	return kube
+}
+
+func wait(t *testing.T, r *Remover, ip string) *model.NodeRemoval {
+	for i := 0; i < 100; i++ {
+		rm, err := r.Get(context.Background(), "c1", ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rm.Phase != PhaseDraining {
+			return rm
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("removal of %s not done", ip)
+	return nil
+}
+
+func TestRemover(t *testing.T) {
+	cluster := &devopsv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "c1", Name: "c1"},
+		Spec:       devopsv1.ClusterSpec{Machines: []*devopsv1.ClusterMachine{{IP: "10.0.0.9"}}},
+	}
+	racks := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kunkka-api", Name: "kunkka-api"},
+		Data:       map[string]string{rackpool.DataKey: rackCfg},
+	}
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	devopsv1.AddToScheme(scheme)
+	cli := fake.NewFakeClientWithScheme(scheme, cluster, racks, newMachine("10.0.0.1", "cidr-1"), newMachine("10.0.0.2", "cidr-2"))
+	r := NewRemover(cli, storage.NewConfigMapStore(cli, "kunkka-storage"), types.NamespacedName{Namespace: "kunkka-api", Name: "kunkka-api"})
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		ip   string
+		want error
+	}{
+		{name: "master", ip: "10.0.0.9", want: ErrMaster},
+		{name: "no machine", ip: "10.0.0.3", want: ErrNoMachine},
+		{name: "unreachable", ip: "10.0.0.1", want: ErrUnreachable},
+	}
+	for _, tt := range tests {
+		if _, err := r.Start(ctx, "c1", tt.ip, "admin", nil, false, time.Second); err != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// the pods refusing the eviction fail the removal, the node is kept cordoned
+	kube := newKube(true)
+	if _, err := r.Start(ctx, "c1", "10.0.0.1", "admin", kube, false, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	rm := wait(t, r, "10.0.0.1")
+	if rm.Phase != PhaseFailed || len(rm.Remaining) != 1 || rm.Remaining[0] != "default/web" {
+		t.Fatalf("got blocked removal %+v", rm)
+	}
+	node, err := kube.CoreV1().Nodes().Get(ctx, "10.0.0.1", metav1.GetOptions{})
+	if err != nil || !node.Spec.Unschedulable {
+		t.Fatalf("node not kept cordoned: %v", err)
+	}
+
+	kube = newKube(false)
+	rm, err = r.Start(ctx, "c1", "10.0.0.1", "admin", kube, false, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rm.Phase != PhaseDraining {
+		t.Fatalf("got started removal %+v", rm)
+	}
+	rm = wait(t, r, "10.0.0.1")
+	if rm.Phase != PhaseDeleting || len(rm.Evicted) != 1 || rm.DeletedAt == nil {
+		t.Fatalf("got drained removal %+v", rm)
+	}
+	if _, err := kube.CoreV1().Nodes().Get(ctx, "10.0.0.1", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("node not deleted: %v", err)
+	}
+	if _, err := r.Start(ctx, "c1", "10.0.0.1", "admin", kube, false, time.Second); err != ErrRemoving {
+		t.Errorf("remove twice: got %v, want %v", err, ErrRemoving)
+	}
+
+	// the unreachable node is removed by force, its machine skips the cleanup of the node
+	if _, err := r.Start(ctx, "c1", "10.0.0.2", "admin", nil, true, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if rm := wait(t, r, "10.0.0.2"); rm.Phase != PhaseDeleting || !rm.Force {
+		t.Fatalf("got forced removal %+v", rm)
+	}
+
+	if err := r.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		if rm, _ := r.Get(ctx, "c1", ip); rm.Phase != PhaseReleased || rm.FinishedAt == nil {
+			t.Errorf("%s not released: %+v", ip, rm)
+		}
+	}
+	cm := &corev1.ConfigMap{}
+	cli.Get(ctx, types.NamespacedName{Namespace: "kunkka-api", Name: "kunkka-api"}, cm)
+	list, err := rackpool.Parse(cm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := []string{}
+	for _, h := range list[0].HostAddr {
+		state = append(state, fmt.Sprintf("%s=%d", h.IPADDR, h.UseState))
+	}
+	for _, c := range list[0].PodCidr {
+		state = append(state, fmt.Sprintf("%s=%d", c.ID, c.UseState))
+	}
+	want := "10.0.0.1=0,10.0.0.2=0,cidr-1=0,cidr-2=0"
+	if strings.Join(state, ",") != want {
+		t.Errorf("got racks %s, want %s", strings.Join(state, ","), want)
+	}
+}
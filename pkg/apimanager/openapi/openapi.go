@@ -0,0 +1,211 @@
+// Package openapi generates the OpenAPI v3 document of the routes of the api manager from the routes
+// and the go types of their request bodies and response items, wrapped in the responseutil envelope.
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/gostship/kunkka/pkg/apimanager/router"
+)
+
+// Version the OpenAPI version of the document
+const Version = "3.0.3"
+
+const (
+	successSchema = "responseutil.Success"
+	errorSchema   = "responseutil.Error"
+	bearerScheme  = "bearerAuth"
+)
+
+// anyMethods the methods documented for the "Any" routes
+var anyMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// Document is the OpenAPI v3 document.
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+	Security   []map[string][]string            `json:"security"`
+}
+
+// Info ...
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Components ...
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme ...
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+}
+
+// Operation ...
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Deprecated  bool                 `json:"deprecated,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter ...
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody ...
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response ...
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType ...
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Build returns the document of the routes, the paths of the routes are converted from the gin
+// syntax, e.g. /klusters/:name/proxy/*path to /klusters/{name}/proxy/{path}.
+func Build(info Info, routes []*router.Route) *Document {
+	s := newSchemas()
+	success := s.add(successSchema, &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"success":     {Type: "boolean"},
+			"message":     {Type: "string"},
+			"items":       {},
+			"total_count": {Type: "integer", Format: "int32"},
+		},
+	})
+	failure := s.add(errorSchema, &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"success": {Type: "boolean"},
+			"code":    {Type: "integer", Format: "int32", Description: "the code of the error"},
+			"reason":  {Type: "string", Description: "the typed reason of the http status of the error"},
+			"message": {Type: "string", Description: "the message of the code"},
+			"detail":  {Type: "string"},
+			"details": {Description: "the object of the error, e.g. the free machines of the racks, absent for most errors"},
+			"data":    {Nullable: true},
+		},
+	})
+	errorResponse := func(desc string) *Response {
+		return &Response{Description: desc, Content: jsonContent(failure)}
+	}
+
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]map[string]*Operation{},
+		Components: Components{
+			Schemas: s.components,
+			SecuritySchemes: map[string]*SecurityScheme{
+				bearerScheme: {Type: "http", Scheme: "bearer"},
+			},
+		},
+		Security: []map[string][]string{{bearerScheme: {}}},
+	}
+
+	ids := map[string]bool{}
+	for _, r := range routes {
+		methods := []string{r.Method}
+		if r.Method == "Any" {
+			methods = anyMethods
+		}
+		p, params := convertPath(r.Path)
+		for _, method := range methods {
+			id := operationID(r, method)
+			if ids[id] {
+				// the handlers of many routes
+				id += strings.NewReplacer("/", "_", ":", "", "*", "").Replace(r.Path)
+			}
+			ids[id] = true
+			op := &Operation{
+				OperationID: id,
+				Summary:     r.Desc,
+				Deprecated:  r.Deprecated,
+				Parameters:  params,
+				Responses: map[string]*Response{
+					"400": errorResponse("invalid request"),
+					"401": errorResponse("unauthenticated"),
+					"403": errorResponse("forbidden"),
+				},
+			}
+			op.Responses["200"] = &Response{Description: "success", Content: jsonContent(success)}
+			if items := s.of(r.Response); items != nil {
+				op.Responses["200"].Content = jsonContent(&Schema{
+					AllOf: []*Schema{success, {Type: "object", Properties: map[string]*Schema{"items": items}}},
+				})
+			}
+			if body := s.of(r.Request); body != nil && method != http.MethodGet {
+				op.RequestBody = &RequestBody{Required: true, Content: jsonContent(body)}
+			}
+			if len(params) > 0 {
+				op.Responses["404"] = errorResponse("not found")
+			}
+
+			if doc.Paths[p] == nil {
+				doc.Paths[p] = map[string]*Operation{}
+			}
+			doc.Paths[p][strings.ToLower(method)] = op
+		}
+	}
+	return doc
+}
+
+func jsonContent(schema *Schema) map[string]*MediaType {
+	return map[string]*MediaType{"application/json": {Schema: schema}}
+}
+
+// convertPath returns the OpenAPI path of the gin path and its parameters in order.
+func convertPath(p string) (string, []*Parameter) {
+	var params []*Parameter
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			name := seg[1:]
+			segments[i] = "{" + name + "}"
+			params = append(params, &Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID is the name of the handler of the route, suffixed by the method for the "Any" routes,
+// e.g. getNodeInventory of (*Manager).getNodeInventory-fm.
+func operationID(r *router.Route, method string) string {
+	id := strings.ToLower(method) + strings.NewReplacer("/", "_", ":", "", "*", "").Replace(r.Path)
+	if r.Handler != nil {
+		name := runtime.FuncForPC(reflect.ValueOf(r.Handler).Pointer()).Name()
+		name = strings.TrimSuffix(name[strings.LastIndex(name, ".")+1:], "-fm")
+		if name != "" && !strings.HasPrefix(name, "func") {
+			id = name
+		}
+	}
+	if r.Method == "Any" {
+		id += "_" + strings.ToLower(method)
+	}
+	return id
+}
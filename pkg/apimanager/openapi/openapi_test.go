@@ -0,0 +1,118 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/router"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type tree struct {
+	Name     string  `json:"name"`
+	Children []*tree `json:"children,omitempty"`
+}
+
+type request struct {
+	metav1.TypeMeta `json:",inline"`
+	Name            string            `json:"name"`
+	Labels          map[string]string `json:"labels"`
+	Secret          string            `json:"-"`
+	Created         time.Time         `json:"created"`
+	Data            []byte            `json:"data"`
+	Node            *corev1.Node      `json:"node"`
+	Tree            tree              `json:"tree"`
+	unexported      string
+}
+
+func handler(c *gin.Context) {}
+
+func TestBuild(t *testing.T) {
+	routes := []*router.Route{
+		{Method: "POST", Path: "/apis/cluster/klusters/:name/things", Handler: handler, Request: &request{}, Response: []*tree{}, Desc: "create things"},
+		{Method: "GET", Path: "/apis/cluster/klusters/:name/things", Handler: handler},
+		{Method: "Any", Path: "/apis/cluster/klusters/:name/proxy/*path"},
+	}
+	doc := Build(Info{Title: "test", Version: "v1"}, routes)
+
+	if _, err := json.Marshal(doc); err != nil {
+		t.Fatalf("marshal document: %v", err)
+	}
+	if doc.OpenAPI != Version || doc.Components.SecuritySchemes[bearerScheme] == nil {
+		t.Errorf("Build() = %s %v", doc.OpenAPI, doc.Components.SecuritySchemes)
+	}
+
+	things := doc.Paths["/apis/cluster/klusters/{name}/things"]
+	if things == nil || things["post"] == nil || things["get"] == nil {
+		t.Fatalf("Build() paths = %v", doc.Paths)
+	}
+	post := things["post"]
+	if post.Summary != "create things" || len(post.Parameters) != 1 || post.Parameters[0].Name != "name" || post.Responses["404"] == nil {
+		t.Errorf("post operation = %+v", post)
+	}
+	if post.OperationID != "handler" || things["get"].OperationID == post.OperationID {
+		t.Errorf("operation ids: %s, %s", post.OperationID, things["get"].OperationID)
+	}
+	if ref := post.RequestBody.Content["application/json"].Schema.Ref; ref != "#/components/schemas/apimanager.openapi.request" {
+		t.Errorf("request body ref = %s", ref)
+	}
+	items := post.Responses["200"].Content["application/json"].Schema.AllOf[1].Properties["items"]
+	if items.Type != "array" || items.Items.Ref != "#/components/schemas/apimanager.openapi.tree" {
+		t.Errorf("response items = %+v", items)
+	}
+	if things["get"].RequestBody != nil {
+		t.Errorf("get operation has a request body")
+	}
+
+	proxy := doc.Paths["/apis/cluster/klusters/{name}/proxy/{path}"]
+	if len(proxy) != len(anyMethods) || len(proxy["patch"].Parameters) != 2 {
+		t.Errorf("Any route = %v", proxy)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	s := newSchemas()
+	s.of(&request{})
+
+	req := s.components["apimanager.openapi.request"]
+	if req == nil {
+		t.Fatalf("components = %v", s.components)
+	}
+	props := []string{}
+	for name := range req.Properties {
+		props = append(props, name)
+	}
+	want := map[string]bool{"kind": true, "apiVersion": true, "name": true, "labels": true, "created": true, "data": true, "node": true, "tree": true}
+	if len(props) != len(want) {
+		t.Errorf("properties = %v", props)
+	}
+	for _, p := range props {
+		if !want[p] {
+			t.Errorf("unexpected property: %s", p)
+		}
+	}
+
+	if got := req.Properties["created"]; got.Format != "date-time" {
+		t.Errorf("time schema = %+v", got)
+	}
+	if got := req.Properties["data"]; got.Format != "byte" {
+		t.Errorf("bytes schema = %+v", got)
+	}
+	if got := req.Properties["labels"]; got.AdditionalProperties == nil || got.AdditionalProperties.Type != "string" {
+		t.Errorf("map schema = %+v", got)
+	}
+	if got := req.Properties["node"]; got.Ref != "#/components/schemas/core.v1.Node" {
+		t.Errorf("k8s schema = %+v", got)
+	}
+	if q := s.components["resource.Quantity"]; q != nil {
+		t.Errorf("quantity is a component: %+v", q)
+	}
+	tree := s.components["apimanager.openapi.tree"]
+	if !reflect.DeepEqual(tree.Properties["children"].Items, &Schema{Ref: "#/components/schemas/apimanager.openapi.tree"}) {
+		t.Errorf("recursive schema = %+v", tree.Properties["children"])
+	}
+}
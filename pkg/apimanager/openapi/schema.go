@@ -0,0 +1,179 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Schema is the subset of the OpenAPI v3 schema object describing the json of the go types.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+}
+
+var (
+	jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+	// knownSchemas the types marshaled by themselves
+	knownSchemas = map[reflect.Type]*Schema{
+		reflect.TypeOf(time.Time{}):                {Type: "string", Format: "date-time"},
+		reflect.TypeOf(metav1.Time{}):              {Type: "string", Format: "date-time"},
+		reflect.TypeOf(metav1.MicroTime{}):         {Type: "string", Format: "date-time"},
+		reflect.TypeOf(metav1.Duration{}):          {Type: "string", Description: "go duration, e.g. 1m30s"},
+		reflect.TypeOf(time.Duration(0)):           {Type: "integer", Format: "int64", Description: "nanoseconds"},
+		reflect.TypeOf(resource.Quantity{}):        {Type: "string", Description: "kubernetes quantity, e.g. 500m or 1Gi"},
+		reflect.TypeOf(intstr.IntOrString{}):       {OneOf: []*Schema{{Type: "integer"}, {Type: "string"}}},
+		reflect.TypeOf(runtime.RawExtension{}):     {Type: "object"},
+		reflect.TypeOf(json.RawMessage{}):          {},
+		reflect.TypeOf(metav1.FieldsV1{}):          {Type: "object"},
+		reflect.TypeOf(runtime.Unknown{}):          {Type: "object"},
+		reflect.TypeOf((*interface{})(nil)).Elem(): {},
+	}
+)
+
+// schemas builds the schemas of the go types, the named structs are kept as the components referenced by $ref.
+type schemas struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{
+		components: map[string]*Schema{},
+		names:      map[reflect.Type]string{},
+	}
+}
+
+// add registers a component of the fixed schema.
+func (s *schemas) add(name string, schema *Schema) *Schema {
+	s.components[name] = schema
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// of returns the schema of the go value, nil for nil.
+func (s *schemas) of(v interface{}) *Schema {
+	if v == nil {
+		return nil
+	}
+	return s.schema(reflect.TypeOf(v))
+}
+
+func (s *schemas) schema(t reflect.Type) *Schema {
+	if known, ok := knownSchemas[t]; ok {
+		copied := *known
+		return &copied
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := s.schema(t.Elem())
+		if schema.Ref != "" {
+			return schema
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		if t.Implements(jsonMarshaler) || reflect.PtrTo(t).Implements(jsonMarshaler) {
+			// the json of the custom marshalers is unknown
+			return &Schema{}
+		}
+		return s.named(t)
+	default:
+		// the funcs and the chans are never marshaled
+		return &Schema{}
+	}
+}
+
+// named returns the reference to the component of the struct, the component is built once.
+func (s *schemas) named(t reflect.Type) *Schema {
+	name, ok := s.names[t]
+	if !ok {
+		name = componentName(t)
+		s.names[t] = name
+		// registered before its fields, so the recursive types refer to themselves
+		s.components[name] = &Schema{}
+		s.components[name] = s.object(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (s *schemas) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	s.fields(t, schema)
+	return schema
+}
+
+// fields adds the json fields of the struct to the schema, the embedded structs without a json name are inlined.
+func (s *schemas) fields(t reflect.Type, schema *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		inline := strings.Contains(tag, ",inline")
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && (name == "" || inline) && ft.Kind() == reflect.Struct {
+			s.fields(ft, schema)
+			continue
+		}
+		if f.PkgPath != "" {
+			// unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		schema.Properties[name] = s.schema(f.Type)
+	}
+}
+
+// componentName is the type name qualified by the last two elements of its package path,
+// e.g. devops.v1.Cluster of github.com/gostship/kunkka/pkg/apis/devops/v1.
+func componentName(t reflect.Type) string {
+	elems := strings.Split(t.PkgPath(), "/")
+	if len(elems) > 2 {
+		elems = elems[len(elems)-2:]
+	}
+	return strings.Join(append(elems, t.Name()), ".")
+}
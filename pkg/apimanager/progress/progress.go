@@ -0,0 +1,185 @@
+// Package progress broadcasts the changes of the phases and the conditions of the Clusters and their Machines
+// watched by the informers of the meta cluster to the subscribers of each cluster, e.g. the provisioning
+// progress streamed to the console.
+package progress
+
+import (
+	"reflect"
+	"sync"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+const (
+	KindCluster = "Cluster"
+	KindMachine = "Machine"
+
+	TypeAdded    = "ADDED"
+	TypeModified = "MODIFIED"
+	TypeDeleted  = "DELETED"
+)
+
+// DefaultBuffer the default number of events a subscriber may fall behind before it is dropped
+const DefaultBuffer = 64
+
+// Event is the phase and the conditions of a Cluster or a Machine after a change.
+type Event struct {
+	Type       string      `json:"type"`
+	Kind       string      `json:"kind"`
+	Cluster    string      `json:"cluster"`
+	Name       string      `json:"name"`
+	Phase      string      `json:"phase"`
+	Reason     string      `json:"reason,omitempty"`
+	Message    string      `json:"message,omitempty"`
+	Conditions interface{} `json:"conditions,omitempty"`
+}
+
+// ClusterEvent ...
+func ClusterEvent(typ string, c *devopsv1.Cluster) Event {
+	return Event{
+		Type:       typ,
+		Kind:       KindCluster,
+		Cluster:    c.Name,
+		Name:       c.Name,
+		Phase:      string(c.Status.Phase),
+		Reason:     c.Status.Reason,
+		Message:    c.Status.Message,
+		Conditions: c.Status.Conditions,
+	}
+}
+
+// MachineEvent ...
+func MachineEvent(typ string, m *devopsv1.Machine) Event {
+	return Event{
+		Type:       typ,
+		Kind:       KindMachine,
+		Cluster:    m.Spec.ClusterName,
+		Name:       m.Name,
+		Phase:      string(m.Status.Phase),
+		Reason:     m.Status.Reason,
+		Message:    m.Status.Message,
+		Conditions: m.Status.Conditions,
+	}
+}
+
+type subscriber struct {
+	cluster string
+	ch      chan Event
+}
+
+// Hub is the informer event handler of the Clusters and the Machines, the subscribers falling behind
+// by more than their buffer are dropped, i.e. their channels are closed, and resubscribe from a snapshot.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[*subscriber]struct{}
+}
+
+var _ toolscache.ResourceEventHandler = &Hub{}
+
+// NewHub ...
+func NewHub() *Hub {
+	return &Hub{subs: make(map[*subscriber]struct{})}
+}
+
+// Subscribe returns the events of the cluster and the function to unsubscribe.
+func (h *Hub) Subscribe(cluster string, buffer int) (<-chan Event, func()) {
+	if buffer < 1 {
+		buffer = DefaultBuffer
+	}
+	s := &subscriber{cluster: cluster, ch: make(chan Event, buffer)}
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+
+	return s.ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.drop(s)
+	}
+}
+
+// Publish sends the event to the subscribers of its cluster without blocking.
+func (h *Hub) Publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		if s.cluster != e.Cluster {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			h.drop(s)
+		}
+	}
+}
+
+func (h *Hub) drop(s *subscriber) {
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.ch)
+	}
+}
+
+// OnAdd ...
+func (h *Hub) OnAdd(obj interface{}) {
+	if e, ok := event(TypeAdded, obj); ok {
+		h.Publish(e)
+	}
+}
+
+// OnUpdate publishes the changes of the phases, the reasons and the conditions only,
+// not the resyncs nor the probes which only bump the lastProbeTime.
+func (h *Hub) OnUpdate(oldObj, newObj interface{}) {
+	old, ok := event(TypeModified, oldObj)
+	if !ok {
+		return
+	}
+	e, ok := event(TypeModified, newObj)
+	if ok && !reflect.DeepEqual(withoutProbeTime(old), withoutProbeTime(e)) {
+		h.Publish(e)
+	}
+}
+
+// OnDelete ...
+func (h *Hub) OnDelete(obj interface{}) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if e, ok := event(TypeDeleted, obj); ok {
+		h.Publish(e)
+	}
+}
+
+func event(typ string, obj interface{}) (Event, bool) {
+	switch o := obj.(type) {
+	case *devopsv1.Cluster:
+		return ClusterEvent(typ, o), true
+	case *devopsv1.Machine:
+		return MachineEvent(typ, o), true
+	default:
+		return Event{}, false
+	}
+}
+
+func withoutProbeTime(e Event) Event {
+	switch conds := e.Conditions.(type) {
+	case []devopsv1.ClusterCondition:
+		stripped := make([]devopsv1.ClusterCondition, len(conds))
+		for i := range conds {
+			stripped[i] = conds[i]
+			stripped[i].LastProbeTime = metav1.Time{}
+		}
+		e.Conditions = stripped
+	case []devopsv1.MachineCondition:
+		stripped := make([]devopsv1.MachineCondition, len(conds))
+		for i := range conds {
+			stripped[i] = conds[i]
+			stripped[i].LastProbeTime = metav1.Time{}
+		}
+		e.Conditions = stripped
+	}
+	return e
+}
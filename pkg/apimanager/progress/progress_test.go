@@ -0,0 +1,99 @@
+package progress
+
+import (
+	"testing"
+	"time"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+func newCluster(name string, phase devopsv1.ClusterPhase, probe time.Time) *devopsv1.Cluster {
+	c := &devopsv1.Cluster{}
+	c.Name = name
+	c.Status.Phase = phase
+	c.Status.Conditions = []devopsv1.ClusterCondition{{Type: "EnsureSystem", Status: "True", LastProbeTime: metav1.NewTime(probe)}}
+	return c
+}
+
+func TestHub(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		publish func(h *Hub)
+		want    []string
+	}{
+		{
+			name: "added and deleted",
+			publish: func(h *Hub) {
+				h.OnAdd(newCluster("c1", devopsv1.ClusterInitializing, now))
+				h.OnDelete(toolscache.DeletedFinalStateUnknown{Obj: newCluster("c1", devopsv1.ClusterTerminating, now)})
+			},
+			want: []string{"ADDED Cluster c1 Initializing", "DELETED Cluster c1 Terminating"},
+		},
+		{
+			name: "probe only",
+			publish: func(h *Hub) {
+				h.OnUpdate(newCluster("c1", devopsv1.ClusterRunning, now), newCluster("c1", devopsv1.ClusterRunning, now.Add(time.Minute)))
+			},
+		},
+		{
+			name: "phase changed",
+			publish: func(h *Hub) {
+				h.OnUpdate(newCluster("c1", devopsv1.ClusterInitializing, now), newCluster("c1", devopsv1.ClusterRunning, now))
+			},
+			want: []string{"MODIFIED Cluster c1 Running"},
+		},
+		{
+			name: "other cluster and machine",
+			publish: func(h *Hub) {
+				h.OnAdd(newCluster("c2", devopsv1.ClusterRunning, now))
+				m := &devopsv1.Machine{}
+				m.Name = "m1"
+				m.Spec.ClusterName = "c1"
+				m.Status.Phase = devopsv1.MachineInitializing
+				h.OnAdd(m)
+			},
+			want: []string{"ADDED Machine m1 Initializing"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHub()
+			events, unsubscribe := h.Subscribe("c1", 0)
+			defer unsubscribe()
+			tt.publish(h)
+
+			var got []string
+			for len(events) > 0 {
+				e := <-events
+				got = append(got, e.Type+" "+e.Kind+" "+e.Name+" "+e.Phase)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	h := NewHub()
+	events, unsubscribe := h.Subscribe("c1", 1)
+	h.OnAdd(newCluster("c1", devopsv1.ClusterInitializing, time.Now()))
+	h.OnAdd(newCluster("c1", devopsv1.ClusterRunning, time.Now()))
+
+	if e, ok := <-events; !ok || e.Phase != string(devopsv1.ClusterInitializing) {
+		t.Fatalf("first event = %v, %v", e, ok)
+	}
+	if _, ok := <-events; ok {
+		t.Fatal("slow subscriber not dropped")
+	}
+	// unsubscribing a dropped subscriber is a no-op
+	unsubscribe()
+}
@@ -0,0 +1,71 @@
+// Package rackpool gives the machines and the pod cidrs of the racks, kept in the rack ConfigMap, back to the
+// pool once the machines are cleaned up.
+package rackpool
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ghodss/yaml"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DataKey the key of the racks in the rack ConfigMap
+const DataKey = "List"
+
+// Release marks the releases unused in the racks of the ConfigMap, retried on the conflicts of the concurrent
+// updates, and returns the number of the releases newly released. The releases are marked released only
+// when the racks are updated.
+func Release(ctx context.Context, cli client.Client, key types.NamespacedName, releases []*model.RackRelease) (int, error) {
+	released := 0
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm := &corev1.ConfigMap{}
+		if err := cli.Get(ctx, key, cm); err != nil {
+			return err
+		}
+		racks, err := Parse(cm)
+		if err != nil {
+			return err
+		}
+
+		copies := make([]*model.RackRelease, 0, len(releases))
+		for _, r := range releases {
+			copied := *r
+			copies = append(copies, &copied)
+		}
+		released = model.ReleaseRacks(racks, copies)
+		if released > 0 {
+			list, err := json.MarshalIndent(racks, "", "  ")
+			if err != nil {
+				return err
+			}
+			cm.Data[DataKey] = string(list)
+			if err := cli.Update(ctx, cm); err != nil {
+				return err
+			}
+		}
+		for i := range releases {
+			releases[i].Released = copies[i].Released
+		}
+		return nil
+	})
+	return released, err
+}
+
+// Parse returns the racks of the rack ConfigMap.
+func Parse(cm *corev1.ConfigMap) ([]*model.Rack, error) {
+	racks := []*model.Rack{}
+	data, err := yaml.YAMLToJSON([]byte(cm.Data[DataKey]))
+	if err != nil {
+		return nil, errors.Wrapf(err, "rack cfg yaml to json")
+	}
+	if err := json.Unmarshal(data, &racks); err != nil {
+		return nil, errors.Wrapf(err, "unmarshal rack cfg")
+	}
+	return racks, nil
+}
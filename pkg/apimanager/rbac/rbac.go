@@ -0,0 +1,189 @@
+// Package rbac authorizes the apimanager requests by the roles bound to the users and groups
+// per cluster or tenant, the bindings are registered by the platform admins in a ConfigMap.
+package rbac
+
+import (
+	"fmt"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// Role the permission of a binding, each role includes the ones below it.
+type Role string
+
+const (
+	// RoleViewer reads the clusters
+	RoleViewer Role = "viewer"
+	// RoleOperator operates the nodes and workloads of the clusters, e.g. adds nodes, opens terminals
+	RoleOperator Role = "operator"
+	// RoleAdmin creates the clusters, issues their admin credentials and manages the platform config
+	RoleAdmin Role = "admin"
+
+	// AllClusters the cluster of the bindings on all the clusters, only they grant the platform wide permissions
+	AllClusters = "*"
+
+	// DataKey the key of the bindings in the ConfigMap
+	DataKey = "bindings"
+)
+
+var ranks = map[Role]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// Valid returns whether the role is one of the supported roles.
+func (r Role) Valid() bool {
+	_, ok := ranks[r]
+	return ok
+}
+
+// Includes returns whether the role has the permission of the other.
+func (r Role) Includes(other Role) bool {
+	return ranks[r] >= ranks[other]
+}
+
+// Binding grants the role on the clusters and the clusters of the tenants to the users and groups.
+type Binding struct {
+	Name   string   `json:"name"`
+	Role   Role     `json:"role"`
+	Users  []string `json:"users,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+	// Clusters the clusters by ClusterName, or AllClusters
+	Clusters []string `json:"clusters,omitempty"`
+	Tenants  []string `json:"tenants,omitempty"`
+}
+
+// Subject the user and groups of the request.
+type Subject struct {
+	User   string
+	Groups []string
+}
+
+// Resource the cluster the request operates on, empty Cluster for the platform wide requests.
+type Resource struct {
+	Cluster string
+	Tenant  string
+}
+
+// ClusterName returns the cluster of the bindings of the Cluster, it's the name of the Clusters in the
+// namespaces of their names, "<namespace>/<name>" of the others.
+func ClusterName(namespace, name string) string {
+	if namespace == name {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+// Parse decodes the bindings from the yaml of the ConfigMap.
+func Parse(data string) ([]*Binding, error) {
+	bindings := []*Binding{}
+	err := yaml.Unmarshal([]byte(data), &bindings)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unmarshal role bindings")
+	}
+
+	names := map[string]bool{}
+	for _, b := range bindings {
+		if b.Name == "" || names[b.Name] {
+			return nil, errors.Errorf("role binding name: %q is empty or duplicated", b.Name)
+		}
+		names[b.Name] = true
+
+		if !b.Role.Valid() {
+			return nil, errors.Errorf("role binding: %s unsupported role: %q", b.Name, b.Role)
+		}
+		if len(b.Users) == 0 && len(b.Groups) == 0 {
+			return nil, errors.Errorf("role binding: %s has no users or groups", b.Name)
+		}
+		if len(b.Clusters) == 0 && len(b.Tenants) == 0 {
+			return nil, errors.Errorf("role binding: %s has no clusters or tenants", b.Name)
+		}
+	}
+	return bindings, nil
+}
+
+// Authorize returns nil if any of the bindings grants the role on the resource to the subject,
+// the platform wide requests need a binding on all the clusters.
+func Authorize(bindings []*Binding, sub *Subject, res *Resource, role Role) error {
+	for _, b := range bindings {
+		if b.Role.Includes(role) && b.bound(sub) && b.covers(res) {
+			return nil
+		}
+	}
+
+	if res.Cluster == "" {
+		return fmt.Errorf("user: %s is not %s of the platform", sub.User, role)
+	}
+	return fmt.Errorf("user: %s is not %s of cluster: %s", sub.User, role, res.Cluster)
+}
+
+// Grantable returns nil if the subject has the role of the scope on all its clusters and tenants,
+// so the scope granted by the subject, e.g. to an api token, doesn't exceed its own permissions.
+func Grantable(bindings []*Binding, sub *Subject, scope *Binding) error {
+	for _, c := range scope.Clusters {
+		res := &Resource{Cluster: c}
+		if c == AllClusters {
+			res.Cluster = ""
+		}
+		if err := Authorize(bindings, sub, res, scope.Role); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range scope.Tenants {
+		granted := false
+		for _, b := range bindings {
+			if b.Role.Includes(scope.Role) && b.bound(sub) && (contains(b.Clusters, AllClusters) || contains(b.Tenants, t)) {
+				granted = true
+				break
+			}
+		}
+		if !granted {
+			return fmt.Errorf("user: %s is not %s of tenant: %s", sub.User, scope.Role, t)
+		}
+	}
+	return nil
+}
+
+// Viewable returns whether the subject has any binding, it reads the platform wide lists.
+func Viewable(bindings []*Binding, sub *Subject) bool {
+	for _, b := range bindings {
+		if b.bound(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Binding) bound(sub *Subject) bool {
+	if contains(b.Users, sub.User) {
+		return true
+	}
+	for _, g := range sub.Groups {
+		if contains(b.Groups, g) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Binding) covers(res *Resource) bool {
+	if contains(b.Clusters, AllClusters) {
+		return true
+	}
+	if res.Cluster == "" {
+		return false
+	}
+	return contains(b.Clusters, res.Cluster) || (res.Tenant != "" && contains(b.Tenants, res.Tenant))
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package rbac
+
+import (
+	"testing"
+)
+
+func TestAuthorize(t *testing.T) {
+	bindings, err := Parse(`
+- name: platform
+  role: admin
+  users: ["root"]
+  clusters: ["*"]
+- name: tenant1-ops
+  role: operator
+  groups: ["ops"]
+  tenants: ["tenant1"]
+- name: c1-viewers
+  role: viewer
+  users: ["alice"]
+  clusters: ["c1"]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		sub     *Subject
+		res     *Resource
+		role    Role
+		allowed bool
+	}{
+		{"platform admin", &Subject{User: "root"}, &Resource{}, RoleAdmin, true},
+		{"platform admin on cluster", &Subject{User: "root"}, &Resource{Cluster: "c9"}, RoleAdmin, true},
+		{"viewer reads", &Subject{User: "alice"}, &Resource{Cluster: "c1"}, RoleViewer, true},
+		{"viewer operates", &Subject{User: "alice"}, &Resource{Cluster: "c1"}, RoleOperator, false},
+		{"viewer of another cluster", &Subject{User: "alice"}, &Resource{Cluster: "c2"}, RoleViewer, false},
+		{"viewer of the namesake in another namespace", &Subject{User: "alice"}, &Resource{Cluster: ClusterName("ns-b", "c1")}, RoleViewer, false},
+		{"tenant group operates", &Subject{User: "bob", Groups: []string{"ops"}}, &Resource{Cluster: "c2", Tenant: "tenant1"}, RoleOperator, true},
+		{"tenant group adds cluster", &Subject{User: "bob", Groups: []string{"ops"}}, &Resource{Cluster: "c2", Tenant: "tenant1"}, RoleAdmin, false},
+		{"tenant group on another tenant", &Subject{User: "bob", Groups: []string{"ops"}}, &Resource{Cluster: "c3", Tenant: "tenant2"}, RoleViewer, false},
+		{"tenant binding on platform", &Subject{User: "bob", Groups: []string{"ops"}}, &Resource{}, RoleOperator, false},
+		{"unbound user", &Subject{User: "eve"}, &Resource{Cluster: "c1"}, RoleViewer, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Authorize(bindings, tt.sub, tt.res, tt.role)
+			if (err == nil) != tt.allowed {
+				t.Errorf("Authorize() error = %v, allowed %v", err, tt.allowed)
+			}
+		})
+	}
+
+	if Viewable(bindings, &Subject{User: "eve"}) || !Viewable(bindings, &Subject{User: "alice"}) {
+		t.Errorf("Viewable() only the bound users read the platform wide lists")
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, data := range []string{
+		`[{"name": "b", "role": "owner", "users": ["a"], "clusters": ["c1"]}]`,
+		`[{"name": "b", "role": "admin", "clusters": ["c1"]}]`,
+		`[{"name": "b", "role": "admin", "users": ["a"]}]`,
+		`[{"name": "b", "role": "admin", "users": ["a"], "clusters": ["c1"]}, {"name": "b", "role": "viewer", "users": ["a"], "clusters": ["c1"]}]`,
+	} {
+		if _, err := Parse(data); err == nil {
+			t.Errorf("Parse(%s) expected error", data)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package router
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/tracing"
+	"github.com/gostship/kunkka/pkg/util/authutil"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	utilvalidation "github.com/gostship/kunkka/pkg/util/validation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog"
+)
+
+const (
+	// DefaultMaxBodySize the max size of the request body, 1MiB
+	DefaultMaxBodySize int64 = 1 << 20
+)
+
+var (
+	// DefaultContentTypes the content types accepted by the requests with a body
+	DefaultContentTypes = []string{gin.MIMEJSON}
+
+	// DefaultNameParams the query and path params which end up in object names
+	DefaultNameParams = []string{"name", "clusterName", "namespace"}
+	// DefaultLabelParams the query and path params which end up in label values
+	DefaultLabelParams = []string{"rackTag"}
+
+	// DefaultPublicPaths the paths served without authentication, the pprof endpoints have their own token
+	DefaultPublicPaths = []string{"/", LivePath, ReadyPath, HealthzPath, ReadyzPath, VersionPath, MetricsPath, "/capabilities", "/openapi.json", "/swagger-ui", "/oauth/authorize", "/apis/cluster/configs/oauth"}
+)
+
+// MaxBodySize rejects the requests whose body is larger than max, or the override of the route,
+// the RouteKey of the request.
+func MaxBodySize(defaultMax int64, routeMax map[string]int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		max := defaultMax
+		for _, key := range routeKeys(c) {
+			if m, ok := routeMax[key]; ok {
+				max = m
+				break
+			}
+		}
+		if max <= 0 || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > max {
+			resp := responseutil.Gin{Ctx: c}
+			resp.RespErrorCode(http.StatusRequestEntityTooLarge, responseutil.HTTP_BODY_TOO_LARGE,
+				fmt.Sprintf("body size %d exceeds the limit %d", c.Request.ContentLength, max))
+			return
+		}
+
+		// chunked body has no ContentLength, reading beyond max fails the bind of the handler
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, max)
+		c.Next()
+	}
+}
+
+// ContentType rejects the requests with a body whose content type is not one of the types, or of the override
+// of the route, the RouteKey of the request. A route overridden by no types accepts any content type.
+func ContentType(defaultTypes []string, routeTypes map[string][]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		types := defaultTypes
+		for _, key := range routeKeys(c) {
+			if t, ok := routeTypes[key]; ok {
+				types = t
+				break
+			}
+		}
+		if len(types) == 0 || !hasBody(c.Request) {
+			c.Next()
+			return
+		}
+
+		mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		if err == nil {
+			for _, t := range types {
+				if strings.EqualFold(mediaType, t) {
+					c.Next()
+					return
+				}
+			}
+		}
+
+		resp := responseutil.Gin{Ctx: c}
+		resp.RespErrorCode(http.StatusUnsupportedMediaType, responseutil.HTTP_CONTENT_TYPE_ERROR,
+			fmt.Sprintf("content type %q is not supported, expected: %s", c.GetHeader("Content-Type"), strings.Join(types, ",")))
+	}
+}
+
+// routeKeys returns the RouteKey of the request and the key of its "Any" route, the overrides of the former win.
+func routeKeys(c *gin.Context) []string {
+	key := RouteKey(c)
+	return []string{key, "Any " + strings.SplitN(key, " ", 2)[1]}
+}
+
+// SanitizeParams rejects the requests whose query or path params would make invalid
+// object names (nameParams) or label values (labelParams), e.g. cluster name, rack tag.
+func SanitizeParams(nameParams []string, labelParams []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, key := range nameParams {
+			for _, v := range paramValues(c, key) {
+				if errs := k8svalidation.IsDNS1123Subdomain(v); len(errs) > 0 {
+					invalidParam(c, key, fmt.Errorf("%q %s", v, strings.Join(errs, ", ")))
+					return
+				}
+			}
+		}
+
+		for _, key := range labelParams {
+			for _, v := range paramValues(c, key) {
+				if _, err := utilvalidation.SanitizeLabelValue(v); err != nil {
+					invalidParam(c, key, err)
+					return
+				}
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// PprofGuard rejects the pprof requests without the bearer token.
+func PprofGuard(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !strings.HasPrefix(c.Request.URL.Path, PprofPath) {
+			c.Next()
+			return
+		}
+
+		got := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			resp := responseutil.Gin{Ctx: c}
+			resp.RespErrorCode(http.StatusUnauthorized, responseutil.ERROR_AUTH_CHECK_TOKEN_FAIL, "the pprof token is invalid")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Authenticate rejects the requests without a valid bearer token or client certificate except the public paths,
+// and injects the user of the token or the certificate into the context of the handlers.
+func Authenticate(authn authutil.Authenticator, publicPaths []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isPublicPath(c.Request.URL.Path, publicPaths) {
+			c.Next()
+			return
+		}
+
+		var user *authutil.User
+		var err error
+		authorization := authutil.RequestAuthorization(c.Request)
+		if authorization == "" && c.Request.TLS != nil && len(c.Request.TLS.VerifiedChains) > 0 {
+			// the automation clients authenticated by the mTLS client certificates
+			user, err = authutil.CertUser(c.Request.TLS)
+		} else {
+			user, err = authn.Authenticate(authorization)
+		}
+		if err != nil {
+			klog.V(3).Infof("reject unauthenticated %s %s from %s, err: %v", c.Request.Method, c.Request.URL.Path, c.ClientIP(), err)
+			c.Header("WWW-Authenticate", `Bearer realm="kunkka"`)
+			resp := responseutil.Gin{Ctx: c}
+			resp.RespErrorCode(http.StatusUnauthorized, responseutil.ERROR_AUTH_CHECK_TOKEN_FAIL, "the bearer token or the client certificate is invalid")
+			return
+		}
+
+		authutil.SetUser(c, user)
+		c.Next()
+	}
+}
+
+// Trace starts the span of the request except the skipped paths, the child of the span of the traceparent header
+// if any. The handlers start their spans from the context of the request, e.g. the creation of the Clusters.
+func Trace(skipPaths []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isPublicPath(c.Request.URL.Path, skipPaths) {
+			c.Next()
+			return
+		}
+
+		ctx := tracing.WithTraceParent(c.Request.Context(), c.GetHeader("traceparent"))
+		ctx, span := tracing.Start(ctx, RouteKey(c),
+			semconv.HTTPMethodKey.String(c.Request.Method),
+			semconv.HTTPTargetKey.String(c.Request.URL.Path),
+			semconv.HTTPClientIPKey.String(c.ClientIP()))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+		var err error
+		if status >= http.StatusInternalServerError {
+			err = fmt.Errorf("%d %s", status, http.StatusText(status))
+		}
+		tracing.End(span, err)
+	}
+}
+
+// isPublicPath returns whether the path is one of the public paths or below one of them, "/" only matches itself.
+func isPublicPath(path string, publicPaths []string) bool {
+	for _, p := range publicPaths {
+		if path == p || (p != "/" && strings.HasPrefix(path, strings.TrimSuffix(p, "/")+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+func invalidParam(c *gin.Context, key string, err error) {
+	resp := responseutil.Gin{Ctx: c}
+	resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, fmt.Sprintf("%s: %v", key, err))
+}
+
+func paramValues(c *gin.Context, key string) []string {
+	var values []string
+	if v, ok := c.Params.Get(key); ok {
+		values = append(values, v)
+	}
+	values = append(values, c.Request.URL.Query()[key]...)
+
+	filtered := values[:0]
+	for _, v := range values {
+		// "all" selects all clusters in the list apis
+		if v != "" && v != "all" {
+			filtered = append(filtered, v)
+		}
+	}
+
+	return filtered
+}
+
+func hasBody(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return r.ContentLength != 0
+	}
+
+	return false
+}
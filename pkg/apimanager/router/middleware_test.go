@@ -0,0 +1,60 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBodyChecks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := NewRouter(&Options{
+		MaxBodySize:       8,
+		ContentTypes:      DefaultContentTypes,
+		RouteContentTypes: map[string][]string{"PATCH /v1/clusters/:name": {gin.MIMEJSON, "application/merge-patch+json"}},
+		Aliases:           []*Alias{{Path: "/v2/clusters/:name/proxy/*path", Route: "Any /v1/clusters/:name/proxy/*path", Raw: true}},
+	})
+	ok := func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	}
+	r.AddRoutes("test", []*Route{
+		{Method: "POST", Path: "/v1/clusters", Handler: ok},
+		{Method: "PATCH", Path: "/v1/clusters/:name", Handler: ok},
+		{Method: "Any", Path: "/v1/clusters/:name/proxy/*path", Handler: ok, Raw: true},
+		{Method: "Any", Path: "/v2/clusters/:name/proxy/*path", Handler: ok},
+	})
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		want        int
+	}{
+		{name: "json", method: http.MethodPost, path: "/v1/clusters", contentType: gin.MIMEJSON, body: "{}", want: http.StatusOK},
+		{name: "yaml", method: http.MethodPost, path: "/v1/clusters", contentType: "application/yaml", body: "a: b", want: http.StatusUnsupportedMediaType},
+		{name: "too large", method: http.MethodPost, path: "/v1/clusters", contentType: gin.MIMEJSON, body: `{"a":"bcd"}`, want: http.StatusRequestEntityTooLarge},
+		{name: "route content type", method: http.MethodPatch, path: "/v1/clusters/c1", contentType: "application/merge-patch+json", body: "{}", want: http.StatusOK},
+		{name: "route default content type", method: http.MethodPatch, path: "/v1/clusters/c1", contentType: gin.MIMEJSON, body: "{}", want: http.StatusOK},
+		{name: "route unsupported content type", method: http.MethodPatch, path: "/v1/clusters/c1", contentType: "application/yaml", body: "{}", want: http.StatusUnsupportedMediaType},
+		{name: "raw apply patch", method: http.MethodPatch, path: "/v1/clusters/c1/proxy/api/v1/namespaces/ns", contentType: "application/apply-patch+yaml", body: "kind: Namespace\n", want: http.StatusOK},
+		{name: "raw protobuf", method: http.MethodPost, path: "/v1/clusters/c1/proxy/api/v1/pods", contentType: "application/vnd.kubernetes.protobuf", body: "k8s\x00", want: http.StatusOK},
+		{name: "raw alias", method: http.MethodPut, path: "/v2/clusters/c1/proxy/api/v1/namespaces/ns", contentType: "application/strategic-merge-patch+json", body: `{"metadata":{"labels":{}}}`, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("%s %s got %d, want %d: %s", tt.method, tt.path, w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
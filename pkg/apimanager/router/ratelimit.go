@@ -0,0 +1,128 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/util/authutil"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+const (
+	// DefaultRateLimitQPS, DefaultRateLimitBurst the rate limit of each client on all the routes
+	DefaultRateLimitQPS   float32 = 20
+	DefaultRateLimitBurst         = 40
+
+	// limiterIdleTimeout the limiters of the clients idle for longer are dropped
+	limiterIdleTimeout = 10 * time.Minute
+)
+
+// RateLimit the token bucket of a client, QPS <= 0 means no limit.
+type RateLimit struct {
+	QPS   float32
+	Burst int
+}
+
+// ParseRouteLimit parses the route override "<METHOD> <path>=<value>", e.g. "POST /apis/cluster/addCluster=0.1:2".
+func ParseRouteLimit(s string) (string, string, error) {
+	i := strings.LastIndex(s, "=")
+	if i < 0 {
+		return "", "", fmt.Errorf("route limit %q must be <METHOD> <path>=<value>", s)
+	}
+	route, value := strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	if parts := strings.Fields(route); len(parts) != 2 || !strings.HasPrefix(parts[1], "/") {
+		return "", "", fmt.Errorf("route %q must be <METHOD> <path>", route)
+	} else {
+		route = strings.ToUpper(parts[0]) + " " + parts[1]
+	}
+	return route, value, nil
+}
+
+// ParseRateLimit parses "<qps>:<burst>", the burst defaults to the qps rounded up.
+func ParseRateLimit(s string) (RateLimit, error) {
+	parts := strings.SplitN(s, ":", 2)
+	qps, err := strconv.ParseFloat(parts[0], 32)
+	if err != nil || qps < 0 {
+		return RateLimit{}, fmt.Errorf("invalid qps: %q", parts[0])
+	}
+	l := RateLimit{QPS: float32(qps), Burst: int(qps + 0.999)}
+	if len(parts) == 2 {
+		if l.Burst, err = strconv.Atoi(parts[1]); err != nil || l.Burst < 1 {
+			return RateLimit{}, fmt.Errorf("invalid burst: %q", parts[1])
+		}
+	}
+	if l.Burst < 1 {
+		l.Burst = 1
+	}
+	return l, nil
+}
+
+type clientLimiter struct {
+	limiter  flowcontrol.RateLimiter
+	lastSeen time.Time
+}
+
+// limiters keeps a token bucket per client and limit.
+type limiters struct {
+	sync.Mutex
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+func (l *limiters) allow(key string, limit RateLimit) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > limiterIdleTimeout {
+		for k, c := range l.clients {
+			if now.Sub(c.lastSeen) > limiterIdleTimeout {
+				delete(l.clients, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	c, ok := l.clients[key]
+	if !ok {
+		c = &clientLimiter{limiter: flowcontrol.NewTokenBucketRateLimiter(limit.QPS, limit.Burst)}
+		l.clients[key] = c
+	}
+	c.lastSeen = now
+	return c.limiter.TryAccept()
+}
+
+// RateLimiter rejects the requests of a client beyond the limit on all the routes or the override of the route,
+// the RouteKey of the request, e.g. the cluster creation is limited stricter than the reads.
+// The client is the authenticated user, or the client ip without the authentication.
+func RateLimiter(limit RateLimit, routeLimits map[string]RateLimit) gin.HandlerFunc {
+	l := &limiters{clients: make(map[string]*clientLimiter)}
+
+	return func(c *gin.Context) {
+		client := c.ClientIP()
+		if user, err := authutil.RequestUser(c); err == nil {
+			client = user.Name
+		}
+
+		route := RouteKey(c)
+		allowed := limit.QPS <= 0 || l.allow(client, limit)
+		if rl, ok := routeLimits[route]; ok && allowed && rl.QPS > 0 {
+			allowed = l.allow(route+"|"+client, rl)
+		}
+		if !allowed {
+			c.Header("Retry-After", "1")
+			resp := responseutil.Gin{Ctx: c}
+			resp.RespErrorCode(http.StatusTooManyRequests, responseutil.HTTP_TOO_MANY_REQUESTS,
+				fmt.Sprintf("client %s exceeds the rate limit of %s", client, route))
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,75 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseRouteRateLimit(t *testing.T) {
+	tests := []struct {
+		in    string
+		route string
+		limit RateLimit
+		err   bool
+	}{
+		{in: "post /apis/cluster/addCluster=0.1:2", route: "POST /apis/cluster/addCluster", limit: RateLimit{QPS: 0.1, Burst: 2}},
+		{in: "GET /apis/cluster/getMemberList=5", route: "GET /apis/cluster/getMemberList", limit: RateLimit{QPS: 5, Burst: 5}},
+		{in: "GET /audit=0", route: "GET /audit", limit: RateLimit{QPS: 0, Burst: 1}},
+		{in: "/apis/cluster/addCluster=1", err: true},
+		{in: "POST /apis/cluster/addCluster", err: true},
+		{in: "POST /apis/cluster/addCluster=1:0", err: true},
+		{in: "POST /apis/cluster/addCluster=-1", err: true},
+	}
+	for _, tt := range tests {
+		route, value, err := ParseRouteLimit(tt.in)
+		var limit RateLimit
+		if err == nil {
+			limit, err = ParseRateLimit(value)
+		}
+		if (err != nil) != tt.err {
+			t.Errorf("%q: error: %v, want error: %v", tt.in, err, tt.err)
+			continue
+		}
+		if !tt.err && (route != tt.route || limit != tt.limit) {
+			t.Errorf("%q: got %q %+v, want %q %+v", tt.in, route, limit, tt.route, tt.limit)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(RateLimiter(RateLimit{QPS: 0.001, Burst: 3}, map[string]RateLimit{
+		"POST /clusters": {QPS: 0.001, Burst: 1},
+	}))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	engine.GET("/clusters", ok)
+	engine.POST("/clusters", ok)
+
+	do := func(method, ip string) int {
+		req := httptest.NewRequest(method, "/clusters", nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := do(http.MethodPost, "10.0.0.1"); code != http.StatusOK {
+		t.Fatalf("first create: got %d", code)
+	}
+	if code := do(http.MethodPost, "10.0.0.1"); code != http.StatusTooManyRequests {
+		t.Fatalf("second create: got %d, want 429", code)
+	}
+	if code := do(http.MethodGet, "10.0.0.1"); code != http.StatusOK {
+		t.Fatalf("read after the create limit: got %d", code)
+	}
+	if code := do(http.MethodGet, "10.0.0.1"); code != http.StatusTooManyRequests {
+		t.Fatalf("read beyond the client limit: got %d, want 429", code)
+	}
+	if code := do(http.MethodPost, "10.0.0.2"); code != http.StatusOK {
+		t.Fatalf("create of another client: got %d", code)
+	}
+}
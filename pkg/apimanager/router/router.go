@@ -6,6 +6,8 @@ import (
 	"crypto/tls"
 	"fmt"
 	"github.com/gostship/kunkka/pkg/apimanager/metrics"
+	"github.com/gostship/kunkka/pkg/logs"
+	"github.com/gostship/kunkka/pkg/util/authutil"
 	"github.com/gostship/kunkka/pkg/version"
 	"net/http"
 	"text/template"
@@ -24,7 +26,11 @@ const (
 	MetricsPath = "/metrics"
 	LivePath    = "/live"
 	ReadyPath   = "/ready"
+	HealthzPath = "/healthz"
+	ReadyzPath  = "/readyz"
 	PprofPath   = "/debug/pprof"
+	// LogLevelPath shares the token of the pprof endpoints
+	LogLevelPath = PprofPath + "/loglevel"
 )
 
 // Options are options for constructing a Router
@@ -32,15 +38,44 @@ type Options struct {
 	GinLogEnabled  bool
 	GinLogSkipPath []string
 	PprofEnabled   bool
+	PprofToken     string
 	MetricsEnabled bool
+	// TracingEnabled starts the spans of the requests except the probes and the metrics
+	TracingEnabled bool
 
 	Addr             string
 	MetricsSubsystem string
 	MetricsPath      string
 	ShutdownTimeout  time.Duration
 
+	// TLS serves https with the reloaded certificates, CertFilePath and KeyFilePath are reloaded every minute without it
+	TLS          *CertReloader
 	CertFilePath string
 	KeyFilePath  string
+
+	// MaxBodySize the max size of the request body, <= 0 means no limit
+	MaxBodySize int64
+	// RouteMaxBodySizes the max body size overrides of the "<METHOD> <path>" routes
+	RouteMaxBodySizes map[string]int64
+	// RateLimit the rate limit of each client, RouteRateLimits the additional limits of the "<METHOD> <path>" routes
+	RateLimit       RateLimit
+	RouteRateLimits map[string]RateLimit
+	// ContentTypes the accepted content types of the request body, empty means all,
+	// RouteContentTypes the overrides of the "<METHOD> <path>" routes
+	ContentTypes      []string
+	RouteContentTypes map[string][]string
+	// NameParams, LabelParams the params sanitized as object names and label values
+	NameParams  []string
+	LabelParams []string
+
+	// Aliases the routes of the newer api versions served by the handlers of the older routes,
+	// the errors of the paths below TypedErrorPrefix are the typed errors
+	Aliases          []*Alias
+	TypedErrorPrefix string
+
+	// Authenticator verifies the bearer token of the requests except the PublicPaths, nil disables the authentication
+	Authenticator authutil.Authenticator
+	PublicPaths   []string
 }
 
 // Router handles all incoming HTTP requests
@@ -50,6 +85,9 @@ type Router struct {
 	httpServer          *http.Server
 	ProfileDescriptions []*Profile
 	Opt                 *Options
+	// bodySizes, contentTypes the overrides of the routes, the Raw routes added are not checked
+	bodySizes    map[string]int64
+	contentTypes map[string][]string
 }
 
 // Profile ...
@@ -65,6 +103,15 @@ type Route struct {
 	Path    string
 	Handler gin.HandlerFunc
 	Desc    string
+	// Request, Response the samples of the json body and of the items of the response,
+	// documented by the OpenAPI document
+	Request  interface{}
+	Response interface{}
+	// Deprecated the route is served by an Alias of a newer api version as well
+	Deprecated bool
+	// Raw the body is passed through as is, e.g. to a proxied apiserver, neither its size nor its content type
+	// is checked unless the route is overridden by the options
+	Raw bool
 }
 
 // NewRouter creates a new Router instance
@@ -79,12 +126,36 @@ func NewRouter(opt *Options) *Router {
 		}
 		engine.Use(gin.LoggerWithConfig(conf))
 	}
-
+	if len(opt.Aliases) > 0 || opt.TypedErrorPrefix != "" {
+		engine.Use(Versioned(opt.Aliases, opt.TypedErrorPrefix))
+	}
+	if opt.TracingEnabled {
+		engine.Use(Trace(append(opt.GinLogSkipPath[:len(opt.GinLogSkipPath):len(opt.GinLogSkipPath)], MetricsPath)))
+	}
+	if opt.Authenticator != nil {
+		publicPaths := opt.PublicPaths
+		if opt.PprofEnabled && opt.PprofToken != "" {
+			publicPaths = append(publicPaths[:len(publicPaths):len(publicPaths)], PprofPath)
+		}
+		engine.Use(Authenticate(opt.Authenticator, publicPaths))
+	}
+	if opt.RateLimit.QPS > 0 || len(opt.RouteRateLimits) > 0 {
+		engine.Use(RateLimiter(opt.RateLimit, opt.RouteRateLimits))
+	}
 	r := &Router{
 		Engine:              engine,
 		Routes:              make(map[string][]*Route, 0),
 		ProfileDescriptions: make([]*Profile, 0),
+		bodySizes:           make(map[string]int64, len(opt.RouteMaxBodySizes)),
+		contentTypes:        make(map[string][]string, len(opt.RouteContentTypes)),
+	}
+	for route, size := range opt.RouteMaxBodySizes {
+		r.bodySizes[route] = size
+	}
+	for route, types := range opt.RouteContentTypes {
+		r.contentTypes[route] = types
 	}
+	engine.Use(MaxBodySize(opt.MaxBodySize, r.bodySizes), ContentType(opt.ContentTypes, r.contentTypes), SanitizeParams(opt.NameParams, opt.LabelParams))
 
 	if opt.MetricsEnabled {
 		klog.Infof("start load router path:%s ", opt.MetricsPath)
@@ -102,9 +173,15 @@ func NewRouter(opt *Options) *Router {
 	}
 
 	if opt.PprofEnabled {
+		if opt.PprofToken != "" {
+			r.Engine.Use(PprofGuard(opt.PprofToken))
+		}
 		ginpprof.Wrap(r.Engine)
 		r.AddProfile("GET", PprofPath, `PProf related things:<br/>
 			<a href="/debug/pprof/goroutine?debug=2">full goroutine stack dump</a>`)
+		r.Engine.GET(LogLevelPath, gin.WrapH(logs.LevelHandler()))
+		r.Engine.PUT(LogLevelPath, gin.WrapH(logs.LevelHandler()))
+		r.AddProfile("GET", LogLevelPath, "The level of the logs, PUT {\"level\":\"debug\"} to adjust it")
 	}
 
 	r.Opt = opt
@@ -143,20 +220,29 @@ func (r *Router) Start(stopCh <-chan struct{}) error {
 		WriteTimeout: 35 * time.Second,
 	}
 
-	if r.Opt.CertFilePath != "" && r.Opt.KeyFilePath != "" {
-		cert, err := tls.LoadX509KeyPair(r.Opt.CertFilePath, r.Opt.KeyFilePath)
+	reloader := r.Opt.TLS
+	if reloader == nil && r.Opt.CertFilePath != "" && r.Opt.KeyFilePath != "" {
+		reloader = NewCertReloader(tls.NoClientCert)
+		err := reloader.WatchFiles(r.Opt.CertFilePath, r.Opt.KeyFilePath, "", time.Minute, stopCh)
 		if err != nil {
 			klog.Errorf("LoadX509KeyPair err:%+v", err)
 			return err
 		}
-		r.httpServer.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	if reloader != nil {
+		if err := reloader.Ready(); err != nil {
+			klog.Errorf("tls is not ready err:%+v", err)
+			return err
+		}
+		r.httpServer.TLSConfig = reloader.TLSConfig()
 	}
 
 	errCh := make(chan error)
 	go func() {
-		if r.Opt.CertFilePath != "" && r.Opt.KeyFilePath != "" {
+		if reloader != nil {
 			klog.Infof("Listening on %s, https://localhost%s\n", r.Opt.Addr, r.Opt.Addr)
-			if err := r.httpServer.ListenAndServeTLS(r.Opt.CertFilePath, r.Opt.KeyFilePath); err != nil && err != http.ErrServerClosed {
+			// the certificates come from the TLSConfig
+			if err := r.httpServer.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
 				klog.Error("Https server error: ", err)
 				errCh <- err
 			}
@@ -215,6 +301,8 @@ func (r *Router) AddRoutes(apiGroup string, routes []*Route) {
 			r.GET(route.Path, route.Handler)
 		case "POST":
 			r.POST(route.Path, route.Handler)
+		case "PATCH":
+			r.PATCH(route.Path, route.Handler)
 		case "DELETE":
 			r.DELETE(route.Path, route.Handler)
 		case "Any":
@@ -222,6 +310,9 @@ func (r *Router) AddRoutes(apiGroup string, routes []*Route) {
 		default:
 			klog.Warningf("no method:%s apiGroup:%s", route.Method, apiGroup)
 		}
+		if route.Raw {
+			r.skipBodyChecks(route.Method + " " + route.Path)
+		}
 	}
 
 	if rs, ok := r.Routes[apiGroup]; !ok {
@@ -235,6 +326,10 @@ func (r *Router) AddRoutes(apiGroup string, routes []*Route) {
 			<a href="/live?full=true"> query the full body`)
 		r.AddProfile("GET", ReadyPath, `readyness check:  <br/>
 			<a href="/ready?full=true"> query the full body`)
+		r.AddProfile("GET", HealthzPath, `liveness check of the meta cluster client: <br/>
+			<a href="/healthz?full=true"> query the full body`)
+		r.AddProfile("GET", ReadyzPath, `readiness check of the meta cluster and the informer caches: <br/>
+			<a href="/readyz?full=true"> query the full body`)
 		r.AddProfile("GET", VersionPath, `version describe: <br/>
             <a href="/version"> query version info`)
 	} else if apiGroup == "cluster" {
@@ -250,6 +345,16 @@ func (r *Router) AddRoutes(apiGroup string, routes []*Route) {
 	}
 }
 
+// skipBodyChecks lets the body of the route through as is, unless the options override the route.
+func (r *Router) skipBodyChecks(route string) {
+	if _, ok := r.bodySizes[route]; !ok {
+		r.bodySizes[route] = 0
+	}
+	if _, ok := r.contentTypes[route]; !ok {
+		r.contentTypes[route] = nil
+	}
+}
+
 // all incoming requests are passed through this handler
 func (r *Router) masterHandler(c *gin.Context) {
 	klog.V(4).Infof("no router for method:%s, url:%s", c.Request.Method, c.Request.URL.Path)
@@ -286,8 +391,8 @@ func (r *Router) DefaultRoutes() []*Route {
 	var routes []*Route
 
 	appRoutes := []*Route{
-		{"GET", "/", r.IndexHandler, ""},
-		{"GET", VersionPath, VersionHandler, ""},
+		{Method: "GET", Path: "/", Handler: r.IndexHandler},
+		{Method: "GET", Path: VersionPath, Handler: VersionHandler},
 	}
 
 	routes = append(routes, appRoutes...)
@@ -0,0 +1,185 @@
+package router
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+)
+
+// the client auth modes of the TLS server
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// ClientAuthType returns the tls client auth type of the mode, the client certs are verified by the client CAs.
+func ClientAuthType(mode string) (tls.ClientAuthType, error) {
+	switch strings.ToLower(mode) {
+	case "", ClientAuthNone:
+		return tls.NoClientCert, nil
+	case ClientAuthOptional:
+		return tls.VerifyClientCertIfGiven, nil
+	case ClientAuthRequire:
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return tls.NoClientCert, fmt.Errorf("tls client auth must be %s, %s or %s", ClientAuthNone, ClientAuthOptional, ClientAuthRequire)
+}
+
+// CertReloader serves the latest serving certificate and client CAs,
+// so the rotated certificates are picked up by the new connections without restart.
+type CertReloader struct {
+	mu         sync.RWMutex
+	cert       *tls.Certificate
+	certPEM    []byte // the pem of the cert and the key
+	clientCAs  *x509.CertPool
+	caPEM      []byte
+	clientAuth tls.ClientAuthType
+}
+
+// NewCertReloader ...
+func NewCertReloader(clientAuth tls.ClientAuthType) *CertReloader {
+	return &CertReloader{clientAuth: clientAuth}
+}
+
+// SetCertificate replaces the serving certificate, the current one is kept if the new one is invalid.
+// It returns whether the certificate changed.
+func (r *CertReloader) SetCertificate(certPEM, keyPEM []byte) (bool, error) {
+	pair := append(append([]byte{}, certPEM...), keyPEM...)
+	r.mu.RLock()
+	unchanged := r.cert != nil && bytes.Equal(r.certPEM, pair)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, errors.Wrap(err, "load serving certificate")
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return false, errors.Wrap(err, "parse serving certificate")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.certPEM = pair
+	klog.Infof("serving certificate of %v loaded, expires at %s", cert.Leaf.DNSNames, cert.Leaf.NotAfter.Format(time.RFC3339))
+	return true, nil
+}
+
+// SetClientCAs replaces the CAs verifying the client certificates.
+func (r *CertReloader) SetClientCAs(caPEM []byte) (bool, error) {
+	r.mu.RLock()
+	unchanged := r.clientCAs != nil && bytes.Equal(r.caPEM, caPEM)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return false, errors.New("no client CA certificate found")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clientCAs = pool
+	r.caPEM = caPEM
+	klog.Info("client CAs loaded")
+	return true, nil
+}
+
+// Ready returns whether the serving certificate is loaded, and the client CAs if the client certs are verified.
+func (r *CertReloader) Ready() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.cert == nil {
+		return errors.New("no serving certificate")
+	}
+	if r.clientAuth != tls.NoClientCert && r.clientCAs == nil {
+		return errors.New("no client CAs")
+	}
+	return nil
+}
+
+// TLSConfig returns the config of the TLS server, each handshake gets the latest certificate and client CAs.
+func (r *CertReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			if r.cert == nil {
+				return nil, errors.New("no serving certificate")
+			}
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.cert},
+				ClientAuth:   r.clientAuth,
+				ClientCAs:    r.clientCAs,
+				NextProtos:   []string{"h2", "http/1.1"},
+			}, nil
+		},
+	}
+}
+
+// LoadFiles sets the serving certificate of the files.
+func (r *CertReloader) LoadFiles(certFile, keyFile string) error {
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return errors.Wrapf(err, "read %s", certFile)
+	}
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return errors.Wrapf(err, "read %s", keyFile)
+	}
+	_, err = r.SetCertificate(certPEM, keyPEM)
+	return errors.Wrapf(err, "%s", certFile)
+}
+
+// LoadClientCAFile sets the client CAs of the file.
+func (r *CertReloader) LoadClientCAFile(caFile string) error {
+	caPEM, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return errors.Wrapf(err, "read %s", caFile)
+	}
+	_, err = r.SetClientCAs(caPEM)
+	return errors.Wrapf(err, "%s", caFile)
+}
+
+// WatchFiles reloads the certificate and the client CAs from the files every interval till stopCh is closed,
+// the files of the mounted Secrets are replaced by the kubelet when the Secrets change.
+// The files are loaded once before it returns, an empty caFile skips the client CAs.
+func (r *CertReloader) WatchFiles(certFile, keyFile, caFile string, interval time.Duration, stopCh <-chan struct{}) error {
+	load := func() error {
+		if err := r.LoadFiles(certFile, keyFile); err != nil {
+			return err
+		}
+		if caFile == "" {
+			return nil
+		}
+		return r.LoadClientCAFile(caFile)
+	}
+
+	if err := load(); err != nil {
+		return err
+	}
+	go wait.Until(func() {
+		if err := load(); err != nil {
+			klog.Errorf("reload tls files error, keep serving the current ones: %v", err)
+		}
+	}, interval, stopCh)
+	return nil
+}
@@ -0,0 +1,75 @@
+package router
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	certutil "k8s.io/client-go/util/cert"
+)
+
+func TestCertReloader(t *testing.T) {
+	reloader := NewCertReloader(tls.NoClientCert)
+	if err := reloader.Ready(); err == nil {
+		t.Fatal("ready without the serving certificate")
+	}
+	if _, err := reloader.SetCertificate([]byte("bad"), []byte("bad")); err == nil {
+		t.Fatal("invalid certificate accepted")
+	}
+
+	serve := func(host string) {
+		certPEM, keyPEM, err := certutil.GenerateSelfSignedCertKey(host, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		changed, err := reloader.SetCertificate(certPEM, keyPEM)
+		if err != nil || !changed {
+			t.Fatalf("set certificate of %s: changed: %v, err: %v", host, changed, err)
+		}
+		if changed, _ := reloader.SetCertificate(certPEM, keyPEM); changed {
+			t.Fatalf("the same certificate of %s changed", host)
+		}
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = reloader.TLSConfig()
+	serve("one.example.com")
+	server.StartTLS()
+	defer server.Close()
+
+	servedHost := func() string {
+		conn, err := tls.Dial("tcp", server.Listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].DNSNames[0]
+	}
+
+	if host := servedHost(); host != "one.example.com" {
+		t.Fatalf("served %s, want one.example.com", host)
+	}
+	serve("two.example.com")
+	if host := servedHost(); host != "two.example.com" {
+		t.Fatalf("served %s after reload, want two.example.com", host)
+	}
+}
+
+func TestClientAuthType(t *testing.T) {
+	tests := map[string]tls.ClientAuthType{
+		"":         tls.NoClientCert,
+		"none":     tls.NoClientCert,
+		"optional": tls.VerifyClientCertIfGiven,
+		"Require":  tls.RequireAndVerifyClientCert,
+	}
+	for mode, want := range tests {
+		got, err := ClientAuthType(mode)
+		if err != nil || got != want {
+			t.Errorf("%q: got %v, %v, want %v", mode, got, err, want)
+		}
+	}
+	if _, err := ClientAuthType("request"); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
@@ -0,0 +1,224 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+)
+
+// routeKey the context key of the "<METHOD> <path>" of the older route serving an Alias
+const routeKey = "kunkka/route"
+
+// Alias is a route of a newer api version served by the handler of an older route, e.g. GET /apis/v2/clusters/:name
+// by GET /apis/cluster/getClusterDetail?name=. The alias shares the limits, the roles and the audit of the older route,
+// and the older route is deprecated with the alias as its successor.
+type Alias struct {
+	Path string
+	// Route the "<METHOD> <path>" of the older route, "Any" routes included
+	Route string
+	// Query the path params of Path the older handler reads from the query, e.g. node of nodeName
+	Query map[string]string
+	// Raw passes the errors through as they are instead of the typed errors, e.g. the Status of the proxied apiserver
+	Raw bool
+}
+
+// Method returns the method of the older route.
+func (a *Alias) Method() string {
+	return strings.SplitN(a.Route, " ", 2)[0]
+}
+
+// Key returns the "<METHOD> <path>" of the alias.
+func (a *Alias) Key() string {
+	return a.Method() + " " + a.Path
+}
+
+// RouteKey returns the "<METHOD> <path>" of the route of the request, the older route of an Alias.
+func RouteKey(c *gin.Context) string {
+	if route := c.GetString(routeKey); route != "" {
+		return route
+	}
+	return c.Request.Method + " " + c.FullPath()
+}
+
+// Versioned serves the aliases and deprecates their older routes by the Deprecation header and the Link
+// to the successor, the errors of the paths below typedPrefix are rewritten to the typed errors.
+// It runs before the other middlewares, which see the query of the older route and its RouteKey.
+func Versioned(aliases []*Alias, typedPrefix string) gin.HandlerFunc {
+	byKey := make(map[string]*Alias, len(aliases))
+	successors := make(map[string]*Alias, len(aliases))
+	for _, a := range aliases {
+		byKey[a.Key()] = a
+		if _, ok := successors[a.Route]; !ok {
+			successors[a.Route] = a
+		}
+	}
+
+	return func(c *gin.Context) {
+		a, ok := byKey[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			a, ok = byKey["Any "+c.FullPath()]
+		}
+		if ok {
+			c.Set(routeKey, a.Route)
+			// before the query is cached by the first c.Query
+			if len(a.Query) > 0 {
+				q := c.Request.URL.Query()
+				for param, key := range a.Query {
+					q.Set(key, c.Param(param))
+				}
+				c.Request.URL.RawQuery = q.Encode()
+			}
+		}
+
+		route := c.Request.Method + " " + c.FullPath()
+		if _, ok := successors["Any "+c.FullPath()]; ok {
+			route = "Any " + c.FullPath()
+		}
+		if s, deprecated := successors[route]; deprecated {
+			c.Header("Deprecation", "true")
+			if path, ok := successorPath(c, s); ok {
+				c.Header("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", path))
+			}
+		}
+
+		if typedPrefix == "" || !strings.HasPrefix(c.Request.URL.Path, typedPrefix) || (a != nil && a.Raw) {
+			c.Next()
+			return
+		}
+		w := &typedErrorWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		w.flush()
+	}
+}
+
+// successorPath fills the params of the path of the alias by the params or the query of the older request.
+func successorPath(c *gin.Context, a *Alias) (string, bool) {
+	segments := strings.Split(a.Path, "/")
+	for i, s := range segments {
+		if !strings.HasPrefix(s, ":") && !strings.HasPrefix(s, "*") {
+			continue
+		}
+		param := s[1:]
+		v := strings.Trim(c.Param(param), "/")
+		if v == "" {
+			v = c.Request.URL.Query().Get(a.Query[param])
+		}
+		if v == "" {
+			return "", false
+		}
+		segments[i] = v
+	}
+	return strings.Join(segments, "/"), true
+}
+
+// typedErrorWriter buffers the body of the errors and rewrites it to the typed error once the handlers return,
+// the other responses, e.g. the streams and the websockets, are written through.
+type typedErrorWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *typedErrorWriter) failed() bool {
+	return w.Status() >= http.StatusBadRequest
+}
+
+func (w *typedErrorWriter) WriteHeaderNow() {
+	if !w.failed() {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *typedErrorWriter) Write(data []byte) (int, error) {
+	if w.failed() {
+		return w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *typedErrorWriter) WriteString(s string) (int, error) {
+	if w.failed() {
+		return w.body.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *typedErrorWriter) Flush() {
+	if !w.failed() {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *typedErrorWriter) flush() {
+	if !w.failed() || w.ResponseWriter.Written() {
+		return
+	}
+	data, err := json.Marshal(typedError(w.Status(), w.body.Bytes()))
+	if err != nil {
+		data = w.body.Bytes()
+	}
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.ResponseWriter.WriteHeaderNow()
+	_, _ = w.ResponseWriter.Write(data)
+}
+
+// typedError converts the error body of the status to the typed error, the detail of the errors without
+// a code, e.g. the messages of the middlewares, is their message.
+func typedError(status int, body []byte) *responseutil.TypedError {
+	e := &responseutil.TypedError{Code: defaultErrorCode(status)}
+	legacy := &struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Detail  string          `json:"detail"`
+		Details json.RawMessage `json:"details"`
+		Error   string          `json:"error"`
+	}{}
+	if json.Unmarshal(body, legacy) == nil {
+		if legacy.Code != 0 {
+			e.Code = legacy.Code
+			e.Detail = legacy.Detail
+			if len(legacy.Details) > 0 {
+				e.Details = legacy.Details
+			}
+		} else if legacy.Message != "" {
+			e.Detail = legacy.Message
+		} else {
+			e.Detail = legacy.Error
+		}
+	} else {
+		e.Detail = strings.TrimSpace(string(body))
+	}
+	e.Reason = responseutil.Reason(status)
+	e.Message = responseutil.GetRequestMsg(e.Code)
+	return e
+}
+
+// defaultErrorCode the code of the errors of the status without one
+func defaultErrorCode(status int) int {
+	switch status {
+	case http.StatusBadRequest:
+		return responseutil.HTTP_INVALID_PARAMS
+	case http.StatusNotFound:
+		return responseutil.HTTP_NOT_FOUND
+	case http.StatusConflict:
+		return responseutil.HTTP_CONFLICT
+	case http.StatusUnauthorized:
+		return responseutil.ERROR_AUTH_CHECK_TOKEN_FAIL
+	case http.StatusForbidden:
+		return responseutil.HTTP_FORBIDDEN
+	case http.StatusRequestEntityTooLarge:
+		return responseutil.HTTP_BODY_TOO_LARGE
+	case http.StatusUnsupportedMediaType:
+		return responseutil.HTTP_CONTENT_TYPE_ERROR
+	case http.StatusTooManyRequests:
+		return responseutil.HTTP_TOO_MANY_REQUESTS
+	default:
+		return responseutil.HTTP_ERROR
+	}
+}
@@ -0,0 +1,112 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+)
+
+func TestVersioned(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(Versioned([]*Alias{
+		{Path: "/v2/clusters/:name", Route: "GET /v1/getCluster", Query: map[string]string{"name": "clusterName"}},
+		{Path: "/v2/clusters/:name/proxy/*path", Route: "Any /v1/clusters/:name/proxy/*path", Raw: true},
+	}, "/v2"))
+
+	getCluster := func(c *gin.Context) {
+		c.Header("X-Route", RouteKey(c))
+		resp := responseutil.Gin{Ctx: c}
+		switch name := c.Query("clusterName"); name {
+		case "missing":
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, "cluster: missing not found")
+		case "busy":
+			resp.RespErr(responseutil.Conflict("cluster: busy is being deleted").WithDetails(map[string]string{"phase": "Deleting"}))
+		case "broken":
+			resp.RespError("get cluster error")
+		default:
+			resp.RespSuccess(true, "success", name, 1)
+		}
+	}
+	proxy := func(c *gin.Context) {
+		c.Data(http.StatusNotFound, gin.MIMEJSON, []byte(`{"kind":"Status"}`))
+	}
+	engine.GET("/v1/getCluster", getCluster)
+	engine.GET("/v2/clusters/:name", getCluster)
+	engine.Any("/v1/clusters/:name/proxy/*path", proxy)
+	engine.Any("/v2/clusters/:name/proxy/*path", proxy)
+	engine.NoRoute(func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "router not found"})
+	})
+
+	do := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := do("/v2/clusters/c1")
+	if w.Code != http.StatusOK || w.Header().Get("X-Route") != "GET /v1/getCluster" || w.Header().Get("Deprecation") != "" {
+		t.Errorf("v2: got %d route: %q deprecation: %q", w.Code, w.Header().Get("X-Route"), w.Header().Get("Deprecation"))
+	}
+	body := map[string]interface{}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body["items"] != "c1" {
+		t.Errorf("v2: got body %s, want the items of c1", w.Body.String())
+	}
+
+	w = do("/v1/getCluster?clusterName=c1")
+	if w.Code != http.StatusOK || w.Header().Get("Deprecation") != "true" || w.Header().Get("Link") != `</v2/clusters/c1>; rel="successor-version"` {
+		t.Errorf("v1: got %d deprecation: %q link: %q", w.Code, w.Header().Get("Deprecation"), w.Header().Get("Link"))
+	}
+	w = do("/v1/getCluster")
+	if w.Header().Get("Deprecation") != "true" || w.Header().Get("Link") != "" {
+		t.Errorf("v1 without cluster: got deprecation: %q link: %q", w.Header().Get("Deprecation"), w.Header().Get("Link"))
+	}
+	w = do("/v1/clusters/c1/proxy/api/v1/pods")
+	if w.Header().Get("Link") != `</v2/clusters/c1/proxy/api/v1/pods>; rel="successor-version"` {
+		t.Errorf("v1 proxy: got link: %q", w.Header().Get("Link"))
+	}
+
+	tests := []struct {
+		path    string
+		status  int
+		code    int
+		reason  string
+		detail  string
+		details interface{}
+	}{
+		{path: "/v2/clusters/missing", status: http.StatusNotFound, code: responseutil.HTTP_NOT_FOUND, reason: responseutil.ReasonNotFound, detail: "cluster: missing not found"},
+		{path: "/v2/clusters/busy", status: http.StatusConflict, code: responseutil.HTTP_CONFLICT, reason: responseutil.ReasonConflict, detail: "cluster: busy is being deleted",
+			details: map[string]interface{}{"phase": "Deleting"}},
+		{path: "/v2/clusters/broken", status: http.StatusInternalServerError, code: responseutil.HTTP_ERROR, reason: responseutil.ReasonInternalError, detail: "get cluster error"},
+		{path: "/v2/unknown", status: http.StatusNotFound, code: responseutil.HTTP_NOT_FOUND, reason: responseutil.ReasonNotFound, detail: "router not found"},
+	}
+	for _, tt := range tests {
+		w := do(tt.path)
+		e := &responseutil.TypedError{}
+		if err := json.Unmarshal(w.Body.Bytes(), e); err != nil {
+			t.Errorf("%s: invalid typed error %s: %v", tt.path, w.Body.String(), err)
+			continue
+		}
+		if w.Code != tt.status || e.Success || e.Code != tt.code || e.Reason != tt.reason || e.Detail != tt.detail ||
+			e.Message != responseutil.GetRequestMsg(tt.code) || !reflect.DeepEqual(e.Details, tt.details) {
+			t.Errorf("%s: got %d %+v, want %d code: %d reason: %s detail: %q", tt.path, w.Code, e, tt.status, tt.code, tt.reason, tt.detail)
+		}
+	}
+
+	// the v1 errors are written by the handlers as they are, the raw aliases are untouched
+	w = do("/v1/getCluster?clusterName=broken")
+	want := `{"success":false,"code":500,"reason":"InternalError","message":"请求失败","detail":"get cluster error","data":null}`
+	if w.Code != http.StatusInternalServerError || w.Body.String() != want {
+		t.Errorf("v1 error: got %d %s", w.Code, w.Body.String())
+	}
+	w = do("/v2/clusters/c1/proxy/api")
+	if w.Code != http.StatusNotFound || w.Body.String() != `{"kind":"Status"}` {
+		t.Errorf("v2 proxy: got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,124 @@
+package apimanager
+
+import (
+	"context"
+	"crypto/tls"
+	"strings"
+	"time"
+
+	"github.com/gostship/kunkka/pkg/apimanager/router"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// tlsReloadInterval the interval the tls files are reloaded
+const tlsReloadInterval = time.Minute
+
+// TLSEnabled returns whether the apis are served over https.
+func (o *Option) TLSEnabled() bool {
+	return o.TLSSecret != "" || (o.TLSCertFile != "" && o.TLSKeyFile != "")
+}
+
+// setSecretCert sets the serving certificate of the kubernetes.io/tls Secret.
+func setSecretCert(reloader *router.CertReloader, secret *corev1.Secret) error {
+	changed, err := reloader.SetCertificate(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return errors.Wrapf(err, "secret: %s/%s", secret.Namespace, secret.Name)
+	}
+	if changed {
+		klog.Infof("serving certificate reloaded from secret: %s/%s, resourceVersion: %s", secret.Namespace, secret.Name, secret.ResourceVersion)
+	}
+	return nil
+}
+
+// newCertReloader loads the serving certificate and the client CAs, and adds the runnables to mgr
+// reloading them on change, the Secret is watched and the files are polled.
+func newCertReloader(mgr manager.Manager, kubeCli kubernetes.Interface, opt *Option) (*router.CertReloader, error) {
+	clientAuth, err := router.ClientAuthType(opt.TLSClientAuth)
+	if err != nil {
+		return nil, err
+	}
+	if opt.TLSClientCAFile == "" {
+		if clientAuth == tls.RequireAndVerifyClientCert {
+			return nil, errors.New("--tls-client-ca-file is required by the tls client auth")
+		}
+		clientAuth = tls.NoClientCert
+	}
+	reloader := router.NewCertReloader(clientAuth)
+
+	if opt.TLSClientCAFile != "" {
+		if err := reloader.LoadClientCAFile(opt.TLSClientCAFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if opt.TLSSecret == "" {
+		if err := reloader.LoadFiles(opt.TLSCertFile, opt.TLSKeyFile); err != nil {
+			return nil, err
+		}
+	} else {
+		parts := strings.SplitN(opt.TLSSecret, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.Errorf("tls secret: %q must be <namespace>/<name>", opt.TLSSecret)
+		}
+		namespace, name := parts[0], parts[1]
+
+		secret, err := kubeCli.CoreV1().Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "get tls secret: %s", opt.TLSSecret)
+		}
+		if err := setSecretCert(reloader, secret); err != nil {
+			return nil, err
+		}
+
+		lw := cache.NewListWatchFromClient(kubeCli.CoreV1().RESTClient(), "secrets", namespace, fields.OneTermEqualSelector("metadata.name", name))
+		reload := func(obj interface{}) {
+			if secret, ok := obj.(*corev1.Secret); ok {
+				if err := setSecretCert(reloader, secret); err != nil {
+					klog.Errorf("reload tls secret error, keep serving the current certificate: %v", err)
+				}
+			}
+		}
+		_, informer := cache.NewInformer(lw, &corev1.Secret{}, 0, cache.ResourceEventHandlerFuncs{
+			AddFunc:    reload,
+			UpdateFunc: func(_, obj interface{}) { reload(obj) },
+			DeleteFunc: func(interface{}) {
+				klog.Warningf("tls secret: %s is deleted, keep serving the current certificate", opt.TLSSecret)
+			},
+		})
+		err = mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+			informer.Run(stop)
+			return nil
+		}))
+		if err != nil {
+			return nil, errors.Wrapf(err, "add tls secret watcher")
+		}
+	}
+
+	err = mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		wait.Until(func() {
+			if opt.TLSSecret == "" {
+				if err := reloader.LoadFiles(opt.TLSCertFile, opt.TLSKeyFile); err != nil {
+					klog.Errorf("reload tls files error, keep serving the current certificate: %v", err)
+				}
+			}
+			if opt.TLSClientCAFile != "" {
+				if err := reloader.LoadClientCAFile(opt.TLSClientCAFile); err != nil {
+					klog.Errorf("reload client CA file error, keep the current CAs: %v", err)
+				}
+			}
+		}, tlsReloadInterval, stop)
+		return nil
+	}))
+	if err != nil {
+		return nil, errors.Wrapf(err, "add tls files watcher")
+	}
+	return reloader, nil
+}
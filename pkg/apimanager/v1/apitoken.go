@@ -0,0 +1,168 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/apitoken"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/apimanager/rbac"
+	"github.com/gostship/kunkka/pkg/util/authutil"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog"
+)
+
+// apiTokenUser returns the user managing the api tokens, the api tokens can't manage the tokens themselves.
+func apiTokenUser(c *gin.Context) (*authutil.User, bool) {
+	resp := responseutil.Gin{Ctx: c}
+	user, err := authutil.RequestUser(c)
+	if err != nil {
+		resp.RespErrorCode(http.StatusUnauthorized, responseutil.ERROR_AUTH_CHECK_TOKEN_FAIL, err.Error())
+		return nil, false
+	}
+	if user.Issuer == apitoken.Issuer {
+		resp.RespErrorCode(http.StatusForbidden, responseutil.HTTP_FORBIDDEN, "api tokens can't manage api tokens")
+		return nil, false
+	}
+	return user, true
+}
+
+// isPlatformAdmin returns whether the user is admin of all the clusters, who manages the tokens of all the users.
+func (m *Manager) isPlatformAdmin(ctx context.Context, user *authutil.User) bool {
+	if authorizeClaimed(user, rbac.RoleAdmin) != nil {
+		return false
+	}
+	bindings, err := m.roleBindings(ctx)
+	if err != nil {
+		klog.Errorf("get role bindings error: %v", err)
+		return false
+	}
+	return rbac.Authorize(bindings, &rbac.Subject{User: user.Name, Groups: user.Groups}, &rbac.Resource{}, rbac.RoleAdmin) == nil
+}
+
+// 创建 API token, 权限不能超过创建人自身的权限, token 明文只在创建时返回一次
+func (m *Manager) CreateAPIToken(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	ctx := context.Background()
+	user, ok := apiTokenUser(c)
+	if !ok {
+		return
+	}
+
+	req := &model.APITokenRequest{}
+	err := c.ShouldBindJSON(req)
+	if err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+	if err := req.Sanitize(); err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+	if !rbac.Role(req.Role).Valid() {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, "role: must be viewer, operator or admin")
+		return
+	}
+
+	if err := authorizeClaimed(user, rbac.Role(req.Role)); err != nil {
+		resp.RespErrorCode(http.StatusForbidden, responseutil.HTTP_FORBIDDEN, err.Error())
+		return
+	}
+
+	bindings, err := m.roleBindings(ctx)
+	if err != nil {
+		klog.Errorf("get role bindings error: %v", err)
+		resp.RespError("get role bindings error")
+		return
+	}
+	scope := &rbac.Binding{Role: rbac.Role(req.Role), Clusters: req.Clusters, Tenants: req.Tenants}
+	err = rbac.Grantable(bindings, &rbac.Subject{User: user.Name, Groups: user.Groups}, scope)
+	if err != nil {
+		resp.RespErrorCode(http.StatusForbidden, responseutil.HTTP_FORBIDDEN, err.Error())
+		return
+	}
+
+	t := &model.APIToken{
+		Name:        req.Name,
+		Description: req.Description,
+		Owner:       user.Name,
+		Role:        req.Role,
+		Clusters:    req.Clusters,
+		Tenants:     req.Tenants,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if req.TTLSeconds > 0 {
+		expiresAt := t.CreatedAt.Add(time.Duration(req.TTLSeconds) * time.Second)
+		t.ExpiresAt = &expiresAt
+	}
+
+	t.Token, err = m.Tokens.Create(ctx, t)
+	if err != nil {
+		klog.Errorf("create api token: %s of user: %s error: %v", t.Name, user.Name, err)
+		resp.RespError("create api token error")
+		return
+	}
+	klog.Infof("api token: %s(%s) created by user: %s, role: %s, clusters: %v, tenants: %v, source: %s",
+		t.ID, t.Name, user.Name, t.Role, t.Clusters, t.Tenants, c.ClientIP())
+	resp.RespSuccess(true, "success", t, 1)
+}
+
+// 查询当前用户的 API token, 平台管理员查询全部
+func (m *Manager) ListAPIToken(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	ctx := context.Background()
+	user, ok := apiTokenUser(c)
+	if !ok {
+		return
+	}
+
+	owner := user.Name
+	if m.isPlatformAdmin(ctx, user) {
+		owner = ""
+	}
+	list, err := m.Tokens.List(ctx, owner)
+	if err != nil {
+		klog.Errorf("list api tokens error: %v", err)
+		resp.RespError("list api tokens error")
+		return
+	}
+	resp.RespSuccess(true, "success", list, len(list))
+}
+
+// 吊销 API token, 只有创建人及平台管理员可以吊销
+func (m *Manager) RevokeAPIToken(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	ctx := context.Background()
+	user, ok := apiTokenUser(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	t, _, err := m.Tokens.Get(ctx, id)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, "api token not found")
+			return
+		}
+		klog.Errorf("get api token: %s error: %v", id, err)
+		resp.RespError("get api token error")
+		return
+	}
+	if t.Owner != user.Name && !m.isPlatformAdmin(ctx, user) {
+		resp.RespErrorCode(http.StatusForbidden, responseutil.HTTP_FORBIDDEN, "only the owner or the platform admins can revoke the api token")
+		return
+	}
+
+	err = m.Tokens.Revoke(ctx, id)
+	if err != nil {
+		klog.Errorf("revoke api token: %s error: %v", id, err)
+		resp.RespError("revoke api token error")
+		return
+	}
+	klog.Infof("api token: %s(%s) of user: %s revoked by user: %s, source: %s", t.ID, t.Name, t.Owner, user.Name, c.ClientIP())
+	resp.RespSuccess(true, "success", "OK", 0)
+}
@@ -0,0 +1,103 @@
+package v1
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/router"
+	"k8s.io/klog"
+)
+
+// V2Prefix the prefix of the v2 apis, their errors are the typed errors of responseutil.TypedError
+const V2Prefix = "/apis/v2"
+
+// V2Aliases the RESTful v2 routes served by the handlers of the v1 routes, the cluster is always the name param.
+// The v1 routes keep working and answer with the Deprecation header and the Link to their v2 routes.
+var V2Aliases = []*router.Alias{
+	{Path: V2Prefix + "/clusters", Route: "GET /apis/cluster/getMemberList"},
+	{Path: V2Prefix + "/clusters", Route: "POST /apis/cluster/addCluster"},
+	{Path: V2Prefix + "/clusters/:name", Route: "GET /apis/cluster/getClusterDetail", Query: map[string]string{"name": "name"}},
+	{Path: V2Prefix + "/clusters/:name", Route: "DELETE /apis/cluster/klusters/:name"},
+	{Path: V2Prefix + "/clusters/:name", Route: "PATCH /apis/cluster/klusters/:name"},
+	{Path: V2Prefix + "/clusters/:name/deletion", Route: "POST /apis/cluster/klusters/:name/deletion"},
+	{Path: V2Prefix + "/clusters/:name/deletion", Route: "GET /apis/cluster/klusters/:name/deletion"},
+	{Path: V2Prefix + "/clusters/:name/conditions", Route: "GET /apis/cluster/getClusterCondition", Query: map[string]string{"name": "clusterName"}},
+	{Path: V2Prefix + "/clusters/:name/counts", Route: "GET /apis/cluster/getClusterCounts", Query: map[string]string{"name": "clusterName"}},
+	{Path: V2Prefix + "/clusters/:name/meta", Route: "GET /apis/cluster/getMemberMeta", Query: map[string]string{"name": "clusterName"}},
+	{Path: V2Prefix + "/clusters/:name/waits", Route: "GET /apis/cluster/waits", Query: map[string]string{"name": "name"}},
+	{Path: V2Prefix + "/clusters/:name/progress", Route: "GET /apis/cluster/klusters/:name/progress"},
+	{Path: V2Prefix + "/clusters/:name/events", Route: "GET /apis/cluster/klusters/:name/events"},
+	{Path: V2Prefix + "/clusters/:name/events/aggregated", Route: "GET /apis/cluster/klusters/:name/events/aggregated"},
+	{Path: V2Prefix + "/clusters/:name/components", Route: "GET /apis/cluster/klusters/:name/components"},
+	{Path: V2Prefix + "/clusters/:name/components/:component", Route: "GET /apis/cluster/klusters/:name/components/:component"},
+	{Path: V2Prefix + "/clusters/:name/componenthealth", Route: "GET /apis/cluster/klusters/:name/componenthealth"},
+	{Path: V2Prefix + "/clusters/:name/certs", Route: "GET /apis/cluster/klusters/:name/certs"},
+	{Path: V2Prefix + "/clusters/:name/trends", Route: "GET /apis/cluster/klusters/:name/trends"},
+	{Path: V2Prefix + "/clusters/:name/usage", Route: "GET /apis/cluster/klusters/:name/usage"},
+	{Path: V2Prefix + "/clusters/:name/kubeconfig", Route: "GET /apis/cluster/klusters/:name/kubeconfig"},
+	{Path: V2Prefix + "/clusters/:name/machines/notready", Route: "GET /apis/cluster/getNoreadyNode", Query: map[string]string{"name": "clusterName"}},
+	{Path: V2Prefix + "/clusters/:name/machines/drifted", Route: "GET /apis/cluster/getDriftNode", Query: map[string]string{"name": "clusterName"}},
+	{Path: V2Prefix + "/clusters/:name/nodes", Route: "GET /apis/cluster/klusters/:name/nodes"},
+	{Path: V2Prefix + "/clusters/:name/nodes", Route: "POST /apis/cluster/klusters/:name/nodes"},
+	{Path: V2Prefix + "/clusters/:name/nodes/:node", Route: "GET /apis/cluster/klusters/:name/nodes/:node/inventory"},
+	{Path: V2Prefix + "/clusters/:name/nodes/:node", Route: "DELETE /apis/cluster/klusters/:name/nodes/:node"},
+	{Path: V2Prefix + "/clusters/:name/nodes/:node/removal", Route: "GET /apis/cluster/klusters/:name/nodes/:node/removal"},
+	{Path: V2Prefix + "/clusters/:name/nodes/:node/marks", Route: "POST /apis/cluster/klusters/:name/nodes/:node/marks"},
+	{Path: V2Prefix + "/clusters/:name/nodes/:node/pods", Route: "GET /apis/cluster/klusters/:name/pods", Query: map[string]string{"node": "nodeName"}},
+	{Path: V2Prefix + "/clusters/:name/namespaces", Route: "GET /apis/cluster/resource/klusters/:name/namespaces"},
+	{Path: V2Prefix + "/clusters/:name/namespaces/:namespace", Route: "GET /apis/cluster/klusters/:name/namespaces/:namespace"},
+	{Path: V2Prefix + "/clusters/:name/namespaces/:namespace/events", Route: "GET /apis/cluster/klusters/:name/namespaces/:namespace/events"},
+	{Path: V2Prefix + "/clusters/:name/namespaces/:namespace/pods", Route: "GET /apis/cluster/klusters/:name/namespaces/:namespace/pods"},
+	{Path: V2Prefix + "/clusters/:name/namespaces/:namespace/pods/:pod", Route: "GET /apis/cluster/klusters/:name/namespaces/:namespace/pods/:pod"},
+	{Path: V2Prefix + "/clusters/:name/namespaces/:namespace/pods/:pod/log", Route: "GET /apis/cluster/klusters/:name/namespaces/:namespace/pods/:pod/log"},
+	{Path: V2Prefix + "/clusters/:name/namespaces/:namespace/pods/:pod/logs", Route: "GET /apis/cluster/klusters/:name/namespaces/:namespace/pods/:pod/logs"},
+	{Path: V2Prefix + "/clusters/:name/namespaces/:namespace/pods/:pod/exec", Route: "GET /apis/cluster/klusters/:name/namespaces/:namespace/pods/:pod/exec"},
+	{Path: V2Prefix + "/clusters/:name/proxy/*path", Route: "Any /apis/cluster/klusters/:name/proxy/*path", Raw: true},
+	{Path: V2Prefix + "/namespaces", Route: "POST /apis/cluster/namespaces"},
+	{Path: V2Prefix + "/namespaces", Route: "GET /apis/cluster/namespaces"},
+	{Path: V2Prefix + "/namespaces/:namespace", Route: "DELETE /apis/cluster/namespaces/:namespace"},
+	{Path: V2Prefix + "/namespaces/:namespace/clusters/:name", Route: "GET /apis/cluster/clusters/:namespace/:name"},
+	{Path: V2Prefix + "/namespaces/:namespace/clusters/:name/conditions", Route: "GET /apis/cluster/clusters/:namespace/:name/conditions"},
+	{Path: V2Prefix + "/namespaces/:namespace/clusters/:name/machines", Route: "GET /apis/cluster/clusters/:namespace/:name/machines"},
+	{Path: V2Prefix + "/namespaces/:namespace/clusters/:name/machines/:machine", Route: "GET /apis/cluster/clusters/:namespace/:name/machines/:machine"},
+	{Path: V2Prefix + "/namespaces/:namespace/clusters/:name/machines/:machine/conditions", Route: "GET /apis/cluster/clusters/:namespace/:name/machines/:machine/conditions"},
+	{Path: V2Prefix + "/workloads", Route: "GET /apis/cluster/workloads"},
+	{Path: V2Prefix + "/search", Route: "GET /search"},
+}
+
+// v2Routes returns the routes of V2Aliases served by the handlers of their v1 routes, marking the v1 routes deprecated.
+func v2Routes(routes []*router.Route) []*router.Route {
+	byKey := make(map[string]*router.Route, len(routes))
+	for _, r := range routes {
+		byKey[r.Method+" "+r.Path] = r
+	}
+
+	v2 := make([]*router.Route, 0, len(V2Aliases))
+	for _, a := range V2Aliases {
+		r, ok := byKey[a.Route]
+		if !ok {
+			klog.Warningf("no v1 route: %s of v2 route: %s", a.Route, a.Key())
+			continue
+		}
+		r.Deprecated = true
+		v2 = append(v2, &router.Route{
+			Method:   r.Method,
+			Path:     a.Path,
+			Handler:  r.Handler,
+			Desc:     r.Desc,
+			Request:  r.Request,
+			Response: r.Response,
+		})
+	}
+	return v2
+}
+
+// requestRoute returns the "<METHOD> <path>" of the v1 route of the request, the "Any" routes included.
+func requestRoute(c *gin.Context, routes map[string]bool) string {
+	route := router.RouteKey(c)
+	path := route[strings.Index(route, " ")+1:]
+	if routes["Any "+path] {
+		return "Any " + path
+	}
+	return route
+}
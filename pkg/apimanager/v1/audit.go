@@ -0,0 +1,120 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/auditlog"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/util/authutil"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	"github.com/gostship/kunkka/pkg/util/uidutil"
+	"k8s.io/klog"
+)
+
+// auditedReads the GET routes handing out credentials or shells, audited as the mutating ones
+var auditedReads = map[string]bool{
+	"GET /apis/cluster/klusters/:name/users/:user/kubeconfig":               true,
+	"GET /apis/cluster/klusters/:name/kubeconfig":                           true,
+	"GET /apis/cluster/klusters/:name/users/:user/kubectl":                  true,
+	"GET /apis/clusters/:name/namespaces/:namespace/pods/:pod":              true,
+	"GET /apis/cluster/klusters/:name/namespaces/:namespace/pods/:pod/exec": true,
+	"GET /apis/cluster/breakglass/:id/credential":                           true,
+	"GET /apis/cluster/klusters/:name/secrets":                              true,
+}
+
+// auditDetailKey the context key of the detail of the audit event set by the handlers
+const auditDetailKey = "kunkka/audit-detail"
+
+// auditDetail sets the detail of the audit event of the request.
+func auditDetail(c *gin.Context, format string, args ...interface{}) {
+	c.Set(auditDetailKey, fmt.Sprintf(format, args...))
+}
+
+// AuditTrail records the mutating and the credential requests of the routes served by the manager
+// with the user, payload hash and result, the denied ones included.
+func (m *Manager) AuditTrail() gin.HandlerFunc {
+	routes := map[string]bool{}
+	for _, r := range m.Routes() {
+		routes[r.Method+" "+r.Path] = true
+	}
+
+	return func(c *gin.Context) {
+		route := requestRoute(c, routes)
+		if m.Audit == nil || !routes[route] || (c.Request.Method == http.MethodGet && !auditedReads[route]) {
+			c.Next()
+			return
+		}
+
+		e := &model.AuditEvent{
+			ID:     uidutil.GenerateId(),
+			Time:   time.Now().UTC(),
+			Source: c.ClientIP(),
+			Method: c.Request.Method,
+			Path:   c.Request.URL.Path,
+			Route:  route,
+		}
+		if user, err := authutil.RequestUser(c); err == nil {
+			e.User = user.Name
+			e.Groups = user.Groups
+		}
+		if c.Request.Body != nil {
+			data, err := ioutil.ReadAll(c.Request.Body)
+			if err == nil && len(data) > 0 {
+				sum := sha256.Sum256(data)
+				e.PayloadHash = hex.EncodeToString(sum[:])
+			}
+			c.Request.Body = ioutil.NopCloser(bytes.NewReader(data))
+		}
+		e.Cluster, _ = requestCluster(c)
+
+		c.Next()
+
+		e.Status = c.Writer.Status()
+		e.Result = auditlog.Result(e.Status)
+		e.LatencyMs = time.Since(e.Time).Milliseconds()
+		e.Detail = c.GetString(auditDetailKey)
+		if err := m.Audit.Record(context.Background(), e); err != nil {
+			klog.Errorf("record audit event: %s of user: %s %s %s error: %v", e.ID, e.User, e.Method, e.Path, err)
+		}
+	}
+}
+
+// 查询审计记录, 可按日期(UTC, 如 2020-10-01)、集群及用户过滤, 最新的在前
+func (m *Manager) GetAuditEvents(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	if m.Audit == nil {
+		resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, "audit trail is not enabled")
+		return
+	}
+
+	q := &auditlog.Query{
+		Date:    c.Query("date"),
+		Cluster: c.Query("cluster"),
+		User:    c.Query("user"),
+	}
+	if s := c.Query("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit <= 0 || limit > 1000 {
+			resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, "limit: must be 1-1000")
+			return
+		}
+		q.Limit = limit
+	}
+
+	events, err := m.Audit.Query(context.Background(), q)
+	if err != nil {
+		klog.Errorf("query audit events error: %v", err)
+		resp.RespError("query audit events error")
+		return
+	}
+	resp.RespSuccess(true, "success", events, len(events))
+}
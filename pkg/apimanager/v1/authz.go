@@ -0,0 +1,296 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/apitoken"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/apimanager/rbac"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/util/authutil"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+)
+
+// RoleBindingConfigMapName the configmap of the role bindings registered by the platform admins
+const RoleBindingConfigMapName = "role-bindings"
+
+// routeRoles the roles of the routes other than viewer for GET and operator for the others
+var routeRoles = map[string]rbac.Role{
+	"GET /audit":                                                            rbac.RoleAdmin,
+	"POST /apis/cluster/addCluster":                                         rbac.RoleAdmin,
+	"POST /apis/cluster/addRackCidr":                                        rbac.RoleAdmin,
+	"POST /apis/cluster/updateRackCidr":                                     rbac.RoleAdmin,
+	"DELETE /apis/cluster/delRackCidr":                                      rbac.RoleAdmin,
+	"POST /apis/cluster/breakglass":                                         rbac.RoleAdmin,
+	"POST /apis/cluster/breakglass/:id/approve":                             rbac.RoleAdmin,
+	"POST /apis/cluster/expansions/:id/approve":                             rbac.RoleAdmin,
+	"POST /apis/cluster/expansions/:id/reject":                              rbac.RoleAdmin,
+	"GET /apis/cluster/klusters/:name/users/:user/kubeconfig":               rbac.RoleAdmin,
+	"GET /apis/cluster/klusters/:name/kubeconfig":                           rbac.RoleAdmin,
+	"POST /apis/cluster/klusters/:name/kubeconfig/regenerate":               rbac.RoleAdmin,
+	"POST /apis/cluster/klusters/:name/sshkeys/rotations":                   rbac.RoleAdmin,
+	"POST /apis/cluster/klusters/:name/deletion":                            rbac.RoleAdmin,
+	"DELETE /apis/cluster/klusters/:name":                                   rbac.RoleAdmin,
+	"PATCH /apis/cluster/klusters/:name":                                    rbac.RoleAdmin,
+	"DELETE /apis/cluster/klusters/:name/nodes/:node":                       rbac.RoleAdmin,
+	"GET /apis/cluster/klusters/:name/secrets":                              rbac.RoleOperator,
+	"GET /apis/cluster/klusters/:name/users/:user/kubectl":                  rbac.RoleOperator,
+	"GET /apis/clusters/:name/namespaces/:namespace/pods/:pod":              rbac.RoleOperator,
+	"GET /apis/cluster/klusters/:name/namespaces/:namespace/pods/:pod/exec": rbac.RoleOperator,
+}
+
+// anyUserRoutes the routes of every authenticated user
+var anyUserRoutes = map[string]bool{
+	"GET /oauth/authorize":               true,
+	"GET /capabilities":                  true,
+	"GET /openapi.json":                  true,
+	"GET /swagger-ui":                    true,
+	"GET /apis/cluster/configs/oauth":    true,
+	"GET /apis/cluster/users/:username":  true,
+	"POST /apis/cluster/apitokens":       true,
+	"GET /apis/cluster/apitokens":        true,
+	"DELETE /apis/cluster/apitokens/:id": true,
+}
+
+// claimedRole the highest role of the users only claiming their names, see authutil.User.Claimed,
+// anyone knowing the shared login password may claim the name of a platform admin
+const claimedRole = rbac.RoleViewer
+
+// namespacedClusterRoutes the routes of the clusters by their namespaces and names, the clusters of the other
+// routes are in the namespaces of their names
+var namespacedClusterRoutes = map[string]bool{
+	"GET /apis/cluster/clusters/:namespace/:name":                              true,
+	"GET /apis/cluster/clusters/:namespace/:name/conditions":                   true,
+	"GET /apis/cluster/clusters/:namespace/:name/machines":                     true,
+	"GET /apis/cluster/clusters/:namespace/:name/machines/:machine":            true,
+	"GET /apis/cluster/clusters/:namespace/:name/machines/:machine/conditions": true,
+}
+
+// multiClusterRoutes the routes of many clusters at once, the handlers authorize each cluster by authorizeClusters
+var multiClusterRoutes = map[string]bool{
+	"POST /apis/cluster/namespaces":              true,
+	"GET /apis/cluster/namespaces":               true,
+	"DELETE /apis/cluster/namespaces/:namespace": true,
+	"GET /apis/cluster/workloads":                true,
+	"GET /search":                                true,
+}
+
+// Authorize rejects the requests of the users without the role of the route on the cluster of the request,
+// the routes not served by the manager are skipped.
+func (m *Manager) Authorize() gin.HandlerFunc {
+	routes := map[string]bool{}
+	for _, r := range m.Routes() {
+		routes[r.Method+" "+r.Path] = true
+	}
+
+	return func(c *gin.Context) {
+		route := requestRoute(c, routes)
+		if !routes[route] || anyUserRoutes[route] || multiClusterRoutes[route] {
+			c.Next()
+			return
+		}
+
+		resp := responseutil.Gin{Ctx: c}
+		user, err := authutil.RequestUser(c)
+		if err != nil {
+			resp.RespErrorCode(http.StatusUnauthorized, responseutil.ERROR_AUTH_CHECK_TOKEN_FAIL, err.Error())
+			return
+		}
+
+		role, ok := routeRoles[route]
+		if !ok {
+			role = rbac.RoleOperator
+			if c.Request.Method == http.MethodGet {
+				role = rbac.RoleViewer
+			}
+		}
+
+		err = m.authorize(c, route, user, role)
+		if err != nil {
+			klog.Infof("authz: reject %s %s of user: %s from %s, err: %v", c.Request.Method, c.Request.URL.Path, user.Name, c.ClientIP(), err)
+			resp.RespErrorCode(http.StatusForbidden, responseutil.HTTP_FORBIDDEN, err.Error())
+			return
+		}
+		c.Next()
+	}
+}
+
+func (m *Manager) authorize(c *gin.Context, route string, user *authutil.User, role rbac.Role) error {
+	if namespacedClusterRoutes[route] {
+		return m.authorizeNamespacedCluster(user, types.NamespacedName{Namespace: c.Param("namespace"), Name: c.Param("name")}, role)
+	}
+	cluster, err := requestCluster(c)
+	if err != nil {
+		return err
+	}
+	return m.authorizeCluster(user, cluster, role)
+}
+
+// authorizeClusters authorizes the user of the request with the role on each of the clusters,
+// it responds 401 or 403 and returns false once one is rejected.
+func (m *Manager) authorizeClusters(c *gin.Context, clusters []string, role rbac.Role) bool {
+	resp := responseutil.Gin{Ctx: c}
+	user, err := authutil.RequestUser(c)
+	if err != nil {
+		resp.RespErrorCode(http.StatusUnauthorized, responseutil.ERROR_AUTH_CHECK_TOKEN_FAIL, err.Error())
+		return false
+	}
+	for _, cluster := range clusters {
+		if err := m.authorizeCluster(user, cluster, role); err != nil {
+			klog.Infof("authz: reject %s %s of user: %s on cluster: %s from %s, err: %v", c.Request.Method, c.Request.URL.Path, user.Name, cluster, c.ClientIP(), err)
+			resp.RespErrorCode(http.StatusForbidden, responseutil.HTTP_FORBIDDEN, err.Error())
+			return false
+		}
+	}
+	return true
+}
+
+// requestClusters returns the comma separated clusters of the query authorized by authorizeClusters,
+// or all the clusters the user has the role on when not specified. It responds and returns false once rejected.
+func (m *Manager) requestClusters(c *gin.Context, role rbac.Role) ([]string, bool) {
+	resp := responseutil.Gin{Ctx: c}
+	clusters := splitQuery(c.Query("clusters"))
+	if len(clusters) > 0 {
+		clusters, err := model.SanitizeClusters(clusters)
+		if err != nil {
+			resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+			return nil, false
+		}
+		return clusters, m.authorizeClusters(c, clusters, role)
+	}
+
+	user, err := authutil.RequestUser(c)
+	if err != nil {
+		resp.RespErrorCode(http.StatusUnauthorized, responseutil.ERROR_AUTH_CHECK_TOKEN_FAIL, err.Error())
+		return nil, false
+	}
+	for _, cls := range m.Cluster.GetAll() {
+		if m.authorizeCluster(user, cls.Name, role) == nil {
+			clusters = append(clusters, cls.Name)
+		}
+	}
+	return clusters, true
+}
+
+// authorizeClaimed rejects the roles above claimedRole of the users only claiming their names.
+func authorizeClaimed(user *authutil.User, role rbac.Role) error {
+	if user.Claimed() && !claimedRole.Includes(role) {
+		return errors.Errorf("role %s requires a token bound to the user identity, user %s logged in with the shared password", role, user.Name)
+	}
+	return nil
+}
+
+// authorizeCluster authorizes the user with the role on the cluster in the namespace of its name, or on the
+// platform if the cluster is empty.
+func (m *Manager) authorizeCluster(user *authutil.User, cluster string, role rbac.Role) error {
+	return m.authorizeNamespacedCluster(user, types.NamespacedName{Namespace: cluster, Name: cluster}, role)
+}
+
+// authorizeNamespacedCluster authorizes the user with the role on the cluster, the tenant is of the Cluster
+// of the namespace and name.
+func (m *Manager) authorizeNamespacedCluster(user *authutil.User, key types.NamespacedName, role rbac.Role) error {
+	if err := authorizeClaimed(user, role); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	sub := &rbac.Subject{User: user.Name, Groups: user.Groups}
+	// the api tokens are limited to their own scope
+	bindings := []*rbac.Binding{apitoken.Binding(user)}
+	if bindings[0] == nil {
+		var err error
+		bindings, err = m.roleBindings(ctx)
+		if err != nil {
+			klog.Errorf("authz: get role bindings err: %v", err)
+			return errors.New("get role bindings error")
+		}
+	}
+
+	// the platform wide lists are readable by anyone bound to a cluster
+	if key.Name == "" && role == rbac.RoleViewer && rbac.Viewable(bindings, sub) {
+		return nil
+	}
+
+	res := &rbac.Resource{Cluster: rbac.ClusterName(key.Namespace, key.Name)}
+	if key.Name != "" {
+		cls := &devopsv1.Cluster{}
+		err := m.Cluster.GetClient().Get(ctx, key, cls)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "get cluster: %s", key)
+		}
+		res.Tenant = cls.Spec.TenantID
+	}
+	return rbac.Authorize(bindings, sub, res, role)
+}
+
+// roleBindings returns the bindings of the configmap, the platform admins are bound to admin of all the clusters.
+func (m *Manager) roleBindings(ctx context.Context) ([]*rbac.Binding, error) {
+	bindings := []*rbac.Binding{}
+	if len(m.PlatformAdmins) > 0 {
+		bindings = append(bindings, &rbac.Binding{
+			Name:     "platform-admins",
+			Role:     rbac.RoleAdmin,
+			Users:    m.PlatformAdmins,
+			Clusters: []string{rbac.AllClusters},
+		})
+	}
+
+	cm := &corev1.ConfigMap{}
+	err := m.Cluster.GetClient().Get(ctx, types.NamespacedName{Namespace: ConfigMapName, Name: RoleBindingConfigMapName}, cm)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return bindings, nil
+		}
+		return nil, err
+	}
+
+	registered, err := rbac.Parse(cm.Data[rbac.DataKey])
+	if err != nil {
+		return nil, err
+	}
+	return append(bindings, registered...), nil
+}
+
+// requestCluster returns the cluster of the request from the path, query or json body, empty for the platform wide requests.
+func requestCluster(c *gin.Context) (string, error) {
+	if name := c.Param("name"); name != "" {
+		return name, nil
+	}
+	for _, key := range []string{"clusterName", "cluster", "name"} {
+		if v := c.Query(key); v != "" && v != "all" {
+			return v, nil
+		}
+	}
+
+	if c.Request.Body == nil || c.Request.Method == http.MethodGet {
+		return "", nil
+	}
+	data, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		return "", errors.Wrapf(err, "read body")
+	}
+	// the handlers bind the body again
+	c.Request.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+	body := &struct {
+		ClusterName string `json:"clusterName"`
+		Cluster     string `json:"cluster"`
+	}{}
+	if len(data) == 0 || json.Unmarshal(data, body) != nil {
+		return "", nil
+	}
+	if body.ClusterName != "" {
+		return body.ClusterName, nil
+	}
+	return body.Cluster, nil
+}
@@ -0,0 +1,124 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/rbac"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/k8smanager"
+	"github.com/gostship/kunkka/pkg/util/authutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// fakeManager serves the client of the master cluster only
+type fakeManager struct {
+	manager.Manager
+	client client.Client
+}
+
+func (m *fakeManager) GetClient() client.Client {
+	return m.client
+}
+
+func TestAuthorizeClaimed(t *testing.T) {
+	claimed := &authutil.User{Name: "admin", Issuer: authutil.DefaultIssuerName}
+	oidc := &authutil.User{Name: "admin", Issuer: "https://dex.example.com"}
+	tests := []struct {
+		name    string
+		user    *authutil.User
+		role    rbac.Role
+		wantErr bool
+	}{
+		{name: "claimed viewer", user: claimed, role: rbac.RoleViewer},
+		{name: "claimed operator", user: claimed, role: rbac.RoleOperator, wantErr: true},
+		{name: "claimed admin", user: claimed, role: rbac.RoleAdmin, wantErr: true},
+		{name: "oidc admin", user: oidc, role: rbac.RoleAdmin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := authorizeClaimed(tt.user, tt.role); (err != nil) != tt.wantErr {
+				t.Errorf("authorizeClaimed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// rejected before the bindings are looked up, the platform admins are bound by name
+	m := &Manager{PlatformAdmins: []string{"admin"}}
+	if err := m.authorizeCluster(claimed, "demo", rbac.RoleAdmin); err == nil {
+		t.Error("authorizeCluster() of the claimed platform admin = nil, want error")
+	}
+	if m.isPlatformAdmin(context.Background(), claimed) {
+		t.Error("isPlatformAdmin() of the claimed platform admin = true, want false")
+	}
+}
+
+func TestAuthorizeNamespacedCluster(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	devopsv1.AddToScheme(scheme)
+	bindings := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ConfigMapName, Name: RoleBindingConfigMapName},
+		Data: map[string]string{rbac.DataKey: `
+- name: foo-viewers
+  role: viewer
+  users: ["alice"]
+  clusters: ["ns-a/foo"]
+- name: tenant1-viewers
+  role: viewer
+  users: ["bob"]
+  tenants: ["tenant1"]
+`},
+	}
+	objs := []runtime.Object{bindings}
+	for _, ns := range []string{"ns-a", "ns-b"} {
+		objs = append(objs, &devopsv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "foo"},
+			Spec:       devopsv1.ClusterSpec{TenantID: map[string]string{"ns-a": "tenant1", "ns-b": "tenant2"}[ns]},
+		})
+	}
+	m := &Manager{Cluster: &k8smanager.ClusterManager{
+		MasterClient: k8smanager.MasterClient{Manager: &fakeManager{client: fake.NewFakeClientWithScheme(scheme, objs...)}},
+	}}
+	handler := m.Authorize()
+
+	tests := []struct {
+		name     string
+		user     string
+		path     string
+		wantCode int
+	}{
+		{name: "bound cluster", user: "alice", path: "/apis/cluster/clusters/ns-a/foo", wantCode: http.StatusOK},
+		{name: "bound cluster machines", user: "alice", path: "/apis/cluster/clusters/ns-a/foo/machines", wantCode: http.StatusOK},
+		{name: "namesake in another namespace", user: "alice", path: "/apis/cluster/clusters/ns-b/foo", wantCode: http.StatusForbidden},
+		{name: "machines of the namesake", user: "alice", path: "/apis/cluster/clusters/ns-b/foo/machines/10.0.0.1", wantCode: http.StatusForbidden},
+		{name: "tenant of the served cluster", user: "bob", path: "/apis/cluster/clusters/ns-a/foo", wantCode: http.StatusOK},
+		{name: "tenant of the namesake", user: "bob", path: "/apis/cluster/clusters/ns-b/foo/conditions", wantCode: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			_, r := gin.CreateTestContext(w)
+			r.Use(func(c *gin.Context) {
+				authutil.SetUser(c, &authutil.User{Name: tt.user, Issuer: "https://dex.example.com"})
+			}, handler)
+			for route := range namespacedClusterRoutes {
+				r.GET(strings.TrimPrefix(route, "GET "), func(c *gin.Context) { c.Status(http.StatusOK) })
+			}
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.wantCode {
+				t.Errorf("GET %s code = %d, want %d", tt.path, w.Code, tt.wantCode)
+			}
+		})
+	}
+}
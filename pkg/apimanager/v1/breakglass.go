@@ -0,0 +1,306 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/escrow"
+	"github.com/gostship/kunkka/pkg/util/authutil"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	"github.com/gostship/kunkka/pkg/util/uidutil"
+	"github.com/gostship/kunkka/pkg/util/validation"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// BreakGlassTTL 批准后可取回凭证的时间窗口
+	BreakGlassTTL = time.Hour
+
+	breakGlassDataKey = "request.json"
+)
+
+func breakGlassName(id string) string {
+	return fmt.Sprintf("breakglass-%s", id)
+}
+
+// breakGlassUser returns the user of the bearer token, the break glass api is always audited by user.
+func breakGlassUser(c *gin.Context) (string, bool) {
+	user, err := authutil.RequestUser(c)
+	if err != nil {
+		klog.Warningf("break-glass audit: reject %s %s from %s, err: %v", c.Request.Method, c.Request.URL.Path, c.ClientIP(), err)
+		resp := responseutil.Gin{Ctx: c}
+		resp.RespErrorCode(http.StatusUnauthorized, responseutil.ERROR_AUTH_CHECK_TOKEN_FAIL, err.Error())
+		return "", false
+	}
+	return user.Name, true
+}
+
+func (m *Manager) audit(c *gin.Context, r *model.BreakGlassRequest, user, action string) {
+	r.Audit = append(r.Audit, &model.BreakGlassAudit{
+		Time:   time.Now().UTC(),
+		User:   user,
+		Action: action,
+		Source: c.ClientIP(),
+	})
+	klog.Infof("break-glass audit: id: %s, cluster: %s, user: %s, action: %s, source: %s", r.ID, r.Cluster, user, action, c.ClientIP())
+}
+
+func (m *Manager) getBreakGlass(ctx context.Context, id string) (*corev1.ConfigMap, *model.BreakGlassRequest, error) {
+	cm := &corev1.ConfigMap{}
+	err := m.Cluster.GetClient().Get(ctx, types.NamespacedName{Namespace: m.EscrowNamespace, Name: breakGlassName(id)}, cm)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r := &model.BreakGlassRequest{}
+	err = json.Unmarshal([]byte(cm.Data[breakGlassDataKey]), r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if r.Status == model.BreakGlassApproved && r.ExpiresAt != nil && time.Now().After(*r.ExpiresAt) {
+		r.Status = model.BreakGlassExpired
+	}
+	return cm, r, nil
+}
+
+func (m *Manager) saveBreakGlass(ctx context.Context, cm *corev1.ConfigMap, r *model.BreakGlassRequest) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	cm.Data = map[string]string{breakGlassDataKey: string(data)}
+	return m.Cluster.GetClient().Update(ctx, cm)
+}
+
+// 创建紧急访问申请
+func (m *Manager) CreateBreakGlass(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	user, ok := breakGlassUser(c)
+	if !ok {
+		return
+	}
+
+	r := &model.BreakGlassRequest{}
+	if _, err := resp.Bind(r); err != nil {
+		klog.Errorf("Http Bind BreakGlassRequest error: %v", err)
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_REQUEST_BIND_ERROR, err.Error())
+		return
+	}
+	if err := r.Sanitize(); err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	s := &corev1.Secret{}
+	err := m.Cluster.GetClient().Get(ctx, types.NamespacedName{Namespace: m.EscrowNamespace, Name: escrow.SecretName(r.Cluster)}, s)
+	if err != nil {
+		klog.Errorf("get cluster: %s escrow error: %v", r.Cluster, err)
+		resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("cluster: %s has no escrowed credential", r.Cluster))
+		return
+	}
+
+	r.ID = uidutil.GenerateId()
+	r.Requester = user
+	r.Approver = ""
+	r.Status = model.BreakGlassPending
+	r.CreatedAt = time.Now().UTC()
+	r.ApprovedAt = nil
+	r.ExpiresAt = nil
+	r.Audit = nil
+	m.audit(c, r, user, "create")
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		resp.RespError(err.Error())
+		return
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      breakGlassName(r.ID),
+			Namespace: m.EscrowNamespace,
+			Labels: map[string]string{
+				constants.BreakGlassLabel:         "true",
+				constants.CredentialEscrowCluster: r.Cluster,
+			},
+		},
+		Data: map[string]string{breakGlassDataKey: string(data)},
+	}
+	err = m.Cluster.GetClient().Create(ctx, cm)
+	if err != nil {
+		klog.Errorf("create break glass request error: %v", err)
+		resp.RespError("create break glass request error")
+		return
+	}
+
+	resp.RespSuccess(true, "success", r, 1)
+}
+
+// 紧急访问申请列表
+func (m *Manager) ListBreakGlass(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	if _, ok := breakGlassUser(c); !ok {
+		return
+	}
+
+	cms := &corev1.ConfigMapList{}
+	err := m.Cluster.GetClient().List(context.Background(), cms,
+		client.InNamespace(m.EscrowNamespace), client.MatchingLabels{constants.BreakGlassLabel: "true"})
+	if err != nil {
+		klog.Errorf("list break glass request error: %v", err)
+		resp.RespError("list break glass request error")
+		return
+	}
+
+	list := make([]*model.BreakGlassRequest, 0, len(cms.Items))
+	for i := range cms.Items {
+		r := &model.BreakGlassRequest{}
+		if err := json.Unmarshal([]byte(cms.Items[i].Data[breakGlassDataKey]), r); err != nil {
+			klog.Warningf("decode break glass request: %s error: %v", cms.Items[i].Name, err)
+			continue
+		}
+		if r.Status == model.BreakGlassApproved && r.ExpiresAt != nil && time.Now().After(*r.ExpiresAt) {
+			r.Status = model.BreakGlassExpired
+		}
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+
+	resp.RespSuccess(true, "success", list, len(list))
+}
+
+// 批准紧急访问申请, 批准人不能是申请人
+func (m *Manager) ApproveBreakGlass(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	user, ok := breakGlassUser(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	if err := validation.IsDNS1123Name(id); err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	cm, r, err := m.getBreakGlass(ctx, id)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("break glass request: %s not found", id))
+			return
+		}
+		klog.Errorf("get break glass request: %s error: %v", id, err)
+		resp.RespError("get break glass request error")
+		return
+	}
+
+	if r.Status != model.BreakGlassPending {
+		resp.RespErrorCode(http.StatusConflict, responseutil.HTTP_CONFLICT, fmt.Sprintf("break glass request is %s", r.Status))
+		return
+	}
+	if user == r.Requester {
+		m.audit(c, r, user, "deny self approve")
+		_ = m.saveBreakGlass(ctx, cm, r)
+		resp.RespErrorCode(http.StatusForbidden, responseutil.HTTP_FORBIDDEN, "the requester can not approve the request")
+		return
+	}
+
+	now := time.Now().UTC()
+	expires := now.Add(BreakGlassTTL)
+	r.Approver = user
+	r.Status = model.BreakGlassApproved
+	r.ApprovedAt = &now
+	r.ExpiresAt = &expires
+	m.audit(c, r, user, "approve")
+
+	err = m.saveBreakGlass(ctx, cm, r)
+	if err != nil {
+		klog.Errorf("update break glass request: %s error: %v", id, err)
+		resp.RespError("update break glass request error")
+		return
+	}
+
+	resp.RespSuccess(true, "success", r, 1)
+}
+
+// 取回托管的集群凭证, 返回的信封只能用管理员离线保管的私钥解密
+func (m *Manager) GetBreakGlassCredential(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	user, ok := breakGlassUser(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	if err := validation.IsDNS1123Name(id); err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	cm, r, err := m.getBreakGlass(ctx, id)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("break glass request: %s not found", id))
+			return
+		}
+		klog.Errorf("get break glass request: %s error: %v", id, err)
+		resp.RespError("get break glass request error")
+		return
+	}
+
+	if user != r.Requester && user != r.Approver {
+		m.audit(c, r, user, "deny retrieve")
+		_ = m.saveBreakGlass(ctx, cm, r)
+		resp.RespErrorCode(http.StatusForbidden, responseutil.HTTP_FORBIDDEN, "only the requester or the approver can retrieve the credential")
+		return
+	}
+	if r.Status != model.BreakGlassApproved {
+		resp.RespErrorCode(http.StatusForbidden, responseutil.HTTP_FORBIDDEN, fmt.Sprintf("break glass request is %s", r.Status))
+		return
+	}
+
+	s := &corev1.Secret{}
+	err = m.Cluster.GetClient().Get(ctx, types.NamespacedName{Namespace: m.EscrowNamespace, Name: escrow.SecretName(r.Cluster)}, s)
+	if err != nil {
+		klog.Errorf("get cluster: %s escrow error: %v", r.Cluster, err)
+		resp.RespError("get escrowed credential error")
+		return
+	}
+
+	env, err := escrow.Unmarshal(s.Data[escrow.DataKey])
+	if err != nil {
+		klog.Errorf("decode cluster: %s escrow error: %v", r.Cluster, err)
+		resp.RespError("decode escrowed credential error")
+		return
+	}
+
+	m.audit(c, r, user, "retrieve")
+	err = m.saveBreakGlass(ctx, cm, r)
+	if err != nil {
+		// never hand out the credential without the audit record
+		klog.Errorf("update break glass request: %s error: %v", id, err)
+		resp.RespError("update break glass request error")
+		return
+	}
+
+	resp.RespSuccess(true, "success", env, 1)
+}
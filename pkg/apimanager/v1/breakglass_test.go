@@ -0,0 +1,38 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/util/authutil"
+)
+
+func TestBreakGlassUser(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     *authutil.User
+		wantOK   bool
+		wantCode int
+	}{
+		{name: "client certificate", user: &authutil.User{Name: "alice", Issuer: authutil.X509Issuer}, wantOK: true, wantCode: http.StatusOK},
+		{name: "oidc", user: &authutil.User{Name: "alice", Issuer: "https://dex.example.com"}, wantOK: true, wantCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/apis/v1/breakglass", nil)
+			authutil.SetUser(c, tt.user)
+
+			name, ok := breakGlassUser(c)
+			if ok != tt.wantOK || w.Code != tt.wantCode {
+				t.Errorf("breakGlassUser() = %q, %v, code %d, want %v, code %d", name, ok, w.Code, tt.wantOK, tt.wantCode)
+			}
+			if ok && name != tt.user.Name {
+				t.Errorf("breakGlassUser() = %q, want %q", name, tt.user.Name)
+			}
+		})
+	}
+}
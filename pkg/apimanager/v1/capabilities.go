@@ -0,0 +1,58 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/provider/addons/flannel"
+	"github.com/gostship/kunkka/pkg/provider/addons/gatekeeper"
+	"github.com/gostship/kunkka/pkg/provider/addons/metricsserver"
+	"github.com/gostship/kunkka/pkg/provider/addons/multus"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+)
+
+const (
+	// AuthModeOAuthToken the bearer token issued by /oauth/authorize
+	AuthModeOAuthToken = "oauth-token"
+	// AuthModePprofToken the token guarding the pprof endpoints
+	AuthModePprofToken = "pprof-token"
+	// AuthModeOIDC the id token of the OIDC issuer set by --oidc-issuer-url
+	AuthModeOIDC = "oidc"
+	// AuthModeAPIToken the long-lived api tokens of the automation clients
+	AuthModeAPIToken = "api-token"
+	// AuthModeClientCert the client certificates verified by --tls-client-ca-file
+	AuthModeClientCert = "client-cert"
+)
+
+// 查询部署支持的集群类型、组件、版本、认证方式及功能开关
+func (m *Manager) getCapabilities(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+
+	features := map[string]bool{
+		"breakGlass":             true,
+		"expansionRequests":      true,
+		"validationWebhooks":     true,
+		"kubeconfigRegeneration": true,
+	}
+	for k, v := range m.Features {
+		features[k] = v
+	}
+
+	caps := &model.Capabilities{
+		Providers: []string{"Baremetal", "Hosted", "VSphere", "OpenStack", "AWS", "Managed", "Libvirt", "CAPI", "Include"},
+		Addons: []*model.AddonCapability{
+			{Name: "kube-proxy"},
+			{Name: "coredns", Version: constants.CoreDNSVersion},
+			{Name: "flannel", Version: flannel.Version},
+			{Name: "metrics-server", Version: metricsserver.Version},
+			{Name: "multus", Version: multus.Version, Optional: true},
+			{Name: gatekeeper.PolicyType, Version: gatekeeper.Version, Optional: true},
+		},
+		KubernetesVersions: constants.K8sVersions,
+		DockerVersions:     constants.DockerVersions,
+		AuthModes:          m.AuthModes,
+		APIVersions:        []string{"v1", "v2"},
+		Features:           features,
+	}
+	resp.RespSuccess(true, "success", caps, 1)
+}
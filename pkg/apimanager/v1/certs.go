@@ -0,0 +1,66 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/certexpiry"
+	"github.com/gostship/kunkka/pkg/credential"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	"k8s.io/klog"
+)
+
+// 获取集群证书的有效期
+func (m *Manager) getClusterCerts(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Param("name")
+
+	creds := &devopsv1.ClusterCredentialList{}
+	err := m.Cluster.GetClient().List(context.Background(), creds)
+	if err != nil {
+		klog.Errorf("list cluster credential error: %v", err)
+		resp.RespError("list cluster credential error")
+		return
+	}
+
+	var cred *devopsv1.ClusterCredential
+	for i := range creds.Items {
+		if certexpiry.ClusterName(&creds.Items[i]) == name {
+			cred = &creds.Items[i]
+			break
+		}
+	}
+	if cred == nil {
+		resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("cluster: %s credential not found", name))
+		return
+	}
+	err = credential.Load(context.Background(), m.Cluster.GetClient(), cred)
+	if err != nil {
+		klog.Errorf("load cluster: %s credential error: %v", name, err)
+		resp.RespError("load cluster credential error")
+		return
+	}
+
+	now := time.Now()
+	certs := certexpiry.FromCredential(cred)
+	items := make([]*model.ClusterCert, 0, len(certs))
+	for _, cert := range certs {
+		items = append(items, &model.ClusterCert{
+			Name:             cert.Name,
+			Subject:          cert.Subject,
+			Issuer:           cert.Issuer,
+			SANs:             cert.SANs,
+			IsCA:             cert.IsCA,
+			NotBefore:        cert.NotBefore,
+			NotAfter:         cert.NotAfter,
+			ExpiresInSeconds: int64(cert.ExpiresIn(now).Seconds()),
+		})
+	}
+
+	resp.RespSuccess(true, "success", items, len(items))
+}
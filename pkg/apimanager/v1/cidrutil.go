@@ -12,6 +12,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog"
+	"net/http"
 	"strconv"
 )
 
@@ -46,8 +47,8 @@ func (m *Manager) GetPodCidr(c *gin.Context) {
 			}
 		}
 
-		klog.Error("get configMap error %v: ", err)
-		resp.RespError("can't found rackcidr, please create.")
+		klog.Errorf("get configMap error: %v", err)
+		resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, "can't found rackcidr, please create.")
 	}
 
 	data := cmList.Data["List"]
@@ -55,7 +56,7 @@ func (m *Manager) GetPodCidr(c *gin.Context) {
 	// 将yaml转换为json
 	yamlToRack, err := yaml.YAMLToJSON([]byte(data))
 	if err != nil {
-		klog.Errorf("yamlToJson error", err)
+		klog.Errorf("yamlToJson error: %v", err)
 		resp.RespError("yamlToJson error.")
 	}
 
@@ -1,12 +1,13 @@
 package v1
 
 import (
+	"github.com/gostship/kunkka/pkg/util/responseutil"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// get cluster client
+// get cluster client, the errors of the clusters not connected are responseutil.Error
 func (m *Manager) getClient(cliName string) (client.Client, error) {
 	var cli client.Client
 	if cliName == MetaClusterName {
@@ -14,7 +15,7 @@ func (m *Manager) getClient(cliName string) (client.Client, error) {
 	} else {
 		cls, err := m.Cluster.Get(cliName)
 		if err != nil {
-			return nil, nil
+			return nil, responseutil.ClusterUnavailable("%v", err)
 		}
 		cli = cls.Client
 	}
@@ -29,7 +30,7 @@ func (m *Manager) getClientInterface(cliName string) (kubernetes.Interface, erro
 	} else {
 		cls, err := m.Cluster.Get(cliName)
 		if err != nil {
-			return nil, nil
+			return nil, responseutil.ClusterUnavailable("%v", err)
 		}
 		cli = cls.KubeCli
 	}
@@ -44,7 +45,7 @@ func (m *Manager) getClientRestCfg(cliName string) (rest.Config, error) {
 	} else {
 		cls, err := m.Cluster.Get(cliName)
 		if err != nil {
-			return cfg, nil
+			return cfg, responseutil.ClusterUnavailable("%v", err)
 		}
 		cfg = *cls.RestConfig
 	}
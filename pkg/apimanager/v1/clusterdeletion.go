@@ -0,0 +1,152 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/clusterdeletion"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/apimanager/webhook"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/storage"
+	"github.com/gostship/kunkka/pkg/util/authutil"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog"
+)
+
+// 申请删除集群, 返回将被清理的机器、托管控制面及释放的机柜地址, 以及确认删除用的 token(只返回一次, 10 分钟内有效)
+func (m *Manager) requestClusterDeletion(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Param("name")
+	if name == MetaClusterName {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, "the meta cluster can't be deleted")
+		return
+	}
+
+	del, err := m.Deleter.Request(context.Background(), name, requestUserName(c))
+	if err != nil {
+		switch {
+		case apierrors.IsNotFound(errors.Cause(err)):
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("cluster: %s not found", name))
+		case err == clusterdeletion.ErrDeleting:
+			resp.RespErrorCode(http.StatusConflict, responseutil.HTTP_CONFLICT, err.Error())
+		default:
+			klog.Errorf("request deletion of cluster: %s error: %v", name, err)
+			resp.RespError("request cluster deletion error")
+		}
+		return
+	}
+	klog.Infof("deletion of cluster: %s requested by user: %s, %d machines", name, del.Requester, len(del.Machines))
+	resp.RespSuccess(true, "success", del, 1)
+}
+
+// 查询集群删除的进度: Confirming 待确认, Deleting 删除中, Released 集群已删除且机柜地址已释放
+func (m *Manager) getClusterDeletion(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Param("name")
+
+	del, err := m.Deleter.Get(context.Background(), name)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, "cluster deletion not found")
+			return
+		}
+		klog.Errorf("get deletion of cluster: %s error: %v", name, err)
+		resp.RespError("get cluster deletion error")
+		return
+	}
+	resp.RespSuccess(true, "success", del, 1)
+}
+
+// 确认删除集群, confirm 为申请时返回的 token; 集群不可达或仍运行业务 pod 时需 force=true,
+// force 删除时无法清理的节点将被跳过
+func (m *Manager) deleteCluster(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Param("name")
+	token := c.Query("confirm")
+	if token == "" {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, "confirm token is required, request the deletion first")
+		return
+	}
+	force := c.Query("force") == "true"
+
+	user := requestUserName(c)
+	del, err := m.Deleter.Confirm(context.Background(), name, user, token, force, func(cluster *devopsv1.Cluster) error {
+		if !force {
+			if err := m.checkClusterDeletion(name); err != nil {
+				return err
+			}
+		}
+		return m.validateWebhooks(webhook.OperationDeleteCluster, name, []runtime.Object{cluster})
+	})
+	if err != nil {
+		// the cluster can't be deleted without force, e.g. it still runs workloads
+		blocked, _ := err.(*responseutil.Error)
+		switch {
+		case apierrors.IsNotFound(errors.Cause(err)):
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("cluster: %s not found", name))
+		case err == clusterdeletion.ErrInvalidToken:
+			resp.RespErrorCode(http.StatusForbidden, responseutil.HTTP_FORBIDDEN, err.Error())
+		case err == clusterdeletion.ErrNotRequested, err == clusterdeletion.ErrExpired:
+			resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		case err == clusterdeletion.ErrDeleting, err == clusterdeletion.ErrChanged:
+			resp.RespErrorCode(http.StatusConflict, responseutil.HTTP_CONFLICT, err.Error())
+		case blocked != nil:
+			resp.RespErr(blocked)
+		case webhook.IsDenied(err):
+			resp.RespErrorCode(http.StatusForbidden, responseutil.HTTP_FORBIDDEN, err.Error())
+		default:
+			klog.Errorf("delete cluster: %s error: %v", name, err)
+			resp.RespError("delete cluster error")
+		}
+		return
+	}
+	klog.Infof("cluster: %s deleted by user: %s, force: %v", name, user, force)
+	resp.RespSuccess(true, "success", del, 1)
+}
+
+// checkClusterDeletion rejects the deletion of the cluster which is unreachable or still runs pods out of
+// the system namespaces, the details of the error are the counts of the pods by namespace.
+func (m *Manager) checkClusterDeletion(name string) error {
+	cli, _ := m.getClientInterface(name)
+	if cli == nil {
+		return responseutil.Conflict("cluster: %s is unreachable, delete it with force", name)
+	}
+	pods, err := cli.CoreV1().Pods(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "list pods of cluster: %s", name)
+	}
+
+	counts := map[string]int{}
+	for i := range pods.Items {
+		if ns := pods.Items[i].Namespace; !model.IsSystemNamespace(ns) {
+			counts[ns]++
+		}
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+	blockers := make([]string, 0, len(counts))
+	for ns, n := range counts {
+		blockers = append(blockers, fmt.Sprintf("%s(%d)", ns, n))
+	}
+	sort.Strings(blockers)
+	return responseutil.Conflict("cluster: %s still runs pods in namespaces: %s, delete them first or delete the cluster with force",
+		name, strings.Join(blockers, ", ")).WithDetails(counts)
+}
+
+// requestUserName returns the name of the authenticated user of the request, empty if there's none.
+func requestUserName(c *gin.Context) string {
+	if u, err := authutil.RequestUser(c); err == nil {
+		return u.Name
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+// 获取集群的事件, 合并 meta 集群中集群的 Cluster、Machine 等 CR 的事件及集群自身的事件, 按最后发生时间倒序,
+// 可按 source(meta、member)、namespace、involvedObject.kind、involvedObject.name、type、reason、since 过滤,
+// limit 默认 500; 集群不可达时只返回 meta 集群的事件
+func (m *Manager) getAggregatedEvents(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Param("name")
+
+	q, err := parseEventQuery(c)
+	if err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	events := []*model.ClusterEvent{}
+	if q.Includes(model.EventSourceMeta) {
+		list, err := listEvents(ctx, m.Cluster.KubeCli, name, q)
+		if err != nil {
+			klog.Errorf("list cluster: %s meta events error: %v", name, err)
+			resp.RespError("list meta events error")
+			return
+		}
+		for i := range list {
+			// the events of the other resources in the namespace of the cluster are not of the cluster
+			if strings.HasPrefix(list[i].InvolvedObject.APIVersion, devopsv1.GroupVersion.Group+"/") {
+				events = append(events, model.NewClusterEvent(model.EventSourceMeta, &list[i]))
+			}
+		}
+	}
+	if q.Includes(model.EventSourceMember) && name != MetaClusterName {
+		cli, _ := m.getClientInterface(name)
+		if cli == nil && q.Source == model.EventSourceMember {
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("cluster: %s not found", name))
+			return
+		}
+		if cli != nil {
+			list, err := listEvents(ctx, cli, q.Namespace, q)
+			if err != nil {
+				klog.Errorf("list cluster: %s events error: %v", name, err)
+				if q.Source == model.EventSourceMember {
+					resp.RespError("list cluster events error")
+					return
+				}
+			}
+			for i := range list {
+				events = append(events, model.NewClusterEvent(model.EventSourceMember, &list[i]))
+			}
+		}
+	}
+
+	events = q.Apply(events, time.Now())
+	resp.RespSuccess(true, "success", events, len(events))
+}
+
+// listEvents lists the events of the namespace, all if empty, filtered by the field selectors of the query.
+func listEvents(ctx context.Context, cli kubernetes.Interface, namespace string, q *model.EventQuery) ([]corev1.Event, error) {
+	set := fields.Set{}
+	if q.Kind != "" {
+		set["involvedObject.kind"] = q.Kind
+	}
+	if q.Name != "" {
+		set["involvedObject.name"] = q.Name
+	}
+	if q.Type != "" {
+		set["type"] = q.Type
+	}
+	if q.Reason != "" {
+		set["reason"] = q.Reason
+	}
+	list, err := cli.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: fields.SelectorFromSet(set).String()})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func parseEventQuery(c *gin.Context) (*model.EventQuery, error) {
+	q := &model.EventQuery{
+		Source:    c.Query("source"),
+		Namespace: c.Query("namespace"),
+		Kind:      c.Query("involvedObject.kind"),
+		Name:      c.Query("involvedObject.name"),
+		Type:      c.Query("type"),
+		Reason:    c.Query("reason"),
+		Limit:     500,
+	}
+	if s := c.Query("since"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("since: invalid duration %s", s)
+		}
+		q.Since = d
+	}
+	if s := c.Query("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("limit: invalid number %s", s)
+		}
+		q.Limit = limit
+	}
+	return q, q.Validate()
+}
@@ -0,0 +1,86 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/provider/baremetal/validation"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+)
+
+// 修改集群 spec 的部分字段(version、registryMirrors、features.multus、features.ha), 其余字段不可修改,
+// 修改后由 controller 同步到集群
+func (m *Manager) patchCluster(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Param("name")
+
+	patch := &model.ClusterSpecPatch{}
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(patch); err != nil {
+		msg := err.Error()
+		if strings.HasPrefix(msg, "json: unknown field ") {
+			msg = fmt.Sprintf("%s is immutable, the mutable fields: %s",
+				strings.TrimPrefix(msg, "json: unknown field "), strings.Join(model.ClusterPatchFields, ", "))
+		}
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, msg)
+		return
+	}
+
+	ctx := context.Background()
+	changed := []string{}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cluster := &devopsv1.Cluster{}
+		if err := m.Cluster.GetClient().Get(ctx, types.NamespacedName{Namespace: name, Name: name}, cluster); err != nil {
+			return err
+		}
+		if !cluster.DeletionTimestamp.IsZero() {
+			return responseutil.Conflict("cluster: %s is being deleted", name)
+		}
+		if err := patch.Validate(cluster); err != nil {
+			return responseutil.BadRequest("%v", err)
+		}
+		if patch.Version != nil && *patch.Version != cluster.Spec.Version && cluster.Status.Phase != devopsv1.ClusterRunning {
+			return responseutil.Conflict("cluster: %s is %s, only the running clusters can be upgraded", name, cluster.Status.Phase)
+		}
+
+		changed = patch.Apply(&cluster.Spec)
+		if len(changed) == 0 {
+			return nil
+		}
+		if errs := validation.ValidatClusterSpec(&cluster.Spec, field.NewPath("spec"), cluster.Status.Phase); len(errs) > 0 {
+			return responseutil.BadRequest("%v", errs.ToAggregate())
+		}
+		return m.Cluster.GetClient().Update(ctx, cluster)
+	})
+	if err != nil {
+		// the patch can't be applied to the cluster, it's not retried on the conflicts
+		if e, ok := err.(*responseutil.Error); ok {
+			resp.RespErr(e)
+			return
+		}
+		if apierrors.IsNotFound(err) {
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("cluster: %s not found", name))
+			return
+		}
+		klog.Errorf("patch cluster: %s error: %v", name, err)
+		resp.RespError("patch cluster error")
+		return
+	}
+
+	if len(changed) > 0 {
+		klog.Infof("cluster: %s spec patched by user: %s, changed: %v", name, requestUserName(c), changed)
+	}
+	resp.RespSuccess(true, "success", &model.ClusterSpecUpdate{Cluster: name, Changed: changed}, 1)
+}
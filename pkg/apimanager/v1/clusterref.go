@@ -0,0 +1,238 @@
+package v1
+
+import (
+	"context"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/util/metautil"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// 按 namespace 及 name 获取集群, meta 集群及 extend 集群同样可以获取
+func (m *Manager) getNamespacedCluster(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	cluster, err := m.getCluster(context.Background(), c.Param("namespace"), c.Param("name"))
+	if err != nil {
+		respClusterError(&resp, err)
+		return
+	}
+	resp.RespSuccess(true, "success", cluster, 1)
+}
+
+// 按 namespace 及 name 获取集群的部署步骤状态, clusterType 同 getClusterCondition
+func (m *Manager) getNamespacedClusterConditions(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	cluster, err := m.getCluster(context.Background(), c.Param("namespace"), c.Param("name"))
+	if err != nil {
+		respClusterError(&resp, err)
+		return
+	}
+	conditions := clusterConditions(cluster, c.Query("clusterType"))
+	resp.RespSuccess(true, "success", conditions, len(conditions))
+}
+
+// 获取集群的机器, notReady=true 时只返回未就绪的机器, drifted=true 时只返回 os/kernel 漂移的机器
+func (m *Manager) getClusterMachines(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	ctx := context.Background()
+	cluster, err := m.getCluster(ctx, c.Param("namespace"), c.Param("name"))
+	if err != nil {
+		respClusterError(&resp, err)
+		return
+	}
+	machines, err := m.listClusterMachines(ctx, cluster)
+	if err != nil {
+		klog.Errorf("list machines of cluster: %s/%s error: %v", cluster.Namespace, cluster.Name, err)
+		resp.RespError("list cluster machines error")
+		return
+	}
+
+	notReady, drifted := c.Query("notReady") == "true", c.Query("drifted") == "true"
+	items := []devopsv1.Machine{}
+	for i := range machines {
+		if notReady && machines[i].Status.Phase == devopsv1.MachineRunning {
+			continue
+		}
+		if drifted && (machines[i].Status.OSDrift == nil || !machines[i].Status.OSDrift.Drifted) {
+			continue
+		}
+		items = append(items, machines[i])
+	}
+	resp.RespSuccess(true, "success", items, len(items))
+}
+
+// 获取集群的机器, machine 为机器的 ip
+func (m *Manager) getClusterMachine(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	machine, err := m.getClusterMachineByPath(c)
+	if err != nil {
+		respClusterError(&resp, err)
+		return
+	}
+	resp.RespSuccess(true, "success", machine, 1)
+}
+
+// 获取集群机器的部署步骤状态
+func (m *Manager) getClusterMachineConditions(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	machine, err := m.getClusterMachineByPath(c)
+	if err != nil {
+		respClusterError(&resp, err)
+		return
+	}
+	conditions := machineConditions(machine)
+	resp.RespSuccess(true, "success", conditions, len(conditions))
+}
+
+// getClusterMachineByPath returns the machine of the namespace, name and machine params.
+func (m *Manager) getClusterMachineByPath(c *gin.Context) (*devopsv1.Machine, error) {
+	ctx := context.Background()
+	cluster, err := m.getCluster(ctx, c.Param("namespace"), c.Param("name"))
+	if err != nil {
+		return nil, err
+	}
+	return m.getMachine(ctx, cluster, c.Param("machine"))
+}
+
+// respClusterError writes the errors of getCluster, findCluster and getMachine, the errors of the meta
+// apiserver are internal errors.
+func respClusterError(resp *responseutil.Gin, err error) {
+	if _, ok := err.(*responseutil.Error); !ok {
+		klog.Errorf("get cluster error: %v", err)
+		err = errors.New("get cluster error")
+	}
+	resp.RespErr(err)
+}
+
+// clusterCandidates returns the Clusters of the meta cluster and the virtual clusters.
+func (m *Manager) clusterCandidates(ctx context.Context) ([]devopsv1.Cluster, error) {
+	clusters := &devopsv1.ClusterList{}
+	if err := m.Cluster.GetClient().List(ctx, clusters); err != nil {
+		return nil, errors.Wrap(err, "list clusters")
+	}
+	virtual, err := m.virtualClusters()
+	if err != nil {
+		return nil, err
+	}
+	return append(clusters.Items, virtual...), nil
+}
+
+// virtualClusters returns the extend clusters and the meta cluster, they are not Cluster resources.
+func (m *Manager) virtualClusters() ([]devopsv1.Cluster, error) {
+	extend, err := metautil.BuildExtendObj(m.Cluster.GetClient())
+	if err != nil {
+		return nil, errors.Wrap(err, "build extend clusters")
+	}
+	meta, err := metautil.BuildMetaObj()
+	if err != nil {
+		return nil, errors.Wrap(err, "build meta cluster")
+	}
+	return append(extend, *meta), nil
+}
+
+// getCluster returns the cluster of the namespace and the name, a responseutil.Error of NotFound if
+// there's none.
+func (m *Manager) getCluster(ctx context.Context, namespace, name string) (*devopsv1.Cluster, error) {
+	cluster := &devopsv1.Cluster{}
+	err := m.Cluster.GetClient().Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, cluster)
+	if err == nil {
+		return cluster, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, errors.Wrapf(err, "get cluster: %s/%s", namespace, name)
+	}
+
+	clusters, err := m.virtualClusters()
+	if err != nil {
+		return nil, err
+	}
+	for i := range clusters {
+		if clusters[i].Namespace == namespace && clusters[i].Name == name {
+			return &clusters[i], nil
+		}
+	}
+	return nil, responseutil.NotFound("cluster: %s/%s not found", namespace, name)
+}
+
+// findCluster returns the only cluster of the name in any namespace for the routes addressing the clusters
+// by name, a responseutil.Error of NotFound if there's none and of Conflict with the namespaces of the
+// clusters as details if the name is ambiguous.
+func (m *Manager) findCluster(ctx context.Context, name string) (*devopsv1.Cluster, error) {
+	clusters, err := m.clusterCandidates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var found []*devopsv1.Cluster
+	for i := range clusters {
+		if clusters[i].Name == name {
+			found = append(found, &clusters[i])
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, responseutil.NotFound("cluster: %s not found", name)
+	case 1:
+		return found[0], nil
+	}
+	namespaces := make([]string, 0, len(found))
+	for _, cluster := range found {
+		namespaces = append(namespaces, cluster.Namespace)
+	}
+	sort.Strings(namespaces)
+	return nil, responseutil.Conflict("cluster: %s exists in namespaces: %v, get it by /apis/cluster/clusters/{namespace}/%s",
+		name, namespaces, name).WithDetails(map[string][]string{"namespaces": namespaces})
+}
+
+// listClusterMachines returns the Machines of the cluster, they are in the namespace of the cluster.
+func (m *Manager) listClusterMachines(ctx context.Context, cluster *devopsv1.Cluster) ([]devopsv1.Machine, error) {
+	machines := &devopsv1.MachineList{}
+	if err := m.Cluster.GetClient().List(ctx, machines, client.InNamespace(cluster.Namespace)); err != nil {
+		return nil, err
+	}
+	items := make([]devopsv1.Machine, 0, len(machines.Items))
+	for i := range machines.Items {
+		if machines.Items[i].Spec.ClusterName == cluster.Name {
+			items = append(items, machines.Items[i])
+		}
+	}
+	return items, nil
+}
+
+// getMachine returns the Machine of the cluster, a responseutil.Error of NotFound if there's none.
+func (m *Manager) getMachine(ctx context.Context, cluster *devopsv1.Cluster, name string) (*devopsv1.Machine, error) {
+	machine := &devopsv1.Machine{}
+	err := m.Cluster.GetClient().Get(ctx, types.NamespacedName{Namespace: cluster.Namespace, Name: name}, machine)
+	if apierrors.IsNotFound(err) || (err == nil && machine.Spec.ClusterName != cluster.Name) {
+		return nil, responseutil.NotFound("machine: %s of cluster: %s/%s not found", name, cluster.Namespace, cluster.Name)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "get machine: %s/%s", cluster.Namespace, name)
+	}
+	return machine, nil
+}
+
+// clusterConditions returns the deploy steps of the cluster type with their status.
+func clusterConditions(cluster *devopsv1.Cluster, clusterType string) []*model.RuntimeCondition {
+	conditions := []*model.RuntimeCondition{}
+	for _, condit := range metautil.ProviderClusterSteps(clusterType) {
+		conditions = append(conditions, metautil.ClusterConditionOfContains(cluster.Status.Conditions, condit))
+	}
+	return conditions
+}
+
+// machineConditions returns the deploy steps of the machine with their status.
+func machineConditions(machine *devopsv1.Machine) []*model.RuntimeCondition {
+	conditions := []*model.RuntimeCondition{}
+	for _, condit := range metautil.ProviderClusterSteps("Machine") {
+		conditions = append(conditions, metautil.MachineConditionOfContains(machine.Status.Conditions, condit))
+	}
+	return conditions
+}
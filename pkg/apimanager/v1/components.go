@@ -19,7 +19,7 @@ func (m *Manager) getClusterComponents(c *gin.Context) {
 	clusName := c.Param("name")
 	cli, err := m.getClient(clusName)
 	if err != nil {
-		resp.RespError("get client error")
+		resp.RespErr(err)
 		return
 	}
 
@@ -80,7 +80,7 @@ func (m *Manager) getComponentsDetail(c *gin.Context) {
 	componets := c.Param("component")
 	cli, err := m.getClient(clusName)
 	if err != nil {
-		resp.RespError("get client error")
+		resp.RespErr(err)
 		return
 	}
 
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/ghodss/yaml"
 	"github.com/gin-gonic/gin"
+	"net/http"
 	"github.com/gostship/kunkka/pkg/apimanager/model"
 	"github.com/gostship/kunkka/pkg/util/cidrutil"
 	"github.com/gostship/kunkka/pkg/util/responseutil"
@@ -31,8 +32,13 @@ func (m *Manager) AddRackCidr(c *gin.Context) {
 	// 获取创建Rack结构体
 	r, err := resp.Bind(newRack)
 	if err != nil {
-		klog.Error("Http Bind ConfigMap error %v: ", err)
-		resp.RespError("http Bind ConfigMap error")
+		klog.Errorf("Http Bind ConfigMap error: %v", err)
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_REQUEST_BIND_ERROR, err.Error())
+		return
+	}
+
+	if err := r.(*model.Rack).Sanitize(); err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
 		return
 	}
 
@@ -80,28 +86,28 @@ func (m *Manager) AddRackCidr(c *gin.Context) {
 	data, ok := cm.Data["List"]
 	if !ok {
 		klog.Info("no ConfigMap list!")
-		resp.RespError("no configMap list!")
+		resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, "no configMap list!")
 		return
 	}
 	// 将yaml转换为json
 	yamlToRack, err := yaml.YAMLToJSON([]byte(data))
 	if err != nil {
-		klog.Errorf("yamlToJson error", err)
+		klog.Errorf("yamlToJson error: %v", err)
 		resp.RespError("yaml to struct error!")
 		return
 	}
 	// 转换为结构体
 	err = json.Unmarshal(yamlToRack, &listMap)
 	if err != nil {
-		klog.Errorf("Unmarshal json err", err)
+		klog.Errorf("Unmarshal json err: %v", err)
 		resp.RespError("Unmarshal list json error.")
 		return
 	}
 	for _, rack := range listMap {
 		if rack.RackCidr == r.(*model.Rack).RackCidr {
 			// cidr already
-			klog.Error("cidr %s is already:", r.(*model.Rack).RackCidr)
-			resp.RespError(fmt.Sprintf("cidr %s is already", r.(*model.Rack).RackCidr))
+			klog.Errorf("cidr %s is already exists", r.(*model.Rack).RackCidr)
+			resp.RespErrorCode(http.StatusConflict, responseutil.HTTP_CONFLICT, fmt.Sprintf("cidr %s is already", r.(*model.Rack).RackCidr))
 			return
 		}
 	}
@@ -168,7 +174,7 @@ func (m *Manager) GetRackMap(c *gin.Context) {
 	// 将yaml转换为json
 	yamlToRack, err := yaml.YAMLToJSON([]byte(data))
 	if err != nil {
-		klog.Errorf("yamlToJson error", err)
+		klog.Errorf("yamlToJson error: %v", err)
 		resp.RespError("yamlToJson error")
 		return
 	}
@@ -212,8 +218,13 @@ func (m *Manager) UptConfigMap(c *gin.Context) {
 	// 获取创建Rack结构体
 	r, err := resp.Bind(newRack)
 	if err != nil {
-		klog.Error("http Bind update ConfigMap error %v: ", err)
-		resp.RespError("Update httpParams error.")
+		klog.Errorf("http Bind update ConfigMap error: %v", err)
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_REQUEST_BIND_ERROR, err.Error())
+		return
+	}
+
+	if err := r.(*model.Rack).Sanitize(); err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
 		return
 	}
 
@@ -226,8 +237,8 @@ func (m *Manager) UptConfigMap(c *gin.Context) {
 	err = cli.Get(ctx, types.NamespacedName{Namespace: ConfigMapName, Name: ConfigMapName}, cm)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
-			klog.Error("get ConfigMap %s error %v: ", ConfigMapName, err)
-			resp.RespError("get configMap error.")
+			klog.Errorf("get ConfigMap %s error: %v", ConfigMapName, err)
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, "get configMap error.")
 			return
 		}
 	}
@@ -236,21 +247,21 @@ func (m *Manager) UptConfigMap(c *gin.Context) {
 	data, ok := cm.Data["List"]
 	if !ok {
 		klog.Info("no ConfigMap list!")
-		resp.RespError("no ConfigMap list!")
+		resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, "no ConfigMap list!")
 		return
 	}
 
 	// 将yaml转换为json
 	yamlToRack, err := yaml.YAMLToJSON([]byte(data))
 	if err != nil {
-		klog.Errorf("yamlToJson error", err)
+		klog.Errorf("yamlToJson error: %v", err)
 		resp.RespError("yamlToJson error.")
 		return
 	}
 	// 转换为结构体
 	err = json.Unmarshal(yamlToRack, &listMap)
 	if err != nil {
-		klog.Errorf("Unmarshal json err", err)
+		klog.Errorf("Unmarshal json err: %v", err)
 		resp.RespError("Unmarshal json err")
 		return
 	}
@@ -287,8 +298,8 @@ func (m *Manager) DelConfigMap(c *gin.Context) {
 	// 获取创建Rack结构体
 	r, err := resp.Bind(newRack)
 	if err != nil {
-		klog.Error("bind delete ConfigMap error %v: ", err)
-		resp.RespError("bind delete Params error")
+		klog.Errorf("bind delete ConfigMap error: %v", err)
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_REQUEST_BIND_ERROR, err.Error())
 		return
 	}
 
@@ -301,8 +312,8 @@ func (m *Manager) DelConfigMap(c *gin.Context) {
 	err = cli.Get(ctx, types.NamespacedName{Namespace: ConfigMapName, Name: ConfigMapName}, cm)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
-			klog.Error("get ConfigMap %s error %v: ", ConfigMapName, err)
-			resp.RespError("get configMap error")
+			klog.Errorf("get ConfigMap %s error: %v", ConfigMapName, err)
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, "get configMap error")
 			return
 		}
 	}
@@ -311,20 +322,20 @@ func (m *Manager) DelConfigMap(c *gin.Context) {
 	data, ok := cm.Data["List"]
 	if !ok {
 		klog.Info("no ConfigMap list!")
-		resp.RespError("no ConfigMap list!")
+		resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, "no ConfigMap list!")
 		return
 	}
 	// 将yaml转换为json
 	yamlToRack, err := yaml.YAMLToJSON([]byte(data))
 	if err != nil {
-		klog.Errorf("yamlToJson error", err)
+		klog.Errorf("yamlToJson error: %v", err)
 		resp.RespError("yamlToJson error.")
 		return
 	}
 	// 转换为结构体
 	err = json.Unmarshal(yamlToRack, &listMap)
 	if err != nil {
-		klog.Errorf("Unmarshal json err", err)
+		klog.Errorf("Unmarshal json err: %v", err)
 		resp.RespError("Unmarshal json err.")
 		return
 	}
@@ -368,8 +379,8 @@ func (m *Manager) getMasterRack(c *gin.Context) {
 	}, cmList)
 
 	if err != nil {
-		klog.Error("Get ConfigMap error %v: ", err)
-		resp.RespError("can't found rackcidr, please create!")
+		klog.Errorf("Get ConfigMap error: %v", err)
+		resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, "can't found rackcidr, please create!")
 		return
 	}
 
@@ -378,7 +389,7 @@ func (m *Manager) getMasterRack(c *gin.Context) {
 	// 将yaml转换为json
 	yamlToRack, err := yaml.YAMLToJSON([]byte(data))
 	if err != nil {
-		klog.Errorf("yamlToJson error", err)
+		klog.Errorf("yamlToJson error: %v", err)
 		resp.RespError("yamlToJson error")
 		return
 	}
@@ -425,8 +436,8 @@ func (m *Manager) getHostRack(typeName string, c *gin.Context, clstype string) *
 			}
 		}
 
-		klog.Error("get configMap error %v: ", err)
-		resp.RespError("can't found rackcidr, please create.")
+		klog.Errorf("get configMap error: %v", err)
+		resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, "can't found rackcidr, please create.")
 	}
 
 	data := cmList.Data["List"]
@@ -434,7 +445,7 @@ func (m *Manager) getHostRack(typeName string, c *gin.Context, clstype string) *
 	// 将yaml转换为json
 	yamlToRack, err := yaml.YAMLToJSON([]byte(data))
 	if err != nil {
-		klog.Errorf("yamlToJson error", err)
+		klog.Errorf("yamlToJson error: %v", err)
 		resp.RespError("yamlToJson error.")
 	}
 
@@ -475,7 +486,7 @@ func (m *Manager) UptRackStatePhase(rack string, machines string, podID string,
 	err := cli.Get(ctx, types.NamespacedName{Namespace: ConfigMapName, Name: ConfigMapName}, cm)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
-			klog.Error("get ConfigMap %s error %v: ", ConfigMapName, err)
+			klog.Errorf("get ConfigMap %s error: %v", ConfigMapName, err)
 			return err
 		}
 	}
@@ -490,13 +501,13 @@ func (m *Manager) UptRackStatePhase(rack string, machines string, podID string,
 	// 将yaml转换为json
 	yamlToRack, err := yaml.YAMLToJSON([]byte(data))
 	if err != nil {
-		klog.Errorf("yamlToJson error", err)
+		klog.Errorf("yamlToJson error: %v", err)
 		return err
 	}
 	// 转换为结构体
 	err = json.Unmarshal(yamlToRack, &listMap)
 	if err != nil {
-		klog.Errorf("Unmarshal json err", err)
+		klog.Errorf("Unmarshal json err: %v", err)
 		return err
 	}
 
@@ -0,0 +1,422 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/apimanager/webhook"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/tracing"
+	"github.com/gostship/kunkka/pkg/util/crdutil"
+	"github.com/gostship/kunkka/pkg/util/k8sutil"
+	"github.com/gostship/kunkka/pkg/util/metautil"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	"github.com/gostship/kunkka/pkg/util/uidutil"
+	"github.com/gostship/kunkka/pkg/util/validation"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// TenantQuotaName 租户配额 configmap, key 为租户 id, value 为租户所有集群的机器数上限
+	TenantQuotaName = "tenant-quotas"
+
+	expansionDataKey     = "request.json"
+	expansionPasswordKey = "password"
+)
+
+func expansionName(id string) string {
+	return fmt.Sprintf("expansion-%s", id)
+}
+
+// tenantUsage returns the machine quota of the tenant and the machines used by its clusters, -1 quota is unlimited.
+// The tenants without quota have no free machine, every expansion of them needs the approval.
+func (m *Manager) tenantUsage(ctx context.Context, tenant string) (int, int, error) {
+	cli := m.Cluster.GetClient()
+	quota := 0
+	cm := &corev1.ConfigMap{}
+	err := cli.Get(ctx, types.NamespacedName{Namespace: ConfigMapName, Name: TenantQuotaName}, cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return 0, 0, err
+	}
+	if v, ok := cm.Data[tenant]; ok {
+		quota, err = strconv.Atoi(v)
+		if err != nil {
+			return 0, 0, errors.Wrapf(err, "tenant: %s quota", tenant)
+		}
+	}
+
+	clusters := &devopsv1.ClusterList{}
+	err = cli.List(ctx, clusters)
+	if err != nil {
+		return 0, 0, err
+	}
+	owned := map[string]bool{}
+	for i := range clusters.Items {
+		if clusters.Items[i].Spec.TenantID == tenant {
+			owned[clusters.Items[i].Name] = true
+		}
+	}
+
+	machines := &devopsv1.MachineList{}
+	err = cli.List(ctx, machines)
+	if err != nil {
+		return 0, 0, err
+	}
+	used := 0
+	for i := range machines.Items {
+		if owned[machines.Items[i].Spec.ClusterName] {
+			used++
+		}
+	}
+
+	return quota, used, nil
+}
+
+func (m *Manager) getExpansion(ctx context.Context, id string) (*corev1.ConfigMap, *model.ExpansionRequest, error) {
+	cm := &corev1.ConfigMap{}
+	err := m.Cluster.GetClient().Get(ctx, types.NamespacedName{Namespace: ConfigMapName, Name: expansionName(id)}, cm)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r := &model.ExpansionRequest{}
+	err = json.Unmarshal([]byte(cm.Data[expansionDataKey]), r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cm, r, nil
+}
+
+func (m *Manager) saveExpansion(ctx context.Context, cm *corev1.ConfigMap, r *model.ExpansionRequest) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	cm.Data = map[string]string{expansionDataKey: string(data)}
+	return m.Cluster.GetClient().Update(ctx, cm)
+}
+
+// provisionExpansion allocates the machines from the racks and creates them like addClusterNode,
+// the password is read from the Secret of the request which is deleted once the machines are created.
+func (m *Manager) provisionExpansion(ctx context.Context, r *model.ExpansionRequest) error {
+	cli := m.Cluster.GetClient()
+	s := &corev1.Secret{}
+	err := cli.Get(ctx, types.NamespacedName{Namespace: ConfigMapName, Name: expansionName(r.ID)}, s)
+	if err != nil {
+		return errors.Wrapf(err, "get expansion secret")
+	}
+
+	// mark the machines used first, so they are never allocated concurrently
+	cniOpts, err := m.allocateRackMachines(ctx, r.NodeRack, r.Count, false)
+	if err != nil {
+		return err
+	}
+
+	node := &model.ClusterNode{
+		ClusterName:   r.Cluster,
+		DockerVersion: r.DockerVersion,
+		NodeVersion:   r.NodeVersion,
+		NodeRack:      r.NodeRack,
+		UserName:      r.UserName,
+		Password:      string(s.Data[expansionPasswordKey]),
+	}
+	r.Machines = nil
+	for _, opt := range cniOpts {
+		node.AddressList = append(node.AddressList, opt.Machine)
+		r.Machines = append(r.Machines, opt.Machine)
+	}
+
+	err = m.createNodes(ctx, node, cniOpts)
+	if err != nil {
+		return err
+	}
+
+	err = cli.Delete(ctx, s)
+	if err != nil && !apierrors.IsNotFound(err) {
+		klog.Warningf("delete expansion: %s secret err: %v", r.ID, err)
+	}
+	return nil
+}
+
+// createNodes builds the Machines of the nodes and creates them on the meta cluster, the Machines carry the trace
+// context of ctx, so the spans of their provisioning join the trace of the request.
+func (m *Manager) createNodes(ctx context.Context, node *model.ClusterNode, cniOpts []*model.CniOption) (err error) {
+	ctx, span := tracing.Start(ctx, "create machines", tracing.ClusterKey.String(node.ClusterName))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	nodeObj, err := crdutil.BuildNodeCrd(node, cniOpts)
+	if err != nil {
+		return errors.Wrapf(err, "build node crd cfg")
+	}
+
+	err = m.validateWebhooks(webhook.OperationAddMachine, node.ClusterName, nodeObj)
+	if err != nil {
+		return err
+	}
+
+	logger := ctrl.Log.WithValues("cluster", node.ClusterName)
+	logger.Info("create node reconcile ...")
+	injectTrace(ctx, nodeObj)
+	for _, obj := range nodeObj {
+		err := k8sutil.Reconcile(logger, m.Cluster.GetClient(), obj, k8sutil.DesiredStatePresent)
+		if err != nil {
+			return errors.Wrapf(err, "create node reconcile")
+		}
+	}
+	return nil
+}
+
+// injectTrace keeps the trace context of ctx in the annotations of the objects created by the request.
+func injectTrace(ctx context.Context, objs []runtime.Object) {
+	for _, obj := range objs {
+		if o, ok := obj.(metav1.Object); ok {
+			tracing.Inject(ctx, o)
+		}
+	}
+}
+
+func (m *Manager) finishExpansion(ctx context.Context, r *model.ExpansionRequest) {
+	err := m.provisionExpansion(ctx, r)
+	if err != nil {
+		klog.Errorf("provision expansion: %s cluster: %s err: %v", r.ID, r.Cluster, err)
+		r.Status = model.ExpansionFailed
+		r.Message = err.Error()
+		return
+	}
+
+	klog.Infof("expansion: %s cluster: %s provisioned machines: %v", r.ID, r.Cluster, r.Machines)
+	r.Status = model.ExpansionProvisioned
+	r.Message = ""
+}
+
+// 创建扩容申请, 租户配额内直接分配机器, 超出配额的等待平台管理员批准
+func (m *Manager) CreateExpansion(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	user, ok := breakGlassUser(c)
+	if !ok {
+		return
+	}
+
+	r := &model.ExpansionRequest{}
+	if _, err := resp.Bind(r); err != nil {
+		klog.Errorf("Http Bind ExpansionRequest error: %v", err)
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_REQUEST_BIND_ERROR, err.Error())
+		return
+	}
+	if err := r.Sanitize(); err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	cli := m.Cluster.GetClient()
+	cluster := &devopsv1.Cluster{}
+	err := cli.Get(ctx, types.NamespacedName{Namespace: r.Cluster, Name: r.Cluster}, cluster)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("cluster: %s not found", r.Cluster))
+			return
+		}
+		klog.Errorf("get cluster: %s error: %v", r.Cluster, err)
+		resp.RespError("get cluster error")
+		return
+	}
+
+	quota, used, err := m.tenantUsage(ctx, cluster.Spec.TenantID)
+	if err != nil {
+		klog.Errorf("get tenant: %s usage error: %v", cluster.Spec.TenantID, err)
+		resp.RespError("get tenant usage error")
+		return
+	}
+
+	password := r.Password
+	r.ID = uidutil.GenerateId()
+	r.Tenant = cluster.Spec.TenantID
+	r.Password = ""
+	r.Requester = user
+	r.Approver = ""
+	r.Status = model.ExpansionPending
+	r.Message = ""
+	r.Machines = nil
+	r.Quota = quota
+	r.Used = used
+	r.CreatedAt = time.Now().UTC()
+	r.ApprovedAt = nil
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      expansionName(r.ID),
+			Namespace: ConfigMapName,
+			Labels: map[string]string{
+				constants.ExpansionRequestLabel: r.Cluster,
+			},
+		},
+	}
+	s := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      expansionName(r.ID),
+			Namespace: ConfigMapName,
+			Labels: map[string]string{
+				constants.ExpansionRequestLabel: r.Cluster,
+			},
+		},
+		Data: map[string][]byte{expansionPasswordKey: []byte(password)},
+	}
+	err = cli.Create(ctx, s)
+	if err != nil {
+		klog.Errorf("create expansion secret error: %v", err)
+		resp.RespError("create expansion request error")
+		return
+	}
+
+	if quota < 0 || used+r.Count <= quota {
+		// within the quota of the tenant
+		now := time.Now().UTC()
+		r.Approver = "quota"
+		r.ApprovedAt = &now
+		m.finishExpansion(ctx, r)
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		resp.RespError(err.Error())
+		return
+	}
+	cm.Data = map[string]string{expansionDataKey: string(data)}
+	err = cli.Create(ctx, cm)
+	if err != nil {
+		klog.Errorf("create expansion request error: %v", err)
+		resp.RespError("create expansion request error")
+		return
+	}
+
+	resp.RespSuccess(true, "success", r, 1)
+}
+
+// 扩容申请列表, 可按集群过滤
+func (m *Manager) ListExpansion(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	if _, ok := breakGlassUser(c); !ok {
+		return
+	}
+
+	opts := []client.ListOption{client.InNamespace(ConfigMapName), client.HasLabels{constants.ExpansionRequestLabel}}
+	if cluster := c.Query("cluster"); cluster != "" {
+		opts = append(opts, client.MatchingLabels{constants.ExpansionRequestLabel: cluster})
+	}
+	cms := &corev1.ConfigMapList{}
+	err := m.Cluster.GetClient().List(context.Background(), cms, opts...)
+	if err != nil {
+		klog.Errorf("list expansion request error: %v", err)
+		resp.RespError("list expansion request error")
+		return
+	}
+
+	list := make([]*model.ExpansionRequest, 0, len(cms.Items))
+	for i := range cms.Items {
+		r := &model.ExpansionRequest{}
+		if err := json.Unmarshal([]byte(cms.Items[i].Data[expansionDataKey]), r); err != nil {
+			klog.Warningf("decode expansion request: %s error: %v", cms.Items[i].Name, err)
+			continue
+		}
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+
+	resp.RespSuccess(true, "success", list, len(list))
+}
+
+// 批准或拒绝扩容申请, 只有平台管理员可以审批, 且审批人不能是申请人
+func (m *Manager) reviewExpansion(c *gin.Context, approve bool) {
+	resp := responseutil.Gin{Ctx: c}
+	user, ok := breakGlassUser(c)
+	if !ok {
+		return
+	}
+	if len(m.ExpansionApprovers) > 0 && !metautil.StringofContains(user, m.ExpansionApprovers) {
+		resp.RespErrorCode(http.StatusForbidden, responseutil.HTTP_FORBIDDEN, "only the platform admins can review the expansion")
+		return
+	}
+
+	id := c.Param("id")
+	if err := validation.IsDNS1123Name(id); err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	cm, r, err := m.getExpansion(ctx, id)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("expansion request: %s not found", id))
+			return
+		}
+		klog.Errorf("get expansion request: %s error: %v", id, err)
+		resp.RespError("get expansion request error")
+		return
+	}
+
+	if r.Status != model.ExpansionPending {
+		resp.RespErrorCode(http.StatusConflict, responseutil.HTTP_CONFLICT, fmt.Sprintf("expansion request is %s", r.Status))
+		return
+	}
+	if user == r.Requester {
+		resp.RespErrorCode(http.StatusForbidden, responseutil.HTTP_FORBIDDEN, "the requester can not review the request")
+		return
+	}
+
+	now := time.Now().UTC()
+	r.Approver = user
+	r.ApprovedAt = &now
+	if approve {
+		klog.Infof("expansion: %s cluster: %s approved by: %s", r.ID, r.Cluster, user)
+		m.finishExpansion(ctx, r)
+	} else {
+		klog.Infof("expansion: %s cluster: %s rejected by: %s", r.ID, r.Cluster, user)
+		r.Status = model.ExpansionRejected
+		err = m.Cluster.GetClient().Delete(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: ConfigMapName, Name: expansionName(r.ID)}})
+		if err != nil && !apierrors.IsNotFound(err) {
+			klog.Warningf("delete expansion: %s secret err: %v", r.ID, err)
+		}
+	}
+
+	err = m.saveExpansion(ctx, cm, r)
+	if err != nil {
+		klog.Errorf("update expansion request: %s error: %v", id, err)
+		resp.RespError("update expansion request error")
+		return
+	}
+
+	resp.RespSuccess(true, "success", r, 1)
+}
+
+// 批准扩容申请, 从机柜分配机器并加入集群
+func (m *Manager) ApproveExpansion(c *gin.Context) {
+	m.reviewExpansion(c, true)
+}
+
+// 拒绝扩容申请
+func (m *Manager) RejectExpansion(c *gin.Context) {
+	m.reviewExpansion(c, false)
+}
@@ -0,0 +1,289 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/phases/certs"
+	"github.com/gostship/kunkka/pkg/util/authutil"
+	"github.com/gostship/kunkka/pkg/util/k8sutil"
+	"github.com/gostship/kunkka/pkg/util/pkiutil"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	"github.com/pkg/errors"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const extKubeconfigAction = "EnsureExtKubeconfig"
+
+// 重新生成集群的 admin kubeconfig, controller 用新的 client 证书生成 kubeconfig 并吊销之前的
+func (m *Manager) regenerateKubeConfig(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Param("name")
+
+	ctx := context.Background()
+	cli := m.Cluster.GetClient()
+	cluster := &devopsv1.Cluster{}
+	err := cli.Get(ctx, types.NamespacedName{Namespace: name, Name: name}, cluster)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("cluster: %s not found", name))
+			return
+		}
+		klog.Errorf("get cluster: %s error: %v", name, err)
+		resp.RespError("get cluster error")
+		return
+	}
+	if cluster.Status.Phase != devopsv1.ClusterRunning {
+		resp.RespErrorCode(http.StatusConflict, responseutil.HTTP_CONFLICT, fmt.Sprintf("cluster: %s is %s", name, cluster.Status.Phase))
+		return
+	}
+
+	gen, _ := strconv.Atoi(constants.GetAnnotationKey(cluster.Annotations, constants.ClusterKubeconfigGeneration))
+	gen++
+	if cluster.Annotations == nil {
+		cluster.Annotations = map[string]string{}
+	}
+	cluster.Annotations[constants.ClusterKubeconfigGeneration] = strconv.Itoa(gen)
+	actions := cluster.Annotations[constants.ClusterAnnotationAction]
+	if !strings.Contains(actions, extKubeconfigAction) {
+		if actions != "" {
+			actions += ","
+		}
+		cluster.Annotations[constants.ClusterAnnotationAction] = actions + extKubeconfigAction
+	}
+
+	err = cli.Update(ctx, cluster)
+	if err != nil {
+		if apierrors.IsConflict(err) {
+			resp.RespErrorCode(http.StatusConflict, responseutil.HTTP_CONFLICT, err.Error())
+			return
+		}
+		klog.Errorf("update cluster: %s kubeconfig generation error: %v", name, err)
+		resp.RespError("update cluster error")
+		return
+	}
+
+	klog.Infof("cluster: %s admin kubeconfig regeneration requested, generation: %d", name, gen)
+	resp.RespSuccess(true, "success", map[string]interface{}{"cluster": name, "generation": gen}, 1)
+}
+
+// 签发受限 kubeconfig, 绑定到当前用户及指定的 ClusterRole, 客户端证书在 ttl 后过期
+func (m *Manager) issueScopedKubeConfig(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Param("name")
+
+	user, err := authutil.RequestUser(c)
+	if err != nil {
+		resp.RespErrorCode(http.StatusUnauthorized, responseutil.ERROR_AUTH_CHECK_TOKEN_FAIL, err.Error())
+		return
+	}
+	if user.Name == "" || strings.ContainsAny(user.Name, "/%") {
+		resp.RespErrorCode(http.StatusUnauthorized, responseutil.ERROR_AUTH_CHECK_TOKEN_FAIL, fmt.Sprintf("invalid user: %q", user.Name))
+		return
+	}
+
+	req := &model.ScopedKubeconfigRequest{}
+	err = c.ShouldBindJSON(req)
+	if err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+	err = req.Sanitize()
+	if err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	cli := m.Cluster.GetClient()
+	cluster := &devopsv1.Cluster{}
+	err = cli.Get(ctx, types.NamespacedName{Namespace: name, Name: name}, cluster)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("cluster: %s not found", name))
+			return
+		}
+		klog.Errorf("get cluster: %s error: %v", name, err)
+		resp.RespError("get cluster error")
+		return
+	}
+	if cluster.Status.Phase != devopsv1.ClusterRunning {
+		resp.RespErrorCode(http.StatusConflict, responseutil.HTTP_CONFLICT, fmt.Sprintf("cluster: %s is %s", name, cluster.Status.Phase))
+		return
+	}
+
+	clusterCtx, err := m.Cluster.Get(name)
+	if err != nil {
+		klog.Errorf("get cluster: %s client error: %v", name, err)
+		resp.RespErr(responseutil.ClusterUnavailable("%v", err))
+		return
+	}
+	err = clusterCtx.Client.Get(ctx, types.NamespacedName{Name: req.ClusterRole}, &rbacv1.ClusterRole{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, fmt.Sprintf("clusterRole: %s not found", req.ClusterRole))
+			return
+		}
+		klog.Errorf("get cluster: %s clusterRole: %s error: %v", name, req.ClusterRole, err)
+		resp.RespError("get clusterRole error")
+		return
+	}
+
+	logger := ctrl.Log.WithValues("cluster", name)
+	err = k8sutil.Reconcile(logger, clusterCtx.Client, certs.BuildScopedBinding(user.Name, req.ClusterRole), k8sutil.DesiredStatePresent)
+	if err != nil {
+		klog.Errorf("apply cluster: %s scoped binding for user: %s error: %v", name, user.Name, err)
+		resp.RespError("apply clusterRoleBinding error")
+		return
+	}
+
+	cls, err := common.GetCluster(ctx, cli, cluster, m.Cluster)
+	if err != nil {
+		klog.Errorf("get cluster: %s credential error: %v", name, err)
+		resp.RespError("get cluster credential error")
+		return
+	}
+	issuer, err := certs.ClusterIssuer(cls)
+	if err != nil {
+		klog.Errorf("get cluster: %s issuer error: %v", name, err)
+		resp.RespError("get cluster issuer error")
+		return
+	}
+	cfg, err := certs.CreateScopedKubeConfig(issuer, cls.ClusterCredential.CACert, clusterCtx.RestConfig.Host, name, user.Name, req.TTL())
+	if err != nil {
+		klog.Errorf("create cluster: %s scoped kubeconfig error: %v", name, err)
+		resp.RespError("create kubeconfig error")
+		return
+	}
+	data, err := certs.BuildKubeConfigByte(cfg)
+	if err != nil {
+		resp.RespError("encode kubeconfig error")
+		return
+	}
+
+	klog.Infof("cluster: %s scoped kubeconfig issued to user: %s clusterRole: %s ttl: %s, source: %s", name, user.Name, req.ClusterRole, req.TTL(), c.ClientIP())
+	resp.RespSuccess(true, "success", &model.ScopedKubeconfig{
+		User:        certs.ScopedUser(user.Name),
+		ClusterRole: req.ClusterRole,
+		ExpiresAt:   time.Now().Add(req.TTL()).UTC(),
+		Kubeconfig:  string(data),
+	}, 1)
+}
+
+// 下载集群的 admin kubeconfig, ttl(如 1h, 最长 24h)指定时签发 ttl 后过期的 kubeconfig, 否则返回 controller 生成的 kubeconfig;
+// download=true 时以附件返回 kubeconfig 文件. 每次下载都记录到审计日志
+func (m *Manager) downloadKubeConfig(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Param("name")
+
+	var ttl time.Duration
+	if s := c.Query("ttl"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 || d > model.MaxAdminKubeconfigTTL {
+			resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS,
+				fmt.Sprintf("ttl: %q must be a duration up to %s", s, model.MaxAdminKubeconfigTTL))
+			return
+		}
+		ttl = d
+	}
+
+	ctx := context.Background()
+	cli := m.Cluster.GetClient()
+	cluster := &devopsv1.Cluster{}
+	err := cli.Get(ctx, types.NamespacedName{Namespace: name, Name: name}, cluster)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("cluster: %s not found", name))
+			return
+		}
+		klog.Errorf("get cluster: %s error: %v", name, err)
+		resp.RespError("get cluster error")
+		return
+	}
+	cls, err := common.GetCluster(ctx, cli, cluster, m.Cluster)
+	if err != nil {
+		klog.Errorf("get cluster: %s credential error: %v", name, err)
+		resp.RespError("get cluster credential error")
+		return
+	}
+	ext := cls.ClusterCredential.ExtData[pkiutil.ExternalAdminKubeConfigFileName]
+	if ext == "" {
+		resp.RespErrorCode(http.StatusConflict, responseutil.HTTP_CONFLICT, fmt.Sprintf("cluster: %s has no admin kubeconfig yet", name))
+		return
+	}
+
+	// the kubeconfig of the managed cluster is supplied, there is no CA to sign another one with
+	if ttl > 0 && cluster.Spec.Managed != nil {
+		resp.RespErrorCode(http.StatusConflict, responseutil.HTTP_CONFLICT, fmt.Sprintf("cluster: %s is managed, its kubeconfig can't expire", name))
+		return
+	}
+
+	kc := &model.ClusterKubeconfig{Cluster: name, Generation: certs.ExternalKubeconfigGeneration(ext), Kubeconfig: ext}
+	if ttl > 0 {
+		data, expiresAt, err := mintKubeConfig(cls, ext, kc.Generation, ttl)
+		if err != nil {
+			klog.Errorf("create cluster: %s expiring admin kubeconfig error: %v", name, err)
+			resp.RespError("create kubeconfig error")
+			return
+		}
+		kc.Kubeconfig = string(data)
+		kc.ExpiresAt = &expiresAt
+		auditDetail(c, "admin kubeconfig generation: %d expires at: %s", kc.Generation, expiresAt.Format(time.RFC3339))
+	} else {
+		auditDetail(c, "admin kubeconfig generation: %d", kc.Generation)
+	}
+
+	klog.Infof("cluster: %s admin kubeconfig generation: %d downloaded by user: %s ttl: %s, source: %s",
+		name, kc.Generation, requestUserName(c), ttl, c.ClientIP())
+	if c.Query("download") == "true" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".kubeconfig"))
+		c.Data(http.StatusOK, "application/yaml", []byte(kc.Kubeconfig))
+		return
+	}
+	resp.RespSuccess(true, "success", kc, 1)
+}
+
+// mintKubeConfig creates an admin kubeconfig of the generation expiring after the ttl, with the apiserver of
+// the kubeconfig generated by the controller.
+func mintKubeConfig(cls *common.Cluster, ext string, generation int, ttl time.Duration) ([]byte, time.Time, error) {
+	cfg, err := clientcmd.Load([]byte(ext))
+	if err != nil {
+		return nil, time.Time{}, errors.Wrap(err, "load admin kubeconfig")
+	}
+	var apiserver string
+	for _, cluster := range cfg.Clusters {
+		apiserver = cluster.Server
+	}
+	if ctx, ok := cfg.Contexts[cfg.CurrentContext]; ok && cfg.Clusters[ctx.Cluster] != nil {
+		apiserver = cfg.Clusters[ctx.Cluster].Server
+	}
+	if apiserver == "" {
+		return nil, time.Time{}, errors.New("admin kubeconfig has no apiserver")
+	}
+
+	issuer, err := certs.ClusterIssuer(cls)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	expiresAt := time.Now().Add(ttl).UTC()
+	minted, err := certs.CreateExpiringExternalKubeConfig(issuer, cls.ClusterCredential.CACert, apiserver, cls.Name, generation, ttl)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := certs.BuildKubeConfigByte(minted)
+	return data, expiresAt, err
+}
@@ -1,12 +1,47 @@
 package v1
 
 import (
+	"github.com/gostship/kunkka/pkg/apimanager/apitoken"
+	"github.com/gostship/kunkka/pkg/apimanager/auditlog"
+	"github.com/gostship/kunkka/pkg/apimanager/clusterdeletion"
+	"github.com/gostship/kunkka/pkg/apimanager/keyrotation"
+	"github.com/gostship/kunkka/pkg/apimanager/noderemoval"
+	"github.com/gostship/kunkka/pkg/apimanager/openapi"
+	"github.com/gostship/kunkka/pkg/apimanager/progress"
 	"github.com/gostship/kunkka/pkg/controllers/k8smanager"
+	"github.com/gostship/kunkka/pkg/storage"
 	"sync"
 )
 
 type Manager struct {
 	Cluster *k8smanager.ClusterManager
+	// EscrowNamespace holds the escrowed credentials and the break glass requests
+	EscrowNamespace string
+	// ExpansionApprovers the platform admins who review the expansion requests, anyone but the requester if empty
+	ExpansionApprovers []string
+	// AuthModes the enabled auth modes, reported by the capabilities
+	AuthModes []string
+	// Features the feature flags of the deployment, reported by the capabilities
+	Features map[string]bool
+	// Store keeps the operational data which doesn't fit into the CRDs
+	Store storage.Store
+	// PlatformAdmins the users bound to admin of all the clusters besides the role bindings configmap
+	PlatformAdmins []string
+	// Tokens keeps the api tokens of the automation clients
+	Tokens *apitoken.Store
+	// Audit records the mutating operations in the Store
+	Audit *auditlog.Recorder
+	// KeyRotator rotates the ssh keys of the machines of the clusters
+	KeyRotator *keyrotation.Rotator
+	// Deleter requests, confirms and finishes the deletions of the clusters
+	Deleter *clusterdeletion.Deleter
+	// Remover drains and deletes the nodes of the clusters
+	Remover *noderemoval.Remover
+	// Progress broadcasts the phase and condition changes of the clusters and the machines
+	Progress *progress.Hub
+
+	openAPIOnce sync.Once
+	openAPI     *openapi.Document
 	//Monitor map[string]*prometheus.Prometheus
 	sync.RWMutex
 }
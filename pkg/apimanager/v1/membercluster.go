@@ -2,7 +2,6 @@ package v1
 
 import (
 	"context"
-	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/gostship/kunkka/pkg/util/metautil"
 	"github.com/gostship/kunkka/pkg/util/responseutil"
@@ -23,7 +22,7 @@ func (m *Manager) GetMemberMetaData(c *gin.Context) {
 
 	cli, err := m.getClient(clusterName)
 	if err != nil {
-		resp.RespError("get cluster client error.")
+		resp.RespErr(err)
 		return
 	}
 
@@ -35,10 +34,10 @@ func (m *Manager) GetMemberMetaData(c *gin.Context) {
 
 	if err != nil {
 		if apierrors.IsNotFound(err) {
-			err = errors.New("cluster namespace is not found.")
+			err = responseutil.NotFound("cluster namespace is not found.")
 		}
 		klog.Error(err)
-		resp.RespError(err.Error())
+		resp.RespErr(err)
 		return
 	}
 
@@ -64,7 +63,7 @@ func (m *Manager) GetNodeCount(c *gin.Context) {
 
 	cli, err := m.getClient(clsName)
 	if err != nil {
-		resp.RespError("get cluster client error")
+		resp.RespErr(err)
 		return
 	}
 
@@ -85,7 +84,7 @@ func (m *Manager) TestGet(c *gin.Context) {
 
 	cli, err := m.getClient(cliName)
 	if err != nil {
-		resp.RespError("get cluster client error")
+		resp.RespErr(err)
 		return
 	}
 
@@ -109,7 +108,7 @@ func (m *Manager) getKubeConfig(c *gin.Context) {
 	cli, err := m.getClient(clsName)
 	if err != nil {
 		klog.Error("get client errors.", err)
-		resp.RespError("get client error.")
+		resp.RespErr(err)
 		return
 	}
 	ctx := context.Background()
@@ -3,21 +3,28 @@ package v1
 import (
 	"context"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"github.com/ghodss/yaml"
 	"github.com/gin-gonic/gin"
 	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/apimanager/webhook"
 	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/tracing"
+	"github.com/gostship/kunkka/pkg/trends"
 	"github.com/gostship/kunkka/pkg/util/crdutil"
 	"github.com/gostship/kunkka/pkg/util/k8sutil"
+	"net/http"
 
 	"github.com/gostship/kunkka/pkg/util/metautil"
 	"github.com/gostship/kunkka/pkg/util/responseutil"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"strconv"
 )
 
@@ -40,8 +47,8 @@ func (m *Manager) GetClusterVersion(c *gin.Context) {
 	}, cmList)
 
 	if err != nil {
-		klog.Error("Get ConfigMap error %v: ", err)
-		resp.RespError("can't found clusterVersion configMap, please create!")
+		klog.Errorf("Get ConfigMap error: %v", err)
+		resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, "can't found clusterVersion configMap, please create!")
 		return
 	}
 
@@ -50,7 +57,7 @@ func (m *Manager) GetClusterVersion(c *gin.Context) {
 	// 将yaml转换为json
 	yamlToRack, err := yaml.YAMLToJSON([]byte(data))
 	if err != nil {
-		klog.Errorf("yamlToJson error", err)
+		klog.Errorf("yamlToJson error: %v", err)
 		resp.RespError("yamlToJson error")
 		return
 	}
@@ -65,28 +72,41 @@ func (m *Manager) GetClusterVersion(c *gin.Context) {
 	resp.RespSuccess(true, "success", cms, len(cms))
 }
 
-// get list of cluster
+// get list of cluster, 支持分页(page, limit)、排序(sortBy)及按 phase、version、rack 过滤, 返回过滤后的总数
 func (m *Manager) getClusterList(c *gin.Context) {
-	lable := c.Query("labelSelector")
-
 	resp := responseutil.Gin{Ctx: c}
 
+	selector, err := parseClusterSelector(c.Query("labelSelector"))
+	if err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+	q, err := parseClusterQuery(c)
+	if err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+
 	cli := m.Cluster.GetClient()
 	ctx := context.Background()
 
 	clusters := &devopsv1.ClusterList{}
 	clusterList := []*devopsv1.Cluster{}
 
-	err := cli.List(ctx, clusters)
+	err = cli.List(ctx, clusters, client.MatchingLabelsSelector{Selector: selector})
 
 	if err != nil {
 		if apierrors.IsNotFound(err) {
-			err = errors.New("cluster is not found.")
+			err = responseutil.NotFound("cluster is not found.")
 		}
 		klog.Error(err)
-		resp.RespError(err.Error())
+		resp.RespErr(err)
 		return
 	}
+	for i := range clusters.Items {
+		clusterList = append(clusterList, &clusters.Items[i])
+	}
+
 	// append meta cluster
 	metaObj, err := metautil.BuildMetaObj()
 	if err != nil {
@@ -102,19 +122,99 @@ func (m *Manager) getClusterList(c *gin.Context) {
 		resp.RespError("build extend cluster error!")
 		return
 	}
-	clusters.Items = append(clusters.Items, extendObj...)
-	clusters.Items = append(clusters.Items, *metaObj)
+	extendObj = append(extendObj, *metaObj)
 
-	for i := 0; i < len(clusters.Items); i++ {
-		clusters.Items[i].Status.NodeCount = m.getCount(clusters.Items[i].Name)
-		if lable == "meta" && clusters.Items[i].Labels["cluster-role.kunkka.io/cluster-role"] == lable {
-			clusterList = append(clusterList, &clusters.Items[i])
+	// extend 及 meta 集群不是 Cluster 资源, 在内存中按 selector 过滤
+	for i := range extendObj {
+		if selector.Matches(labels.Set(extendObj[i].Labels)) {
+			clusterList = append(clusterList, &extendObj[i])
 		}
-		if lable == "member" && clusters.Items[i].Labels["cluster-role.kunkka.io/cluster-role"] == lable {
-			clusterList = append(clusterList, &clusters.Items[i])
+	}
+
+	clusterList = q.Filter(clusterList)
+	// 只有按节点数排序时才需要全部集群的节点数
+	if q.SortByNodeCount() {
+		m.fillNodeCount(clusterList)
+	}
+	q.Sort(clusterList)
+	page := q.Paginate(clusterList)
+	if !q.SortByNodeCount() {
+		m.fillNodeCount(page)
+	}
+	resp.RespSuccess(true, "success", page, len(clusterList))
+}
+
+// parseClusterSelector 解析 kubernetes label selector, 如 "region=bj,env in (prod,staging),!deprecated",
+// 兼容原来的 "meta"、"member", 即按集群角色过滤, 为空时不过滤
+func parseClusterSelector(s string) (labels.Selector, error) {
+	if s == "meta" || s == "member" {
+		return labels.SelectorFromSet(labels.Set{constants.ClusterRoleLabel: s}), nil
+	}
+	selector, err := labels.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("labelSelector: %v", err)
+	}
+	return selector, nil
+}
+
+func parseClusterQuery(c *gin.Context) (*model.ClusterQuery, error) {
+	q := &model.ClusterQuery{
+		Page:    1,
+		SortBy:  c.Query("sortBy"),
+		Phase:   c.Query("phase"),
+		Version: c.Query("version"),
+		Rack:    c.Query("rack"),
+
+		Reachable: c.Query("reachable"),
+	}
+	if s := c.Query("page"); s != "" {
+		page, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("page: invalid number %s", s)
 		}
+		q.Page = page
+	}
+	if s := c.Query("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("limit: invalid number %s", s)
+		}
+		q.Limit = limit
+	}
+	return q, q.Validate()
+}
+
+// fillNodeCount 以集群 node informer 维护的节点数填充 NodeCount, 不逐个查询集群的节点;
+// 集群未连接(如创建中)或 informer 未同步的, 以最近一次采样的节点数填充
+func (m *Manager) fillNodeCount(clusters []*devopsv1.Cluster) {
+	missing := []*devopsv1.Cluster{}
+	for _, cluster := range clusters {
+		n, ok := m.getCount(cluster.Name)
+		cluster.Status.NodeCount = n
+		if !ok {
+			missing = append(missing, cluster)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	cms := &corev1.ConfigMapList{}
+	err := m.Cluster.GetClient().List(context.Background(), cms, client.HasLabels{constants.ClusterTrendsLabel})
+	if err != nil {
+		klog.Errorf("list cluster trends error: %v", err)
+	}
+	counts := map[string]int{}
+	for i := range cms.Items {
+		samples, err := trends.Decode(&cms.Items[i])
+		if err != nil || len(samples) == 0 || samples[len(samples)-1].Nodes < 0 {
+			continue
+		}
+		counts[cms.Items[i].Labels[constants.ClusterTrendsLabel]] = samples[len(samples)-1].Nodes
+	}
+	for _, cluster := range missing {
+		cluster.Status.NodeCount = counts[cluster.Name]
 	}
-	resp.RespSuccess(true, "success", clusterList, len(clusterList))
 }
 
 // add member cluster
@@ -129,7 +229,11 @@ func (m *Manager) AddCluster(c *gin.Context) {
 	cluster, err := resp.Bind(newCluster)
 	if err != nil {
 		klog.Error("add cluster bind params error.", err)
-		resp.RespError("add cluster faild params.")
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_REQUEST_BIND_ERROR, err.Error())
+		return
+	}
+	if err := cluster.(*model.AddCluster).Sanitize(); err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
 		return
 	}
 	if cluster.(*model.AddCluster).ClusterType == "Baremetal" {
@@ -144,14 +248,14 @@ func (m *Manager) AddCluster(c *gin.Context) {
 
 	if cluster.(*model.AddCluster).ClusterType == "Baremetal" && len(listRack) != len(cluster.(*model.AddCluster).ClusterIP) {
 		klog.Error("address list lerge rack list!")
-		resp.RespError("address list lerge rack list!")
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, "address list lerge rack list!")
 		return
 	}
 
 	// 导入外部集群
 	if cluster.(*model.AddCluster).ClusterType == "Include" {
 		// 将配置持久化存储到meta集群
-		klog.Info("cluster %s is extend.", cluster.(*model.AddCluster).ClusterName)
+		klog.Infof("cluster %s is extend.", cluster.(*model.AddCluster).ClusterName)
 
 		// 生成CRD对象
 		err := crdutil.BuildExtendCrd(cluster.(*model.AddCluster), cli)
@@ -206,132 +310,71 @@ func (m *Manager) AddCluster(c *gin.Context) {
 		return
 	}
 
+	err = m.validateWebhooks(webhook.OperationAddCluster, cluster.(*model.AddCluster).ClusterName, cls)
+	if err != nil {
+		klog.Errorf("validate cluster: %s error: %v", cluster.(*model.AddCluster).ClusterName, err)
+		if webhook.IsDenied(err) {
+			resp.RespErrorCode(http.StatusForbidden, responseutil.HTTP_FORBIDDEN, err.Error())
+			return
+		}
+		resp.RespError("validate cluster webhook error")
+		return
+	}
+
+	ctx, span := tracing.Start(c.Request.Context(), "create cluster", tracing.ClusterKey.String(cluster.(*model.AddCluster).ClusterName))
+	injectTrace(ctx, cls)
 	logger := ctrl.Log.WithValues("cluster", cluster.(*model.AddCluster).ClusterName)
 	logger.Info("create cluster reconcile ...")
 	for _, obj := range cls {
 		err := k8sutil.Reconcile(logger, cli, obj, k8sutil.DesiredStatePresent)
 		if err != nil {
+			tracing.End(span, err)
 			resp.RespError("create cluster reconcile error")
 			return
 		}
 	}
+	tracing.End(span, nil)
 	resp.RespSuccess(true, "success", "OK", 0)
 }
 
 // get meta cluster detail
 func (m *Manager) GetClusterDetail(c *gin.Context) {
-	name := c.Query("name")
-
 	resp := responseutil.Gin{Ctx: c}
-
-	cli := m.Cluster.GetClient()
-	ctx := context.Background()
-
-	clusters := &devopsv1.ClusterList{}
-	clusterDetail := &devopsv1.Cluster{}
-
-	err := cli.List(ctx, clusters)
-
+	cluster, err := m.findCluster(context.Background(), c.Query("name"))
 	if err != nil {
-		if apierrors.IsNotFound(err) {
-			err = errors.New("cluster is not found.")
-		}
-		klog.Error(err)
-		resp.RespError(err.Error())
-		return
-	}
-	// append meta cluster
-	metaObj, err := metautil.BuildMetaObj()
-	if err != nil {
-		klog.Error("build meta cluster error")
-		resp.RespError("build meta cluster error!")
+		respClusterError(&resp, err)
 		return
 	}
-
-	// append extend cluster
-	extendCls, err := metautil.BuildExtendObj(cli)
-	if err != nil {
-		klog.Error("build extend cluster error.")
-		resp.RespError("build extend cluster error.")
-		return
-	}
-
-	clusters.Items = append(clusters.Items, extendCls...)
-	clusters.Items = append(clusters.Items, *metaObj)
-
-	for _, cls := range clusters.Items {
-		if cls.Name == name {
-			clusterDetail = &cls
-			break
-		}
-	}
-
-	resp.RespSuccess(true, "success", clusterDetail, 1)
+	resp.RespSuccess(true, "success", cluster, 1)
 }
 
 // get cluster conditions
 func (m *Manager) GetClusterCondition(c *gin.Context) {
 	resp := responseutil.Gin{Ctx: c}
-	clusterName := c.Query("clusterName")
-	clusterType := c.Query("clusterType")
-
-	cli := m.Cluster.GetClient()
-	ctx := context.Background()
-
-	resultList := []*model.RuntimeCondition{}
-
-	cluster := &devopsv1.Cluster{}
-
-	err := cli.Get(ctx, types.NamespacedName{
-		Namespace: clusterName,
-		Name:      clusterName,
-	}, cluster)
-
+	cluster, err := m.findCluster(context.Background(), c.Query("clusterName"))
 	if err != nil {
-		if apierrors.IsNotFound(err) {
-			err = errors.New("cluster is not found.")
-		}
-		klog.Error(err)
-		resp.RespError(err.Error())
+		respClusterError(&resp, err)
 		return
 	}
-
-	for _, condit := range metautil.ProviderClusterSteps(clusterType) {
-		resultList = append(resultList, metautil.ClusterConditionOfContains(cluster.Status.Conditions, condit))
-	}
+	resultList := clusterConditions(cluster, c.Query("clusterType"))
 	resp.RespSuccess(true, "success", resultList, len(resultList))
 }
 
 // get node getNodeCondition
 func (m *Manager) getNodeCondition(c *gin.Context) {
 	resp := responseutil.Gin{Ctx: c}
-	clusterName := c.Query("clusterName")
-	ipAddr := c.Query("ipAddr")
-
-	cli := m.Cluster.GetClient()
 	ctx := context.Background()
-
-	resultList := []*model.RuntimeCondition{}
-
-	cluster := &devopsv1.Machine{}
-
-	err := cli.Get(ctx, types.NamespacedName{
-		Namespace: clusterName,
-		Name:      ipAddr,
-	}, cluster)
-
+	cluster, err := m.findCluster(ctx, c.Query("clusterName"))
 	if err != nil {
-		if apierrors.IsNotFound(err) {
-			err = errors.New("machine is not found.")
-		}
-		klog.Error(err)
-		resp.RespError(err.Error())
+		respClusterError(&resp, err)
 		return
 	}
-
-	for _, condit := range metautil.ProviderClusterSteps("Machine") {
-		resultList = append(resultList, metautil.MachineConditionOfContains(cluster.Status.Conditions, condit))
+	machine, err := m.getMachine(ctx, cluster, c.Query("ipAddr"))
+	if err != nil {
+		respClusterError(&resp, err)
+		return
 	}
+	resultList := machineConditions(machine)
 	resp.RespSuccess(true, "success", resultList, len(resultList))
 }
 
@@ -341,7 +384,7 @@ func (m *Manager) GetClusterCounts(c *gin.Context) {
 
 	cli, err := m.getClient(clusterName)
 	if err != nil {
-		resp.RespError("get cluster client error.")
+		resp.RespErr(err)
 		return
 	}
 
@@ -378,16 +421,19 @@ func (m *Manager) GetClusterCounts(c *gin.Context) {
 	resp.RespSuccess(true, "success", clusterCount, len(clusterCount))
 }
 
-func (m *Manager) getCount(name string) int {
-	cli, err := m.getClient(name)
-	if cli == nil || err != nil { // 如果是创建新的集群,此时client还未缓存,直接返回默认值。
-		return 0
+// getCount returns the node count of the cluster kept by its node informer, false if the cluster is not
+// connected or its informer is not synced. The nodes of the meta cluster are read from its cache.
+func (m *Manager) getCount(name string) (int, bool) {
+	if name == MetaClusterName {
+		nodes := &corev1.NodeList{}
+		if err := m.Cluster.GetClient().List(context.Background(), nodes); err != nil {
+			return 0, false
+		}
+		return len(nodes.Items), true
 	}
-	nodes := &corev1.NodeList{}
-	ctx := context.Background()
-	err = cli.List(ctx, nodes)
+	cls, err := m.Cluster.Get(name)
 	if err != nil {
-		return 0
+		return 0, false
 	}
-	return len(nodes.Items)
+	return cls.NodeCount()
 }
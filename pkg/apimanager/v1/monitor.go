@@ -5,6 +5,7 @@ import (
 	"github.com/gostship/kunkka/pkg/apimanager/model/monit"
 	"github.com/gostship/kunkka/pkg/provider/monitoring"
 	"github.com/gostship/kunkka/pkg/util/responseutil"
+	"net/http"
 	"regexp"
 )
 
@@ -14,7 +15,7 @@ func (m *Manager) getNodeMonitor(c *gin.Context) {
 	params := parseRequestParams(c)
 	opt, err := makeQueryOptions(m, params, monitoring.LevelNode)
 	if err != nil {
-		resp.RespError("make node query options error")
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
 		return
 	}
 	m.handleNameMetricsQuery(c, opt)
@@ -25,7 +26,7 @@ func (m *Manager) getClusterMonitor(c *gin.Context) {
 	params := parseRequestParams(c)
 	opt, err := makeQueryOptions(m, params, monitoring.LevelCluster)
 	if err != nil {
-		resp.RespError("make cluster options error")
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
 		return
 	}
 	m.handleNameMetricsQuery(c, opt)
@@ -36,7 +37,7 @@ func (m *Manager) getNodePods(c *gin.Context) {
 	params := parseRequestParams(c)
 	opt, err := makeQueryOptions(m, params, monitoring.LevelPod)
 	if err != nil {
-		resp.RespError("make pod monitor option error")
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
 		return
 	}
 	m.handleNameMetricsQuery(c, opt)
@@ -48,7 +49,7 @@ func (m *Manager) getApiserverMonitor(c *gin.Context) {
 	params := parseRequestParams(c)
 	opt, err := makeQueryOptions(m, params, monitoring.LevelComponent)
 	if err != nil {
-		resp.RespError("make comonent options error")
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
 		return
 	}
 	m.handleNameMetricsQuery(c, opt)
@@ -58,7 +59,7 @@ func (m *Manager) handleNameMetricsQuery(c *gin.Context, q queryOptions) {
 	resp := responseutil.Gin{Ctx: c}
 	cli, err := m.Cluster.GetMonitor(c.Param("name"))
 	if err != nil {
-		resp.RespError("get monitor client error")
+		resp.RespErr(responseutil.ClusterUnavailable("%v", err))
 		return
 	}
 	var res monit.Metrics
@@ -90,7 +91,7 @@ func (m *Manager) getClusterNsMonitor(c *gin.Context) {
 	params := parseRequestParams(c)
 	opt, err := makeQueryOptions(m, params, monitoring.LevelNamespace)
 	if err != nil {
-		resp.RespError("make namespace option err")
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
 		return
 	}
 	m.handleNameMetricsQuery(c, opt)
@@ -101,7 +102,7 @@ func (m *Manager) getClusterNsPodsMonitor(c *gin.Context) {
 	params := parseRequestParams(c)
 	opt, err := makeQueryOptions(m, params, monitoring.LevelPod)
 	if err != nil {
-		resp.RespError("make namespace option err")
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
 		return
 	}
 	m.handleNameMetricsQuery(c, opt)
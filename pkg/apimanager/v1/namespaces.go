@@ -0,0 +1,272 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/apimanager/rbac"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/util/parallel"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+)
+
+// 在多个集群中创建命名空间, 可选地应用 ResourceQuota 及 LimitRange 模板, 已存在的命名空间合并 labels 及 annotations;
+// 各集群并发执行, 返回每个集群的结果, 需要每个集群的 operator 权限
+func (m *Manager) createClusterNamespaces(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	req := &model.NamespaceRequest{}
+	if _, err := resp.Bind(req); err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+	if err := req.Sanitize(); err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+	if !m.authorizeClusters(c, req.Clusters, rbac.RoleOperator) {
+		return
+	}
+
+	results := make([]*model.NamespaceResult, len(req.Clusters))
+	_ = parallel.Run(len(req.Clusters), parallel.Concurrency(), func(i int) error {
+		results[i] = &model.NamespaceResult{Cluster: req.Clusters[i], Name: req.Name}
+		status, err := m.ensureNamespace(req.Clusters[i], req)
+		if err != nil {
+			klog.Errorf("cluster: %s create namespace: %s error: %v", req.Clusters[i], req.Name, err)
+			results[i].Status = model.NamespaceFailed
+			results[i].Message = err.Error()
+			return nil
+		}
+		results[i].Status = status
+		return nil
+	})
+	resp.RespSuccess(true, "success", results, len(results))
+}
+
+// 列出多个集群的命名空间, clusters 以逗号分隔, 可按 name 过滤; 不可达的集群记入 message 而不会失败
+func (m *Manager) listClusterNamespaces(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	clusters, err := model.SanitizeClusters(splitQuery(c.Query("clusters")))
+	if err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+	name := c.Query("name")
+	if !m.authorizeClusters(c, clusters, rbac.RoleViewer) {
+		return
+	}
+
+	lists := make([][]*model.ClusterNamespace, len(clusters))
+	errs := make([]string, len(clusters))
+	_ = parallel.Run(len(clusters), parallel.Concurrency(), func(i int) error {
+		kubeCli, _ := m.getClientInterface(clusters[i])
+		if kubeCli == nil {
+			errs[i] = fmt.Sprintf("cluster: %s not found", clusters[i])
+			return nil
+		}
+		nsList, err := kubeCli.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			klog.Errorf("cluster: %s list namespaces error: %v", clusters[i], err)
+			errs[i] = fmt.Sprintf("cluster: %s list namespaces error", clusters[i])
+			return nil
+		}
+		for _, ns := range nsList.Items {
+			if name != "" && ns.Name != name {
+				continue
+			}
+			lists[i] = append(lists[i], &model.ClusterNamespace{
+				Cluster:      clusters[i],
+				Name:         ns.Name,
+				Phase:        string(ns.Status.Phase),
+				Labels:       ns.Labels,
+				CreationTime: ns.CreationTimestamp,
+			})
+		}
+		return nil
+	})
+
+	result := []*model.ClusterNamespace{}
+	for i := range lists {
+		result = append(result, lists[i]...)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].Cluster < result[j].Cluster
+	})
+
+	msg := "success"
+	if failed := nonEmpty(errs); len(failed) > 0 {
+		msg = strings.Join(failed, "; ")
+	}
+	resp.RespSuccess(true, msg, result, len(result))
+}
+
+// 删除多个集群中的命名空间, clusters 以逗号分隔, 不可删除 default 及 kube-* 系统命名空间
+func (m *Manager) deleteClusterNamespaces(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name, err := model.SanitizeNamespace(c.Param("namespace"))
+	if err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+	clusters, err := model.SanitizeClusters(splitQuery(c.Query("clusters")))
+	if err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+	if !m.authorizeClusters(c, clusters, rbac.RoleOperator) {
+		return
+	}
+
+	results := make([]*model.NamespaceResult, len(clusters))
+	_ = parallel.Run(len(clusters), parallel.Concurrency(), func(i int) error {
+		results[i] = &model.NamespaceResult{Cluster: clusters[i], Name: name, Status: model.NamespaceDeleted}
+		kubeCli, _ := m.getClientInterface(clusters[i])
+		if kubeCli == nil {
+			results[i].Status = model.NamespaceFailed
+			results[i].Message = fmt.Sprintf("cluster: %s not found", clusters[i])
+			return nil
+		}
+		err := kubeCli.CoreV1().Namespaces().Delete(context.Background(), name, metav1.DeleteOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			results[i].Status = model.NamespaceNotFound
+		case err != nil:
+			klog.Errorf("cluster: %s delete namespace: %s error: %v", clusters[i], name, err)
+			results[i].Status = model.NamespaceFailed
+			results[i].Message = err.Error()
+		}
+		return nil
+	})
+	resp.RespSuccess(true, "success", results, len(results))
+}
+
+// ensureNamespace creates or updates the namespace of the request and its quota and limit range in the cluster.
+func (m *Manager) ensureNamespace(cluster string, req *model.NamespaceRequest) (string, error) {
+	kubeCli, _ := m.getClientInterface(cluster)
+	if kubeCli == nil {
+		return "", fmt.Errorf("cluster: %s not found", cluster)
+	}
+
+	ctx := context.Background()
+	status := model.NamespaceCreated
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        req.Name,
+			Labels:      map[string]string{constants.NamespaceManagedLabel: "true"},
+			Annotations: map[string]string{},
+		},
+	}
+	for k, v := range req.Labels {
+		ns.Labels[k] = v
+	}
+	for k, v := range req.Annotations {
+		ns.Annotations[k] = v
+	}
+	_, err := kubeCli.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		status = model.NamespaceUpdated
+		err = updateNamespace(ctx, kubeCli, ns)
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "namespace: %s", req.Name)
+	}
+
+	if req.ResourceQuota != nil {
+		quota := &corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: model.NamespaceQuotaName, Namespace: req.Name},
+			Spec:       *req.ResourceQuota,
+		}
+		_, err = kubeCli.CoreV1().ResourceQuotas(req.Name).Create(ctx, quota, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			var old *corev1.ResourceQuota
+			old, err = kubeCli.CoreV1().ResourceQuotas(req.Name).Get(ctx, quota.Name, metav1.GetOptions{})
+			if err == nil {
+				old.Spec = quota.Spec
+				_, err = kubeCli.CoreV1().ResourceQuotas(req.Name).Update(ctx, old, metav1.UpdateOptions{})
+			}
+		}
+		if err != nil {
+			return "", errors.Wrapf(err, "resource quota: %s", quota.Name)
+		}
+	}
+
+	if req.LimitRange != nil {
+		limits := &corev1.LimitRange{
+			ObjectMeta: metav1.ObjectMeta{Name: model.NamespaceLimitRangeName, Namespace: req.Name},
+			Spec:       *req.LimitRange,
+		}
+		_, err = kubeCli.CoreV1().LimitRanges(req.Name).Create(ctx, limits, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			var old *corev1.LimitRange
+			old, err = kubeCli.CoreV1().LimitRanges(req.Name).Get(ctx, limits.Name, metav1.GetOptions{})
+			if err == nil {
+				old.Spec = limits.Spec
+				_, err = kubeCli.CoreV1().LimitRanges(req.Name).Update(ctx, old, metav1.UpdateOptions{})
+			}
+		}
+		if err != nil {
+			return "", errors.Wrapf(err, "limit range: %s", limits.Name)
+		}
+	}
+	return status, nil
+}
+
+// updateNamespace merges the labels and the annotations into the existing namespace.
+func updateNamespace(ctx context.Context, kubeCli kubernetes.Interface, ns *corev1.Namespace) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		old, err := kubeCli.CoreV1().Namespaces().Get(ctx, ns.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if old.Labels == nil {
+			old.Labels = map[string]string{}
+		}
+		if old.Annotations == nil {
+			old.Annotations = map[string]string{}
+		}
+		for k, v := range ns.Labels {
+			old.Labels[k] = v
+		}
+		for k, v := range ns.Annotations {
+			old.Annotations[k] = v
+		}
+		_, err = kubeCli.CoreV1().Namespaces().Update(ctx, old, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// splitQuery splits the comma separated values of a query.
+func splitQuery(value string) []string {
+	values := []string{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func nonEmpty(values []string) []string {
+	result := []string{}
+	for _, v := range values {
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -3,12 +3,12 @@ package v1
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/ghodss/yaml"
 	"github.com/gin-gonic/gin"
 	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/apimanager/webhook"
 	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
-	"github.com/gostship/kunkka/pkg/util/crdutil"
-	"github.com/gostship/kunkka/pkg/util/k8sutil"
 	"github.com/gostship/kunkka/pkg/util/metautil"
 	"github.com/gostship/kunkka/pkg/util/responseutil"
 	"github.com/gostship/kunkka/pkg/util/workload"
@@ -18,11 +18,12 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	v1beta12 "k8s.io/api/extensions/v1beta1"
 	v13 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog"
-	ctrl "sigs.k8s.io/controller-runtime"
+	"net/http"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"strings"
 )
@@ -40,7 +41,11 @@ func (m *Manager) addClusterNode(c *gin.Context) {
 	node, err := resp.Bind(nodeParm)
 	if err != nil {
 		klog.Error("bind http params error: ", err)
-		resp.RespError("bind http params error")
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_REQUEST_BIND_ERROR, err.Error())
+		return
+	}
+	if err := node.(*model.ClusterNode).Sanitize(); err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
 		return
 	}
 	cms := &corev1.ConfigMap{}
@@ -51,7 +56,7 @@ func (m *Manager) addClusterNode(c *gin.Context) {
 	}, cms)
 
 	if err != nil {
-		resp.RespError("not found rack cfg.")
+		resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, "not found rack cfg.")
 		return
 	}
 
@@ -59,20 +64,20 @@ func (m *Manager) addClusterNode(c *gin.Context) {
 	data, ok := cms.Data["List"]
 	if !ok {
 		klog.Info("no ConfigMap list!")
-		resp.RespError("no configMap list!")
+		resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, "no configMap list!")
 		return
 	}
 	// 将yaml转换为json
 	yamlToRack, err := yaml.YAMLToJSON([]byte(data))
 	if err != nil {
-		klog.Errorf("yamlToJson error", err)
+		klog.Errorf("yamlToJson error: %v", err)
 		resp.RespError("yaml to struct error!")
 		return
 	}
 	// 转换为结构体
 	err = json.Unmarshal(yamlToRack, &listMap)
 	if err != nil {
-		klog.Errorf("Unmarshal json err", err)
+		klog.Errorf("Unmarshal json err: %v", err)
 		resp.RespError("Unmarshal list json error.")
 		return
 	}
@@ -85,7 +90,7 @@ func (m *Manager) addClusterNode(c *gin.Context) {
 
 	if len(listRack) != len(node.(*model.ClusterNode).AddressList) {
 		klog.Error("address list lerge rack list!")
-		resp.RespError("address list lerge rack list!")
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, "address list lerge rack list!")
 		return
 	}
 
@@ -112,21 +117,15 @@ func (m *Manager) addClusterNode(c *gin.Context) {
 		//klog.Info("update rack state: %s", err)
 	}
 
-	nodeObj, err := crdutil.BuildNodeCrd(node.(*model.ClusterNode), cniOptList)
+	err = m.createNodes(c.Request.Context(), node.(*model.ClusterNode), cniOptList)
 	if err != nil {
-		klog.Error("build node crd cfg error: ", err)
-		resp.RespError("build node crd cfg error")
-		return
-	}
-
-	logger := ctrl.Log.WithValues("cluster", node.(*model.ClusterNode).ClusterName)
-	logger.Info("create node reconcile ...")
-	for _, obj := range nodeObj {
-		err := k8sutil.Reconcile(logger, cli, obj, k8sutil.DesiredStatePresent)
-		if err != nil {
-			resp.RespError("create node reconcile error")
+		klog.Errorf("create node error: %v", err)
+		if webhook.IsDenied(err) {
+			resp.RespErrorCode(http.StatusForbidden, responseutil.HTTP_FORBIDDEN, err.Error())
 			return
 		}
+		resp.RespError("create node reconcile error")
+		return
 	}
 	resp.RespSuccess(true, "success", "OK", 0)
 }
@@ -134,27 +133,50 @@ func (m *Manager) addClusterNode(c *gin.Context) {
 // get Noready machine
 func (m *Manager) getNoreadyNode(c *gin.Context) {
 	resp := responseutil.Gin{Ctx: c}
-	cli := m.Cluster.GetClient()
 	ctx := context.Background()
-	clusterName := c.Query("clusterName")
 	resultList := []devopsv1.Machine{}
-	machine := &devopsv1.MachineList{}
 
-	err := cli.List(ctx, machine)
+	machines, err := m.findClusterMachines(ctx, c.Query("clusterName"))
 	if err != nil {
-		klog.Error("get list no ready machine err:", err)
-		resp.RespError("get list no ready machine err.")
+		respClusterError(&resp, err)
 		return
 	}
+	for _, ma := range machines {
+		if ma.Status.Phase != devopsv1.MachineRunning { // 未就绪的节点
+			resultList = append(resultList, ma)
+		}
+	}
+	resp.RespSuccess(true, "success", resultList, len(resultList))
+}
 
-	for _, ma := range machine.Items {
-		if ma.Status.Phase != devopsv1.MachineRunning && ma.Spec.ClusterName == clusterName { // 未就绪的节点
+// get os/kernel drifted machine
+func (m *Manager) getDriftNode(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	ctx := context.Background()
+	resultList := []devopsv1.Machine{}
+
+	machines, err := m.findClusterMachines(ctx, c.Query("clusterName"))
+	if err != nil {
+		respClusterError(&resp, err)
+		return
+	}
+	for _, ma := range machines {
+		if ma.Status.OSDrift != nil && ma.Status.OSDrift.Drifted { // os/kernel 漂移的节点
 			resultList = append(resultList, ma)
 		}
 	}
 	resp.RespSuccess(true, "success", resultList, len(resultList))
 }
 
+// findClusterMachines returns the Machines of the cluster of the name, see findCluster.
+func (m *Manager) findClusterMachines(ctx context.Context, name string) ([]devopsv1.Machine, error) {
+	cluster, err := m.findCluster(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return m.listClusterMachines(ctx, cluster)
+}
+
 // 获取node节点CRD
 func (m *Manager) getNodeDetail(c *gin.Context) {
 	resp := responseutil.Gin{Ctx: c}
@@ -164,24 +186,20 @@ func (m *Manager) getNodeDetail(c *gin.Context) {
 
 	cli, err := m.getClient(clusterName)
 	if err != nil {
-		resp.RespError("get cluster client error")
+		resp.RespErr(err)
 		return
 	}
-	ctx := context.Background()
-	nodes := &corev1.NodeList{}
 	result := &corev1.Node{}
-	err = cli.List(ctx, nodes)
-
+	err = cli.Get(context.Background(), types.NamespacedName{Name: nodeName}, result)
 	if err != nil {
+		if apierrors.IsNotFound(err) {
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("node: %s of cluster: %s not found", nodeName, clusterName))
+			return
+		}
+		klog.Errorf("cluster: %s get node: %s error: %v", clusterName, nodeName, err)
 		resp.RespError("get node kind error!")
 		return
 	}
-	for _, node := range nodes.Items {
-		if node.Name == nodeName {
-			result = &node
-			break
-		}
-	}
 	resp.RespJson(result)
 }
 
@@ -193,8 +211,8 @@ func (m *Manager) getNodePodDetail(c *gin.Context) {
 
 	cli, err := m.getClient(clsName)
 	if err != nil {
-		klog.Error("get clienet error:%s", err)
-		resp.RespError("get client error")
+		klog.Errorf("get clienet error:%s", err)
+		resp.RespErr(err)
 		return
 	}
 
@@ -293,7 +311,7 @@ func (m *Manager) getDeploymentList(c *gin.Context) {
 
 	cli, err := m.getClient(clsName)
 	if err != nil {
-		resp.RespError("get cluster client error")
+		resp.RespErr(err)
 		return
 	}
 	ctx := context.Background()
@@ -324,7 +342,7 @@ func (m *Manager) getDeploymentDetail(c *gin.Context) {
 	}
 	cli, err := m.getClient(clsName)
 	if err != nil {
-		resp.RespError("get cluster client error")
+		resp.RespErr(err)
 		return
 	}
 	ctx := context.Background()
@@ -357,7 +375,7 @@ func (m *Manager) getStatefulsetsWorkLoad(c *gin.Context) {
 	}
 	cli, err := m.getClient(clsName)
 	if err != nil {
-		resp.RespError("get cluster client error")
+		resp.RespErr(err)
 		return
 	}
 	ctx := context.Background()
@@ -392,7 +410,7 @@ func (m *Manager) getDeploymentReplicaset(c *gin.Context) {
 
 	cli, err := m.getClient(clsName)
 	if err != nil {
-		resp.RespError("get cluster client error")
+		resp.RespErr(err)
 		return
 	}
 	ctx := context.Background()
@@ -428,7 +446,7 @@ func (m *Manager) getControllerRevisions(c *gin.Context) {
 	}
 	cli, err := m.getClient(clsName)
 	if err != nil {
-		resp.RespError("get cluster client error")
+		resp.RespErr(err)
 		return
 	}
 	ctx := context.Background()
@@ -455,7 +473,7 @@ func (m *Manager) getStatefulsetsDetail(c *gin.Context) {
 
 	cli, err := m.getClient(clsName)
 	if err != nil {
-		resp.RespError("get cluster client error")
+		resp.RespErr(err)
 		return
 	}
 	ctx := context.Background()
@@ -478,7 +496,7 @@ func (m *Manager) getDaemonsetsList(c *gin.Context) {
 
 	cli, err := m.getClient(clsName)
 	if err != nil {
-		resp.RespError("get cluster client error")
+		resp.RespErr(err)
 		return
 	}
 	ctx := context.Background()
@@ -503,7 +521,7 @@ func (m *Manager) getDaemonsetsDetail(c *gin.Context) {
 
 	cli, err := m.getClient(clsName)
 	if err != nil {
-		resp.RespError("get cluster client error")
+		resp.RespErr(err)
 		return
 	}
 	ctx := context.Background()
@@ -529,7 +547,7 @@ func (m *Manager) getJobsDetail(c *gin.Context) {
 
 	cli, err := m.getClient(clsName)
 	if err != nil {
-		resp.RespError("get cluster client error")
+		resp.RespErr(err)
 		return
 	}
 	ctx := context.Background()
@@ -552,7 +570,7 @@ func (m *Manager) getCronJobDetail(c *gin.Context) {
 
 	cli, err := m.getClient(clsName)
 	if err != nil {
-		resp.RespError("get cluster client error")
+		resp.RespErr(err)
 		return
 	}
 	ctx := context.Background()
@@ -575,7 +593,7 @@ func (m *Manager) getServiceList(c *gin.Context) {
 
 	cli, err := m.getClient(clsName)
 	if err != nil {
-		resp.RespError("get cluster client error")
+		resp.RespErr(err)
 		return
 	}
 	ctx := context.Background()
@@ -599,7 +617,7 @@ func (m *Manager) getServiceDetail(c *gin.Context) {
 	svcName := c.Param("service")
 	cli, err := m.getClient(clusName)
 	if err != nil {
-		resp.RespError("get client error")
+		resp.RespErr(err)
 		return
 	}
 	svc := &corev1.Service{}
@@ -623,7 +641,7 @@ func (m *Manager) getIngressesDetail(c *gin.Context) {
 
 	cli, err := m.getClient(clsName)
 	if err != nil {
-		resp.RespError("get cluster client error")
+		resp.RespErr(err)
 		return
 	}
 	ctx := context.Background()
@@ -645,7 +663,7 @@ func (m *Manager) getSecretsDetail(c *gin.Context) {
 	clsName := c.Param("name")
 	cli, err := m.getClient(clsName)
 	if err != nil {
-		resp.RespError("get cluster client error")
+		resp.RespErr(err)
 		return
 	}
 	ctx := context.Background()
@@ -667,7 +685,7 @@ func (m *Manager) getConfigmapsDetail(c *gin.Context) {
 	clsName := c.Param("name")
 	cli, err := m.getClient(clsName)
 	if err != nil {
-		resp.RespError("get cluster client error")
+		resp.RespErr(err)
 		return
 	}
 	ctx := context.Background()
@@ -689,7 +707,7 @@ func (m *Manager) getPvcDetail(c *gin.Context) {
 	clsName := c.Param("name")
 	cli, err := m.getClient(clsName)
 	if err != nil {
-		resp.RespError("get cluster client error")
+		resp.RespErr(err)
 		return
 	}
 
@@ -712,7 +730,7 @@ func (m *Manager) getStorageClassesDetail(c *gin.Context) {
 	clsName := c.Param("name")
 	cli, err := m.getClient(clsName)
 	if err != nil {
-		resp.RespError("get cluster client error")
+		resp.RespErr(err)
 		return
 	}
 
@@ -0,0 +1,143 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/apimanager/rackpool"
+	"github.com/gostship/kunkka/pkg/apimanager/webhook"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+)
+
+// 批量添加集群 worker 节点, 从机柜自动分配空闲机器及 pod 地址段, 生成 Machine 并提交到集群, 返回分配结果
+func (m *Manager) addClusterNodes(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Param("name")
+
+	r := &model.NodeBatchRequest{}
+	if _, err := resp.Bind(r); err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+	if err := r.Sanitize(); err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	cluster := &devopsv1.Cluster{}
+	err := m.Cluster.GetClient().Get(ctx, types.NamespacedName{Namespace: name, Name: name}, cluster)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("cluster: %s not found", name))
+			return
+		}
+		klog.Errorf("get cluster: %s error: %v", name, err)
+		resp.RespError("get cluster error")
+		return
+	}
+	if !cluster.DeletionTimestamp.IsZero() {
+		resp.RespErrorCode(http.StatusConflict, responseutil.HTTP_CONFLICT, fmt.Sprintf("cluster: %s is being deleted", name))
+		return
+	}
+
+	opts, err := m.allocateRackMachines(ctx, r.NodeRack, r.Count, r.DryRun)
+	if err != nil {
+		if e, ok := err.(*model.NotEnoughMachinesError); ok {
+			resp.RespErr(responseutil.Conflict("%v", e).WithDetails(e))
+			return
+		}
+		klog.Errorf("allocate %d machines of cluster: %s error: %v", r.Count, name, err)
+		resp.RespError("allocate rack machines error")
+		return
+	}
+	allocation := model.NewNodeAllocation(name, r.DryRun, opts)
+	if r.DryRun {
+		resp.RespSuccess(true, "success", allocation, len(allocation.Machines))
+		return
+	}
+
+	err = m.createNodes(c.Request.Context(), r.ClusterNode(name, opts), opts)
+	if err != nil {
+		klog.Errorf("create %d nodes of cluster: %s error: %v", len(opts), name, err)
+		// the machines not created are given back, the Machines created are cleaned up by their deletion
+		if rerr := m.releaseRackMachines(ctx, opts); rerr != nil {
+			klog.Errorf("release machines of cluster: %s error: %v", name, rerr)
+		}
+		if webhook.IsDenied(err) {
+			resp.RespErrorCode(http.StatusForbidden, responseutil.HTTP_FORBIDDEN, err.Error())
+			return
+		}
+		resp.RespError("create node reconcile error")
+		return
+	}
+	klog.Infof("cluster: %s added %d nodes by user: %s, machines: %v", name, len(opts), requestUserName(c), allocation.Machines)
+	resp.RespSuccess(true, "success", allocation, len(allocation.Machines))
+}
+
+// allocateRackMachines picks count unused machines of the racks, all the racks if tags is empty, and marks them
+// used in the rack cfg unless dryRun. The allocation is retried on the conflicts of the concurrent allocations.
+func (m *Manager) allocateRackMachines(ctx context.Context, tags []string, count int, dryRun bool) ([]*model.CniOption, error) {
+	var opts []*model.CniOption
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cms, racks, err := m.getRacks(ctx)
+		if err != nil {
+			return err
+		}
+		opts, err = model.AllocateMachines(racks, tags, count)
+		if err != nil || dryRun {
+			return err
+		}
+		return m.updateRacks(ctx, cms, racks)
+	})
+	return opts, err
+}
+
+// releaseRackMachines marks the machines allocated by allocateRackMachines unused again.
+func (m *Manager) releaseRackMachines(ctx context.Context, opts []*model.CniOption) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cms, racks, err := m.getRacks(ctx)
+		if err != nil {
+			return err
+		}
+		if model.ReleaseRacks(racks, model.NewCniReleases(opts)) == 0 {
+			return nil
+		}
+		return m.updateRacks(ctx, cms, racks)
+	})
+}
+
+// getRacks returns the rack cfg ConfigMap and its racks.
+func (m *Manager) getRacks(ctx context.Context) (*corev1.ConfigMap, []*model.Rack, error) {
+	cms := &corev1.ConfigMap{}
+	err := m.Cluster.GetClient().Get(ctx, types.NamespacedName{Namespace: ConfigMapName, Name: ConfigMapName}, cms)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "get rack cfg")
+	}
+	racks, err := rackpool.Parse(cms)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cms, racks, nil
+}
+
+// updateRacks writes the racks back to the rack cfg ConfigMap, it conflicts if the racks were changed since read.
+func (m *Manager) updateRacks(ctx context.Context, cms *corev1.ConfigMap, racks []*model.Rack) error {
+	list, err := json.MarshalIndent(racks, "", "  ")
+	if err != nil {
+		return err
+	}
+	cms.Data[rackpool.DataKey] = string(list)
+	return m.Cluster.GetClient().Update(ctx, cms)
+}
@@ -0,0 +1,92 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// 获取集群节点清单, 包括容量、可分配资源、kubelet 及系统版本、conditions 及污点, 支持 labelSelector 过滤;
+// 节点读自集群 client 的 informer 缓存, 不直接请求集群的 apiserver
+func (m *Manager) getNodeInventory(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Param("name")
+
+	selector, err := labels.Parse(c.Query("labelSelector"))
+	if err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, fmt.Sprintf("labelSelector: %v", err))
+		return
+	}
+
+	cli, _ := m.getClient(name)
+	if cli == nil {
+		resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("cluster: %s not found", name))
+		return
+	}
+
+	nodes := &corev1.NodeList{}
+	err = cli.List(context.Background(), nodes, client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		klog.Errorf("cluster: %s list nodes error: %v", name, err)
+		resp.RespError("list cluster nodes error")
+		return
+	}
+
+	infos := model.NewNodeInfos(nodes.Items)
+	resp.RespSuccess(true, "success", infos, len(infos))
+}
+
+// 获取集群单个节点的清单及其上的 pod 数
+func (m *Manager) getNodeInventoryDetail(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Param("name")
+	nodeName := c.Param("node")
+
+	cli, _ := m.getClient(name)
+	kubeCli, _ := m.getClientInterface(name)
+	if cli == nil || kubeCli == nil {
+		resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("cluster: %s not found", name))
+		return
+	}
+
+	ctx := context.Background()
+	node := &corev1.Node{}
+	err := cli.Get(ctx, types.NamespacedName{Name: nodeName}, node)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("node: %s not found", nodeName))
+			return
+		}
+		klog.Errorf("cluster: %s get node: %s error: %v", name, nodeName, err)
+		resp.RespError("get cluster node error")
+		return
+	}
+
+	// the pods are not cached, only the pods of the node are listed from the apiserver
+	pods, err := kubeCli.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.SelectorFromSet(fields.Set{"spec.nodeName": nodeName}).String(),
+	})
+	if err != nil {
+		klog.Errorf("cluster: %s list pods of node: %s error: %v", name, nodeName, err)
+		resp.RespError("list node pods error")
+		return
+	}
+
+	detail := &model.NodeDetail{
+		NodeInfo: *model.NewNodeInfo(node),
+		Pods:     len(pods.Items),
+	}
+	resp.RespSuccess(true, "success", detail, 1)
+}
@@ -0,0 +1,164 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/util/apiclient"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// 节点 labels 及污点变更的结果, Synced 为同步了 spec 的 Machine 或 Cluster
+type nodeMarksResult struct {
+	Node   *model.NodeInfo `json:"node"`
+	Synced []string        `json:"synced"`
+}
+
+// 修改集群节点的 labels 及污点, 并同步到节点对应的 Machine 或集群 master 的 spec, 以免被 MarkNode 覆盖
+func (m *Manager) patchNodeMarks(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Param("name")
+	nodeName := c.Param("node")
+
+	patch := &model.NodeMarksPatch{}
+	if _, err := resp.Bind(patch); err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+	if err := patch.Validate(); err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+
+	kubeCli, _ := m.getClientInterface(name)
+	if kubeCli == nil {
+		resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("cluster: %s not found", name))
+		return
+	}
+
+	ctx := context.Background()
+	node, err := kubeCli.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("node: %s not found", nodeName))
+			return
+		}
+		klog.Errorf("cluster: %s get node: %s error: %v", name, nodeName, err)
+		resp.RespError("get cluster node error")
+		return
+	}
+
+	// the spec is synced first, so the node is never marked back by the reconciler after it is patched
+	synced, err := m.syncNodeMarks(ctx, name, nodeIPs(node), patch)
+	if err != nil {
+		klog.Errorf("cluster: %s sync marks of node: %s error: %v", name, nodeName, err)
+		resp.RespError("sync node marks to spec error")
+		return
+	}
+
+	err = apiclient.PatchNode(ctx, kubeCli, nodeName, func(n *corev1.Node) {
+		n.Labels, n.Spec.Taints = patch.Apply(n.Labels, n.Spec.Taints)
+		node = n
+	})
+	if err != nil {
+		klog.Errorf("cluster: %s patch node: %s error: %v", name, nodeName, err)
+		resp.RespError("patch cluster node error")
+		return
+	}
+
+	klog.Infof("cluster: %s node: %s marks patched, synced: %v", name, nodeName, synced)
+	resp.RespSuccess(true, "success", &nodeMarksResult{Node: model.NewNodeInfo(node), Synced: synced}, 1)
+}
+
+// syncNodeMarks applies the patch to the spec of the machines and the cluster masters of the ips,
+// it returns the names of the updated objects.
+func (m *Manager) syncNodeMarks(ctx context.Context, cluster string, ips map[string]bool, patch *model.NodeMarksPatch) ([]string, error) {
+	synced := []string{}
+	if cluster == MetaClusterName {
+		return synced, nil
+	}
+
+	cli := m.Cluster.GetClient()
+	apply := func(cm *devopsv1.ClusterMachine) bool {
+		if cm == nil || !ips[cm.IP] {
+			return false
+		}
+		cm.Labels, cm.Taints = patch.Apply(cm.Labels, cm.Taints)
+		return true
+	}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		c := &devopsv1.Cluster{}
+		if err := cli.Get(ctx, types.NamespacedName{Namespace: cluster, Name: cluster}, c); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		changed := false
+		for _, cm := range c.Spec.Machines {
+			changed = apply(cm) || changed
+		}
+		if !changed {
+			return nil
+		}
+		if err := cli.Update(ctx, c); err != nil {
+			return err
+		}
+		synced = append(synced, "cluster/"+cluster)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "update cluster: %s", cluster)
+	}
+
+	machines := &devopsv1.MachineList{}
+	err = cli.List(ctx, machines, client.InNamespace(cluster))
+	if err != nil {
+		return nil, errors.Wrapf(err, "list machines of cluster: %s", cluster)
+	}
+	for i := range machines.Items {
+		if machines.Items[i].Spec.ClusterName != cluster || machines.Items[i].Spec.Machine == nil || !ips[machines.Items[i].Spec.Machine.IP] {
+			continue
+		}
+		key := types.NamespacedName{Namespace: machines.Items[i].Namespace, Name: machines.Items[i].Name}
+		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			ma := &devopsv1.Machine{}
+			if err := cli.Get(ctx, key, ma); err != nil {
+				return err
+			}
+			if !apply(ma.Spec.Machine) {
+				return nil
+			}
+			return cli.Update(ctx, ma)
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "update machine: %s", key.Name)
+		}
+		synced = append(synced, "machine/"+key.Name)
+	}
+	return synced, nil
+}
+
+// nodeIPs returns the name and the addresses of the node, the nodes are named by the ips of the machines.
+func nodeIPs(n *corev1.Node) map[string]bool {
+	ips := map[string]bool{n.Name: true}
+	for _, addr := range n.Status.Addresses {
+		if addr.Type == corev1.NodeInternalIP || addr.Type == corev1.NodeExternalIP {
+			ips[addr.Address] = true
+		}
+	}
+	return ips
+}
@@ -0,0 +1,82 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/noderemoval"
+	"github.com/gostship/kunkka/pkg/storage"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog"
+)
+
+// 下线集群的 worker 节点: 禁止调度并在 timeout(默认 5m, 最长 30m)内驱逐节点上的 pod, 删除 Node 及 Machine,
+// 机器清理完成后释放机柜中的机器及 pod 地址段; 驱逐失败时节点保持禁止调度,
+// 集群不可达或驱逐失败仍需下线时使用 force=true. 下线在后台进行, 通过 removal 查询进度
+func (m *Manager) removeClusterNode(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Param("name")
+	ip := c.Param("node")
+	if net.ParseIP(ip) == nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, fmt.Sprintf("node: %q must be the ip of the node", ip))
+		return
+	}
+	force := c.Query("force") == "true"
+	timeout := noderemoval.DefaultDrainTimeout
+	if s := c.Query("timeout"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 || d > noderemoval.MaxDrainTimeout {
+			resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS,
+				fmt.Sprintf("timeout: %q must be a duration up to %s", s, noderemoval.MaxDrainTimeout))
+			return
+		}
+		timeout = d
+	}
+
+	kube, _ := m.getClientInterface(name)
+	user := requestUserName(c)
+	rm, err := m.Remover.Start(context.Background(), name, ip, user, kube, force, timeout)
+	if err != nil {
+		switch {
+		case apierrors.IsNotFound(errors.Cause(err)):
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("cluster: %s not found", name))
+		case err == noderemoval.ErrNoMachine:
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("node: %s of cluster: %s not found", ip, name))
+		case err == noderemoval.ErrMaster:
+			resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		case err == noderemoval.ErrRemoving, err == noderemoval.ErrClusterDeleting, err == noderemoval.ErrUnreachable:
+			resp.RespErrorCode(http.StatusConflict, responseutil.HTTP_CONFLICT, err.Error())
+		default:
+			klog.Errorf("remove node: %s of cluster: %s error: %v", ip, name, err)
+			resp.RespError("remove node error")
+		}
+		return
+	}
+	klog.Infof("removal of node: %s of cluster: %s started by user: %s, force: %v", ip, name, user, force)
+	resp.RespSuccess(true, "success", rm, 1)
+}
+
+// 查询节点下线的进度: Draining 驱逐中, Deleting 机器清理中, Released 已下线且机柜地址已释放, Failed 下线失败
+func (m *Manager) getNodeRemoval(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Param("name")
+	ip := c.Param("node")
+
+	rm, err := m.Remover.Get(context.Background(), name, ip)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, "node removal not found")
+			return
+		}
+		klog.Errorf("get removal of node: %s of cluster: %s error: %v", ip, name, err)
+		resp.RespError("get node removal error")
+		return
+	}
+	resp.RespSuccess(true, "success", rm, 1)
+}
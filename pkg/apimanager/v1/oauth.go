@@ -21,20 +21,30 @@ func (m *Manager) AuthorizeHandler(c *gin.Context) {
 
 	if responseType != "token" {
 		err := apierrors.NewUnauthorized(fmt.Sprintf("Unauthorized: response type %s is not supported", responseType))
-		resp.RespError(err.Error())
+		resp.RespErrorCode(http.StatusUnauthorized, responseutil.ERROR_AUTH_CHECK_TOKEN_FAIL, err.Error())
+		return
+	}
+
+	if !authutil.PasswordLoginEnabled() {
+		resp.RespErrorCode(http.StatusForbidden, responseutil.ERROR_AUTH_CHECK_TOKEN_FAIL, "password login is disabled, set --login-password-file to enable it")
 		return
 	}
 
 	authorization := c.GetHeader("Authorization")
 	decodeUser, _ := base64.StdEncoding.DecodeString(authorization)
-	userInfo := strings.Split(string(decodeUser), ":")
+	userInfo := strings.SplitN(string(decodeUser), ":", 2)
+	if len(userInfo) != 2 || userInfo[0] == "" {
+		resp.RespErrorCode(http.StatusUnauthorized, responseutil.ERROR_AUTH_CHECK_TOKEN_FAIL, "invalid basic credential")
+		return
+	}
 
 	username := userInfo[0]
 	password := userInfo[1]
 
 	userState := authutil.Authenticate(password)
 	if !userState {
-		klog.Error("user: %s ,password error.", username)
+		klog.Errorf("user: %s ,password error.", username)
+		resp.RespErrorCode(http.StatusUnauthorized, responseutil.ERROR_AUTH_CHECK_TOKEN_FAIL, "invalid username or password")
 		return
 	}
 
@@ -56,8 +66,8 @@ func (m *Manager) AuthorizeHandler(c *gin.Context) {
 func (m *Manager) getAuthConfig(c *gin.Context) {
 	resp := responseutil.Gin{Ctx: c}
 	res := map[string]time.Duration{
-		"accessTokenMaxAge":            6 * time.Hour,
-		"accessTokenInactivityTimeout": 6 * time.Hour,
+		"accessTokenMaxAge":            authutil.TokenMaxAge,
+		"accessTokenInactivityTimeout": authutil.TokenMaxAge,
 	}
 	resp.RespJson(res)
 }
@@ -65,10 +75,14 @@ func (m *Manager) getAuthConfig(c *gin.Context) {
 func (m *Manager) getUserDetail(c *gin.Context) {
 	resp := responseutil.Gin{Ctx: c}
 	//userName := c.Param("username")
+	username := "admin"
+	if user, err := authutil.RequestUser(c); err == nil {
+		username = user.Name
+	}
 	result := map[string]string{
 		"email":      "admin@gostship.io",
 		"lang":       "zh",
-		"username":   "admin",
+		"username":   username,
 		"globalrole": "true",
 	}
 	resp.RespJson(result)
@@ -0,0 +1,61 @@
+package v1
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/openapi"
+	"github.com/gostship/kunkka/pkg/version"
+)
+
+const (
+	OpenAPIPath   = "/openapi.json"
+	SwaggerUIPath = "/swagger-ui"
+
+	// swaggerUIAssets the swagger-ui assets loaded by the browser, they are not vendored
+	swaggerUIAssets = "https://unpkg.com/swagger-ui-dist@3"
+)
+
+// 返回 API 的 OpenAPI v3 文档, 由路由及其请求、响应模型生成, 只生成一次
+func (m *Manager) getOpenAPI(c *gin.Context) {
+	m.openAPIOnce.Do(func() {
+		m.openAPI = openapi.Build(openapi.Info{
+			Title:       "kunkka api",
+			Description: "The api of the kunkka clusters, the responses are wrapped in the success or the error envelope.",
+			Version:     version.GetVersion().Release,
+		}, m.Routes())
+	})
+	c.JSON(http.StatusOK, m.openAPI)
+}
+
+// swagger-ui 页面, 浏览 OpenAPI 文档并调试 API, 点击 Authorize 填写 token
+func (m *Manager) getSwaggerUI(c *gin.Context) {
+	var b bytes.Buffer
+	err := swaggerUITmpl.Execute(&b, map[string]string{"Assets": swaggerUIAssets, "URL": OpenAPIPath})
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", b.Bytes())
+}
+
+var swaggerUITmpl = template.Must(template.New("swagger-ui").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>kunkka api</title>
+<link rel="stylesheet" href="{{.Assets}}/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="{{.Assets}}/swagger-ui-bundle.js"></script>
+<script>
+window.onload = function() {
+	window.ui = SwaggerUIBundle({url: "{{.URL}}", dom_id: "#swagger-ui", deepLinking: true});
+};
+</script>
+</body>
+</html>
+`))
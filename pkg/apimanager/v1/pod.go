@@ -6,11 +6,14 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/util/authutil"
 	"github.com/gostship/kunkka/pkg/util/responseutil"
 	websocket2 "github.com/gostship/kunkka/pkg/util/websocket"
 	"io/ioutil"
 	v1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -41,7 +44,7 @@ func (m *Manager) getKubectlPod(c *gin.Context) {
 	cli, err := m.getClient(clsName)
 	if err != nil {
 		klog.Error("get client error:", err)
-		resp.RespError("get client error.")
+		resp.RespErr(err)
 		return
 	}
 
@@ -80,6 +83,7 @@ func (m *Manager) getKubectlPod(c *gin.Context) {
 	resp.RespJson(info)
 }
 
+// 通过 websocket 进入集群中 pod 的终端, 需要集群的 operator 权限, 会话记录在操作审计中
 func (m *Manager) getTerminalSession(c *gin.Context) {
 	resp := responseutil.Gin{Ctx: c}
 	nsName := c.Param("namespace")
@@ -89,29 +93,73 @@ func (m *Manager) getTerminalSession(c *gin.Context) {
 	containerName := c.Query("container")
 	shell := c.Query("shell")
 
+	if !websocket.IsWebSocketUpgrade(c.Request) {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, "websocket upgrade required")
+		return
+	}
+
 	cli, err := m.getClientInterface(clsName)
-	if err != nil {
-		klog.Error("get client interface error,", err)
-		resp.RespError("get client interface error")
+	if err != nil || cli == nil {
+		resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("cluster: %s not found", clsName))
 		return
 	}
 	cfg, err := m.getClientRestCfg(clsName)
 	if err != nil {
 		klog.Error("get client restconfig error,", err)
-		resp.RespError("get client restconfig error")
+		resp.RespErr(err)
+		return
+	}
+
+	pod, err := cli.CoreV1().Pods(nsName).Get(context.Background(), podName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("pod: %s/%s not found", nsName, podName))
+			return
+		}
+		klog.Errorf("cluster: %s get pod: %s/%s error: %v", clsName, nsName, podName, err)
+		resp.RespError("get pod error")
+		return
+	}
+	if containerName == "" {
+		containerName = pod.Spec.Containers[0].Name
+	}
+	if !podHasContainer(pod, containerName) {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, fmt.Sprintf("container: %s not found in pod: %s/%s", containerName, nsName, podName))
+		return
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, fmt.Sprintf("pod: %s/%s is %s", nsName, podName, pod.Status.Phase))
 		return
 	}
 
 	handle := websocket2.NewTerminaler(cli, &cfg)
 
-	ws, err := upGrader.Upgrade(c.Writer, c.Request, nil)
+	header := http.Header{}
+	if protocols := authutil.WebSocketProtocols(c.Request); len(protocols) > 0 {
+		header.Set("Sec-WebSocket-Protocol", protocols[0])
+	}
+	ws, err := upGrader.Upgrade(c.Writer, c.Request, header)
 	if err != nil {
 		klog.Error("update websocker error", err)
-		resp.RespError("update websocket error.")
 		return
 	}
-	//defer ws.Close()
+
+	user := ""
+	if u, err := authutil.RequestUser(c); err == nil {
+		user = u.Name
+	}
+	klog.Infof("user: %s open terminal of cluster: %s pod: %s/%s container: %s", user, clsName, nsName, podName, containerName)
 	handle.HandleSession(shell, nsName, podName, containerName, ws)
+	klog.Infof("user: %s close terminal of cluster: %s pod: %s/%s container: %s", user, clsName, nsName, podName, containerName)
+}
+
+func podHasContainer(pod *corev1.Pod, name string) bool {
+	for _, cont := range pod.Spec.Containers {
+		if cont.Name == name {
+			return true
+		}
+	}
+	return false
 }
 
 func (m *Manager) getPodDetail(c *gin.Context) {
@@ -124,7 +172,7 @@ func (m *Manager) getPodDetail(c *gin.Context) {
 	cli, err := m.getClient(clsName)
 	if err != nil {
 		klog.Error("get client error,", err)
-		resp.RespError("get client error.")
+		resp.RespErr(err)
 		return
 	}
 	pods := &corev1.Pod{}
@@ -165,7 +213,7 @@ func (m *Manager) getPodLogs(c *gin.Context) {
 	clsInterface, err := m.getClientInterface(clsName)
 	if err != nil {
 		klog.Error("get cluster interface error", err)
-		resp.RespError("get cluster interface error")
+		resp.RespErr(err)
 		return
 	}
 	req, err := clsInterface.CoreV1().RESTClient().Get().
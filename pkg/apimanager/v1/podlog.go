@@ -0,0 +1,175 @@
+package v1
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog"
+)
+
+// podLogFollowTimeout ends the followed logs over http before the write timeout of the server,
+// the clients resume by sinceSeconds or use the websocket which is not bounded.
+const podLogFollowTimeout = 30 * time.Second
+
+// 流式获取集群中 pod 的日志, 支持 follow、tailLines、container、previous、timestamps、sinceSeconds、limitBytes,
+// websocket 请求逐行以文本消息推送, 否则以 chunked http 返回
+func (m *Manager) streamPodLogs(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	clsName := c.Param("name")
+	nsName := c.Param("namespace")
+	podName := c.Param("pod")
+
+	opts, err := parsePodLogOptions(c)
+	if err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+
+	cli, err := m.getClientInterface(clsName)
+	if err != nil || cli == nil {
+		resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("cluster: %s not found", clsName))
+		return
+	}
+
+	isWebsocket := websocket.IsWebSocketUpgrade(c.Request)
+	timeout := time.Duration(0)
+	if opts.Follow && !isWebsocket {
+		timeout = podLogFollowTimeout
+	}
+	ctx, cancel := podLogContext(c.Request.Context(), timeout)
+	defer cancel()
+
+	stream, err := cli.CoreV1().Pods(nsName).GetLogs(podName, opts).Stream(ctx)
+	if err != nil {
+		klog.Errorf("cluster: %s get pod: %s/%s logs error: %v", clsName, nsName, podName, err)
+		switch {
+		case apierrors.IsNotFound(err):
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, err.Error())
+		case apierrors.IsBadRequest(err):
+			resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		default:
+			resp.RespError("get pod logs error")
+		}
+		return
+	}
+	defer stream.Close()
+
+	if isWebsocket {
+		ws, err := upGrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			klog.Errorf("upgrade pod: %s/%s logs websocket error: %v", nsName, podName, err)
+			return
+		}
+		defer ws.Close()
+
+		// the messages of the client are discarded, the stream is stopped once it is closed
+		go func() {
+			defer cancel()
+			for {
+				if _, _, err := ws.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		err = streamPodLogLines(stream, ws)
+		if err != nil && ctx.Err() == nil {
+			klog.Errorf("cluster: %s stream pod: %s/%s logs error: %v", clsName, nsName, podName, err)
+		}
+		_ = ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		return
+	}
+
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := stream.Read(buf)
+		if n > 0 {
+			if _, werr := c.Writer.Write(buf[:n]); werr != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+		if err != nil {
+			if err != io.EOF && ctx.Err() == nil {
+				klog.Errorf("cluster: %s stream pod: %s/%s logs error: %v", clsName, nsName, podName, err)
+			}
+			return
+		}
+	}
+}
+
+// streamPodLogLines writes each line of the logs as a text message.
+func streamPodLogLines(stream io.Reader, ws *websocket.Conn) error {
+	r := bufio.NewReader(stream)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			if werr := ws.WriteMessage(websocket.TextMessage, line); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func podLogContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func parsePodLogOptions(c *gin.Context) (*corev1.PodLogOptions, error) {
+	opts := &corev1.PodLogOptions{
+		Container: c.Query("container"),
+	}
+
+	bools := map[string]*bool{
+		"follow":     &opts.Follow,
+		"previous":   &opts.Previous,
+		"timestamps": &opts.Timestamps,
+	}
+	for name, v := range bools {
+		if s := c.Query(name); s != "" {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid bool %s", name, s)
+			}
+			*v = b
+		}
+	}
+
+	ints := map[string]**int64{
+		"tailLines":    &opts.TailLines,
+		"sinceSeconds": &opts.SinceSeconds,
+		"limitBytes":   &opts.LimitBytes,
+	}
+	for name, v := range ints {
+		if s := c.Query(name); s != "" {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || n < 0 || (n == 0 && name != "tailLines") {
+				return nil, fmt.Errorf("%s: invalid number %s", name, s)
+			}
+			*v = &n
+		}
+	}
+	return opts, nil
+}
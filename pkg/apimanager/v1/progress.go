@@ -0,0 +1,179 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/gostship/kunkka/pkg/apimanager/progress"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/util/authutil"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+)
+
+const (
+	// progressSSETimeout ends the server sent events before the write timeout of the server,
+	// the EventSource reconnects by itself and gets a new snapshot.
+	progressSSETimeout = 30 * time.Second
+	// progressHeartbeat the period of the heartbeats keeping the idle streams through the proxies
+	progressHeartbeat = 10 * time.Second
+)
+
+// 推送集群及其机器的 phase、conditions 变化, 先推送当前状态, 之后推送每次变化, 代替轮询 getClusterCondition;
+// websocket 请求以 json 文本消息推送, 否则以 server sent events 推送
+func (m *Manager) streamClusterProgress(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Param("name")
+
+	if m.Progress == nil {
+		resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, "progress streaming is not enabled")
+		return
+	}
+
+	// subscribe before the snapshot, so no change is lost in between
+	events, unsubscribe := m.Progress.Subscribe(name, progress.DefaultBuffer)
+	defer unsubscribe()
+
+	snapshot, err := m.progressSnapshot(name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("cluster: %s not found", name))
+			return
+		}
+		klog.Errorf("get cluster: %s progress error: %v", name, err)
+		resp.RespError("get cluster progress error")
+		return
+	}
+
+	if websocket.IsWebSocketUpgrade(c.Request) {
+		m.streamProgressWebsocket(c, snapshot, events)
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	send := func(e progress.Event) bool {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return false
+		}
+		_, err = fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", e.Type, data)
+		c.Writer.Flush()
+		return err == nil
+	}
+
+	fmt.Fprint(c.Writer, "retry: 1000\n\n")
+	for _, e := range snapshot {
+		if !send(e) {
+			return
+		}
+	}
+
+	timeout := time.NewTimer(progressSSETimeout)
+	defer timeout.Stop()
+	heartbeat := time.NewTicker(progressHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok || !send(e) {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-timeout.C:
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+func (m *Manager) streamProgressWebsocket(c *gin.Context, snapshot []progress.Event, events <-chan progress.Event) {
+	header := http.Header{}
+	if protocols := authutil.WebSocketProtocols(c.Request); len(protocols) > 0 {
+		header.Set("Sec-WebSocket-Protocol", protocols[0])
+	}
+	ws, err := upGrader.Upgrade(c.Writer, c.Request, header)
+	if err != nil {
+		klog.Errorf("upgrade cluster progress websocket error: %v", err)
+		return
+	}
+	defer ws.Close()
+
+	// the messages of the client are discarded, the stream is stopped once it is closed
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for _, e := range snapshot {
+		if ws.WriteJSON(e) != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(progressHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				// fell behind, the client resubscribes for a new snapshot
+				_ = ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"), time.Now().Add(time.Second))
+				return
+			}
+			if ws.WriteJSON(e) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second)) != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// progressSnapshot returns the current state of the cluster and its machines.
+func (m *Manager) progressSnapshot(name string) ([]progress.Event, error) {
+	ctx := context.Background()
+	cli := m.Cluster.GetClient()
+
+	cluster := &devopsv1.Cluster{}
+	err := cli.Get(ctx, types.NamespacedName{Namespace: name, Name: name}, cluster)
+	if err != nil {
+		return nil, err
+	}
+	machines := &devopsv1.MachineList{}
+	err = cli.List(ctx, machines)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := []progress.Event{progress.ClusterEvent(progress.TypeAdded, cluster)}
+	for i := range machines.Items {
+		if machines.Items[i].Spec.ClusterName == name {
+			snapshot = append(snapshot, progress.MachineEvent(progress.TypeAdded, &machines.Items[i]))
+		}
+	}
+	return snapshot, nil
+}
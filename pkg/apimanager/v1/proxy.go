@@ -0,0 +1,119 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/provider/phases/certs"
+	"github.com/gostship/kunkka/pkg/util/authutil"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog"
+)
+
+// 将请求转发到集群的 apiserver, 使用 kunkka 持有的 admin 凭证并以 kunkka:user:<用户> 的身份 impersonate,
+// 权限同受限 kubeconfig, 由集群中该用户的 RoleBinding 决定
+func (m *Manager) proxyCluster(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Param("name")
+
+	user, err := authutil.RequestUser(c)
+	if err != nil {
+		resp.RespErrorCode(http.StatusUnauthorized, responseutil.ERROR_AUTH_CHECK_TOKEN_FAIL, err.Error())
+		return
+	}
+	if user.Name == "" || strings.ContainsAny(user.Name, "/%") {
+		resp.RespErrorCode(http.StatusUnauthorized, responseutil.ERROR_AUTH_CHECK_TOKEN_FAIL, fmt.Sprintf("invalid user: %q", user.Name))
+		return
+	}
+	if user.Claimed() {
+		// the password login issues the token of any user name, impersonating it would act as any user, even for the reads
+		resp.RespErrorCode(http.StatusForbidden, responseutil.HTTP_FORBIDDEN, "the cluster proxy requires a token bound to the user identity")
+		return
+	}
+
+	cfg, err := m.proxyRestConfig(name)
+	if err != nil {
+		resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, err.Error())
+		return
+	}
+	proxy, err := newClusterProxy(name, user.Name, cfg, c.Param("path"))
+	if err != nil {
+		klog.Errorf("cluster: %s new proxy error: %v", name, err)
+		resp.RespError("build cluster proxy error")
+		return
+	}
+	proxy.ServeHTTP(c.Writer, c.Request)
+}
+
+// newClusterProxy returns the proxy of the requests of the user to the path below the apiserver of cfg,
+// impersonating the scoped user of the user with the credentials of cfg.
+func newClusterProxy(name, user string, cfg *rest.Config, p string) (*httputil.ReverseProxy, error) {
+	cfg.Impersonate = rest.ImpersonationConfig{UserName: certs.ScopedUser(user)}
+	target, err := url.Parse(cfg.Host)
+	if err != nil || target.Host == "" {
+		return nil, fmt.Errorf("invalid apiserver: %q, err: %v", cfg.Host, err)
+	}
+	transport, err := rest.TransportFor(cfg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "build transport")
+	}
+
+	return &httputil.ReverseProxy{
+		Director: func(r *http.Request) {
+			r.URL.Scheme = target.Scheme
+			r.URL.Host = target.Host
+			r.URL.Path = proxyPath(target.Path, p)
+			r.URL.RawPath = ""
+			r.Host = target.Host
+			dropClientCredentials(r)
+		},
+		Transport: transport,
+		// the watches and the logs are flushed as they come
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			klog.Errorf("cluster: %s proxy %s %s of user: %s error: %v", name, r.Method, r.URL.Path, user, err)
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}, nil
+}
+
+// proxyRestConfig returns a copy of the admin rest config of the cluster, so the impersonation is never shared.
+func (m *Manager) proxyRestConfig(name string) (*rest.Config, error) {
+	if name == MetaClusterName {
+		return rest.CopyConfig(m.Cluster.GetConfig()), nil
+	}
+	cls, err := m.Cluster.Get(name)
+	if err != nil || cls.RestConfig == nil {
+		return nil, fmt.Errorf("cluster: %s not found", name)
+	}
+	return rest.CopyConfig(cls.RestConfig), nil
+}
+
+// proxyPath joins the path of the apiserver and the cleaned path of the request.
+func proxyPath(base, p string) string {
+	return strings.TrimSuffix(base, "/") + path.Clean("/"+p)
+}
+
+// dropClientCredentials drops the token and the impersonation of the client, the request is
+// authenticated by the admin credentials and impersonated by the transport.
+func dropClientCredentials(r *http.Request) {
+	r.Header.Del("Authorization")
+	r.Header.Del("Cookie")
+	for key := range r.Header {
+		if strings.HasPrefix(http.CanonicalHeaderKey(key), "Impersonate-") {
+			r.Header.Del(key)
+		}
+	}
+	if protocols := authutil.WebSocketProtocols(r); len(protocols) > 0 {
+		r.Header.Set("Sec-WebSocket-Protocol", strings.Join(protocols, ", "))
+	} else {
+		r.Header.Del("Sec-WebSocket-Protocol")
+	}
+}
@@ -0,0 +1,107 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/provider/phases/certs"
+	"github.com/gostship/kunkka/pkg/util/authutil"
+	"k8s.io/client-go/rest"
+)
+
+func TestProxyPath(t *testing.T) {
+	tests := []struct {
+		base string
+		path string
+		want string
+	}{
+		{base: "", path: "/api/v1/pods", want: "/api/v1/pods"},
+		{base: "/k8s/", path: "api/v1/pods", want: "/k8s/api/v1/pods"},
+		{base: "/k8s", path: "/api/v1/../../../oauth", want: "/k8s/oauth"},
+		{base: "", path: "", want: "/"},
+	}
+
+	for _, tt := range tests {
+		if got := proxyPath(tt.base, tt.path); got != tt.want {
+			t.Errorf("proxyPath(%q, %q) = %q, want %q", tt.base, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestDropClientCredentials(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil)
+	r.Header.Set("Authorization", "Bearer user-token")
+	r.Header.Set("Cookie", "session=1")
+	r.Header.Set("Impersonate-User", "admin")
+	r.Header.Add("Impersonate-Group", "system:masters")
+	r.Header.Set("Impersonate-Extra-Scopes", "all")
+	r.Header.Set("Sec-WebSocket-Protocol", "base64url.bearer.authorization.k8s.io.dG9rZW4, v4.channel.k8s.io")
+	r.Header.Set("Accept", "application/json")
+
+	dropClientCredentials(r)
+
+	for _, key := range []string{"Authorization", "Cookie", "Impersonate-User", "Impersonate-Group", "Impersonate-Extra-Scopes"} {
+		if v := r.Header.Get(key); v != "" {
+			t.Errorf("header %s = %q, want dropped", key, v)
+		}
+	}
+	if got := r.Header.Get("Sec-WebSocket-Protocol"); got != "v4.channel.k8s.io" {
+		t.Errorf("Sec-WebSocket-Protocol = %q, want the token protocol dropped", got)
+	}
+	if got := r.Header.Get("Accept"); got != "application/json" {
+		t.Errorf("Accept = %q, want kept", got)
+	}
+}
+
+func TestClusterProxyImpersonation(t *testing.T) {
+	var got *http.Request
+	apiserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer apiserver.Close()
+
+	cfg := &rest.Config{Host: apiserver.URL + "/k8s", BearerToken: "admin-token"}
+	proxy, err := newClusterProxy("c1", "alice", cfg, "/api/v1/namespaces/ns/pods")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPatch, "/apis/cluster/klusters/c1/proxy/api/v1/namespaces/ns/pods", nil)
+	r.Header.Set("Authorization", "Bearer alice-token")
+	r.Header.Set("Impersonate-User", "admin")
+	r.Header.Set("Impersonate-Group", "system:masters")
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK || got == nil {
+		t.Fatalf("proxy got %d, want the request forwarded", w.Code)
+	}
+	if got.URL.Path != "/k8s/api/v1/namespaces/ns/pods" {
+		t.Errorf("forwarded path = %q", got.URL.Path)
+	}
+	if auth := got.Header.Get("Authorization"); auth != "Bearer admin-token" {
+		t.Errorf("forwarded Authorization = %q, want the admin credentials", auth)
+	}
+	if user := got.Header.Get("Impersonate-User"); user != certs.ScopedUser("alice") {
+		t.Errorf("forwarded Impersonate-User = %q, want %q", user, certs.ScopedUser("alice"))
+	}
+	if groups := got.Header.Values("Impersonate-Group"); len(groups) != 0 {
+		t.Errorf("forwarded Impersonate-Group = %v, want the groups of the client dropped", groups)
+	}
+}
+
+func TestProxyClusterClaimedUser(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/apis/cluster/klusters/c1/proxy/api/v1/secrets", nil)
+	c.Params = gin.Params{{Key: "name", Value: "c1"}, {Key: "path", Value: "/api/v1/secrets"}}
+	authutil.SetUser(c, &authutil.User{Name: "alice", Issuer: authutil.DefaultIssuerName})
+
+	(&Manager{}).proxyCluster(c)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("proxyCluster() code = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,39 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/router"
+)
+
+// RouteRateLimits the per client rate limits of the routes besides the limit of all the routes,
+// the cluster creation is stricter than the reads and the cluster lists are served by listing all the clusters
+// and the searches and the fleet usage fan out to all the clusters.
+var RouteRateLimits = map[string]router.RateLimit{
+	"POST /apis/cluster/addCluster":                                         {QPS: 0.1, Burst: 2},
+	"POST /apis/cluster/addClusterNode":                                     {QPS: 0.5, Burst: 5},
+	"POST /apis/cluster/klusters/:name/nodes":                               {QPS: 0.5, Burst: 5},
+	"DELETE /apis/cluster/klusters/:name/nodes/:node":                       {QPS: 0.5, Burst: 5},
+	"POST /apis/cluster/breakglass":                                         {QPS: 0.1, Burst: 2},
+	"POST /apis/cluster/apitokens":                                          {QPS: 0.1, Burst: 5},
+	"POST /apis/cluster/klusters/:name/sshkeys/rotations":                   {QPS: 0.01, Burst: 1},
+	"POST /apis/cluster/klusters/:name/deletion":                            {QPS: 0.1, Burst: 2},
+	"DELETE /apis/cluster/klusters/:name":                                   {QPS: 0.1, Burst: 2},
+	"GET /apis/cluster/getMetaList":                                         {QPS: 2, Burst: 10},
+	"GET /apis/cluster/getMemberList":                                       {QPS: 2, Burst: 10},
+	"GET /audit":                                                            {QPS: 1, Burst: 5},
+	"GET /apis/cluster/klusters/:name/namespaces/:namespace/pods/:pod/logs": {QPS: 1, Burst: 10},
+	"GET /search":             {QPS: 1, Burst: 5},
+	"GET /apis/cluster/usage": {QPS: 1, Burst: 5},
+}
+
+// RouteMaxBodySizes the max body size overrides of the routes, the node list of the cluster creation is larger.
+var RouteMaxBodySizes = map[string]int64{
+	"POST /apis/cluster/addCluster":     4 << 20,
+	"POST /apis/cluster/addClusterNode": 4 << 20,
+	"POST /apis/cluster/apitokens":      16 << 10,
+}
+
+// RouteContentTypes the content types overrides of the routes, the cluster patch is a json merge patch.
+var RouteContentTypes = map[string][]string{
+	"PATCH /apis/cluster/klusters/:name": {gin.MIMEJSON, "application/merge-patch+json"},
+}
@@ -19,7 +19,7 @@ func (m *Manager) getClusterAllNameSpace(c *gin.Context) {
 	cli, err := m.getClient(clsName)
 	if err != nil {
 		klog.Error("get cluster client error")
-		resp.RespError("get cluster client error")
+		resp.RespErr(err)
 		return
 	}
 	ctx := context.Background()
@@ -50,7 +50,7 @@ func (m *Manager) getClusterNameSpace(c *gin.Context) {
 	cli, err := m.getClient(clsName)
 	if err != nil {
 		klog.Error("get cluster client error")
-		resp.RespError("get cluster client error")
+		resp.RespErr(err)
 		return
 	}
 	ctx := context.Background()
@@ -86,8 +86,8 @@ func (m *Manager) getClusterNameSpacePods(c *gin.Context) {
 	ctx := context.Background()
 	cli, err := m.getClient(clsName)
 	if err != nil {
-		klog.Error("get clienet error:%s", err)
-		resp.RespError("get client error")
+		klog.Errorf("get clienet error:%s", err)
+		resp.RespErr(err)
 		return
 	}
 
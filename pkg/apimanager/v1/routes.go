@@ -1,6 +1,10 @@
 package v1
 
-import "github.com/gostship/kunkka/pkg/apimanager/router"
+import (
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/apimanager/router"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+)
 
 // Routes ...
 func (m *Manager) Routes() []*router.Route {
@@ -11,6 +15,79 @@ func (m *Manager) Routes() []*router.Route {
 			Path:    "/oauth/authorize",
 			Handler: m.AuthorizeHandler,
 		},
+		{
+			Method:   "POST",
+			Path:     "/apis/cluster/breakglass",
+			Handler:  m.CreateBreakGlass,
+			Request:  &model.BreakGlassRequest{},
+			Response: &model.BreakGlassRequest{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/apis/cluster/breakglass",
+			Handler:  m.ListBreakGlass,
+			Response: []*model.BreakGlassRequest{},
+		},
+		{
+			Method:   "POST",
+			Path:     "/apis/cluster/breakglass/:id/approve",
+			Handler:  m.ApproveBreakGlass,
+			Response: &model.BreakGlassRequest{},
+		},
+		{
+			Method:  "GET",
+			Path:    "/apis/cluster/breakglass/:id/credential",
+			Handler: m.GetBreakGlassCredential,
+		},
+		{
+			Method:   "GET",
+			Path:     "/audit",
+			Handler:  m.GetAuditEvents,
+			Response: []*model.AuditEvent{},
+		},
+		{
+			Method:   "POST",
+			Path:     "/apis/cluster/apitokens",
+			Handler:  m.CreateAPIToken,
+			Request:  &model.APITokenRequest{},
+			Response: &model.APIToken{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/apis/cluster/apitokens",
+			Handler:  m.ListAPIToken,
+			Response: []*model.APIToken{},
+		},
+		{
+			Method:  "DELETE",
+			Path:    "/apis/cluster/apitokens/:id",
+			Handler: m.RevokeAPIToken,
+		},
+		{
+			Method:   "POST",
+			Path:     "/apis/cluster/expansions",
+			Handler:  m.CreateExpansion,
+			Request:  &model.ExpansionRequest{},
+			Response: &model.ExpansionRequest{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/apis/cluster/expansions",
+			Handler:  m.ListExpansion,
+			Response: []*model.ExpansionRequest{},
+		},
+		{
+			Method:   "POST",
+			Path:     "/apis/cluster/expansions/:id/approve",
+			Handler:  m.ApproveExpansion,
+			Response: &model.ExpansionRequest{},
+		},
+		{
+			Method:   "POST",
+			Path:     "/apis/cluster/expansions/:id/reject",
+			Handler:  m.RejectExpansion,
+			Response: &model.ExpansionRequest{},
+		},
 		{
 			Method:  "GET",
 			Path:    "/apis/cluster/users",
@@ -21,6 +98,22 @@ func (m *Manager) Routes() []*router.Route {
 			Path:    "/apis/cluster/globalroles",
 			Handler: m.getGlobalRole,
 		},
+		{
+			Method:   "GET",
+			Path:     "/capabilities",
+			Handler:  m.getCapabilities,
+			Response: &model.Capabilities{},
+		},
+		{
+			Method:  "GET",
+			Path:    OpenAPIPath,
+			Handler: m.getOpenAPI,
+		},
+		{
+			Method:  "GET",
+			Path:    SwaggerUIPath,
+			Handler: m.getSwaggerUI,
+		},
 		{
 			Method:  "GET",
 			Path:    "/apis/cluster/configs/oauth",
@@ -50,41 +143,52 @@ func (m *Manager) Routes() []*router.Route {
 			Method:  "POST",
 			Path:    "/apis/cluster/addRackCidr",
 			Handler: m.AddRackCidr,
+			Request: &model.Rack{},
 		},
 		{
 			Method:  "POST",
 			Path:    "/apis/cluster/updateRackCidr",
 			Handler: m.UptConfigMap,
+			Request: &model.Rack{},
 		},
 		{
 			Method:  "DELETE",
 			Path:    "/apis/cluster/delRackCidr",
 			Handler: m.DelConfigMap,
+			Request: &model.Rack{},
 		},
 		{
 			Method:  "GET",
 			Path:    "/apis/cluster/getPodCidr",
 			Handler: m.GetPodCidr,
 		},
+		{
+			Method:  "GET",
+			Path:    "/apis/cluster/waits",
+			Handler: m.GetWaits,
+		},
 		{
 			Method:  "GET",
 			Path:    "/apis/cluster/getClusterVersion",
 			Handler: m.GetClusterVersion,
 		},
 		{
-			Method:  "GET",
-			Path:    "/apis/cluster/getMetaList",
-			Handler: m.getClusterList,
+			Method:   "GET",
+			Path:     "/apis/cluster/getMetaList",
+			Handler:  m.getClusterList,
+			Response: []*devopsv1.Cluster{},
 		},
 		{
-			Method:  "GET",
-			Path:    "/apis/cluster/getMemberList",
-			Handler: m.getClusterList,
+			Method:   "GET",
+			Path:     "/apis/cluster/getMemberList",
+			Handler:  m.getClusterList,
+			Response: []*devopsv1.Cluster{},
 		},
 		{
 			Method:  "POST",
 			Path:    "/apis/cluster/addCluster",
 			Handler: m.AddCluster,
+			Request: &model.AddCluster{},
 		},
 		{
 			Method:  "GET",
@@ -106,6 +210,36 @@ func (m *Manager) Routes() []*router.Route {
 			Path:    "/apis/cluster/getNodeCondition",
 			Handler: m.getNodeCondition,
 		},
+		{
+			Method:   "GET",
+			Path:     "/apis/cluster/clusters/:namespace/:name",
+			Handler:  m.getNamespacedCluster,
+			Response: &devopsv1.Cluster{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/apis/cluster/clusters/:namespace/:name/conditions",
+			Handler:  m.getNamespacedClusterConditions,
+			Response: []*model.RuntimeCondition{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/apis/cluster/clusters/:namespace/:name/machines",
+			Handler:  m.getClusterMachines,
+			Response: []devopsv1.Machine{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/apis/cluster/clusters/:namespace/:name/machines/:machine",
+			Handler:  m.getClusterMachine,
+			Response: &devopsv1.Machine{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/apis/cluster/clusters/:namespace/:name/machines/:machine/conditions",
+			Handler:  m.getClusterMachineConditions,
+			Response: []*model.RuntimeCondition{},
+		},
 		{
 			Method:  "GET",
 			Path:    "/apis/cluster/getMemberMeta",
@@ -135,12 +269,18 @@ func (m *Manager) Routes() []*router.Route {
 			Method:  "POST",
 			Path:    "/apis/cluster/addClusterNode",
 			Handler: m.addClusterNode,
+			Request: &model.ClusterNode{},
 		},
 		{
 			Method:  "GET",
 			Path:    "/apis/cluster/getNoreadyNode",
 			Handler: m.getNoreadyNode,
 		},
+		{
+			Method:  "GET",
+			Path:    "/apis/cluster/getDriftNode",
+			Handler: m.getDriftNode,
+		},
 		{
 			Method:  "GET",
 			Path:    "/apis/cluster/Monitoring/:name/nodes",
@@ -186,6 +326,44 @@ func (m *Manager) Routes() []*router.Route {
 			Path:    "/apis/cluster/klusters/:name/nodes/:node",
 			Handler: m.getNodeDetail,
 		},
+		{
+			Method:   "GET",
+			Path:     "/apis/cluster/klusters/:name/nodes",
+			Handler:  m.getNodeInventory,
+			Response: []*model.NodeInfo{},
+		},
+		{
+			Method:   "POST",
+			Path:     "/apis/cluster/klusters/:name/nodes",
+			Handler:  m.addClusterNodes,
+			Request:  &model.NodeBatchRequest{},
+			Response: &model.NodeAllocation{},
+		},
+		{
+			Method:   "DELETE",
+			Path:     "/apis/cluster/klusters/:name/nodes/:node",
+			Handler:  m.removeClusterNode,
+			Response: &model.NodeRemoval{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/apis/cluster/klusters/:name/nodes/:node/removal",
+			Handler:  m.getNodeRemoval,
+			Response: &model.NodeRemoval{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/apis/cluster/klusters/:name/nodes/:node/inventory",
+			Handler:  m.getNodeInventoryDetail,
+			Response: &model.NodeDetail{},
+		},
+		{
+			Method:   "POST",
+			Path:     "/apis/cluster/klusters/:name/nodes/:node/marks",
+			Handler:  m.patchNodeMarks,
+			Request:  &model.NodeMarksPatch{},
+			Response: &nodeMarksResult{},
+		},
 		{
 			Method:  "GET",
 			Path:    "/apis/cluster/klusters/:name/components",
@@ -256,6 +434,43 @@ func (m *Manager) Routes() []*router.Route {
 			Path:    "/apis/cluster/resource/klusters/:name/namespaces",
 			Handler: m.getClusterAllNameSpace,
 		},
+		{
+			Method:   "POST",
+			Path:     "/apis/cluster/namespaces",
+			Handler:  m.createClusterNamespaces,
+			Request:  &model.NamespaceRequest{},
+			Response: []*model.NamespaceResult{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/apis/cluster/namespaces",
+			Handler:  m.listClusterNamespaces,
+			Response: []*model.ClusterNamespace{},
+		},
+		{
+			Method:   "DELETE",
+			Path:     "/apis/cluster/namespaces/:namespace",
+			Handler:  m.deleteClusterNamespaces,
+			Response: []*model.NamespaceResult{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/apis/cluster/workloads",
+			Handler:  m.listClusterWorkloads,
+			Response: []*model.WorkloadSummary{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/apis/cluster/usage",
+			Handler:  m.getFleetUsage,
+			Response: &model.FleetUsage{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/search",
+			Handler:  m.searchClusters,
+			Response: &model.SearchResult{},
+		},
 		{
 			Method:  "GET",
 			Path:    "/apis/cluster/klusters/:name/namespaces/:namespace",
@@ -306,11 +521,38 @@ func (m *Manager) Routes() []*router.Route {
 			Path:    "/apis/cluster/klusters/:name/events",
 			Handler: m.getNodeEvents,
 		},
+		{
+			Method:   "GET",
+			Path:     "/apis/cluster/klusters/:name/events/aggregated",
+			Handler:  m.getAggregatedEvents,
+			Response: []*model.ClusterEvent{},
+		},
 		{
 			Method:  "GET",
 			Path:    "/apis/cluster/klusters/:name/componenthealth",
 			Handler: m.getComponentHealth,
 		},
+		{
+			Method:  "GET",
+			Path:    "/apis/cluster/klusters/:name/certs",
+			Handler: m.getClusterCerts,
+		},
+		{
+			Method:   "GET",
+			Path:     "/apis/cluster/klusters/:name/usage",
+			Handler:  m.getClusterUsage,
+			Response: &model.ClusterUsage{},
+		},
+		{
+			Method:  "GET",
+			Path:    "/apis/cluster/klusters/:name/trends",
+			Handler: m.getClusterTrends,
+		},
+		{
+			Method:  "GET",
+			Path:    "/apis/cluster/klusters/:name/progress",
+			Handler: m.streamClusterProgress,
+		},
 		{
 			Method:  "GET",
 			Path:    "/apis/cluster/klusters/:name/users/:user/kubectl",
@@ -321,11 +563,84 @@ func (m *Manager) Routes() []*router.Route {
 			Path:    "/apis/clusters/:name/namespaces/:namespace/pods/:pod",
 			Handler: m.getTerminalSession,
 		},
+		{
+			Method:  "GET",
+			Path:    "/apis/cluster/klusters/:name/namespaces/:namespace/pods/:pod/exec",
+			Handler: m.getTerminalSession,
+		},
+		{
+			Method:  "Any",
+			Path:    "/apis/cluster/klusters/:name/proxy/*path",
+			Handler: m.proxyCluster,
+			Raw:     true,
+		},
 		{
 			Method:  "GET",
 			Path:    "/apis/cluster/klusters/:name/users/:user/kubeconfig",
 			Handler: m.getKubeConfig,
 		},
+		{
+			Method:   "GET",
+			Path:     "/apis/cluster/klusters/:name/kubeconfig",
+			Handler:  m.downloadKubeConfig,
+			Response: &model.ClusterKubeconfig{},
+		},
+		{
+			Method:  "POST",
+			Path:    "/apis/cluster/klusters/:name/kubeconfig/regenerate",
+			Handler: m.regenerateKubeConfig,
+		},
+		{
+			Method:   "POST",
+			Path:     "/apis/cluster/klusters/:name/kubeconfig/scoped",
+			Handler:  m.issueScopedKubeConfig,
+			Request:  &model.ScopedKubeconfigRequest{},
+			Response: &model.ScopedKubeconfig{},
+		},
+		{
+			Method:   "POST",
+			Path:     "/apis/cluster/klusters/:name/sshkeys/rotations",
+			Handler:  m.rotateSSHKey,
+			Request:  &model.SSHKeyRotationRequest{},
+			Response: &model.SSHKeyRotation{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/apis/cluster/klusters/:name/sshkeys/rotations",
+			Handler:  m.listSSHKeyRotations,
+			Response: []*model.SSHKeyRotation{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/apis/cluster/klusters/:name/sshkeys/rotations/:id",
+			Handler:  m.getSSHKeyRotation,
+			Response: &model.SSHKeyRotation{},
+		},
+		{
+			Method:   "POST",
+			Path:     "/apis/cluster/klusters/:name/deletion",
+			Handler:  m.requestClusterDeletion,
+			Response: &model.ClusterDeletion{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/apis/cluster/klusters/:name/deletion",
+			Handler:  m.getClusterDeletion,
+			Response: &model.ClusterDeletion{},
+		},
+		{
+			Method:   "DELETE",
+			Path:     "/apis/cluster/klusters/:name",
+			Handler:  m.deleteCluster,
+			Response: &model.ClusterDeletion{},
+		},
+		{
+			Method:   "PATCH",
+			Path:     "/apis/cluster/klusters/:name",
+			Handler:  m.patchCluster,
+			Request:  &model.ClusterSpecPatch{},
+			Response: &model.ClusterSpecUpdate{},
+		},
 		{
 			Method:  "GET",
 			Path:    "/apis/cluster/klusters/:name/namespaces/:namespace/pods/:pod",
@@ -336,6 +651,11 @@ func (m *Manager) Routes() []*router.Route {
 			Path:    "/apis/cluster/klusters/:name/namespaces/:namespace/pods/:pod/log",
 			Handler: m.getPodLogs,
 		},
+		{
+			Method:  "GET",
+			Path:    "/apis/cluster/klusters/:name/namespaces/:namespace/pods/:pod/logs",
+			Handler: m.streamPodLogs,
+		},
 		{
 			Method:  "GET",
 			Path:    "/apis/cluster/klusters/:name/namespaces/:namespace/replicasets",
@@ -456,5 +776,6 @@ func (m *Manager) Routes() []*router.Route {
 	}
 
 	routes = append(routes, apiRoutes...)
+	routes = append(routes, v2Routes(routes)...)
 	return routes
 }
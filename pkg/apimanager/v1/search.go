@@ -0,0 +1,149 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/apimanager/rbac"
+	"github.com/gostship/kunkka/pkg/util/parallel"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// searchTimeout bounds the search of a cluster, the clusters not answering in time are reported as failed
+const searchTimeout = 5 * time.Second
+
+// searchLists the lists of the searchable kinds and whether the kinds are namespaced
+var searchLists = map[string]struct {
+	newList    func() runtime.Object
+	namespaced bool
+}{
+	"Pod":                   {func() runtime.Object { return &corev1.PodList{} }, true},
+	"Service":               {func() runtime.Object { return &corev1.ServiceList{} }, true},
+	"Deployment":            {func() runtime.Object { return &appsv1.DeploymentList{} }, true},
+	"StatefulSet":           {func() runtime.Object { return &appsv1.StatefulSetList{} }, true},
+	"DaemonSet":             {func() runtime.Object { return &appsv1.DaemonSetList{} }, true},
+	"Job":                   {func() runtime.Object { return &batchv1.JobList{} }, true},
+	"CronJob":               {func() runtime.Object { return &batchv1beta1.CronJobList{} }, true},
+	"ConfigMap":             {func() runtime.Object { return &corev1.ConfigMapList{} }, true},
+	"Ingress":               {func() runtime.Object { return &networkingv1beta1.IngressList{} }, true},
+	"PersistentVolumeClaim": {func() runtime.Object { return &corev1.PersistentVolumeClaimList{} }, true},
+	"Namespace":             {func() runtime.Object { return &corev1.NamespaceList{} }, false},
+	"Node":                  {func() runtime.Object { return &corev1.NodeList{} }, false},
+}
+
+// 在多个集群中搜索资源, kind 必须指定(逗号分隔, 支持简称), name 为子串匹配, label 为 label selector;
+// clusters 不指定时为用户有权查看的全部集群, 每个集群最长 5s, 失败或超时的集群记入 failed 并返回其余集群的结果
+func (m *Manager) searchClusters(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	query := &model.SearchQuery{
+		Kinds:     splitQuery(c.Query("kind")),
+		Namespace: c.Query("namespace"),
+		Name:      c.Query("name"),
+		Label:     c.Query("label"),
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n == 0 {
+			resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, fmt.Sprintf("limit: must be 1-%d", model.MaxSearchLimit))
+			return
+		}
+		query.Limit = n
+	}
+	if err := query.Validate(); err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+	selector, err := labels.Parse(query.Label)
+	if err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, fmt.Sprintf("label: %v", err))
+		return
+	}
+
+	clusters, ok := m.requestClusters(c, rbac.RoleViewer)
+	if !ok {
+		return
+	}
+
+	var mu sync.Mutex
+	items := []*model.SearchItem{}
+	failed := []*model.SearchFailure{}
+	_ = parallel.Run(len(clusters), parallel.Concurrency(), func(i int) error {
+		found, err := m.searchCluster(clusters[i], query, selector)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			klog.Errorf("cluster: %s search error: %v", clusters[i], err)
+			failed = append(failed, &model.SearchFailure{Cluster: clusters[i], Message: err.Error()})
+			return nil
+		}
+		items = append(items, found...)
+		return nil
+	})
+
+	result := model.NewSearchResult(items, failed, query.Limit)
+	resp.RespSuccess(true, "success", result, len(result.Items))
+}
+
+// searchCluster lists the resources of the queried kinds of the cluster from its cached client.
+func (m *Manager) searchCluster(cluster string, query *model.SearchQuery, selector labels.Selector) ([]*model.SearchItem, error) {
+	cli, _ := m.getClient(cluster)
+	if cli == nil {
+		return nil, fmt.Errorf("cluster: %s not found", cluster)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), searchTimeout)
+	defer cancel()
+
+	items := []*model.SearchItem{}
+	for _, kind := range query.Kinds {
+		l, ok := searchLists[kind]
+		if !ok {
+			return nil, fmt.Errorf("unsupported kind: %s", kind)
+		}
+		opts := []client.ListOption{client.MatchingLabelsSelector{Selector: selector}}
+		if l.namespaced && query.Namespace != "" {
+			opts = append(opts, client.InNamespace(query.Namespace))
+		}
+
+		list := l.newList()
+		if err := cli.List(ctx, list, opts...); err != nil {
+			return nil, errors.Wrapf(err, "list %s", kind)
+		}
+		objs, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, errors.Wrapf(err, "extract %s", kind)
+		}
+		for _, obj := range objs {
+			o, err := meta.Accessor(obj)
+			if err != nil || !query.Matches(o.GetName()) {
+				continue
+			}
+			items = append(items, &model.SearchItem{
+				Cluster:      cluster,
+				Kind:         kind,
+				Namespace:    o.GetNamespace(),
+				Name:         o.GetName(),
+				Labels:       o.GetLabels(),
+				CreationTime: o.GetCreationTimestamp(),
+			})
+		}
+	}
+	return items, nil
+}
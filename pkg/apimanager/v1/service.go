@@ -24,7 +24,7 @@ func (m *Manager) getServicePods(c *gin.Context) {
 	cli, err := m.getClient(clsName)
 	if err != nil {
 		klog.Error("get client error.")
-		resp.RespError("get client error.")
+		resp.RespErr(err)
 		return
 	}
 
@@ -63,7 +63,7 @@ func (m *Manager) getServiceDep(c *gin.Context) {
 	cli, err := m.getClient(clsName)
 	if err != nil {
 		klog.Error("get client error.")
-		resp.RespError("get client error.")
+		resp.RespErr(err)
 		return
 	}
 
@@ -111,7 +111,7 @@ func (m *Manager) getServiceDae(c *gin.Context) {
 	cli, err := m.getClient(clsName)
 	if err != nil {
 		klog.Error("get client error.")
-		resp.RespError("get client error.")
+		resp.RespErr(err)
 		return
 	}
 
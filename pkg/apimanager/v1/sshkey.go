@@ -0,0 +1,92 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/keyrotation"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/storage"
+	"github.com/gostship/kunkka/pkg/util/authutil"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	"github.com/gostship/kunkka/pkg/util/ssh"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog"
+)
+
+// 轮换集群所有机器的 SSH 密钥, 后台执行, 通过返回的 id 查询进度
+func (m *Manager) rotateSSHKey(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Param("name")
+
+	req := &model.SSHKeyRotationRequest{}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(req); err != nil {
+			resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+			return
+		}
+	}
+	if req.PrivateKey != "" {
+		if _, err := ssh.AuthorizedKey([]byte(req.PrivateKey), []byte(req.PassPhrase), ""); err != nil {
+			resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, fmt.Sprintf("privateKey: %v", err))
+			return
+		}
+	}
+
+	user := ""
+	if u, err := authutil.RequestUser(c); err == nil {
+		user = u.Name
+	}
+	rot, err := m.KeyRotator.Start(context.Background(), name, user, []byte(req.PrivateKey), []byte(req.PassPhrase))
+	if err != nil {
+		switch {
+		case apierrors.IsNotFound(errors.Cause(err)):
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("cluster: %s not found", name))
+		case err == keyrotation.ErrRunning:
+			resp.RespErrorCode(http.StatusConflict, responseutil.HTTP_CONFLICT, err.Error())
+		default:
+			klog.Errorf("start ssh key rotation of cluster: %s error: %v", name, err)
+			resp.RespError(err.Error())
+		}
+		return
+	}
+	klog.Infof("ssh key rotation: %s of cluster: %s started by user: %s, %d machines, new key: %s",
+		rot.ID, name, user, len(rot.Machines), rot.Fingerprint)
+	resp.RespSuccess(true, "success", rot, 1)
+}
+
+// 查询集群的 SSH 密钥轮换记录, 最新的在前
+func (m *Manager) listSSHKeyRotations(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Param("name")
+
+	list, err := m.KeyRotator.List(context.Background(), name)
+	if err != nil {
+		klog.Errorf("list ssh key rotations of cluster: %s error: %v", name, err)
+		resp.RespError("list ssh key rotations error")
+		return
+	}
+	resp.RespSuccess(true, "success", list, len(list))
+}
+
+// 查询 SSH 密钥轮换的进度及每台机器的结果
+func (m *Manager) getSSHKeyRotation(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Param("name")
+	id := c.Param("id")
+
+	rot, err := m.KeyRotator.Get(context.Background(), name, id)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, "ssh key rotation not found")
+			return
+		}
+		klog.Errorf("get ssh key rotation: %s of cluster: %s error: %v", id, name, err)
+		resp.RespError("get ssh key rotation error")
+		return
+	}
+	resp.RespSuccess(true, "success", rot, 1)
+}
@@ -0,0 +1,54 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/trends"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// 获取集群节点数及状态的历史采样, since 默认为 24h
+func (m *Manager) getClusterTrends(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Param("name")
+
+	since := 24 * time.Hour
+	if s := c.Query("since"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, fmt.Sprintf("invalid since: %s", s))
+			return
+		}
+		since = d
+	}
+
+	cms := &corev1.ConfigMapList{}
+	err := m.Cluster.GetClient().List(context.Background(), cms, client.MatchingLabels{constants.ClusterTrendsLabel: name})
+	if err != nil {
+		klog.Errorf("list cluster: %s trends error: %v", name, err)
+		resp.RespError("list cluster trends error")
+		return
+	}
+	if len(cms.Items) == 0 {
+		resp.RespErrorCode(http.StatusNotFound, responseutil.HTTP_NOT_FOUND, fmt.Sprintf("cluster: %s trends not found", name))
+		return
+	}
+
+	samples, err := trends.Decode(&cms.Items[0])
+	if err != nil {
+		klog.Errorf("decode cluster: %s trends error: %v", name, err)
+		resp.RespError("decode cluster trends error")
+		return
+	}
+
+	samples = trends.Since(samples, time.Now().Add(-since))
+	resp.RespSuccess(true, "success", samples, len(samples))
+}
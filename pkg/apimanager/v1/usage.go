@@ -0,0 +1,117 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/apimanager/rbac"
+	"github.com/gostship/kunkka/pkg/util/parallel"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+)
+
+const (
+	// usageTimeout bounds the lists of a cluster for its usage
+	usageTimeout = 15 * time.Second
+	// podMetricsPath the pod metrics served by the metrics-server of the cluster
+	podMetricsPath = "/apis/metrics.k8s.io/v1beta1/pods"
+)
+
+// 获取集群按命名空间汇总的 cpu/memory requests、limits 及实际用量, 实际用量来自集群的 metrics-server,
+// 没有 metrics-server 时 metrics 为 false
+func (m *Manager) getClusterUsage(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Param("name")
+
+	usage, err := m.clusterUsage(name)
+	if err != nil {
+		if _, ok := err.(*responseutil.Error); !ok {
+			klog.Errorf("cluster: %s usage error: %v", name, err)
+			err = errors.New("get cluster usage error")
+		}
+		resp.RespErr(err)
+		return
+	}
+	resp.RespSuccess(true, "success", usage, 1)
+}
+
+// 获取多个集群的资源用量及其汇总, clusters 以逗号分隔, 不指定时为用户有权查看的全部集群;
+// 各集群并发获取, 不可达的集群记入 message 而不会失败
+func (m *Manager) getFleetUsage(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	clusters, ok := m.requestClusters(c, rbac.RoleViewer)
+	if !ok {
+		return
+	}
+
+	usages := make([]*model.ClusterUsage, len(clusters))
+	errs := make([]string, len(clusters))
+	_ = parallel.Run(len(clusters), parallel.Concurrency(), func(i int) error {
+		usage, err := m.clusterUsage(clusters[i])
+		if err != nil {
+			klog.Errorf("cluster: %s usage error: %v", clusters[i], err)
+			errs[i] = fmt.Sprintf("cluster: %s usage error", clusters[i])
+			return nil
+		}
+		usages[i] = usage
+		return nil
+	})
+
+	found := []*model.ClusterUsage{}
+	for _, usage := range usages {
+		if usage != nil {
+			found = append(found, usage)
+		}
+	}
+
+	msg := "success"
+	if failed := nonEmpty(errs); len(failed) > 0 {
+		msg = strings.Join(failed, "; ")
+	}
+	resp.RespSuccess(true, msg, model.NewFleetUsage(found), len(found))
+}
+
+// clusterUsage sums up the usage of the cluster, the nodes are read from the cache of the cluster client and
+// the pods, which are not cached, from the apiserver.
+func (m *Manager) clusterUsage(name string) (*model.ClusterUsage, error) {
+	cli, err := m.getClient(name)
+	if err != nil {
+		return nil, err
+	}
+	kubeCli, err := m.getClientInterface(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), usageTimeout)
+	defer cancel()
+	nodes := &corev1.NodeList{}
+	if err := cli.List(ctx, nodes); err != nil {
+		return nil, errors.Wrap(err, "list nodes")
+	}
+	pods, err := kubeCli.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "list pods")
+	}
+
+	// the usage is optional, the clusters without the metrics-server still have their requests and limits
+	var metrics *model.PodMetricsList
+	data, err := kubeCli.CoreV1().RESTClient().Get().AbsPath(podMetricsPath).DoRaw(ctx)
+	if err == nil {
+		metrics = &model.PodMetricsList{}
+		err = json.Unmarshal(data, metrics)
+	}
+	if err != nil {
+		klog.Warningf("cluster: %s get pod metrics error: %v", name, err)
+		metrics = nil
+	}
+	return model.NewClusterUsage(name, nodes.Items, pods.Items, metrics), nil
+}
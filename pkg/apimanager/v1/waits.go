@@ -0,0 +1,54 @@
+package v1
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/timeouts"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+)
+
+// 获取集群生效的等待参数, 不指定集群时返回全局参数
+func (m *Manager) GetWaits(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	name := c.Query("name")
+
+	var cluster *devopsv1.Cluster
+	if name != "" {
+		var err error
+		cluster, err = m.findCluster(context.Background(), name)
+		if err != nil {
+			respClusterError(&resp, err)
+			return
+		}
+	}
+
+	defaults := timeouts.Defaults()
+	global := timeouts.Global()
+	items := make([]*model.WaitParam, 0, len(defaults))
+	for _, one := range timeouts.Names() {
+		n := timeouts.Name(one)
+		p := timeouts.Get(cluster, n)
+
+		source := "default"
+		if global[n] != defaults[n] {
+			source = "global"
+		}
+		if cluster != nil {
+			if _, ok := cluster.Spec.Waits[one]; ok {
+				source = "cluster"
+			}
+		}
+
+		items = append(items, &model.WaitParam{
+			Name:     one,
+			Interval: p.Interval.Duration.String(),
+			Timeout:  p.Timeout.Duration.String(),
+			Source:   source,
+		})
+	}
+
+	resp.RespSuccess(true, "success", items, len(items))
+}
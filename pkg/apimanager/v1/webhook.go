@@ -0,0 +1,35 @@
+package v1
+
+import (
+	"context"
+
+	"github.com/gostship/kunkka/pkg/apimanager/webhook"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// WebhookConfigMapName the configmap of the validation webhooks registered by the admins
+const WebhookConfigMapName = "validation-webhooks"
+
+// validateWebhooks calls the registered webhooks of the operation with the rendered objects before they are created,
+// there is nothing to validate if the webhooks are not registered.
+func (m *Manager) validateWebhooks(op, cluster string, objs []runtime.Object) error {
+	ctx := context.Background()
+	cm := &corev1.ConfigMap{}
+	err := m.Cluster.GetClient().Get(ctx, types.NamespacedName{Namespace: ConfigMapName, Name: WebhookConfigMapName}, cm)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "get webhook cfg")
+	}
+
+	hooks, err := webhook.Parse(cm.Data[webhook.DataKey])
+	if err != nil {
+		return err
+	}
+	return webhook.Validate(ctx, hooks, op, cluster, objs)
+}
@@ -0,0 +1,119 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostship/kunkka/pkg/apimanager/model"
+	"github.com/gostship/kunkka/pkg/apimanager/rbac"
+	"github.com/gostship/kunkka/pkg/util/parallel"
+	"github.com/gostship/kunkka/pkg/util/responseutil"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// workloadListTimeout bounds the list of a cluster, the first list of a kind waits for its informer to sync
+const workloadListTimeout = 10 * time.Second
+
+// 列出多个集群的 Deployment、StatefulSet 及 DaemonSet 及其副本、镜像, clusters 以逗号分隔, 不指定时为用户有权查看的全部集群;
+// 读自各集群 client 的 informer 缓存, 并发数有上限, 不可达的集群记入 message 而不会失败
+func (m *Manager) listClusterWorkloads(c *gin.Context) {
+	resp := responseutil.Gin{Ctx: c}
+	query := &model.WorkloadQuery{
+		Kinds:     splitQuery(c.Query("kind")),
+		Namespace: c.Query("namespace"),
+		Name:      c.Query("name"),
+		Image:     c.Query("image"),
+	}
+	if err := query.Validate(); err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, err.Error())
+		return
+	}
+	selector, err := labels.Parse(c.Query("labelSelector"))
+	if err != nil {
+		resp.RespErrorCode(http.StatusBadRequest, responseutil.HTTP_INVALID_PARAMS, fmt.Sprintf("labelSelector: %v", err))
+		return
+	}
+
+	clusters, ok := m.requestClusters(c, rbac.RoleViewer)
+	if !ok {
+		return
+	}
+
+	lists := make([][]*model.WorkloadSummary, len(clusters))
+	errs := make([]string, len(clusters))
+	_ = parallel.Run(len(clusters), parallel.Concurrency(), func(i int) error {
+		list, err := m.clusterWorkloads(clusters[i], query, selector)
+		if err != nil {
+			klog.Errorf("cluster: %s list workloads error: %v", clusters[i], err)
+			errs[i] = fmt.Sprintf("cluster: %s list workloads error", clusters[i])
+			return nil
+		}
+		lists[i] = list
+		return nil
+	})
+
+	workloads := []*model.WorkloadSummary{}
+	for i := range lists {
+		workloads = append(workloads, lists[i]...)
+	}
+	workloads = query.Apply(workloads)
+
+	msg := "success"
+	if failed := nonEmpty(errs); len(failed) > 0 {
+		msg = strings.Join(failed, "; ")
+	}
+	resp.RespSuccess(true, msg, workloads, len(workloads))
+}
+
+// clusterWorkloads lists the workloads of the queried kinds of the cluster from its cached client.
+func (m *Manager) clusterWorkloads(cluster string, query *model.WorkloadQuery, selector labels.Selector) ([]*model.WorkloadSummary, error) {
+	cli, _ := m.getClient(cluster)
+	if cli == nil {
+		return nil, fmt.Errorf("cluster: %s not found", cluster)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), workloadListTimeout)
+	defer cancel()
+	opts := []client.ListOption{client.MatchingLabelsSelector{Selector: selector}}
+	if query.Namespace != "" {
+		opts = append(opts, client.InNamespace(query.Namespace))
+	}
+
+	workloads := []*model.WorkloadSummary{}
+	if query.Includes(model.WorkloadDeployment) {
+		list := &appsv1.DeploymentList{}
+		if err := cli.List(ctx, list, opts...); err != nil {
+			return nil, errors.Wrap(err, "list deployments")
+		}
+		for i := range list.Items {
+			workloads = append(workloads, model.DeploymentSummary(cluster, &list.Items[i]))
+		}
+	}
+	if query.Includes(model.WorkloadStatefulSet) {
+		list := &appsv1.StatefulSetList{}
+		if err := cli.List(ctx, list, opts...); err != nil {
+			return nil, errors.Wrap(err, "list statefulsets")
+		}
+		for i := range list.Items {
+			workloads = append(workloads, model.StatefulSetSummary(cluster, &list.Items[i]))
+		}
+	}
+	if query.Includes(model.WorkloadDaemonSet) {
+		list := &appsv1.DaemonSetList{}
+		if err := cli.List(ctx, list, opts...); err != nil {
+			return nil, errors.Wrap(err, "list daemonsets")
+		}
+		for i := range list.Items {
+			workloads = append(workloads, model.DaemonSetSummary(cluster, &list.Items[i]))
+		}
+	}
+	return workloads, nil
+}
@@ -0,0 +1,227 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/gostship/kunkka/pkg/util/uidutil"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog"
+)
+
+const (
+	// OperationAddCluster the cluster objects rendered by AddCluster
+	OperationAddCluster = "AddCluster"
+	// OperationAddMachine the machine objects rendered by AddClusterNode and the expansions
+	OperationAddMachine = "AddMachine"
+	// OperationDeleteCluster the cluster confirmed to be deleted by DeleteCluster
+	OperationDeleteCluster = "DeleteCluster"
+
+	// FailurePolicyFail rejects the operation when the webhook can not be called, it's the default
+	FailurePolicyFail = "Fail"
+	// FailurePolicyIgnore allows the operation when the webhook can not be called
+	FailurePolicyIgnore = "Ignore"
+
+	// DataKey the key of the webhooks in the ConfigMap
+	DataKey = "webhooks"
+
+	defaultTimeout = 10 * time.Second
+	maxTimeout     = 30 * time.Second
+)
+
+// Webhook an external validation registered by the admins, it's called with a Review
+// before the objects are created and may be implemented in any language.
+type Webhook struct {
+	Name           string   `json:"name"`
+	URL            string   `json:"url"`
+	Operations     []string `json:"operations"`
+	TimeoutSeconds int      `json:"timeoutSeconds,omitempty"`
+	FailurePolicy  string   `json:"failurePolicy,omitempty"`
+	// CABundle the PEM of the CA of the https webhook, the system roots are used if empty
+	CABundle string `json:"caBundle,omitempty"`
+}
+
+// Review the request posted to the webhooks.
+type Review struct {
+	UID       string            `json:"uid"`
+	Operation string            `json:"operation"`
+	Cluster   string            `json:"cluster"`
+	Objects   []json.RawMessage `json:"objects"`
+}
+
+// Response the response of the webhooks, the operation is rejected with the message if not allowed.
+type Response struct {
+	Allowed bool   `json:"allowed"`
+	Message string `json:"message,omitempty"`
+}
+
+// DeniedError the operation is rejected by a webhook.
+type DeniedError struct {
+	Webhook string
+	Message string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("denied by webhook %s: %s", e.Webhook, e.Message)
+}
+
+// IsDenied returns whether the operation is rejected by a webhook rather than the webhook failed.
+func IsDenied(err error) bool {
+	_, ok := err.(*DeniedError)
+	return ok
+}
+
+// Parse decodes the webhooks from the yaml of the ConfigMap.
+func Parse(data string) ([]*Webhook, error) {
+	hooks := []*Webhook{}
+	err := yaml.Unmarshal([]byte(data), &hooks)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unmarshal webhooks")
+	}
+
+	names := map[string]bool{}
+	for _, h := range hooks {
+		if h.Name == "" || names[h.Name] {
+			return nil, errors.Errorf("webhook name: %q is empty or duplicated", h.Name)
+		}
+		names[h.Name] = true
+
+		u, err := url.Parse(h.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errors.Errorf("webhook: %s url: %q must be an http or https url", h.Name, h.URL)
+		}
+		for _, op := range h.Operations {
+			if op != OperationAddCluster && op != OperationAddMachine && op != OperationDeleteCluster {
+				return nil, errors.Errorf("webhook: %s unsupported operation: %s", h.Name, op)
+			}
+		}
+		if h.FailurePolicy != "" && h.FailurePolicy != FailurePolicyFail && h.FailurePolicy != FailurePolicyIgnore {
+			return nil, errors.Errorf("webhook: %s unsupported failurePolicy: %s", h.Name, h.FailurePolicy)
+		}
+	}
+	return hooks, nil
+}
+
+// Validate calls the webhooks of the operation in order with the rendered objects,
+// the first rejection or failure of a webhook with the Fail policy is returned.
+func Validate(ctx context.Context, hooks []*Webhook, op, cluster string, objs []runtime.Object) error {
+	var review *Review
+	for _, h := range hooks {
+		if !h.handles(op) {
+			continue
+		}
+
+		if review == nil {
+			review = &Review{UID: uidutil.GenerateId(), Operation: op, Cluster: cluster}
+			for _, obj := range objs {
+				data, err := json.Marshal(obj)
+				if err != nil {
+					return errors.Wrapf(err, "marshal %T", obj)
+				}
+				review.Objects = append(review.Objects, data)
+			}
+		}
+
+		resp, err := h.call(ctx, review)
+		if err != nil {
+			if h.FailurePolicy == FailurePolicyIgnore {
+				klog.Warningf("webhook: %s %s cluster: %s failed and ignored, err: %v", h.Name, op, cluster, err)
+				continue
+			}
+			return errors.Wrapf(err, "call webhook %s", h.Name)
+		}
+		if !resp.Allowed {
+			klog.Infof("webhook: %s denied %s cluster: %s, msg: %s", h.Name, op, cluster, resp.Message)
+			return &DeniedError{Webhook: h.Name, Message: resp.Message}
+		}
+	}
+	return nil
+}
+
+func (h *Webhook) handles(op string) bool {
+	if len(h.Operations) == 0 {
+		return true
+	}
+	for _, o := range h.Operations {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Webhook) client() (*http.Client, error) {
+	timeout := defaultTimeout
+	if h.TimeoutSeconds > 0 {
+		timeout = time.Duration(h.TimeoutSeconds) * time.Second
+	}
+	if timeout > maxTimeout {
+		timeout = maxTimeout
+	}
+
+	tlsConfig := &tls.Config{}
+	if h.CABundle != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(h.CABundle)) {
+			return nil, errors.Errorf("invalid caBundle")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		},
+	}, nil
+}
+
+func (h *Webhook) call(ctx context.Context, review *Review) (*Response, error) {
+	cli, err := h.client()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(review)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("status: %d body: %s", resp.StatusCode, string(data))
+	}
+
+	r := &Response{}
+	err = json.Unmarshal(data, r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decode response")
+	}
+	return r, nil
+}
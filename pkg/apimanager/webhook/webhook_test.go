@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestValidate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		review := &Review{}
+		if err := json.NewDecoder(r.Body).Decode(review); err != nil || len(review.Objects) != 1 {
+			t.Errorf("decode review: %v %v", err, review)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		obj := &devopsv1.Cluster{}
+		json.Unmarshal(review.Objects[0], obj)
+		resp := &Response{Allowed: obj.Name == "prod-c1"}
+		if !resp.Allowed {
+			resp.Message = "name must start with prod-"
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	hooks, err := Parse(`
+- name: naming
+  url: ` + srv.URL + `
+  operations: ["AddCluster"]
+- name: down
+  url: http://127.0.0.1:1
+  operations: ["AddCluster"]
+  failurePolicy: Ignore
+- name: cmdb
+  url: http://127.0.0.1:1
+  operations: ["AddMachine"]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objs := func(name string) []runtime.Object {
+		return []runtime.Object{&devopsv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: name}}}
+	}
+	if err := Validate(context.TODO(), hooks, OperationAddCluster, "prod-c1", objs("prod-c1")); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	err = Validate(context.TODO(), hooks, OperationAddCluster, "c1", objs("c1"))
+	if !IsDenied(err) {
+		t.Errorf("Validate() error = %v, want denied", err)
+	}
+	err = Validate(context.TODO(), hooks, OperationAddMachine, "c1", objs("c1"))
+	if err == nil || IsDenied(err) {
+		t.Errorf("Validate() error = %v, want failure of cmdb", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "duplicated", data: "[{name: a, url: 'http://a'}, {name: a, url: 'http://b'}]"},
+		{name: "bad url", data: "[{name: a, url: 'a.com'}]"},
+		{name: "bad operation", data: "[{name: a, url: 'http://a', operations: [DeleteMachine]}]"},
+		{name: "bad policy", data: "[{name: a, url: 'http://a', failurePolicy: Retry}]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.data); err == nil {
+				t.Errorf("Parse() want error")
+			}
+		})
+	}
+}
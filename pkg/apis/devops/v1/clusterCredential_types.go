@@ -37,15 +37,33 @@ type CredentialInfo struct {
 	// +optional
 	CertificateKey *string `json:"certificateKey,omitempty"`
 
+	// The providers of the encryption at rest, the first one encrypts and all decrypt
+	// +optional
+	EncryptionKeys []EncryptionKey `json:"encryptionKeys,omitempty"`
+
 	ExtData         map[string]string `json:"extData,omitempty"`
 	KubeData        map[string]string `json:"kubeData,omitempty"`
 	ManifestsData   map[string]string `json:"manifestsData,omitempty"`
 	CertsBinaryData map[string][]byte `json:"certsBinaryData,omitempty"`
 }
 
+// EncryptionKey is a provider of the encryption at rest, an aescbc key, a kms plugin or the identity.
+type EncryptionKey struct {
+	Name string `json:"name"`
+	// The aescbc key
+	// +optional
+	Secret []byte `json:"secret,omitempty"`
+	// +optional
+	KMS *KMSPlugin `json:"kms,omitempty"`
+	// All the secrets are rewritten with the key, the other keys are dropped once it's set.
+	// +optional
+	Rewritten bool `json:"rewritten,omitempty"`
+}
+
 // +kubebuilder:object:root=true
 
 // ClusterCredential records the credential information needed to access the cluster.
+// +kubebuilder:storageversion
 type ClusterCredential struct {
 	metav1.TypeMeta `json:",inline"`
 	// +optional
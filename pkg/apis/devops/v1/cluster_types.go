@@ -17,6 +17,7 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -110,14 +111,27 @@ type ClusterCondition struct {
 	// Human-readable message indicating details about last transition.
 	// +optional
 	Message string `json:"message,omitempty"`
+	// Attempts is the number of the consecutive failures of the condition, the condition
+	// backs off between the attempts and is failed once the budget is exhausted.
+	// +optional
+	Attempts int32 `json:"attempts,omitempty"`
+	// Duration is how long the last run of the condition took.
+	// +optional
+	Duration metav1.Duration `json:"duration,omitempty"`
 }
 
+// ClusterConditionReachable is the condition of the probes of the apiserver of the cluster by the health
+// controller, it's not a phase.
+const ClusterConditionReachable = "Reachable"
+
 type HookType string
 
 const (
 	HookPreInstall  HookType = "preInstall"
 	HookPostInstall HookType = "postInstall"
 	HookCniInstall  HookType = "cniInstall"
+	// HookPolicyInstall selects the admission policy engine, only "gatekeeper" is supported now.
+	HookPolicyInstall HookType = "policyInstall"
 )
 
 // AddressType indicates the type of cluster apiserver access address.
@@ -179,6 +193,260 @@ type ThirdPartyHA struct {
 type File struct {
 	Src string `json:"src"` // Only support regular file
 	Dst string `json:"dst"`
+	// Arch the Src is the amd64 binary, the binary of the arch of the node is copied from ArchDir of its directory.
+	// +optional
+	Arch bool `json:"arch,omitempty"`
+}
+
+// OSBaseline describes the os/kernel every machine of the cluster is required to run.
+type OSBaseline struct {
+	// OSImage is matched against PRETTY_NAME of /etc/os-release, e.g. "CentOS Linux 7 (Core)".
+	// +optional
+	OSImage string `json:"osImage,omitempty"`
+	// KernelVersion is matched against "uname -r".
+	// +optional
+	KernelVersion string `json:"kernelVersion,omitempty"`
+	// Enforce requests the maintenance of drifted nodes, which cordons them until the drift is fixed.
+	// +optional
+	Enforce bool `json:"enforce,omitempty"`
+}
+
+// FlannelBackend is the backend type of flannel.
+type FlannelBackend string
+
+const (
+	FlannelBackendVxlan     FlannelBackend = "vxlan"
+	FlannelBackendHostGW    FlannelBackend = "host-gw"
+	FlannelBackendWireguard FlannelBackend = "wireguard"
+)
+
+// Flannel holds the configuration of the flannel cni.
+type Flannel struct {
+	// Backend is one of vxlan, host-gw, wireguard. Defaults to vxlan.
+	// host-gw requires all the nodes in the same L2 network (e.g. one rack).
+	// +optional
+	Backend FlannelBackend `json:"backend,omitempty"`
+	// MTU of the pod interfaces, 0 means calculated by flannel from the host nic.
+	// +optional
+	MTU int32 `json:"mtu,omitempty"`
+}
+
+// ContainerRuntimeType is the container runtime of the cluster nodes.
+type ContainerRuntimeType string
+
+const (
+	ContainerRuntimeDocker     ContainerRuntimeType = "docker"
+	ContainerRuntimeContainerd ContainerRuntimeType = "containerd"
+	ContainerRuntimeCRIO       ContainerRuntimeType = "cri-o"
+)
+
+// ContainerRuntime holds the configuration of the container runtime installed on the nodes.
+type ContainerRuntime struct {
+	// Type is one of docker, containerd, cri-o. Defaults to docker.
+	// Kubernetes 1.24+ removes dockershim and requires containerd or cri-o.
+	// +optional
+	Type ContainerRuntimeType `json:"type,omitempty"`
+	// Version of the runtime package, empty installs the latest containerd.io,
+	// or the cri-o stream of the kubernetes minor version, e.g. "1.20".
+	// +optional
+	Version string `json:"version,omitempty"`
+	// RegistryMirrors are the mirrors of docker.io, e.g. "https://mirror.ccs.tencentyun.com".
+	// +optional
+	RegistryMirrors []string `json:"registryMirrors,omitempty"`
+	// InsecureRegistries are the registries pulled over http or without tls verification.
+	// +optional
+	InsecureRegistries []string `json:"insecureRegistries,omitempty"`
+	// SandboxImage is the pause image of the pod sandbox. Defaults to the pause image of the cluster registry.
+	// +optional
+	SandboxImage string `json:"sandboxImage,omitempty"`
+}
+
+// RegistryMirror holds the pull configuration of a registry.
+type RegistryMirror struct {
+	// Endpoints are the mirrors tried in order before the registry itself, e.g. "https://mirror.example.com".
+	// Docker only supports the mirrors of docker.io.
+	// +optional
+	Endpoints []string `json:"endpoints,omitempty"`
+	// CA is the PEM encoded ca bundle trusted for the registry and its mirrors.
+	// +optional
+	CA string `json:"ca,omitempty"`
+	// Insecure allows pulling from the registry and its mirrors over http or without tls verification.
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// ExternalCA references the Secret holding an existing CA, e.g. issued by the corp PKI.
+type ExternalCA struct {
+	// SecretName is the Secret in the namespace of the cluster with tls.crt and tls.key of the CA,
+	// tls.crt may be an intermediate CA followed by its chain, which is distributed as the ca bundle.
+	SecretName string `json:"secretName"`
+}
+
+// VaultPKI issues the certificates of the cluster by the pki secrets engines of vault,
+// the keys of the mounted CAs are kept in vault only.
+type VaultPKI struct {
+	// Address of vault, e.g. https://vault.example.com:8200
+	Address string `json:"address"`
+	// SecretName is the Secret in the namespace of the cluster with the vault token (token)
+	// and optionally the CA of vault (ca.crt).
+	SecretName string `json:"secretName"`
+	// Mounts is the path of the pki secrets engine by the CA name: ca, etcd-ca, front-proxy-ca.
+	// The CAs not mounted are generated as before.
+	Mounts map[string]string `json:"mounts"`
+	// Roles is the pki role by the certificate name, e.g. apiserver, etcd-server, apiserver-kubelet-client,
+	// and the kubeconfig users: admin, kubelet, controller-manager, scheduler.
+	// +optional
+	Roles map[string]string `json:"roles,omitempty"`
+	// DefaultRole is the role of the certificates not in Roles, default: kunkka
+	// +optional
+	DefaultRole string `json:"defaultRole,omitempty"`
+}
+
+// OIDC configures the apiserver to authenticate the users by the id tokens of an OpenID Connect provider, e.g. the corp SSO.
+type OIDC struct {
+	// IssuerURL of the provider, only https is accepted by the apiserver.
+	IssuerURL string `json:"issuerURL"`
+	// ClientID all the tokens must be issued for.
+	ClientID string `json:"clientID"`
+	// UsernameClaim is the claim used as the user name. Defaults to "sub".
+	// +optional
+	UsernameClaim string `json:"usernameClaim,omitempty"`
+	// UsernamePrefix is prepended to the user names, e.g. "oidc:", "-" disables the prefix.
+	// +optional
+	UsernamePrefix string `json:"usernamePrefix,omitempty"`
+	// GroupsClaim is the claim used as the groups of the user.
+	// +optional
+	GroupsClaim string `json:"groupsClaim,omitempty"`
+	// GroupsPrefix is prepended to the groups, e.g. "oidc:".
+	// +optional
+	GroupsPrefix string `json:"groupsPrefix,omitempty"`
+	// RequiredClaims must be present in the tokens with the values, at most one claim is supported.
+	// +optional
+	RequiredClaims map[string]string `json:"requiredClaims,omitempty"`
+	// CA is the PEM encoded CA of the issuer, the system roots are used if empty.
+	// +optional
+	CA string `json:"ca,omitempty"`
+}
+
+// Audit configures the audit policy and the backends of the apiserver, the events are logged to
+// /var/log/kubernetes/audit.log of the masters and optionally shipped to a central webhook.
+type Audit struct {
+	// Policy is the inline audit.k8s.io/v1 Policy yaml, it takes precedence over PolicyConfigMap.
+	// The Metadata level of all the requests is used if neither is set.
+	// +optional
+	Policy string `json:"policy,omitempty"`
+	// PolicyConfigMap references the policy yaml in a ConfigMap of the namespace of the cluster.
+	// +optional
+	PolicyConfigMap *corev1.ConfigMapKeySelector `json:"policyConfigMap,omitempty"`
+	// MaxAge is the days the audit log files are retained on the masters. Defaults to 7.
+	// +optional
+	MaxAge int32 `json:"maxAge,omitempty"`
+	// Webhook ships the audit events to a central backend.
+	// +optional
+	Webhook *AuditWebhook `json:"webhook,omitempty"`
+}
+
+// AuditWebhook posts the audit events of the apiserver as audit.k8s.io/v1 EventLists to the server.
+type AuditWebhook struct {
+	// Server is the url the events are posted to.
+	Server string `json:"server"`
+	// CA is the PEM encoded CA of the server, the system roots are used if empty.
+	// +optional
+	CA string `json:"ca,omitempty"`
+	// Mode is "batch" or "blocking". Defaults to "batch".
+	// +optional
+	Mode string `json:"mode,omitempty"`
+}
+
+// Encryption encrypts the secrets of the cluster at rest in etcd, by the aescbc keys generated and
+// kept in the ClusterCredential or by a kms plugin.
+type Encryption struct {
+	// KMS encrypts the secrets by the kms plugin instead of the aescbc keys.
+	// +optional
+	KMS *KMSPlugin `json:"kms,omitempty"`
+}
+
+// KMSPlugin is the kms plugin of the apiserver, it must listen on the unix socket of the masters,
+// of the meta cluster nodes for the hosted clusters.
+type KMSPlugin struct {
+	// Name of the plugin, the keys of another name are rotated.
+	Name string `json:"name"`
+	// Endpoint is the unix socket of the plugin, e.g. unix:///var/run/kmsplugin/socket.sock.
+	Endpoint string `json:"endpoint"`
+	// CacheSize is the number of the data encryption keys cached in memory. Defaults to 1000.
+	// +optional
+	CacheSize *int32 `json:"cacheSize,omitempty"`
+	// Timeout of the calls to the plugin. Defaults to 3s.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// PodSecurity labels the namespaces of the cluster with the levels of the Pod Security Standards,
+// which are enforced by the PodSecurity admission of kubernetes v1.23+.
+type PodSecurity struct {
+	// Enforce is the level the pods violating are rejected: privileged, baseline or restricted.
+	Enforce string `json:"enforce"`
+	// Audit is the level the violations are audited. Defaults to Enforce.
+	// +optional
+	Audit string `json:"audit,omitempty"`
+	// Warn is the level the violations are warned to the users. Defaults to Enforce.
+	// +optional
+	Warn string `json:"warn,omitempty"`
+	// ExemptNamespaces keep their own labels, the system namespaces are always exempt.
+	// +optional
+	ExemptNamespaces []string `json:"exemptNamespaces,omitempty"`
+}
+
+// NetworkAttachment describes a secondary network which is rendered into a multus
+// NetworkAttachmentDefinition on the cluster.
+type NetworkAttachment struct {
+	Name string `json:"name"`
+	// Namespace of the NetworkAttachmentDefinition. Defaults to "default".
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Type is the cni plugin of the secondary interface, e.g. macvlan, ipvlan, host-device, sriov.
+	// +optional
+	Type string `json:"type,omitempty"`
+	// Master is the host nic the secondary interface is attached to, e.g. a rack nic "eth1".
+	// +optional
+	Master string `json:"master,omitempty"`
+	// Mode is the macvlan/ipvlan mode. Defaults to "bridge" for macvlan and "l2" for ipvlan.
+	// +optional
+	Mode string `json:"mode,omitempty"`
+	// +optional
+	VlanID int32 `json:"vlanID,omitempty"`
+	// +optional
+	MTU int32 `json:"mtu,omitempty"`
+	// IPAM is the raw json ipam config. Defaults to {"type": "dhcp"}, sriov and host-device have no ipam by default.
+	// +optional
+	IPAM string `json:"ipam,omitempty"`
+	// ResourceName is the device plugin resource (e.g. intel.com/sriov_netdevice) the pods request.
+	// +optional
+	ResourceName string `json:"resourceName,omitempty"`
+	// Config is the raw cni json config, it overrides all the fields above except Name and Namespace.
+	// +optional
+	Config string `json:"config,omitempty"`
+}
+
+// NodePlacement dedicates the first Replicas nodes of the cluster, e.g. for ingress or system addons,
+// by applying the labels and taints when the nodes join. The placements take the nodes in order
+// of the machine creation and never share a node.
+type NodePlacement struct {
+	// Name is recorded in the k8s.io/placement label of the nodes.
+	Name     string `json:"name"`
+	Replicas int32  `json:"replicas"`
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// +optional
+	Taints []corev1.Taint `json:"taints,omitempty"`
+}
+
+// WaitParam is the poll interval and the timeout of a wait, the zero fields keep the global value.
+type WaitParam struct {
+	// +optional
+	Interval metav1.Duration `json:"interval,omitempty"`
+	// +optional
+	Timeout metav1.Duration `json:"timeout,omitempty"`
 }
 
 // ClusterFeature records the features that are enabled by the cluster.
@@ -199,6 +467,11 @@ type ClusterFeature struct {
 	Files []File `json:"files,omitempty"`
 	// +optional
 	Hooks map[HookType]string `json:"hooks,omitempty"`
+	// +optional
+	OSBaseline *OSBaseline `json:"osBaseline,omitempty"`
+	// Multus enables the multus meta cni so pods can request secondary networks.
+	// +optional
+	Multus bool `json:"multus,omitempty"`
 }
 
 // HelmChartSpec records the attribute application of  cluster.
@@ -283,8 +556,17 @@ type ClusterSpec struct {
 	Properties ClusterProperty `json:"properties,omitempty"`
 	// +optional
 	Machines []*ClusterMachine `json:"machines,omitempty"`
+	// Bastions are the jump hosts to reach the machines through, the first one is dialed directly.
+	// +optional
+	Bastions []SSHBastion `json:"bastions,omitempty"`
+	// RackBastions override the bastions of the machines in the racks.
+	// +optional
+	RackBastions []RackBastion `json:"rackBastions,omitempty"`
 	// +optional
 	DockerExtraArgs map[string]string `json:"dockerExtraArgs,omitempty"`
+	// ContainerRuntime selects the container runtime of the nodes, docker is used when it is nil.
+	// +optional
+	ContainerRuntime *ContainerRuntime `json:"containerRuntime,omitempty"`
 	// +optional
 	KubeletExtraArgs map[string]string `json:"kubeletExtraArgs,omitempty"`
 	// +optional
@@ -293,6 +575,58 @@ type ClusterSpec struct {
 	ControllerManagerExtraArgs map[string]string `json:"controllerManagerExtraArgs,omitempty"`
 	// +optional
 	SchedulerExtraArgs map[string]string `json:"schedulerExtraArgs,omitempty"`
+	// Flannel is used when the cniInstall hook is flannel.
+	// +optional
+	Flannel *Flannel `json:"flannel,omitempty"`
+	// NetworkAttachments are the secondary networks served by multus.
+	// +optional
+	NetworkAttachments []NetworkAttachment `json:"networkAttachments,omitempty"`
+	// Placements are the labels and taints applied to the first nodes of the cluster,
+	// the labels and taints of the machine itself take precedence.
+	// +optional
+	Placements []NodePlacement `json:"placements,omitempty"`
+	// Waits overrides the wait parameters of the provisioning phases by name, e.g. nodeReady,
+	// see pkg/timeouts for the names. Slow environments need longer timeouts.
+	// +optional
+	Waits map[string]WaitParam `json:"waits,omitempty"`
+	// Registry is the image registry of the cluster the sandbox image is pulled from, e.g. "symcn.tencentcloudcr.com/symcn".
+	// Defaults to the registry of the provider config.
+	// +optional
+	Registry string `json:"registry,omitempty"`
+	// RegistryMirrors is the pull configuration of the registries keyed by host, e.g. "docker.io" or "registry.example.com:5000",
+	// it is rendered into the container runtime config of every machine and kept reconciled.
+	// The mirrors of docker.io override containerRuntime.registryMirrors.
+	// +optional
+	RegistryMirrors map[string]RegistryMirror `json:"registryMirrors,omitempty"`
+	// ExternalCA signs the cluster certs with an existing CA instead of a generated one.
+	// +optional
+	ExternalCA *ExternalCA `json:"externalCA,omitempty"`
+	// VaultPKI issues the cluster certs by vault instead of the local keys.
+	// +optional
+	VaultPKI *VaultPKI `json:"vaultPKI,omitempty"`
+	// OIDC authenticates the users of the corp SSO natively by the apiserver.
+	// +optional
+	OIDC *OIDC `json:"oidc,omitempty"`
+	// Audit customizes the audit policy of the apiserver and ships the audit logs.
+	// +optional
+	Audit *Audit `json:"audit,omitempty"`
+	// Encryption encrypts the secrets at rest, removing it decrypts them again.
+	// +optional
+	Encryption *Encryption `json:"encryption,omitempty"`
+	// PodSecurity applies the Pod Security Standards to the namespaces but the system ones.
+	// +optional
+	PodSecurity *PodSecurity `json:"podSecurity,omitempty"`
+	// Infrastructure provisions the instances of the machines, e.g. the VMs of a vCenter, for the clusters of
+	// the providers of the infrastructure.
+	// +optional
+	Infrastructure *Infrastructure `json:"infrastructure,omitempty"`
+	// Managed attaches the cluster of a cloud, e.g. EKS, for the clusters of the Managed provider.
+	// +optional
+	Managed *ManagedCluster `json:"managed,omitempty"`
+	// InfraHooks run an external IaC tool, e.g. terraform, before the machines are provisioned and once the
+	// cluster is created.
+	// +optional
+	InfraHooks *InfraHooks `json:"infraHooks,omitempty"`
 	// Etcd holds configuration for etcd.
 	Etcd *Etcd `json:"etcd,omitempty"`
 	//
@@ -312,6 +646,10 @@ type ClusterStatus struct {
 	// +patchMergeKey=type
 	// +patchStrategy=merge
 	Conditions []ClusterCondition `json:"conditions,omitempty"`
+	// CompletedPhases the create phases completed, they are skipped when reached again until rerun by the
+	// k8s.io/rerun-phases annotation.
+	// +optional
+	CompletedPhases []string `json:"completedPhases,omitempty"`
 	// A human readable message indicating details about why the cluster is in this condition.
 	// +optional
 	Message string `json:"message,omitempty"`
@@ -337,6 +675,9 @@ type ClusterStatus struct {
 	DNSIP string `json:"dnsIP,omitempty"`
 	// +optional
 	MonitoringStatus *MonitoringStatus `json:"monitoringStatus,omitempty"`
+	// Connectivity is the result of the periodic probes of the client of the cluster.
+	// +optional
+	Connectivity *ClusterConnectivity `json:"connectivity,omitempty"`
 	// +optional
 	RegistryIPs []string `json:"registryIPs,omitempty"`
 	NodeCount   int      `json:"nodeCount,omitempty"`
@@ -349,6 +690,21 @@ type MonitoringStatus struct {
 	PrometheusEndpoint   *string `json:"prometheusEndpoint,omitempty"`
 }
 
+// ClusterConnectivity records whether the cluster is reachable by the client of the controller,
+// it's updated when the probes of the client start or stop failing.
+type ClusterConnectivity struct {
+	Reachable bool `json:"reachable"`
+	// Last time the cluster became reachable or unreachable.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// The error of the probe of the unreachable cluster.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// Last time a probe of the health controller succeeded, it's refreshed every few probes.
+	// +optional
+	LastSeen *metav1.Time `json:"lastSeen,omitempty"`
+}
+
 // +genclient
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
@@ -356,6 +712,7 @@ type MonitoringStatus struct {
 
 // Cluster is the Schema for the Cluster API
 // +k8s:openapi-gen=true
+// +kubebuilder:storageversion
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:shortName=vc
 // +kubebuilder:printcolumn:name="DNSIP",type="string",JSONPath=".status.dnsIP",description="The cluster dnsIP."
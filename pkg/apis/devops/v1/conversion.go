@@ -0,0 +1,18 @@
+package v1
+
+import "sigs.k8s.io/controller-runtime/pkg/conversion"
+
+var (
+	_ conversion.Hub = &Cluster{}
+	_ conversion.Hub = &Machine{}
+	_ conversion.Hub = &ClusterCredential{}
+)
+
+// Hub marks v1, the storage version, as the version the other versions are converted through.
+func (*Cluster) Hub() {}
+
+// Hub ...
+func (*Machine) Hub() {}
+
+// Hub ...
+func (*ClusterCredential) Hub() {}
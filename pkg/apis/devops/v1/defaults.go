@@ -0,0 +1,73 @@
+package v1
+
+import (
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/util/pointer"
+)
+
+const (
+	// DefaultDNSDomain the dns domain of the k8s services
+	DefaultDNSDomain = "cluster.local"
+	// DefaultBindPort the port of the apiserver
+	DefaultBindPort = 6443
+	// DefaultMaxNum the default max number of the services of the cluster and the pods of a node
+	DefaultMaxNum = 256
+	// DefaultSSHPort the ssh port of the machines
+	DefaultSSHPort = 22
+	// DefaultSSHUser the ssh user of the machines
+	DefaultSSHUser = "root"
+)
+
+// Default fills the fields left empty by a minimal spec, the mutating webhook persists them on admission
+// and the controllers apply them again in memory for the clusters created without the webhook.
+func (in *Cluster) Default() {
+	spec := &in.Spec
+	if spec.Version == "" {
+		spec.Version = constants.K8sVersions[0]
+	}
+	if spec.ClusterCIDR == "" {
+		spec.ClusterCIDR = "10.244.0.0/16"
+	}
+	if spec.NetworkDevice == "" {
+		spec.NetworkDevice = "eth0"
+	}
+	if spec.DNSDomain == "" {
+		spec.DNSDomain = DefaultDNSDomain
+	}
+	if spec.Features.IPVS == nil {
+		spec.Features.IPVS = pointer.ToBool(true)
+	}
+	if ha := spec.Features.HA; ha != nil && ha.ThirdPartyHA != nil && ha.ThirdPartyHA.VPort == 0 {
+		ha.ThirdPartyHA.VPort = DefaultBindPort
+	}
+	if spec.Properties.MaxClusterServiceNum == nil && spec.ServiceCIDR == nil {
+		spec.Properties.MaxClusterServiceNum = pointer.ToInt32(DefaultMaxNum)
+	}
+	if spec.Properties.MaxNodePodNum == nil {
+		spec.Properties.MaxNodePodNum = pointer.ToInt32(DefaultMaxNum)
+	}
+	if spec.Etcd == nil {
+		spec.Etcd = &Etcd{Local: &LocalEtcd{}}
+	}
+	for _, m := range spec.Machines {
+		m.Default()
+	}
+}
+
+// Default fills the ssh port and user of the machine.
+func (in *Machine) Default() {
+	in.Spec.Machine.Default()
+}
+
+// Default fills the ssh port and user.
+func (in *ClusterMachine) Default() {
+	if in == nil {
+		return
+	}
+	if in.Port == 0 {
+		in.Port = DefaultSSHPort
+	}
+	if in.Username == "" {
+		in.Username = DefaultSSHUser
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"sort"
 	"time"
 
+	"github.com/gostship/kunkka/pkg/constants"
 	"github.com/gostship/kunkka/pkg/util/ssh"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -42,6 +44,89 @@ type ClusterMachine struct {
 	// +optional
 	Taints  []corev1.Taint `json:"taints,omitempty"`
 	HostCni *ClusterCni    `json:"hostCni"`
+	// Bastions are the jump hosts to reach the machine through, the first one is dialed directly.
+	// The bastions of the rack or the cluster are used when it is empty.
+	// +optional
+	Bastions []SSHBastion `json:"bastions,omitempty"`
+	// DefaultBastions are the bastions of the rack or the cluster resolved by Cluster.DefaultBastions,
+	// they are never persisted.
+	DefaultBastions []SSHBastion `json:"-"`
+	// CredentialRef references the password or the private key kept in a Secret or vault
+	// instead of the spec, it takes precedence over the credential of the spec.
+	// +optional
+	CredentialRef *SSHCredentialRef `json:"credentialRef,omitempty"`
+	// Credential is the credential loaded from the CredentialRef by credential.LoadSSH,
+	// it is never persisted.
+	Credential *SSHCredential `json:"-"`
+	// Audit labels the commands run on the machine in the ssh audit, set by the providers
+	// per phase, it is never persisted.
+	Audit SSHAudit `json:"-"`
+	// HostKey is the public host key of the machine in the authorized_keys format, e.g. the content of
+	// /etc/ssh/ssh_host_ed25519_key.pub, the key pinned on the first contact is verified when it is empty.
+	// +optional
+	HostKey string `json:"hostKey,omitempty"`
+}
+
+// SSHAudit is the cluster and the phase the commands run on a machine for.
+type SSHAudit struct {
+	Cluster string `json:"cluster,omitempty"`
+	Phase   string `json:"phase,omitempty"`
+	// TraceParent is the w3c traceparent of the span of the phase, the spans of the commands are its children.
+	TraceParent string `json:"traceParent,omitempty"`
+}
+
+// SSHCredentialRef references the ssh credential of the machine, the secret has the keys:
+// password, privateKey and passPhrase.
+type SSHCredentialRef struct {
+	// SecretName is the Secret in the namespace of the machine.
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+	// Vault is the kv secret of vault.
+	// +optional
+	Vault *VaultSecretRef `json:"vault,omitempty"`
+}
+
+// VaultSecretRef is a secret of the kv secrets engine of vault, both kv v1 and v2 are supported.
+type VaultSecretRef struct {
+	// Address of vault, e.g. https://vault.example.com:8200
+	Address string `json:"address"`
+	// Path of the secret, e.g. secret/data/machines/rack-a of kv v2.
+	Path string `json:"path"`
+	// TokenSecretName is the Secret in the namespace of the machine with the vault token (token)
+	// and optionally the CA of vault (ca.crt).
+	TokenSecretName string `json:"tokenSecretName"`
+}
+
+// SSHCredential is the resolved ssh credential of a machine.
+type SSHCredential struct {
+	Password   string `json:"password,omitempty"`
+	PrivateKey []byte `json:"privateKey,omitempty"`
+	PassPhrase []byte `json:"passPhrase,omitempty"`
+}
+
+// SSHBastion is a jump host of the machines.
+type SSHBastion struct {
+	IP string `json:"ip"`
+	// Port defaults to 22.
+	// +optional
+	Port     int32  `json:"port,omitempty"`
+	Username string `json:"username"`
+	// +optional
+	Password string `json:"password,omitempty"`
+	// +optional
+	PrivateKey []byte `json:"privateKey,omitempty"`
+	// +optional
+	PassPhrase []byte `json:"passPhrase,omitempty"`
+	// HostKey is the public host key of the bastion in the authorized_keys format,
+	// the key pinned on the first contact is verified when it is empty.
+	// +optional
+	HostKey string `json:"hostKey,omitempty"`
+}
+
+// RackBastion are the bastions of the machines in the rack, matched by hostCni.rackTag.
+type RackBastion struct {
+	RackTag  string       `json:"rackTag"`
+	Bastions []SSHBastion `json:"bastions"`
 }
 
 // ClusterCni configuration for cluster or machine cni
@@ -69,6 +154,9 @@ func (in *Cluster) SetCondition(newCondition ClusterCondition) {
 			exist = true
 			if newCondition.LastTransitionTime.IsZero() {
 				newCondition.LastTransitionTime = condition.LastTransitionTime
+				if condition.Status != newCondition.Status {
+					newCondition.LastTransitionTime = metav1.Now()
+				}
 			}
 			condition = newCondition
 		}
@@ -85,20 +173,135 @@ func (in *Cluster) SetCondition(newCondition ClusterCondition) {
 	in.Status.Conditions = conditions
 }
 
+// Reachable returns whether the apiserver of the cluster answered the last probe of the health controller,
+// known is false if it's not probed.
+func (in *Cluster) Reachable() (reachable, known bool) {
+	for _, condition := range in.Status.Conditions {
+		if condition.Type == ClusterConditionReachable {
+			return condition.Status == ConditionTrue, condition.Status != ConditionUnknown
+		}
+	}
+	return false, false
+}
+
+// CompletePhase checkpoints the create phase of the cluster as completed.
+func (in *Cluster) CompletePhase(phase string) {
+	if !in.PhaseCompleted(phase) {
+		in.Status.CompletedPhases = append(in.Status.CompletedPhases, phase)
+	}
+}
+
+// PhaseCompleted returns whether the create phase of the cluster is checkpointed.
+func (in *Cluster) PhaseCompleted(phase string) bool {
+	for _, p := range in.Status.CompletedPhases {
+		if p == phase {
+			return true
+		}
+	}
+	return false
+}
+
+// ForgetPhases removes the checkpoints of the phases, they are run again when reached.
+func (in *Cluster) ForgetPhases(phases ...string) {
+	completed := make([]string, 0, len(in.Status.CompletedPhases))
+	for _, p := range in.Status.CompletedPhases {
+		if !constants.ContainsString(phases, p) {
+			completed = append(completed, p)
+		}
+	}
+	in.Status.CompletedPhases = completed
+}
+
 func (in *ClusterMachine) SSH() (*ssh.SSH, error) {
+	cred, err := in.SSHCredential()
+	if err != nil {
+		return nil, err
+	}
 	sshConfig := &ssh.Config{
 		User:        in.Username,
 		Host:        in.IP,
 		Port:        int(in.Port),
-		Password:    in.Password,
-		PrivateKey:  in.PrivateKey,
-		PassPhrase:  in.PassPhrase,
+		Password:    cred.Password,
+		PrivateKey:  cred.PrivateKey,
+		PassPhrase:  cred.PassPhrase,
 		DialTimeOut: time.Second,
 		Retry:       0,
+		Bastions:    in.sshBastions(),
+		Cluster:     in.Audit.Cluster,
+		Phase:       in.Audit.Phase,
+		TraceParent: in.Audit.TraceParent,
+		HostKey:     in.HostKey,
 	}
 	return ssh.New(sshConfig)
 }
 
+// SSHCredential returns the credential loaded from the CredentialRef, or else the one of the spec.
+func (in *ClusterMachine) SSHCredential() (*SSHCredential, error) {
+	if in.CredentialRef == nil {
+		return &SSHCredential{Password: in.Password, PrivateKey: in.PrivateKey, PassPhrase: in.PassPhrase}, nil
+	}
+	if in.Credential == nil {
+		return nil, fmt.Errorf("ssh credential of machine %s is not loaded", in.IP)
+	}
+	return in.Credential, nil
+}
+
+// sshBastions returns the ssh configs of the bastions of the machine, or else the default ones.
+func (in *ClusterMachine) sshBastions() []*ssh.Config {
+	bastions := in.Bastions
+	if len(bastions) == 0 {
+		bastions = in.DefaultBastions
+	}
+	var configs []*ssh.Config
+	for _, b := range bastions {
+		configs = append(configs, &ssh.Config{
+			User:       b.Username,
+			Host:       b.IP,
+			Port:       int(b.Port),
+			Password:   b.Password,
+			PrivateKey: b.PrivateKey,
+			PassPhrase: b.PassPhrase,
+			HostKey:    b.HostKey,
+		})
+	}
+	return configs
+}
+
+// Bastions returns the bastions of the machine in the cluster: the ones of the machine,
+// or else the ones of its rack, or else the ones of the cluster.
+func (in *Cluster) Bastions(m *ClusterMachine) []SSHBastion {
+	if len(m.Bastions) != 0 {
+		return m.Bastions
+	}
+	if m.HostCni != nil && m.HostCni.RackTag != "" {
+		for _, rack := range in.Spec.RackBastions {
+			if rack.RackTag == m.HostCni.RackTag {
+				return rack.Bastions
+			}
+		}
+	}
+	return in.Spec.Bastions
+}
+
+// AuditPhase labels the commands run on the machines of the cluster spec with the phase, their spans are the
+// children of the traceParent if any.
+func (in *Cluster) AuditPhase(phase, traceParent string) {
+	for _, m := range in.Spec.Machines {
+		if m != nil {
+			m.Audit = SSHAudit{Cluster: in.Name, Phase: phase, TraceParent: traceParent}
+		}
+	}
+}
+
+// DefaultBastions sets the default bastions of the machines by the rack and the cluster.
+func (in *Cluster) DefaultBastions(machines ...*ClusterMachine) {
+	for _, m := range machines {
+		if m != nil && len(m.Bastions) == 0 {
+			m.DefaultBastions = in.Bastions(m)
+		}
+	}
+}
+
 func (in *Cluster) Address(addrType AddressType) *ClusterAddress {
 	for _, one := range in.Status.Addresses {
 		if one.Type == addrType {
@@ -134,6 +337,71 @@ func (in *Cluster) RemoveAddress(addrType AddressType) {
 	in.Status.Addresses = addrs
 }
 
+// ContainerRuntimeType returns the container runtime of the nodes, defaults to docker.
+func (in *Cluster) ContainerRuntimeType() ContainerRuntimeType {
+	if in.Spec.ContainerRuntime == nil || in.Spec.ContainerRuntime.Type == "" {
+		return ContainerRuntimeDocker
+	}
+
+	return in.Spec.ContainerRuntime.Type
+}
+
+// CRISocket returns the CRI socket of the container runtime.
+func (in *Cluster) CRISocket() string {
+	switch in.ContainerRuntimeType() {
+	case ContainerRuntimeContainerd:
+		return constants.ContainerdCRISocket
+	case ContainerRuntimeCRIO:
+		return constants.CRIOCRISocket
+	default:
+		return constants.DefaultDockerCRISocket
+	}
+}
+
+// NodePlacementOf returns the placement the machine with the ip is dedicated to, nil if it is beyond all the placements.
+// The placements take the machines of the cluster in order of creation.
+func (in *Cluster) NodePlacementOf(machines []Machine, ip string) *NodePlacement {
+	if len(in.Spec.Placements) == 0 {
+		return nil
+	}
+
+	ms := make([]*Machine, 0, len(machines))
+	for i := range machines {
+		m := &machines[i]
+		if m.Spec.ClusterName != in.Name || m.Spec.Machine == nil || m.DeletionTimestamp != nil {
+			continue
+		}
+		ms = append(ms, m)
+	}
+	sort.SliceStable(ms, func(i, j int) bool {
+		if !ms[i].CreationTimestamp.Equal(&ms[j].CreationTimestamp) {
+			return ms[i].CreationTimestamp.Before(&ms[j].CreationTimestamp)
+		}
+		return ms[i].Name < ms[j].Name
+	})
+
+	idx := -1
+	for i, m := range ms {
+		if m.Spec.Machine.IP == ip {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return nil
+	}
+
+	for i := range in.Spec.Placements {
+		p := &in.Spec.Placements[i]
+		if idx < int(p.Replicas) {
+			return p
+		}
+		idx -= int(p.Replicas)
+	}
+
+	return nil
+}
+
 func (in *Cluster) Host() (string, error) {
 	addrs := make(map[AddressType][]ClusterAddress)
 	for _, one := range in.Status.Addresses {
@@ -171,6 +439,9 @@ func (in *Machine) SetCondition(newCondition MachineCondition) {
 			exist = true
 			if newCondition.LastTransitionTime.IsZero() {
 				newCondition.LastTransitionTime = condition.LastTransitionTime
+				if condition.Status != newCondition.Status {
+					newCondition.LastTransitionTime = metav1.Now()
+				}
 			}
 			condition = newCondition
 		}
@@ -187,16 +458,60 @@ func (in *Machine) SetCondition(newCondition MachineCondition) {
 	in.Status.Conditions = conditions
 }
 
+// CompletePhase checkpoints the create phase of the machine as completed.
+func (in *Machine) CompletePhase(phase string) {
+	if !in.PhaseCompleted(phase) {
+		in.Status.CompletedPhases = append(in.Status.CompletedPhases, phase)
+	}
+}
+
+// PhaseCompleted returns whether the create phase of the machine is checkpointed.
+func (in *Machine) PhaseCompleted(phase string) bool {
+	for _, p := range in.Status.CompletedPhases {
+		if p == phase {
+			return true
+		}
+	}
+	return false
+}
+
+// ForgetPhases removes the checkpoints of the phases, they are run again when reached.
+func (in *Machine) ForgetPhases(phases ...string) {
+	completed := make([]string, 0, len(in.Status.CompletedPhases))
+	for _, p := range in.Status.CompletedPhases {
+		if !constants.ContainsString(phases, p) {
+			completed = append(completed, p)
+		}
+	}
+	in.Status.CompletedPhases = completed
+}
+
+// AuditPhase labels the commands run on the machine with the phase, their spans are the children of the
+// traceParent if any.
+func (in *Machine) AuditPhase(phase, traceParent string) {
+	if in.Spec.Machine != nil {
+		in.Spec.Machine.Audit = SSHAudit{Cluster: in.Spec.ClusterName, Phase: phase, TraceParent: traceParent}
+	}
+}
+
 func (in *MachineSpec) SSH() (*ssh.SSH, error) {
+	cred, err := in.Machine.SSHCredential()
+	if err != nil {
+		return nil, err
+	}
 	sshConfig := &ssh.Config{
 		User:        in.Machine.Username,
 		Host:        in.Machine.IP,
 		Port:        int(in.Machine.Port),
-		Password:    in.Machine.Password,
-		PrivateKey:  in.Machine.PrivateKey,
-		PassPhrase:  in.Machine.PassPhrase,
+		Password:    cred.Password,
+		PrivateKey:  cred.PrivateKey,
+		PassPhrase:  cred.PassPhrase,
 		DialTimeOut: time.Second,
 		Retry:       0,
+		Bastions:    in.Machine.sshBastions(),
+		Cluster:     in.Machine.Audit.Cluster,
+		Phase:       in.Machine.Audit.Phase,
+		HostKey:     in.Machine.HostKey,
 	}
 	return ssh.New(sshConfig)
 }
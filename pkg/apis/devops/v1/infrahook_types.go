@@ -0,0 +1,53 @@
+/*
+Copyright 2020 dke.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+// InfraHooks run an external IaC tool, e.g. terraform, as the Jobs in the namespace of the cluster, for the
+// changes of the switches, the VLANs or the load balancers coordinated with the creation of the cluster. The
+// state of the tool is kept by its own backend, the Jobs are run again on the retries of the phases.
+type InfraHooks struct {
+	// Pre runs before the instances are provisioned and the machines are installed, the ips of its outputs are
+	// set to the masters without ip.
+	// +optional
+	Pre *InfraHook `json:"pre,omitempty"`
+	// Post runs once the cluster is created, e.g. to balance the masters by the load balancer.
+	// +optional
+	Post *InfraHook `json:"post,omitempty"`
+}
+
+// InfraHook is the Job of a hook. The Job writes the JSON object of its outputs to the termination message of
+// the container, e.g. by `terraform output -json > /dev/termination-log`, the outputs of terraform and the
+// plain values are both accepted. The environment has KUNKKA_CLUSTER, KUNKKA_NAMESPACE and KUNKKA_MASTER_IPS,
+// the ips of the masters separated by commas.
+type InfraHook struct {
+	// Image of the Job, e.g. "hashicorp/terraform:0.13.5".
+	Image string `json:"image"`
+	// Command of the Job, e.g. ["sh", "-c", "terraform init && terraform apply -auto-approve && terraform output -json > /dev/termination-log"].
+	Command []string `json:"command"`
+	// ConfigMapName is the ConfigMap whose files are copied to /workspace, the working directory, e.g. the
+	// terraform files. The image must have sh to copy them.
+	// +optional
+	ConfigMapName string `json:"configMapName,omitempty"`
+	// SecretName is the Secret of the environment of the Job, e.g. the credential of the cloud and of the
+	// backend of the state.
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+	// MachineIPsOutput is the output of the list of the ips of the masters in the order of spec.machines, only
+	// for the pre hook.
+	// +optional
+	MachineIPsOutput string `json:"machineIPsOutput,omitempty"`
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2020 dke.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+// Infrastructure is where the instances of the machines of the cluster are provisioned. The masters of
+// spec.machines and the Machines without ip get an instance, once it has an ip it's installed over ssh
+// like a bare metal machine with the username and the credential of the machine.
+type Infrastructure struct {
+	// SecretName is the Secret in the namespace of the cluster with the credential of the infrastructure
+	// API, e.g. the username and password keys of the vCenter.
+	SecretName string `json:"secretName"`
+	// VSphere clones the instances from a VM template of a vCenter.
+	// +optional
+	VSphere *VSphereInfrastructure `json:"vsphere,omitempty"`
+	// OpenStack boots the instances from an image with Nova on the ports of Neutron.
+	// +optional
+	OpenStack *OpenStackInfrastructure `json:"openstack,omitempty"`
+	// AWS launches the instances with EC2 into the subnets of their racks.
+	// +optional
+	AWS *AWSInfrastructure `json:"aws,omitempty"`
+	// Libvirt creates the instances as the VMs of the libvirt of the hypervisors.
+	// +optional
+	Libvirt *LibvirtInfrastructure `json:"libvirt,omitempty"`
+	// CAPI provisions the instances as the Machines of Cluster API with its infrastructure providers.
+	// +optional
+	CAPI *CAPIInfrastructure `json:"capi,omitempty"`
+}
+
+// VSphereInfrastructure clones the instances from a VM template, the template and the placement are given by
+// the inventory paths relative to the datacenter or the names, e.g. "kunkka/centos7" of a template in the
+// kunkka folder. The template's own resource pool and hardware are used for the fields not set.
+type VSphereInfrastructure struct {
+	// Server is the url of the vCenter, e.g. "https://vcenter.example.com".
+	Server string `json:"server"`
+	// Insecure skips the verification of the certificate of the vCenter.
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
+	// Datacenter of the instances, it may be empty if the vCenter has only one datacenter.
+	// +optional
+	Datacenter string `json:"datacenter,omitempty"`
+	// Template is the VM template the instances are cloned from.
+	Template string `json:"template"`
+	// +optional
+	Folder string `json:"folder,omitempty"`
+	// +optional
+	ResourcePool string `json:"resourcePool,omitempty"`
+	// +optional
+	Cluster string `json:"cluster,omitempty"`
+	// Datastore of the disks of the instances.
+	// +optional
+	Datastore string `json:"datastore,omitempty"`
+	// +optional
+	NumCPUs int32 `json:"numCPUs,omitempty"`
+	// +optional
+	MemoryMiB int64 `json:"memoryMiB,omitempty"`
+}
+
+// OpenStackInfrastructure boots the instances on the ports created in the subnets of their racks, so the ip of
+// an instance is known before it boots. The credential of the secret has the username, password, projectID and
+// the optional domainName keys, the domain defaults to "Default".
+type OpenStackInfrastructure struct {
+	// AuthURL is the url of the Keystone v3 API, e.g. "https://keystone.example.com:5000/v3".
+	AuthURL string `json:"authURL"`
+	// Region of the endpoints of the services in the catalog, it may be empty if the catalog has only one region.
+	// +optional
+	Region string `json:"region,omitempty"`
+	// Insecure skips the verification of the certificates of the endpoints.
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
+	// Flavor is the id of the flavor of the instances.
+	Flavor string `json:"flavor"`
+	// Image is the id of the image of the instances.
+	Image string `json:"image"`
+	// KeyName is the keypair injected into the instances.
+	// +optional
+	KeyName string `json:"keyName,omitempty"`
+	// +optional
+	AvailabilityZone string `json:"availabilityZone,omitempty"`
+	// SecurityGroups are the ids of the security groups of the ports.
+	// +optional
+	SecurityGroups []string `json:"securityGroups,omitempty"`
+	// Subnets of the ports by the rack of the machine, i.e. the rackTag of its hostCni, the machines of the
+	// racks not listed get a port in the first subnet.
+	Subnets []OpenStackSubnet `json:"subnets"`
+	// LoadBalancer balances the apiservers of the masters with an Octavia load balancer, its vip is set as
+	// the third party ha of the cluster before the certificates are generated.
+	// +optional
+	LoadBalancer *OpenStackLoadBalancer `json:"loadBalancer,omitempty"`
+}
+
+// OpenStackSubnet is the subnet of the machines of a rack.
+type OpenStackSubnet struct {
+	// +optional
+	RackTag   string `json:"rackTag,omitempty"`
+	NetworkID string `json:"networkID"`
+	SubnetID  string `json:"subnetID"`
+}
+
+// OpenStackLoadBalancer is the Octavia load balancer of the apiservers.
+type OpenStackLoadBalancer struct {
+	// SubnetID is the subnet of the vip, defaults to the first subnet.
+	// +optional
+	SubnetID string `json:"subnetID,omitempty"`
+}
+
+// AWSInfrastructure launches the EC2 instances tagged with the ownership of the cluster, i.e. the
+// "kubernetes.io/cluster/<cluster>" tag with "owned", they are installed at their private ip so the control
+// plane must reach the VPC, e.g. over a VPN. The credential of the secret has the accessKeyID, secretAccessKey
+// and the optional sessionToken keys.
+type AWSInfrastructure struct {
+	Region string `json:"region"`
+	// AMI is the id of the image of the instances.
+	AMI string `json:"ami"`
+	// InstanceType of the instances, e.g. "m5.xlarge".
+	InstanceType string `json:"instanceType"`
+	// KeyName is the key pair injected into the instances.
+	// +optional
+	KeyName string `json:"keyName,omitempty"`
+	// SecurityGroups are the ids of the security groups of the instances.
+	// +optional
+	SecurityGroups []string `json:"securityGroups,omitempty"`
+	// Subnets of the instances by the rack of the machine, i.e. the rackTag of its hostCni, the machines of the
+	// racks not listed are launched into the first subnet.
+	Subnets []AWSSubnet `json:"subnets"`
+	// LoadBalancer balances the apiservers of the masters with a network load balancer, its dns name is set as
+	// the third party ha of the cluster before the certificates are generated.
+	// +optional
+	LoadBalancer *AWSLoadBalancer `json:"loadBalancer,omitempty"`
+}
+
+// AWSSubnet is the subnet of the machines of a rack.
+type AWSSubnet struct {
+	// +optional
+	RackTag  string `json:"rackTag,omitempty"`
+	SubnetID string `json:"subnetID"`
+}
+
+// AWSLoadBalancer is the network load balancer of the apiservers.
+type AWSLoadBalancer struct {
+	// SubnetIDs are the subnets of the load balancer, one of each availability zone, defaults to the first
+	// subnet.
+	// +optional
+	SubnetIDs []string `json:"subnetIDs,omitempty"`
+	// InternetFacing creates the load balancer with a public dns name, it's internal by default.
+	// +optional
+	InternetFacing bool `json:"internetFacing,omitempty"`
+}
+
+// LibvirtInfrastructure creates the instances as the VMs of libvirt on the hypervisors, e.g. for the ephemeral
+// dev and test clusters. The hypervisors are reached over ssh with the password, privateKey and passPhrase keys
+// of the secret. The disk of an instance is an overlay of the cloud image, the username and the credential of
+// the machine are set by cloud-init.
+type LibvirtInfrastructure struct {
+	// Hosts are the hypervisors, the instances are spread over them by name.
+	Hosts []LibvirtHost `json:"hosts"`
+	// Image is the path of the qcow2 cloud image on the hosts.
+	Image string `json:"image"`
+	// Network is the libvirt network of the instances. Defaults to "default".
+	// +optional
+	Network string `json:"network,omitempty"`
+	// StoragePath is the directory of the disks of the instances on the hosts. Defaults to "/var/lib/libvirt/images".
+	// +optional
+	StoragePath string `json:"storagePath,omitempty"`
+	// NumCPUs defaults to 2.
+	// +optional
+	NumCPUs int32 `json:"numCPUs,omitempty"`
+	// MemoryMiB defaults to 4096.
+	// +optional
+	MemoryMiB int64 `json:"memoryMiB,omitempty"`
+	// DiskGiB defaults to 40.
+	// +optional
+	DiskGiB int32 `json:"diskGiB,omitempty"`
+}
+
+// LibvirtHost is a hypervisor, it's reached through the bastions of the cluster.
+type LibvirtHost struct {
+	IP string `json:"ip"`
+	// Port of ssh. Defaults to 22.
+	// +optional
+	Port     int32  `json:"port,omitempty"`
+	Username string `json:"username"`
+	// HostKey is the public host key of the hypervisor in the authorized_keys format.
+	// +optional
+	HostKey string `json:"hostKey,omitempty"`
+}
+
+// CAPIInfrastructure provisions the instances as the Machines of Cluster API v1alpha3 in the management cluster
+// of the kubeconfig key of the secret, e.g. for the sites standardized on the CAPI infrastructure providers. A
+// CAPI Cluster of the name of the cluster is created with the infrastructure cluster, the machine of each
+// instance is cloned from the machine template and bootstrapped with the cloud-init data setting the username
+// and the credential of the machine, the rack of the machine is its failure domain. Kunkka still installs the
+// instances, the CAPI bootstrap and control plane providers are not used.
+type CAPIInfrastructure struct {
+	// Namespace of the CAPI resources in the management cluster. Defaults to the namespace of the cluster.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// InfrastructureRef is the infrastructure cluster of the CAPI Cluster in the namespace, e.g. an AWSCluster.
+	InfrastructureRef CAPIObjectReference `json:"infrastructureRef"`
+	// MachineTemplate is the infrastructure machine template in the namespace the machines of the instances are
+	// cloned from, e.g. an AWSMachineTemplate.
+	MachineTemplate CAPIObjectReference `json:"machineTemplate"`
+}
+
+// CAPIObjectReference references an object of a CAPI infrastructure provider in the namespace.
+type CAPIObjectReference struct {
+	// APIVersion, e.g. "infrastructure.cluster.x-k8s.io/v1alpha3".
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+}
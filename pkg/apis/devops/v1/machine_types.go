@@ -82,6 +82,34 @@ type MachineSystemInfo struct {
 	Architecture string `json:"architecture,omitempty"`
 }
 
+// MachineOSDrift records whether the os/kernel of a machine drifted from what it was
+// provisioned with or from the cluster os baseline.
+type MachineOSDrift struct {
+	// +optional
+	Drifted bool `json:"drifted,omitempty"`
+	// Human-readable items of the drift, e.g. "kernelVersion: 4.19.1 -> 5.4.2".
+	// +optional
+	Reasons []string `json:"reasons,omitempty"`
+	// Enforced means the maintenance of the node has been requested because of the drift.
+	// +optional
+	Enforced bool `json:"enforced,omitempty"`
+	// +optional
+	LastCheckTime metav1.Time `json:"lastCheckTime,omitempty"`
+}
+
+// MachineArtifacts reports the secrets left on the node by the provisioning, e.g. the bootstrap kubeconfig,
+// the token files and the copied keys, which are removed or restricted to the owner by the cleanup.
+type MachineArtifacts struct {
+	// Items cleaned by the last check, e.g. "/etc/kubernetes/known_tokens.csv: removed".
+	// +optional
+	Cleaned []string `json:"cleaned,omitempty"`
+	// Items still left on the node and the reason, e.g. "/etc/kubernetes/bootstrap-kubelet.conf: waiting for the kubelet client certificate".
+	// +optional
+	Leftover []string `json:"leftover,omitempty"`
+	// +optional
+	LastCheckTime metav1.Time `json:"lastCheckTime,omitempty"`
+}
+
 // MachineCondition contains details for the current condition of this Machine.
 type MachineCondition struct {
 	// Type is the type of the condition.
@@ -101,6 +129,13 @@ type MachineCondition struct {
 	// Human-readable message indicating details about last transition.
 	// +optional
 	Message string `json:"message,omitempty"`
+	// Attempts is the number of the consecutive failures of the condition, the condition
+	// backs off between the attempts and is failed once the budget is exhausted.
+	// +optional
+	Attempts int32 `json:"attempts,omitempty"`
+	// Duration is how long the last run of the condition took.
+	// +optional
+	Duration metav1.Duration `json:"duration,omitempty"`
 }
 
 type MachineFeature struct {
@@ -112,6 +147,28 @@ type MachineFeature struct {
 	Hooks map[string]string `json:"hooks,omitempty"`
 }
 
+// KubeletConfig is the fragment of the KubeletConfiguration tuned per machine,
+// the unset fields keep the defaults of the cluster.
+type KubeletConfig struct {
+	// +optional
+	MaxPods *int32 `json:"maxPods,omitempty"`
+	// CgroupDriver is one of systemd, cgroupfs, the container runtime of the machine uses the same driver.
+	// +optional
+	CgroupDriver string `json:"cgroupDriver,omitempty"`
+	// +optional
+	EvictionHard map[string]string `json:"evictionHard,omitempty"`
+	// +optional
+	EvictionSoft map[string]string `json:"evictionSoft,omitempty"`
+	// +optional
+	EvictionSoftGracePeriod map[string]string `json:"evictionSoftGracePeriod,omitempty"`
+	// +optional
+	KubeReserved map[string]string `json:"kubeReserved,omitempty"`
+	// +optional
+	SystemReserved map[string]string `json:"systemReserved,omitempty"`
+	// +optional
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+}
+
 // MachineSpec is a description of machine.
 type MachineSpec struct {
 	// Finalizers is an opaque list of values that must be empty to permanently remove object from storage.
@@ -123,6 +180,8 @@ type MachineSpec struct {
 	Machine     *ClusterMachine `json:"machine,omitempty"`
 	Feature     *MachineFeature `json:"feature,omitempty"`
 	Pause       bool            `json:"pause,omitempty"`
+	// +optional
+	Kubelet *KubeletConfig `json:"kubelet,omitempty"`
 	//HostCni     *ClusterCni     `json:"hostCni"`
 }
 
@@ -136,6 +195,10 @@ type MachineStatus struct {
 	// +patchMergeKey=type
 	// +patchStrategy=merge
 	Conditions []MachineCondition `json:"conditions,omitempty"`
+	// CompletedPhases the create phases completed, they are skipped when reached again until rerun by the
+	// k8s.io/rerun-phases annotation.
+	// +optional
+	CompletedPhases []string `json:"completedPhases,omitempty"`
 	// A human readable message indicating details about why the machine is in this condition.
 	// +optional
 	Message string `json:"message,omitempty"`
@@ -150,6 +213,13 @@ type MachineStatus struct {
 	// Set of ids/uuids to uniquely identify the node.
 	// +optional
 	MachineInfo MachineSystemInfo `json:"machineInfo,omitempty"`
+	// The os/kernel recorded the first time the machine was checked after provisioning.
+	// +optional
+	ProvisionedInfo *MachineSystemInfo `json:"provisionedInfo,omitempty"`
+	// +optional
+	OSDrift *MachineOSDrift `json:"osDrift,omitempty"`
+	// +optional
+	Artifacts *MachineArtifacts `json:"artifacts,omitempty"`
 }
 
 // +genclient
@@ -159,6 +229,7 @@ type MachineStatus struct {
 
 // Machine is the Schema for the Machine API
 // +k8s:openapi-gen=true
+// +kubebuilder:storageversion
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:shortName=mc
 // +kubebuilder:printcolumn:name="PHASE",type="string",JSONPath=".status.phase",description="The cluter phase."
@@ -0,0 +1,41 @@
+/*
+Copyright 2020 dke.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+// ManagedPlatform is the cloud service running the control plane of a managed cluster.
+type ManagedPlatform string
+
+const (
+	ManagedEKS ManagedPlatform = "EKS"
+	ManagedGKE ManagedPlatform = "GKE"
+	ManagedAKS ManagedPlatform = "AKS"
+	ManagedTKE ManagedPlatform = "TKE"
+)
+
+// ManagedPlatforms the platforms of the managed clusters
+var ManagedPlatforms = []ManagedPlatform{ManagedEKS, ManagedGKE, ManagedAKS, ManagedTKE}
+
+// ManagedCluster attaches a cluster whose control plane and nodes are run by a cloud. The control plane is
+// read-only to kunkka, only the addons, the node placements and the views are applied with the kubeconfig.
+type ManagedCluster struct {
+	// Platform is the cloud service of the cluster, one of EKS, GKE, AKS and TKE.
+	Platform ManagedPlatform `json:"platform"`
+	// SecretName is the Secret in the namespace of the cluster with the kubeconfig in the key "kubeconfig".
+	// The kubeconfig authenticates by a token or a client certificate, the exec and auth provider plugins,
+	// e.g. aws-iam-authenticator, are not supported.
+	SecretName string `json:"secretName"`
+}
@@ -22,9 +22,147 @@ package v1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSInfrastructure) DeepCopyInto(out *AWSInfrastructure) {
+	*out = *in
+	if in.SecurityGroups != nil {
+		in, out := &in.SecurityGroups, &out.SecurityGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Subnets != nil {
+		in, out := &in.Subnets, &out.Subnets
+		*out = make([]AWSSubnet, len(*in))
+		copy(*out, *in)
+	}
+	if in.LoadBalancer != nil {
+		in, out := &in.LoadBalancer, &out.LoadBalancer
+		*out = new(AWSLoadBalancer)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSInfrastructure.
+func (in *AWSInfrastructure) DeepCopy() *AWSInfrastructure {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSInfrastructure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSLoadBalancer) DeepCopyInto(out *AWSLoadBalancer) {
+	*out = *in
+	if in.SubnetIDs != nil {
+		in, out := &in.SubnetIDs, &out.SubnetIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSLoadBalancer.
+func (in *AWSLoadBalancer) DeepCopy() *AWSLoadBalancer {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSLoadBalancer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSSubnet) DeepCopyInto(out *AWSSubnet) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSSubnet.
+func (in *AWSSubnet) DeepCopy() *AWSSubnet {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSSubnet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Audit) DeepCopyInto(out *Audit) {
+	*out = *in
+	if in.PolicyConfigMap != nil {
+		in, out := &in.PolicyConfigMap, &out.PolicyConfigMap
+		*out = new(corev1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Webhook != nil {
+		in, out := &in.Webhook, &out.Webhook
+		*out = new(AuditWebhook)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Audit.
+func (in *Audit) DeepCopy() *Audit {
+	if in == nil {
+		return nil
+	}
+	out := new(Audit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuditWebhook) DeepCopyInto(out *AuditWebhook) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuditWebhook.
+func (in *AuditWebhook) DeepCopy() *AuditWebhook {
+	if in == nil {
+		return nil
+	}
+	out := new(AuditWebhook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CAPIInfrastructure) DeepCopyInto(out *CAPIInfrastructure) {
+	*out = *in
+	out.InfrastructureRef = in.InfrastructureRef
+	out.MachineTemplate = in.MachineTemplate
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CAPIInfrastructure.
+func (in *CAPIInfrastructure) DeepCopy() *CAPIInfrastructure {
+	if in == nil {
+		return nil
+	}
+	out := new(CAPIInfrastructure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CAPIObjectReference) DeepCopyInto(out *CAPIObjectReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CAPIObjectReference.
+func (in *CAPIObjectReference) DeepCopy() *CAPIObjectReference {
+	if in == nil {
+		return nil
+	}
+	out := new(CAPIObjectReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Cluster) DeepCopyInto(out *Cluster) {
 	*out = *in
@@ -118,6 +256,7 @@ func (in *ClusterCondition) DeepCopyInto(out *ClusterCondition) {
 	*out = *in
 	in.LastProbeTime.DeepCopyInto(&out.LastProbeTime)
 	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	out.Duration = in.Duration
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterCondition.
@@ -130,6 +269,26 @@ func (in *ClusterCondition) DeepCopy() *ClusterCondition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterConnectivity) DeepCopyInto(out *ClusterConnectivity) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	if in.LastSeen != nil {
+		in, out := &in.LastSeen, &out.LastSeen
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterConnectivity.
+func (in *ClusterConnectivity) DeepCopy() *ClusterConnectivity {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterConnectivity)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterCredential) DeepCopyInto(out *ClusterCredential) {
 	*out = *in
@@ -228,6 +387,11 @@ func (in *ClusterFeature) DeepCopyInto(out *ClusterFeature) {
 			(*out)[key] = val
 		}
 	}
+	if in.OSBaseline != nil {
+		in, out := &in.OSBaseline, &out.OSBaseline
+		*out = new(OSBaseline)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterFeature.
@@ -304,6 +468,31 @@ func (in *ClusterMachine) DeepCopyInto(out *ClusterMachine) {
 		*out = new(ClusterCni)
 		**out = **in
 	}
+	if in.Bastions != nil {
+		in, out := &in.Bastions, &out.Bastions
+		*out = make([]SSHBastion, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DefaultBastions != nil {
+		in, out := &in.DefaultBastions, &out.DefaultBastions
+		*out = make([]SSHBastion, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.CredentialRef != nil {
+		in, out := &in.CredentialRef, &out.CredentialRef
+		*out = new(SSHCredentialRef)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Credential != nil {
+		in, out := &in.Credential, &out.Credential
+		*out = new(SSHCredential)
+		(*in).DeepCopyInto(*out)
+	}
+	out.Audit = in.Audit
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterMachine.
@@ -415,6 +604,20 @@ func (in *ClusterSpec) DeepCopyInto(out *ClusterSpec) {
 			}
 		}
 	}
+	if in.Bastions != nil {
+		in, out := &in.Bastions, &out.Bastions
+		*out = make([]SSHBastion, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RackBastions != nil {
+		in, out := &in.RackBastions, &out.RackBastions
+		*out = make([]RackBastion, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.DockerExtraArgs != nil {
 		in, out := &in.DockerExtraArgs, &out.DockerExtraArgs
 		*out = make(map[string]string, len(*in))
@@ -422,6 +625,11 @@ func (in *ClusterSpec) DeepCopyInto(out *ClusterSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.ContainerRuntime != nil {
+		in, out := &in.ContainerRuntime, &out.ContainerRuntime
+		*out = new(ContainerRuntime)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.KubeletExtraArgs != nil {
 		in, out := &in.KubeletExtraArgs, &out.KubeletExtraArgs
 		*out = make(map[string]string, len(*in))
@@ -450,6 +658,82 @@ func (in *ClusterSpec) DeepCopyInto(out *ClusterSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.Flannel != nil {
+		in, out := &in.Flannel, &out.Flannel
+		*out = new(Flannel)
+		**out = **in
+	}
+	if in.NetworkAttachments != nil {
+		in, out := &in.NetworkAttachments, &out.NetworkAttachments
+		*out = make([]NetworkAttachment, len(*in))
+		copy(*out, *in)
+	}
+	if in.Placements != nil {
+		in, out := &in.Placements, &out.Placements
+		*out = make([]NodePlacement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Waits != nil {
+		in, out := &in.Waits, &out.Waits
+		*out = make(map[string]WaitParam, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.RegistryMirrors != nil {
+		in, out := &in.RegistryMirrors, &out.RegistryMirrors
+		*out = make(map[string]RegistryMirror, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.ExternalCA != nil {
+		in, out := &in.ExternalCA, &out.ExternalCA
+		*out = new(ExternalCA)
+		**out = **in
+	}
+	if in.VaultPKI != nil {
+		in, out := &in.VaultPKI, &out.VaultPKI
+		*out = new(VaultPKI)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OIDC != nil {
+		in, out := &in.OIDC, &out.OIDC
+		*out = new(OIDC)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Audit != nil {
+		in, out := &in.Audit, &out.Audit
+		*out = new(Audit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Encryption != nil {
+		in, out := &in.Encryption, &out.Encryption
+		*out = new(Encryption)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodSecurity != nil {
+		in, out := &in.PodSecurity, &out.PodSecurity
+		*out = new(PodSecurity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Infrastructure != nil {
+		in, out := &in.Infrastructure, &out.Infrastructure
+		*out = new(Infrastructure)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Managed != nil {
+		in, out := &in.Managed, &out.Managed
+		*out = new(ManagedCluster)
+		**out = **in
+	}
+	if in.InfraHooks != nil {
+		in, out := &in.InfraHooks, &out.InfraHooks
+		*out = new(InfraHooks)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Etcd != nil {
 		in, out := &in.Etcd, &out.Etcd
 		*out = new(Etcd)
@@ -493,6 +777,11 @@ func (in *ClusterStatus) DeepCopyInto(out *ClusterStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.CompletedPhases != nil {
+		in, out := &in.CompletedPhases, &out.CompletedPhases
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.Addresses != nil {
 		in, out := &in.Addresses, &out.Addresses
 		*out = make([]ClusterAddress, len(*in))
@@ -509,6 +798,11 @@ func (in *ClusterStatus) DeepCopyInto(out *ClusterStatus) {
 		*out = new(MonitoringStatus)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Connectivity != nil {
+		in, out := &in.Connectivity, &out.Connectivity
+		*out = new(ClusterConnectivity)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.RegistryIPs != nil {
 		in, out := &in.RegistryIPs, &out.RegistryIPs
 		*out = make([]string, len(*in))
@@ -526,6 +820,31 @@ func (in *ClusterStatus) DeepCopy() *ClusterStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerRuntime) DeepCopyInto(out *ContainerRuntime) {
+	*out = *in
+	if in.RegistryMirrors != nil {
+		in, out := &in.RegistryMirrors, &out.RegistryMirrors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.InsecureRegistries != nil {
+		in, out := &in.InsecureRegistries, &out.InsecureRegistries
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerRuntime.
+func (in *ContainerRuntime) DeepCopy() *ContainerRuntime {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerRuntime)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CredentialInfo) DeepCopyInto(out *CredentialInfo) {
 	*out = *in
@@ -584,6 +903,13 @@ func (in *CredentialInfo) DeepCopyInto(out *CredentialInfo) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.EncryptionKeys != nil {
+		in, out := &in.EncryptionKeys, &out.EncryptionKeys
+		*out = make([]EncryptionKey, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.ExtData != nil {
 		in, out := &in.ExtData, &out.ExtData
 		*out = make(map[string]string, len(*in))
@@ -647,6 +973,51 @@ func (in *DKEHA) DeepCopy() *DKEHA {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Encryption) DeepCopyInto(out *Encryption) {
+	*out = *in
+	if in.KMS != nil {
+		in, out := &in.KMS, &out.KMS
+		*out = new(KMSPlugin)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Encryption.
+func (in *Encryption) DeepCopy() *Encryption {
+	if in == nil {
+		return nil
+	}
+	out := new(Encryption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EncryptionKey) DeepCopyInto(out *EncryptionKey) {
+	*out = *in
+	if in.Secret != nil {
+		in, out := &in.Secret, &out.Secret
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	if in.KMS != nil {
+		in, out := &in.KMS, &out.KMS
+		*out = new(KMSPlugin)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EncryptionKey.
+func (in *EncryptionKey) DeepCopy() *EncryptionKey {
+	if in == nil {
+		return nil
+	}
+	out := new(EncryptionKey)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Etcd) DeepCopyInto(out *Etcd) {
 	*out = *in
@@ -672,6 +1043,21 @@ func (in *Etcd) DeepCopy() *Etcd {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalCA) DeepCopyInto(out *ExternalCA) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalCA.
+func (in *ExternalCA) DeepCopy() *ExternalCA {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalCA)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ExternalEtcd) DeepCopyInto(out *ExternalEtcd) {
 	*out = *in
@@ -707,6 +1093,21 @@ func (in *File) DeepCopy() *File {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Flannel) DeepCopyInto(out *Flannel) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Flannel.
+func (in *Flannel) DeepCopy() *Flannel {
+	if in == nil {
+		return nil
+	}
+	out := new(Flannel)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HA) DeepCopyInto(out *HA) {
 	*out = *in
@@ -762,33 +1163,240 @@ func (in *HelmChartSpec) DeepCopy() *HelmChartSpec {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LocalEtcd) DeepCopyInto(out *LocalEtcd) {
+func (in *InfraHook) DeepCopyInto(out *InfraHook) {
 	*out = *in
-	if in.ExtraArgs != nil {
-		in, out := &in.ExtraArgs, &out.ExtraArgs
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
-	if in.ServerCertSANs != nil {
-		in, out := &in.ServerCertSANs, &out.ServerCertSANs
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
-	if in.PeerCertSANs != nil {
-		in, out := &in.PeerCertSANs, &out.PeerCertSANs
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalEtcd.
-func (in *LocalEtcd) DeepCopy() *LocalEtcd {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InfraHook.
+func (in *InfraHook) DeepCopy() *InfraHook {
 	if in == nil {
 		return nil
 	}
-	out := new(LocalEtcd)
+	out := new(InfraHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InfraHooks) DeepCopyInto(out *InfraHooks) {
+	*out = *in
+	if in.Pre != nil {
+		in, out := &in.Pre, &out.Pre
+		*out = new(InfraHook)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Post != nil {
+		in, out := &in.Post, &out.Post
+		*out = new(InfraHook)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InfraHooks.
+func (in *InfraHooks) DeepCopy() *InfraHooks {
+	if in == nil {
+		return nil
+	}
+	out := new(InfraHooks)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Infrastructure) DeepCopyInto(out *Infrastructure) {
+	*out = *in
+	if in.VSphere != nil {
+		in, out := &in.VSphere, &out.VSphere
+		*out = new(VSphereInfrastructure)
+		**out = **in
+	}
+	if in.OpenStack != nil {
+		in, out := &in.OpenStack, &out.OpenStack
+		*out = new(OpenStackInfrastructure)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AWS != nil {
+		in, out := &in.AWS, &out.AWS
+		*out = new(AWSInfrastructure)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Libvirt != nil {
+		in, out := &in.Libvirt, &out.Libvirt
+		*out = new(LibvirtInfrastructure)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CAPI != nil {
+		in, out := &in.CAPI, &out.CAPI
+		*out = new(CAPIInfrastructure)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Infrastructure.
+func (in *Infrastructure) DeepCopy() *Infrastructure {
+	if in == nil {
+		return nil
+	}
+	out := new(Infrastructure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KMSPlugin) DeepCopyInto(out *KMSPlugin) {
+	*out = *in
+	if in.CacheSize != nil {
+		in, out := &in.CacheSize, &out.CacheSize
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KMSPlugin.
+func (in *KMSPlugin) DeepCopy() *KMSPlugin {
+	if in == nil {
+		return nil
+	}
+	out := new(KMSPlugin)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubeletConfig) DeepCopyInto(out *KubeletConfig) {
+	*out = *in
+	if in.MaxPods != nil {
+		in, out := &in.MaxPods, &out.MaxPods
+		*out = new(int32)
+		**out = **in
+	}
+	if in.EvictionHard != nil {
+		in, out := &in.EvictionHard, &out.EvictionHard
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.EvictionSoft != nil {
+		in, out := &in.EvictionSoft, &out.EvictionSoft
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.EvictionSoftGracePeriod != nil {
+		in, out := &in.EvictionSoftGracePeriod, &out.EvictionSoftGracePeriod
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.KubeReserved != nil {
+		in, out := &in.KubeReserved, &out.KubeReserved
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SystemReserved != nil {
+		in, out := &in.SystemReserved, &out.SystemReserved
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.FeatureGates != nil {
+		in, out := &in.FeatureGates, &out.FeatureGates
+		*out = make(map[string]bool, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubeletConfig.
+func (in *KubeletConfig) DeepCopy() *KubeletConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KubeletConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LibvirtHost) DeepCopyInto(out *LibvirtHost) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LibvirtHost.
+func (in *LibvirtHost) DeepCopy() *LibvirtHost {
+	if in == nil {
+		return nil
+	}
+	out := new(LibvirtHost)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LibvirtInfrastructure) DeepCopyInto(out *LibvirtInfrastructure) {
+	*out = *in
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]LibvirtHost, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LibvirtInfrastructure.
+func (in *LibvirtInfrastructure) DeepCopy() *LibvirtInfrastructure {
+	if in == nil {
+		return nil
+	}
+	out := new(LibvirtInfrastructure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalEtcd) DeepCopyInto(out *LocalEtcd) {
+	*out = *in
+	if in.ExtraArgs != nil {
+		in, out := &in.ExtraArgs, &out.ExtraArgs
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ServerCertSANs != nil {
+		in, out := &in.ServerCertSANs, &out.ServerCertSANs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PeerCertSANs != nil {
+		in, out := &in.PeerCertSANs, &out.PeerCertSANs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalEtcd.
+func (in *LocalEtcd) DeepCopy() *LocalEtcd {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalEtcd)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -835,11 +1443,38 @@ func (in *MachineAddress) DeepCopy() *MachineAddress {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineArtifacts) DeepCopyInto(out *MachineArtifacts) {
+	*out = *in
+	if in.Cleaned != nil {
+		in, out := &in.Cleaned, &out.Cleaned
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Leftover != nil {
+		in, out := &in.Leftover, &out.Leftover
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.LastCheckTime.DeepCopyInto(&out.LastCheckTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineArtifacts.
+func (in *MachineArtifacts) DeepCopy() *MachineArtifacts {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineArtifacts)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MachineCondition) DeepCopyInto(out *MachineCondition) {
 	*out = *in
 	in.LastProbeTime.DeepCopyInto(&out.LastProbeTime)
 	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	out.Duration = in.Duration
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineCondition.
@@ -916,6 +1551,27 @@ func (in *MachineList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineOSDrift) DeepCopyInto(out *MachineOSDrift) {
+	*out = *in
+	if in.Reasons != nil {
+		in, out := &in.Reasons, &out.Reasons
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.LastCheckTime.DeepCopyInto(&out.LastCheckTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineOSDrift.
+func (in *MachineOSDrift) DeepCopy() *MachineOSDrift {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineOSDrift)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MachineSpec) DeepCopyInto(out *MachineSpec) {
 	*out = *in
@@ -934,6 +1590,11 @@ func (in *MachineSpec) DeepCopyInto(out *MachineSpec) {
 		*out = new(MachineFeature)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Kubelet != nil {
+		in, out := &in.Kubelet, &out.Kubelet
+		*out = new(KubeletConfig)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineSpec.
@@ -961,12 +1622,32 @@ func (in *MachineStatus) DeepCopyInto(out *MachineStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.CompletedPhases != nil {
+		in, out := &in.CompletedPhases, &out.CompletedPhases
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.Addresses != nil {
 		in, out := &in.Addresses, &out.Addresses
 		*out = make([]MachineAddress, len(*in))
 		copy(*out, *in)
 	}
 	out.MachineInfo = in.MachineInfo
+	if in.ProvisionedInfo != nil {
+		in, out := &in.ProvisionedInfo, &out.ProvisionedInfo
+		*out = new(MachineSystemInfo)
+		**out = **in
+	}
+	if in.OSDrift != nil {
+		in, out := &in.OSDrift, &out.OSDrift
+		*out = new(MachineOSDrift)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Artifacts != nil {
+		in, out := &in.Artifacts, &out.Artifacts
+		*out = new(MachineArtifacts)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineStatus.
@@ -994,6 +1675,21 @@ func (in *MachineSystemInfo) DeepCopy() *MachineSystemInfo {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedCluster) DeepCopyInto(out *ManagedCluster) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedCluster.
+func (in *ManagedCluster) DeepCopy() *ManagedCluster {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedCluster)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MonitoringStatus) DeepCopyInto(out *MonitoringStatus) {
 	*out = *in
@@ -1024,6 +1720,209 @@ func (in *MonitoringStatus) DeepCopy() *MonitoringStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkAttachment) DeepCopyInto(out *NetworkAttachment) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkAttachment.
+func (in *NetworkAttachment) DeepCopy() *NetworkAttachment {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkAttachment)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodePlacement) DeepCopyInto(out *NodePlacement) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Taints != nil {
+		in, out := &in.Taints, &out.Taints
+		*out = make([]corev1.Taint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodePlacement.
+func (in *NodePlacement) DeepCopy() *NodePlacement {
+	if in == nil {
+		return nil
+	}
+	out := new(NodePlacement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OIDC) DeepCopyInto(out *OIDC) {
+	*out = *in
+	if in.RequiredClaims != nil {
+		in, out := &in.RequiredClaims, &out.RequiredClaims
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OIDC.
+func (in *OIDC) DeepCopy() *OIDC {
+	if in == nil {
+		return nil
+	}
+	out := new(OIDC)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OSBaseline) DeepCopyInto(out *OSBaseline) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSBaseline.
+func (in *OSBaseline) DeepCopy() *OSBaseline {
+	if in == nil {
+		return nil
+	}
+	out := new(OSBaseline)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OpenStackInfrastructure) DeepCopyInto(out *OpenStackInfrastructure) {
+	*out = *in
+	if in.SecurityGroups != nil {
+		in, out := &in.SecurityGroups, &out.SecurityGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Subnets != nil {
+		in, out := &in.Subnets, &out.Subnets
+		*out = make([]OpenStackSubnet, len(*in))
+		copy(*out, *in)
+	}
+	if in.LoadBalancer != nil {
+		in, out := &in.LoadBalancer, &out.LoadBalancer
+		*out = new(OpenStackLoadBalancer)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OpenStackInfrastructure.
+func (in *OpenStackInfrastructure) DeepCopy() *OpenStackInfrastructure {
+	if in == nil {
+		return nil
+	}
+	out := new(OpenStackInfrastructure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OpenStackLoadBalancer) DeepCopyInto(out *OpenStackLoadBalancer) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OpenStackLoadBalancer.
+func (in *OpenStackLoadBalancer) DeepCopy() *OpenStackLoadBalancer {
+	if in == nil {
+		return nil
+	}
+	out := new(OpenStackLoadBalancer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OpenStackSubnet) DeepCopyInto(out *OpenStackSubnet) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OpenStackSubnet.
+func (in *OpenStackSubnet) DeepCopy() *OpenStackSubnet {
+	if in == nil {
+		return nil
+	}
+	out := new(OpenStackSubnet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodSecurity) DeepCopyInto(out *PodSecurity) {
+	*out = *in
+	if in.ExemptNamespaces != nil {
+		in, out := &in.ExemptNamespaces, &out.ExemptNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodSecurity.
+func (in *PodSecurity) DeepCopy() *PodSecurity {
+	if in == nil {
+		return nil
+	}
+	out := new(PodSecurity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RackBastion) DeepCopyInto(out *RackBastion) {
+	*out = *in
+	if in.Bastions != nil {
+		in, out := &in.Bastions, &out.Bastions
+		*out = make([]SSHBastion, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RackBastion.
+func (in *RackBastion) DeepCopy() *RackBastion {
+	if in == nil {
+		return nil
+	}
+	out := new(RackBastion)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryMirror) DeepCopyInto(out *RegistryMirror) {
+	*out = *in
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryMirror.
+func (in *RegistryMirror) DeepCopy() *RegistryMirror {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryMirror)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in ResourceList) DeepCopyInto(out *ResourceList) {
 	{
@@ -1074,6 +1973,91 @@ func (in *ResourceRequirements) DeepCopy() *ResourceRequirements {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SSHAudit) DeepCopyInto(out *SSHAudit) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SSHAudit.
+func (in *SSHAudit) DeepCopy() *SSHAudit {
+	if in == nil {
+		return nil
+	}
+	out := new(SSHAudit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SSHBastion) DeepCopyInto(out *SSHBastion) {
+	*out = *in
+	if in.PrivateKey != nil {
+		in, out := &in.PrivateKey, &out.PrivateKey
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	if in.PassPhrase != nil {
+		in, out := &in.PassPhrase, &out.PassPhrase
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SSHBastion.
+func (in *SSHBastion) DeepCopy() *SSHBastion {
+	if in == nil {
+		return nil
+	}
+	out := new(SSHBastion)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SSHCredential) DeepCopyInto(out *SSHCredential) {
+	*out = *in
+	if in.PrivateKey != nil {
+		in, out := &in.PrivateKey, &out.PrivateKey
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	if in.PassPhrase != nil {
+		in, out := &in.PassPhrase, &out.PassPhrase
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SSHCredential.
+func (in *SSHCredential) DeepCopy() *SSHCredential {
+	if in == nil {
+		return nil
+	}
+	out := new(SSHCredential)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SSHCredentialRef) DeepCopyInto(out *SSHCredentialRef) {
+	*out = *in
+	if in.Vault != nil {
+		in, out := &in.Vault, &out.Vault
+		*out = new(VaultSecretRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SSHCredentialRef.
+func (in *SSHCredentialRef) DeepCopy() *SSHCredentialRef {
+	if in == nil {
+		return nil
+	}
+	out := new(SSHCredentialRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ThirdPartyHA) DeepCopyInto(out *ThirdPartyHA) {
 	*out = *in
@@ -1088,3 +2072,79 @@ func (in *ThirdPartyHA) DeepCopy() *ThirdPartyHA {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereInfrastructure) DeepCopyInto(out *VSphereInfrastructure) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereInfrastructure.
+func (in *VSphereInfrastructure) DeepCopy() *VSphereInfrastructure {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereInfrastructure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VaultPKI) DeepCopyInto(out *VaultPKI) {
+	*out = *in
+	if in.Mounts != nil {
+		in, out := &in.Mounts, &out.Mounts
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VaultPKI.
+func (in *VaultPKI) DeepCopy() *VaultPKI {
+	if in == nil {
+		return nil
+	}
+	out := new(VaultPKI)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VaultSecretRef) DeepCopyInto(out *VaultSecretRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VaultSecretRef.
+func (in *VaultSecretRef) DeepCopy() *VaultSecretRef {
+	if in == nil {
+		return nil
+	}
+	out := new(VaultSecretRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WaitParam) DeepCopyInto(out *WaitParam) {
+	*out = *in
+	out.Interval = in.Interval
+	out.Timeout = in.Timeout
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WaitParam.
+func (in *WaitParam) DeepCopy() *WaitParam {
+	if in == nil {
+		return nil
+	}
+	out := new(WaitParam)
+	in.DeepCopyInto(out)
+	return out
+}
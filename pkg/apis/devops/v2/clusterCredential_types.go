@@ -0,0 +1,49 @@
+/*
+Copyright 2020 dke.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+
+// ClusterCredential records the credential information needed to access the cluster,
+// the credentials are kept in the spec instead of the top level of the object.
+type ClusterCredential struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec devopsv1.CredentialInfo `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterCredentialList is the whole list of all ClusterCredential which owned by a tenant.
+type ClusterCredentialList struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ListMeta `json:"metadata,omitempty"`
+	// List of clusters
+	Items []ClusterCredential `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterCredential{}, &ClusterCredentialList{})
+}
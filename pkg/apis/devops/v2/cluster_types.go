@@ -0,0 +1,244 @@
+/*
+Copyright 2020 dke.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// HAType is the type of the load balancer in front of the apiservers.
+type HAType string
+
+const (
+	// HADKE is the keepalived vip managed by kunkka on the masters
+	HADKE HAType = "DKE"
+	// HAThirdParty is a load balancer out of the cluster
+	HAThirdParty HAType = "ThirdParty"
+)
+
+// ClusterSpec defines the desired state of the cluster, the fields of v1 are grouped by concern.
+type ClusterSpec struct {
+	// Finalizers is an opaque list of values that must be empty to permanently remove object from storage.
+	// +optional
+	Finalizers []devopsv1.FinalizerName `json:"finalizers,omitempty"`
+	TenantID   string                   `json:"tenantID"`
+	// +optional
+	DisplayName string `json:"displayName,omitempty"`
+	Type        string `json:"type"`
+	Version     string `json:"version"`
+	// +optional
+	Networking Networking `json:"networking,omitempty"`
+	// +optional
+	ControlPlane ControlPlane `json:"controlPlane,omitempty"`
+	// Runtime selects the container runtime of the nodes, docker is used when it is nil.
+	// +optional
+	Runtime *Runtime `json:"runtime,omitempty"`
+	// +optional
+	Kubelet Kubelet `json:"kubelet,omitempty"`
+	// +optional
+	Features Features `json:"features,omitempty"`
+	// +optional
+	Machines []*devopsv1.ClusterMachine `json:"machines,omitempty"`
+	// Bastions are the jump hosts to reach the machines through, the first one is dialed directly.
+	// +optional
+	Bastions []devopsv1.SSHBastion `json:"bastions,omitempty"`
+	// RackBastions override the bastions of the machines in the racks.
+	// +optional
+	RackBastions []devopsv1.RackBastion `json:"rackBastions,omitempty"`
+	// Placements are the labels and taints applied to the first nodes of the cluster,
+	// the labels and taints of the machine itself take precedence.
+	// +optional
+	Placements []devopsv1.NodePlacement `json:"placements,omitempty"`
+	// Waits overrides the wait parameters of the provisioning phases by name, see pkg/timeouts for the names.
+	// +optional
+	Waits map[string]devopsv1.WaitParam `json:"waits,omitempty"`
+	// Registry is the image registry of the cluster the sandbox image is pulled from.
+	// +optional
+	Registry string `json:"registry,omitempty"`
+	// RegistryMirrors is the pull configuration of the registries keyed by host.
+	// +optional
+	RegistryMirrors map[string]devopsv1.RegistryMirror `json:"registryMirrors,omitempty"`
+	// +optional
+	Security Security `json:"security,omitempty"`
+	// Infrastructure provisions the instances of the machines, e.g. the VMs of a vCenter.
+	// +optional
+	Infrastructure *devopsv1.Infrastructure `json:"infrastructure,omitempty"`
+	// Managed attaches the cluster of a cloud, e.g. EKS.
+	// +optional
+	Managed *devopsv1.ManagedCluster `json:"managed,omitempty"`
+	// InfraHooks run an external IaC tool, e.g. terraform, before the machines are provisioned and once the
+	// cluster is created.
+	// +optional
+	InfraHooks *devopsv1.InfraHooks `json:"infraHooks,omitempty"`
+	// Addons are the helm charts installed on the cluster.
+	// +optional
+	Addons []devopsv1.HelmChartSpec `json:"addons,omitempty"`
+	// OversoldRatio of the resources of the nodes by resource name.
+	// +optional
+	OversoldRatio map[string]string `json:"oversoldRatio,omitempty"`
+	// +optional
+	Pause bool `json:"pause,omitempty"`
+}
+
+// Networking holds the network configuration of the cluster.
+type Networking struct {
+	// +optional
+	Type devopsv1.NetworkType `json:"type,omitempty"`
+	// Device is the network device of the nodes. Defaults to "eth0".
+	// +optional
+	Device string `json:"device,omitempty"`
+	// PodCIDR is the CIDR of the pods. Defaults to "10.244.0.0/16".
+	// +optional
+	PodCIDR string `json:"podCIDR,omitempty"`
+	// ServiceCIDR is used to set a separated CIDR for k8s service, it's exclusive with MaxClusterServiceNum.
+	// +optional
+	ServiceCIDR *string `json:"serviceCIDR,omitempty"`
+	// DNSDomain is the dns domain used by k8s services. Defaults to "cluster.local".
+	// +optional
+	DNSDomain string `json:"dnsDomain,omitempty"`
+	// +optional
+	MaxClusterServiceNum *int32 `json:"maxClusterServiceNum,omitempty"`
+	// +optional
+	MaxNodePodNum *int32 `json:"maxNodePodNum,omitempty"`
+	// IPVS is the mode of kube-proxy, iptables is used when it is false. Defaults to true.
+	// +optional
+	IPVS *bool `json:"ipvs,omitempty"`
+	// Flannel is used when the cniInstall hook is flannel.
+	// +optional
+	Flannel *devopsv1.Flannel `json:"flannel,omitempty"`
+	// Multus enables the multus meta cni so pods can request secondary networks.
+	// +optional
+	Multus bool `json:"multus,omitempty"`
+	// Attachments are the secondary networks served by multus.
+	// +optional
+	Attachments []devopsv1.NetworkAttachment `json:"attachments,omitempty"`
+}
+
+// ControlPlane holds the configuration of the masters.
+type ControlPlane struct {
+	// HA is the load balancer of the apiservers.
+	// +optional
+	HA *HA `json:"ha,omitempty"`
+	// +optional
+	PublicAlternativeNames []string `json:"publicAlternativeNames,omitempty"`
+	// +optional
+	PublicLB *bool `json:"publicLB,omitempty"`
+	// +optional
+	InternalLB *bool `json:"internalLB,omitempty"`
+	// Schedulable allows the pods to be scheduled to the masters.
+	// +optional
+	Schedulable bool `json:"schedulable,omitempty"`
+	// +optional
+	APIServerExtraArgs map[string]string `json:"apiServerExtraArgs,omitempty"`
+	// +optional
+	ControllerManagerExtraArgs map[string]string `json:"controllerManagerExtraArgs,omitempty"`
+	// +optional
+	SchedulerExtraArgs map[string]string `json:"schedulerExtraArgs,omitempty"`
+	// Etcd holds configuration for etcd.
+	// +optional
+	Etcd *devopsv1.Etcd `json:"etcd,omitempty"`
+}
+
+// HA is the load balancer of the apiservers, the baremetal clusters use DKE and the hosted ones ThirdParty.
+type HA struct {
+	Type HAType `json:"type"`
+	VIP  string `json:"vip"`
+	// Port is the port of the ThirdParty load balancer. Defaults to 6443.
+	// +optional
+	Port int32 `json:"port,omitempty"`
+}
+
+// Runtime holds the configuration of the container runtime installed on the nodes.
+type Runtime struct {
+	devopsv1.ContainerRuntime `json:",inline"`
+	// ExtraArgs of dockerd.
+	// +optional
+	ExtraArgs map[string]string `json:"extraArgs,omitempty"`
+}
+
+// Kubelet holds the configuration of the kubelets shared by the nodes.
+type Kubelet struct {
+	// +optional
+	ExtraArgs map[string]string `json:"extraArgs,omitempty"`
+}
+
+// Features are the provisioning options of the cluster.
+type Features struct {
+	// +optional
+	SkipConditions []string `json:"skipConditions,omitempty"`
+	// +optional
+	Files []devopsv1.File `json:"files,omitempty"`
+	// +optional
+	Hooks map[devopsv1.HookType]string `json:"hooks,omitempty"`
+	// +optional
+	OSBaseline *devopsv1.OSBaseline `json:"osBaseline,omitempty"`
+}
+
+// Security holds the certificates, the authentication and the hardening of the cluster.
+type Security struct {
+	// ExternalCA signs the cluster certs with an existing CA instead of a generated one.
+	// +optional
+	ExternalCA *devopsv1.ExternalCA `json:"externalCA,omitempty"`
+	// VaultPKI issues the cluster certs by vault instead of the local keys.
+	// +optional
+	VaultPKI *devopsv1.VaultPKI `json:"vaultPKI,omitempty"`
+	// OIDC authenticates the users of the corp SSO natively by the apiserver.
+	// +optional
+	OIDC *devopsv1.OIDC `json:"oidc,omitempty"`
+	// Audit customizes the audit policy of the apiserver and ships the audit logs.
+	// +optional
+	Audit *devopsv1.Audit `json:"audit,omitempty"`
+	// Encryption encrypts the secrets at rest, removing it decrypts them again.
+	// +optional
+	Encryption *devopsv1.Encryption `json:"encryption,omitempty"`
+	// PodSecurity applies the Pod Security Standards to the namespaces but the system ones.
+	// +optional
+	PodSecurity *devopsv1.PodSecurity `json:"podSecurity,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// +kubebuilder:object:root=true
+
+// Cluster is the Schema for the Cluster API
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=vc
+// +kubebuilder:printcolumn:name="DNSIP",type="string",JSONPath=".status.dnsIP",description="The cluster dnsIP."
+// +kubebuilder:printcolumn:name="VERSION",type="string",JSONPath=".status..version",description="The version of kubernetes."
+// +kubebuilder:printcolumn:name="PHASE",type="string",JSONPath=".status.phase",description="The cluter phase."
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp",description="CreationTimestamp is a timestamp representing the server time when this object was created. "
+type Cluster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterSpec            `json:"spec,omitempty"`
+	Status devopsv1.ClusterStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterList contains a list of Cluster
+type ClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Cluster `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Cluster{}, &ClusterList{})
+}
@@ -0,0 +1,246 @@
+package v2
+
+import (
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+var (
+	_ conversion.Convertible = &Cluster{}
+	_ conversion.Convertible = &Machine{}
+	_ conversion.Convertible = &ClusterCredential{}
+)
+
+// ConvertTo converts the cluster to the v1 hub.
+func (in *Cluster) ConvertTo(hub conversion.Hub) error {
+	dst := hub.(*devopsv1.Cluster)
+	dst.ObjectMeta = in.ObjectMeta
+	dst.Status = in.Status
+
+	s, n, cp := &in.Spec, &in.Spec.Networking, &in.Spec.ControlPlane
+	dst.Spec = devopsv1.ClusterSpec{
+		Finalizers:    s.Finalizers,
+		TenantID:      s.TenantID,
+		DisplayName:   s.DisplayName,
+		Type:          s.Type,
+		Version:       s.Version,
+		NetworkType:   n.Type,
+		NetworkDevice: n.Device,
+		ClusterCIDR:   n.PodCIDR,
+		ServiceCIDR:   n.ServiceCIDR,
+		DNSDomain:     n.DNSDomain,
+		Features: devopsv1.ClusterFeature{
+			IPVS:                 n.IPVS,
+			PublicLB:             cp.PublicLB,
+			InternalLB:           cp.InternalLB,
+			EnableMasterSchedule: cp.Schedulable,
+			HA:                   convertHAToV1(cp.HA),
+			SkipConditions:       s.Features.SkipConditions,
+			Files:                s.Features.Files,
+			Hooks:                s.Features.Hooks,
+			OSBaseline:           s.Features.OSBaseline,
+			Multus:               n.Multus,
+		},
+		Properties: devopsv1.ClusterProperty{
+			MaxClusterServiceNum: n.MaxClusterServiceNum,
+			MaxNodePodNum:        n.MaxNodePodNum,
+			OversoldRatio:        s.OversoldRatio,
+		},
+		PublicAlternativeNames:     cp.PublicAlternativeNames,
+		Machines:                   s.Machines,
+		Bastions:                   s.Bastions,
+		RackBastions:               s.RackBastions,
+		KubeletExtraArgs:           s.Kubelet.ExtraArgs,
+		APIServerExtraArgs:         cp.APIServerExtraArgs,
+		ControllerManagerExtraArgs: cp.ControllerManagerExtraArgs,
+		SchedulerExtraArgs:         cp.SchedulerExtraArgs,
+		Flannel:                    n.Flannel,
+		NetworkAttachments:         n.Attachments,
+		Placements:                 s.Placements,
+		Waits:                      s.Waits,
+		Registry:                   s.Registry,
+		RegistryMirrors:            s.RegistryMirrors,
+		ExternalCA:                 s.Security.ExternalCA,
+		VaultPKI:                   s.Security.VaultPKI,
+		OIDC:                       s.Security.OIDC,
+		Audit:                      s.Security.Audit,
+		Encryption:                 s.Security.Encryption,
+		PodSecurity:                s.Security.PodSecurity,
+		Infrastructure:             s.Infrastructure,
+		Managed:                    s.Managed,
+		InfraHooks:                 s.InfraHooks,
+		Etcd:                       cp.Etcd,
+		Pause:                      s.Pause,
+	}
+	if rt := s.Runtime; rt != nil {
+		dst.Spec.DockerExtraArgs = rt.ExtraArgs
+		if !isEmptyRuntime(&rt.ContainerRuntime) {
+			r := rt.ContainerRuntime
+			dst.Spec.ContainerRuntime = &r
+		}
+	}
+	for i := range s.Addons {
+		dst.Spec.Apps = append(dst.Spec.Apps, &s.Addons[i])
+	}
+	return nil
+}
+
+// ConvertFrom converts the v1 hub to the cluster.
+func (in *Cluster) ConvertFrom(hub conversion.Hub) error {
+	src := hub.(*devopsv1.Cluster)
+	in.ObjectMeta = src.ObjectMeta
+	in.Status = src.Status
+
+	s := &src.Spec
+	in.Spec = ClusterSpec{
+		Finalizers:  s.Finalizers,
+		TenantID:    s.TenantID,
+		DisplayName: s.DisplayName,
+		Type:        s.Type,
+		Version:     s.Version,
+		Networking: Networking{
+			Type:                 s.NetworkType,
+			Device:               s.NetworkDevice,
+			PodCIDR:              s.ClusterCIDR,
+			ServiceCIDR:          s.ServiceCIDR,
+			DNSDomain:            s.DNSDomain,
+			MaxClusterServiceNum: s.Properties.MaxClusterServiceNum,
+			MaxNodePodNum:        s.Properties.MaxNodePodNum,
+			IPVS:                 s.Features.IPVS,
+			Flannel:              s.Flannel,
+			Multus:               s.Features.Multus,
+			Attachments:          s.NetworkAttachments,
+		},
+		ControlPlane: ControlPlane{
+			HA:                         convertHAFromV1(s.Features.HA),
+			PublicAlternativeNames:     s.PublicAlternativeNames,
+			PublicLB:                   s.Features.PublicLB,
+			InternalLB:                 s.Features.InternalLB,
+			Schedulable:                s.Features.EnableMasterSchedule,
+			APIServerExtraArgs:         s.APIServerExtraArgs,
+			ControllerManagerExtraArgs: s.ControllerManagerExtraArgs,
+			SchedulerExtraArgs:         s.SchedulerExtraArgs,
+			Etcd:                       s.Etcd,
+		},
+		Kubelet: Kubelet{ExtraArgs: s.KubeletExtraArgs},
+		Features: Features{
+			SkipConditions: s.Features.SkipConditions,
+			Files:          s.Features.Files,
+			Hooks:          s.Features.Hooks,
+			OSBaseline:     s.Features.OSBaseline,
+		},
+		Machines:        s.Machines,
+		Bastions:        s.Bastions,
+		RackBastions:    s.RackBastions,
+		Placements:      s.Placements,
+		Waits:           s.Waits,
+		Registry:        s.Registry,
+		RegistryMirrors: s.RegistryMirrors,
+		Security: Security{
+			ExternalCA:  s.ExternalCA,
+			VaultPKI:    s.VaultPKI,
+			OIDC:        s.OIDC,
+			Audit:       s.Audit,
+			Encryption:  s.Encryption,
+			PodSecurity: s.PodSecurity,
+		},
+		Infrastructure: s.Infrastructure,
+		Managed:        s.Managed,
+		InfraHooks:     s.InfraHooks,
+		OversoldRatio:  s.Properties.OversoldRatio,
+		Pause:          s.Pause,
+	}
+	if s.ContainerRuntime != nil || s.DockerExtraArgs != nil {
+		in.Spec.Runtime = &Runtime{ExtraArgs: s.DockerExtraArgs}
+		if s.ContainerRuntime != nil {
+			in.Spec.Runtime.ContainerRuntime = *s.ContainerRuntime
+		}
+	}
+	for _, app := range s.Apps {
+		if app != nil {
+			in.Spec.Addons = append(in.Spec.Addons, *app)
+		}
+	}
+	return nil
+}
+
+// convertHAToV1 returns the v1 HA of the type of the load balancer.
+func convertHAToV1(ha *HA) *devopsv1.HA {
+	if ha == nil {
+		return nil
+	}
+	if ha.Type == HAThirdParty {
+		return &devopsv1.HA{ThirdPartyHA: &devopsv1.ThirdPartyHA{VIP: ha.VIP, VPort: ha.Port}}
+	}
+	return &devopsv1.HA{DKEHA: &devopsv1.DKEHA{VIP: ha.VIP}}
+}
+
+// convertHAFromV1 returns the load balancer of the v1 HA, the DKE one takes precedence if both are set.
+func convertHAFromV1(ha *devopsv1.HA) *HA {
+	switch {
+	case ha == nil:
+		return nil
+	case ha.DKEHA != nil:
+		return &HA{Type: HADKE, VIP: ha.DKEHA.VIP}
+	case ha.ThirdPartyHA != nil:
+		return &HA{Type: HAThirdParty, VIP: ha.ThirdPartyHA.VIP, Port: ha.ThirdPartyHA.VPort}
+	}
+	return nil
+}
+
+func isEmptyRuntime(rt *devopsv1.ContainerRuntime) bool {
+	return rt.Type == "" && rt.Version == "" && rt.SandboxImage == "" &&
+		len(rt.RegistryMirrors) == 0 && len(rt.InsecureRegistries) == 0
+}
+
+// ConvertTo converts the machine to the v1 hub.
+func (in *Machine) ConvertTo(hub conversion.Hub) error {
+	dst := hub.(*devopsv1.Machine)
+	dst.ObjectMeta = in.ObjectMeta
+	dst.Status = in.Status
+	dst.Spec = devopsv1.MachineSpec{
+		Finalizers:  in.Spec.Finalizers,
+		TenantID:    in.Spec.TenantID,
+		ClusterName: in.Spec.ClusterName,
+		Type:        in.Spec.Type,
+		Machine:     in.Spec.Host,
+		Feature:     in.Spec.Features,
+		Pause:       in.Spec.Pause,
+		Kubelet:     in.Spec.Kubelet,
+	}
+	return nil
+}
+
+// ConvertFrom converts the v1 hub to the machine.
+func (in *Machine) ConvertFrom(hub conversion.Hub) error {
+	src := hub.(*devopsv1.Machine)
+	in.ObjectMeta = src.ObjectMeta
+	in.Status = src.Status
+	in.Spec = MachineSpec{
+		Finalizers:  src.Spec.Finalizers,
+		TenantID:    src.Spec.TenantID,
+		ClusterName: src.Spec.ClusterName,
+		Type:        src.Spec.Type,
+		Host:        src.Spec.Machine,
+		Features:    src.Spec.Feature,
+		Kubelet:     src.Spec.Kubelet,
+		Pause:       src.Spec.Pause,
+	}
+	return nil
+}
+
+// ConvertTo converts the credential to the v1 hub.
+func (in *ClusterCredential) ConvertTo(hub conversion.Hub) error {
+	dst := hub.(*devopsv1.ClusterCredential)
+	dst.ObjectMeta = in.ObjectMeta
+	dst.CredentialInfo = in.Spec
+	return nil
+}
+
+// ConvertFrom converts the v1 hub to the credential.
+func (in *ClusterCredential) ConvertFrom(hub conversion.Hub) error {
+	src := hub.(*devopsv1.ClusterCredential)
+	in.ObjectMeta = src.ObjectMeta
+	in.Spec = src.CredentialInfo
+	return nil
+}
@@ -0,0 +1,112 @@
+package v2
+
+import (
+	"reflect"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/util/pointer"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestClusterConversion(t *testing.T) {
+	meta := metav1.ObjectMeta{Name: "c1", Namespace: "c1", Annotations: map[string]string{"a": "b"}}
+	tests := []struct {
+		name    string
+		cluster *devopsv1.Cluster
+	}{
+		{
+			name:    "minimal",
+			cluster: &devopsv1.Cluster{ObjectMeta: meta, Spec: devopsv1.ClusterSpec{TenantID: "t", Type: "Baremetal", Version: "v1.18.5"}},
+		},
+		{
+			name: "dke",
+			cluster: &devopsv1.Cluster{
+				ObjectMeta: meta,
+				Spec: devopsv1.ClusterSpec{
+					Type:          "Baremetal",
+					NetworkDevice: "eth1",
+					ClusterCIDR:   "10.10.0.0/16",
+					ServiceCIDR:   pointer.ToString("10.96.0.0/16"),
+					DNSDomain:     "cluster.local",
+					Features: devopsv1.ClusterFeature{
+						IPVS:                 pointer.ToBool(true),
+						EnableMasterSchedule: true,
+						HA:                   &devopsv1.HA{DKEHA: &devopsv1.DKEHA{VIP: "10.0.0.100"}},
+						SkipConditions:       []string{"EnsureSystem"},
+						Multus:               true,
+					},
+					Properties:         devopsv1.ClusterProperty{MaxNodePodNum: pointer.ToInt32(64), OversoldRatio: map[string]string{"cpu": "2"}},
+					Machines:           []*devopsv1.ClusterMachine{{IP: "10.0.0.1", Port: 22, Username: "root"}},
+					DockerExtraArgs:    map[string]string{"log-level": "warn"},
+					APIServerExtraArgs: map[string]string{"v": "2"},
+					Etcd:               &devopsv1.Etcd{Local: &devopsv1.LocalEtcd{}},
+					Registry:           "registry.example.com/kunkka",
+					Apps:               []*devopsv1.HelmChartSpec{{Name: "metrics-server"}},
+					Infrastructure: &devopsv1.Infrastructure{
+						SecretName: "vcenter",
+						VSphere:    &devopsv1.VSphereInfrastructure{Server: "https://vcenter.example.com", Template: "kunkka/centos7"},
+					},
+					InfraHooks: &devopsv1.InfraHooks{
+						Pre: &devopsv1.InfraHook{Image: "hashicorp/terraform:0.13.5", Command: []string{"terraform", "apply"}, MachineIPsOutput: "master_ips"},
+					},
+					Pause: true,
+				},
+				Status: devopsv1.ClusterStatus{Phase: devopsv1.ClusterRunning},
+			},
+		},
+		{
+			name: "third party",
+			cluster: &devopsv1.Cluster{
+				ObjectMeta: meta,
+				Spec: devopsv1.ClusterSpec{
+					Type:             "Hosted",
+					Features:         devopsv1.ClusterFeature{HA: &devopsv1.HA{ThirdPartyHA: &devopsv1.ThirdPartyHA{VIP: "10.0.0.100", VPort: 8443}}},
+					ContainerRuntime: &devopsv1.ContainerRuntime{Type: devopsv1.ContainerRuntimeContainerd, SandboxImage: "pause:3.2"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v2 := &Cluster{}
+			if err := v2.ConvertFrom(tt.cluster.DeepCopy()); err != nil {
+				t.Fatalf("ConvertFrom() error: %v", err)
+			}
+			got := &devopsv1.Cluster{}
+			if err := v2.ConvertTo(got); err != nil {
+				t.Fatalf("ConvertTo() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.cluster) {
+				t.Errorf("round trip = %+v, want %+v", got.Spec, tt.cluster.Spec)
+			}
+		})
+	}
+}
+
+func TestMachineConversion(t *testing.T) {
+	machine := &devopsv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "10.0.0.11", Namespace: "c1"},
+		Spec: devopsv1.MachineSpec{
+			ClusterName: "c1",
+			Type:        "Baremetal",
+			Machine:     &devopsv1.ClusterMachine{IP: "10.0.0.11", Port: 22, Username: "root"},
+			Feature:     &devopsv1.MachineFeature{SkipConditions: []string{"EnsureSystem"}},
+		},
+		Status: devopsv1.MachineStatus{Phase: devopsv1.MachineRunning},
+	}
+	v2 := &Machine{}
+	if err := v2.ConvertFrom(machine.DeepCopy()); err != nil {
+		t.Fatalf("ConvertFrom() error: %v", err)
+	}
+	if v2.Spec.Host == nil || v2.Spec.Host.IP != "10.0.0.11" {
+		t.Errorf("ConvertFrom() host = %+v, want 10.0.0.11", v2.Spec.Host)
+	}
+	got := &devopsv1.Machine{}
+	if err := v2.ConvertTo(got); err != nil {
+		t.Fatalf("ConvertTo() error: %v", err)
+	}
+	if !reflect.DeepEqual(got, machine) {
+		t.Errorf("round trip = %+v, want %+v", got, machine)
+	}
+}
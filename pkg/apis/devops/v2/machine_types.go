@@ -0,0 +1,70 @@
+/*
+Copyright 2020 dke.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MachineSpec is a description of machine.
+type MachineSpec struct {
+	// Finalizers is an opaque list of values that must be empty to permanently remove object from storage.
+	// +optional
+	Finalizers  []devopsv1.FinalizerName `json:"finalizers,omitempty"`
+	TenantID    string                   `json:"tenantID,omitempty"`
+	ClusterName string                   `json:"clusterName"`
+	Type        string                   `json:"type"`
+	// Host is the machine joined to the cluster and how to reach it by ssh.
+	Host *devopsv1.ClusterMachine `json:"host,omitempty"`
+	// +optional
+	Features *devopsv1.MachineFeature `json:"features,omitempty"`
+	// +optional
+	Kubelet *devopsv1.KubeletConfig `json:"kubelet,omitempty"`
+	// +optional
+	Pause bool `json:"pause,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// +kubebuilder:object:root=true
+
+// Machine is the Schema for the Machine API
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=mc
+// +kubebuilder:printcolumn:name="PHASE",type="string",JSONPath=".status.phase",description="The cluter phase."
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp",description="CreationTimestamp is a timestamp representing the server time when this object was created. "
+type Machine struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MachineSpec            `json:"spec,omitempty"`
+	Status devopsv1.MachineStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// MachineList contains a list of Machine
+type MachineList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Machine `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Machine{}, &MachineList{})
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2020 dke.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package certexpiry reads the certificates kept in the ClusterCredential of each cluster,
+// i.e. the ca, the component certs and the client certs embedded in the kubeconfigs.
+package certexpiry
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"sort"
+	"strings"
+	"time"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/credential"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Cert is the summary of a certificate of the cluster.
+type Cert struct {
+	// Name is the file or field of the credential the certificate is read from, e.g. apiserver.crt, admin.conf/kubernetes-admin.
+	Name      string    `json:"name"`
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	SANs      []string  `json:"sans,omitempty"`
+	IsCA      bool      `json:"isCA"`
+	NotBefore time.Time `json:"notBefore"`
+	NotAfter  time.Time `json:"notAfter"`
+
+	raw string
+}
+
+// ExpiresIn returns the duration until the certificate expires, negative when expired.
+func (c *Cert) ExpiresIn(now time.Time) time.Duration {
+	return c.NotAfter.Sub(now)
+}
+
+// ClusterName returns the name of the cluster the credential belongs to.
+func ClusterName(cred *devopsv1.ClusterCredential) string {
+	if cred.CredentialInfo.ClusterName != "" {
+		return cred.CredentialInfo.ClusterName
+	}
+	return cred.Name
+}
+
+// FromCredential returns the certificates of the credential sorted by name, the undecodable data is skipped.
+// A certificate kept in several places is reported once, under the file name of CertsBinaryData if any.
+func FromCredential(cred *devopsv1.ClusterCredential) []*Cert {
+	certs := map[string]*Cert{}
+	seen := map[string]bool{}
+	add := func(name string, data []byte) {
+		for i, cert := range parse(data) {
+			if seen[cert.raw] {
+				continue
+			}
+			seen[cert.raw] = true
+
+			cert.Name = name
+			if i > 0 {
+				// bundles hold more than one certificate
+				cert.Name = name + "/" + cert.Subject
+			}
+			certs[cert.Name] = cert
+		}
+	}
+
+	names := make([]string, 0, len(cred.CertsBinaryData))
+	for name := range cred.CertsBinaryData {
+		if strings.HasSuffix(name, ".crt") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		add(name, cred.CertsBinaryData[name])
+	}
+	add("ca.crt", cred.CACert)
+	add("client.crt", cred.ClientCert)
+	add("etcd/ca.crt", cred.ETCDCACert)
+	add("apiserver-etcd-client.crt", cred.ETCDAPIClientCert)
+
+	for _, kubeconfigs := range []map[string]string{cred.KubeData, cred.ExtData} {
+		names := make([]string, 0, len(kubeconfigs))
+		for name := range kubeconfigs {
+			if strings.HasSuffix(name, ".conf") || strings.HasSuffix(name, "kubeconfig") {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			cfg, err := clientcmd.Load([]byte(kubeconfigs[name]))
+			if err != nil {
+				klog.V(4).Infof("cluster: %s parse kubeconfig: %s err: %v", ClusterName(cred), name, err)
+				continue
+			}
+			for user, auth := range cfg.AuthInfos {
+				add(name+"/"+user, auth.ClientCertificateData)
+			}
+		}
+	}
+
+	result := make([]*Cert, 0, len(certs))
+	for _, cert := range certs {
+		result = append(result, cert)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func parse(data []byte) []*Cert {
+	var result []*Cert
+	for len(data) > 0 {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+
+		sans := append([]string{}, cert.DNSNames...)
+		for _, ip := range cert.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+		result = append(result, &Cert{
+			Subject:   cert.Subject.String(),
+			Issuer:    cert.Issuer.String(),
+			SANs:      sans,
+			IsCA:      cert.IsCA,
+			NotBefore: cert.NotBefore,
+			NotAfter:  cert.NotAfter,
+			raw:       string(block.Bytes),
+		})
+	}
+	return result
+}
+
+var expiryDesc = prometheus.NewDesc(
+	"kunkka_cluster_cert_expiry_seconds",
+	"Seconds until the certificate of the cluster expires, negative when expired.",
+	[]string{"cluster", "name", "subject"}, nil,
+)
+
+// Collector exports the expiry of the certificates of all the clusters, the credentials are read on scrape.
+type Collector struct {
+	Client client.Client
+}
+
+// NewCollector returns the collector reading the credentials with the client of the meta cluster.
+func NewCollector(cli client.Client) *Collector {
+	return &Collector{Client: cli}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- expiryDesc
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	creds := &devopsv1.ClusterCredentialList{}
+	err := c.Client.List(context.Background(), creds)
+	if err != nil {
+		klog.Errorf("list cluster credential error: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for i := range creds.Items {
+		cluster := ClusterName(&creds.Items[i])
+		if err := credential.Load(context.Background(), c.Client, &creds.Items[i]); err != nil {
+			klog.Errorf("cluster: %s load credential error: %v", cluster, err)
+			continue
+		}
+		for _, cert := range FromCredential(&creds.Items[i]) {
+			ch <- prometheus.MustNewConstMetric(expiryDesc, prometheus.GaugeValue,
+				cert.ExpiresIn(now).Seconds(), cluster, cert.Name, cert.Subject)
+		}
+	}
+}
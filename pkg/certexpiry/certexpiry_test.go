@@ -0,0 +1,51 @@
+package certexpiry
+
+import (
+	"crypto/x509"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/util/pkiutil"
+	certutil "k8s.io/client-go/util/cert"
+)
+
+func TestFromCredential(t *testing.T) {
+	ca, caKey, err := pkiutil.NewCertificateAuthority(&pkiutil.CertConfig{Config: certutil.Config{CommonName: "kubernetes"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _, err := pkiutil.NewCertAndKey(ca, caKey, &pkiutil.CertConfig{
+		Config: certutil.Config{
+			CommonName: "kube-apiserver",
+			AltNames:   certutil.AltNames{DNSNames: []string{"kubernetes.default"}},
+			Usages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caPEM := pkiutil.EncodeCertPEM(ca)
+	cred := &devopsv1.ClusterCredential{
+		CredentialInfo: devopsv1.CredentialInfo{
+			ClusterName: "c1",
+			CACert:      caPEM,
+			CertsBinaryData: map[string][]byte{
+				"/etc/kubernetes/pki/ca.crt":        caPEM,
+				"/etc/kubernetes/pki/ca.key":        []byte("key"),
+				"/etc/kubernetes/pki/apiserver.crt": pkiutil.EncodeCertPEM(cert),
+			},
+		},
+	}
+
+	certs := FromCredential(cred)
+	if len(certs) != 2 {
+		t.Fatalf("FromCredential() got %d certs, want 2 without the duplicated ca", len(certs))
+	}
+	if certs[0].Name != "/etc/kubernetes/pki/apiserver.crt" || len(certs[0].SANs) != 1 || certs[0].IsCA {
+		t.Errorf("FromCredential() apiserver cert = %+v", certs[0])
+	}
+	if certs[1].Name != "/etc/kubernetes/pki/ca.crt" || !certs[1].IsCA {
+		t.Errorf("FromCredential() ca cert = %+v", certs[1])
+	}
+}
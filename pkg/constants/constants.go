@@ -14,6 +14,8 @@ const (
 	SchedulerPolicyConfigFile = KubernetesDir + "scheduler-policy-config.json"
 	AuditWebhookConfigFile    = KubernetesDir + "audit-api-client-config.yaml"
 	AuditPolicyConfigFile     = KubernetesDir + "audit-policy.yaml"
+	AuditLogFile              = "/var/log/kubernetes/audit.log"
+	EncryptionConfigFile      = KubernetesDir + "encryption-config.yaml"
 
 	EtcdPodManifestFile                  = KubeletPodManifestDir + "etcd.yaml"
 	KubeAPIServerPodManifestFile         = KubeletPodManifestDir + "kube-apiserver.yaml"
@@ -39,6 +41,8 @@ const (
 	CACertName = CertificatesDir + "ca.crt"
 	// CAKeyName defines certificate name
 	CAKeyName = CertificatesDir + "ca.key"
+	// OIDCCACertName defines the CA of the OIDC issuer
+	OIDCCACertName = CertificatesDir + "oidc-ca.crt"
 	// APIServerCertName defines API's server certificate name
 	APIServerCertName = CertificatesDir + "apiserver.crt"
 	// APIServerKeyName defines API's server key name
@@ -62,14 +66,17 @@ const (
 
 	// KubeletKubeConfigFileName defines the file name for the kubeconfig that the control-plane kubelet will use for talking
 	// to the API server
-	KubeletKubeConfigFileName    = KubernetesDir + "kubelet.conf"
-	KubeletRunDirectory          = "/var/lib/kubelet/"
-	DefaultSystemdUnitFilePath   = "/usr/lib/systemd/system/"
-	KubeletSystemdUnitFilePath   = DefaultSystemdUnitFilePath + "kubelet.service"
-	KubeletServiceRunConfig      = DefaultSystemdUnitFilePath + "kubelet.service.d/10-kubeadm.conf"
-	KubeletConfigurationFileName = KubeletRunDirectory + "config.yaml"
-	KubeletEnvFileName           = KubeletRunDirectory + "kubeadm-flags.env"
-	KubeletEnvFileVariableName   = "KUBELET_KUBEADM_ARGS"
+	KubeletKubeConfigFileName = KubernetesDir + "kubelet.conf"
+	// KubeletBootstrapKubeConfigFileName defines the file name for the kubeconfig with the bootstrap token
+	// the kubelet requests its client certificate with
+	KubeletBootstrapKubeConfigFileName = KubernetesDir + "bootstrap-kubelet.conf"
+	KubeletRunDirectory                = "/var/lib/kubelet/"
+	DefaultSystemdUnitFilePath         = "/usr/lib/systemd/system/"
+	KubeletSystemdUnitFilePath         = DefaultSystemdUnitFilePath + "kubelet.service"
+	KubeletServiceRunConfig            = DefaultSystemdUnitFilePath + "kubelet.service.d/10-kubeadm.conf"
+	KubeletConfigurationFileName       = KubeletRunDirectory + "config.yaml"
+	KubeletEnvFileName                 = KubeletRunDirectory + "kubeadm-flags.env"
+	KubeletEnvFileVariableName         = "KUBELET_KUBEADM_ARGS"
 
 	// LabelNodeRoleMaster specifies that a node is a control-plane
 	// This is a duplicate definition of the constant in pkg/controller/service/service_controller.go
@@ -80,6 +87,11 @@ const (
 	// RenewCertsTimeThreshold control how long time left to renew certs
 	RenewCertsTimeThreshold = 30 * 24 * time.Hour
 
+	// OSDriftCheckInterval control how often the os/kernel of a machine is checked
+	OSDriftCheckInterval = 30 * time.Minute
+	// ArtifactsCheckInterval control how often the provisioning artifacts left on a machine are cleaned
+	ArtifactsCheckInterval = time.Hour
+
 	FlannelDirFile    = KubernetesDir + "flannel.yaml"
 	CustomDir         = "/opt/k8s/"
 	SystemInitFile    = CustomDir + "init.sh"
@@ -91,6 +103,23 @@ const (
 const (
 	// DefaultDockerCRISocket defines the default Docker CRI socket
 	DefaultDockerCRISocket = "/var/run/dockershim.sock"
+	// ContainerdCRISocket defines the containerd CRI socket
+	ContainerdCRISocket = "/run/containerd/containerd.sock"
+	// CRIOCRISocket defines the cri-o CRI socket
+	CRIOCRISocket = "/var/run/crio/crio.sock"
+
+	// ContainerdConfigFile the config file of containerd
+	ContainerdConfigFile = "/etc/containerd/config.toml"
+	// ContainerdCertsDir the hosts.toml dir of containerd, owned by kunkka
+	ContainerdCertsDir = "/etc/containerd/certs.d"
+	// DockerDaemonFile the config file of docker
+	DockerDaemonFile = "/etc/docker/daemon.json"
+	// DockerCertsDir the ca dir of the registries for docker
+	DockerCertsDir = "/etc/docker/certs.d"
+	// CrioRegistriesFile the registries config of cri-o
+	CrioRegistriesFile = "/etc/containers/registries.conf"
+	// CrioCertsDir the ca dir of the registries for cri-o
+	CrioCertsDir = "/etc/containers/certs.d"
 
 	// PauseVersion indicates the default pause image version for kubeadm
 	PauseVersion = "3.2"
@@ -134,6 +163,10 @@ const (
 	IngressControllerNamespace    = KubeSphereControlNamespace
 	AdminUserName                 = "admin"
 	IngressControllerPrefix       = "kubesphere-router-"
+	// EscrowNamespace holds the escrowed cluster credentials and the break-glass requests
+	EscrowNamespace = "kunkka-escrow"
+	// StorageNamespace holds the ConfigMaps of the configmap storage backend
+	StorageNamespace = "kunkka-storage"
 )
 
 var (
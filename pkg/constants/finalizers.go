@@ -3,6 +3,8 @@ package constants
 const (
 	FinalizersCluster = "finalizers.k8s.io/cluster"
 	FinalizersMachine = "finalizers.k8s.io/machine"
+
+	FinalizersImagePullSecret = "finalizers.k8s.io/image-pull-secret"
 )
 
 func ContainsString(slice []string, s string) bool {
@@ -11,15 +11,138 @@ const (
 	KubeApiServerCerts    = "kube-apiserver-certs"
 	KubeApiServerConfig   = "kube-apiserver-config"
 	KubeApiServerAudit    = "kube-apiserver-audit"
+	KubeApiServerKMS      = "kube-apiserver-kms"
 	KubeMasterManifests   = "kube-master-manifests"
 )
 
+const (
+	// AddonLabel marks the objects of the addons created in the member clusters, value: the addon name, the
+	// objects are collected when the addon is disabled or the cluster is deleted
+	AddonLabel = "k8s.io/addon"
+)
+
+const (
+	// NodePlacementLabel the name of the cluster placement the node is dedicated to
+	NodePlacementLabel = "k8s.io/placement"
+)
+
+const (
+	// ShardLabel on the Clusters assigns them to the shard of the index instead of the hash of their names
+	ShardLabel = "k8s.io/shard"
+)
+
 const (
 	ClusterAnnotationAction  = "k8s.io/action"
 	ClusterPhaseRestore      = "k8s.io/phaseRestore"
 	ClusterApiSvcType        = "k8s.io/apiSvcType"
 	ClusterApiSvcVip         = "k8s.io/apiSvcVip"
 	ClusterAnnoLocalDebugDir = "k8s.io/localDebugDir"
+
+	// ClusterStrictReconcile "true" fails the handlers which can't reach the cluster instead of skipping them,
+	// set on the new clusters by default.
+	ClusterStrictReconcile = "k8s.io/strict-reconcile"
+
+	// ClusterKubeconfigGeneration the generation of the external admin kubeconfig, bumped to regenerate it
+	ClusterKubeconfigGeneration = "k8s.io/kubeconfig-generation"
+	// ClusterEncryptionKeyGeneration the generation of the aescbc key encrypting the secrets, bumped to rotate it
+	ClusterEncryptionKeyGeneration = "k8s.io/encryption-key-generation"
+	// ForceDeletion "true" on the Clusters and the Machines deleted by force, the nodes which can't be
+	// cleaned, e.g. unreachable, are skipped instead of blocking the finalizers
+	ForceDeletion = "k8s.io/force-deletion"
+	// PhaseRetry on the Clusters and the Machines resets the attempts of their failed conditions, the failed
+	// ones are initialized again, it's removed once applied
+	PhaseRetry = "k8s.io/phaseRetry"
+	// RerunPhases on the provisioning Clusters and Machines lists the create phases run again though checkpointed,
+	// separated by comma, e.g. EnsureSystem. It's removed once applied
+	RerunPhases = "k8s.io/rerun-phases"
+	// MachineParallelism on the Clusters overrides the number of their Machines provisioned at a time
+	MachineParallelism = "k8s.io/machine-parallelism"
+	// TraceParent on the Clusters and the Machines is the w3c traceparent of the api request creating them,
+	// the spans of their provisioning phases are children of it
+	TraceParent = "k8s.io/traceparent"
+)
+
+const (
+	// RegistryMirrorsChecksum the sha256 of the registry config applied to the machine
+	RegistryMirrorsChecksum = "k8s.io/registry-mirrors-checksum"
+)
+
+const (
+	// GatekeeperBaselineLabel marks ConfigMaps on the meta cluster which hold the baseline
+	// ConstraintTemplates/Constraints synced to every member cluster with gatekeeper enabled.
+	GatekeeperBaselineLabel = "k8s.io/gatekeeper-baseline"
+)
+
+const (
+	// MachineMaintenance requests the maintenance of the node of the Machine, value: the reason, e.g. os-drift.
+	// The machine controller cordons the node while it's requested and uncordons it once removed.
+	MachineMaintenance = "k8s.io/maintenance"
+	// MaintenanceOSDrift the reason of the maintenance requested for the enforced os baseline
+	MaintenanceOSDrift = "os-drift"
+	// NodeMaintenanceCordoned marks the nodes cordoned for the maintenance requests, the nodes cordoned by
+	// the admins are left cordoned when the requests are removed
+	NodeMaintenanceCordoned = "k8s.io/maintenance-cordoned"
+)
+
+const (
+	// ImagePullSecretLabel marks dockerconfigjson Secrets on the meta cluster which are distributed to member clusters.
+	ImagePullSecretLabel = "k8s.io/image-pull-secret"
+	// ImagePullSecretNamespaces the comma separated namespaces to distribute to, default: default
+	ImagePullSecretNamespaces = "k8s.io/image-pull-secret-namespaces"
+	// ImagePullSecretClusters the comma separated clusters to distribute to, default: all clusters
+	ImagePullSecretClusters = "k8s.io/image-pull-secret-clusters"
+	// ImagePullSecretSource marks the copies in member clusters, value: namespace.name of the source Secret
+	ImagePullSecretSource = "k8s.io/image-pull-secret-source"
+	// ImagePullSecretApplied the comma separated cluster/namespace targets the source Secret was distributed to,
+	// the copies of the targets which are no longer selected are removed
+	ImagePullSecretApplied = "k8s.io/image-pull-secret-applied"
+)
+
+const (
+	// CredentialEscrowLabel marks the Secrets holding the escrowed admin credentials of the clusters.
+	CredentialEscrowLabel = "k8s.io/credential-escrow"
+	// CredentialEscrowCluster the cluster of the escrowed credential
+	CredentialEscrowCluster = "k8s.io/credential-escrow-cluster"
+	// CredentialEscrowChecksum the sha256 of the escrowed credential, used to detect the rotation
+	CredentialEscrowChecksum = "k8s.io/credential-escrow-checksum"
+	// CredentialEscrowKeyID the fingerprint of the public key the credential is sealed to
+	CredentialEscrowKeyID = "k8s.io/credential-escrow-key-id"
+	// BreakGlassLabel marks the ConfigMaps recording the break-glass requests.
+	BreakGlassLabel = "k8s.io/break-glass"
+	// ExpansionRequestLabel marks the ConfigMaps recording the expansion requests, the value is the cluster.
+	ExpansionRequestLabel = "k8s.io/expansion-request"
+	// CredentialChecksum the sha256 of the credential kept in the Secret, the Secret is not rewritten if unchanged
+	CredentialChecksum = "k8s.io/credential-checksum"
+	// CredentialKeyID the fingerprint of the key the credential Secret is encrypted with
+	CredentialKeyID = "k8s.io/credential-key-id"
+	// StorageKindLabel marks the ConfigMaps of the configmap storage backend, the value is the kind of the object.
+	StorageKindLabel = "k8s.io/storage-kind"
+	// StorageKeyAnnotation the key of the object kept in the ConfigMap
+	StorageKeyAnnotation = "k8s.io/storage-key"
+	// PodSecurityManaged marks the namespaces whose pod security labels are set by the cluster spec.
+	PodSecurityManaged = "k8s.io/pod-security-managed"
+	// AuditChecksum the sha256 of the audit policy and webhook of the hosted apiserver pods, they're restarted once changed
+	AuditChecksum = "k8s.io/audit-checksum"
+	// EncryptionChecksum the sha256 of the encryption config of the hosted apiserver pods, they're restarted once changed
+	EncryptionChecksum = "k8s.io/encryption-checksum"
+	// APITokenLabel marks the Secrets holding the hashed api tokens of the automation clients.
+	APITokenLabel = "k8s.io/api-token"
+	// NamespaceManagedLabel marks the namespaces of the member clusters created by the cross cluster namespace api.
+	NamespaceManagedLabel = "k8s.io/namespace-managed"
+)
+
+const (
+	// ClusterTrendsLabel marks the ConfigMaps holding the node count and phase samples, value: the cluster name
+	ClusterTrendsLabel = "k8s.io/cluster-trends"
+	// ClusterSSHAuditLabel marks the ConfigMaps holding the ssh commands run on the machines, value: the cluster name
+	ClusterSSHAuditLabel = "k8s.io/cluster-ssh-audit"
+	// ClusterHostKeysLabel marks the ConfigMaps holding the pinned ssh host keys of the machines, value: the cluster name
+	ClusterHostKeysLabel = "k8s.io/cluster-host-keys"
+)
+
+const (
+	// ClusterRoleLabel the role of the cluster, value: meta or member
+	ClusterRoleLabel = "cluster-role.kunkka.io/cluster-role"
 )
 
 var KubeApiServerLabels = map[string]string{
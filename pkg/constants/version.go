@@ -5,8 +5,16 @@ import (
 	"k8s.io/klog"
 )
 
+const (
+	// ArchAMD64 and ArchARM64 are the architectures of the nodes named as GOARCH and the kubernetes.io/arch label
+	ArchAMD64 = "amd64"
+	ArchARM64 = "arm64"
+)
+
 var (
-	OSs              = []string{"linux"}
+	OSs = []string{"linux"}
+	// Archs the architectures of the nodes, the addons of the single-arch images are rendered for each of them
+	Archs            = []string{ArchAMD64, ArchARM64}
 	K8sVersions      = []string{"v1.16.14", "v1.18.5"}
 	K8sVersionsWithV = funk.Map(K8sVersions, func(s string) string {
 		return "v" + s
@@ -25,3 +33,32 @@ func IsK8sSupport(version string) bool {
 	klog.Errorf("k8s version only support: %#v", K8sVersions)
 	return false
 }
+
+// ArchOf returns the architecture of the machine hardware name, i.e. the output of "uname -m".
+func ArchOf(machine string) (string, bool) {
+	switch machine {
+	case "x86_64", ArchAMD64:
+		return ArchAMD64, true
+	case "aarch64", ArchARM64:
+		return ArchARM64, true
+	}
+	return "", false
+}
+
+// ArchImages returns the image of each arch of the single-arch image, the amd64 image is the image itself and
+// the others are tagged with the suffix of the arch in suffixes, "-<arch>" by default.
+func ArchImages(image string, suffixes map[string]string) map[string]string {
+	images := make(map[string]string, len(Archs))
+	for _, arch := range Archs {
+		images[arch] = image
+		if arch == ArchAMD64 {
+			continue
+		}
+		suffix, ok := suffixes[arch]
+		if !ok {
+			suffix = "-" + arch
+		}
+		images[arch] = image + suffix
+	}
+	return images
+}
@@ -29,6 +29,7 @@ import (
 
 	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
 	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/credential"
 	"github.com/gostship/kunkka/pkg/gmanager"
 	"github.com/gostship/kunkka/pkg/util/pkiutil"
 	"github.com/pkg/errors"
@@ -36,6 +37,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -83,6 +85,7 @@ func (r *apiReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&devopsv1.Cluster{}).
 		Owns(&devopsv1.ClusterCredential{}).
+		Owns(&corev1.Secret{}, builder.WithPredicates(credential.SecretPredicate())).
 		Owns(&corev1.ConfigMap{}).
 		Complete(r)
 }
@@ -111,6 +114,9 @@ func (r *apiReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	err := r.Client.Get(ctx, req.NamespacedName, c)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
+			// the deletion may be missed, e.g. the finalizer was removed at once
+			r.ClusterManager.Delete(req.Name)
+			delete(r.ClusterStarted, req.Name)
 			logger.V(4).Info("not find cluster")
 			return reconcile.Result{}, nil
 		}
@@ -131,6 +137,7 @@ func (r *apiReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			logger.Error(err, "failed to clean cluster client resources")
 			return reconcile.Result{}, err
 		}
+		delete(r.ClusterStarted, c.Name)
 		return reconcile.Result{}, nil
 	}
 
@@ -139,40 +146,23 @@ func (r *apiReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 }
 
 func (r *apiReconciler) addClusterCheck(ctx context.Context, c *common.Cluster) error {
-	if _, ok := r.ClusterStarted[c.Cluster.Name]; ok {
-		if extKubeconfig, ok := c.ClusterCredential.ExtData[pkiutil.ExternalAdminKubeConfigFileName]; ok {
-			klog.V(4).Infof("cluster: %s, add manager success!", c.Cluster.Name)
-			cls, err := r.GManager.AddNewClusters(c.Cluster.Name, extKubeconfig)
-			if err != nil {
-				klog.Errorf("failed add cluster client: %s manager cache", c.Cluster.Name)
-				return nil
-			}
-
-			klog.Infof("#######  add cluster client: %s to manager cache success", c.Cluster.Name)
-			err = r.GManager.Update(cls)
-			if err != nil {
-				klog.Errorf("failed update cluster client: %s manager cache", c.Cluster.Name)
-				return nil
-			}
-
-			return nil
-		}
-	} else {
-		if extKubeconfig, ok := c.ClusterCredential.ExtData[pkiutil.ExternalAdminKubeConfigFileName]; ok {
-			klog.V(4).Infof("cluster client: %s, add manager success!", c.Cluster.Name)
-			_, err := r.GManager.AddNewClusters(c.Cluster.Name, extKubeconfig)
-			if err != nil {
-				klog.Errorf("failed add cluster client: %s manager cache", c.Cluster.Name)
-				return nil
-			}
-
-			klog.Infof("#######  add cluster client: %s to manager cache success", c.Cluster.Name)
-			r.ClusterStarted[c.Cluster.Name] = true
-			return nil
-		}
+	extKubeconfig, ok := c.ClusterCredential.ExtData[pkiutil.ExternalAdminKubeConfigFileName]
+	if !ok {
+		klog.Warningf("can't find %s", pkiutil.ExternalAdminKubeConfigFileName)
+		return nil
 	}
 
-	klog.Warningf("can't find %s", pkiutil.ExternalAdminKubeConfigFileName)
+	// the client is rebuilt if the kubeconfig changed, e.g. regenerated or of another apiserver endpoint,
+	// even while the cluster is offline with the stale client
+	_, err := r.GManager.AddNewClusters(c.Cluster.Name, extKubeconfig)
+	if err != nil {
+		klog.Errorf("failed add cluster client: %s manager cache", c.Cluster.Name)
+		return nil
+	}
+	if !r.ClusterStarted[c.Cluster.Name] {
+		klog.Infof("#######  add cluster client: %s to manager cache success", c.Cluster.Name)
+		r.ClusterStarted[c.Cluster.Name] = true
+	}
 	return nil
 }
 
@@ -19,24 +19,32 @@ package cluster
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 
 	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
 	"github.com/gostship/kunkka/pkg/constants"
 	"github.com/gostship/kunkka/pkg/controllers/common"
+	credentialutil "github.com/gostship/kunkka/pkg/credential"
 	"github.com/gostship/kunkka/pkg/gmanager"
+	"github.com/gostship/kunkka/pkg/option"
+	"github.com/gostship/kunkka/pkg/provider/cluster"
 	"github.com/gostship/kunkka/pkg/provider/phases/clean"
+	"github.com/gostship/kunkka/pkg/sharding"
 	"github.com/gostship/kunkka/pkg/util/pkiutil"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -49,15 +57,24 @@ type clusterReconciler struct {
 	Mgr            manager.Manager
 	Scheme         *runtime.Scheme
 	ClusterStarted map[string]bool
+	Recorder       record.EventRecorder
+	// startedMu guards ClusterStarted, the clusters are reconciled concurrently
+	startedMu sync.Mutex
+	// shard filters the clusters of the shard of the replica
+	shard *sharding.Sharder
+	// requeue the requeue intervals of the clusters
+	requeue *option.RequeueOption
 }
 
 type clusterContext struct {
 	Key     types.NamespacedName
 	Logger  logr.Logger
 	Cluster *devopsv1.Cluster
+	// RetryAfter the shortest backoff of the failed handlers or the time until the next resync of the addons
+	RetryAfter time.Duration
 }
 
-func Add(mgr manager.Manager, pMgr *gmanager.GManager) error {
+func Add(mgr manager.Manager, pMgr *gmanager.GManager, maxConcurrentReconciles int, requeue *option.RequeueOption, shard *sharding.Sharder) error {
 	reconciler := &clusterReconciler{
 		Client:         mgr.GetClient(),
 		Mgr:            mgr,
@@ -65,28 +82,42 @@ func Add(mgr manager.Manager, pMgr *gmanager.GManager) error {
 		Scheme:         mgr.GetScheme(),
 		GManager:       pMgr,
 		ClusterStarted: make(map[string]bool),
+		Recorder:       mgr.GetEventRecorderFor("cluster-controller"),
+		shard:          shard,
+		requeue:        requeue,
 	}
 
-	err := reconciler.SetupWithManager(mgr)
+	err := reconciler.SetupWithManager(mgr, maxConcurrentReconciles)
 	if err != nil {
 		return errors.Wrapf(err, "unable to create cluster controller")
 	}
+	pMgr.OnStatusChange(reconciler.recordConnectivity)
 
 	return nil
 }
 
-func (r *clusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
+func (r *clusterReconciler) SetupWithManager(mgr ctrl.Manager, maxConcurrentReconciles int) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&devopsv1.Cluster{}).
+		For(&devopsv1.Cluster{}, builder.WithPredicates(r.shard.ClusterPredicate())).
+		WithOptions(controller.Options{MaxConcurrentReconciles: maxConcurrentReconciles}).
 		Owns(&devopsv1.ClusterCredential{}).
+		// the kubeconfigs are kept in the credential secret, the renewed ones rebuild the client
+		Owns(&corev1.Secret{}, builder.WithPredicates(credentialutil.SecretPredicate())).
 		Owns(&corev1.ConfigMap{}).
 		Complete(r)
 }
 
 // +kubebuilder:rbac:groups=devops.gostship.io,resources=clusters,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=devops.gostship.io,resources=clusters/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
 
 func (r *clusterReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	return r.requeue.Result(r.doReconcile(req))
+}
+
+func (r *clusterReconciler) doReconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
 	logger := r.Log.WithValues("cluster", req.NamespacedName.String())
 
@@ -108,6 +139,8 @@ func (r *clusterReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	err := r.Client.Get(ctx, req.NamespacedName, c)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
+			// the finalizer was removed by hand, the client is not released by cleanClusterResources
+			r.release(req.Name)
 			logger.V(4).Info("not find cluster")
 			return reconcile.Result{}, nil
 		}
@@ -116,6 +149,12 @@ func (r *clusterReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return reconcile.Result{}, err
 	}
 
+	if !r.shard.Owns(c) {
+		r.release(c.Name)
+		logger.V(4).Info("cluster is of another shard")
+		return reconcile.Result{}, nil
+	}
+
 	rc := &clusterContext{
 		Key:     req.NamespacedName,
 		Logger:  logger,
@@ -151,7 +190,8 @@ func (r *clusterReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return reconcile.Result{}, nil
 	}
 
-	if !constants.IsK8sSupport(c.Spec.Version) {
+	// the version of the managed clusters is of the cloud, it's recorded in the status
+	if c.Spec.Managed == nil && !constants.IsK8sSupport(c.Spec.Version) {
 		if c.Status.Phase != devopsv1.ClusterNotSupport {
 			logger.V(4).Info("not support", "version", c.Spec.Version)
 			c.Status.Phase = devopsv1.ClusterNotSupport
@@ -165,6 +205,20 @@ func (r *clusterReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	}
 
 	if len(string(c.Status.Phase)) == 0 {
+		if _, ok := c.Annotations[constants.ClusterStrictReconcile]; !ok {
+			// the new clusters are strict, the existing ones keep skipping until opted in
+			logger.V(4).Info("set", "annotation", constants.ClusterStrictReconcile)
+			if c.Annotations == nil {
+				c.Annotations = map[string]string{}
+			}
+			c.Annotations[constants.ClusterStrictReconcile] = "true"
+			err = r.Client.Update(ctx, c)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+
 		logger.V(4).Info("change", "status", devopsv1.ClusterInitializing)
 		c.Status.Phase = devopsv1.ClusterInitializing
 		err = r.Client.Status().Update(ctx, c)
@@ -174,44 +228,74 @@ func (r *clusterReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return ctrl.Result{}, nil
 	}
 
-	r.reconcile(ctx, rc)
-	return ctrl.Result{}, nil
+	if _, ok := c.Annotations[constants.PhaseRetry]; ok {
+		return ctrl.Result{}, r.retryPhases(ctx, rc)
+	}
+	if _, ok := c.Annotations[constants.RerunPhases]; ok {
+		return ctrl.Result{}, r.rerunPhases(ctx, rc)
+	}
+
+	err = r.reconcile(ctx, rc)
+	if common.IsClusterUnavailable(err) {
+		// requeue with the backoff of the workqueue until the cluster is available
+		logger.Info("cluster is degraded", "err", err.Error())
+		return ctrl.Result{}, err
+	}
+	// the status updates of the failed handlers requeue at once, the handlers back off until RequeueAfter
+	return ctrl.Result{RequeueAfter: r.requeue.After(rc.RetryAfter)}, nil
 }
 
 func (r *clusterReconciler) addClusterCheck(ctx context.Context, c *common.Cluster) error {
-	if _, ok := r.ClusterStarted[c.Cluster.Name]; ok {
+	extKubeconfig, ok := c.ClusterCredential.ExtData[pkiutil.ExternalAdminKubeConfigFileName]
+	if !ok {
+		klog.Warningf("can't find %s", pkiutil.ExternalAdminKubeConfigFileName)
 		return nil
 	}
 
-	if extKubeconfig, ok := c.ClusterCredential.ExtData[pkiutil.ExternalAdminKubeConfigFileName]; ok {
-		klog.V(4).Infof("cluster: %s, add manager extKubeconfig: \n%s", c.Cluster.Name, extKubeconfig)
-		_, err := r.GManager.AddNewClusters(c.Cluster.Name, extKubeconfig)
-		if err != nil {
-			klog.Errorf("failed add cluster: %s manager cache", c.Cluster.Name)
-			return nil
-		}
+	// the client is rebuilt if the kubeconfig changed, e.g. regenerated or of another apiserver endpoint,
+	// even while the cluster is offline with the stale client
+	_, err := r.GManager.AddNewClusters(c.Cluster.Name, extKubeconfig)
+	if err != nil {
+		klog.Errorf("failed add cluster: %s manager cache", c.Cluster.Name)
+		return nil
+	}
+	r.startedMu.Lock()
+	defer r.startedMu.Unlock()
+	if !r.ClusterStarted[c.Cluster.Name] {
 		klog.Infof("#######  add cluster: %s to manager cache success", c.Cluster.Name)
 		r.ClusterStarted[c.Cluster.Name] = true
-		return nil
 	}
-
-	klog.Warningf("can't find %s", pkiutil.ExternalAdminKubeConfigFileName)
 	return nil
 }
 
+// release stops the client of the cluster, e.g. deleted or moved to another shard. The client added by the
+// providers, e.g. of the hosted clusters, is stopped as well.
+func (r *clusterReconciler) release(name string) {
+	r.startedMu.Lock()
+	defer r.startedMu.Unlock()
+	if r.ClusterStarted[name] {
+		klog.Infof("cluster: %s start delete with cluster manager", name)
+	}
+	r.ClusterManager.Delete(name)
+	delete(r.ClusterStarted, name)
+}
+
 func (r *clusterReconciler) reconcile(ctx context.Context, rc *clusterContext) error {
 	phaseRestore := constants.GetAnnotationKey(rc.Cluster.Annotations, constants.ClusterPhaseRestore)
 	if len(phaseRestore) > 0 {
 		klog.Infof("cluster: %s phaseRestore: %s", rc.Cluster.Name, phaseRestore)
 		conditions := make([]devopsv1.ClusterCondition, 0)
+		restored := make([]string, 0)
 		for i := range rc.Cluster.Status.Conditions {
-			if rc.Cluster.Status.Conditions[i].Type == phaseRestore {
-				break
+			if rc.Cluster.Status.Conditions[i].Type == phaseRestore || len(restored) > 0 {
+				restored = append(restored, rc.Cluster.Status.Conditions[i].Type)
 			} else {
 				conditions = append(conditions, rc.Cluster.Status.Conditions[i])
 			}
 		}
 		rc.Cluster.Status.Conditions = conditions
+		// the restored phases are run again
+		rc.Cluster.ForgetPhases(restored...)
 		rc.Cluster.Status.Phase = devopsv1.ClusterInitializing
 		err := r.Client.Status().Update(ctx, rc.Cluster)
 		if err != nil {
@@ -239,6 +323,9 @@ func (r *clusterReconciler) reconcile(ctx context.Context, rc *clusterContext) e
 	}
 
 	switch rc.Cluster.Status.Phase {
+	case devopsv1.ClusterFailed:
+		rc.Logger.Info("cluster is failed, annotate it with " + constants.PhaseRetry + " to retry")
+		return nil
 	case devopsv1.ClusterInitializing:
 		rc.Logger.Info("onCreate")
 		r.onCreate(ctx, rc, p, clusterWrapper)
@@ -250,7 +337,17 @@ func (r *clusterReconciler) reconcile(ctx context.Context, rc *clusterContext) e
 		return fmt.Errorf("no handler for %q", rc.Cluster.Status.Phase)
 	}
 
-	return r.applyStatus(ctx, rc, clusterWrapper)
+	err = r.applyStatus(ctx, rc, clusterWrapper)
+	if err != nil {
+		return err
+	}
+
+	rc.RetryAfter = cluster.RetryAfter(clusterWrapper.Cluster)
+	// the running clusters requeue for the next resync of the addons
+	if after := cluster.ResyncAfter(clusterWrapper.Cluster); after > 0 && (rc.RetryAfter == 0 || after < rc.RetryAfter) {
+		rc.RetryAfter = after
+	}
+	return degraded(clusterWrapper)
 }
 
 func (r *clusterReconciler) cleanClusterResources(ctx context.Context, rc *clusterContext) error {
@@ -266,11 +363,8 @@ func (r *clusterReconciler) cleanClusterResources(ctx context.Context, rc *clust
 		}
 	}
 
-	if started, ok := r.ClusterStarted[rc.Cluster.Name]; ok && started {
-		rc.Logger.Info("start delete with cluster manager")
-		r.ClusterManager.Delete(rc.Cluster.Name)
-		delete(r.ClusterStarted, rc.Cluster.Name)
-	}
+	r.collectAddons(ctx, rc)
+	r.release(rc.Cluster.Name)
 
 	credential := &devopsv1.ClusterCredential{}
 	err = r.Client.Get(ctx, types.NamespacedName{Name: rc.Cluster.Name, Namespace: rc.Cluster.Namespace}, credential)
@@ -298,26 +392,45 @@ func (r *clusterReconciler) cleanClusterResources(ctx context.Context, rc *clust
 		r.Client.Delete(ctx, m)
 	}
 
-	// clean master node
+	// clean master node, the nodes which can't be cleaned are skipped by the force deletion
+	forced := rc.Cluster.Annotations[constants.ForceDeletion] == "true"
 	rc.Logger.Info("start clean master node")
+	rc.Cluster.DefaultBastions(rc.Cluster.Spec.Machines...)
+	err = credentialutil.LoadSSH(ctx, r.Client, rc.Cluster.Namespace, rc.Cluster.Spec.Machines...)
+	if err != nil {
+		rc.Logger.Error(err, "failed to load ssh credential")
+		if !forced {
+			return err
+		}
+	}
+	rc.Cluster.AuditPhase("CleanCluster", "")
 	for i := range rc.Cluster.Spec.Machines {
 		m := rc.Cluster.Spec.Machines[i]
-		ssh, err := m.SSH()
-		if err != nil {
-			rc.Logger.Error(err, "failed new ssh", "node", m.IP)
-			return err
+		// the instance of the master never booted has nothing to clean
+		if m.IP == "" {
+			continue
 		}
-
-		rc.Logger.Info("start Delete", "machine", m.IP)
-		err = clean.DleNode(ssh, m.IP)
+		err := cleanMasterNode(rc, m)
 		if err != nil {
-			rc.Logger.Error(err, "failed delete machine node", "node", m.IP)
-			return err
+			if !forced {
+				return err
+			}
+			rc.Logger.Info("force deletion, skip the master node", "node", m.IP)
 		}
+	}
 
-		err = clean.CleanNode(ssh)
-		if err != nil {
-			rc.Logger.Error(err, "failed clean machine node", "node", m.IP)
+	// the delete handlers release what the provider provisioned, e.g. the instances of an infrastructure
+	p, err := r.CpManager.GetProvider(rc.Cluster.Spec.Type)
+	if err == nil {
+		err = p.OnDelete(ctx, &common.Cluster{
+			Cluster:        rc.Cluster,
+			Client:         r.Client,
+			ClusterManager: r.ClusterManager,
+		})
+	}
+	if err != nil {
+		rc.Logger.Error(err, "failed to run the delete handlers")
+		if !forced {
 			return err
 		}
 	}
@@ -326,3 +439,25 @@ func (r *clusterReconciler) cleanClusterResources(ctx context.Context, rc *clust
 	rc.Cluster.ObjectMeta.Finalizers = constants.RemoveString(rc.Cluster.ObjectMeta.Finalizers, constants.FinalizersCluster)
 	return r.Client.Update(ctx, rc.Cluster)
 }
+
+func cleanMasterNode(rc *clusterContext, m *devopsv1.ClusterMachine) error {
+	ssh, err := m.SSH()
+	if err != nil {
+		rc.Logger.Error(err, "failed new ssh", "node", m.IP)
+		return err
+	}
+
+	rc.Logger.Info("start Delete", "machine", m.IP)
+	err = clean.DleNode(ssh, m.IP)
+	if err != nil {
+		rc.Logger.Error(err, "failed delete machine node", "node", m.IP)
+		return err
+	}
+
+	err = clean.CleanNode(ssh)
+	if err != nil {
+		rc.Logger.Error(err, "failed clean machine node", "node", m.IP)
+		return err
+	}
+	return nil
+}
@@ -18,14 +18,20 @@ package cluster
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
 	"github.com/gostship/kunkka/pkg/controllers/common"
+	credentialutil "github.com/gostship/kunkka/pkg/credential"
+	"github.com/gostship/kunkka/pkg/provider/addons/inventory"
 	"github.com/gostship/kunkka/pkg/provider/cluster"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/klog"
 )
 
 const (
@@ -49,7 +55,15 @@ func (r *clusterReconciler) applyStatus(ctx context.Context, rc *clusterContext,
 		return err
 	}
 
-	if !equality.Semantic.DeepEqual(credential.CredentialInfo, cluster.ClusterCredential.CredentialInfo) {
+	// the credentials kept in plaintext are moved to the Secret on the first update
+	plaintext := credentialutil.Plaintext(credential)
+	err = credentialutil.Load(ctx, r.Client, credential)
+	if err != nil {
+		rc.Logger.Error(err, "failed to load cluster credential")
+		return err
+	}
+
+	if plaintext || !equality.Semantic.DeepEqual(credential.CredentialInfo, cluster.ClusterCredential.CredentialInfo) {
 		metaAccessor := meta.NewAccessor()
 		currentResourceVersion, err := metaAccessor.ResourceVersion(credential)
 		if err != nil {
@@ -57,7 +71,7 @@ func (r *clusterReconciler) applyStatus(ctx context.Context, rc *clusterContext,
 			return err
 		}
 		metaAccessor.SetResourceVersion(cluster.ClusterCredential, currentResourceVersion)
-		err = r.Client.Update(ctx, cluster.ClusterCredential)
+		err = credentialutil.Save(ctx, r.Client, cluster.ClusterCredential)
 		if err != nil {
 			rc.Logger.Error(err, "failed to update cluster credential")
 			return err
@@ -91,6 +105,7 @@ func (r *clusterReconciler) applyStatus(ctx context.Context, rc *clusterContext,
 			rc.Logger.Error(err, "failed to update cluster status")
 			return err
 		}
+		r.recordStatus(c, cluster.Cluster)
 
 		rc.Logger.V(4).Info("update cluster status success")
 	}
@@ -98,6 +113,87 @@ func (r *clusterReconciler) applyStatus(ctx context.Context, rc *clusterContext,
 	return nil
 }
 
+// recordStatus records the changes of the phase and the failures as the events of the cluster.
+func (r *clusterReconciler) recordStatus(old, c *devopsv1.Cluster) {
+	if r.Recorder == nil {
+		return
+	}
+	if old.Status.Phase != c.Status.Phase {
+		r.Recorder.Eventf(c, corev1.EventTypeNormal, "PhaseChanged", "phase changed from %q to %q", old.Status.Phase, c.Status.Phase)
+	}
+	if c.Status.Reason != "" && (old.Status.Reason != c.Status.Reason || old.Status.Message != c.Status.Message) {
+		r.Recorder.Event(c, corev1.EventTypeWarning, c.Status.Reason, c.Status.Message)
+	}
+}
+
+// degraded returns ErrClusterUnavailable when the last handler of the strict cluster failed to reach the cluster.
+func degraded(c *common.Cluster) error {
+	if c.Cluster.Status.Reason != cluster.ReasonDegraded {
+		return nil
+	}
+
+	return fmt.Errorf("cluster: %s degraded: %w", c.Cluster.Name, common.ErrClusterUnavailable)
+}
+
+// retryPhases resets the attempts of the failed conditions of the cluster, the failed cluster is initialized
+// again from its failed condition, then removes the phaseRetry annotation.
+func (r *clusterReconciler) retryPhases(ctx context.Context, rc *clusterContext) error {
+	klog.Infof("cluster: %s retry the failed phases", rc.Cluster.Name)
+	for i := range rc.Cluster.Status.Conditions {
+		if rc.Cluster.Status.Conditions[i].Status == devopsv1.ConditionFalse {
+			rc.Cluster.Status.Conditions[i].Attempts = 0
+			rc.Cluster.Status.Conditions[i].Reason = cluster.ReasonFailedProcess
+		}
+	}
+	if rc.Cluster.Status.Phase == devopsv1.ClusterFailed {
+		rc.Cluster.Status.Phase = devopsv1.ClusterInitializing
+	}
+	err := r.Client.Status().Update(ctx, rc.Cluster)
+	if err != nil {
+		return err
+	}
+
+	delete(rc.Cluster.Annotations, constants.PhaseRetry)
+	return r.Client.Update(ctx, rc.Cluster)
+}
+
+// rerunPhases runs the phases of the rerun-phases annotation again, their checkpoints are removed and their
+// conditions reset, the provisioning cluster resumes from the earliest one and skips the later phases completed.
+// The running clusters are left as is, then removes the annotation.
+func (r *clusterReconciler) rerunPhases(ctx context.Context, rc *clusterContext) error {
+	phases := common.SplitPhases(rc.Cluster.Annotations[constants.RerunPhases])
+	if rc.Cluster.Status.Phase == devopsv1.ClusterRunning {
+		klog.Warningf("cluster: %s is running, ignore %s: %v, use %s instead", rc.Cluster.Name,
+			constants.RerunPhases, phases, constants.ClusterAnnotationAction)
+	} else {
+		klog.Infof("cluster: %s rerun the phases: %v", rc.Cluster.Name, phases)
+		for i := range rc.Cluster.Status.Conditions {
+			condition := &rc.Cluster.Status.Conditions[i]
+			// the clusters provisioned before the checkpoints skip their completed phases as well
+			if condition.Status == devopsv1.ConditionTrue {
+				rc.Cluster.CompletePhase(condition.Type)
+			}
+			if constants.ContainsString(phases, condition.Type) {
+				condition.Status = devopsv1.ConditionUnknown
+				condition.Reason = cluster.ReasonWaitingProcess
+				condition.Message = "waiting rerun"
+				condition.Attempts = 0
+			}
+		}
+		rc.Cluster.ForgetPhases(phases...)
+		if rc.Cluster.Status.Phase == devopsv1.ClusterFailed {
+			rc.Cluster.Status.Phase = devopsv1.ClusterInitializing
+		}
+		err := r.Client.Status().Update(ctx, rc.Cluster)
+		if err != nil {
+			return err
+		}
+	}
+
+	delete(rc.Cluster.Annotations, constants.RerunPhases)
+	return r.Client.Update(ctx, rc.Cluster)
+}
+
 func (r *clusterReconciler) onCreate(ctx context.Context, rc *clusterContext, p cluster.Provider, clusterWrapper *common.Cluster) error {
 	err := p.OnCreate(ctx, clusterWrapper)
 	if err != nil {
@@ -129,3 +225,21 @@ func (r *clusterReconciler) onUpdate(ctx context.Context, rc *clusterContext, p
 
 	return nil
 }
+
+// collectAddons deletes the objects of the addons in the member cluster before it's torn down, e.g. the ones
+// kept in the etcd of the hosted cluster. It's best effort, the unreachable cluster is skipped.
+func (r *clusterReconciler) collectAddons(ctx context.Context, rc *clusterContext) {
+	clusterCtx, err := r.ClusterManager.Get(rc.Cluster.Name)
+	if err != nil {
+		rc.Logger.Info("skip collecting the addons", "err", err.Error())
+		return
+	}
+
+	deleted, err := inventory.Prune(ctx, clusterCtx.Client, "", inventory.DefaultKinds)
+	if len(deleted) > 0 {
+		rc.Logger.Info("collected the addons", "deleted", deleted)
+	}
+	if err != nil {
+		rc.Logger.Error(err, "failed to collect the addons")
+	}
+}
@@ -0,0 +1,54 @@
+package cluster
+
+import (
+	"context"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/k8smanager"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+)
+
+// recordConnectivity records in the status of the Cluster whether the cluster is reachable when its client
+// starts or stops failing the probes of the cluster manager, the update requeues the cluster so that the
+// handlers skipped while it was unreachable are reconciled again.
+func (r *clusterReconciler) recordConnectivity(name string, status k8smanager.ClusterStatusType, probeErr error) {
+	connectivity := &devopsv1.ClusterConnectivity{
+		Reachable:          status != k8smanager.ClusterOffline,
+		LastTransitionTime: metav1.Now(),
+	}
+	if probeErr != nil {
+		connectivity.Message = probeErr.Error()
+	}
+
+	ctx := context.Background()
+	c := &devopsv1.Cluster{}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := r.Client.Get(ctx, types.NamespacedName{Namespace: name, Name: name}, c); err != nil {
+			return err
+		}
+		if old := c.Status.Connectivity; old != nil && old.Reachable == connectivity.Reachable {
+			return nil
+		}
+		c.Status.Connectivity = connectivity
+		return r.Client.Status().Update(ctx, c)
+	})
+	if apierrors.IsNotFound(err) {
+		// the extend clusters are not Clusters
+		return
+	}
+	if err != nil {
+		klog.Errorf("cluster: %s update connectivity error: %v", name, err)
+		return
+	}
+
+	if connectivity.Reachable {
+		r.Recorder.Event(c, corev1.EventTypeNormal, "ClusterReachable", "cluster is reachable again")
+	} else {
+		r.Recorder.Event(c, corev1.EventTypeWarning, "ClusterUnreachable", connectivity.Message)
+	}
+}
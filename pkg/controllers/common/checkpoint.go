@@ -0,0 +1,15 @@
+package common
+
+import "strings"
+
+// SplitPhases returns the phases of the rerun-phases annotation, separated by comma.
+func SplitPhases(value string) []string {
+	phases := make([]string, 0)
+	for _, phase := range strings.Split(value, ",") {
+		phase = strings.TrimSpace(phase)
+		if phase != "" {
+			phases = append(phases, phase)
+		}
+	}
+	return phases
+}
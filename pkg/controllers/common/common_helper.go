@@ -10,7 +10,9 @@ import (
 	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
 	"github.com/gostship/kunkka/pkg/constants"
 	"github.com/gostship/kunkka/pkg/controllers/k8smanager"
+	"github.com/gostship/kunkka/pkg/credential"
 	"github.com/gostship/kunkka/pkg/util/k8sutil"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
@@ -30,14 +32,23 @@ type Cluster struct {
 	ClusterCredential *devopsv1.ClusterCredential
 	client.Client
 	*k8smanager.ClusterManager
+	// drifted the objects reverted by the resync of the addons
+	drifted []string
 }
 
 func GetCluster(ctx context.Context, cli client.Client, cluster *devopsv1.Cluster, mgr *k8smanager.ClusterManager) (*Cluster, error) {
 	result := new(Cluster)
 	result.Cluster = cluster
+	cluster.Default()
+	cluster.DefaultBastions(cluster.Spec.Machines...)
+	err := credential.LoadSSH(ctx, cli, cluster.Namespace, cluster.Spec.Machines...)
+	if err != nil {
+		klog.Errorf("cluster: %s faild to load ssh credential, err: %v", cluster.Name, err)
+		return nil, err
+	}
 
 	clusterCredential := &devopsv1.ClusterCredential{}
-	err := cli.Get(ctx, types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}, clusterCredential)
+	err = cli.Get(ctx, types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace}, clusterCredential)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			klog.V(3).Infof("cluster: %s not find credential, start create ...", cluster.Name)
@@ -62,12 +73,61 @@ func GetCluster(ctx context.Context, cli client.Client, cluster *devopsv1.Cluste
 		}
 	}
 
+	err = credential.Load(ctx, cli, clusterCredential)
+	if err != nil {
+		klog.Errorf("cluster: %s faild to load credential, err: %v", cluster.Name, err)
+		return nil, err
+	}
+
 	result.ClusterCredential = clusterCredential
 	result.Client = cli
 	result.ClusterManager = mgr
 	return result, nil
 }
 
+// NodeMarks returns the labels and taints of the machine merged with its cluster placement,
+// the labels and taints of the machine take precedence.
+func (c *Cluster) NodeMarks(ctx context.Context, machine *devopsv1.Machine) (map[string]string, []corev1.Taint, error) {
+	labels := make(map[string]string)
+	var taints []corev1.Taint
+	if len(c.Spec.Placements) > 0 {
+		ms := &devopsv1.MachineList{}
+		err := c.Client.List(ctx, ms, client.InNamespace(machine.Namespace))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if p := c.NodePlacementOf(ms.Items, machine.Spec.Machine.IP); p != nil {
+			klog.Infof("cluster: %s node: %s is dedicated to placement: %s", c.Name, machine.Spec.Machine.IP, p.Name)
+			for k, v := range p.Labels {
+				labels[k] = v
+			}
+			labels[constants.NodePlacementLabel] = p.Name
+			taints = append(taints, p.Taints...)
+		}
+	}
+
+	for k, v := range machine.Spec.Machine.Labels {
+		labels[k] = v
+	}
+	for i := range machine.Spec.Machine.Taints {
+		t := machine.Spec.Machine.Taints[i]
+		existed := false
+		for j := range taints {
+			if taints[j].MatchTaint(&t) {
+				taints[j] = t
+				existed = true
+				break
+			}
+		}
+		if !existed {
+			taints = append(taints, t)
+		}
+	}
+
+	return labels, taints, nil
+}
+
 func Clientset(cluster *devopsv1.Cluster, credential *devopsv1.ClusterCredential) (kubernetes.Interface, error) {
 	return (&Cluster{Cluster: cluster, ClusterCredential: credential}).Clientset()
 }
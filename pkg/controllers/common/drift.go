@@ -0,0 +1,52 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/gostship/kunkka/pkg/util/k8sutil"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RecordDrift records the objects of the cluster found deleted or edited and reverted by the resync.
+func (c *Cluster) RecordDrift(objs ...string) {
+	c.drifted = append(c.drifted, objs...)
+}
+
+// Drifted returns the objects recorded by RecordDrift.
+func (c *Cluster) Drifted() []string {
+	return c.drifted
+}
+
+// ResyncObjects applies the objects of the component to the cluster, the objects missing or differing
+// from the desired ones are recorded as drifted.
+func (c *Cluster) ResyncObjects(cli client.Client, component string, objs []runtime.Object) error {
+	logger := ctrl.Log.WithValues("cluster", c.Name, "component", component)
+	for _, obj := range objs {
+		drifted, err := k8sutil.ReconcileDrift(logger, cli, obj)
+		if err != nil {
+			return errors.Wrapf(err, "resync %s", component)
+		}
+		if drifted {
+			c.RecordDrift(DriftName(obj))
+		}
+	}
+	return nil
+}
+
+// DriftName returns the name of the drifted object, i.e. kind/namespace/name.
+func DriftName(obj runtime.Object) string {
+	kind := reflect.Indirect(reflect.ValueOf(obj)).Type().Name()
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return kind
+	}
+	if accessor.GetNamespace() == "" {
+		return fmt.Sprintf("%s/%s", kind, accessor.GetName())
+	}
+	return fmt.Sprintf("%s/%s/%s", kind, accessor.GetNamespace(), accessor.GetName())
+}
@@ -0,0 +1,49 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// ErrClusterUnavailable is returned by the handlers of a strict cluster when the client of the cluster is unavailable.
+var ErrClusterUnavailable = errors.New("cluster client is unavailable")
+
+// IsClusterUnavailable returns whether the error is caused by the unavailable cluster.
+func IsClusterUnavailable(err error) bool {
+	return errors.Is(err, ErrClusterUnavailable)
+}
+
+// SkippedReconciles counts the handlers which could not reach the cluster, strict or not.
+var SkippedReconciles = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "kunkka_cluster_skipped_reconciles_total",
+		Help: "Number of the cluster handlers which could not get the client of the cluster.",
+	},
+	[]string{"cluster", "handler"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(SkippedReconciles)
+}
+
+// Strict returns whether the handlers fail instead of skipping when the cluster is unavailable.
+func (c *Cluster) Strict() bool {
+	return constants.GetAnnotationKey(c.Cluster.Annotations, constants.ClusterStrictReconcile) == "true"
+}
+
+// SkipReconcile records the handler could not get the client of the cluster, the error is
+// returned for the strict clusters only, the others keep skipping the handler as before.
+func (c *Cluster) SkipReconcile(handler string, err error) error {
+	SkippedReconciles.WithLabelValues(c.Cluster.Name, handler).Inc()
+	if !c.Strict() {
+		klog.Warningf("cluster: %s handler: %s skipped, err: %v", c.Cluster.Name, handler, err)
+		return nil
+	}
+
+	return fmt.Errorf("%s: %w: %v", handler, ErrClusterUnavailable, err)
+}
@@ -18,13 +18,26 @@ package controllers
 
 import (
 	"github.com/gostship/kunkka/pkg/controllers/cluster"
+	"github.com/gostship/kunkka/pkg/controllers/defaulting"
+	"github.com/gostship/kunkka/pkg/controllers/escrow"
+	"github.com/gostship/kunkka/pkg/controllers/health"
 	"github.com/gostship/kunkka/pkg/controllers/k8smanager"
 	"github.com/gostship/kunkka/pkg/controllers/machine"
+	"github.com/gostship/kunkka/pkg/controllers/pullsecret"
+	"github.com/gostship/kunkka/pkg/controllers/trends"
+	"github.com/gostship/kunkka/pkg/credential"
 	"github.com/gostship/kunkka/pkg/gmanager"
+	"github.com/gostship/kunkka/pkg/hostkeys"
+	"github.com/gostship/kunkka/pkg/metrics/state"
 	"github.com/gostship/kunkka/pkg/option"
 	"github.com/gostship/kunkka/pkg/provider"
+	"github.com/gostship/kunkka/pkg/sharding"
+	"github.com/gostship/kunkka/pkg/sshaudit"
+	"github.com/gostship/kunkka/pkg/util/ssh"
 	"k8s.io/klog"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
 )
 
 // AddToManagerFuncs is a list of functions to add all Controllers to the Manager
@@ -34,12 +47,30 @@ var AddToManagerWithProviderFuncs []func(manager.Manager, *gmanager.GManager) er
 
 // AddToManager adds all Controllers to the Manager
 func AddToManager(m manager.Manager, opt *option.ControllersManagerOption) error {
+	err := credential.LoadKeyFiles(opt.CredentialKeyFile, opt.CredentialPreviousKeyFiles)
+	if err != nil {
+		return err
+	}
+
+	// the controllers connecting to the member clusters reconcile the clusters of the shard only
+	shard := sharding.New(opt.Sharding, m.GetClient())
+
 	if opt.EnableCluster {
-		AddToManagerWithProviderFuncs = append(AddToManagerWithProviderFuncs, cluster.Add)
+		AddToManagerWithProviderFuncs = append(AddToManagerWithProviderFuncs, func(m manager.Manager, gMgr *gmanager.GManager) error {
+			return cluster.Add(m, gMgr, opt.ClusterConcurrentReconciles, opt.ClusterRequeue, shard)
+		})
 	}
 
 	if opt.EnableMachine {
-		AddToManagerWithProviderFuncs = append(AddToManagerWithProviderFuncs, machine.Add)
+		AddToManagerWithProviderFuncs = append(AddToManagerWithProviderFuncs, func(m manager.Manager, gMgr *gmanager.GManager) error {
+			return machine.Add(m, gMgr, opt.MachineConcurrentReconciles, opt.MachineParallelism, opt.MachineRequeue, shard)
+		})
+	}
+
+	if opt.EnablePullSecret {
+		AddToManagerWithProviderFuncs = append(AddToManagerWithProviderFuncs, func(m manager.Manager, gMgr *gmanager.GManager) error {
+			return pullsecret.Add(m, gMgr, shard)
+		})
 	}
 
 	pMgr, err := provider.NewProvider()
@@ -56,6 +87,49 @@ func AddToManager(m manager.Manager, opt *option.ControllersManagerOption) error
 		ProviderManager: pMgr,
 		ClusterManager:  k8sMgr,
 	}
+	if opt.EscrowPublicKey != "" {
+		err = escrow.Add(m, opt)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opt.EnableSSHAudit {
+		recorder := sshaudit.NewRecorder(m.GetClient(), m.GetScheme(), opt.SSHAuditRetention)
+		err = m.Add(recorder)
+		if err != nil {
+			return err
+		}
+		ssh.SetAuditor(recorder)
+	}
+
+	if opt.SSHHostKeyPinning {
+		ssh.SetHostKeyStore(hostkeys.NewStore(m.GetClient(), m.GetAPIReader(), m.GetScheme()))
+	}
+
+	if opt.EnableWebhook {
+		err = defaulting.Add(m)
+		if err != nil {
+			return err
+		}
+		// the CRDs served in v1 and v2 are converted through v1, the storage version
+		m.GetWebhookServer().Register("/convert", &conversion.Webhook{})
+	}
+
+	if opt.EnableTrends {
+		err = trends.Add(m, gMgr, opt, shard)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opt.EnableHealth {
+		err = health.Add(m, gMgr, opt, shard)
+		if err != nil {
+			return err
+		}
+	}
+
 	for _, f := range AddToManagerFuncs {
 		if err := f(m); err != nil {
 			return err
@@ -68,6 +142,12 @@ func AddToManager(m manager.Manager, opt *option.ControllersManagerOption) error
 		}
 	}
 
+	// export the phases of the clusters and the machines of the shard on /metrics
+	err = metrics.Registry.Register(state.NewCollector(m.GetClient(), shard))
+	if err != nil {
+		return err
+	}
+
 	m.Add(gMgr.ClusterManager)
 	return nil
 }
@@ -0,0 +1,96 @@
+package defaulting
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/provider/config"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// ClusterPath the path of the mutating webhook of the Clusters
+	ClusterPath = "/mutate-devops-gostship-io-v1-cluster"
+	// MachinePath the path of the mutating webhook of the Machines
+	MachinePath = "/mutate-devops-gostship-io-v1-machine"
+)
+
+// +kubebuilder:webhook:path=/mutate-devops-gostship-io-v1-cluster,mutating=true,failurePolicy=fail,groups=devops.gostship.io,resources=clusters,verbs=create;update,versions=v1,name=mcluster.devops.gostship.io
+// +kubebuilder:webhook:path=/mutate-devops-gostship-io-v1-machine,mutating=true,failurePolicy=fail,groups=devops.gostship.io,resources=machines,verbs=create;update,versions=v1,name=mmachine.devops.gostship.io
+
+// Add registers the mutating webhooks filling the defaults of the Clusters and the Machines on the webhook
+// server of the manager.
+func Add(mgr manager.Manager) error {
+	cfg, err := config.NewDefaultConfig()
+	if err != nil {
+		return errors.Wrap(err, "new provider config")
+	}
+	decoder, err := admission.NewDecoder(mgr.GetScheme())
+	if err != nil {
+		return errors.Wrap(err, "new admission decoder")
+	}
+
+	srv := mgr.GetWebhookServer()
+	srv.Register(ClusterPath, &webhook.Admission{Handler: &defaulter{
+		decoder: decoder,
+		newObj:  func() runtime.Object { return &devopsv1.Cluster{} },
+		defaults: func(obj runtime.Object) {
+			DefaultCluster(obj.(*devopsv1.Cluster), cfg)
+		},
+	}})
+	srv.Register(MachinePath, &webhook.Admission{Handler: &defaulter{
+		decoder: decoder,
+		newObj:  func() runtime.Object { return &devopsv1.Machine{} },
+		defaults: func(obj runtime.Object) {
+			obj.(*devopsv1.Machine).Default()
+		},
+	}})
+	return nil
+}
+
+// DefaultCluster fills the defaults of the cluster, the registry of the config and the sandbox image of the registry.
+func DefaultCluster(c *devopsv1.Cluster, cfg *config.Config) {
+	c.Default()
+	if c.Spec.Registry == "" {
+		c.Spec.Registry = cfg.Registry.Prefix
+	}
+	if c.Spec.ContainerRuntime == nil {
+		c.Spec.ContainerRuntime = &devopsv1.ContainerRuntime{}
+	}
+	if c.Spec.ContainerRuntime.SandboxImage == "" {
+		c.Spec.ContainerRuntime.SandboxImage = cfg.RegistryImageFullName(c.Spec.Registry, "pause", constants.PauseVersion)
+	}
+}
+
+// defaulter decodes the object of the request, fills its defaults and responds with the json patch.
+type defaulter struct {
+	decoder  *admission.Decoder
+	newObj   func() runtime.Object
+	defaults func(runtime.Object)
+}
+
+var _ admission.Handler = &defaulter{}
+
+// Handle ...
+func (d *defaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	obj := d.newObj()
+	if err := d.decoder.Decode(req, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	d.defaults(obj)
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		klog.Errorf("marshal %s %s/%s error: %v", req.Kind.Kind, req.Namespace, req.Name, err)
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, raw)
+}
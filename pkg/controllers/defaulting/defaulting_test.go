@@ -0,0 +1,101 @@
+package defaulting
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/provider/config"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestDefaultClusterRegistry(t *testing.T) {
+	cfg, err := config.NewDefaultConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &devopsv1.Cluster{}
+	DefaultCluster(c, cfg)
+	if c.Spec.Registry != cfg.Registry.Prefix {
+		t.Errorf("DefaultCluster() registry = %q, want %q", c.Spec.Registry, cfg.Registry.Prefix)
+	}
+
+	c = &devopsv1.Cluster{Spec: devopsv1.ClusterSpec{Registry: "registry.example.com/kunkka"}}
+	DefaultCluster(c, cfg)
+	if c.Spec.Registry != "registry.example.com/kunkka" {
+		t.Errorf("DefaultCluster() registry = %q, want kept", c.Spec.Registry)
+	}
+	if want := "registry.example.com/kunkka/pause:v3.2"; c.Spec.ContainerRuntime.SandboxImage != want {
+		t.Errorf("DefaultCluster() sandbox image = %q, want %q", c.Spec.ContainerRuntime.SandboxImage, want)
+	}
+}
+
+func TestDefaulterHandle(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := devopsv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.NewDefaultConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &defaulter{
+		decoder: decoder,
+		newObj:  func() runtime.Object { return &devopsv1.Cluster{} },
+		defaults: func(obj runtime.Object) {
+			DefaultCluster(obj.(*devopsv1.Cluster), cfg)
+		},
+	}
+
+	tests := []struct {
+		name    string
+		raw     string
+		allowed bool
+		patches map[string]bool
+	}{
+		{
+			name:    "minimal",
+			raw:     `{"apiVersion":"devops.gostship.io/v1","kind":"Cluster","metadata":{"name":"c1"},"spec":{"type":"Baremetal","machines":[{"ip":"10.0.0.1"}]}}`,
+			allowed: true,
+			patches: map[string]bool{"/spec/properties": true, "/spec/dnsDomain": true, "/spec/registry": true, "/spec/containerRuntime": true, "/spec/machines/0/port": true},
+		},
+		{
+			name:    "complete",
+			raw:     `{"apiVersion":"devops.gostship.io/v1","kind":"Cluster","metadata":{"name":"c1"},"spec":{"type":"Baremetal","version":"v1.18.5","clusterCIDR":"10.10.0.0/16","networkDevice":"eth1","dnsDomain":"example.local","serviceCIDR":"10.96.0.0/16","features":{"ipvs":false},"properties":{"maxNodePodNum":64},"etcd":{"local":{}},"registry":"registry.example.com/kunkka","containerRuntime":{"sandboxImage":"pause:3.2"}}}`,
+			allowed: true,
+			patches: map[string]bool{"/spec/properties": false, "/spec/properties/maxClusterServiceNum": false, "/spec/dnsDomain": false, "/spec/registry": false, "/spec/containerRuntime/sandboxImage": false},
+		},
+		{
+			name: "invalid",
+			raw:  `{"spec":`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := d.Handle(context.TODO(), admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+				Object: runtime.RawExtension{Raw: []byte(tt.raw)},
+			}})
+			if resp.Allowed != tt.allowed {
+				t.Fatalf("Handle() allowed = %v, want %v: %v", resp.Allowed, tt.allowed, resp.Result)
+			}
+			got := map[string]bool{}
+			for _, p := range resp.Patches {
+				got[p.Path] = true
+			}
+			for path, want := range tt.patches {
+				if got[path] != want {
+					data, _ := json.Marshal(resp.Patches)
+					t.Errorf("Handle() patches = %s, want %s patched: %v", data, path, want)
+				}
+			}
+		})
+	}
+}
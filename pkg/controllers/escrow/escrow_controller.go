@@ -0,0 +1,235 @@
+/*
+Copyright 2020 dke.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package escrow
+
+import (
+	"context"
+	"crypto/rsa"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/go-logr/logr"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	credentialutil "github.com/gostship/kunkka/pkg/credential"
+	"github.com/gostship/kunkka/pkg/escrow"
+	"github.com/gostship/kunkka/pkg/option"
+	"github.com/gostship/kunkka/pkg/util/pkiutil"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// escrowReconciler seals the admin kubeconfig of each cluster to the public key of the admins,
+// the sealed copy is only readable with the private key kept offline by the admins.
+type escrowReconciler struct {
+	client.Client
+	Log       logr.Logger
+	PublicKey *rsa.PublicKey
+	KeyID     string
+	Namespace string
+	Dir       string
+}
+
+func Add(mgr manager.Manager, opt *option.ControllersManagerOption) error {
+	pub, err := escrow.LoadPublicKey(opt.EscrowPublicKey)
+	if err != nil {
+		return errors.Wrapf(err, "load escrow public key: %s", opt.EscrowPublicKey)
+	}
+
+	keyID, err := escrow.KeyID(pub)
+	if err != nil {
+		return err
+	}
+
+	reconciler := &escrowReconciler{
+		Client:    mgr.GetClient(),
+		Log:       ctrl.Log.WithName("controllers").WithName("escrow"),
+		PublicKey: pub,
+		KeyID:     keyID,
+		Namespace: opt.EscrowNamespace,
+		Dir:       opt.EscrowDir,
+	}
+
+	err = reconciler.SetupWithManager(mgr)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create escrow controller")
+	}
+
+	return nil
+}
+
+func (r *escrowReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("escrow").
+		For(&devopsv1.ClusterCredential{}).
+		// the credential secret is owned by the cluster of the same name as the credential
+		Watches(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestForOwner{OwnerType: &devopsv1.Cluster{}, IsController: true}).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups=devops.gostship.io,resources=clustercredentials,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update
+
+func (r *escrowReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	logger := r.Log.WithValues("credential", req.NamespacedName.String())
+
+	credential := &devopsv1.ClusterCredential{}
+	err := r.Client.Get(ctx, req.NamespacedName, credential)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			// keep the escrowed copy, it is the last resort of the cluster which may still be running
+			logger.V(4).Info("not find credential")
+			return reconcile.Result{}, nil
+		}
+
+		logger.Error(err, "failed to get credential")
+		return reconcile.Result{}, err
+	}
+	err = credentialutil.Load(ctx, r.Client, credential)
+	if err != nil {
+		logger.Error(err, "failed to load credential")
+		return reconcile.Result{}, err
+	}
+
+	kubeconfig, ok := credential.ExtData[pkiutil.ExternalAdminKubeConfigFileName]
+	if !ok || kubeconfig == "" {
+		logger.V(4).Info("credential has no admin kubeconfig yet")
+		return reconcile.Result{}, nil
+	}
+
+	cluster := credential.CredentialInfo.ClusterName
+	if cluster == "" {
+		cluster = credential.Name
+	}
+
+	err = r.escrow(ctx, cluster, []byte(kubeconfig))
+	if err != nil {
+		logger.Error(err, "failed to escrow credential")
+		return reconcile.Result{}, err
+	}
+
+	return reconcile.Result{}, nil
+}
+
+func (r *escrowReconciler) escrow(ctx context.Context, cluster string, kubeconfig []byte) error {
+	checksum := escrow.Checksum(kubeconfig)
+	key := types.NamespacedName{Namespace: r.Namespace, Name: escrow.SecretName(cluster)}
+
+	s := &corev1.Secret{}
+	err := r.Client.Get(ctx, key, s)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	exist := err == nil
+	if exist && s.Annotations[constants.CredentialEscrowChecksum] == checksum &&
+		s.Annotations[constants.CredentialEscrowKeyID] == r.KeyID {
+		return nil
+	}
+
+	env, err := escrow.Seal(r.PublicKey, cluster, kubeconfig)
+	if err != nil {
+		return err
+	}
+
+	data, err := escrow.Marshal(env)
+	if err != nil {
+		return err
+	}
+
+	if r.Dir != "" {
+		err = writeFile(r.Dir, key.Name+".json", data)
+		if err != nil {
+			return errors.Wrapf(err, "write escrow dir: %s", r.Dir)
+		}
+	}
+
+	if !exist {
+		s = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.Name,
+				Namespace: key.Namespace,
+				Labels: map[string]string{
+					constants.CredentialEscrowLabel: "true",
+					constants.CreatedByLabel:        constants.CreatedBy,
+				},
+			},
+			Type: corev1.SecretTypeOpaque,
+		}
+	}
+
+	if s.Annotations == nil {
+		s.Annotations = make(map[string]string)
+	}
+	s.Annotations[constants.CredentialEscrowCluster] = cluster
+	s.Annotations[constants.CredentialEscrowChecksum] = checksum
+	s.Annotations[constants.CredentialEscrowKeyID] = r.KeyID
+	s.Data = map[string][]byte{
+		escrow.DataKey: data,
+	}
+
+	if exist {
+		err = r.Client.Update(ctx, s)
+	} else {
+		err = r.Client.Create(ctx, s)
+	}
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("cluster: %s admin credential escrowed to key: %s", cluster, r.KeyID)
+	return nil
+}
+
+// writeFile replaces the file atomically, so a crash never leaves a truncated copy.
+func writeFile(dir, name string, data []byte) error {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+name)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2020 dke.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/k8smanager"
+	"github.com/gostship/kunkka/pkg/gmanager"
+	"github.com/gostship/kunkka/pkg/option"
+	"github.com/gostship/kunkka/pkg/sharding"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// DefaultInterval the default period of the probes of a cluster
+	DefaultInterval = time.Minute
+	// LastSeenPeriod the lastSeen of the reachable clusters is refreshed once it's older than the period, so the
+	// probes don't update the status of every cluster on every interval
+	LastSeenPeriod = 5 * time.Minute
+
+	ReasonProbeSucceeded = "ProbeSucceeded"
+	ReasonProbeFailed    = "ProbeFailed"
+)
+
+// healthReconciler probes the apiserver of each running cluster every Interval with the client of the cluster
+// manager and records the result in the Reachable condition and the lastSeen of the cluster.
+type healthReconciler struct {
+	client.Client
+	*gmanager.GManager
+	Log      logr.Logger
+	Interval time.Duration
+	// shard filters the clusters probed by the replica, whose clients are of its shard
+	shard *sharding.Sharder
+
+	// probed the last probe of each cluster, the status updates of the other controllers don't probe again
+	mu     sync.Mutex
+	probed map[string]time.Time
+}
+
+func Add(mgr manager.Manager, pMgr *gmanager.GManager, opt *option.ControllersManagerOption, shard *sharding.Sharder) error {
+	reconciler := &healthReconciler{
+		Client:   mgr.GetClient(),
+		GManager: pMgr,
+		Log:      ctrl.Log.WithName("controllers").WithName("health"),
+		Interval: opt.HealthInterval,
+		shard:    shard,
+		probed:   make(map[string]time.Time),
+	}
+	if reconciler.Interval <= 0 {
+		reconciler.Interval = DefaultInterval
+	}
+
+	err := reconciler.SetupWithManager(mgr)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create health controller")
+	}
+
+	return nil
+}
+
+func (r *healthReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("health").
+		For(&devopsv1.Cluster{}, builder.WithPredicates(r.shard.ClusterPredicate())).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups=devops.gostship.io,resources=clusters,verbs=get;list;watch
+// +kubebuilder:rbac:groups=devops.gostship.io,resources=clusters/status,verbs=get;update;patch
+
+func (r *healthReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	logger := r.Log.WithValues("cluster", req.NamespacedName.String())
+
+	cluster := &devopsv1.Cluster{}
+	err := r.Client.Get(ctx, req.NamespacedName, cluster)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			r.forget(req.Name)
+			return reconcile.Result{}, nil
+		}
+
+		logger.Error(err, "failed to get cluster")
+		return reconcile.Result{}, err
+	}
+
+	// the clusters are connected once running, the phase change reconciles them again
+	if !cluster.ObjectMeta.DeletionTimestamp.IsZero() || !r.shard.Owns(cluster) ||
+		cluster.Status.Phase != devopsv1.ClusterRunning {
+		r.forget(cluster.Name)
+		return reconcile.Result{}, nil
+	}
+
+	if after := r.nextProbe(cluster.Name); after > 0 {
+		return reconcile.Result{RequeueAfter: after}, nil
+	}
+
+	probeErr := r.ClusterManager.Probe(cluster.Name)
+	if probeErr == k8smanager.ErrClusterNotFound {
+		// not connected by the cluster controller yet
+		return reconcile.Result{RequeueAfter: r.Interval}, nil
+	}
+	r.setProbed(cluster.Name)
+	if probeErr != nil {
+		logger.V(4).Info("cluster is unreachable", "err", probeErr.Error())
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := r.Client.Get(ctx, req.NamespacedName, cluster); err != nil {
+			return err
+		}
+		if !setReachable(cluster, probeErr, metav1.Now()) {
+			return nil
+		}
+		return r.Client.Status().Update(ctx, cluster)
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		logger.Error(err, "failed to update reachable condition")
+		return reconcile.Result{}, err
+	}
+
+	return reconcile.Result{RequeueAfter: r.Interval}, nil
+}
+
+// nextProbe returns the time until the next probe of the cluster, 0 if it's due.
+func (r *healthReconciler) nextProbe(name string) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	last, ok := r.probed[name]
+	if !ok {
+		return 0
+	}
+	if after := time.Until(last.Add(r.Interval)); after > 0 {
+		return after
+	}
+	return 0
+}
+
+func (r *healthReconciler) setProbed(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.probed[name] = time.Now()
+}
+
+func (r *healthReconciler) forget(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.probed, name)
+}
+
+// setReachable records the result of the probe in the Reachable condition and the connectivity of the cluster,
+// it returns false if the status is unchanged. The lastSeen of the reachable cluster is refreshed once it's
+// older than LastSeenPeriod.
+func setReachable(c *devopsv1.Cluster, probeErr error, now metav1.Time) bool {
+	reachable := probeErr == nil
+	status := devopsv1.ConditionTrue
+	reason, message := ReasonProbeSucceeded, ""
+	if !reachable {
+		status = devopsv1.ConditionFalse
+		reason, message = ReasonProbeFailed, probeErr.Error()
+	}
+
+	if old, known := c.Reachable(); known && old == reachable {
+		connectivity := c.Status.Connectivity
+		if !reachable || (connectivity != nil && connectivity.LastSeen != nil &&
+			now.Sub(connectivity.LastSeen.Time) < LastSeenPeriod) {
+			return false
+		}
+	}
+
+	c.SetCondition(devopsv1.ClusterCondition{
+		Type:          devopsv1.ClusterConditionReachable,
+		Status:        status,
+		LastProbeTime: now,
+		Reason:        reason,
+		Message:       message,
+	})
+	if c.Status.Connectivity == nil {
+		c.Status.Connectivity = &devopsv1.ClusterConnectivity{
+			Reachable:          reachable,
+			LastTransitionTime: now,
+			Message:            message,
+		}
+	}
+	if reachable {
+		c.Status.Connectivity.LastSeen = &now
+	}
+	return true
+}
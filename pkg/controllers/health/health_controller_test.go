@@ -0,0 +1,49 @@
+package health
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetReachable(t *testing.T) {
+	now := metav1.Now()
+	c := &devopsv1.Cluster{}
+
+	if !setReachable(c, nil, now) {
+		t.Fatalf("setReachable() of the first probe = false, want true")
+	}
+	if reachable, known := c.Reachable(); !reachable || !known {
+		t.Errorf("Reachable() = %v, %v, want true, true", reachable, known)
+	}
+	if c.Status.Connectivity == nil || c.Status.Connectivity.LastSeen == nil || !c.Status.Connectivity.LastSeen.Equal(&now) {
+		t.Fatalf("lastSeen = %v, want %v", c.Status.Connectivity, now)
+	}
+
+	later := metav1.NewTime(now.Add(time.Minute))
+	if setReachable(c, nil, later) {
+		t.Errorf("setReachable() of a recent lastSeen = true, want false")
+	}
+
+	stale := metav1.NewTime(now.Add(LastSeenPeriod + time.Minute))
+	if !setReachable(c, nil, stale) || !c.Status.Connectivity.LastSeen.Equal(&stale) {
+		t.Errorf("lastSeen = %v, want it refreshed to %v", c.Status.Connectivity.LastSeen, stale)
+	}
+
+	failed := metav1.NewTime(stale.Add(time.Minute))
+	if !setReachable(c, errors.New("connection refused"), failed) {
+		t.Fatalf("setReachable() of a failed probe = false, want true")
+	}
+	if reachable, known := c.Reachable(); reachable || !known {
+		t.Errorf("Reachable() = %v, %v, want false, true", reachable, known)
+	}
+	if !c.Status.Connectivity.LastSeen.Equal(&stale) {
+		t.Errorf("lastSeen = %v, want it kept at %v", c.Status.Connectivity.LastSeen, stale)
+	}
+	if setReachable(c, errors.New("connection refused"), metav1.NewTime(failed.Add(time.Minute))) {
+		t.Errorf("setReachable() of an unchanged failure = true, want false")
+	}
+}
@@ -2,6 +2,7 @@ package k8smanager
 
 import (
 	"strings"
+	"sync"
 	"time"
 
 	"context"
@@ -9,7 +10,9 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/gostship/kunkka/pkg/k8sclient"
 	"github.com/pkg/errors"
-	"k8s.io/apimachinery/pkg/util/runtime"
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog"
@@ -31,22 +34,41 @@ const (
 
 var (
 	SyncPeriodTime = 1 * time.Hour
+	// CacheIdleTimeout stops the informers of the clusters whose clients are not read for the duration, they
+	// are started again on the next read, 0 keeps them running
+	CacheIdleTimeout = 30 * time.Minute
+	// CacheSyncTimeout bounds the sync of the informers started on a read
+	CacheSyncTimeout = time.Minute
 )
 
+func AddFlags(fs *pflag.FlagSet) {
+	fs.DurationVar(&CacheIdleTimeout, "member-cache-idle-timeout", CacheIdleTimeout, "The informers of a member cluster are stopped once its client is not read for the duration and started again on the next read, 0 keeps them running")
+}
+
 type Cluster struct {
 	Name          string
 	AliasName     string
 	RawKubeconfig []byte
 	Meta          map[string]string
 	RestConfig    *rest.Config
-	Client        client.Client
-	KubeCli       kubernetes.Interface
+	// Client reads through the informers of the cluster, started on the first read, and writes to the apiserver
+	Client  client.Client
+	KubeCli kubernetes.Interface
+
+	Log        logr.Logger
+	SyncPeriod time.Duration
 
-	Log             logr.Logger
+	// cacheMu guards the informers and the node counter, they are rebuilt on the read after an idle stop as a
+	// stopped cache can't be started again
+	cacheMu         sync.Mutex
 	Mgr             manager.Manager
 	Cache           cache.Cache
-	SyncPeriod      time.Duration
 	internalStopper chan struct{}
+	nodes           *nodeCounter
+	syncing         *cacheSync
+	lastUsed        time.Time
+	// stopped the cluster is removed from the manager, its informers are not started anymore
+	stopped bool
 
 	Status ClusterStatusType
 	// Started is true if the Informers has been Started
@@ -55,12 +77,11 @@ type Cluster struct {
 
 func NewCluster(name string, kubeconfig []byte, log logr.Logger) (*Cluster, error) {
 	cluster := &Cluster{
-		Name:            name,
-		RawKubeconfig:   kubeconfig,
-		Log:             log.WithValues("cluster", name),
-		SyncPeriod:      SyncPeriodTime,
-		internalStopper: make(chan struct{}),
-		Started:         false,
+		Name:          name,
+		RawKubeconfig: kubeconfig,
+		Log:           log.WithValues("cluster", name),
+		SyncPeriod:    SyncPeriodTime,
+		Started:       false,
 	}
 
 	err := cluster.initK8SClients()
@@ -92,6 +113,20 @@ func (c *Cluster) initK8SClients() error {
 
 	klog.V(5).Infof("##### cluster [%s] NewClientCli. time taken: %v. ", c.Name, time.Since(startTime))
 	c.KubeCli = kubecli
+
+	direct, err := client.New(c.RestConfig, client.Options{Scheme: k8sclient.GetScheme()})
+	if err != nil {
+		return errors.Wrapf(err, "could not new client name:%s", c.Name)
+	}
+	c.Client = &cacheClient{Client: direct, cluster: c}
+
+	err = c.newCache()
+	klog.V(5).Infof("##### cluster [%s] NewManagerCli. time taken: %v. ", c.Name, time.Since(startTime))
+	return err
+}
+
+// newCache creates the informers of the cluster with the indexes of the api views, they are started by acquire.
+func (c *Cluster) newCache() error {
 	o := manager.Options{
 		Scheme:                 k8sclient.GetScheme(),
 		SyncPeriod:             &c.SyncPeriod,
@@ -104,48 +139,190 @@ func (c *Cluster) initK8SClients() error {
 		return errors.Wrapf(err, "could not new manager name:%s", c.Name)
 	}
 
-	klog.V(5).Infof("##### cluster [%s] NewManagerCli. time taken: %v. ", c.Name, time.Since(startTime))
 	c.Mgr = mgr
-	c.Client = mgr.GetClient()
 	c.Cache = mgr.GetCache()
-	return nil
+	c.internalStopper = make(chan struct{})
+	if err := c.preStart(); err != nil {
+		return err
+	}
+	return c.countNodes()
 }
 
-func (c *Cluster) healthCheck() bool {
-	body, err := c.KubeCli.Discovery().RESTClient().Get().AbsPath("/healthz").Do(context.TODO()).Raw()
+// probe checks the apiserver of the cluster is healthy and serves a cheap list with the client of the cluster,
+// the healthz of an apiserver whose etcd is lost may still be ok.
+func (c *Cluster) probe(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	body, err := c.KubeCli.Discovery().RESTClient().Get().AbsPath("/healthz").Do(ctx).Raw()
 	if err != nil {
-		runtime.HandleError(errors.Wrapf(err, "Failed to do cluster health check for cluster %q", c.Name))
-		c.Status = ClusterOffline
-		return false
+		return errors.Wrapf(err, "failed to do cluster health check for cluster %q", c.Name)
 	}
-
 	if !strings.EqualFold(string(body), "ok") {
-		c.Status = ClusterOffline
-		return false
+		return errors.Errorf("cluster %q is not healthy: %s", c.Name, body)
 	}
-	c.Status = ClusterReady
-	return true
+	_, err = c.KubeCli.CoreV1().Namespaces().List(ctx, metav1.ListOptions{Limit: 1})
+	if err != nil {
+		return errors.Wrapf(err, "failed to list namespaces of cluster %q", c.Name)
+	}
+	return nil
 }
 
-func (c *Cluster) StartCache(stopCh <-chan struct{}) {
-	if c.Started {
-		klog.Infof("cluster name: %s cache Informers is already startd", c.Name)
-		return
+// KubeconfigChanged returns whether the client of the cluster was built from another kubeconfig, e.g. of
+// regenerated credentials or of a moved apiserver endpoint, and must be rebuilt.
+func (c *Cluster) KubeconfigChanged(kubeconfig string) bool {
+	return string(c.RawKubeconfig) != kubeconfig
+}
+
+// cacheSync the in-flight sync of the informers started by a read, the other reads wait for it
+type cacheSync struct {
+	done chan struct{}
+	err  error
+}
+
+// acquire returns the client of the informers of the cluster, they are started, or rebuilt after an idle stop,
+// and synced first. The lock is released while syncing, the concurrent reads wait for the same sync.
+func (c *Cluster) acquire(ctx context.Context) (client.Client, error) {
+	for {
+		c.cacheMu.Lock()
+		if c.stopped {
+			c.cacheMu.Unlock()
+			return nil, errors.Errorf("cluster: %s is removed", c.Name)
+		}
+		c.lastUsed = time.Now()
+		if c.Started {
+			cli := c.Mgr.GetClient()
+			c.cacheMu.Unlock()
+			return cli, nil
+		}
+
+		if inflight := c.syncing; inflight != nil {
+			c.cacheMu.Unlock()
+			select {
+			case <-inflight.done:
+				if inflight.err != nil {
+					return nil, inflight.err
+				}
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		if c.Cache == nil {
+			if err := c.newCache(); err != nil {
+				c.cacheMu.Unlock()
+				return nil, err
+			}
+		}
+		inflight := &cacheSync{done: make(chan struct{})}
+		c.syncing = inflight
+		stopper, informers := c.internalStopper, c.Cache
+		c.cacheMu.Unlock()
+
+		inflight.err = c.syncCache(ctx, stopper, informers)
+		close(inflight.done)
+		if inflight.err != nil {
+			return nil, inflight.err
+		}
 	}
+}
 
+// syncCache starts the informers and waits for their sync, the informers are stopped if not synced
+// in CacheSyncTimeout or once the cluster is stopped meanwhile.
+func (c *Cluster) syncCache(ctx context.Context, stopper chan struct{}, informers cache.Cache) error {
 	klog.Infof("cluster name: %s start cache Informers ", c.Name)
 	go func() {
-		err := c.Cache.Start(c.internalStopper)
+		err := informers.Start(stopper)
 		if err != nil {
 			klog.Warningf("cluster name: %s cache Informers quit end err: %+v", c.Name, err)
 		}
 	}()
 
-	c.Cache.WaitForCacheSync(stopCh)
+	ctx, cancel := context.WithTimeout(ctx, CacheSyncTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-stopper:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	synced := informers.WaitForCacheSync(ctx.Done())
+
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	c.syncing = nil
+	if c.Cache != informers {
+		return errors.Errorf("cluster: %s is removed", c.Name)
+	}
+	if !synced {
+		c.stopCache()
+		return errors.Errorf("cluster: %s cache Informers not synced in %s", c.Name, CacheSyncTimeout)
+	}
 	c.Started = true
+	return nil
 }
 
-func (c *Cluster) Stop() {
-	klog.Infof("cluster: %s start stop cache Informers")
+// stopCache stops the informers, the next read rebuilds them.
+func (c *Cluster) stopCache() {
+	if c.Cache == nil {
+		return
+	}
 	close(c.internalStopper)
+	c.Mgr = nil
+	c.Cache = nil
+	c.nodes = nil
+	c.Started = false
+}
+
+// stopIdle stops the informers of the cluster not read for the timeout, it returns whether they are stopped.
+func (c *Cluster) stopIdle(timeout time.Duration) bool {
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+
+	if timeout <= 0 || !c.Started || time.Since(c.lastUsed) < timeout {
+		return false
+	}
+	klog.Infof("cluster: %s cache Informers idle for %s, stop them", c.Name, timeout)
+	c.stopCache()
+	return true
+}
+
+// cacheSynced returns false if the started informers of the cluster are not synced before the stopCh is closed,
+// the stopped ones are synced on the next read.
+func (c *Cluster) cacheSynced(stopCh <-chan struct{}) bool {
+	c.cacheMu.Lock()
+	started, informers := c.Started, c.Cache
+	c.cacheMu.Unlock()
+	return !started || informers.WaitForCacheSync(stopCh)
+}
+
+func (c *Cluster) Stop() {
+	klog.Infof("cluster: %s start stop cache Informers", c.Name)
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	c.stopped = true
+	c.stopCache()
+}
+
+// cacheClient reads the objects of the cluster from its informers, the writes go to the apiserver directly.
+type cacheClient struct {
+	client.Client
+	cluster *Cluster
+}
+
+func (cc *cacheClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	cli, err := cc.cluster.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	return cli.Get(ctx, key, obj)
+}
+
+func (cc *cacheClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	cli, err := cc.cluster.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	return cli.List(ctx, list, opts...)
 }
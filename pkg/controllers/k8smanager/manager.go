@@ -8,6 +8,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -27,6 +28,19 @@ const (
 	ClustersAll = "all"
 )
 
+var (
+	// ProbePeriod the period of the probes of the cluster clients
+	ProbePeriod = time.Minute
+	// ProbeTimeout the timeout of a probe of a cluster client
+	ProbeTimeout = 10 * time.Second
+)
+
+// ErrClusterNotFound the cluster is not added to the manager, e.g. it's not running yet
+var ErrClusterNotFound = errors.New("cluster not found")
+
+// StatusHandler is called when a cluster becomes offline, with the error of the probe, or ready again.
+type StatusHandler func(name string, status ClusterStatusType, err error)
+
 // MasterClient ...
 type MasterClient struct {
 	KubeCli kubernetes.Interface
@@ -36,9 +50,10 @@ type MasterClient struct {
 // ClusterManager ...
 type ClusterManager struct {
 	MasterClient
-	clusters []*Cluster
-	monitor  map[string]*prometheus.Prometheus
-	Started  bool
+	clusters       []*Cluster
+	monitor        map[string]*prometheus.Prometheus
+	statusHandlers []StatusHandler
+	Started        bool
 	sync.RWMutex
 }
 
@@ -88,12 +103,12 @@ func (m *ClusterManager) GetAll(name ...string) []*Cluster {
 
 // Add ...
 func (m *ClusterManager) Add(cluster *Cluster) error {
-	if _, err := m.Get(cluster.Name); err == nil {
+	m.Lock()
+	defer m.Unlock()
+	if _, ok := m.GetClusterIndex(cluster.Name); ok {
 		return fmt.Errorf("cluster name: %s is already add to manager", cluster.Name)
 	}
 
-	m.Lock()
-	defer m.Unlock()
 	m.clusters = append(m.clusters, cluster)
 	sort.Slice(m.clusters, func(i int, j int) bool {
 		return m.clusters[i].Name > m.clusters[j].Name
@@ -111,7 +126,7 @@ func (m *ClusterManager) Update(cluster *Cluster) error {
 		klog.Infof("the cluster update %s has been updated.", cls.Name)
 		return nil
 	}
-	klog.Error("cluster %s,not found.", cluster.Name)
+	klog.Errorf("cluster %s,not found.", cluster.Name)
 	return errors.New("cluster not found.")
 }
 
@@ -125,7 +140,8 @@ func (m *ClusterManager) GetClusterIndex(name string) (int, bool) {
 	return 0, false
 }
 
-// Delete ...
+// Delete stops the client and the informers of the cluster and removes it, e.g. deleted or of renewed
+// credentials, the cluster not added is ignored.
 func (m *ClusterManager) Delete(name string) error {
 	if name == "" {
 		return nil
@@ -134,14 +150,9 @@ func (m *ClusterManager) Delete(name string) error {
 	m.Lock()
 	defer m.Unlock()
 
-	if len(m.clusters) == 0 {
-		klog.Errorf("clusters list is empty, nothing to delete")
-		return nil
-	}
-
 	index, ok := m.GetClusterIndex(name)
 	if !ok {
-		klog.Warningf("cluster:%s  is not found in the registries list, nothing to delete", name)
+		klog.V(4).Infof("cluster: %s is not found in the registries list, nothing to delete", name)
 		return nil
 	}
 
@@ -180,36 +191,101 @@ func (m *ClusterManager) Get(name string) (*Cluster, error) {
 	return findCluster, nil
 }
 
+// OnStatusChange adds the handler called when a cluster becomes offline or ready again.
+func (m *ClusterManager) OnStatusChange(handler StatusHandler) {
+	m.Lock()
+	defer m.Unlock()
+	m.statusHandlers = append(m.statusHandlers, handler)
+}
+
+// cluterCheck probes the clients of all the clusters concurrently, the clusters failing the probe are offline,
+// i.e. not returned by Get, until a probe succeeds again. The idle informers are stopped.
 func (m *ClusterManager) cluterCheck() {
 	klog.V(5).Info("cluster health check.")
-	for _, c := range m.clusters {
-		if !c.healthCheck() {
-			klog.Warningf("cluster: %s healthCheck fail", c.Name)
-		}
+	m.RLock()
+	clusters := append([]*Cluster(nil), m.clusters...)
+	m.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, c := range clusters {
+		wg.Add(1)
+		go func(c *Cluster) {
+			defer wg.Done()
+			err := c.probe(ProbeTimeout)
+			if err != nil {
+				klog.Warningf("cluster: %s healthCheck fail: %v", c.Name, err)
+			}
+			m.setStatus(c, err)
+			c.stopIdle(CacheIdleTimeout)
+		}(c)
 	}
+	wg.Wait()
 }
 
-func (m *ClusterManager) AddNewClusters(name string, kubeconfig string) (*Cluster, error) {
-	if c, _ := m.Get(name); c != nil {
-		return c, nil
+// Probe probes the client of the cluster, offline or not, and records the result like the periodic probes.
+// It returns ErrClusterNotFound if the cluster is not added.
+func (m *ClusterManager) Probe(name string) error {
+	m.RLock()
+	var c *Cluster
+	if index, ok := m.GetClusterIndex(name); ok {
+		c = m.clusters[index]
+	}
+	m.RUnlock()
+	if c == nil {
+		return ErrClusterNotFound
 	}
 
-	nc, err := NewCluster(name, []byte(kubeconfig), logger)
+	err := c.probe(ProbeTimeout)
+	m.setStatus(c, err)
+	return err
+}
+
+// setStatus records the result of the probe of the cluster and calls the handlers if the status changed.
+func (m *ClusterManager) setStatus(c *Cluster, err error) {
+	status := ClusterReady
 	if err != nil {
-		klog.Errorf("cluster: %s new client err: %v", name, err)
-		return nil, err
+		status = ClusterOffline
+	}
+
+	m.Lock()
+	changed := c.Status != status
+	c.Status = status
+	handlers := m.statusHandlers
+	m.Unlock()
+
+	if !changed {
+		return
+	}
+	klog.Infof("cluster: %s status changed to %s", c.Name, status)
+	for _, h := range handlers {
+		h(c.Name, status, err)
+	}
+}
+
+// AddNewClusters returns the cached cluster, offline or not, or adds the cluster of the kubeconfig. The client
+// of the cached cluster is rebuilt if its kubeconfig changed, e.g. the credentials or the apiserver endpoint.
+// The informers of the cluster are started on the first read of its client.
+func (m *ClusterManager) AddNewClusters(name string, kubeconfig string) (*Cluster, error) {
+	m.RLock()
+	var cached *Cluster
+	if index, ok := m.GetClusterIndex(name); ok {
+		cached = m.clusters[index]
+	}
+	m.RUnlock()
+	if cached != nil {
+		if !cached.KubeconfigChanged(kubeconfig) {
+			return cached, nil
+		}
+		klog.Infof("cluster: %s kubeconfig changed, rebuild the cluster client", name)
+		m.Delete(name)
 	}
 
-	err = m.preStart(nc)
+	nc, err := NewCluster(name, []byte(kubeconfig), logger)
 	if err != nil {
-		klog.Error("cluster client preStart error:%s", nc.Name)
+		klog.Errorf("cluster: %s new client err: %v", name, err)
 		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
-	defer cancel()
-
-	nc.StartCache(ctx.Done())
 	err = m.Add(nc)
 	if err != nil {
 		klog.Errorf("cluster: %s add err: %+v", name, err)
@@ -218,10 +294,29 @@ func (m *ClusterManager) AddNewClusters(name string, kubeconfig string) (*Cluste
 	return nc, nil
 }
 
-// Start timer check cluster health
+// CheckCaches returns an error naming the clusters whose started informer caches are not synced within the
+// timeout, the offline clusters are skipped as they are not served.
+func (m *ClusterManager) CheckCaches(timeout time.Duration) error {
+	stop := make(chan struct{})
+	timer := time.AfterFunc(timeout, func() { close(stop) })
+	defer timer.Stop()
+
+	unsynced := []string{}
+	for _, c := range m.GetAll() {
+		if !c.cacheSynced(stop) {
+			unsynced = append(unsynced, c.Name)
+		}
+	}
+	if len(unsynced) > 0 {
+		return fmt.Errorf("informers of clusters: %s not synced in %s", strings.Join(unsynced, ", "), timeout)
+	}
+	return nil
+}
+
+// Start probes the clients of the clusters every ProbePeriod until stopped, it's a runnable of the manager.
 func (m *ClusterManager) Start(stopCh <-chan struct{}) error {
 	klog.V(4).Info("multi cluster manager start check loop ... ")
-	wait.Until(m.cluterCheck, time.Minute, stopCh)
+	wait.Until(m.cluterCheck, ProbePeriod, stopCh)
 
 	klog.V(4).Info("multi cluster manager stoped ... ")
 	m.Stop()
@@ -238,35 +333,35 @@ func (m *ClusterManager) Stop() {
 }
 
 // 增加对象索引
-func (r *ClusterManager) preStart(cls *Cluster) error {
-	if err := cls.Mgr.GetFieldIndexer().IndexField(context.TODO(), &corev1.Pod{}, "spec.nodeName", func(object runtime.Object) []string {
+func (c *Cluster) preStart() error {
+	if err := c.Mgr.GetFieldIndexer().IndexField(context.TODO(), &corev1.Pod{}, "spec.nodeName", func(object runtime.Object) []string {
 		pod := object.(*corev1.Pod)
 		return []string{pod.Status.HostIP}
 	}); err != nil {
-		klog.Warning("cluster: %#v add field index pod status.hostIP, err: %#v", cls.Name, err)
+		klog.Warningf("cluster: %#v add field index pod status.hostIP, err: %#v", c.Name, err)
 		return errors.New("cluster add field index pod spec.nodeName failed")
 	} else {
-		klog.Warning("########### cluster: %#v add field index pod status.hostIP, successfully ##################", cls.Name)
+		klog.Warningf("########### cluster: %#v add field index pod status.hostIP, successfully ##################", c.Name)
 	}
 
-	if err := cls.Mgr.GetFieldIndexer().IndexField(context.TODO(), &corev1.Event{}, "source.host", func(object runtime.Object) []string {
+	if err := c.Mgr.GetFieldIndexer().IndexField(context.TODO(), &corev1.Event{}, "source.host", func(object runtime.Object) []string {
 		event := object.(*corev1.Event)
 		return []string{event.Source.Host}
 	}); err != nil {
-		klog.Warning("cluster: %#v add field index pod source.host, err: %#v", cls.Name, err)
+		klog.Warningf("cluster: %#v add field index pod source.host, err: %#v", c.Name, err)
 		return errors.New("cluster add field index pod source.host failed")
 	} else {
-		klog.Warning("########### cluster: %#v  add field index pod source.host, successfully ##################", cls.Name)
+		klog.Warningf("########### cluster: %#v  add field index pod source.host, successfully ##################", c.Name)
 	}
 
-	if err := cls.Mgr.GetFieldIndexer().IndexField(context.TODO(), &corev1.Event{}, "involvedObject.kind", func(object runtime.Object) []string {
+	if err := c.Mgr.GetFieldIndexer().IndexField(context.TODO(), &corev1.Event{}, "involvedObject.kind", func(object runtime.Object) []string {
 		event := object.(*corev1.Event)
 		return []string{event.InvolvedObject.Kind}
 	}); err != nil {
-		klog.Warning("cluster: %#v add field index pod involvedObject.kind, err: %#v", cls.Name, err)
+		klog.Warningf("cluster: %#v add field index pod involvedObject.kind, err: %#v", c.Name, err)
 		return errors.New("cluster add field index pod involvedObject.kind failed")
 	} else {
-		klog.Warning("########### cluster: %#v  add field index pod involvedObject.kind, successfully ##################", cls.Name)
+		klog.Warningf("########### cluster: %#v  add field index pod involvedObject.kind, successfully ##################", c.Name)
 	}
 	return nil
 }
@@ -0,0 +1,205 @@
+package k8smanager
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+)
+
+func TestSetStatus(t *testing.T) {
+	m := &ClusterManager{}
+	c := &Cluster{Name: "c1"}
+	if err := m.Add(c); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	var changes []ClusterStatusType
+	m.OnStatusChange(func(name string, status ClusterStatusType, err error) {
+		changes = append(changes, status)
+	})
+
+	probeErr := errors.New("connection refused")
+	m.setStatus(c, nil)
+	m.setStatus(c, probeErr)
+	m.setStatus(c, probeErr)
+	if _, err := m.Get("c1"); err == nil {
+		t.Errorf("Get() of the offline cluster error = nil, want error")
+	}
+	if err := m.Add(&Cluster{Name: "c1"}); err == nil {
+		t.Errorf("Add() of the offline cluster error = nil, want error")
+	}
+	m.setStatus(c, nil)
+	if _, err := m.Get("c1"); err != nil {
+		t.Errorf("Get() of the ready cluster error = %v", err)
+	}
+
+	want := []ClusterStatusType{ClusterReady, ClusterOffline, ClusterReady}
+	if len(changes) != len(want) {
+		t.Fatalf("status changes = %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("status changes = %v, want %v", changes, want)
+		}
+	}
+}
+
+func TestStopIdle(t *testing.T) {
+	newCluster := func(lastUsed time.Time) *Cluster {
+		return &Cluster{
+			Name:            "c1",
+			Cache:           &informertest.FakeInformers{},
+			internalStopper: make(chan struct{}),
+			Started:         true,
+			lastUsed:        lastUsed,
+		}
+	}
+
+	c := newCluster(time.Now())
+	if c.stopIdle(time.Minute) {
+		t.Errorf("stopIdle() of the recently read cluster = true, want false")
+	}
+	if c.stopIdle(0) {
+		t.Errorf("stopIdle() without idle timeout = true, want false")
+	}
+
+	c = newCluster(time.Now().Add(-time.Hour))
+	stopper := c.internalStopper
+	if !c.stopIdle(time.Minute) {
+		t.Fatalf("stopIdle() of the idle cluster = false, want true")
+	}
+	select {
+	case <-stopper:
+	default:
+		t.Errorf("the informers of the idle cluster are not stopped")
+	}
+	if c.Started || c.Cache != nil {
+		t.Errorf("the idle cluster is started: %v, cache: %v, want them reset", c.Started, c.Cache)
+	}
+	if !c.cacheSynced(nil) {
+		t.Errorf("cacheSynced() of the stopped cluster = false, want true")
+	}
+
+	c.Stop()
+	if _, err := c.acquire(context.Background()); err == nil {
+		t.Errorf("acquire() of the removed cluster error = nil, want error")
+	}
+}
+
+// blockingInformers blocks the sync until released or stopped.
+type blockingInformers struct {
+	informertest.FakeInformers
+	waits   int32
+	waiting chan struct{}
+	release chan bool
+}
+
+func (b *blockingInformers) WaitForCacheSync(stop <-chan struct{}) bool {
+	if atomic.AddInt32(&b.waits, 1) == 1 {
+		close(b.waiting)
+	}
+	select {
+	case synced := <-b.release:
+		return synced
+	case <-stop:
+		return false
+	}
+}
+
+// doneContext signals once its Done is called, i.e. the read waits for the sync of another.
+type doneContext struct {
+	context.Context
+	called chan struct{}
+}
+
+func (d *doneContext) Done() <-chan struct{} {
+	close(d.called)
+	return d.Context.Done()
+}
+
+func TestAcquireUnlocked(t *testing.T) {
+	informers := &blockingInformers{waiting: make(chan struct{}), release: make(chan bool)}
+	c := &Cluster{Name: "c1", Cache: informers, internalStopper: make(chan struct{})}
+
+	errs := make(chan error, 2)
+	acquire := func(ctx context.Context) {
+		_, err := c.acquire(ctx)
+		errs <- err
+	}
+	go acquire(context.Background())
+	<-informers.waiting
+	waiter := &doneContext{Context: context.Background(), called: make(chan struct{})}
+	go acquire(waiter)
+	<-waiter.called
+
+	// the readers of the cluster are not blocked by the sync
+	done := make(chan struct{})
+	go func() {
+		c.NodeCount()
+		c.stopIdle(time.Nanosecond)
+		c.cacheSynced(nil)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the readers of the cluster are blocked by the sync")
+	}
+
+	informers.release <- false
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err == nil {
+			t.Errorf("acquire() of the unsynced cluster error = nil, want error")
+		}
+	}
+	if n := atomic.LoadInt32(&informers.waits); n != 1 {
+		t.Errorf("the informers are synced %d times, want the concurrent reads to share one", n)
+	}
+	if c.Cache != nil || c.Started {
+		t.Errorf("the unsynced cluster is started: %v, cache: %v, want them reset", c.Started, c.Cache)
+	}
+
+	informers = &blockingInformers{waiting: make(chan struct{}), release: make(chan bool)}
+	c = &Cluster{Name: "c1", Cache: informers, internalStopper: make(chan struct{})}
+	go acquire(context.Background())
+	<-informers.waiting
+	c.Stop()
+	if err := <-errs; err == nil {
+		t.Errorf("acquire() of the cluster removed while syncing error = nil, want error")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	m := &ClusterManager{}
+	c := &Cluster{
+		Name:            "c1",
+		Cache:           &informertest.FakeInformers{},
+		internalStopper: make(chan struct{}),
+		Started:         true,
+	}
+	stopper := c.internalStopper
+	if err := m.Add(c); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	if err := m.Delete("c1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	select {
+	case <-stopper:
+	default:
+		t.Errorf("the informers of the deleted cluster are not stopped")
+	}
+	if _, err := m.Get("c1"); err == nil {
+		t.Errorf("Get() of the deleted cluster error = nil, want error")
+	}
+	if err := m.Probe("c1"); err != ErrClusterNotFound {
+		t.Errorf("Probe() of the deleted cluster error = %v, want %v", err, ErrClusterNotFound)
+	}
+	if err := m.Delete("c1"); err != nil {
+		t.Errorf("Delete() of the deleted cluster error = %v", err)
+	}
+}
@@ -44,7 +44,7 @@ func (m *ClusterManager) UpdateMonitor(name string, monitor *prometheus.Promethe
 		klog.Infof("the monitor update %s has been updated.", name)
 		return nil
 	}
-	klog.Error("monitor %s,not found.", name)
+	klog.Errorf("monitor %s,not found.", name)
 	return errors.New("monitor not found.")
 }
 
@@ -0,0 +1,90 @@
+package k8smanager
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+)
+
+// nodeCounter keeps the names of the nodes of a cluster from the events of its node informer, the cluster
+// lists read the count instead of listing the nodes of every cluster.
+type nodeCounter struct {
+	mu     sync.RWMutex
+	nodes  map[string]struct{}
+	synced func() bool
+}
+
+var _ toolscache.ResourceEventHandler = &nodeCounter{}
+
+func newNodeCounter(synced func() bool) *nodeCounter {
+	return &nodeCounter{nodes: make(map[string]struct{}), synced: synced}
+}
+
+// Count returns the number of the nodes, false until the informer is synced.
+func (n *nodeCounter) Count() (int, bool) {
+	if n.synced != nil && !n.synced() {
+		return 0, false
+	}
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return len(n.nodes), true
+}
+
+// OnAdd ...
+func (n *nodeCounter) OnAdd(obj interface{}) {
+	n.set(obj, true)
+}
+
+// OnUpdate ...
+func (n *nodeCounter) OnUpdate(oldObj, newObj interface{}) {
+	n.set(newObj, true)
+}
+
+// OnDelete ...
+func (n *nodeCounter) OnDelete(obj interface{}) {
+	n.set(obj, false)
+}
+
+func (n *nodeCounter) set(obj interface{}, exists bool) {
+	// the deletions missed by the informer come as tombstones
+	name, err := toolscache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		klog.Warningf("count node %#v error: %v", obj, err)
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if exists {
+		n.nodes[name] = struct{}{}
+	} else {
+		delete(n.nodes, name)
+	}
+}
+
+// countNodes registers the handler of the node informer counting the nodes, it's started with the cache.
+func (c *Cluster) countNodes() error {
+	informer, err := c.Cache.GetInformer(context.TODO(), &corev1.Node{})
+	if err != nil {
+		return errors.Wrapf(err, "get node informer of cluster: %s", c.Name)
+	}
+	counter := newNodeCounter(informer.HasSynced)
+	informer.AddEventHandler(counter)
+	c.nodes = counter
+	return nil
+}
+
+// NodeCount returns the number of the nodes of the cluster read from its informer cache, false if the
+// nodes are not counted or the informer is not synced yet.
+func (c *Cluster) NodeCount() (int, bool) {
+	c.cacheMu.Lock()
+	nodes := c.nodes
+	c.cacheMu.Unlock()
+	if nodes == nil {
+		return 0, false
+	}
+	return nodes.Count()
+}
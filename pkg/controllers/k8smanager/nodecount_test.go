@@ -0,0 +1,33 @@
+package k8smanager
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+func TestNodeCounter(t *testing.T) {
+	synced := false
+	n := newNodeCounter(func() bool { return synced })
+	node := func(name string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	}
+
+	n.OnAdd(node("n1"))
+	n.OnAdd(node("n2"))
+	if _, ok := n.Count(); ok {
+		t.Errorf("Count() before synced = ok, want not ok")
+	}
+	synced = true
+
+	n.OnUpdate(node("n1"), node("n1"))
+	n.OnAdd(node("n3"))
+	n.OnDelete(node("n2"))
+	n.OnDelete(toolscache.DeletedFinalStateUnknown{Key: "n3", Obj: node("n3")})
+	n.OnDelete(node("missing"))
+	if count, ok := n.Count(); !ok || count != 1 {
+		t.Errorf("Count() = %d, %v, want 1, true", count, ok)
+	}
+}
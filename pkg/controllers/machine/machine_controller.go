@@ -28,22 +28,27 @@ import (
 
 	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
 	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	credentialutil "github.com/gostship/kunkka/pkg/credential"
 	"github.com/gostship/kunkka/pkg/gmanager"
+	"github.com/gostship/kunkka/pkg/option"
+	machineprovider "github.com/gostship/kunkka/pkg/provider/machine"
 	"github.com/gostship/kunkka/pkg/provider/phases/clean"
+	"github.com/gostship/kunkka/pkg/sharding"
+	"github.com/gostship/kunkka/pkg/timeouts"
+	"github.com/gostship/kunkka/pkg/util/apiclient"
+	"github.com/gostship/kunkka/pkg/util/parallel"
 	"github.com/pkg/errors"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-const (
-	machineMaxReconciles = 2
-)
-
 // machineReconciler reconciles a machine object
 type machineReconciler struct {
 	client.Client
@@ -51,6 +56,14 @@ type machineReconciler struct {
 	Mgr    manager.Manager
 	Scheme *runtime.Scheme
 	*gmanager.GManager
+
+	// slots bounds the machines of each cluster provisioned at a time, parallelism is the default bound
+	slots       *parallel.Limiter
+	parallelism int
+	// shard filters the machines of the clusters of the shard of the replica
+	shard *sharding.Sharder
+	// requeue the requeue intervals of the machines
+	requeue *option.RequeueOption
 }
 
 type manchineContext struct {
@@ -61,16 +74,20 @@ type manchineContext struct {
 	*devopsv1.ClusterCredential
 }
 
-func Add(mgr manager.Manager, pMgr *gmanager.GManager) error {
+func Add(mgr manager.Manager, pMgr *gmanager.GManager, maxConcurrentReconciles, parallelism int, requeue *option.RequeueOption, shard *sharding.Sharder) error {
 	reconciler := &machineReconciler{
-		Client:   mgr.GetClient(),
-		Mgr:      mgr,
-		Log:      ctrl.Log.WithName("controllers").WithName("machine"),
-		Scheme:   mgr.GetScheme(),
-		GManager: pMgr,
+		Client:      mgr.GetClient(),
+		Mgr:         mgr,
+		Log:         ctrl.Log.WithName("controllers").WithName("machine"),
+		Scheme:      mgr.GetScheme(),
+		GManager:    pMgr,
+		slots:       parallel.NewLimiter(),
+		parallelism: parallelism,
+		shard:       shard,
+		requeue:     requeue,
 	}
 
-	err := reconciler.SetupWithManager(mgr)
+	err := reconciler.SetupWithManager(mgr, maxConcurrentReconciles)
 	if err != nil {
 		return errors.Wrapf(err, "unable to create machine controller")
 	}
@@ -78,10 +95,10 @@ func Add(mgr manager.Manager, pMgr *gmanager.GManager) error {
 	return nil
 }
 
-func (r *machineReconciler) SetupWithManager(mgr ctrl.Manager) error {
+func (r *machineReconciler) SetupWithManager(mgr ctrl.Manager, maxConcurrentReconciles int) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&devopsv1.Machine{}).
-		WithOptions(controller.Options{MaxConcurrentReconciles: machineMaxReconciles}).
+		For(&devopsv1.Machine{}, builder.WithPredicates(r.shard.MachinePredicate())).
+		WithOptions(controller.Options{MaxConcurrentReconciles: maxConcurrentReconciles}).
 		Complete(r)
 }
 
@@ -89,6 +106,10 @@ func (r *machineReconciler) SetupWithManager(mgr ctrl.Manager) error {
 // +kubebuilder:rbac:groups=devops.gostship.io,resources=machines/status,verbs=get;update;patch
 
 func (r *machineReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	return r.requeue.Result(r.doReconcile(req))
+}
+
+func (r *machineReconciler) doReconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
 	logger := r.Log.WithValues("machine", req.NamespacedName.String())
 
@@ -118,7 +139,14 @@ func (r *machineReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return reconcile.Result{}, err
 	}
 
+	if !r.shard.OwnsMachine(m, m.Spec.ClusterName) {
+		r.release(m)
+		logger.V(4).Info("machine is of the cluster of another shard")
+		return reconcile.Result{}, nil
+	}
+
 	if !m.ObjectMeta.DeletionTimestamp.IsZero() {
+		r.release(m)
 		err := r.cleanMachinesResources(ctx, logger, m)
 		if err != nil {
 			logger.Error(err, "failed to clean machine resources")
@@ -144,9 +172,17 @@ func (r *machineReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 
 	if m.Spec.Pause == true {
 		logger.Info("machine is Pause")
+		r.release(m)
 		return reconcile.Result{}, nil
 	}
 
+	if _, ok := m.Annotations[constants.PhaseRetry]; ok {
+		return ctrl.Result{}, r.retryPhases(ctx, m)
+	}
+	if _, ok := m.Annotations[constants.RerunPhases]; ok {
+		return ctrl.Result{}, r.rerunPhases(ctx, m)
+	}
+
 	if len(string(m.Status.Phase)) == 0 {
 		m.Status.Phase = devopsv1.MachineInitializing
 		err = r.Client.Status().Update(ctx, m)
@@ -174,6 +210,26 @@ func (r *machineReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			RequeueAfter: 30 * time.Second,
 		}, nil
 	}
+	if m.Status.Phase == devopsv1.MachineRunning {
+		err = r.maintain(ctx, logger, cluster, m)
+		if err != nil {
+			logger.Error(err, "failed to apply the maintenance request")
+			return reconcile.Result{}, err
+		}
+	}
+	// the machines of the cluster beyond its parallelism wait for the provisioning ones
+	if m.Status.Phase == devopsv1.MachineInitializing && !r.acquire(cluster, m) {
+		logger.V(4).Info("wait for a provisioning slot", "provisioning", r.slots.Holding(slotKey(m)))
+		return ctrl.Result{RequeueAfter: slotRetryInterval}, nil
+	}
+	cluster.Default()
+	m.Default()
+	cluster.DefaultBastions(m.Spec.Machine)
+	err = credentialutil.LoadSSH(ctx, r.Client, m.Namespace, m.Spec.Machine)
+	if err != nil {
+		logger.Error(err, "failed to load ssh credential")
+		return reconcile.Result{}, err
+	}
 
 	credential := &devopsv1.ClusterCredential{}
 	err = r.Client.Get(ctx, types.NamespacedName{Name: m.Spec.ClusterName, Namespace: m.Namespace}, credential)
@@ -186,6 +242,11 @@ func (r *machineReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		logger.Error(err, "failed to get ClusterCredential")
 		return reconcile.Result{}, err
 	}
+	err = credentialutil.Load(ctx, r.Client, credential)
+	if err != nil {
+		logger.Error(err, "failed to load ClusterCredential")
+		return reconcile.Result{}, err
+	}
 
 	klog.Infof("name: %s", cluster.Name)
 
@@ -196,37 +257,138 @@ func (r *machineReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		Cluster:           cluster,
 		ClusterCredential: credential,
 	})
-	return ctrl.Result{}, nil
+	if m.Status.Phase != devopsv1.MachineInitializing {
+		r.release(m)
+	}
+
+	if m.Status.Phase == devopsv1.MachineRunning {
+		// recheck os drift periodically
+		return ctrl.Result{RequeueAfter: r.requeue.After(0)}, nil
+	}
+	// the status updates of the failed handlers requeue at once, the handlers back off until RequeueAfter
+	return ctrl.Result{RequeueAfter: machineprovider.RetryAfter(cluster, m)}, nil
 }
 
+// cleanMachinesResources is the removal handler of the machine finalizer, it drains and deletes the node of the
+// machine from the member cluster, then cleans the machine over ssh. The force deletion skips the steps failed.
 func (r *machineReconciler) cleanMachinesResources(ctx context.Context, logger logr.Logger, m *devopsv1.Machine) error {
-	clusterCtx, err := r.ClusterManager.Get(m.Name)
-	if err == nil {
-		logger.Info("start delete node")
-		clusterCtx.KubeCli.CoreV1().Nodes().Delete(ctx, m.Name, metav1.DeleteOptions{})
+	force := m.Annotations[constants.ForceDeletion] == "true"
+
+	// the cluster may be deleted before the machine, the machine is reached by its own bastions then
+	cluster := &devopsv1.Cluster{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: m.Spec.ClusterName, Namespace: m.Namespace}, cluster)
+	if err != nil && !apierrors.IsNotFound(err) {
+		logger.Error(err, "failed to get cluster")
+		return err
+	}
+	if err != nil {
+		cluster = nil
+	} else {
+		cluster.DefaultBastions(m.Spec.Machine)
 	}
 
-	ssh, err := m.Spec.Machine.SSH()
+	// the nodes of the cluster deleted are gone with it
+	if cluster != nil && cluster.DeletionTimestamp.IsZero() {
+		err = r.removeNode(ctx, logger, cluster, m)
+		if err != nil {
+			if !force {
+				logger.Error(err, "failed to remove node")
+				return err
+			}
+			logger.Info("force deletion, skip removing the node", "err", err.Error())
+		}
+	}
+
+	m.AuditPhase("CleanMachine", "")
+	err = r.cleanNode(ctx, logger, cluster, m)
 	if err != nil {
-		logger.Error(err, "failed new ssh")
-		return err
+		// the node which can't be cleaned is skipped by the force deletion
+		if !force {
+			return err
+		}
+		logger.Info("force deletion, skip the node")
 	}
 
-	logger.Info("start clean node")
+	logger.Info("start clean machine finalizers")
+	m.ObjectMeta.Finalizers = constants.RemoveString(m.ObjectMeta.Finalizers, constants.FinalizersMachine)
+	return r.Client.Update(ctx, m)
+}
 
-	err = clean.DleNode(ssh, m.Name)
+// removeNode cordons and drains the node of the machine, then deletes it from the member cluster.
+func (r *machineReconciler) removeNode(ctx context.Context, logger logr.Logger, cluster *devopsv1.Cluster, m *devopsv1.Machine) error {
+	clusterCtx, err := r.ClusterManager.Get(cluster.Name)
 	if err != nil {
-		logger.Error(err, "failed delete machine node!")
-		return err
+		return errors.Wrapf(err, "get client of cluster: %s", cluster.Name)
+	}
+	kubeCli := clusterCtx.KubeCli
+
+	_, err = kubeCli.CoreV1().Nodes().Get(ctx, m.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		logger.Info("node not found, skip removing it")
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "get node: %s", m.Name)
 	}
 
-	err = clean.CleanNode(ssh)
+	logger.Info("start drain node")
+	err = apiclient.CordonNode(ctx, kubeCli, m.Name)
+	if err != nil {
+		return err
+	}
+	evicted, err := apiclient.DrainNode(ctx, kubeCli, m.Name, timeouts.Get(cluster, timeouts.NodeDrain).Timeout.Duration)
 	if err != nil {
-		logger.Error(err, "failed clean machine node")
 		return err
 	}
 
-	logger.Info("start clean machine finalizers")
-	m.ObjectMeta.Finalizers = constants.RemoveString(m.ObjectMeta.Finalizers, constants.FinalizersMachine)
-	return r.Client.Update(ctx, m)
+	logger.Info("start delete node", "evicted", len(evicted))
+	err = kubeCli.CoreV1().Nodes().Delete(ctx, m.Name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "delete node: %s", m.Name)
+	}
+	return nil
+}
+
+// cleanNode resets the machine and runs the delete handlers of the provider of the cluster over ssh, cluster is
+// nil if it's deleted.
+func (r *machineReconciler) cleanNode(ctx context.Context, logger logr.Logger, cluster *devopsv1.Cluster, m *devopsv1.Machine) error {
+	// the instance of the machine never booted has nothing to clean over ssh
+	if m.Spec.Machine.IP != "" {
+		err := credentialutil.LoadSSH(ctx, r.Client, m.Namespace, m.Spec.Machine)
+		if err != nil {
+			logger.Error(err, "failed to load ssh credential")
+			return err
+		}
+
+		ssh, err := m.Spec.Machine.SSH()
+		if err != nil {
+			logger.Error(err, "failed new ssh")
+			return err
+		}
+
+		logger.Info("start clean node")
+		err = clean.CleanNode(ssh)
+		if err != nil {
+			logger.Error(err, "failed clean machine node")
+			return err
+		}
+	}
+
+	if cluster == nil {
+		return nil
+	}
+	p, err := r.MpManager.GetProvider(cluster.Spec.Type)
+	if err != nil {
+		return err
+	}
+	err = p.OnDelete(ctx, m, &common.Cluster{
+		Cluster:        cluster,
+		Client:         r.Client,
+		ClusterManager: r.ClusterManager,
+	})
+	if err != nil {
+		logger.Error(err, "failed to run the delete handlers")
+		return err
+	}
+	return nil
 }
@@ -19,17 +19,32 @@ package machine
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"strconv"
 
 	"time"
 
+	"github.com/go-logr/logr"
 	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
 	"github.com/gostship/kunkka/pkg/controllers/common"
+	machineprovider "github.com/gostship/kunkka/pkg/provider/machine"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
 	machineClientRetryCount    = 5
 	machineClientRetryInterval = 5 * time.Second
 
+	// slotRetryInterval the interval the machines waiting for a provisioning slot are requeued at
+	slotRetryInterval = 10 * time.Second
+
 	reasonFailedInit   = "FailedInit"
 	reasonFailedUpdate = "FailedUpdate"
 )
@@ -50,7 +65,7 @@ func (r *machineReconciler) onCreate(ctx context.Context, rc *manchineContext) e
 	if err != nil {
 		rc.Machine.Status.Message = err.Error()
 		rc.Machine.Status.Reason = reasonFailedInit
-		r.Client.Status().Update(ctx, rc.Machine)
+		r.updateStatus(ctx, rc.Machine)
 		return err
 	}
 
@@ -58,20 +73,38 @@ func (r *machineReconciler) onCreate(ctx context.Context, rc *manchineContext) e
 	if condition.Status == devopsv1.ConditionFalse { // means current condition run into error
 		rc.Machine.Status.Message = condition.Message
 		rc.Machine.Status.Reason = condition.Reason
-		r.Client.Status().Update(ctx, rc.Machine)
+		r.updateStatus(ctx, rc.Machine)
 		return fmt.Errorf("Provider.OnCreate.%s [Failed] reason: %s message: %s",
 			condition.Type, condition.Reason, condition.Message)
 	}
 
 	rc.Machine.Status.Message = ""
 	rc.Machine.Status.Reason = ""
-	err = r.Client.Status().Update(ctx, rc.Machine)
+	err = r.updateStatus(ctx, rc.Machine)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// updateStatus writes the status of the machine, it's written again over the latest machine on the conflicts,
+// so the checkpoints of the phases completed are not lost and the phases are not run again.
+func (r *machineReconciler) updateStatus(ctx context.Context, m *devopsv1.Machine) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		err := r.Client.Status().Update(ctx, m)
+		if !apierrors.IsConflict(err) {
+			return err
+		}
+
+		latest := &devopsv1.Machine{}
+		if getErr := r.Client.Get(ctx, types.NamespacedName{Name: m.Name, Namespace: m.Namespace}, latest); getErr != nil {
+			return getErr
+		}
+		m.ResourceVersion = latest.ResourceVersion
+		return err
+	})
+}
+
 func (r *machineReconciler) onUpdate(ctx context.Context, rc *manchineContext) error {
 	p, err := r.MpManager.GetProvider(rc.Cluster.Spec.Type)
 	if err != nil {
@@ -85,23 +118,114 @@ func (r *machineReconciler) onUpdate(ctx context.Context, rc *manchineContext) e
 		ClusterManager:    r.ClusterManager,
 	}
 
+	oldStatus := rc.Machine.Status.DeepCopy()
 	err = p.OnUpdate(ctx, rc.Machine, clusterWrapper)
+	if !reflect.DeepEqual(oldStatus, &rc.Machine.Status) {
+		r.updateStatus(ctx, rc.Machine)
+	}
+
+	// only the message and the reason are patched, the conditions and the checkpoints of the cluster are
+	// written by the cluster controller
+	patch := client.MergeFrom(rc.Cluster.DeepCopy())
 	if err != nil {
-		clusterWrapper.Cluster.Status.Message = err.Error()
-		clusterWrapper.Cluster.Status.Reason = reasonFailedUpdate
-		r.Client.Status().Update(ctx, rc.Cluster)
+		rc.Cluster.Status.Message = err.Error()
+		rc.Cluster.Status.Reason = reasonFailedUpdate
+		r.Client.Status().Patch(ctx, rc.Cluster, patch)
 		return err
 	}
-	clusterWrapper.Cluster.Status.Message = ""
-	clusterWrapper.Cluster.Status.Reason = ""
-	r.Client.Status().Update(ctx, clusterWrapper.ClusterCredential)
-	r.Client.Status().Update(ctx, clusterWrapper.Cluster)
+	rc.Cluster.Status.Message = ""
+	rc.Cluster.Status.Reason = ""
+	r.Client.Status().Patch(ctx, rc.Cluster, patch)
 	return nil
 }
 
+// retryPhases resets the attempts of the failed conditions of the machine, the failed machine is initialized
+// again from its failed condition, then removes the phaseRetry annotation.
+func (r *machineReconciler) retryPhases(ctx context.Context, m *devopsv1.Machine) error {
+	klog.Infof("machine: %s retry the failed phases", m.Name)
+	for i := range m.Status.Conditions {
+		if m.Status.Conditions[i].Status == devopsv1.ConditionFalse {
+			m.Status.Conditions[i].Attempts = 0
+			m.Status.Conditions[i].Reason = reasonFailedInit
+		}
+	}
+	if m.Status.Phase == devopsv1.MachineFailed {
+		m.Status.Phase = devopsv1.MachineInitializing
+	}
+	err := r.Client.Status().Update(ctx, m)
+	if err != nil {
+		return err
+	}
+
+	delete(m.Annotations, constants.PhaseRetry)
+	return r.Client.Update(ctx, m)
+}
+
+// acquire takes a provisioning slot of the cluster for the machine, the slot is held until the machine is
+// running, failed, paused or deleted. The parallelism of the cluster is overridden by its annotation.
+func (r *machineReconciler) acquire(cluster *devopsv1.Cluster, m *devopsv1.Machine) bool {
+	limit := r.parallelism
+	if v, ok := cluster.Annotations[constants.MachineParallelism]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			klog.Warningf("cluster: %s invalid %s: %q, use %d", cluster.Name, constants.MachineParallelism, v, limit)
+		} else {
+			limit = n
+		}
+	}
+	return r.slots.Acquire(slotKey(m), m.Name, limit)
+}
+
+// release frees the provisioning slot held by the machine.
+func (r *machineReconciler) release(m *devopsv1.Machine) {
+	r.slots.Release(slotKey(m), m.Name)
+}
+
+func slotKey(m *devopsv1.Machine) string {
+	return m.Namespace + "/" + m.Spec.ClusterName
+}
+
+// rerunPhases runs the phases of the rerun-phases annotation again, their checkpoints are removed and their
+// conditions reset, the provisioning machine resumes from the earliest one and skips the later phases completed.
+// The running machines are left as is, then removes the annotation.
+func (r *machineReconciler) rerunPhases(ctx context.Context, m *devopsv1.Machine) error {
+	phases := common.SplitPhases(m.Annotations[constants.RerunPhases])
+	if m.Status.Phase == devopsv1.MachineRunning {
+		klog.Warningf("machine: %s is running, ignore %s: %v", m.Name, constants.RerunPhases, phases)
+	} else {
+		klog.Infof("machine: %s rerun the phases: %v", m.Name, phases)
+		for i := range m.Status.Conditions {
+			condition := &m.Status.Conditions[i]
+			// the machines provisioned before the checkpoints skip their completed phases as well
+			if condition.Status == devopsv1.ConditionTrue {
+				m.CompletePhase(condition.Type)
+			}
+			if constants.ContainsString(phases, condition.Type) {
+				condition.Status = devopsv1.ConditionUnknown
+				condition.Reason = machineprovider.ReasonWaiting
+				condition.Message = "waiting rerun"
+				condition.Attempts = 0
+			}
+		}
+		m.ForgetPhases(phases...)
+		if m.Status.Phase == devopsv1.MachineFailed {
+			m.Status.Phase = devopsv1.MachineInitializing
+		}
+		err := r.Client.Status().Update(ctx, m)
+		if err != nil {
+			return err
+		}
+	}
+
+	delete(m.Annotations, constants.RerunPhases)
+	return r.Client.Update(ctx, m)
+}
+
 func (r *machineReconciler) reconcile(ctx context.Context, rc *manchineContext) error {
 	var err error
 	switch rc.Machine.Status.Phase {
+	case devopsv1.MachineFailed:
+		rc.Logger.Info("machine is failed, annotate it with " + constants.PhaseRetry + " to retry")
 	case devopsv1.MachineInitializing:
 		rc.Logger.Info("onCreate")
 		err = r.onCreate(ctx, rc)
@@ -114,3 +238,40 @@ func (r *machineReconciler) reconcile(ctx context.Context, rc *manchineContext)
 
 	return err
 }
+
+// maintain cordons the node of the machine while constants.MachineMaintenance is requested and uncordons it once
+// the request is removed, the node is marked so that the nodes cordoned by the admins are left cordoned.
+func (r *machineReconciler) maintain(ctx context.Context, logger logr.Logger, cluster *devopsv1.Cluster, m *devopsv1.Machine) error {
+	reason := m.Annotations[constants.MachineMaintenance]
+	clusterCtx, err := r.ClusterManager.Get(cluster.Name)
+	if err != nil {
+		if reason == "" {
+			return nil
+		}
+		return errors.Wrapf(err, "get client of cluster: %s", cluster.Name)
+	}
+
+	nodes := clusterCtx.KubeCli.CoreV1().Nodes()
+	node, err := nodes.Get(ctx, m.Spec.Machine.IP, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "get node: %s", m.Spec.Machine.IP)
+	}
+
+	cordoned := node.Annotations[constants.NodeMaintenanceCordoned] != ""
+	var patch string
+	switch {
+	case reason != "" && !cordoned && !node.Spec.Unschedulable:
+		patch = fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}},"spec":{"unschedulable":true}}`, constants.NodeMaintenanceCordoned, reason)
+	case reason == "" && cordoned:
+		patch = fmt.Sprintf(`{"metadata":{"annotations":{%q:null}},"spec":{"unschedulable":false}}`, constants.NodeMaintenanceCordoned)
+	default:
+		return nil
+	}
+
+	logger.Info("apply maintenance request", "node", node.Name, "reason", reason)
+	_, err = nodes.Patch(ctx, node.Name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+	return errors.Wrapf(err, "patch node: %s", node.Name)
+}
@@ -0,0 +1,447 @@
+/*
+Copyright 2020 dke.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pullsecret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/controllers/k8smanager"
+	"github.com/gostship/kunkka/pkg/gmanager"
+	"github.com/gostship/kunkka/pkg/sharding"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	defaultNamespace      = "default"
+	defaultServiceAccount = "default"
+)
+
+var (
+	// ResyncPeriod distributes the secrets again, so new clusters and namespaces are covered
+	ResyncPeriod = 10 * time.Minute
+)
+
+// pullSecretReconciler distributes the image pull secrets labeled with constants.ImagePullSecretLabel
+// to the member clusters and adds them to the default ServiceAccount.
+type pullSecretReconciler struct {
+	client.Client
+	*gmanager.GManager
+	Log logr.Logger
+	// finalizer the finalizer of the shard of the replica, each shard cleans its own clusters
+	finalizer string
+	// applied the annotation of the targets applied by the shard
+	applied string
+}
+
+func Add(mgr manager.Manager, pMgr *gmanager.GManager, shard *sharding.Sharder) error {
+	reconciler := &pullSecretReconciler{
+		Client:    mgr.GetClient(),
+		Log:       ctrl.Log.WithName("controllers").WithName("pullsecret"),
+		GManager:  pMgr,
+		finalizer: shard.Finalizer(constants.FinalizersImagePullSecret),
+		applied:   shard.Finalizer(constants.ImagePullSecretApplied),
+	}
+
+	err := reconciler.SetupWithManager(mgr)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create pullsecret controller")
+	}
+
+	return nil
+}
+
+func isPullSecret(meta metav1.Object) bool {
+	return meta.GetLabels()[constants.ImagePullSecretLabel] == "true"
+}
+
+func (r *pullSecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("pullsecret").
+		For(&corev1.Secret{}).
+		WithEventFilter(predicate.Funcs{
+			CreateFunc: func(e event.CreateEvent) bool {
+				return isPullSecret(e.Meta)
+			},
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				return isPullSecret(e.MetaOld) || isPullSecret(e.MetaNew)
+			},
+			DeleteFunc: func(e event.DeleteEvent) bool {
+				return isPullSecret(e.Meta)
+			},
+			GenericFunc: func(e event.GenericEvent) bool {
+				return isPullSecret(e.Meta)
+			},
+		}).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;update;patch
+
+func (r *pullSecretReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	logger := r.Log.WithValues("secret", req.NamespacedName.String())
+
+	s := &corev1.Secret{}
+	err := r.Client.Get(ctx, req.NamespacedName, s)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.V(4).Info("not find secret")
+			return reconcile.Result{}, nil
+		}
+
+		logger.Error(err, "failed to get secret")
+		return reconcile.Result{}, err
+	}
+
+	source := fmt.Sprintf("%s.%s", s.Namespace, s.Name)
+	if !s.ObjectMeta.DeletionTimestamp.IsZero() || !isPullSecret(s) {
+		if !constants.ContainsString(s.ObjectMeta.Finalizers, r.finalizer) {
+			return reconcile.Result{}, nil
+		}
+
+		for _, cls := range r.ClusterManager.GetAll() {
+			err = cleanCluster(ctx, cls, source, s.Name)
+			if err != nil {
+				logger.Error(err, "failed to clean", "cluster", cls.Name)
+				return reconcile.Result{}, err
+			}
+		}
+
+		s.ObjectMeta.Finalizers = constants.RemoveString(s.ObjectMeta.Finalizers, r.finalizer)
+		err = r.Client.Update(ctx, s)
+		if err != nil {
+			logger.Error(err, "failed to remove finalizers")
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, nil
+	}
+
+	if s.Type != corev1.SecretTypeDockerConfigJson {
+		logger.Info("ignore secret", "type", s.Type)
+		return reconcile.Result{}, nil
+	}
+
+	if !constants.ContainsString(s.ObjectMeta.Finalizers, r.finalizer) {
+		s.ObjectMeta.Finalizers = append(s.ObjectMeta.Finalizers, r.finalizer)
+		err = r.Client.Update(ctx, s)
+		if err != nil {
+			logger.Error(err, "failed to set finalizers")
+			return reconcile.Result{}, err
+		}
+
+		return reconcile.Result{}, nil
+	}
+
+	namespaces := splitList(s.Annotations[constants.ImagePullSecretNamespaces])
+	if len(namespaces) == 0 {
+		namespaces = []string{defaultNamespace}
+	}
+	clusters := splitList(s.Annotations[constants.ImagePullSecretClusters])
+
+	var errs []error
+	all := map[string]*k8smanager.Cluster{}
+	var targets []string
+	for _, cls := range r.ClusterManager.GetAll() {
+		all[cls.Name] = cls
+		if len(clusters) > 0 && !constants.ContainsString(clusters, cls.Name) {
+			continue
+		}
+
+		for _, ns := range namespaces {
+			targets = append(targets, target(cls.Name, ns))
+			err = distribute(ctx, cls, source, s, ns)
+			if err != nil {
+				errs = append(errs, errors.Wrapf(err, "cluster: %s namespace: %s", cls.Name, ns))
+			}
+		}
+	}
+
+	// the targets no longer selected by the annotations are removed, the ones of the unavailable clusters are
+	// kept until their clusters come back
+	kept, err := pruneTargets(ctx, all, source, s.Name, splitList(s.Annotations[r.applied]), targets)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	err = r.setApplied(ctx, s, append(targets, kept...))
+	if err != nil {
+		errs = append(errs, errors.Wrapf(err, "record applied targets"))
+	}
+
+	if len(errs) > 0 {
+		err = utilerrors.NewAggregate(errs)
+		logger.Error(err, "failed to distribute secret")
+		return reconcile.Result{}, err
+	}
+
+	klog.V(4).Infof("secret: %s distributed to namespaces: %v", source, namespaces)
+	return reconcile.Result{RequeueAfter: ResyncPeriod}, nil
+}
+
+// distribute copies the secret to the namespace and adds it to the default ServiceAccount,
+// missing namespaces are skipped.
+func distribute(ctx context.Context, cls *k8smanager.Cluster, source string, s *corev1.Secret, ns string) error {
+	cli := cls.KubeCli.CoreV1()
+	_, err := cli.Namespaces().Get(ctx, ns, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	obj, err := cli.Secrets(ns).Get(ctx, s.Name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		obj = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      s.Name,
+				Namespace: ns,
+				Labels: map[string]string{
+					constants.CreatedByLabel: constants.CreatedBy,
+				},
+				Annotations: map[string]string{
+					constants.ImagePullSecretSource: source,
+				},
+			},
+			Type: s.Type,
+			Data: s.Data,
+		}
+		_, err = cli.Secrets(ns).Create(ctx, obj, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		klog.Infof("cluster: %s create secret: %s/%s", cls.Name, ns, s.Name)
+	} else {
+		if obj.Annotations[constants.ImagePullSecretSource] != source {
+			return fmt.Errorf("secret: %s/%s already exists and is not managed by: %s", ns, s.Name, source)
+		}
+
+		if !secretDataEqual(obj.Data, s.Data) {
+			obj.Data = s.Data
+			_, err = cli.Secrets(ns).Update(ctx, obj, metav1.UpdateOptions{})
+			if err != nil {
+				return err
+			}
+			klog.Infof("cluster: %s update secret: %s/%s", cls.Name, ns, s.Name)
+		}
+	}
+
+	sa, err := cli.ServiceAccounts(ns).Get(ctx, defaultServiceAccount, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, ref := range sa.ImagePullSecrets {
+		if ref.Name == s.Name {
+			return nil
+		}
+	}
+
+	refs := append(sa.ImagePullSecrets, corev1.LocalObjectReference{Name: s.Name})
+	return patchImagePullSecrets(ctx, cls, ns, sa.ResourceVersion, refs)
+}
+
+// cleanCluster removes the copies of the source secret and their references in the default ServiceAccounts.
+func cleanCluster(ctx context.Context, cls *k8smanager.Cluster, source string, name string) error {
+	secrets, err := cls.KubeCli.CoreV1().Secrets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: "metadata.name=" + name,
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range secrets.Items {
+		obj := &secrets.Items[i]
+		if obj.Annotations[constants.ImagePullSecretSource] != source {
+			continue
+		}
+
+		err = cleanNamespace(ctx, cls, source, name, obj.Namespace)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cleanNamespace removes the copy of the source secret in the namespace and its reference in the default
+// ServiceAccount, the secrets of other sources are left alone.
+func cleanNamespace(ctx context.Context, cls *k8smanager.Cluster, source string, name string, ns string) error {
+	cli := cls.KubeCli.CoreV1()
+	obj, err := cli.Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if obj.Annotations[constants.ImagePullSecretSource] != source {
+		return nil
+	}
+
+	sa, err := cli.ServiceAccounts(ns).Get(ctx, defaultServiceAccount, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if err == nil {
+		refs := make([]corev1.LocalObjectReference, 0, len(sa.ImagePullSecrets))
+		for _, ref := range sa.ImagePullSecrets {
+			if ref.Name != name {
+				refs = append(refs, ref)
+			}
+		}
+		if len(refs) != len(sa.ImagePullSecrets) {
+			err = patchImagePullSecrets(ctx, cls, ns, sa.ResourceVersion, refs)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = cli.Secrets(ns).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	klog.Infof("cluster: %s delete secret: %s/%s", cls.Name, ns, name)
+	return nil
+}
+
+// pruneTargets removes the copies of the previously applied targets which are not selected anymore, it returns
+// the stale targets which could not be removed yet, e.g. of the unavailable clusters.
+func pruneTargets(ctx context.Context, clusters map[string]*k8smanager.Cluster, source string, name string, applied, targets []string) ([]string, error) {
+	var kept []string
+	var errs []error
+	for _, t := range applied {
+		if constants.ContainsString(targets, t) {
+			continue
+		}
+
+		clusterName, ns := splitTarget(t)
+		if ns == "" {
+			continue
+		}
+		cls, ok := clusters[clusterName]
+		if !ok {
+			kept = append(kept, t)
+			continue
+		}
+
+		err := cleanNamespace(ctx, cls, source, name, ns)
+		if err != nil {
+			kept = append(kept, t)
+			errs = append(errs, errors.Wrapf(err, "cluster: %s namespace: %s", clusterName, ns))
+		}
+	}
+
+	return kept, utilerrors.NewAggregate(errs)
+}
+
+// setApplied records the applied targets on the source secret.
+func (r *pullSecretReconciler) setApplied(ctx context.Context, s *corev1.Secret, targets []string) error {
+	sort.Strings(targets)
+	value := strings.Join(targets, ",")
+	if s.Annotations[r.applied] == value {
+		return nil
+	}
+
+	patch := client.MergeFrom(s.DeepCopy())
+	if s.Annotations == nil {
+		s.Annotations = map[string]string{}
+	}
+	s.Annotations[r.applied] = value
+	return r.Client.Patch(ctx, s, patch)
+}
+
+func target(cluster, ns string) string {
+	return cluster + "/" + ns
+}
+
+func splitTarget(t string) (string, string) {
+	i := strings.Index(t, "/")
+	if i < 0 {
+		return t, ""
+	}
+	return t[:i], t[i+1:]
+}
+
+func patchImagePullSecrets(ctx context.Context, cls *k8smanager.Cluster, ns string, resourceVersion string, refs []corev1.LocalObjectReference) error {
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"resourceVersion": resourceVersion,
+		},
+		"imagePullSecrets": refs,
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	// merge patch replaces the list, resourceVersion guards the concurrent modification
+	_, err = cls.KubeCli.CoreV1().ServiceAccounts(ns).Patch(ctx, defaultServiceAccount, types.MergePatchType, data, metav1.PatchOptions{})
+	return err
+}
+
+func secretDataEqual(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for k, v := range a {
+		if string(b[k]) != string(v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
@@ -0,0 +1,75 @@
+package pullsecret
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/controllers/k8smanager"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPruneTargets(t *testing.T) {
+	const source = "kube-system.registry"
+	const name = "registry"
+
+	var objs []runtime.Object
+	for _, ns := range []string{"team-a", "team-b"} {
+		objs = append(objs,
+			&corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   ns,
+				Annotations: map[string]string{constants.ImagePullSecretSource: source},
+			}},
+			&corev1.ServiceAccount{
+				ObjectMeta:       metav1.ObjectMeta{Name: defaultServiceAccount, Namespace: ns},
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: "other"}, {Name: name}},
+			},
+		)
+	}
+	// not a copy of the source, left alone
+	objs = append(objs, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-c"}})
+
+	cli := fake.NewSimpleClientset(objs...)
+	clusters := map[string]*k8smanager.Cluster{"c1": {Name: "c1", KubeCli: cli}}
+	applied := []string{"c1/team-a", "c1/team-b", "c1/team-c", "gone/team-a"}
+	targets := []string{"c1/team-a"}
+
+	ctx := context.Background()
+	kept, err := pruneTargets(ctx, clusters, source, name, applied, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"gone/team-a"}; !reflect.DeepEqual(kept, want) {
+		t.Errorf("pruneTargets() = %v, want %v", kept, want)
+	}
+
+	if _, err := cli.CoreV1().Secrets("team-b").Get(ctx, name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("secret team-b/%s not removed, err: %v", name, err)
+	}
+	sa, err := cli.CoreV1().ServiceAccounts("team-b").Get(ctx, defaultServiceAccount, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []corev1.LocalObjectReference{{Name: "other"}}; !reflect.DeepEqual(sa.ImagePullSecrets, want) {
+		t.Errorf("team-b imagePullSecrets = %v, want %v", sa.ImagePullSecrets, want)
+	}
+
+	for _, ns := range []string{"team-a", "team-c"} {
+		if _, err := cli.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{}); err != nil {
+			t.Errorf("secret %s/%s removed, err: %v", ns, name, err)
+		}
+	}
+	sa, err = cli.CoreV1().ServiceAccounts("team-a").Get(ctx, defaultServiceAccount, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sa.ImagePullSecrets) != 2 {
+		t.Errorf("team-a imagePullSecrets = %v, want unchanged", sa.ImagePullSecrets)
+	}
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2020 dke.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trends
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/gmanager"
+	"github.com/gostship/kunkka/pkg/option"
+	"github.com/gostship/kunkka/pkg/sharding"
+	"github.com/gostship/kunkka/pkg/trends"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// trendsReconciler samples the node count and phase of each cluster every Interval,
+// the phase changes are sampled at once so the console charts catch the short outages.
+type trendsReconciler struct {
+	client.Client
+	*gmanager.GManager
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	Interval  time.Duration
+	Retention int
+	// shard filters the clusters sampled by the replica, whose clients are of its shard
+	shard *sharding.Sharder
+}
+
+func Add(mgr manager.Manager, pMgr *gmanager.GManager, opt *option.ControllersManagerOption, shard *sharding.Sharder) error {
+	reconciler := &trendsReconciler{
+		Client:    mgr.GetClient(),
+		GManager:  pMgr,
+		Log:       ctrl.Log.WithName("controllers").WithName("trends"),
+		Scheme:    mgr.GetScheme(),
+		Interval:  opt.TrendsInterval,
+		Retention: opt.TrendsRetention,
+		shard:     shard,
+	}
+	if reconciler.Interval <= 0 {
+		reconciler.Interval = trends.DefaultInterval
+	}
+
+	err := reconciler.SetupWithManager(mgr)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create trends controller")
+	}
+
+	return nil
+}
+
+func (r *trendsReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("trends").
+		For(&devopsv1.Cluster{}, builder.WithPredicates(r.shard.ClusterPredicate())).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups=devops.gostship.io,resources=clusters,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update
+
+func (r *trendsReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	logger := r.Log.WithValues("cluster", req.NamespacedName.String())
+
+	cluster := &devopsv1.Cluster{}
+	err := r.Client.Get(ctx, req.NamespacedName, cluster)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			// the samples are garbage collected with the cluster
+			logger.V(4).Info("not find cluster")
+			return reconcile.Result{}, nil
+		}
+
+		logger.Error(err, "failed to get cluster")
+		return reconcile.Result{}, err
+	}
+
+	if !cluster.ObjectMeta.DeletionTimestamp.IsZero() || !r.shard.Owns(cluster) {
+		return reconcile.Result{}, nil
+	}
+
+	key := types.NamespacedName{Namespace: cluster.Namespace, Name: trends.ConfigMapName(cluster.Name)}
+	cm := &corev1.ConfigMap{}
+	err = r.Client.Get(ctx, key, cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		logger.Error(err, "failed to get trends")
+		return reconcile.Result{}, err
+	}
+	exist := err == nil
+
+	samples, err := trends.Decode(cm)
+	if err != nil {
+		// start over instead of getting stuck on a broken ring
+		logger.Error(err, "failed to decode trends, drop the samples")
+		samples = nil
+	}
+
+	now := time.Now()
+	if n := len(samples); n > 0 {
+		last := samples[n-1]
+		next := last.Time.Add(r.Interval)
+		if last.Phase == string(cluster.Status.Phase) && now.Before(next) {
+			return reconcile.Result{RequeueAfter: next.Sub(now)}, nil
+		}
+	}
+
+	sample := r.sample(ctx, cluster)
+	sample.Time = now.UTC()
+	samples = trends.Append(samples, sample, r.Retention)
+
+	if !exist {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.Name,
+				Namespace: key.Namespace,
+				Labels: map[string]string{
+					constants.ClusterTrendsLabel: cluster.Name,
+					constants.CreatedByLabel:     constants.CreatedBy,
+				},
+			},
+		}
+		err = controllerutil.SetControllerReference(cluster, cm, r.Scheme)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	err = trends.Encode(cm, samples)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if exist {
+		err = r.Client.Update(ctx, cm)
+	} else {
+		err = r.Client.Create(ctx, cm)
+	}
+	if err != nil {
+		logger.Error(err, "failed to save trends")
+		return reconcile.Result{}, err
+	}
+
+	return reconcile.Result{RequeueAfter: r.Interval}, nil
+}
+
+// sample counts the nodes of the cluster, Nodes is -1 when the cluster is not connected.
+func (r *trendsReconciler) sample(ctx context.Context, cluster *devopsv1.Cluster) trends.Sample {
+	sample := trends.Sample{
+		Phase: string(cluster.Status.Phase),
+		Nodes: -1,
+	}
+
+	cls, err := r.ClusterManager.Get(cluster.Name)
+	if err != nil {
+		return sample
+	}
+
+	nodes, err := cls.KubeCli.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		r.Log.V(4).Info("failed to list nodes", "cluster", cluster.Name, "err", err.Error())
+		return sample
+	}
+
+	sample.Nodes = len(nodes.Items)
+	for i := range nodes.Items {
+		for _, condition := range nodes.Items[i].Status.Conditions {
+			if condition.Type == corev1.NodeReady && condition.Status == corev1.ConditionTrue {
+				sample.ReadyNodes++
+				break
+			}
+		}
+	}
+
+	return sample
+}
@@ -0,0 +1,313 @@
+// Package credential keeps the keys, tokens and kubeconfigs of the ClusterCredential in a Secret of the cluster,
+// optionally encrypted with AES-256-GCM, the ClusterCredential itself only keeps the public certificates.
+package credential
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"strings"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/util/aesgcm"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// DataKey the key of the plaintext credential in the Secret
+	DataKey = "credential.json"
+	// EncryptedDataKey the key of the encrypted credential in the Secret, the nonce is prepended to the ciphertext
+	EncryptedDataKey = "credential.enc"
+
+	keySize = 32
+
+	secretPrefix = "credential-"
+)
+
+var (
+	encryptionKey []byte
+	// previousKeys the keys by KeyID the secrets may still be encrypted with, they only decrypt
+	previousKeys map[string][]byte
+)
+
+// SecretName returns the name of the credential secret of the cluster.
+func SecretName(cluster string) string {
+	return secretPrefix + cluster
+}
+
+// SecretPredicate filters the events of the credential secrets, e.g. of the renewed kubeconfigs.
+func SecretPredicate() predicate.Funcs {
+	isSecret := func(meta metav1.Object) bool {
+		return strings.HasPrefix(meta.GetName(), secretPrefix)
+	}
+	return predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return isSecret(e.Meta) },
+		UpdateFunc:  func(e event.UpdateEvent) bool { return isSecret(e.MetaNew) },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return isSecret(e.Meta) },
+		GenericFunc: func(e event.GenericEvent) bool { return isSecret(e.Meta) },
+	}
+}
+
+// SetKey sets the AES-256 key the secrets are encrypted with, nil keeps them in plaintext. The secrets encrypted
+// with the previous keys are still decrypted and are encrypted with the key again on the next Save.
+func SetKey(key []byte, previous ...[]byte) error {
+	if key != nil && len(key) != keySize {
+		return errors.Errorf("credential key must be %d bytes, got %d", keySize, len(key))
+	}
+	keys := make(map[string][]byte, len(previous))
+	for _, k := range previous {
+		if len(k) != keySize {
+			return errors.Errorf("previous credential key must be %d bytes, got %d", keySize, len(k))
+		}
+		keys[KeyID(k)] = k
+	}
+	encryptionKey = key
+	previousKeys = keys
+	return nil
+}
+
+// LoadKeyFiles sets the key and the previous keys from the files of the raw or base64 encoded 32 bytes,
+// empty file disables the encryption.
+func LoadKeyFiles(file string, previousFiles []string) error {
+	var key []byte
+	if file != "" {
+		var err error
+		key, err = readKeyFile(file)
+		if err != nil {
+			return err
+		}
+	}
+
+	previous := make([][]byte, 0, len(previousFiles))
+	for _, f := range previousFiles {
+		k, err := readKeyFile(f)
+		if err != nil {
+			return err
+		}
+		previous = append(previous, k)
+	}
+	return SetKey(key, previous...)
+}
+
+func readKeyFile(file string) ([]byte, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read credential key: %s", file)
+	}
+	if len(data) != keySize {
+		data, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, errors.Wrapf(err, "decode credential key: %s", file)
+		}
+	}
+	return data, nil
+}
+
+// KeyID returns the fingerprint of the key.
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// split returns the sensitive part of the credential.
+func split(info *devopsv1.CredentialInfo) *devopsv1.CredentialInfo {
+	return &devopsv1.CredentialInfo{
+		ETCDCAKey:        info.ETCDCAKey,
+		ETCDAPIClientKey: info.ETCDAPIClientKey,
+		CAKey:            info.CAKey,
+		ClientKey:        info.ClientKey,
+		Token:            info.Token,
+		BootstrapToken:   info.BootstrapToken,
+		CertificateKey:   info.CertificateKey,
+		EncryptionKeys:   info.EncryptionKeys,
+		ExtData:          info.ExtData,
+		KubeData:         info.KubeData,
+		CertsBinaryData:  info.CertsBinaryData,
+	}
+}
+
+// strip removes the sensitive part from the credential.
+func strip(info *devopsv1.CredentialInfo) {
+	info.ETCDCAKey = nil
+	info.ETCDAPIClientKey = nil
+	info.CAKey = nil
+	info.ClientKey = nil
+	info.Token = nil
+	info.BootstrapToken = nil
+	info.CertificateKey = nil
+	info.EncryptionKeys = nil
+	info.ExtData = nil
+	info.KubeData = nil
+	info.CertsBinaryData = nil
+}
+
+// merge fills the credential with the sensitive part read from the Secret.
+func merge(info, s *devopsv1.CredentialInfo) {
+	if len(s.ETCDCAKey) > 0 {
+		info.ETCDCAKey = s.ETCDCAKey
+	}
+	if len(s.ETCDAPIClientKey) > 0 {
+		info.ETCDAPIClientKey = s.ETCDAPIClientKey
+	}
+	if len(s.CAKey) > 0 {
+		info.CAKey = s.CAKey
+	}
+	if len(s.ClientKey) > 0 {
+		info.ClientKey = s.ClientKey
+	}
+	if s.Token != nil {
+		info.Token = s.Token
+	}
+	if s.BootstrapToken != nil {
+		info.BootstrapToken = s.BootstrapToken
+	}
+	if s.CertificateKey != nil {
+		info.CertificateKey = s.CertificateKey
+	}
+	if s.EncryptionKeys != nil {
+		info.EncryptionKeys = s.EncryptionKeys
+	}
+	if s.ExtData != nil {
+		info.ExtData = s.ExtData
+	}
+	if s.KubeData != nil {
+		info.KubeData = s.KubeData
+	}
+	if s.CertsBinaryData != nil {
+		info.CertsBinaryData = s.CertsBinaryData
+	}
+}
+
+// Plaintext returns whether the ClusterCredential itself still keeps the sensitive part, i.e. it's not migrated yet.
+func Plaintext(cred *devopsv1.ClusterCredential) bool {
+	return !reflect.DeepEqual(split(&cred.CredentialInfo), &devopsv1.CredentialInfo{})
+}
+
+// Load fills the credential with the keys, tokens and kubeconfigs of its Secret,
+// the credentials not migrated to a Secret are kept as is.
+func Load(ctx context.Context, cli client.Client, cred *devopsv1.ClusterCredential) error {
+	s := &corev1.Secret{}
+	err := cli.Get(ctx, types.NamespacedName{Namespace: cred.Namespace, Name: SecretName(cred.Name)}, s)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "get credential secret: %s", SecretName(cred.Name))
+	}
+
+	data, err := Decode(s)
+	if err != nil {
+		return err
+	}
+
+	sensitive := &devopsv1.CredentialInfo{}
+	err = json.Unmarshal(data, sensitive)
+	if err != nil {
+		return errors.Wrapf(err, "unmarshal credential secret: %s", s.Name)
+	}
+	merge(&cred.CredentialInfo, sensitive)
+	return nil
+}
+
+// Save writes the sensitive part of the credential to its Secret and updates the ClusterCredential without it,
+// the credential keeps the sensitive part in memory and gets the new resource version.
+func Save(ctx context.Context, cli client.Client, cred *devopsv1.ClusterCredential) error {
+	data, err := json.Marshal(split(&cred.CredentialInfo))
+	if err != nil {
+		return err
+	}
+
+	err = saveSecret(ctx, cli, cred, data)
+	if err != nil {
+		return err
+	}
+
+	stripped := cred.DeepCopy()
+	strip(&stripped.CredentialInfo)
+	err = cli.Update(ctx, stripped)
+	if err != nil {
+		return err
+	}
+	cred.ResourceVersion = stripped.ResourceVersion
+	return nil
+}
+
+func saveSecret(ctx context.Context, cli client.Client, cred *devopsv1.ClusterCredential, data []byte) error {
+	checksum := sha256.Sum256(data)
+	keyID := ""
+	if encryptionKey != nil {
+		keyID = KeyID(encryptionKey)
+	}
+
+	s := &corev1.Secret{}
+	err := cli.Get(ctx, types.NamespacedName{Namespace: cred.Namespace, Name: SecretName(cred.Name)}, s)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "get credential secret: %s", SecretName(cred.Name))
+	}
+	exists := err == nil
+	if exists && s.Annotations[constants.CredentialChecksum] == hex.EncodeToString(checksum[:]) &&
+		s.Annotations[constants.CredentialKeyID] == keyID {
+		return nil
+	}
+
+	s.ObjectMeta = metav1.ObjectMeta{
+		Name:            SecretName(cred.Name),
+		Namespace:       cred.Namespace,
+		Labels:          cred.Labels,
+		OwnerReferences: cred.OwnerReferences,
+		ResourceVersion: s.ResourceVersion,
+		Annotations: map[string]string{
+			constants.CredentialChecksum: hex.EncodeToString(checksum[:]),
+		},
+	}
+	s.Type = corev1.SecretTypeOpaque
+	if encryptionKey == nil {
+		s.Data = map[string][]byte{DataKey: data}
+	} else {
+		ciphertext, err := aesgcm.Seal(encryptionKey, data, []byte(s.Namespace+"/"+s.Name))
+		if err != nil {
+			return err
+		}
+		s.Annotations[constants.CredentialKeyID] = keyID
+		s.Data = map[string][]byte{EncryptedDataKey: ciphertext}
+	}
+
+	if exists {
+		return cli.Update(ctx, s)
+	}
+	return cli.Create(ctx, s)
+}
+
+// Decode returns the credential json of the Secret, the encrypted one needs the key or the previous key it's
+// encrypted with.
+func Decode(s *corev1.Secret) ([]byte, error) {
+	ciphertext, ok := s.Data[EncryptedDataKey]
+	if !ok {
+		return s.Data[DataKey], nil
+	}
+
+	if encryptionKey == nil && len(previousKeys) == 0 {
+		return nil, errors.Errorf("credential secret: %s is encrypted but no credential key is set", s.Name)
+	}
+	keyID := s.Annotations[constants.CredentialKeyID]
+	key := previousKeys[keyID]
+	if encryptionKey != nil && keyID == KeyID(encryptionKey) {
+		key = encryptionKey
+	}
+	if key == nil {
+		return nil, errors.Errorf("credential secret: %s is encrypted with key: %s, neither the credential key nor a previous one", s.Name, keyID)
+	}
+	return aesgcm.Open(key, ciphertext, []byte(s.Namespace+"/"+s.Name))
+}
@@ -0,0 +1,140 @@
+package credential
+
+import (
+	"context"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSaveLoad(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	devopsv1.AddToScheme(scheme)
+
+	token := "abcdef.0123456789abcdef"
+	cred := &devopsv1.ClusterCredential{
+		ObjectMeta: metav1.ObjectMeta{Name: "c1", Namespace: "c1"},
+		CredentialInfo: devopsv1.CredentialInfo{
+			ClusterName: "c1",
+			CACert:      []byte("ca cert"),
+			CAKey:       []byte("ca key"),
+			Token:       &token,
+			ExtData:     map[string]string{"admin.conf": "kubeconfig"},
+		},
+	}
+
+	for _, key := range [][]byte{nil, []byte("0123456789abcdef0123456789abcdef")} {
+		if err := SetKey(key); err != nil {
+			t.Fatal(err)
+		}
+		cli := fake.NewFakeClientWithScheme(scheme, cred.DeepCopy())
+		ctx := context.TODO()
+
+		saved := cred.DeepCopy()
+		if err := Save(ctx, cli, saved); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		if saved.CAKey == nil || saved.ResourceVersion == cred.ResourceVersion {
+			t.Errorf("Save() changed the credential in memory: %v", saved)
+		}
+
+		got := &devopsv1.ClusterCredential{}
+		if err := cli.Get(ctx, types.NamespacedName{Namespace: "c1", Name: "c1"}, got); err != nil {
+			t.Fatal(err)
+		}
+		if Plaintext(got) || string(got.CACert) != "ca cert" {
+			t.Errorf("Save() the ClusterCredential keeps the sensitive part: %v", got.CredentialInfo)
+		}
+
+		s := &corev1.Secret{}
+		if err := cli.Get(ctx, types.NamespacedName{Namespace: "c1", Name: SecretName("c1")}, s); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := s.Data[EncryptedDataKey]; ok != (key != nil) {
+			t.Errorf("Save() secret data = %v, encrypted = %v", s.Data, key != nil)
+		}
+
+		if err := Load(ctx, cli, got); err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if string(got.CAKey) != "ca key" || *got.Token != token || got.ExtData["admin.conf"] != "kubeconfig" {
+			t.Errorf("Load() = %v", got.CredentialInfo)
+		}
+
+		if key != nil {
+			SetKey([]byte("fedcba9876543210fedcba9876543210"))
+			if err := Load(ctx, cli, got); err == nil {
+				t.Errorf("Load() with another key want error")
+			}
+		}
+	}
+	SetKey(nil)
+}
+
+func TestKeyRotation(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	devopsv1.AddToScheme(scheme)
+	defer SetKey(nil)
+
+	oldKey := []byte("0123456789abcdef0123456789abcdef")
+	newKey := []byte("fedcba9876543210fedcba9876543210")
+	cred := &devopsv1.ClusterCredential{
+		ObjectMeta:     metav1.ObjectMeta{Name: "c1", Namespace: "c1"},
+		CredentialInfo: devopsv1.CredentialInfo{ClusterName: "c1", CAKey: []byte("ca key")},
+	}
+	cli := fake.NewFakeClientWithScheme(scheme, cred.DeepCopy())
+	ctx := context.TODO()
+
+	SetKey(oldKey)
+	if err := Save(ctx, cli, cred.DeepCopy()); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	SetKey(newKey)
+	got := cred.DeepCopy()
+	if err := Load(ctx, cli, got); err == nil {
+		t.Errorf("Load() without the previous key want error")
+	}
+
+	if err := SetKey(newKey, oldKey); err != nil {
+		t.Fatal(err)
+	}
+	got = &devopsv1.ClusterCredential{}
+	if err := cli.Get(ctx, types.NamespacedName{Namespace: "c1", Name: "c1"}, got); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(ctx, cli, got); err != nil || string(got.CAKey) != "ca key" {
+		t.Fatalf("Load() with the previous key = %v, %v", got.CredentialInfo, err)
+	}
+	if err := Save(ctx, cli, got); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	s := &corev1.Secret{}
+	if err := cli.Get(ctx, types.NamespacedName{Namespace: "c1", Name: SecretName("c1")}, s); err != nil {
+		t.Fatal(err)
+	}
+	if keyID := s.Annotations[constants.CredentialKeyID]; keyID != KeyID(newKey) {
+		t.Errorf("Save() secret key id = %s, want the new key: %s", keyID, KeyID(newKey))
+	}
+
+	SetKey(newKey)
+	got = cred.DeepCopy()
+	got.CAKey = nil
+	if err := Load(ctx, cli, got); err != nil || string(got.CAKey) != "ca key" {
+		t.Errorf("Load() re-encrypted secret = %v, %v", got.CredentialInfo, err)
+	}
+
+	if err := SetKey(newKey, []byte("short")); err == nil {
+		t.Errorf("SetKey() short previous key want error")
+	}
+}
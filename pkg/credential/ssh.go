@@ -0,0 +1,159 @@
+package credential
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// the keys of the ssh credential in the Secret or the vault secret
+	SSHPasswordKey   = "password"
+	SSHPrivateKeyKey = "privateKey"
+	SSHPassPhraseKey = "passPhrase"
+
+	// the keys of the vault token Secret
+	VaultTokenKey = "token"
+	VaultCAKey    = "ca.crt"
+
+	vaultTimeout = 30 * time.Second
+)
+
+// LoadSSH loads the ssh credentials referenced by the machines in the namespace into the machines,
+// the loaded credentials are in memory only and never written back to the specs.
+func LoadSSH(ctx context.Context, cli client.Client, namespace string, machines ...*devopsv1.ClusterMachine) error {
+	// the machines sharing a secret load it once
+	loaded := map[string]map[string][]byte{}
+	for _, m := range machines {
+		if m == nil || m.CredentialRef == nil {
+			continue
+		}
+
+		ref := "secret/" + m.CredentialRef.SecretName
+		if v := m.CredentialRef.Vault; v != nil && m.CredentialRef.SecretName == "" {
+			ref = fmt.Sprintf("vault/%s/%s/%s", v.Address, v.Path, v.TokenSecretName)
+		}
+		data, ok := loaded[ref]
+		if !ok {
+			var err error
+			data, err = loadSSHRef(ctx, cli, namespace, m.CredentialRef)
+			if err != nil {
+				return errors.Wrapf(err, "machine: %s ssh credential", m.IP)
+			}
+			loaded[ref] = data
+		}
+
+		cred := &devopsv1.SSHCredential{
+			Password:   string(data[SSHPasswordKey]),
+			PrivateKey: data[SSHPrivateKeyKey],
+			PassPhrase: data[SSHPassPhraseKey],
+		}
+		if cred.Password == "" && len(cred.PrivateKey) == 0 {
+			return errors.Errorf("machine: %s ssh credential has neither %s nor %s", m.IP, SSHPasswordKey, SSHPrivateKeyKey)
+		}
+		m.Credential = cred
+	}
+	return nil
+}
+
+func loadSSHRef(ctx context.Context, cli client.Client, namespace string, ref *devopsv1.SSHCredentialRef) (map[string][]byte, error) {
+	if ref.SecretName != "" {
+		s := &corev1.Secret{}
+		key := types.NamespacedName{Namespace: namespace, Name: ref.SecretName}
+		if err := cli.Get(ctx, key, s); err != nil {
+			return nil, errors.Wrapf(err, "get secret: %s", key.String())
+		}
+		return s.Data, nil
+	}
+	if ref.Vault != nil {
+		return readVaultSecret(ctx, cli, namespace, ref.Vault)
+	}
+	return nil, errors.New("credentialRef has neither secretName nor vault")
+}
+
+// readVaultSecret reads the kv secret by the token of the Secret in the namespace.
+func readVaultSecret(ctx context.Context, cli client.Client, namespace string, ref *devopsv1.VaultSecretRef) (map[string][]byte, error) {
+	s := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: namespace, Name: ref.TokenSecretName}
+	if err := cli.Get(ctx, key, s); err != nil {
+		return nil, errors.Wrapf(err, "get vault token secret: %s", key.String())
+	}
+	token := strings.TrimSpace(string(s.Data[VaultTokenKey]))
+	if token == "" {
+		return nil, errors.Errorf("secret: %s must have %s", key.String(), VaultTokenKey)
+	}
+
+	tlsConfig := &tls.Config{}
+	if ca := s.Data[VaultCAKey]; len(ca) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.Errorf("secret: %s invalid %s", key.String(), VaultCAKey)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	httpCli := &http.Client{
+		Timeout: vaultTimeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		},
+	}
+
+	url := fmt.Sprintf("%s/v1/%s", strings.TrimSuffix(ref.Address, "/"), strings.Trim(ref.Path, "/"))
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	resp, err := httpCli.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "read vault secret: %s", ref.Path)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read vault secret: %s", ref.Path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("read vault secret: %s: %d %s", ref.Path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return decodeVaultSecret(body)
+}
+
+// decodeVaultSecret returns the string values of the kv secret, the data of kv v2 is nested
+// in the data of the response with the metadata.
+func decodeVaultSecret(body []byte) (map[string][]byte, error) {
+	resp := struct {
+		Data map[string]interface{} `json:"data"`
+	}{}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, errors.Wrap(err, "decode vault secret")
+	}
+
+	values := resp.Data
+	if nested, ok := values["data"].(map[string]interface{}); ok {
+		if _, ok := values["metadata"]; ok {
+			values = nested
+		}
+	}
+	data := make(map[string][]byte, len(values))
+	for k, v := range values {
+		if s, ok := v.(string); ok {
+			data[k] = []byte(s)
+		}
+	}
+	return data, nil
+}
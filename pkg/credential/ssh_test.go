@@ -0,0 +1,113 @@
+package credential
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestLoadSSH(t *testing.T) {
+	reads := 0
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		reads++
+		switch r.URL.Path {
+		case "/v1/secret/data/rack-a":
+			w.Write([]byte(`{"data":{"data":{"password":"kv2"},"metadata":{"version":1}}}`))
+		case "/v1/kv/rack-b":
+			w.Write([]byte(`{"data":{"privateKey":"kv1 key","passPhrase":"kv1 pass"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer vault.Close()
+
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	secret := func(name string, data map[string]string) *corev1.Secret {
+		s := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "c1"}, Data: map[string][]byte{}}
+		for k, v := range data {
+			s.Data[k] = []byte(v)
+		}
+		return s
+	}
+	cli := fake.NewFakeClientWithScheme(scheme,
+		secret("ssh", map[string]string{SSHPrivateKeyKey: "secret key"}),
+		secret("empty", nil),
+		secret("vault-token", map[string]string{VaultTokenKey: "s.token"}),
+		secret("wrong-token", map[string]string{VaultTokenKey: "s.wrong"}),
+	)
+	vaultRef := func(path, token string) *devopsv1.SSHCredentialRef {
+		return &devopsv1.SSHCredentialRef{Vault: &devopsv1.VaultSecretRef{Address: vault.URL + "/", Path: path, TokenSecretName: token}}
+	}
+
+	tests := []struct {
+		name    string
+		ref     *devopsv1.SSHCredentialRef
+		want    *devopsv1.SSHCredential
+		wantErr bool
+	}{
+		{name: "spec", want: &devopsv1.SSHCredential{Password: "spec"}},
+		{name: "secret", ref: &devopsv1.SSHCredentialRef{SecretName: "ssh"}, want: &devopsv1.SSHCredential{PrivateKey: []byte("secret key")}},
+		{name: "vault kv v2", ref: vaultRef("secret/data/rack-a", "vault-token"), want: &devopsv1.SSHCredential{Password: "kv2"}},
+		{name: "vault kv v1", ref: vaultRef("/kv/rack-b", "vault-token"),
+			want: &devopsv1.SSHCredential{PrivateKey: []byte("kv1 key"), PassPhrase: []byte("kv1 pass")}},
+		{name: "secret not found", ref: &devopsv1.SSHCredentialRef{SecretName: "none"}, wantErr: true},
+		{name: "no credential in secret", ref: &devopsv1.SSHCredentialRef{SecretName: "empty"}, wantErr: true},
+		{name: "vault permission denied", ref: vaultRef("secret/data/rack-a", "wrong-token"), wantErr: true},
+		{name: "vault secret not found", ref: vaultRef("secret/data/none", "vault-token"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &devopsv1.ClusterMachine{IP: "10.0.0.1", Password: "spec", CredentialRef: tt.ref}
+			err := LoadSSH(context.TODO(), cli, "c1", m)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadSSH() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got, err := m.SSHCredential()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Password != tt.want.Password || string(got.PrivateKey) != string(tt.want.PrivateKey) ||
+				string(got.PassPhrase) != string(tt.want.PassPhrase) {
+				t.Errorf("SSHCredential() = %+v, want %+v", got, tt.want)
+			}
+			if m.Password != "spec" {
+				t.Errorf("LoadSSH() changed the spec credential: %q", m.Password)
+			}
+		})
+	}
+
+	// the machines sharing a vault secret read it once
+	reads = 0
+	m1 := &devopsv1.ClusterMachine{IP: "10.0.0.1", CredentialRef: vaultRef("secret/data/rack-a", "vault-token")}
+	m2 := &devopsv1.ClusterMachine{IP: "10.0.0.2", CredentialRef: vaultRef("secret/data/rack-a", "vault-token")}
+	if err := LoadSSH(context.TODO(), cli, "c1", m1, m2, nil); err != nil {
+		t.Fatal(err)
+	}
+	if reads != 1 || m2.Credential == nil || m2.Credential.Password != "kv2" {
+		t.Errorf("reads = %d, credential = %+v", reads, m2.Credential)
+	}
+
+	// the referenced credential must be loaded before connecting
+	m := &devopsv1.ClusterMachine{IP: "10.0.0.1", Password: "spec", CredentialRef: &devopsv1.SSHCredentialRef{SecretName: "ssh"}}
+	if _, err := m.SSH(); err == nil {
+		t.Error("SSH() without the loaded credential succeeded")
+	}
+}
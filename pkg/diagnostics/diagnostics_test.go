@@ -0,0 +1,68 @@
+package diagnostics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gostship/kunkka/pkg/option"
+)
+
+func TestPruneBundles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnostics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	names := []string{
+		"diagnostics-heap-20200101-000000.tar.gz",
+		"diagnostics-all-20200101-000001.tar.gz",
+		"diagnostics-cpu-20200101-000002.tar.gz",
+	}
+	for i, name := range names {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := now.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := PruneBundles(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := names[:1]; !reflect.DeepEqual(removed, want) {
+		t.Errorf("PruneBundles() = %v, want %v", removed, want)
+	}
+	left, err := ListBundles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 2 {
+		t.Errorf("ListBundles() = %v, want 2 bundles", left)
+	}
+}
+
+func TestProfileBusy(t *testing.T) {
+	s := NewServer(&option.DiagnosticsOption{Token: "secret", BundleDir: os.TempDir()})
+	// a capture is running
+	s.capturing <- struct{}{}
+
+	r := httptest.NewRequest(http.MethodPost, ProfilePath+"?type=heap", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusConflict {
+		t.Errorf("profile code = %d, want %d", w.Code, http.StatusConflict)
+	}
+}
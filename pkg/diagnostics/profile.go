@@ -0,0 +1,219 @@
+package diagnostics
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"time"
+
+	"github.com/gostship/kunkka/pkg/version"
+	"github.com/pkg/errors"
+)
+
+type ProfileType string
+
+const (
+	ProfileCPU       ProfileType = "cpu"
+	ProfileHeap      ProfileType = "heap"
+	ProfileGoroutine ProfileType = "goroutine"
+	ProfileAll       ProfileType = "all"
+
+	DefaultCPUDuration = 30 * time.Second
+	MaxCPUDuration     = 5 * time.Minute
+)
+
+// RuntimeInfo is a snapshot of the go runtime of the process.
+type RuntimeInfo struct {
+	Version      version.Version  `json:"version"`
+	GoVersion    string           `json:"goVersion"`
+	NumCPU       int              `json:"numCPU"`
+	NumGoroutine int              `json:"numGoroutine"`
+	MemStats     runtime.MemStats `json:"memStats"`
+	Time         time.Time        `json:"time"`
+}
+
+// GetRuntimeInfo returns the runtime snapshot of the process.
+func GetRuntimeInfo() *RuntimeInfo {
+	info := &RuntimeInfo{
+		Version:      version.GetVersion(),
+		GoVersion:    runtime.Version(),
+		NumCPU:       runtime.NumCPU(),
+		NumGoroutine: runtime.NumGoroutine(),
+		Time:         time.Now(),
+	}
+	runtime.ReadMemStats(&info.MemStats)
+	return info
+}
+
+// Capture collects the profile, the cpu profile blocks for d.
+// It returns the files of the profile, keyed by file name.
+func Capture(t ProfileType, d time.Duration) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	switch t {
+	case ProfileCPU:
+		data, err := captureCPU(d)
+		if err != nil {
+			return nil, err
+		}
+		files["cpu.pprof"] = data
+	case ProfileHeap:
+		data, err := captureLookup("heap", 0)
+		if err != nil {
+			return nil, err
+		}
+		files["heap.pprof"] = data
+	case ProfileGoroutine:
+		data, err := captureLookup("goroutine", 2)
+		if err != nil {
+			return nil, err
+		}
+		files["goroutine.txt"] = data
+	case ProfileAll:
+		for _, one := range []ProfileType{ProfileGoroutine, ProfileHeap, ProfileCPU} {
+			tmp, err := Capture(one, d)
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range tmp {
+				files[k] = v
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported profile type: %s", t)
+	}
+
+	return files, nil
+}
+
+func captureCPU(d time.Duration) ([]byte, error) {
+	if d <= 0 {
+		d = DefaultCPUDuration
+	}
+	if d > MaxCPUDuration {
+		d = MaxCPUDuration
+	}
+
+	buf := &bytes.Buffer{}
+	// only one cpu profile can be running at once
+	if err := pprof.StartCPUProfile(buf); err != nil {
+		return nil, errors.Wrap(err, "start cpu profile")
+	}
+	time.Sleep(d)
+	pprof.StopCPUProfile()
+	return buf.Bytes(), nil
+}
+
+func captureLookup(name string, debug int) ([]byte, error) {
+	p := pprof.Lookup(name)
+	if p == nil {
+		return nil, fmt.Errorf("profile: %s not found", name)
+	}
+
+	if name == "heap" {
+		runtime.GC()
+	}
+
+	buf := &bytes.Buffer{}
+	if err := p.WriteTo(buf, debug); err != nil {
+		return nil, errors.Wrapf(err, "write profile: %s", name)
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteBundle saves the files and the runtime info as a tar.gz bundle in dir, it returns the bundle path.
+func WriteBundle(dir string, t ProfileType, files map[string][]byte) (string, error) {
+	info, err := json.MarshalIndent(GetRuntimeInfo(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	files["runtime.json"] = info
+
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	now := time.Now()
+	for name, data := range files {
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return "", err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return "", err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	if err := gw.Close(); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	name := filepath.Join(dir, fmt.Sprintf("diagnostics-%s-%s.tar.gz", t, now.Format("20060102-150405")))
+	if err := ioutil.WriteFile(name, buf.Bytes(), 0644); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// ListBundles returns the bundle names in dir.
+func ListBundles(dir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "diagnostics-*.tar.gz"))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(matches))
+	for _, m := range matches {
+		names = append(names, filepath.Base(m))
+	}
+	return names, nil
+}
+
+// PruneBundles removes the oldest bundles in dir beyond max, it returns the removed bundle names.
+func PruneBundles(dir string, max int) ([]string, error) {
+	if max <= 0 {
+		return nil, nil
+	}
+	names, err := ListBundles(dir)
+	if err != nil || len(names) <= max {
+		return nil, err
+	}
+
+	modTimes := make(map[string]time.Time, len(names))
+	for _, name := range names {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		modTimes[name] = fi.ModTime()
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return modTimes[names[i]].Before(modTimes[names[j]])
+	})
+
+	var removed []string
+	for _, name := range names[:len(names)-max] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed = append(removed, name)
+	}
+	return removed, nil
+}
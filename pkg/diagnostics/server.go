@@ -0,0 +1,188 @@
+package diagnostics
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gostship/kunkka/pkg/logs"
+	"github.com/gostship/kunkka/pkg/option"
+	"k8s.io/klog"
+)
+
+const (
+	PprofPath       = "/debug/pprof/"
+	ProfilePath     = "/debug/diagnostics/profile"
+	RuntimePath     = "/debug/diagnostics/runtime"
+	BundlesPath     = "/debug/diagnostics/bundles"
+	LogLevelPath    = "/debug/diagnostics/loglevel"
+	shutdownTimeout = 5 * time.Second
+)
+
+// Server exposes the pprof endpoints and captures diagnostics bundles on demand,
+// it implements manager.Runnable so that it runs with the controller manager.
+type Server struct {
+	opt *option.DiagnosticsOption
+	mux *http.ServeMux
+	// capturing serializes the captures, the cpu profile blocks for its duration and only one can run
+	capturing chan struct{}
+}
+
+func NewServer(opt *option.DiagnosticsOption) *Server {
+	s := &Server{
+		opt:       opt,
+		mux:       http.NewServeMux(),
+		capturing: make(chan struct{}, 1),
+	}
+
+	s.mux.HandleFunc(PprofPath, pprof.Index)
+	s.mux.HandleFunc(PprofPath+"cmdline", pprof.Cmdline)
+	s.mux.HandleFunc(PprofPath+"profile", pprof.Profile)
+	s.mux.HandleFunc(PprofPath+"symbol", pprof.Symbol)
+	s.mux.HandleFunc(PprofPath+"trace", pprof.Trace)
+	s.mux.HandleFunc(ProfilePath, s.profile)
+	s.mux.HandleFunc(RuntimePath, s.runtime)
+	s.mux.HandleFunc(BundlesPath, s.listBundles)
+	s.mux.Handle(LogLevelPath, logs.LevelHandler())
+	s.mux.Handle(BundlesPath+"/", http.StripPrefix(BundlesPath+"/", http.FileServer(http.Dir(opt.BundleDir))))
+	return s
+}
+
+// ServeHTTP only serves the requests with the token, or from loopback when no token is configured.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.opt.Token != "" {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		return subtle.ConstantTimeCompare([]byte(token), []byte(s.opt.Token)) == 1
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// profile captures the profile and saves it as a bundle,
+// e.g. POST /debug/diagnostics/profile?type=cpu&seconds=30
+func (s *Server) profile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	t := ProfileType(r.URL.Query().Get("type"))
+	if t == "" {
+		t = ProfileAll
+	}
+	d := DefaultCPUDuration
+	if seconds := r.URL.Query().Get("seconds"); seconds != "" {
+		v, err := time.ParseDuration(seconds + "s")
+		if err != nil {
+			http.Error(w, "invalid seconds: "+seconds, http.StatusBadRequest)
+			return
+		}
+		d = v
+	}
+
+	select {
+	case s.capturing <- struct{}{}:
+		defer func() { <-s.capturing }()
+	default:
+		http.Error(w, "another capture is in progress", http.StatusConflict)
+		return
+	}
+
+	klog.Infof("start capture diagnostics profile: %s, duration: %s", t, d)
+	files, err := Capture(t, d)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name, err := WriteBundle(s.opt.BundleDir, t, files)
+	if err != nil {
+		klog.Errorf("write diagnostics bundle err: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	klog.Infof("diagnostics bundle saved: %s", name)
+	removed, err := PruneBundles(s.opt.BundleDir, s.opt.MaxBundles)
+	if err != nil {
+		klog.Warningf("prune diagnostics bundles err: %v", err)
+	}
+	if len(removed) > 0 {
+		klog.Infof("diagnostics bundles removed: %v", removed)
+	}
+	writeJSON(w, map[string]string{
+		"bundle": filepath.Base(name),
+		"url":    BundlesPath + "/" + filepath.Base(name),
+	})
+}
+
+func (s *Server) runtime(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, GetRuntimeInfo())
+}
+
+func (s *Server) listBundles(w http.ResponseWriter, r *http.Request) {
+	names, err := ListBundles(s.opt.BundleDir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, names)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		klog.Errorf("write response err: %v", err)
+	}
+}
+
+// Start runs the server until stop is closed.
+// NeedLeaderElection implements manager.LeaderElectionRunnable, the standby replicas are diagnosed as well.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *Server) Start(stop <-chan struct{}) error {
+	srv := &http.Server{
+		Addr:    s.opt.Addr,
+		Handler: s,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		klog.Infof("diagnostics listening on %s", s.opt.Addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errCh <- err
+		}
+	}()
+
+	select {
+	case <-stop:
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return srv.Shutdown(ctx)
+	case err := <-errCh:
+		return err
+	}
+}
@@ -0,0 +1,205 @@
+package escrow
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/gostship/kunkka/pkg/util/aesgcm"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Algorithm wraps a random AES-256-GCM data key with the RSA-OAEP(SHA-256) public key of the admins
+	Algorithm = "RSA-OAEP-SHA256+A256GCM"
+
+	// DataKey is the key of the envelope in the escrow secret
+	DataKey = "envelope.json"
+
+	dataKeySize = 32
+)
+
+// Envelope is an escrowed credential, it can only be opened with the private key held by the admins.
+type Envelope struct {
+	Algorithm    string    `json:"algorithm"`
+	KeyID        string    `json:"keyID"`
+	Cluster      string    `json:"cluster"`
+	Checksum     string    `json:"checksum"`
+	EncryptedKey []byte    `json:"encryptedKey"`
+	Nonce        []byte    `json:"nonce"`
+	Ciphertext   []byte    `json:"ciphertext"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// SecretName returns the name of the escrow secret of the cluster.
+func SecretName(cluster string) string {
+	return fmt.Sprintf("escrow-%s", cluster)
+}
+
+// Checksum returns the sha256 of the plaintext, it tells whether the escrowed copy is stale without opening it.
+func Checksum(plaintext []byte) string {
+	sum := sha256.Sum256(plaintext)
+	return hex.EncodeToString(sum[:])
+}
+
+// KeyID returns the fingerprint of the public key.
+func KeyID(pub *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// Seal encrypts the plaintext to the public key.
+func Seal(pub *rsa.PublicKey, cluster string, plaintext []byte) (*Envelope, error) {
+	keyID, err := KeyID(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, errors.Wrap(err, "generate data key")
+	}
+
+	gcm, err := aesgcm.New(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "generate nonce")
+	}
+
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, dataKey, []byte(cluster))
+	if err != nil {
+		return nil, errors.Wrap(err, "wrap data key")
+	}
+
+	return &Envelope{
+		Algorithm:    Algorithm,
+		KeyID:        keyID,
+		Cluster:      cluster,
+		Checksum:     Checksum(plaintext),
+		EncryptedKey: encryptedKey,
+		Nonce:        nonce,
+		Ciphertext:   gcm.Seal(nil, nonce, plaintext, []byte(cluster)),
+		CreatedAt:    time.Now().UTC(),
+	}, nil
+}
+
+// Open decrypts the envelope with the private key.
+func Open(priv *rsa.PrivateKey, env *Envelope) ([]byte, error) {
+	if env.Algorithm != Algorithm {
+		return nil, fmt.Errorf("unsupported algorithm: %s", env.Algorithm)
+	}
+
+	keyID, err := KeyID(&priv.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if keyID != env.KeyID {
+		return nil, fmt.Errorf("envelope is sealed to key: %s, not: %s", env.KeyID, keyID)
+	}
+
+	dataKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, priv, env.EncryptedKey, []byte(env.Cluster))
+	if err != nil {
+		return nil, errors.Wrap(err, "unwrap data key")
+	}
+
+	gcm, err := aesgcm.New(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := gcm.Open(nil, env.Nonce, env.Ciphertext, []byte(env.Cluster))
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypt")
+	}
+
+	return plaintext, nil
+}
+
+// Marshal encodes the envelope as json.
+func Marshal(env *Envelope) ([]byte, error) {
+	return json.MarshalIndent(env, "", "  ")
+}
+
+// Unmarshal decodes the json envelope.
+func Unmarshal(data []byte) (*Envelope, error) {
+	env := &Envelope{}
+	if err := json.Unmarshal(data, env); err != nil {
+		return nil, errors.Wrap(err, "decode envelope")
+	}
+
+	return env, nil
+}
+
+// ParsePublicKey parses the PEM encoded PKIX or PKCS#1 rsa public key.
+func ParsePublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	if pub, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return pub, nil
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse public key")
+	}
+
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported public key type: %T", key)
+	}
+
+	return pub, nil
+}
+
+// ParsePrivateKey parses the PEM encoded PKCS#1 or PKCS#8 rsa private key.
+func ParsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	if priv, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return priv, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse private key")
+	}
+
+	priv, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type: %T", key)
+	}
+
+	return priv, nil
+}
+
+// LoadPublicKey reads the public key file.
+func LoadPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParsePublicKey(data)
+}
@@ -0,0 +1,85 @@
+package escrow
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&priv.PublicKey)})
+	pub, err := ParsePublicKey(pubPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := []byte("apiVersion: v1\nkind: Config\n")
+	env, err := Seal(pub, "c1", plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err = Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		key     *rsa.PrivateKey
+		modify  func(e Envelope) *Envelope
+		wantErr bool
+	}{
+		{
+			name:   "open",
+			key:    priv,
+			modify: func(e Envelope) *Envelope { return &e },
+		},
+		{
+			name:    "wrong key",
+			key:     other,
+			modify:  func(e Envelope) *Envelope { return &e },
+			wantErr: true,
+		},
+		{
+			name: "other cluster",
+			key:  priv,
+			modify: func(e Envelope) *Envelope {
+				e.Cluster = "c2"
+				return &e
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Open(tt.key, tt.modify(*env))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != string(plaintext) {
+				t.Errorf("Open() = %q, want %q", got, plaintext)
+			}
+		})
+	}
+
+	if env.Checksum != Checksum(plaintext) {
+		t.Errorf("checksum = %s, want %s", env.Checksum, Checksum(plaintext))
+	}
+}
@@ -0,0 +1,152 @@
+// Package hostkeys pins the ssh host keys of the machines of each cluster on the first contact,
+// they are kept in the known_hosts format in a ConfigMap next to the Cluster.
+package hostkeys
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	sshutil "github.com/gostship/kunkka/pkg/util/ssh"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// DataKey the key of the known_hosts in the ConfigMap
+const DataKey = "known_hosts"
+
+// ConfigMapName returns the name of the ConfigMap holding the host keys of the cluster.
+func ConfigMapName(cluster string) string {
+	return fmt.Sprintf("knownhosts-%s", cluster)
+}
+
+// Decode returns the keys of the known_hosts by the normalized hosts, the invalid lines are skipped.
+func Decode(data string) map[string]ssh.PublicKey {
+	keys := make(map[string]ssh.PublicKey)
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		key, err := sshutil.ParseHostKey(fields[1])
+		if err != nil {
+			klog.Warningf("skip known host: %s, %v", fields[0], err)
+			continue
+		}
+		for _, host := range strings.Split(fields[0], ",") {
+			keys[knownhosts.Normalize(host)] = key
+		}
+	}
+	return keys
+}
+
+// Store is the sshutil.HostKeyStore of the ConfigMaps, the keys are looked up by the cached client
+// and pinned by the fresh reads of the reader, so the removed keys, e.g. of a reinstalled machine,
+// are pinned again on the next contact.
+type Store struct {
+	cli    client.Client
+	reader client.Reader
+	scheme *runtime.Scheme
+}
+
+var _ sshutil.HostKeyStore = &Store{}
+
+// NewStore ...
+func NewStore(cli client.Client, reader client.Reader, scheme *runtime.Scheme) *Store {
+	return &Store{
+		cli:    cli,
+		reader: reader,
+		scheme: scheme,
+	}
+}
+
+// Lookup returns the pinned key of the host.
+func (s *Store) Lookup(cluster, host string) (ssh.PublicKey, error) {
+	cm := &corev1.ConfigMap{}
+	err := s.cli.Get(context.Background(), types.NamespacedName{Namespace: cluster, Name: ConfigMapName(cluster)}, cm)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return Decode(cm.Data[DataKey])[knownhosts.Normalize(host)], nil
+}
+
+// Pin appends the key of the host to the known_hosts of the cluster.
+func (s *Store) Pin(cluster, host string, key ssh.PublicKey) error {
+	ctx := context.Background()
+	host = knownhosts.Normalize(host)
+	nn := types.NamespacedName{Namespace: cluster, Name: ConfigMapName(cluster)}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm := &corev1.ConfigMap{}
+		err := s.reader.Get(ctx, nn, cm)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		exist := err == nil
+
+		if want := Decode(cm.Data[DataKey])[host]; want != nil {
+			if string(want.Marshal()) != string(key.Marshal()) {
+				return &sshutil.HostKeyMismatchError{Host: host, Want: want, Got: key}
+			}
+			return nil
+		}
+
+		if !exist {
+			c := &devopsv1.Cluster{}
+			err = s.reader.Get(ctx, types.NamespacedName{Namespace: cluster, Name: cluster}, c)
+			if err != nil {
+				return errors.Wrapf(err, "get cluster")
+			}
+			cm = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      nn.Name,
+					Namespace: nn.Namespace,
+					Labels: map[string]string{
+						constants.ClusterHostKeysLabel: cluster,
+						constants.CreatedByLabel:       constants.CreatedBy,
+					},
+				},
+			}
+			err = controllerutil.SetControllerReference(c, cm, s.scheme)
+			if err != nil {
+				return err
+			}
+		}
+
+		if cm.Data == nil {
+			cm.Data = make(map[string]string)
+		}
+		data := cm.Data[DataKey]
+		if data != "" && !strings.HasSuffix(data, "\n") {
+			data += "\n"
+		}
+		cm.Data[DataKey] = data + knownhosts.Line([]string{host}, key) + "\n"
+
+		klog.Infof("cluster: %s pin host key of %s: %s %s", cluster, host, key.Type(), ssh.FingerprintSHA256(key))
+		if exist {
+			return s.cli.Update(ctx, cm)
+		}
+		return s.cli.Create(ctx, cm)
+	})
+}
@@ -0,0 +1,95 @@
+package hostkeys
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	sshutil "github.com/gostship/kunkka/pkg/util/ssh"
+	"golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newKey(t *testing.T) ssh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestStore(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	devopsv1.AddToScheme(scheme)
+	cluster := &devopsv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "c1", Name: "c1", UID: "uid-c1"}}
+	cli := fake.NewFakeClientWithScheme(scheme, cluster)
+	s := NewStore(cli, cli, scheme)
+
+	k1, k2 := newKey(t), newKey(t)
+	if key, err := s.Lookup("c1", "10.0.0.1:22"); err != nil || key != nil {
+		t.Fatalf("Lookup() of unknown host = %v, %v", key, err)
+	}
+
+	if err := s.Pin("c1", "10.0.0.1:22", k1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Pin("c1", "10.0.0.2:2222", k2); err != nil {
+		t.Fatal(err)
+	}
+	// pinning the same key again is a no-op
+	if err := s.Pin("c1", "10.0.0.1", k1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Pin("c1", "10.0.0.1:22", k2); !sshutil.IsHostKeyMismatch(err) {
+		t.Errorf("Pin() of another key = %v, want mismatch", err)
+	}
+	// the cluster is gone
+	if err := s.Pin("c2", "10.0.0.1:22", k1); err == nil {
+		t.Error("Pin() without cluster succeeded")
+	}
+
+	for host, want := range map[string]ssh.PublicKey{"10.0.0.1": k1, "10.0.0.1:22": k1, "10.0.0.2:2222": k2, "10.0.0.2": nil} {
+		key, err := s.Lookup("c1", host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (key == nil) != (want == nil) || (key != nil && string(key.Marshal()) != string(want.Marshal())) {
+			t.Errorf("Lookup(%s) = %v, want %v", host, key, want)
+		}
+	}
+
+	cm := &corev1.ConfigMap{}
+	if err := cli.Get(context.TODO(), types.NamespacedName{Namespace: "c1", Name: ConfigMapName("c1")}, cm); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(cm.Data[DataKey]), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "10.0.0.1 ssh-ed25519 ") || !strings.HasPrefix(lines[1], "[10.0.0.2]:2222 ssh-ed25519 ") {
+		t.Errorf("known_hosts: %q", cm.Data[DataKey])
+	}
+	if refs := cm.OwnerReferences; len(refs) != 1 || refs[0].UID != "uid-c1" {
+		t.Errorf("owner references: %v", refs)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	k := newKey(t)
+	line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(k)))
+	data := "# pinned\n\n10.0.0.1," + "[10.0.0.2]:2222 " + line + "\n10.0.0.3 invalid\n"
+	keys := Decode(data)
+	if len(keys) != 2 || keys["10.0.0.1"] == nil || keys["[10.0.0.2]:2222"] == nil {
+		t.Errorf("Decode() = %v", keys)
+	}
+}
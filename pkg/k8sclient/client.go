@@ -7,12 +7,25 @@ import (
 	"path/filepath"
 
 	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
 )
 
+var (
+	// MemberQPS and MemberBurst the rate limits of the clients of the member clusters
+	MemberQPS   float32 = 40
+	MemberBurst         = 60
+)
+
+// AddFlags adds the flags of the rate limits of the clients of the member clusters.
+func AddFlags(fs *pflag.FlagSet) {
+	fs.Float32Var(&MemberQPS, "member-kube-api-qps", MemberQPS, "The queries per second of the clients of the member clusters")
+	fs.IntVar(&MemberBurst, "member-kube-api-burst", MemberBurst, "The burst of the queries of the clients of the member clusters")
+}
+
 // GetConfig returns kubernetes config based on the current environment.
 // If fpath is provided, loads configuration from that file. Otherwise,
 // GetConfig uses default strategy to load configuration from $KUBECONFIG,
@@ -78,10 +91,9 @@ func NewClientConfig(kubeConfig []byte) (*rest.Config, error) {
 		return nil, errors.Wrap(err, "failed to build client config from API config")
 	}
 
-	// Adjust our client's rate limits based on the number of controllers we are running.
 	if cfg.QPS == 0.0 {
-		cfg.QPS = 40.0
-		cfg.Burst = 60
+		cfg.QPS = MemberQPS
+		cfg.Burst = MemberBurst
 	}
 
 	return cfg, nil
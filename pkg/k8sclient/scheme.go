@@ -21,6 +21,7 @@ import (
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 
 	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	devopsv2 "github.com/gostship/kunkka/pkg/apis/devops/v2"
 	"k8s.io/apimachinery/pkg/runtime"
 	apiregistrationv1beta1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1beta1"
 	//  monitorv1 "github.com/coreos/prometheus-operator/pkg/apis/monitoring/v1"
@@ -35,6 +36,7 @@ func init() {
 	_ = apiextensionsv1beta1.AddToScheme(scheme)
 	_ = apiregistrationv1beta1.AddToScheme(scheme)
 	_ = devopsv1.AddToScheme(scheme)
+	_ = devopsv2.AddToScheme(scheme)
 	// _ = monitorv1.AddToScheme(scheme)
 }
 
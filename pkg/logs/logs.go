@@ -0,0 +1,142 @@
+// Package logs sets up the zap logger of controller-runtime with a level adjustable at runtime, and carries the
+// cluster, machine and phase of the reconcile in the loggers of the contexts, so the logs of a provisioning can be
+// filtered by their fields instead of grepping the messages.
+package logs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	ctrl "sigs.k8s.io/controller-runtime"
+	crzap "sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// the fields of the structured logs
+const (
+	ClusterKey  = "cluster"
+	MachineKey  = "machine"
+	PhaseKey    = "phase"
+	ProviderKey = "provider"
+	AddonKey    = "addon"
+)
+
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
+
+// level the level of the logger, adjusted at runtime by LevelHandler
+var level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+
+// Options the level and the format of the logs.
+type Options struct {
+	// Level debug, info, warn, error or the verbosity of the V logs, e.g. 2, empty means debug in the development
+	// mode and info otherwise
+	Level string
+	// Format console or json, empty means console in the development mode and json otherwise
+	Format      string
+	Development bool
+}
+
+// DefaultOptions returns the options of the development mode.
+func DefaultOptions() *Options {
+	return &Options{
+		Development: true,
+	}
+}
+
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Level, "log-level", o.Level, "The level of the logs: debug, info, warn, error or the verbosity of the V logs, e.g. 2, adjustable at runtime on the log level endpoint. Empty means debug in the development mode and info otherwise")
+	fs.StringVar(&o.Format, "log-format", o.Format, "The format of the logs: console or json. Empty means console in the development mode and json otherwise")
+}
+
+func (o *Options) Validate() error {
+	if _, err := ParseLevel(o.level()); err != nil {
+		return err
+	}
+	switch o.Format {
+	case "", FormatConsole, FormatJSON:
+		return nil
+	default:
+		return fmt.Errorf("invalid log format: %q, want %s or %s", o.Format, FormatConsole, FormatJSON)
+	}
+}
+
+func (o *Options) level() string {
+	if o.Level != "" {
+		return o.Level
+	}
+	if o.Development {
+		return "debug"
+	}
+	return "info"
+}
+
+// ParseLevel parses the named zap levels and the verbosity of the V logs, V(n) logs at the zap level -n.
+func ParseLevel(s string) (zapcore.Level, error) {
+	if v, err := strconv.Atoi(s); err == nil {
+		if v < 0 {
+			return 0, fmt.Errorf("invalid log level: %q, the verbosity must not be negative", s)
+		}
+		return zapcore.Level(-v), nil
+	}
+
+	var l zapcore.Level
+	if err := l.UnmarshalText([]byte(strings.ToLower(s))); err != nil {
+		return 0, fmt.Errorf("invalid log level: %q", s)
+	}
+	return l, nil
+}
+
+// Setup sets the logger of controller-runtime, ctrl.Log.
+func Setup(o *Options) error {
+	l, err := ParseLevel(o.level())
+	if err != nil {
+		return err
+	}
+	level.SetLevel(l)
+
+	opts := []crzap.Opts{crzap.UseDevMode(o.Development), crzap.Level(&level)}
+	switch o.Format {
+	case FormatJSON:
+		opts = append(opts, crzap.Encoder(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())))
+	case FormatConsole:
+		opts = append(opts, crzap.Encoder(zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())))
+	}
+	ctrl.SetLogger(crzap.New(opts...))
+	return nil
+}
+
+// LevelHandler serves the level of the logs, GET returns it and PUT sets a named level, e.g. {"level":"debug"}.
+func LevelHandler() http.Handler {
+	return level
+}
+
+type loggerKey struct{}
+
+// IntoContext returns the context carrying the logger.
+func IntoContext(ctx context.Context, logger logr.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger of the context, ctrl.Log if it has none.
+func FromContext(ctx context.Context) logr.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerKey{}).(logr.Logger); ok {
+			return logger
+		}
+	}
+	return ctrl.Log
+}
+
+// WithValues returns the context whose logger has the additional fields.
+func WithValues(ctx context.Context, keysAndValues ...interface{}) context.Context {
+	return IntoContext(ctx, FromContext(ctx).WithValues(keysAndValues...))
+}
@@ -0,0 +1,51 @@
+package logs
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		level   string
+		want    zapcore.Level
+		wantErr bool
+	}{
+		{level: "debug", want: zapcore.DebugLevel},
+		{level: "INFO", want: zapcore.InfoLevel},
+		{level: "error", want: zapcore.ErrorLevel},
+		{level: "4", want: zapcore.Level(-4)},
+		{level: "-1", wantErr: true},
+		{level: "verbose", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.level)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, %v, want %v, err %v", tt.level, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := DefaultOptions().Validate(); err != nil {
+		t.Errorf("Validate() default = %v", err)
+	}
+	if err := (&Options{Format: "yaml"}).Validate(); err == nil {
+		t.Error("Validate() format yaml, want error")
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if got := FromContext(context.Background()); got != ctrl.Log {
+		t.Errorf("FromContext() = %v, want ctrl.Log", got)
+	}
+
+	logger := ctrl.Log.WithName("test")
+	ctx := IntoContext(context.Background(), logger)
+	if got := FromContext(ctx); got != logger {
+		t.Errorf("FromContext() = %v, want %v", got, logger)
+	}
+}
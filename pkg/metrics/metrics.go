@@ -0,0 +1,81 @@
+// Package metrics the prometheus metrics of the provisioning of the clusters and the machines, registered to the
+// registry of controller-runtime served on the --metrics-addr of the controller.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// the kinds of the objects of the phases
+const (
+	KindCluster = "cluster"
+	KindMachine = "machine"
+)
+
+// the results of the phases and the ssh operations
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+var (
+	// PhaseDuration the duration of each run of the handlers of the clusters and the machines
+	PhaseDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "kunkka_phase_duration_seconds",
+			Help: "Duration of the runs of the provisioning phases of the clusters and the machines.",
+			// 0.5s to about 2h
+			Buckets: prometheus.ExponentialBuckets(0.5, 2, 15),
+		},
+		[]string{"kind", "phase", "result"},
+	)
+
+	// PhaseFailures the failures of the handlers by the reason of their conditions
+	PhaseFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kunkka_phase_failures_total",
+			Help: "Number of the failed runs of the phases of the clusters and the machines by the reason of the condition.",
+		},
+		[]string{"kind", "phase", "reason"},
+	)
+
+	// SSHDuration the latency of the ssh operations on the machines
+	SSHDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "kunkka_ssh_operation_duration_seconds",
+			Help: "Duration of the ssh operations on the machines, the commands exiting non-zero are successful operations.",
+			// 50ms to about 7m
+			Buckets: prometheus.ExponentialBuckets(0.05, 2, 14),
+		},
+		[]string{"operation", "result"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(PhaseDuration, PhaseFailures, SSHDuration)
+}
+
+// ObservePhase records the run of the phase of the kind taking d.
+func ObservePhase(kind, phase string, d time.Duration, err error) {
+	PhaseDuration.WithLabelValues(kind, phase, result(err)).Observe(d.Seconds())
+}
+
+// RecordFailure counts the failure of the phase of the kind by the reason of its condition.
+func RecordFailure(kind, phase, reason string) {
+	PhaseFailures.WithLabelValues(kind, phase, reason).Inc()
+}
+
+// ObserveSSH records the ssh operation started at start.
+func ObserveSSH(operation string, start time.Time, err error) {
+	SSHDuration.WithLabelValues(operation, result(err)).Observe(time.Since(start).Seconds())
+}
+
+func result(err error) string {
+	if err != nil {
+		return ResultFailure
+	}
+	return ResultSuccess
+}
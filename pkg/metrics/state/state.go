@@ -0,0 +1,152 @@
+// Package state exports the phases of the clusters and the machines, it's apart from the metrics package
+// for the metrics are recorded by the packages the apis depend on, e.g. ssh.
+package state
+
+import (
+	"context"
+	"time"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/sharding"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	clustersDesc = prometheus.NewDesc(
+		"kunkka_clusters",
+		"Number of the clusters by phase.",
+		[]string{"phase"}, nil,
+	)
+	clusterProvisioningDesc = prometheus.NewDesc(
+		"kunkka_cluster_provisioning_seconds",
+		"Seconds since the creation of the cluster still initializing.",
+		[]string{"namespace", "cluster", "condition"}, nil,
+	)
+	machinesDesc = prometheus.NewDesc(
+		"kunkka_machines",
+		"Number of the machines by phase.",
+		[]string{"phase"}, nil,
+	)
+	machineConditionsDesc = prometheus.NewDesc(
+		"kunkka_machine_conditions",
+		"Number of the machines by the type and the status of their conditions.",
+		[]string{"type", "status"}, nil,
+	)
+	machineProvisioningDesc = prometheus.NewDesc(
+		"kunkka_machine_provisioning_seconds",
+		"Seconds since the creation of the machine still initializing.",
+		[]string{"namespace", "cluster", "machine", "condition"}, nil,
+	)
+)
+
+// Collector exports the phases of the clusters and the machines of the shard, they're read from the cache
+// on scrape. The provisioning seconds are labeled with the condition in progress, e.g. to alert on the
+// clusters stuck in provisioning.
+type Collector struct {
+	Client client.Reader
+	shard  *sharding.Sharder
+}
+
+// NewCollector returns the collector reading the clusters and the machines of the shard with cli.
+func NewCollector(cli client.Reader, shard *sharding.Sharder) *Collector {
+	return &Collector{Client: cli, shard: shard}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- clustersDesc
+	ch <- clusterProvisioningDesc
+	ch <- machinesDesc
+	ch <- machineConditionsDesc
+	ch <- machineProvisioningDesc
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+	c.collectClusters(ch, now)
+	c.collectMachines(ch, now)
+}
+
+func (c *Collector) collectClusters(ch chan<- prometheus.Metric, now time.Time) {
+	clusters := &devopsv1.ClusterList{}
+	err := c.Client.List(context.Background(), clusters)
+	if err != nil {
+		klog.Errorf("list clusters error: %v", err)
+		return
+	}
+
+	phases := map[devopsv1.ClusterPhase]int{}
+	for i := range clusters.Items {
+		cluster := &clusters.Items[i]
+		if !c.shard.Owns(cluster) {
+			continue
+		}
+		phases[cluster.Status.Phase]++
+		if cluster.Status.Phase != devopsv1.ClusterInitializing {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(clusterProvisioningDesc, prometheus.GaugeValue,
+			now.Sub(cluster.CreationTimestamp.Time).Seconds(), cluster.Namespace, cluster.Name, clusterPending(cluster))
+	}
+	for phase, n := range phases {
+		ch <- prometheus.MustNewConstMetric(clustersDesc, prometheus.GaugeValue, float64(n), string(phase))
+	}
+}
+
+func (c *Collector) collectMachines(ch chan<- prometheus.Metric, now time.Time) {
+	machines := &devopsv1.MachineList{}
+	err := c.Client.List(context.Background(), machines)
+	if err != nil {
+		klog.Errorf("list machines error: %v", err)
+		return
+	}
+
+	type condition struct {
+		Type   string
+		Status devopsv1.ConditionStatus
+	}
+	phases := map[devopsv1.MachinePhase]int{}
+	conditions := map[condition]int{}
+	for i := range machines.Items {
+		m := &machines.Items[i]
+		if !c.shard.OwnsMachine(m, m.Spec.ClusterName) {
+			continue
+		}
+		phases[m.Status.Phase]++
+		for _, cond := range m.Status.Conditions {
+			conditions[condition{Type: cond.Type, Status: cond.Status}]++
+		}
+		if m.Status.Phase != devopsv1.MachineInitializing {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(machineProvisioningDesc, prometheus.GaugeValue,
+			now.Sub(m.CreationTimestamp.Time).Seconds(), m.Namespace, m.Spec.ClusterName, m.Name, machinePending(m))
+	}
+	for phase, n := range phases {
+		ch <- prometheus.MustNewConstMetric(machinesDesc, prometheus.GaugeValue, float64(n), string(phase))
+	}
+	for cond, n := range conditions {
+		ch <- prometheus.MustNewConstMetric(machineConditionsDesc, prometheus.GaugeValue, float64(n), cond.Type, string(cond.Status))
+	}
+}
+
+// clusterPending returns the type of the first condition of the cluster not true, the phase in progress.
+func clusterPending(c *devopsv1.Cluster) string {
+	for _, cond := range c.Status.Conditions {
+		if cond.Status != devopsv1.ConditionTrue {
+			return cond.Type
+		}
+	}
+	return ""
+}
+
+// machinePending returns the type of the first condition of the machine not true, the phase in progress.
+func machinePending(m *devopsv1.Machine) string {
+	for _, cond := range m.Status.Conditions {
+		if cond.Status != devopsv1.ConditionTrue {
+			return cond.Type
+		}
+	}
+	return ""
+}
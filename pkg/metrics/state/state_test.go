@@ -0,0 +1,80 @@
+package state
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// listReader lists the clusters and the machines.
+type listReader struct {
+	clusters []devopsv1.Cluster
+	machines []devopsv1.Machine
+}
+
+func (r *listReader) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	return nil
+}
+
+func (r *listReader) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	switch l := list.(type) {
+	case *devopsv1.ClusterList:
+		l.Items = r.clusters
+	case *devopsv1.MachineList:
+		l.Items = r.machines
+	}
+	return nil
+}
+
+func TestCollector(t *testing.T) {
+	machine := func(name string, phase devopsv1.MachinePhase, conditions ...devopsv1.MachineCondition) devopsv1.Machine {
+		return devopsv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "c1"},
+			Spec:       devopsv1.MachineSpec{ClusterName: "c1"},
+			Status:     devopsv1.MachineStatus{Phase: phase, Conditions: conditions},
+		}
+	}
+	reader := &listReader{
+		clusters: []devopsv1.Cluster{
+			{ObjectMeta: metav1.ObjectMeta{Name: "c1"}, Status: devopsv1.ClusterStatus{Phase: devopsv1.ClusterRunning}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "c2"}, Status: devopsv1.ClusterStatus{Phase: devopsv1.ClusterRunning}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "c3"}, Status: devopsv1.ClusterStatus{Phase: devopsv1.ClusterInitializing}},
+		},
+		machines: []devopsv1.Machine{
+			machine("m1", devopsv1.MachineRunning,
+				devopsv1.MachineCondition{Type: "EnsureSystem", Status: devopsv1.ConditionTrue}),
+			machine("m2", devopsv1.MachineInitializing,
+				devopsv1.MachineCondition{Type: "EnsureSystem", Status: devopsv1.ConditionTrue},
+				devopsv1.MachineCondition{Type: "EnsureJoinNode", Status: devopsv1.ConditionFalse}),
+		},
+	}
+
+	want := `
+# HELP kunkka_clusters Number of the clusters by phase.
+# TYPE kunkka_clusters gauge
+kunkka_clusters{phase="Initializing"} 1
+kunkka_clusters{phase="Running"} 2
+# HELP kunkka_machine_conditions Number of the machines by the type and the status of their conditions.
+# TYPE kunkka_machine_conditions gauge
+kunkka_machine_conditions{status="False",type="EnsureJoinNode"} 1
+kunkka_machine_conditions{status="True",type="EnsureSystem"} 2
+# HELP kunkka_machines Number of the machines by phase.
+# TYPE kunkka_machines gauge
+kunkka_machines{phase="Initializing"} 1
+kunkka_machines{phase="Running"} 1
+`
+	c := NewCollector(reader, nil)
+	err := testutil.CollectAndCompare(c, strings.NewReader(want), "kunkka_clusters", "kunkka_machines", "kunkka_machine_conditions")
+	if err != nil {
+		t.Error(err)
+	}
+	if got := machinePending(&reader.machines[1]); got != "EnsureJoinNode" {
+		t.Errorf("machinePending() = %v, want EnsureJoinNode", got)
+	}
+}
@@ -17,25 +17,146 @@ limitations under the License.
 package option
 
 import (
+	"time"
+
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/controllers/k8smanager"
+	"github.com/gostship/kunkka/pkg/k8sclient"
+	"github.com/gostship/kunkka/pkg/sharding"
+	"github.com/gostship/kunkka/pkg/sshaudit"
+	"github.com/gostship/kunkka/pkg/timeouts"
+	"github.com/gostship/kunkka/pkg/trends"
+	"github.com/gostship/kunkka/pkg/util/parallel"
 	"github.com/spf13/pflag"
 )
 
 type ControllersManagerOption struct {
 	EnableCluster     bool
 	EnableMachine     bool
+	EnablePullSecret  bool
+	EnableTrends      bool
+	EnableHealth      bool
 	EnableManagerCrds bool
+
+	// EscrowPublicKey the PEM rsa public key the admin credentials are sealed to, empty disables the escrow
+	EscrowPublicKey string
+	EscrowNamespace string
+	// EscrowDir an extra copy of the sealed credentials, e.g. a backup volume outside the meta cluster
+	EscrowDir string
+
+	// CredentialKeyFile the AES-256 key the credential secrets of the clusters are encrypted with, empty keeps them in plaintext
+	CredentialKeyFile string
+	// CredentialPreviousKeyFiles the keys the credential secrets were encrypted with before the rotation, the secrets
+	// are decrypted with them and encrypted with CredentialKeyFile on the next save
+	CredentialPreviousKeyFiles []string
+
+	// TrendsInterval the period of the node count and phase samples of the clusters
+	TrendsInterval time.Duration
+	// TrendsRetention the number of samples kept per cluster
+	TrendsRetention int
+
+	// HealthInterval the period of the probes of the apiservers of the clusters by the health controller
+	HealthInterval time.Duration
+
+	// EnableSSHAudit records the ssh commands run on the machines in a ConfigMap per cluster besides the logs
+	EnableSSHAudit bool
+	// SSHAuditRetention the number of ssh commands kept per cluster
+	SSHAuditRetention int
+
+	// SSHHostKeyPinning pins the ssh host keys of the machines on the first contact and verifies them afterwards
+	SSHHostKeyPinning bool
+
+	// ClusterConcurrentReconciles the number of the Clusters reconciled at a time
+	ClusterConcurrentReconciles int
+	// MachineConcurrentReconciles the number of the Machines reconciled at a time
+	MachineConcurrentReconciles int
+	// MachineParallelism the number of the Machines of a cluster provisioned at a time, overridden per cluster
+	// by the k8s.io/machine-parallelism annotation
+	MachineParallelism int
+	// ClusterRequeue and MachineRequeue the requeue intervals of the Clusters and the Machines, the addons are
+	// resynced on the addonResync wait
+	ClusterRequeue *RequeueOption
+	MachineRequeue *RequeueOption
+
+	// EnableWebhook serves the mutating webhooks filling the defaults of the Clusters and the Machines
+	EnableWebhook bool
+	// WebhookPort the port of the webhook server
+	WebhookPort int
+	// WebhookCertDir the directory of the tls.crt and tls.key of the webhook server
+	WebhookCertDir string
+
+	// MetricsAddr the address the prometheus metrics are served on, "0" disables them
+	MetricsAddr string
+
+	// Sharding assigns the clusters to the shards of the replicas, each connects to the clusters of its shard
+	Sharding *sharding.Options
 }
 
 func DefaultControllersManagerOption() *ControllersManagerOption {
 	return &ControllersManagerOption{
 		EnableCluster:     true,
 		EnableMachine:     true,
+		EnablePullSecret:  true,
+		EnableTrends:      true,
+		EnableHealth:      true,
 		EnableManagerCrds: false,
+		EscrowNamespace:   constants.EscrowNamespace,
+		TrendsInterval:    trends.DefaultInterval,
+		TrendsRetention:   trends.DefaultRetention,
+		HealthInterval:    time.Minute,
+		SSHAuditRetention: sshaudit.DefaultRetention,
+		SSHHostKeyPinning: true,
+
+		ClusterConcurrentReconciles: 1,
+		MachineConcurrentReconciles: 10,
+		MachineParallelism:          5,
+		ClusterRequeue:              &RequeueOption{},
+		// the running machines are checked for the os drift
+		MachineRequeue: &RequeueOption{Success: constants.OSDriftCheckInterval},
+
+		WebhookPort: 9443,
+		MetricsAddr: ":8080",
+		Sharding:    sharding.DefaultOptions(),
 	}
 }
 
 func (o *ControllersManagerOption) AddFlags(fs *pflag.FlagSet) {
 	fs.BoolVar(&o.EnableCluster, "enable-cluster", o.EnableCluster, "Enables the Cluster controller manager")
 	fs.BoolVar(&o.EnableMachine, "enable-machine", o.EnableMachine, "Enables the Machine controller manager")
+	fs.BoolVar(&o.EnablePullSecret, "enable-pull-secret", o.EnablePullSecret, "Enables the image pull secret distribution controller")
+	fs.BoolVar(&o.EnableTrends, "enable-trends", o.EnableTrends, "Enables the node count and phase sampling of the clusters")
 	fs.BoolVar(&o.EnableManagerCrds, "enable-manager-crds", o.EnableManagerCrds, "Enables to manager the associated crds")
+	fs.StringVar(&o.EscrowPublicKey, "escrow-public-key", o.EscrowPublicKey, "The PEM rsa public key file the cluster admin credentials are escrowed to, empty disables the escrow")
+	fs.StringVar(&o.EscrowNamespace, "escrow-namespace", o.EscrowNamespace, "The namespace of the escrowed credentials")
+	fs.StringVar(&o.EscrowDir, "escrow-dir", o.EscrowDir, "The directory an extra copy of the escrowed credentials is written to, e.g. a backup volume")
+	fs.StringVar(&o.CredentialKeyFile, "credential-key-file", o.CredentialKeyFile, "The file of the raw or base64 encoded 32 bytes key the credential secrets are encrypted with, empty keeps them in plaintext")
+	fs.StringSliceVar(&o.CredentialPreviousKeyFiles, "credential-previous-key-files", o.CredentialPreviousKeyFiles, "The files of the keys the credential secrets were encrypted with before the rotation, they only decrypt")
+	fs.DurationVar(&o.TrendsInterval, "trends-interval", o.TrendsInterval, "The period of the node count and phase samples of the clusters")
+	fs.IntVar(&o.TrendsRetention, "trends-retention", o.TrendsRetention, "The number of the node count and phase samples kept per cluster")
+	fs.BoolVar(&o.EnableHealth, "enable-health", o.EnableHealth, "Enables the probes of the apiservers of the clusters recorded in their Reachable condition")
+	fs.DurationVar(&o.HealthInterval, "health-interval", o.HealthInterval, "The period of the probes of the apiservers of the clusters")
+	fs.BoolVar(&o.EnableSSHAudit, "enable-ssh-audit", o.EnableSSHAudit, "Enables to record the ssh commands run on the machines in the sshaudit-<cluster> ConfigMap of each cluster, they are always logged")
+	fs.IntVar(&o.SSHAuditRetention, "ssh-audit-retention", o.SSHAuditRetention, "The number of the ssh commands kept per cluster")
+	fs.BoolVar(&o.SSHHostKeyPinning, "ssh-host-key-pinning", o.SSHHostKeyPinning, "Pins the ssh host keys of the machines in the knownhosts-<cluster> ConfigMap of each cluster on the first contact and verifies them afterwards, the host keys of the specs are always verified")
+	fs.IntVar(&o.ClusterConcurrentReconciles, "cluster-concurrent-reconciles", o.ClusterConcurrentReconciles, "The number of the Clusters reconciled at a time")
+	fs.IntVar(&o.MachineConcurrentReconciles, "machine-concurrent-reconciles", o.MachineConcurrentReconciles, "The number of the Machines reconciled at a time")
+	fs.IntVar(&o.MachineParallelism, "machine-parallelism", o.MachineParallelism, "The number of the Machines of a cluster provisioned at a time, overridden by the k8s.io/machine-parallelism annotation of the cluster")
+	o.ClusterRequeue.AddFlags(fs, "cluster")
+	o.MachineRequeue.AddFlags(fs, "machine")
+	fs.BoolVar(&o.EnableWebhook, "enable-webhook", o.EnableWebhook, "Enables the mutating webhooks filling the defaults of the Clusters and the Machines")
+	fs.IntVar(&o.WebhookPort, "webhook-port", o.WebhookPort, "The port of the webhook server")
+	fs.StringVar(&o.WebhookCertDir, "webhook-cert-dir", o.WebhookCertDir, "The directory of the tls.crt and tls.key of the webhook server, defaults to <tmp>/k8s-webhook-server/serving-certs")
+	fs.StringVar(&o.MetricsAddr, "metrics-addr", o.MetricsAddr, "The address the prometheus metrics are served on, \"0\" disables them")
+	o.Sharding.AddFlags(fs)
+	timeouts.AddFlags(fs)
+	parallel.AddFlags(fs)
+	k8sclient.AddFlags(fs)
+	k8smanager.AddFlags(fs)
+}
+
+func (o *ControllersManagerOption) Validate() error {
+	if err := o.ClusterRequeue.Validate("cluster"); err != nil {
+		return err
+	}
+	return o.MachineRequeue.Validate("machine")
 }
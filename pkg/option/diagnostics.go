@@ -0,0 +1,28 @@
+package option
+
+import (
+	"github.com/spf13/pflag"
+)
+
+type DiagnosticsOption struct {
+	Addr      string
+	Token     string
+	BundleDir string
+	// MaxBundles the number of the bundles kept in BundleDir, the oldest are removed
+	MaxBundles int
+}
+
+func DefaultDiagnosticsOption() *DiagnosticsOption {
+	return &DiagnosticsOption{
+		Addr:       ":8091",
+		BundleDir:  "/tmp/kunkka-diagnostics",
+		MaxBundles: 10,
+	}
+}
+
+func (o *DiagnosticsOption) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Addr, "diagnostics-addr", o.Addr, "The address the pprof and diagnostics endpoint binds to, empty disables it")
+	fs.StringVar(&o.Token, "diagnostics-token", o.Token, "The bearer token of the diagnostics endpoint, without it only loopback requests (e.g. kubectl port-forward) are allowed")
+	fs.StringVar(&o.BundleDir, "diagnostics-bundle-dir", o.BundleDir, "The directory the captured diagnostics bundles are saved to")
+	fs.IntVar(&o.MaxBundles, "diagnostics-max-bundles", o.MaxBundles, "The number of the diagnostics bundles kept, the oldest are removed, 0 keeps all of them")
+}
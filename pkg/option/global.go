@@ -28,26 +28,27 @@ import (
 )
 
 type GlobalManagerOption struct {
-	Kubeconfig              string
-	ConfigContext           string
-	Namespace               string
-	DefaultNamespace        string
-	LoggerDevMode           bool
-	Threads                 int
-	GoroutineThreshold      int
-	ResyncPeriod            time.Duration
-	LeaderElectionNamespace string
-	EnableLeaderElection    bool
+	Kubeconfig         string
+	ConfigContext      string
+	Namespace          string
+	DefaultNamespace   string
+	LoggerDevMode      bool
+	Threads            int
+	GoroutineThreshold int
+	ResyncPeriod       time.Duration
+	// KubeAPIQPS and KubeAPIBurst the rate limits of the client of the meta cluster
+	KubeAPIQPS   float32
+	KubeAPIBurst int
 }
 
 func DefaultGlobalManagerOption() *GlobalManagerOption {
 	return &GlobalManagerOption{
-		LoggerDevMode:           true,
-		Threads:                 1,
-		GoroutineThreshold:      1000,
-		ResyncPeriod:            60 * time.Minute,
-		EnableLeaderElection:    false,
-		LeaderElectionNamespace: "kunkka-system",
+		LoggerDevMode:      true,
+		Threads:            1,
+		GoroutineThreshold: 1000,
+		ResyncPeriod:       60 * time.Minute,
+		KubeAPIQPS:         40,
+		KubeAPIBurst:       60,
 	}
 }
 
@@ -58,6 +59,9 @@ func (o *GlobalManagerOption) AddFlags(fs *pflag.FlagSet) {
 	fs.BoolVar(&o.LoggerDevMode, "logger-dev-mode", o.LoggerDevMode, "Enables the Cluster controller manager")
 	fs.IntVar(&o.Threads, "threads", o.Threads, "Enables the Machine controller manager")
 	fs.IntVar(&o.GoroutineThreshold, "goroutine-threshold", o.GoroutineThreshold, "Enables the Machine controller manager")
+	fs.DurationVar(&o.ResyncPeriod, "resync-period", o.ResyncPeriod, "The period all the objects of the meta cluster are reconciled again")
+	fs.Float32Var(&o.KubeAPIQPS, "kube-api-qps", o.KubeAPIQPS, "The queries per second of the client of the meta cluster")
+	fs.IntVar(&o.KubeAPIBurst, "kube-api-burst", o.KubeAPIBurst, "The burst of the queries of the client of the meta cluster")
 }
 
 func (o *GlobalManagerOption) GetK8sConfig() (*rest.Config, error) {
@@ -66,11 +70,8 @@ func (o *GlobalManagerOption) GetK8sConfig() (*rest.Config, error) {
 		return nil, errors.Wrap(err, "could not get k8s config")
 	}
 
-	// Adjust our client's rate limits based on the number of controllers we are running.
-	if cfg.QPS == 0.0 {
-		cfg.QPS = 40.0
-		cfg.Burst = 60
-	}
+	cfg.QPS = o.KubeAPIQPS
+	cfg.Burst = o.KubeAPIBurst
 
 	return cfg, nil
 }
@@ -0,0 +1,63 @@
+/*
+Copyright 2020 dke.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package option
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// RequeueOption the requeue intervals of the objects of a controller.
+type RequeueOption struct {
+	// Success requeues the objects reconciled without pending work to check them again, 0 waits for the next
+	// change or resync. The backoff of the failed phases and the resync of the addons requeue sooner.
+	Success time.Duration
+	// Failure requeues the objects failed to reconcile after the interval, 0 keeps the exponential backoff of
+	// the workqueue
+	Failure time.Duration
+}
+
+func (o *RequeueOption) AddFlags(fs *pflag.FlagSet, controller string) {
+	fs.DurationVar(&o.Success, controller+"-requeue-success", o.Success, fmt.Sprintf("The interval the %ss reconciled without pending work are requeued after, 0 waits for the next change or resync", controller))
+	fs.DurationVar(&o.Failure, controller+"-requeue-failure", o.Failure, fmt.Sprintf("The interval the %ss failed to reconcile are requeued after, 0 backs off exponentially", controller))
+}
+
+func (o *RequeueOption) Validate(controller string) error {
+	if o.Success < 0 || o.Failure < 0 {
+		return fmt.Errorf("invalid requeue of the %s controller: the intervals must not be negative", controller)
+	}
+	return nil
+}
+
+// After returns the sooner of the pending requeue and the success interval.
+func (o *RequeueOption) After(after time.Duration) time.Duration {
+	if o == nil || o.Success <= 0 || (after > 0 && after < o.Success) {
+		return after
+	}
+	return o.Success
+}
+
+// Result requeues the failed reconcile after the failure interval instead of returning the err to the workqueue.
+func (o *RequeueOption) Result(result ctrl.Result, err error) (ctrl.Result, error) {
+	if err == nil || o == nil || o.Failure <= 0 {
+		return result, err
+	}
+	return ctrl.Result{RequeueAfter: o.Failure}, nil
+}
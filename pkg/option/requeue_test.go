@@ -0,0 +1,51 @@
+package option
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestRequeueAfter(t *testing.T) {
+	tests := []struct {
+		name    string
+		success time.Duration
+		after   time.Duration
+		want    time.Duration
+	}{
+		{name: "no success interval", after: time.Minute, want: time.Minute},
+		{name: "nothing pending", success: time.Hour, want: time.Hour},
+		{name: "backoff sooner", success: time.Hour, after: time.Minute, want: time.Minute},
+		{name: "success sooner", success: time.Minute, after: time.Hour, want: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &RequeueOption{Success: tt.success}
+			if got := o.After(tt.after); got != tt.want {
+				t.Errorf("After() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequeueResult(t *testing.T) {
+	err := errors.New("failed")
+
+	var o *RequeueOption
+	if _, got := o.Result(ctrl.Result{}, err); got != err {
+		t.Errorf("Result() of the default option err = %v, want %v", got, err)
+	}
+
+	o = &RequeueOption{Failure: time.Minute}
+	result, got := o.Result(ctrl.Result{}, err)
+	if got != nil || result.RequeueAfter != time.Minute {
+		t.Errorf("Result() = %v, %v, want requeue after %v", result, got, time.Minute)
+	}
+
+	result, got = o.Result(ctrl.Result{RequeueAfter: time.Second}, nil)
+	if got != nil || result.RequeueAfter != time.Second {
+		t.Errorf("Result() of the success = %v, %v, want it unchanged", result, got)
+	}
+}
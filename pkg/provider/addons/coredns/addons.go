@@ -5,6 +5,7 @@ import (
 
 	"github.com/gostship/kunkka/pkg/constants"
 	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/addons/inventory"
 	"github.com/gostship/kunkka/pkg/provider/config"
 	"github.com/gostship/kunkka/pkg/util/template"
 	"github.com/pkg/errors"
@@ -293,5 +294,6 @@ func BuildCoreDNSAddon(cfg *config.Config, c *common.Cluster) ([]runtime.Object,
 	}
 
 	objs = append(objs, coreDNSServiceAccount)
+	inventory.Mark(inventory.CoreDNS, objs...)
 	return objs, nil
 }
@@ -3,7 +3,10 @@ package flannel
 import (
 	"bytes"
 
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
 	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/addons/inventory"
 	"github.com/gostship/kunkka/pkg/provider/config"
 	"github.com/gostship/kunkka/pkg/util/k8sutil"
 	"github.com/gostship/kunkka/pkg/util/template"
@@ -12,6 +15,11 @@ import (
 )
 
 const (
+	// Version the version of the flannel image, the wireguard backend uses WireguardVersion
+	Version = "v0.12.0"
+	// WireguardVersion the first flannel version supports the wireguard backend
+	WireguardVersion = "v0.15.1"
+
 	flannelTemplate = `---
 apiVersion: policy/v1beta1
 kind: PodSecurityPolicy
@@ -125,6 +133,9 @@ data:
         {
           "type": "flannel",
           "delegate": {
+            {{- if .MTU }}
+            "mtu": {{ .MTU }},
+            {{- end }}
             "hairpinMode": true,
             "isDefaultGateway": true
           }
@@ -144,11 +155,12 @@ data:
         "Type": "{{ default "vxlan" .BackendType }}"
       }
     }
+{{- range $arch, $image := .Images }}
 ---
 apiVersion: apps/v1
 kind: DaemonSet
 metadata:
-  name: kube-flannel-ds-amd64
+  name: kube-flannel-ds-{{ $arch }}
   namespace: kube-system
   labels:
     tier: node
@@ -175,7 +187,7 @@ spec:
                   - key: kubernetes.io/arch
                     operator: In
                     values:
-                      - amd64
+                      - {{ $arch }}
       hostNetwork: true
       tolerations:
       - operator: Exists
@@ -183,7 +195,7 @@ spec:
       serviceAccountName: flannel
       initContainers:
       - name: install-cni
-        image: "{{ default "quay.io/coreos/flannel:v0.12.0-amd64" .ImageName }}"
+        image: "{{ $image }}"
         command:
         - cp
         args:
@@ -197,7 +209,7 @@ spec:
           mountPath: /etc/kube-flannel/
       containers:
       - name: kube-flannel
-        image: "{{ default "quay.io/coreos/flannel:v0.12.0-amd64" .ImageName }}"
+        image: "{{ $image }}"
         command:
         - /opt/bin/flanneld
         args:
@@ -238,21 +250,50 @@ spec:
         - name: flannel-cfg
           configMap:
             name: kube-flannel-cfg
+{{- end }}
 `
 )
 
+// images the flannel image of each arch by version. The symcn mirror only has the amd64 images, the arm64
+// ones are the upstream images: the single-arch tag of v0.12.0 the upstream v0.12.0 manifest deploys on arm64,
+// and the multi-arch tag of v0.15.1.
+var images = map[string]map[string]string{
+	Version: {
+		constants.ArchAMD64: "symcn.tencentcloudcr.com/symcn/flannel:" + Version,
+		constants.ArchARM64: "quay.io/coreos/flannel:" + Version + "-arm64",
+	},
+	WireguardVersion: {
+		constants.ArchAMD64: "symcn.tencentcloudcr.com/symcn/flannel:" + WireguardVersion,
+		constants.ArchARM64: "quay.io/coreos/flannel:" + WireguardVersion,
+	},
+}
+
 type Option struct {
 	ClusterPodCidr string
 	BackendType    string
+	MTU            int32
 	ImageName      string
+	// Images the images of the daemonset of each arch, see images
+	Images map[string]string
 }
 
 func BuildFlannelAddon(cfg *config.Config, c *common.Cluster) ([]runtime.Object, error) {
 	opt := &Option{
 		ClusterPodCidr: c.Cluster.Spec.ClusterCIDR,
-		BackendType:    "vxlan",
-		ImageName:      "symcn.tencentcloudcr.com/symcn/flannel:v0.12.0",
+		BackendType:    string(devopsv1.FlannelBackendVxlan),
+		Images:         images[Version],
+	}
+	if f := c.Cluster.Spec.Flannel; f != nil {
+		if f.Backend != "" {
+			opt.BackendType = string(f.Backend)
+		}
+		opt.MTU = f.MTU
+	}
+	if opt.BackendType == string(devopsv1.FlannelBackendWireguard) {
+		// the wireguard backend is supported since flannel v0.15
+		opt.Images = images[WireguardVersion]
 	}
+	opt.ImageName = opt.Images[constants.ArchAMD64]
 	data, err := template.ParseString(flannelTemplate, opt)
 	if err != nil {
 		return nil, err
@@ -264,5 +305,6 @@ func BuildFlannelAddon(cfg *config.Config, c *common.Cluster) ([]runtime.Object,
 		return nil, err
 	}
 
+	inventory.Mark(inventory.Flannel, objs...)
 	return objs, nil
 }
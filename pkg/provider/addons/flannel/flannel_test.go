@@ -0,0 +1,79 @@
+package flannel
+
+import (
+	"reflect"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/config"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+func TestBuildFlannelAddon(t *testing.T) {
+	cfg, err := config.NewDefaultConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		flannel *devopsv1.Flannel
+		want    map[string]string
+	}{
+		{
+			name: "vxlan",
+			want: map[string]string{
+				"amd64": "symcn.tencentcloudcr.com/symcn/flannel:v0.12.0",
+				"arm64": "quay.io/coreos/flannel:v0.12.0-arm64",
+			},
+		},
+		{
+			name:    "wireguard",
+			flannel: &devopsv1.Flannel{Backend: devopsv1.FlannelBackendWireguard},
+			want: map[string]string{
+				"amd64": "symcn.tencentcloudcr.com/symcn/flannel:v0.15.1",
+				"arm64": "quay.io/coreos/flannel:v0.15.1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &common.Cluster{Cluster: &devopsv1.Cluster{Spec: devopsv1.ClusterSpec{Flannel: tt.flannel}}}
+			objs, err := BuildFlannelAddon(cfg, c)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the images of the containers of the daemonset match the arch of its nodes
+			got := map[string]string{}
+			for _, obj := range objs {
+				ds, ok := obj.(*appsv1.DaemonSet)
+				if !ok {
+					continue
+				}
+				spec := ds.Spec.Template.Spec
+				arch := spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions[1].Values[0]
+				if spec.InitContainers[0].Image != spec.Containers[0].Image {
+					t.Errorf("daemonset: %s images = %s and %s, want the same", ds.Name, spec.InitContainers[0].Image, spec.Containers[0].Image)
+				}
+				got[arch] = spec.Containers[0].Image
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("images = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImages(t *testing.T) {
+	// every arch has a pinned image of every version, the arch added to constants.Archs needs one
+	for version, byArch := range images {
+		for _, arch := range constants.Archs {
+			if byArch[arch] == "" {
+				t.Errorf("flannel: %s has no image of arch: %s", version, arch)
+			}
+		}
+	}
+}
@@ -0,0 +1,536 @@
+package gatekeeper
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/addons/inventory"
+	"github.com/gostship/kunkka/pkg/provider/config"
+	"github.com/gostship/kunkka/pkg/tracing"
+	"github.com/gostship/kunkka/pkg/util/k8sutil"
+	"github.com/gostship/kunkka/pkg/util/template"
+	"github.com/pkg/errors"
+	admissionv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// PolicyType is the value of the policyInstall hook which enables gatekeeper.
+	PolicyType = "gatekeeper"
+	// Version the version of the gatekeeper image
+	Version = "v3.1.0"
+
+	templateGroup = "templates.gatekeeper.sh"
+
+	gatekeeperTemplate = `
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: gatekeeper-system
+  labels:
+    admission.gatekeeper.sh/ignore: no-self-managing
+    control-plane: controller-manager
+---
+apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: configs.config.gatekeeper.sh
+  labels:
+    gatekeeper.sh/system: "yes"
+spec:
+  group: config.gatekeeper.sh
+  names:
+    kind: Config
+    listKind: ConfigList
+    plural: configs
+    singular: config
+  scope: Namespaced
+  preserveUnknownFields: true
+  version: v1alpha1
+  versions:
+  - name: v1alpha1
+    served: true
+    storage: true
+---
+apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: constrainttemplates.templates.gatekeeper.sh
+  labels:
+    controller-tools.k8s.io: "1.0"
+    gatekeeper.sh/system: "yes"
+spec:
+  group: templates.gatekeeper.sh
+  names:
+    kind: ConstraintTemplate
+    plural: constrainttemplates
+  scope: Cluster
+  preserveUnknownFields: true
+  subresources:
+    status: {}
+  version: v1beta1
+  versions:
+  - name: v1beta1
+    served: true
+    storage: true
+  - name: v1alpha1
+    served: true
+    storage: false
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: gatekeeper-admin
+  namespace: gatekeeper-system
+  labels:
+    gatekeeper.sh/system: "yes"
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: gatekeeper-manager-role
+  namespace: gatekeeper-system
+  labels:
+    gatekeeper.sh/system: "yes"
+rules:
+- apiGroups: [""]
+  resources: ["events"]
+  verbs: ["create", "patch"]
+- apiGroups: [""]
+  resources: ["secrets"]
+  verbs: ["create", "delete", "get", "list", "patch", "update", "watch"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: gatekeeper-manager-role
+  labels:
+    gatekeeper.sh/system: "yes"
+rules:
+- apiGroups: ["*"]
+  resources: ["*"]
+  verbs: ["get", "list", "watch"]
+- apiGroups: ["apiextensions.k8s.io"]
+  resources: ["customresourcedefinitions"]
+  verbs: ["create", "delete", "get", "list", "patch", "update", "watch"]
+- apiGroups: ["config.gatekeeper.sh", "constraints.gatekeeper.sh", "status.gatekeeper.sh", "templates.gatekeeper.sh"]
+  resources: ["*"]
+  verbs: ["create", "delete", "get", "list", "patch", "update", "watch"]
+- apiGroups: ["admissionregistration.k8s.io"]
+  resources: ["validatingwebhookconfigurations"]
+  verbs: ["create", "delete", "get", "list", "patch", "update", "watch"]
+- apiGroups: ["policy"]
+  resources: ["podsecuritypolicies"]
+  resourceNames: ["gatekeeper-admin"]
+  verbs: ["use"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: gatekeeper-manager-rolebinding
+  namespace: gatekeeper-system
+  labels:
+    gatekeeper.sh/system: "yes"
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: gatekeeper-manager-role
+subjects:
+- kind: ServiceAccount
+  name: gatekeeper-admin
+  namespace: gatekeeper-system
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: gatekeeper-manager-rolebinding
+  labels:
+    gatekeeper.sh/system: "yes"
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: gatekeeper-manager-role
+subjects:
+- kind: ServiceAccount
+  name: gatekeeper-admin
+  namespace: gatekeeper-system
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: gatekeeper-webhook-server-cert
+  namespace: gatekeeper-system
+  labels:
+    gatekeeper.sh/system: "yes"
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: gatekeeper-webhook-service
+  namespace: gatekeeper-system
+  labels:
+    gatekeeper.sh/system: "yes"
+spec:
+  ports:
+  - port: 443
+    targetPort: 8443
+  selector:
+    control-plane: controller-manager
+    gatekeeper.sh/operation: webhook
+    gatekeeper.sh/system: "yes"
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: gatekeeper-controller-manager
+  namespace: gatekeeper-system
+  labels:
+    control-plane: controller-manager
+    gatekeeper.sh/operation: webhook
+    gatekeeper.sh/system: "yes"
+spec:
+  replicas: {{ .Replicas }}
+  selector:
+    matchLabels:
+      control-plane: controller-manager
+      gatekeeper.sh/operation: webhook
+      gatekeeper.sh/system: "yes"
+  template:
+    metadata:
+      labels:
+        control-plane: controller-manager
+        gatekeeper.sh/operation: webhook
+        gatekeeper.sh/system: "yes"
+    spec:
+      serviceAccountName: gatekeeper-admin
+      nodeSelector:
+        kubernetes.io/os: linux
+      terminationGracePeriodSeconds: 60
+      containers:
+      - name: manager
+        image: {{ .ImageName }}
+        imagePullPolicy: IfNotPresent
+        command:
+        - /manager
+        args:
+        - --port=8443
+        - --logtostderr
+        - --exempt-namespace=gatekeeper-system
+        - --operation=webhook
+        env:
+        - name: POD_NAMESPACE
+          valueFrom:
+            fieldRef:
+              apiVersion: v1
+              fieldPath: metadata.namespace
+        - name: POD_NAME
+          valueFrom:
+            fieldRef:
+              fieldPath: metadata.name
+        ports:
+        - containerPort: 8443
+          name: webhook-server
+          protocol: TCP
+        - containerPort: 8888
+          name: metrics
+          protocol: TCP
+        - containerPort: 9090
+          name: healthz
+          protocol: TCP
+        livenessProbe:
+          httpGet:
+            path: /healthz
+            port: 9090
+        readinessProbe:
+          httpGet:
+            path: /readyz
+            port: 9090
+        resources:
+          limits:
+            cpu: 1000m
+            memory: 512Mi
+          requests:
+            cpu: 100m
+            memory: 256Mi
+        securityContext:
+          allowPrivilegeEscalation: false
+          capabilities:
+            drop:
+            - all
+          runAsGroup: 999
+          runAsNonRoot: true
+          runAsUser: 1000
+        volumeMounts:
+        - mountPath: /certs
+          name: cert
+          readOnly: true
+      volumes:
+      - name: cert
+        secret:
+          defaultMode: 420
+          secretName: gatekeeper-webhook-server-cert
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: gatekeeper-audit
+  namespace: gatekeeper-system
+  labels:
+    control-plane: audit-controller
+    gatekeeper.sh/operation: audit
+    gatekeeper.sh/system: "yes"
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      control-plane: audit-controller
+      gatekeeper.sh/operation: audit
+      gatekeeper.sh/system: "yes"
+  template:
+    metadata:
+      labels:
+        control-plane: audit-controller
+        gatekeeper.sh/operation: audit
+        gatekeeper.sh/system: "yes"
+    spec:
+      serviceAccountName: gatekeeper-admin
+      nodeSelector:
+        kubernetes.io/os: linux
+      terminationGracePeriodSeconds: 60
+      containers:
+      - name: manager
+        image: {{ .ImageName }}
+        imagePullPolicy: IfNotPresent
+        command:
+        - /manager
+        args:
+        - --operation=audit
+        - --operation=status
+        - --logtostderr
+        - --audit-interval={{ .AuditInterval }}
+        env:
+        - name: POD_NAMESPACE
+          valueFrom:
+            fieldRef:
+              apiVersion: v1
+              fieldPath: metadata.namespace
+        - name: POD_NAME
+          valueFrom:
+            fieldRef:
+              fieldPath: metadata.name
+        ports:
+        - containerPort: 8888
+          name: metrics
+          protocol: TCP
+        - containerPort: 9090
+          name: healthz
+          protocol: TCP
+        livenessProbe:
+          httpGet:
+            path: /healthz
+            port: 9090
+        readinessProbe:
+          httpGet:
+            path: /readyz
+            port: 9090
+        resources:
+          limits:
+            cpu: 1000m
+            memory: 512Mi
+          requests:
+            cpu: 100m
+            memory: 256Mi
+        securityContext:
+          allowPrivilegeEscalation: false
+          capabilities:
+            drop:
+            - all
+          runAsGroup: 999
+          runAsNonRoot: true
+          runAsUser: 1000
+---
+apiVersion: admissionregistration.k8s.io/v1beta1
+kind: ValidatingWebhookConfiguration
+metadata:
+  name: gatekeeper-validating-webhook-configuration
+  labels:
+    gatekeeper.sh/system: "yes"
+webhooks:
+- name: validation.gatekeeper.sh
+  clientConfig:
+    service:
+      name: gatekeeper-webhook-service
+      namespace: gatekeeper-system
+      path: /v1/admit
+  failurePolicy: Ignore
+  sideEffects: None
+  timeoutSeconds: 3
+  namespaceSelector:
+    matchExpressions:
+    - key: admission.gatekeeper.sh/ignore
+      operator: DoesNotExist
+  rules:
+  - apiGroups: ["*"]
+    apiVersions: ["*"]
+    operations: ["CREATE", "UPDATE"]
+    resources: ["*"]
+- name: check-ignore-label.gatekeeper.sh
+  clientConfig:
+    service:
+      name: gatekeeper-webhook-service
+      namespace: gatekeeper-system
+      path: /v1/admitlabel
+  failurePolicy: Fail
+  sideEffects: None
+  timeoutSeconds: 3
+  rules:
+  - apiGroups: [""]
+    apiVersions: ["*"]
+    operations: ["CREATE", "UPDATE"]
+    resources: ["namespaces"]
+`
+)
+
+type Option struct {
+	ImageName     string
+	Replicas      int
+	AuditInterval int
+}
+
+func BuildGatekeeperAddon(cfg *config.Config, c *common.Cluster) ([]runtime.Object, error) {
+	opt := &Option{
+		ImageName:     cfg.ImageFullName("gatekeeper", Version),
+		Replicas:      3,
+		AuditInterval: 60,
+	}
+	data, err := template.ParseString(gatekeeperTemplate, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	objs, err := k8sutil.LoadObjs(bytes.NewReader(data))
+	if err != nil {
+		klog.Errorf("gatekeeper load objs err: %v", err)
+		return nil, err
+	}
+
+	inventory.Mark(inventory.Gatekeeper, objs...)
+	return objs, nil
+}
+
+// BuildBaselineObjs loads the baseline ConstraintTemplates and Constraints kept in
+// ConfigMaps labeled with constants.GatekeeperBaselineLabel on the meta cluster.
+// ConstraintTemplates are returned first, the constraint kinds only exist after them.
+func BuildBaselineObjs(ctx context.Context, cli client.Client) ([]*unstructured.Unstructured, error) {
+	cms := &corev1.ConfigMapList{}
+	err := cli.List(ctx, cms, client.MatchingLabels{constants.GatekeeperBaselineLabel: "true"})
+	if err != nil {
+		return nil, errors.Wrapf(err, "list gatekeeper baseline configmaps")
+	}
+
+	objs := make([]*unstructured.Unstructured, 0)
+	for i := range cms.Items {
+		cm := &cms.Items[i]
+		keys := make([]string, 0, len(cm.Data))
+		for k := range cm.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			us, err := k8sutil.LoadUnstructuredObjs(strings.NewReader(cm.Data[k]))
+			if err != nil {
+				return nil, errors.Wrapf(err, "load baseline %s/%s key: %s", cm.Namespace, cm.Name, k)
+			}
+			objs = append(objs, us...)
+		}
+	}
+
+	sort.SliceStable(objs, func(i, j int) bool {
+		return isTemplate(objs[i]) && !isTemplate(objs[j])
+	})
+	for _, obj := range objs {
+		inventory.Mark(inventory.Gatekeeper, obj)
+	}
+	return objs, nil
+}
+
+func isTemplate(obj *unstructured.Unstructured) bool {
+	return obj.GroupVersionKind().Group == templateGroup
+}
+
+// ApplyGatekeeper installs gatekeeper into the member cluster and syncs the baseline policies from the meta cluster.
+func ApplyGatekeeper(ctx context.Context, cfg *config.Config, c *common.Cluster) (err error) {
+	ctx, span := tracing.StartAddon(ctx, inventory.Gatekeeper)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	clusterCtx, err := c.ClusterManager.Get(c.Name)
+	if err != nil {
+		return c.SkipReconcile("ApplyGatekeeper", err)
+	}
+
+	objs, err := BuildGatekeeperAddon(cfg, c)
+	if err != nil {
+		return errors.Wrapf(err, "build gatekeeper err: %v", err)
+	}
+
+	logger := ctrl.Log.WithValues("cluster", c.Name, "component", "gatekeeper")
+	logger.Info("start reconcile ...")
+	for _, obj := range objs {
+		switch obj.(type) {
+		case *corev1.Secret, *admissionv1beta1.ValidatingWebhookConfiguration:
+			// gatekeeper injects the webhook cert and caBundle itself, only create them once
+			err = clusterCtx.Client.Create(ctx, obj)
+			if err != nil && !apierrors.IsAlreadyExists(err) {
+				return errors.Wrapf(err, "create err: %v", err)
+			}
+		default:
+			err = k8sutil.Reconcile(logger, clusterCtx.Client, obj, k8sutil.DesiredStatePresent)
+			if err != nil {
+				return errors.Wrapf(err, "Reconcile  err: %v", err)
+			}
+		}
+	}
+
+	baseline, err := BuildBaselineObjs(ctx, c.Client)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range baseline {
+		err = k8sutil.Reconcile(logger, clusterCtx.Client, obj, k8sutil.DesiredStatePresent)
+		if err != nil {
+			if meta.IsNoMatchError(errors.Cause(err)) {
+				// constraint crd is created by gatekeeper after the template, sync it next time
+				logger.Info("constraint kind not ready, skip", "kind", obj.GetKind(), "name", obj.GetName())
+				continue
+			}
+			return errors.Wrapf(err, "Reconcile baseline %s/%s err: %v", obj.GetKind(), obj.GetName(), err)
+		}
+	}
+
+	// the baseline policies removed from the meta cluster are deleted
+	keep := objs
+	for _, obj := range baseline {
+		keep = append(keep, obj)
+	}
+	deleted, err := inventory.Prune(ctx, clusterCtx.Client, inventory.Gatekeeper, inventory.Kinds(keep...), keep...)
+	if len(deleted) > 0 {
+		logger.Info("pruned", "deleted", deleted)
+	}
+	return err
+}
@@ -0,0 +1,204 @@
+// Package inventory tracks the objects of the addons created in the member clusters by the addon label,
+// the objects no longer desired, e.g. of the disabled addons or of the deleted cluster, are collected by Prune.
+package inventory
+
+import (
+	"context"
+	"fmt"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/k8sclient"
+	"github.com/gostship/kunkka/pkg/tracing"
+	"github.com/gostship/kunkka/pkg/util/k8sutil"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// the names of the addons
+const (
+	Flannel       = "flannel"
+	Multus        = "multus"
+	Gatekeeper    = "gatekeeper"
+	CoreDNS       = "coredns"
+	KubeProxy     = "kube-proxy"
+	MetricsServer = "metrics-server"
+)
+
+// DefaultKinds the kinds of the objects of the addons, the namespaces and the crds go last for deleting
+// them deletes the objects in them as well.
+var DefaultKinds = []schema.GroupVersionKind{
+	{Group: "apps", Version: "v1", Kind: "Deployment"},
+	{Group: "apps", Version: "v1", Kind: "DaemonSet"},
+	{Group: "apps", Version: "v1", Kind: "StatefulSet"},
+	{Version: "v1", Kind: "Service"},
+	{Version: "v1", Kind: "ConfigMap"},
+	{Version: "v1", Kind: "Secret"},
+	{Version: "v1", Kind: "ServiceAccount"},
+	{Group: "policy", Version: "v1beta1", Kind: "PodDisruptionBudget"},
+	{Group: "policy", Version: "v1beta1", Kind: "PodSecurityPolicy"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "RoleBinding"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "Role"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRoleBinding"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"},
+	{Group: "admissionregistration.k8s.io", Version: "v1beta1", Kind: "ValidatingWebhookConfiguration"},
+	{Group: "apiregistration.k8s.io", Version: "v1beta1", Kind: "APIService"},
+	{Group: "apiextensions.k8s.io", Version: "v1beta1", Kind: "CustomResourceDefinition"},
+	{Version: "v1", Kind: "Namespace"},
+}
+
+// Mark labels the objects with the addon, the labels are applied with the objects.
+func Mark(addon string, objs ...runtime.Object) {
+	for _, obj := range objs {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			klog.Warningf("addon: %s mark %T err: %v", addon, obj, err)
+			continue
+		}
+		labels := accessor.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[constants.AddonLabel] = addon
+		labels[constants.CreatedByLabel] = constants.CreatedBy
+		accessor.SetLabels(labels)
+	}
+}
+
+// Apply reconciles the objects of the addon in the cluster to the state, in the span of the addon.
+func Apply(ctx context.Context, cli client.Client, cluster, addon string, objs []runtime.Object, state k8sutil.DesiredState) (err error) {
+	_, span := tracing.StartAddon(ctx, addon)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	logger := ctrl.Log.WithValues("cluster", cluster, "component", addon)
+	logger.Info("start reconcile ...")
+	for _, obj := range objs {
+		err = k8sutil.Reconcile(logger, cli, obj, state)
+		if err != nil {
+			return errors.Wrapf(err, "reconcile %s", addon)
+		}
+	}
+	return nil
+}
+
+// Kinds returns the kinds of the objects in order, without duplicates.
+func Kinds(objs ...runtime.Object) []schema.GroupVersionKind {
+	kinds := make([]schema.GroupVersionKind, 0)
+	seen := make(map[schema.GroupVersionKind]bool)
+	for _, obj := range objs {
+		gvk, err := apiutil.GVKForObject(obj, k8sclient.GetScheme())
+		if err != nil {
+			klog.Warningf("get kind of %T err: %v", obj, err)
+			continue
+		}
+		if !seen[gvk] {
+			seen[gvk] = true
+			kinds = append(kinds, gvk)
+		}
+	}
+	return kinds
+}
+
+// Prune deletes the objects of the kinds labeled with the addon but the kept ones, the objects of all the
+// addons if addon is empty. The kinds not served by the cluster are skipped. It returns the deleted objects.
+func Prune(ctx context.Context, cli client.Client, addon string, kinds []schema.GroupVersionKind, keep ...runtime.Object) ([]string, error) {
+	var opt client.ListOption = client.HasLabels{constants.AddonLabel}
+	if addon != "" {
+		opt = client.MatchingLabels{constants.AddonLabel: addon}
+	}
+
+	kept := sets.NewString()
+	for _, obj := range keep {
+		gvk, err := apiutil.GVKForObject(obj, k8sclient.GetScheme())
+		if err != nil {
+			return nil, errors.Wrapf(err, "get kind of %T", obj)
+		}
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return nil, err
+		}
+		kept.Insert(objectName(gvk, accessor))
+	}
+
+	deleted := make([]string, 0)
+	for _, gvk := range kinds {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		err := cli.List(ctx, list, opt)
+		if err != nil {
+			if meta.IsNoMatchError(err) || apierrors.IsNotFound(err) {
+				continue
+			}
+			return deleted, errors.Wrapf(err, "list %s", gvk.Kind)
+		}
+
+		for i := range list.Items {
+			obj := &list.Items[i]
+			name := objectName(gvk, obj)
+			if kept.Has(name) || obj.GetDeletionTimestamp() != nil {
+				continue
+			}
+			err = cli.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground))
+			if err != nil && !apierrors.IsNotFound(err) {
+				return deleted, errors.Wrapf(err, "delete %s", name)
+			}
+			deleted = append(deleted, name)
+		}
+	}
+	return deleted, nil
+}
+
+// Disabled returns the addons which can be turned off by the spec of the cluster and are off.
+func Disabled(c *devopsv1.Cluster) []string {
+	var addons []string
+	if c.Spec.Features.Hooks[devopsv1.HookCniInstall] != Flannel {
+		addons = append(addons, Flannel)
+	}
+	if !c.Spec.Features.Multus {
+		addons = append(addons, Multus)
+	}
+	if c.Spec.Features.Hooks[devopsv1.HookPolicyInstall] != Gatekeeper {
+		addons = append(addons, Gatekeeper)
+	}
+	return addons
+}
+
+// CollectDisabled deletes the objects of the addons disabled in the spec of the cluster, e.g. the DaemonSets
+// of multus after the feature is turned off.
+func CollectDisabled(ctx context.Context, c *common.Cluster) error {
+	clusterCtx, err := c.ClusterManager.Get(c.Name)
+	if err != nil {
+		return errors.Wrapf(err, "get cluster: %s client", c.Name)
+	}
+
+	for _, addon := range Disabled(c.Cluster) {
+		deleted, err := Prune(ctx, clusterCtx.Client, addon, DefaultKinds)
+		if len(deleted) > 0 {
+			klog.Infof("cluster: %s addon: %s is disabled, deleted: %v", c.Name, addon, deleted)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "collect addon: %s", addon)
+		}
+	}
+	return nil
+}
+
+func objectName(gvk schema.GroupVersionKind, obj metav1.Object) string {
+	if obj.GetNamespace() == "" {
+		return fmt.Sprintf("%s/%s", gvk.Kind, obj.GetName())
+	}
+	return fmt.Sprintf("%s/%s/%s", gvk.Kind, obj.GetNamespace(), obj.GetName())
+}
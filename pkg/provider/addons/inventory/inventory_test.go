@@ -0,0 +1,83 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestDisabled(t *testing.T) {
+	tests := []struct {
+		name     string
+		features devopsv1.ClusterFeature
+		want     []string
+	}{
+		{
+			name: "all disabled",
+			want: []string{Flannel, Multus, Gatekeeper},
+		},
+		{
+			name: "all enabled",
+			features: devopsv1.ClusterFeature{
+				Multus: true,
+				Hooks:  map[devopsv1.HookType]string{devopsv1.HookCniInstall: Flannel, devopsv1.HookPolicyInstall: Gatekeeper},
+			},
+		},
+		{
+			name: "other cni",
+			features: devopsv1.ClusterFeature{
+				Multus: true,
+				Hooks:  map[devopsv1.HookType]string{devopsv1.HookCniInstall: "dke-cni", devopsv1.HookPolicyInstall: Gatekeeper},
+			},
+			want: []string{Flannel},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &devopsv1.Cluster{Spec: devopsv1.ClusterSpec{Features: tt.features}}
+			if got := Disabled(c); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Disabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkKinds(t *testing.T) {
+	nad := &unstructured.Unstructured{}
+	nad.SetGroupVersionKind(schema.GroupVersionKind{Group: "k8s.cni.cncf.io", Version: "v1", Kind: "NetworkAttachmentDefinition"})
+	objs := []runtime.Object{
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "kube-multus-ds", Labels: map[string]string{"app": "multus"}}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "multus-cni-config"}},
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "kube-multus-ds-arm64"}},
+		nad,
+	}
+
+	Mark(Multus, objs...)
+	for _, obj := range objs {
+		labels := obj.(metav1.Object).GetLabels()
+		if labels[constants.AddonLabel] != Multus || labels[constants.CreatedByLabel] != constants.CreatedBy {
+			t.Errorf("Mark() %T labels = %v", obj, labels)
+		}
+	}
+	if objs[0].(metav1.Object).GetLabels()["app"] != "multus" {
+		t.Errorf("Mark() dropped the labels of the object")
+	}
+
+	want := []schema.GroupVersionKind{
+		{Group: "apps", Version: "v1", Kind: "DaemonSet"},
+		{Version: "v1", Kind: "ConfigMap"},
+		nad.GroupVersionKind(),
+	}
+	if got := Kinds(objs...); !reflect.DeepEqual(got, want) {
+		t.Errorf("Kinds() = %v, want %v", got, want)
+	}
+}
@@ -39,6 +39,8 @@ spec:
         - mountPath: /etc/localtime
           name: host-time
       dnsPolicy: ClusterFirst
+      nodeSelector:
+        kubernetes.io/arch: amd64
       restartPolicy: Always
       schedulerName: default-scheduler
       securityContext: {}
@@ -8,6 +8,7 @@ import (
 	kubeproxyv1alpha1 "github.com/gostship/kunkka/pkg/apis/kubeproxy/config/v1alpha1"
 	"github.com/gostship/kunkka/pkg/constants"
 	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/addons/inventory"
 	"github.com/gostship/kunkka/pkg/provider/config"
 	"github.com/gostship/kunkka/pkg/provider/phases/certs"
 	"github.com/gostship/kunkka/pkg/provider/phases/kubemisc"
@@ -296,5 +297,6 @@ func BuildKubeproxyAddon(cfg *config.Config, c *common.Cluster) ([]runtime.Objec
 	objs = append(objs, crb)
 	objs = append(objs, role)
 	objs = append(objs, rb)
+	inventory.Mark(inventory.KubeProxy, objs...)
 	return objs, nil
 }
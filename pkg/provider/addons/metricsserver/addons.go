@@ -4,6 +4,7 @@ import (
 	"bytes"
 
 	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/addons/inventory"
 	"github.com/gostship/kunkka/pkg/util/k8sutil"
 	"github.com/gostship/kunkka/pkg/util/template"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -11,6 +12,9 @@ import (
 )
 
 const (
+	// Version the version of the metrics-server image, the images are multi-arch since v0.3.7
+	Version = "v0.3.7"
+
 	metricsServerTemplate = `
 ---
 apiVersion: rbac.authorization.k8s.io/v1
@@ -97,7 +101,7 @@ spec:
         emptyDir: {}
       containers:
       - name: metrics-server
-        image: {{ default "registry.cn-hangzhou.aliyuncs.com/google_containers/metrics-server-amd64:v0.3.6" .ImageName }}  
+        image: {{ default "registry.cn-hangzhou.aliyuncs.com/google_containers/metrics-server:` + Version + `" .ImageName }}  
         imagePullPolicy: IfNotPresent
         args:
           - --cert-dir=/tmp
@@ -117,7 +121,6 @@ spec:
           mountPath: /tmp
       nodeSelector:
         kubernetes.io/os: linux
-        kubernetes.io/arch: "amd64"
 ---
 apiVersion: v1
 kind: Service
@@ -187,5 +190,6 @@ func BuildMetricsServerAddon(c *common.Cluster) ([]runtime.Object, error) {
 		return nil, err
 	}
 
+	inventory.Mark(inventory.MetricsServer, objs...)
 	return objs, nil
 }
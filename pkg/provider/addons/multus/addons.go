@@ -0,0 +1,376 @@
+package multus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/addons/inventory"
+	"github.com/gostship/kunkka/pkg/provider/config"
+	"github.com/gostship/kunkka/pkg/tracing"
+	"github.com/gostship/kunkka/pkg/util/k8sutil"
+	"github.com/gostship/kunkka/pkg/util/template"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// networkAttachmentGVK the kind of the secondary networks
+var networkAttachmentGVK = schema.GroupVersionKind{Group: "k8s.cni.cncf.io", Version: "v1", Kind: "NetworkAttachmentDefinition"}
+
+const (
+	// Version the version of the multus image
+	Version = "v3.6"
+
+	// networkAttachmentCrd the name of the crd of the secondary networks
+	networkAttachmentCrd = "network-attachment-definitions.k8s.cni.cncf.io"
+	// crdEstablishTimeout how long the network attachments wait for their crd applied in the same pass
+	crdEstablishTimeout = 30 * time.Second
+
+	resourceNameAnnotation = "k8s.v1.cni.cncf.io/resourceName"
+
+	multusTemplate = `
+---
+apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: network-attachment-definitions.k8s.cni.cncf.io
+spec:
+  group: k8s.cni.cncf.io
+  scope: Namespaced
+  names:
+    plural: network-attachment-definitions
+    singular: network-attachment-definition
+    kind: NetworkAttachmentDefinition
+    shortNames:
+    - net-attach-def
+  versions:
+  - name: v1
+    served: true
+    storage: true
+  validation:
+    openAPIV3Schema:
+      description: 'NetworkAttachmentDefinition is a CRD schema specified by the Network Plumbing
+        Working Group to express the intent for attaching pods to one or more logical or physical
+        networks. More information available at: https://github.com/k8snetworkplumbingwg/multi-net-spec'
+      type: object
+      properties:
+        spec:
+          description: 'NetworkAttachmentDefinition spec defines the desired state of a network attachment'
+          type: object
+          properties:
+            config:
+              description: 'NetworkAttachmentDefinition config is a JSON-formatted CNI configuration'
+              type: string
+---
+kind: ClusterRole
+apiVersion: rbac.authorization.k8s.io/v1
+metadata:
+  name: multus
+rules:
+- apiGroups: ["k8s.cni.cncf.io"]
+  resources: ["*"]
+  verbs: ["*"]
+- apiGroups: [""]
+  resources: ["pods", "pods/status"]
+  verbs: ["get", "update"]
+- apiGroups: ["", "events.k8s.io"]
+  resources: ["events"]
+  verbs: ["create", "patch", "update"]
+---
+kind: ClusterRoleBinding
+apiVersion: rbac.authorization.k8s.io/v1
+metadata:
+  name: multus
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: multus
+subjects:
+- kind: ServiceAccount
+  name: multus
+  namespace: kube-system
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: multus
+  namespace: kube-system
+{{- range $arch, $image := .Images }}
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: kube-multus-ds{{ if ne $arch "amd64" }}-{{ $arch }}{{ end }}
+  namespace: kube-system
+  labels:
+    tier: node
+    app: multus
+    name: multus
+spec:
+  selector:
+    matchLabels:
+      name: multus
+  updateStrategy:
+    type: RollingUpdate
+  template:
+    metadata:
+      labels:
+        tier: node
+        app: multus
+        name: multus
+    spec:
+      hostNetwork: true
+      nodeSelector:
+        kubernetes.io/arch: {{ $arch }}
+      tolerations:
+      - operator: Exists
+        effect: NoSchedule
+      serviceAccountName: multus
+      containers:
+      - name: kube-multus
+        image: {{ $image }}
+        command: ["/entrypoint.sh"]
+        args:
+        - "--multus-conf-file=auto"
+        - "--cni-version=0.3.1"
+        resources:
+          requests:
+            cpu: "100m"
+            memory: "50Mi"
+          limits:
+            cpu: "100m"
+            memory: "50Mi"
+        securityContext:
+          privileged: true
+        volumeMounts:
+        - name: cni
+          mountPath: /host/etc/cni/net.d
+        - name: cnibin
+          mountPath: /host/opt/cni/bin
+      terminationGracePeriodSeconds: 10
+      volumes:
+      - name: cni
+        hostPath:
+          path: /etc/cni/net.d
+      - name: cnibin
+        hostPath:
+          path: /opt/cni/bin
+{{- end }}
+`
+)
+
+// archSuffixes the tag suffixes of the multus images of the archs, the suffixes differ from the arch names
+var archSuffixes = map[string]string{constants.ArchARM64: "-arm64v8"}
+
+type Option struct {
+	ImageName string
+	// Images the images of the daemonset of each arch, the multus images are single-arch
+	Images map[string]string
+}
+
+func BuildMultusAddon(cfg *config.Config, c *common.Cluster) ([]runtime.Object, error) {
+	opt := &Option{
+		ImageName: cfg.ImageFullName("multus-cni", Version),
+	}
+	opt.Images = constants.ArchImages(opt.ImageName, archSuffixes)
+	data, err := template.ParseString(multusTemplate, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	objs, err := k8sutil.LoadObjs(bytes.NewReader(data))
+	if err != nil {
+		klog.Errorf("multus load objs err: %v", err)
+		return nil, err
+	}
+
+	inventory.Mark(inventory.Multus, objs...)
+	return objs, nil
+}
+
+// BuildNetworkAttachmentConfig renders the cni json config of the secondary network.
+func BuildNetworkAttachmentConfig(na *devopsv1.NetworkAttachment) (string, error) {
+	if na.Config != "" {
+		if !json.Valid([]byte(na.Config)) {
+			return "", fmt.Errorf("network attachment: %s config is not valid json", na.Name)
+		}
+		return na.Config, nil
+	}
+
+	if na.Type == "" {
+		return "", fmt.Errorf("network attachment: %s type or config is required", na.Name)
+	}
+
+	ipam := json.RawMessage(`{"type": "dhcp"}`)
+	if na.IPAM != "" {
+		if !json.Valid([]byte(na.IPAM)) {
+			return "", fmt.Errorf("network attachment: %s ipam is not valid json", na.Name)
+		}
+		ipam = json.RawMessage(na.IPAM)
+	}
+
+	conf := map[string]interface{}{
+		"cniVersion": "0.3.1",
+		"name":       na.Name,
+		"type":       na.Type,
+	}
+	if na.Master != "" {
+		if na.Type == "host-device" {
+			conf["device"] = na.Master
+		} else {
+			conf["master"] = na.Master
+		}
+	}
+
+	mode := na.Mode
+	switch na.Type {
+	case "macvlan":
+		if mode == "" {
+			mode = "bridge"
+		}
+	case "ipvlan":
+		if mode == "" {
+			mode = "l2"
+		}
+	}
+	if mode != "" {
+		conf["mode"] = mode
+	}
+	if na.VlanID != 0 {
+		conf["vlan"] = na.VlanID
+	}
+	if na.MTU != 0 {
+		conf["mtu"] = na.MTU
+	}
+	// sriov and host-device get the address from the device plugin / host, others need ipam
+	if (na.Type != "sriov" && na.Type != "host-device") || na.IPAM != "" {
+		conf["ipam"] = ipam
+	}
+
+	data, err := json.Marshal(conf)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// BuildNetworkAttachments builds the NetworkAttachmentDefinitions declared in the cluster spec.
+func BuildNetworkAttachments(c *common.Cluster) ([]*unstructured.Unstructured, error) {
+	objs := make([]*unstructured.Unstructured, 0, len(c.Spec.NetworkAttachments))
+	for i := range c.Spec.NetworkAttachments {
+		na := &c.Spec.NetworkAttachments[i]
+		conf, err := BuildNetworkAttachmentConfig(na)
+		if err != nil {
+			return nil, err
+		}
+
+		ns := na.Namespace
+		if ns == "" {
+			ns = "default"
+		}
+
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(networkAttachmentGVK)
+		obj.SetName(na.Name)
+		obj.SetNamespace(ns)
+		if na.ResourceName != "" {
+			obj.SetAnnotations(map[string]string{resourceNameAnnotation: na.ResourceName})
+		}
+		err = unstructured.SetNestedField(obj.Object, conf, "spec", "config")
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, obj)
+	}
+
+	for _, obj := range objs {
+		inventory.Mark(inventory.Multus, obj)
+	}
+	return objs, nil
+}
+
+// ApplyMultus installs multus and the NetworkAttachmentDefinitions of the cluster.
+func ApplyMultus(ctx context.Context, cfg *config.Config, c *common.Cluster) (err error) {
+	ctx, span := tracing.StartAddon(ctx, inventory.Multus)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	clusterCtx, err := c.ClusterManager.Get(c.Name)
+	if err != nil {
+		return c.SkipReconcile("ApplyMultus", err)
+	}
+
+	objs, err := BuildMultusAddon(cfg, c)
+	if err != nil {
+		return errors.Wrapf(err, "build multus err: %v", err)
+	}
+
+	logger := ctrl.Log.WithValues("cluster", c.Name, "component", "multus")
+	logger.Info("start reconcile ...")
+	for _, obj := range objs {
+		err = k8sutil.Reconcile(logger, clusterCtx.Client, obj, k8sutil.DesiredStatePresent)
+		if err != nil {
+			return errors.Wrapf(err, "Reconcile  err: %v", err)
+		}
+	}
+
+	nads, err := BuildNetworkAttachments(c)
+	if err != nil {
+		return errors.Wrapf(err, "build network attachments err: %v", err)
+	}
+
+	keep := objs
+	if len(nads) > 0 {
+		err = k8sutil.WaitCrdEstablished(ctx, clusterCtx.Client, networkAttachmentCrd, time.Second, crdEstablishTimeout)
+		if err != nil {
+			return errors.Wrapf(err, "wait crd %s established err: %v", networkAttachmentCrd, err)
+		}
+	}
+	for _, obj := range nads {
+		err = reconcileNetworkAttachment(logger, clusterCtx.Client, obj)
+		if err != nil {
+			return errors.Wrapf(err, "Reconcile network attachment %s/%s err: %v", obj.GetNamespace(), obj.GetName(), err)
+		}
+		keep = append(keep, obj)
+	}
+
+	// the network attachments removed from the spec are deleted
+	kinds := append(inventory.Kinds(objs...), networkAttachmentGVK)
+	deleted, err := inventory.Prune(ctx, clusterCtx.Client, inventory.Multus, kinds, keep...)
+	if len(deleted) > 0 {
+		logger.Info("pruned", "deleted", deleted)
+	}
+	return err
+}
+
+// reconcileNetworkAttachment applies the network attachment, the rest mapper of the cluster client reloads the
+// kinds of the established crd after a no match, the error requeues the cluster if the kind is still not served.
+func reconcileNetworkAttachment(logger logr.Logger, cli client.Client, obj *unstructured.Unstructured) error {
+	var err error
+	pollErr := wait.PollImmediate(time.Second, crdEstablishTimeout, func() (bool, error) {
+		err = k8sutil.Reconcile(logger, cli, obj, k8sutil.DesiredStatePresent)
+		if err != nil && meta.IsNoMatchError(errors.Cause(err)) {
+			logger.Info("network attachment kind not served yet, retry", "name", obj.GetName())
+			return false, nil
+		}
+		return true, nil
+	})
+	if pollErr != nil {
+		return errors.Wrapf(err, "network attachment kind not served after %v", crdEstablishTimeout)
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+package multus
+
+import (
+	"encoding/json"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/config"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+func TestBuildNetworkAttachmentConfig(t *testing.T) {
+	tests := []struct {
+		na       devopsv1.NetworkAttachment
+		wantIPAM bool
+	}{
+		{na: devopsv1.NetworkAttachment{Name: "macvlan", Type: "macvlan", Master: "eth1"}, wantIPAM: true},
+		{na: devopsv1.NetworkAttachment{Name: "sriov", Type: "sriov"}},
+		{na: devopsv1.NetworkAttachment{Name: "host-device", Type: "host-device", Master: "eth2"}},
+		{na: devopsv1.NetworkAttachment{Name: "host-device-ipam", Type: "host-device", IPAM: `{"type": "static"}`}, wantIPAM: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.na.Name, func(t *testing.T) {
+			data, err := BuildNetworkAttachmentConfig(&tt.na)
+			if err != nil {
+				t.Fatal(err)
+			}
+			conf := map[string]interface{}{}
+			if err := json.Unmarshal([]byte(data), &conf); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := conf["ipam"]; ok != tt.wantIPAM {
+				t.Errorf("BuildNetworkAttachmentConfig() = %s, ipam %v, want %v", data, ok, tt.wantIPAM)
+			}
+		})
+	}
+}
+
+func TestBuildMultusAddon(t *testing.T) {
+	cfg, err := config.NewDefaultConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs, err := BuildMultusAddon(cfg, &common.Cluster{Cluster: &devopsv1.Cluster{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the images of the daemonsets match the arch of their nodes
+	images := map[string]string{}
+	for _, obj := range objs {
+		ds, ok := obj.(*appsv1.DaemonSet)
+		if !ok {
+			continue
+		}
+		images[ds.Spec.Template.Spec.NodeSelector["kubernetes.io/arch"]] = ds.Spec.Template.Spec.Containers[0].Image
+	}
+	want := map[string]string{
+		"amd64": cfg.ImageFullName("multus-cni", Version),
+		"arm64": cfg.ImageFullName("multus-cni", Version) + "-arm64v8",
+	}
+	if len(images) != len(want) || images["amd64"] != want["amd64"] || images["arm64"] != want["arm64"] {
+		t.Errorf("images = %v, want %v", images, want)
+	}
+}
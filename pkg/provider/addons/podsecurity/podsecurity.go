@@ -0,0 +1,141 @@
+package podsecurity
+
+import (
+	"context"
+
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/tracing"
+	"github.com/gostship/kunkka/pkg/util/apiclient"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
+)
+
+const (
+	// the levels of the Pod Security Standards
+	LevelPrivileged = "privileged"
+	LevelBaseline   = "baseline"
+	LevelRestricted = "restricted"
+
+	// the labels of the namespaces read by the PodSecurity admission
+	EnforceLabel = "pod-security.kubernetes.io/enforce"
+	AuditLabel   = "pod-security.kubernetes.io/audit"
+	WarnLabel    = "pod-security.kubernetes.io/warn"
+)
+
+var (
+	// Levels the levels of the Pod Security Standards
+	Levels = []string{LevelPrivileged, LevelBaseline, LevelRestricted}
+
+	// systemNamespaces run the control plane and the addons, which need the privileged pods
+	systemNamespaces = append([]string{"kube-public", "kube-node-lease", "gatekeeper-system"}, constants.SystemNamespaces...)
+)
+
+// ExemptNamespaces returns the namespaces whose labels are left as is.
+func ExemptNamespaces(c *common.Cluster) sets.String {
+	exempt := sets.NewString(systemNamespaces...)
+	if c.Spec.PodSecurity != nil {
+		exempt.Insert(c.Spec.PodSecurity.ExemptNamespaces...)
+	}
+	return exempt
+}
+
+// Labels returns the pod security labels of the cluster, nil if it's not set.
+func Labels(c *common.Cluster) map[string]string {
+	ps := c.Spec.PodSecurity
+	if ps == nil {
+		return nil
+	}
+
+	labels := map[string]string{
+		EnforceLabel: ps.Enforce,
+		AuditLabel:   ps.Enforce,
+		WarnLabel:    ps.Enforce,
+	}
+	if ps.Audit != "" {
+		labels[AuditLabel] = ps.Audit
+	}
+	if ps.Warn != "" {
+		labels[WarnLabel] = ps.Warn
+	}
+	return labels
+}
+
+// apply sets the labels of the namespace, nil labels remove the ones set before, returns whether it's changed.
+func apply(ns *corev1.Namespace, labels map[string]string) bool {
+	managed := ns.Annotations[constants.PodSecurityManaged] == "true"
+	if labels == nil {
+		if !managed {
+			return false
+		}
+		for _, key := range []string{EnforceLabel, AuditLabel, WarnLabel} {
+			delete(ns.Labels, key)
+		}
+		delete(ns.Annotations, constants.PodSecurityManaged)
+		return true
+	}
+
+	changed := !managed
+	if ns.Labels == nil {
+		ns.Labels = map[string]string{}
+	}
+	for key, want := range labels {
+		if ns.Labels[key] != want {
+			ns.Labels[key] = want
+			changed = true
+		}
+	}
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	ns.Annotations[constants.PodSecurityManaged] = "true"
+	return changed
+}
+
+// ApplyPodSecurity labels the namespaces of the cluster but the exempt ones with the pod security levels,
+// the namespaces created later are labeled by the next reconcile.
+func ApplyPodSecurity(ctx context.Context, c *common.Cluster) (err error) {
+	ctx, span := tracing.StartAddon(ctx, "pod-security")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	clusterCtx, err := c.ClusterManager.Get(c.Name)
+	if err != nil {
+		return c.SkipReconcile("ApplyPodSecurity", err)
+	}
+
+	labels := Labels(c)
+	if labels != nil {
+		if ok, err := apiclient.CheckVersion(c.Spec.Version, "< 1.23"); err == nil && ok {
+			klog.Warningf("cluster: %s version: %s has no PodSecurity admission, the pod security labels are not enforced", c.Name, c.Spec.Version)
+		}
+	}
+
+	nsList := &corev1.NamespaceList{}
+	err = clusterCtx.Client.List(ctx, nsList)
+	if err != nil {
+		return errors.Wrapf(err, "list namespaces")
+	}
+
+	exempt := ExemptNamespaces(c)
+	for i := range nsList.Items {
+		ns := &nsList.Items[i]
+		want := labels
+		if exempt.Has(ns.Name) {
+			want = nil
+		}
+		if !apply(ns, want) {
+			continue
+		}
+
+		err = clusterCtx.Client.Update(ctx, ns)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "update namespace: %s pod security labels", ns.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package podsecurity
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gostship/kunkka/pkg/constants"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestApply(t *testing.T) {
+	restricted := map[string]string{EnforceLabel: LevelRestricted, AuditLabel: LevelRestricted, WarnLabel: LevelRestricted}
+	managed := map[string]string{constants.PodSecurityManaged: "true"}
+
+	tests := []struct {
+		name        string
+		ns          *corev1.Namespace
+		labels      map[string]string
+		changed     bool
+		wantLabels  map[string]string
+		wantManaged bool
+	}{
+		{
+			name:        "label a new namespace",
+			ns:          &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app", Labels: map[string]string{"team": "a"}}},
+			labels:      restricted,
+			changed:     true,
+			wantLabels:  map[string]string{"team": "a", EnforceLabel: LevelRestricted, AuditLabel: LevelRestricted, WarnLabel: LevelRestricted},
+			wantManaged: true,
+		},
+		{
+			name:        "up to date",
+			ns:          &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app", Labels: restricted, Annotations: managed}},
+			labels:      restricted,
+			wantLabels:  restricted,
+			wantManaged: true,
+		},
+		{
+			name:        "relaxed by the user",
+			ns:          &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app", Labels: map[string]string{EnforceLabel: LevelPrivileged}, Annotations: managed}},
+			labels:      restricted,
+			changed:     true,
+			wantLabels:  restricted,
+			wantManaged: true,
+		},
+		{
+			name:       "removed",
+			ns:         &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app", Labels: map[string]string{"team": "a", EnforceLabel: LevelRestricted}, Annotations: map[string]string{constants.PodSecurityManaged: "true"}}},
+			changed:    true,
+			wantLabels: map[string]string{"team": "a"},
+		},
+		{
+			name:       "labeled by the user",
+			ns:         &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app", Labels: map[string]string{EnforceLabel: LevelBaseline}}},
+			wantLabels: map[string]string{EnforceLabel: LevelBaseline},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := tt.ns.DeepCopy()
+			if changed := apply(ns, tt.labels); changed != tt.changed {
+				t.Errorf("apply() = %v, want %v", changed, tt.changed)
+			}
+			if !reflect.DeepEqual(ns.Labels, tt.wantLabels) {
+				t.Errorf("apply() labels = %v, want %v", ns.Labels, tt.wantLabels)
+			}
+			if got := ns.Annotations[constants.PodSecurityManaged] == "true"; got != tt.wantManaged {
+				t.Errorf("apply() managed = %v, want %v", got, tt.wantManaged)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gostship/kunkka/pkg/util/sigv4"
+	"github.com/pkg/errors"
+)
+
+const (
+	requestTimeout = 30 * time.Second
+
+	serviceEC2 = "ec2"
+	serviceELB = "elasticloadbalancing"
+
+	ec2Version = "2016-11-15"
+	elbVersion = "2015-12-01"
+)
+
+// endpointOf returns the url of the query API of the service in the region, it's replaced by the tests.
+var endpointOf = func(service, region string) string {
+	return fmt.Sprintf("https://%s.%s.amazonaws.com/", service, region)
+}
+
+// apiError is the error response of the query APIs, the errors of EC2 are in Errors and the ones of ELB in Error.
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("aws api status: %d, code: %s, message: %s", e.Status, e.Code, e.Message)
+}
+
+func isCode(err error, code string) bool {
+	e, ok := errors.Cause(err).(*apiError)
+	return ok && e.Code == code
+}
+
+// client is the client of the query APIs of EC2 and ELB of a region signed by the signature version 4.
+type client struct {
+	region string
+	cred   sigv4.Credentials
+	http   *http.Client
+}
+
+func newClient(region string, cred sigv4.Credentials) *client {
+	return &client{
+		region: region,
+		cred:   cred,
+		http:   &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// do calls the action of the service with the parameters and decodes the xml response into out.
+func (c *client) do(ctx context.Context, service, action string, params url.Values, out interface{}) error {
+	form := url.Values{}
+	for k, v := range params {
+		form[k] = v
+	}
+	form.Set("Action", action)
+	version := ec2Version
+	if service == serviceELB {
+		version = elbVersion
+	}
+	form.Set("Version", version)
+	body := []byte(form.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpointOf(service, c.region), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	signer := &sigv4.Signer{Credentials: c.cred, Region: c.region, Service: service}
+	signer.Sign(req, body, time.Now())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		e := &apiError{Status: resp.StatusCode}
+		errResp := struct {
+			Errors []apiError `xml:"Errors>Error"`
+			Error  *apiError  `xml:"Error"`
+		}{}
+		if xml.Unmarshal(data, &errResp) == nil {
+			if len(errResp.Errors) > 0 {
+				e.Code, e.Message = errResp.Errors[0].Code, errResp.Errors[0].Message
+			} else if errResp.Error != nil {
+				e.Code, e.Message = errResp.Error.Code, errResp.Error.Message
+			}
+		}
+		return errors.Wrapf(e, "%s %s", service, action)
+	}
+	if out == nil {
+		return nil
+	}
+	return xml.Unmarshal(data, out)
+}
+
+// setList sets the list parameters of the values, e.g. SecurityGroupId.1 and SecurityGroupId.2.
+func setList(params url.Values, prefix string, values []string) {
+	for i, v := range values {
+		params.Set(prefix+"."+strconv.Itoa(i+1), v)
+	}
+}
@@ -0,0 +1,303 @@
+package aws
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/infra"
+	"github.com/gostship/kunkka/pkg/util/sigv4"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// the keys of the infrastructure secret
+	accessKeyIDKey     = "accessKeyID"
+	secretAccessKeyKey = "secretAccessKey"
+	sessionTokenKey    = "sessionToken"
+
+	apiserverPort = 6443
+	// maxELBName the max length of the names of the load balancers and the target groups
+	maxELBName = 32
+)
+
+// Driver launches the EC2 instances owned by the clusters, the instances are found by the name and the
+// ownership tags, the load balancers and the target groups by name.
+type Driver struct {
+	mu sync.Mutex
+	// clients the clients of each region and credential
+	clients map[string]*client
+}
+
+var _ infra.EndpointDriver = &Driver{}
+
+func NewDriver() *Driver {
+	return &Driver{clients: make(map[string]*client)}
+}
+
+func (d *Driver) Validate(in *devopsv1.Infrastructure, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	awsPath := fldPath.Child("aws")
+	spec := in.AWS
+	if spec == nil {
+		return append(allErrs, field.Required(awsPath, "must be set for the aws clusters"))
+	}
+	if spec.Region == "" {
+		allErrs = append(allErrs, field.Required(awsPath.Child("region"), ""))
+	}
+	if spec.AMI == "" {
+		allErrs = append(allErrs, field.Required(awsPath.Child("ami"), ""))
+	}
+	if spec.InstanceType == "" {
+		allErrs = append(allErrs, field.Required(awsPath.Child("instanceType"), ""))
+	}
+	if len(spec.Subnets) == 0 {
+		allErrs = append(allErrs, field.Required(awsPath.Child("subnets"), ""))
+	}
+	racks := make(map[string]bool)
+	for i, s := range spec.Subnets {
+		sPath := awsPath.Child("subnets").Index(i)
+		if s.SubnetID == "" {
+			allErrs = append(allErrs, field.Required(sPath.Child("subnetID"), ""))
+		}
+		if racks[s.RackTag] {
+			allErrs = append(allErrs, field.Duplicate(sPath.Child("rackTag"), s.RackTag))
+		}
+		racks[s.RackTag] = true
+	}
+	return allErrs
+}
+
+func (d *Driver) EnsureInstance(ctx context.Context, c *common.Cluster, spec *infra.InstanceSpec) (*infra.Instance, error) {
+	as := c.Spec.Infrastructure.AWS
+	cli, err := d.client(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := cli.findInstance(ctx, c.Name, spec.Name)
+	if err != nil {
+		return nil, err
+	}
+	if i == nil {
+		i, err = cli.runInstance(ctx, &runSpec{
+			Name:           spec.Name,
+			ClientToken:    clientToken(c, spec.Name),
+			AMI:            as.AMI,
+			InstanceType:   as.InstanceType,
+			KeyName:        as.KeyName,
+			SubnetID:       subnetOf(as, spec.Machine),
+			SecurityGroups: as.SecurityGroups,
+			Tags:           []tag{{Key: nameTag, Value: spec.Name}, ownedTag(c.Name)},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	instance := &infra.Instance{ID: i.InstanceID, Name: spec.Name}
+	if i.State == instanceRunning {
+		instance.IP = i.PrivateIPAddress
+	}
+	return instance, nil
+}
+
+func (d *Driver) DeleteInstance(ctx context.Context, c *common.Cluster, name string) error {
+	cli, err := d.client(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	i, err := cli.findInstance(ctx, c.Name, name)
+	if err != nil || i == nil {
+		return err
+	}
+	return cli.terminateInstance(ctx, i.InstanceID)
+}
+
+// EnsureEndpoint creates the network load balancer of the apiservers and the target group of the ips of the
+// masters, the targets are registered or deregistered once the masters changed. The endpoint is the dns name
+// of the load balancer.
+func (d *Driver) EnsureEndpoint(ctx context.Context, c *common.Cluster) (*infra.Endpoint, error) {
+	as := c.Spec.Infrastructure.AWS
+	if as.LoadBalancer == nil {
+		return nil, nil
+	}
+	cli, err := d.client(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	name := elbName(c.Name)
+	pending := &infra.Endpoint{Port: apiserverPort}
+	lb, err := cli.findLoadBalancer(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if lb == nil {
+		subnets := as.LoadBalancer.SubnetIDs
+		if len(subnets) == 0 {
+			subnets = []string{as.Subnets[0].SubnetID}
+		}
+		return pending, cli.createLoadBalancer(ctx, name, subnets, as.LoadBalancer.InternetFacing, ownedTag(c.Name))
+	}
+	if lb.State == lbFailed {
+		return nil, fmt.Errorf("load balancer: %s is failed", name)
+	}
+
+	tg, err := cli.findTargetGroup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if tg == "" {
+		vpc, err := cli.vpcOf(ctx, as.Subnets[0].SubnetID)
+		if err != nil {
+			return nil, err
+		}
+		tg, err = cli.createTargetGroup(ctx, name, vpc, apiserverPort, ownedTag(c.Name))
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = cli.ensureListener(ctx, lb.LoadBalancerArn, tg, apiserverPort)
+	if err != nil {
+		return nil, err
+	}
+	err = syncTargets(ctx, cli, tg, c.Spec.Machines)
+	if err != nil {
+		return nil, err
+	}
+
+	if lb.State != lbActive {
+		return pending, nil
+	}
+	return &infra.Endpoint{Host: lb.DNSName, Port: apiserverPort}, nil
+}
+
+// syncTargets registers the masters with ip and deregisters the others.
+func syncTargets(ctx context.Context, cli *client, tg string, masters []*devopsv1.ClusterMachine) error {
+	want := sets.NewString()
+	for _, m := range masters {
+		if m != nil && m.IP != "" {
+			want.Insert(m.IP)
+		}
+	}
+	current, err := cli.listTargets(ctx, tg)
+	if err != nil {
+		return err
+	}
+	have := sets.NewString()
+	var stale []target
+	for _, t := range current {
+		have.Insert(t.ID)
+		if !want.Has(t.ID) {
+			stale = append(stale, t)
+		}
+	}
+
+	var missing []target
+	for _, ip := range want.Difference(have).List() {
+		missing = append(missing, target{ID: ip, Port: apiserverPort})
+	}
+	if len(missing) > 0 {
+		if err := cli.changeTargets(ctx, "RegisterTargets", tg, missing); err != nil {
+			return err
+		}
+	}
+	if len(stale) > 0 {
+		return cli.changeTargets(ctx, "DeregisterTargets", tg, stale)
+	}
+	return nil
+}
+
+// DeleteEndpoint deletes the load balancer, then the target group once the listener is gone with it.
+func (d *Driver) DeleteEndpoint(ctx context.Context, c *common.Cluster) error {
+	if c.Spec.Infrastructure.AWS.LoadBalancer == nil {
+		return nil
+	}
+	cli, err := d.client(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	name := elbName(c.Name)
+	lb, err := cli.findLoadBalancer(ctx, name)
+	if err != nil {
+		return err
+	}
+	if lb != nil {
+		if err := cli.deleteLoadBalancer(ctx, lb.LoadBalancerArn); err != nil {
+			return err
+		}
+	}
+	tg, err := cli.findTargetGroup(ctx, name)
+	if err != nil || tg == "" {
+		return err
+	}
+	return cli.deleteTargetGroup(ctx, tg)
+}
+
+// client returns the client of the region of the cluster with the credential of the infrastructure secret.
+func (d *Driver) client(ctx context.Context, c *common.Cluster) (*client, error) {
+	as := c.Spec.Infrastructure.AWS
+	s, err := infra.Secret(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	cred := sigv4.Credentials{SessionToken: string(s.Data[sessionTokenKey])}
+	cred.AccessKeyID, err = infra.SecretValue(s, accessKeyIDKey)
+	if err != nil {
+		return nil, err
+	}
+	cred.SecretAccessKey, err = infra.SecretValue(s, secretAccessKeyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// the updated credential gets a new client
+	key := fmt.Sprintf("%s\x00%+v", as.Region, cred)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cli, ok := d.clients[key]
+	if !ok {
+		cli = newClient(as.Region, cred)
+		d.clients[key] = cli
+	}
+	return cli, nil
+}
+
+// subnetOf returns the subnet of the rack of the machine, or else the first subnet.
+func subnetOf(as *devopsv1.AWSInfrastructure, m *devopsv1.ClusterMachine) string {
+	if m != nil && m.HostCni != nil && m.HostCni.RackTag != "" {
+		for _, s := range as.Subnets {
+			if s.RackTag == m.HostCni.RackTag {
+				return s.SubnetID
+			}
+		}
+	}
+	return as.Subnets[0].SubnetID
+}
+
+// clientToken is the idempotency token of the instance, it's of the uid of the cluster so the instance of
+// the cluster created again with the same name is launched again.
+func clientToken(c *common.Cluster, name string) string {
+	sum := sha256.Sum256([]byte(string(c.UID) + "/" + name))
+	return hex.EncodeToString(sum[:])
+}
+
+// elbName returns the name of the load balancer and the target group of the cluster, the long names are
+// truncated with a hash suffix.
+func elbName(cluster string) string {
+	name := cluster + "-apiserver"
+	if len(name) <= maxELBName {
+		return name
+	}
+	sum := sha256.Sum256([]byte(cluster))
+	suffix := hex.EncodeToString(sum[:])[:8]
+	return name[:maxELBName-len(suffix)-1] + "-" + suffix
+}
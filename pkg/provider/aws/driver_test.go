@@ -0,0 +1,293 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/infra"
+	"github.com/gostship/kunkka/pkg/provider/providertest"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+type fakeInstance struct {
+	id     string
+	ip     string
+	subnet string
+	state  string
+}
+
+// fakeAWS serves the subset of the EC2 and ELB query APIs used by the driver, the instances are running on
+// their first read and the load balancer is active on its second one.
+type fakeAWS struct {
+	mu         sync.Mutex
+	tokens     map[string]*fakeInstance
+	instances  map[string]*fakeInstance
+	lb         *loadBalancer
+	reads      int
+	scheme     string
+	tg         string
+	listeners  int
+	targets    map[string]bool
+	unsigned   int
+	terminated int
+}
+
+func (f *fakeAWS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+		f.unsigned++
+	}
+	r.ParseForm()
+	form := r.Form
+	switch form.Get("Action") {
+	case "DescribeInstances":
+		fmt.Fprint(w, "<DescribeInstancesResponse><reservationSet>")
+		if i, ok := f.instances[form.Get("Filter.1.Value.1")]; ok && form.Get("Filter.2.Value.1") == "kubernetes.io/cluster/dke" {
+			i.state = instanceRunning
+			fmt.Fprintf(w, "<item><instancesSet><item><instanceId>%s</instanceId><privateIpAddress>%s</privateIpAddress>"+
+				"<instanceState><name>%s</name></instanceState></item></instancesSet></item>", i.id, i.ip, i.state)
+		}
+		fmt.Fprint(w, "</reservationSet></DescribeInstancesResponse>")
+	case "RunInstances":
+		name := form.Get("TagSpecification.1.Tag.1.Value")
+		i, ok := f.tokens[form.Get("ClientToken")]
+		if !ok {
+			subnet := form.Get("SubnetId")
+			i = &fakeInstance{id: "i-" + name, subnet: subnet, state: "pending",
+				ip: fmt.Sprintf("10.0.%s.%d", strings.TrimPrefix(subnet, "subnet-"), len(f.tokens)+1)}
+			f.tokens[form.Get("ClientToken")] = i
+			f.instances[name] = i
+		}
+		fmt.Fprintf(w, "<RunInstancesResponse><instancesSet><item><instanceId>%s</instanceId>"+
+			"<instanceState><name>%s</name></instanceState></item></instancesSet></RunInstancesResponse>", i.id, i.state)
+	case "TerminateInstances":
+		for name, i := range f.instances {
+			if i.id == form.Get("InstanceId.1") {
+				delete(f.instances, name)
+				f.terminated++
+			}
+		}
+	case "DescribeSubnets":
+		fmt.Fprint(w, "<DescribeSubnetsResponse><subnetSet><item><vpcId>vpc-1</vpcId></item></subnetSet></DescribeSubnetsResponse>")
+	case "DescribeLoadBalancers":
+		if f.lb == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "<ErrorResponse><Error><Code>LoadBalancerNotFound</Code></Error></ErrorResponse>")
+			return
+		}
+		f.reads++
+		if f.reads > 1 {
+			f.lb.State = lbActive
+		}
+		fmt.Fprintf(w, "<DescribeLoadBalancersResponse><DescribeLoadBalancersResult><LoadBalancers><member>"+
+			"<LoadBalancerArn>%s</LoadBalancerArn><DNSName>%s</DNSName><State><Code>%s</Code></State>"+
+			"</member></LoadBalancers></DescribeLoadBalancersResult></DescribeLoadBalancersResponse>", f.lb.LoadBalancerArn, f.lb.DNSName, f.lb.State)
+	case "CreateLoadBalancer":
+		f.scheme = form.Get("Scheme")
+		f.lb = &loadBalancer{LoadBalancerArn: "arn:lb", DNSName: form.Get("Name") + ".elb.amazonaws.com", State: "provisioning"}
+	case "DeleteLoadBalancer":
+		f.lb, f.listeners = nil, 0
+	case "DescribeTargetGroups":
+		if f.tg == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "<ErrorResponse><Error><Code>TargetGroupNotFound</Code></Error></ErrorResponse>")
+			return
+		}
+		fmt.Fprintf(w, "<DescribeTargetGroupsResponse><DescribeTargetGroupsResult><TargetGroups><member><TargetGroupArn>%s</TargetGroupArn>"+
+			"</member></TargetGroups></DescribeTargetGroupsResult></DescribeTargetGroupsResponse>", f.tg)
+	case "CreateTargetGroup":
+		if form.Get("VpcId") != "vpc-1" || form.Get("TargetType") != "ip" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.tg = "arn:tg"
+		fmt.Fprintf(w, "<CreateTargetGroupResponse><CreateTargetGroupResult><TargetGroups><member><TargetGroupArn>%s</TargetGroupArn>"+
+			"</member></TargetGroups></CreateTargetGroupResult></CreateTargetGroupResponse>", f.tg)
+	case "DeleteTargetGroup":
+		if f.listeners > 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "<ErrorResponse><Error><Code>ResourceInUse</Code></Error></ErrorResponse>")
+			return
+		}
+		f.tg = ""
+	case "DescribeListeners":
+		fmt.Fprint(w, "<DescribeListenersResponse><DescribeListenersResult><Listeners>")
+		for i := 0; i < f.listeners; i++ {
+			fmt.Fprintf(w, "<member><ListenerArn>arn:listener-%d</ListenerArn></member>", i)
+		}
+		fmt.Fprint(w, "</Listeners></DescribeListenersResult></DescribeListenersResponse>")
+	case "CreateListener":
+		f.listeners++
+	case "DescribeTargetHealth":
+		fmt.Fprint(w, "<DescribeTargetHealthResponse><DescribeTargetHealthResult><TargetHealthDescriptions>")
+		for ip := range f.targets {
+			fmt.Fprintf(w, "<member><Target><Id>%s</Id><Port>6443</Port></Target></member>", ip)
+		}
+		fmt.Fprint(w, "</TargetHealthDescriptions></DescribeTargetHealthResult></DescribeTargetHealthResponse>")
+	case "RegisterTargets", "DeregisterTargets":
+		for i := 1; form.Get(fmt.Sprintf("Targets.member.%d.Id", i)) != ""; i++ {
+			ip := form.Get(fmt.Sprintf("Targets.member.%d.Id", i))
+			if form.Get("Action") == "RegisterTargets" {
+				f.targets[ip] = true
+			} else {
+				delete(f.targets, ip)
+			}
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "<Response><Errors><Error><Code>InvalidAction</Code></Error></Errors></Response>")
+	}
+}
+
+func (f *fakeAWS) targetIPs() []string {
+	ips := []string{}
+	for ip := range f.targets {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+func newCluster() *common.Cluster {
+	return providertest.NewInfraCluster(ProviderName, &devopsv1.Infrastructure{
+		SecretName: "aws",
+		AWS: &devopsv1.AWSInfrastructure{
+			Region:       "us-east-1",
+			AMI:          "ami-1",
+			InstanceType: "m5.large",
+			Subnets: []devopsv1.AWSSubnet{
+				{SubnetID: "subnet-1"},
+				{RackTag: "rack-b", SubnetID: "subnet-2"},
+			},
+			LoadBalancer: &devopsv1.AWSLoadBalancer{},
+		},
+	}, map[string][]byte{
+		accessKeyIDKey:     []byte("AKID"),
+		secretAccessKeyKey: []byte("secret"),
+	})
+}
+
+func TestDriver(t *testing.T) {
+	faws := &fakeAWS{
+		tokens:    make(map[string]*fakeInstance),
+		instances: make(map[string]*fakeInstance),
+		targets:   make(map[string]bool),
+	}
+	server := httptest.NewServer(faws)
+	defer server.Close()
+	defer func(f func(service, region string) string) { endpointOf = f }(endpointOf)
+	endpointOf = func(service, region string) string {
+		return server.URL + "/" + service + "/"
+	}
+
+	ctx := context.Background()
+	c := newCluster()
+	d := NewDriver()
+	master := &devopsv1.ClusterMachine{HostCni: &devopsv1.ClusterCni{RackTag: "rack-b"}}
+	spec := &infra.InstanceSpec{Name: infra.MasterName(c.Name, 0), Role: infra.RoleMaster, Machine: master}
+
+	instance, err := d.EnsureInstance(ctx, c, spec)
+	if err != nil {
+		t.Fatalf("EnsureInstance() error = %v", err)
+	}
+	if instance.ID != "i-dke-master-0" || instance.IP != "" {
+		t.Errorf("EnsureInstance() = %+v, want i-dke-master-0 pending", instance)
+	}
+	instance, err = d.EnsureInstance(ctx, c, spec)
+	if err != nil {
+		t.Fatalf("EnsureInstance() error = %v", err)
+	}
+	if instance.IP != "10.0.2.1" {
+		t.Errorf("EnsureInstance() ip = %s, want 10.0.2.1 of the subnet of the rack", instance.IP)
+	}
+	if len(faws.instances) != 1 || faws.unsigned != 0 {
+		t.Errorf("instances = %v, unsigned = %d, want the signed ones found by name", faws.instances, faws.unsigned)
+	}
+
+	master.IP = instance.IP
+	c.Spec.Machines = []*devopsv1.ClusterMachine{master}
+	ep, err := d.EnsureEndpoint(ctx, c)
+	if err != nil || ep == nil || ep.Host != "" {
+		t.Fatalf("EnsureEndpoint() = %+v, %v, want pending", ep, err)
+	}
+	if faws.scheme != "internal" {
+		t.Errorf("scheme = %s, want internal", faws.scheme)
+	}
+	ep, err = d.EnsureEndpoint(ctx, c)
+	if err != nil || ep == nil || ep.Host != "" {
+		t.Fatalf("EnsureEndpoint() = %+v, %v, want pending", ep, err)
+	}
+	if faws.listeners != 1 || strings.Join(faws.targetIPs(), ",") != "10.0.2.1" {
+		t.Errorf("listeners = %d, targets = %v", faws.listeners, faws.targetIPs())
+	}
+	ep, err = d.EnsureEndpoint(ctx, c)
+	if err != nil || ep == nil || ep.Host != "dke-apiserver.elb.amazonaws.com" || ep.Port != apiserverPort {
+		t.Fatalf("EnsureEndpoint() = %+v, %v, want the dns name", ep, err)
+	}
+
+	// the targets are synced once the masters change
+	c.Spec.Machines = []*devopsv1.ClusterMachine{{IP: "10.0.1.9"}}
+	if _, err := d.EnsureEndpoint(ctx, c); err != nil {
+		t.Fatalf("EnsureEndpoint() error = %v", err)
+	}
+	if faws.listeners != 1 || strings.Join(faws.targetIPs(), ",") != "10.0.1.9" {
+		t.Errorf("listeners = %d, targets = %v, want the new master only", faws.listeners, faws.targetIPs())
+	}
+
+	if err := d.DeleteInstance(ctx, c, spec.Name); err != nil {
+		t.Fatalf("DeleteInstance() error = %v", err)
+	}
+	if err := d.DeleteEndpoint(ctx, c); err != nil {
+		t.Fatalf("DeleteEndpoint() error = %v", err)
+	}
+	if len(faws.instances) != 0 || faws.lb != nil || faws.tg != "" {
+		t.Errorf("instances = %v, lb = %v, tg = %s, want deleted", faws.instances, faws.lb, faws.tg)
+	}
+	if err := d.DeleteInstance(ctx, c, spec.Name); err != nil || faws.terminated != 1 {
+		t.Errorf("DeleteInstance() of the terminated instance error = %v, terminated = %d", err, faws.terminated)
+	}
+}
+
+func TestElbName(t *testing.T) {
+	if got := elbName("dke"); got != "dke-apiserver" {
+		t.Errorf("elbName() = %s, want dke-apiserver", got)
+	}
+	long := elbName("a-very-long-cluster-name-of-the-team")
+	if len(long) != maxELBName || long == elbName("a-very-long-cluster-name-of-the-team2") {
+		t.Errorf("elbName() = %s, want the unique names of %d chars", long, maxELBName)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	d := NewDriver()
+	subnets := []devopsv1.AWSSubnet{{SubnetID: "subnet"}}
+	tests := []struct {
+		name string
+		as   *devopsv1.AWSInfrastructure
+		errs int
+	}{
+		{name: "valid", as: &devopsv1.AWSInfrastructure{Region: "us-east-1", AMI: "ami", InstanceType: "t3", Subnets: subnets}},
+		{name: "no aws", errs: 1},
+		{name: "missing", as: &devopsv1.AWSInfrastructure{Region: "us-east-1"}, errs: 3},
+		{name: "duplicate rack", as: &devopsv1.AWSInfrastructure{Region: "us-east-1", AMI: "ami", InstanceType: "t3",
+			Subnets: append(subnets, devopsv1.AWSSubnet{})}, errs: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := d.Validate(&devopsv1.Infrastructure{SecretName: "aws", AWS: tt.as}, field.NewPath("spec", "infrastructure"))
+			if len(errs) != tt.errs {
+				t.Errorf("Validate() = %v, want %d errors", errs, tt.errs)
+			}
+		})
+	}
+}
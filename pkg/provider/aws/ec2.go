@@ -0,0 +1,126 @@
+package aws
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	instanceRunning = "running"
+	// nameTag is the tag of the name of the instances
+	nameTag = "Name"
+)
+
+type instance struct {
+	InstanceID       string `xml:"instanceId"`
+	PrivateIPAddress string `xml:"privateIpAddress"`
+	State            string `xml:"instanceState>name"`
+}
+
+type tag struct {
+	Key   string
+	Value string
+}
+
+// ownedTag returns the tag of the ownership of the resources of the cluster.
+func ownedTag(cluster string) tag {
+	return tag{Key: "kubernetes.io/cluster/" + cluster, Value: "owned"}
+}
+
+// findInstance returns the instance of the name owned by the cluster which is not terminated, it's nil if not
+// found.
+func (c *client) findInstance(ctx context.Context, cluster, name string) (*instance, error) {
+	params := url.Values{}
+	params.Set("Filter.1.Name", "tag:"+nameTag)
+	params.Set("Filter.1.Value.1", name)
+	params.Set("Filter.2.Name", "tag-key")
+	params.Set("Filter.2.Value.1", ownedTag(cluster).Key)
+	params.Set("Filter.3.Name", "instance-state-name")
+	setList(params, "Filter.3.Value", []string{"pending", "running", "stopping", "stopped"})
+
+	out := struct {
+		Instances []instance `xml:"reservationSet>item>instancesSet>item"`
+	}{}
+	err := c.do(ctx, serviceEC2, "DescribeInstances", params, &out)
+	if err != nil {
+		return nil, errors.Wrapf(err, "describe instance: %s", name)
+	}
+	if len(out.Instances) == 0 {
+		return nil, nil
+	}
+	return &out.Instances[0], nil
+}
+
+type runSpec struct {
+	Name           string
+	ClientToken    string
+	AMI            string
+	InstanceType   string
+	KeyName        string
+	SubnetID       string
+	SecurityGroups []string
+	Tags           []tag
+}
+
+func (c *client) runInstance(ctx context.Context, spec *runSpec) (*instance, error) {
+	params := url.Values{}
+	params.Set("ImageId", spec.AMI)
+	params.Set("InstanceType", spec.InstanceType)
+	params.Set("MinCount", "1")
+	params.Set("MaxCount", "1")
+	// the retried request of the same token doesn't launch another instance
+	params.Set("ClientToken", spec.ClientToken)
+	params.Set("SubnetId", spec.SubnetID)
+	if spec.KeyName != "" {
+		params.Set("KeyName", spec.KeyName)
+	}
+	setList(params, "SecurityGroupId", spec.SecurityGroups)
+	params.Set("TagSpecification.1.ResourceType", "instance")
+	for i, t := range spec.Tags {
+		prefix := "TagSpecification.1.Tag." + strconv.Itoa(i+1)
+		params.Set(prefix+".Key", t.Key)
+		params.Set(prefix+".Value", t.Value)
+	}
+
+	out := struct {
+		Instances []instance `xml:"instancesSet>item"`
+	}{}
+	err := c.do(ctx, serviceEC2, "RunInstances", params, &out)
+	if err != nil {
+		return nil, errors.Wrapf(err, "run instance: %s", spec.Name)
+	}
+	if len(out.Instances) == 0 {
+		return nil, errors.Errorf("run instance: %s returns no instance", spec.Name)
+	}
+	return &out.Instances[0], nil
+}
+
+func (c *client) terminateInstance(ctx context.Context, id string) error {
+	params := url.Values{}
+	params.Set("InstanceId.1", id)
+	err := c.do(ctx, serviceEC2, "TerminateInstances", params, nil)
+	if err != nil && !isCode(err, "InvalidInstanceID.NotFound") {
+		return errors.Wrapf(err, "terminate instance: %s", id)
+	}
+	return nil
+}
+
+// vpcOf returns the id of the VPC of the subnet.
+func (c *client) vpcOf(ctx context.Context, subnetID string) (string, error) {
+	params := url.Values{}
+	params.Set("SubnetId.1", subnetID)
+	out := struct {
+		VPCs []string `xml:"subnetSet>item>vpcId"`
+	}{}
+	err := c.do(ctx, serviceEC2, "DescribeSubnets", params, &out)
+	if err != nil {
+		return "", errors.Wrapf(err, "describe subnet: %s", subnetID)
+	}
+	if len(out.VPCs) == 0 {
+		return "", errors.Errorf("subnet: %s not found", subnetID)
+	}
+	return out.VPCs[0], nil
+}
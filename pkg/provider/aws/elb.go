@@ -0,0 +1,184 @@
+package aws
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	lbActive = "active"
+	lbFailed = "failed"
+)
+
+type loadBalancer struct {
+	LoadBalancerArn string `xml:"LoadBalancerArn"`
+	DNSName         string `xml:"DNSName"`
+	State           string `xml:"State>Code"`
+}
+
+type target struct {
+	ID   string `xml:"Target>Id"`
+	Port int32  `xml:"Target>Port"`
+}
+
+// findLoadBalancer returns the load balancer of the name, it's nil if not found.
+func (c *client) findLoadBalancer(ctx context.Context, name string) (*loadBalancer, error) {
+	params := url.Values{}
+	params.Set("Names.member.1", name)
+	out := struct {
+		LoadBalancers []loadBalancer `xml:"DescribeLoadBalancersResult>LoadBalancers>member"`
+	}{}
+	err := c.do(ctx, serviceELB, "DescribeLoadBalancers", params, &out)
+	if isCode(err, "LoadBalancerNotFound") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "describe load balancer: %s", name)
+	}
+	if len(out.LoadBalancers) == 0 {
+		return nil, nil
+	}
+	return &out.LoadBalancers[0], nil
+}
+
+func (c *client) createLoadBalancer(ctx context.Context, name string, subnets []string, internetFacing bool, owned tag) error {
+	params := url.Values{}
+	params.Set("Name", name)
+	params.Set("Type", "network")
+	params.Set("Scheme", "internal")
+	if internetFacing {
+		params.Set("Scheme", "internet-facing")
+	}
+	setList(params, "Subnets.member", subnets)
+	params.Set("Tags.member.1.Key", owned.Key)
+	params.Set("Tags.member.1.Value", owned.Value)
+	err := c.do(ctx, serviceELB, "CreateLoadBalancer", params, nil)
+	if err != nil {
+		return errors.Wrapf(err, "create load balancer: %s", name)
+	}
+	return nil
+}
+
+func (c *client) deleteLoadBalancer(ctx context.Context, arn string) error {
+	params := url.Values{}
+	params.Set("LoadBalancerArn", arn)
+	err := c.do(ctx, serviceELB, "DeleteLoadBalancer", params, nil)
+	if err != nil && !isCode(err, "LoadBalancerNotFound") {
+		return errors.Wrapf(err, "delete load balancer: %s", arn)
+	}
+	return nil
+}
+
+// findTargetGroup returns the arn of the target group of the name, it's empty if not found.
+func (c *client) findTargetGroup(ctx context.Context, name string) (string, error) {
+	params := url.Values{}
+	params.Set("Names.member.1", name)
+	out := struct {
+		Arns []string `xml:"DescribeTargetGroupsResult>TargetGroups>member>TargetGroupArn"`
+	}{}
+	err := c.do(ctx, serviceELB, "DescribeTargetGroups", params, &out)
+	if isCode(err, "TargetGroupNotFound") {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "describe target group: %s", name)
+	}
+	if len(out.Arns) == 0 {
+		return "", nil
+	}
+	return out.Arns[0], nil
+}
+
+// createTargetGroup creates the tcp target group of the ips and returns its arn.
+func (c *client) createTargetGroup(ctx context.Context, name, vpc string, port int32, owned tag) (string, error) {
+	params := url.Values{}
+	params.Set("Name", name)
+	params.Set("Protocol", "TCP")
+	params.Set("Port", strconv.Itoa(int(port)))
+	params.Set("VpcId", vpc)
+	params.Set("TargetType", "ip")
+	params.Set("HealthCheckProtocol", "TCP")
+	params.Set("Tags.member.1.Key", owned.Key)
+	params.Set("Tags.member.1.Value", owned.Value)
+	out := struct {
+		Arns []string `xml:"CreateTargetGroupResult>TargetGroups>member>TargetGroupArn"`
+	}{}
+	err := c.do(ctx, serviceELB, "CreateTargetGroup", params, &out)
+	if err != nil {
+		return "", errors.Wrapf(err, "create target group: %s", name)
+	}
+	if len(out.Arns) == 0 {
+		return "", errors.Errorf("create target group: %s returns no target group", name)
+	}
+	return out.Arns[0], nil
+}
+
+// deleteTargetGroup deletes the target group, it fails while the listener of the load balancer being deleted
+// still forwards to it.
+func (c *client) deleteTargetGroup(ctx context.Context, arn string) error {
+	params := url.Values{}
+	params.Set("TargetGroupArn", arn)
+	err := c.do(ctx, serviceELB, "DeleteTargetGroup", params, nil)
+	if err != nil && !isCode(err, "TargetGroupNotFound") {
+		return errors.Wrapf(err, "delete target group: %s", arn)
+	}
+	return nil
+}
+
+// ensureListener creates the tcp listener of the load balancer forwarding to the target group if it has none.
+func (c *client) ensureListener(ctx context.Context, lbArn, tgArn string, port int32) error {
+	params := url.Values{}
+	params.Set("LoadBalancerArn", lbArn)
+	out := struct {
+		Arns []string `xml:"DescribeListenersResult>Listeners>member>ListenerArn"`
+	}{}
+	err := c.do(ctx, serviceELB, "DescribeListeners", params, &out)
+	if err != nil {
+		return errors.Wrapf(err, "describe listeners of load balancer: %s", lbArn)
+	}
+	if len(out.Arns) > 0 {
+		return nil
+	}
+
+	params.Set("Protocol", "TCP")
+	params.Set("Port", strconv.Itoa(int(port)))
+	params.Set("DefaultActions.member.1.Type", "forward")
+	params.Set("DefaultActions.member.1.TargetGroupArn", tgArn)
+	err = c.do(ctx, serviceELB, "CreateListener", params, nil)
+	if err != nil {
+		return errors.Wrapf(err, "create listener of load balancer: %s", lbArn)
+	}
+	return nil
+}
+
+func (c *client) listTargets(ctx context.Context, tgArn string) ([]target, error) {
+	params := url.Values{}
+	params.Set("TargetGroupArn", tgArn)
+	out := struct {
+		Targets []target `xml:"DescribeTargetHealthResult>TargetHealthDescriptions>member"`
+	}{}
+	err := c.do(ctx, serviceELB, "DescribeTargetHealth", params, &out)
+	if err != nil {
+		return nil, errors.Wrapf(err, "describe targets of target group: %s", tgArn)
+	}
+	return out.Targets, nil
+}
+
+// changeTargets registers or deregisters the targets by the action.
+func (c *client) changeTargets(ctx context.Context, action, tgArn string, targets []target) error {
+	params := url.Values{}
+	params.Set("TargetGroupArn", tgArn)
+	for i, t := range targets {
+		prefix := "Targets.member." + strconv.Itoa(i+1)
+		params.Set(prefix+".Id", t.ID)
+		params.Set(prefix+".Port", strconv.Itoa(int(t.Port)))
+	}
+	err := c.do(ctx, serviceELB, action, params, nil)
+	if err != nil {
+		return errors.Wrapf(err, "%s of target group: %s", action, tgArn)
+	}
+	return nil
+}
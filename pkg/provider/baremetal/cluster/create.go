@@ -17,71 +17,61 @@ import (
 	bootstraputil "k8s.io/cluster-bootstrap/token/util"
 
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/util/wait"
 
 	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
 	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/provider/phases/encryption"
+	"github.com/gostship/kunkka/pkg/provider/phases/infrahook"
 	"github.com/gostship/kunkka/pkg/provider/phases/kubeadm"
 	"github.com/gostship/kunkka/pkg/provider/phases/kubemisc"
 	"github.com/gostship/kunkka/pkg/provider/phases/system"
 	"github.com/gostship/kunkka/pkg/provider/preflight"
+	"github.com/gostship/kunkka/pkg/timeouts"
 	"github.com/gostship/kunkka/pkg/util/apiclient"
 	"github.com/gostship/kunkka/pkg/util/hosts"
+	"github.com/gostship/kunkka/pkg/util/parallel"
 
 	"bytes"
 
 	"github.com/gostship/kunkka/pkg/controllers/common"
 	"github.com/gostship/kunkka/pkg/provider/addons/cni"
 	"github.com/gostship/kunkka/pkg/provider/addons/flannel"
+	"github.com/gostship/kunkka/pkg/provider/addons/gatekeeper"
+	"github.com/gostship/kunkka/pkg/provider/addons/inventory"
 	"github.com/gostship/kunkka/pkg/provider/addons/metricsserver"
+	"github.com/gostship/kunkka/pkg/provider/addons/multus"
+	"github.com/gostship/kunkka/pkg/provider/addons/podsecurity"
 	"github.com/gostship/kunkka/pkg/provider/phases/certs"
 
-	"sync"
-
 	"github.com/gostship/kunkka/pkg/provider/phases/component"
 	"github.com/gostship/kunkka/pkg/util/k8sutil"
-	"github.com/gostship/kunkka/pkg/util/pkiutil"
 	"github.com/gostship/kunkka/pkg/util/ssh"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog"
-	ctrl "sigs.k8s.io/controller-runtime"
 )
 
 func (p *Provider) EnsureCopyFiles(ctx context.Context, c *common.Cluster) error {
-	for _, file := range c.Spec.Features.Files {
-		for _, machine := range c.Spec.Machines {
-			machineSSH, err := machine.SSH()
-			if err != nil {
-				return err
-			}
-
-			err = system.CopyFile(machineSSH, &file)
+	return parallel.Machines(c.Spec.Machines, func(machine *devopsv1.ClusterMachine, s ssh.Interface) error {
+		for i := range c.Spec.Features.Files {
+			err := system.CopyFile(s, &c.Spec.Features.Files[i])
 			if err != nil {
 				return err
 			}
 		}
-	}
-
-	return nil
+		return nil
+	})
 }
 
 func (p *Provider) EnsurePreflight(ctx context.Context, c *common.Cluster) error {
-	for _, machine := range c.Spec.Machines {
-		machineSSH, err := machine.SSH()
-		if err != nil {
-			return err
-		}
-
+	return parallel.Machines(c.Spec.Machines, func(machine *devopsv1.ClusterMachine, s ssh.Interface) error {
 		klog.Infof("start check node: %s ... ", machine.IP)
-		err = preflight.RunMasterChecks(machineSSH, c)
+		err := preflight.RunMasterChecks(s, c)
 		if err != nil {
 			klog.Errorf("node:%s check err: %+v", machine.IP, err)
-			return errors.Wrap(err, machine.IP)
 		}
-	}
-
-	return nil
+		return err
+	})
 }
 
 func (p *Provider) EnsureClusterComplete(ctx context.Context, c *common.Cluster) error {
@@ -247,6 +237,14 @@ func (p *Provider) EnsureKubeMiscPhase(ctx context.Context, c *common.Cluster) e
 	if err != nil {
 		return err
 	}
+	_, err = kubemisc.ApplyAuditMisc(ctx, c.Client, c)
+	if err != nil {
+		return err
+	}
+	_, err = encryption.InitConfig(c)
+	if err != nil {
+		return err
+	}
 
 	for k, v := range c.ClusterCredential.KubeData {
 		kubeMaps[k] = v
@@ -313,6 +311,8 @@ func (p *Provider) EnsureKubeadmInitAddonPhase(ctx context.Context, c *common.Cl
 	return kubeadm.Init(machineSSH, kubeadm.GetKubeadmConfigByMaster0(c, p.Cfg), "addon all")
 }
 
+// EnsureJoinControlePlane joins the other masters one by one, each of them adds an etcd member which must be
+// healthy before the next one joins.
 func (p *Provider) EnsureJoinControlePlane(ctx context.Context, c *common.Cluster) error {
 	for _, machine := range c.Spec.Machines[1:] {
 		sh, err := machine.SSH()
@@ -328,7 +328,7 @@ func (p *Provider) EnsureJoinControlePlane(ctx context.Context, c *common.Cluste
 
 		_, err = clientset.CoreV1().Nodes().Get(context.TODO(), sh.HostIP(), metav1.GetOptions{})
 		if err == nil {
-			return nil
+			continue
 		}
 
 		// apiserver := certs.BuildApiserverEndpoint(c.Spec.Machines[0].IP, 6443)
@@ -337,7 +337,9 @@ func (p *Provider) EnsureJoinControlePlane(ctx context.Context, c *common.Cluste
 		// 	return errors.Wrapf(err, "node: %s JoinNodePhase", sh.HostIP())
 		// }
 
-		err = kubeadm.JoinControlPlane(sh, c)
+		err = timeouts.Deadline(ctx, c.Cluster, timeouts.JoinNode, func(ctx context.Context) error {
+			return kubeadm.JoinControlPlane(sh, c)
+		})
 		if err != nil {
 			return errors.Wrap(err, machine.IP)
 		}
@@ -354,54 +356,18 @@ func (p *Provider) EnsureJoinControlePlane(ctx context.Context, c *common.Cluste
 }
 
 func (p *Provider) EnsureComponent(ctx context.Context, c *common.Cluster) error {
-	for _, machine := range c.Spec.Machines {
-		machineSSH, err := machine.SSH()
-		if err != nil {
-			return err
-		}
-
-		err = component.Install(machineSSH, c)
-		if err != nil {
-			return errors.Wrap(err, machine.IP)
-		}
-	}
-
-	return nil
+	return parallel.Machines(c.Spec.Machines, func(machine *devopsv1.ClusterMachine, s ssh.Interface) error {
+		return component.Install(s, c)
+	})
 }
 
 func (p *Provider) EnsureSystem(ctx context.Context, c *common.Cluster) error {
-	wg := sync.WaitGroup{}
-	quitErrors := make(chan error)
-	wgDone := make(chan struct{})
-	for _, mach := range c.Spec.Machines {
-		sh, err := mach.SSH()
-		if err != nil {
-			return err
-		}
-
-		wg.Add(1)
-
-		go func(s ssh.Interface) {
-			defer wg.Done()
-			err = system.Install(s, c)
-			if err != nil {
-				quitErrors <- errors.Wrap(err, mach.IP)
-			}
-		}(sh)
-	}
-
-	go func() {
-		wg.Wait()
-		close(wgDone)
-	}()
-
-	// Wait until either WaitGroup is done or an error is received through the channel
-	select {
-	case <-wgDone:
-		break
-	case err := <-quitErrors:
-		close(quitErrors)
-		klog.Errorf("err: %+v", err)
+	err := parallel.Machines(c.Spec.Machines, func(machine *devopsv1.ClusterMachine, s ssh.Interface) error {
+		return timeouts.Deadline(ctx, c.Cluster, timeouts.SystemInstall, func(ctx context.Context) error {
+			return system.Install(s, c)
+		})
+	})
+	if err != nil {
 		return err
 	}
 
@@ -422,7 +388,7 @@ func (p *Provider) EnsureKubeadmInitWaitControlPlanePhase(ctx context.Context, c
 	}
 
 	start := time.Now()
-	return wait.PollImmediate(5*time.Second, 5*time.Minute, func() (bool, error) {
+	return timeouts.Poll(c.Cluster, timeouts.ControlPlaneReady, func() (bool, error) {
 		healthStatus := 0
 		clientset, err := c.ClientsetForBootstrap()
 		if err != nil {
@@ -481,22 +447,45 @@ func (p *Provider) EnsureRegistryHosts(ctx context.Context, c *common.Cluster) e
 		p.Cfg.Registry.Domain,
 		c.Spec.TenantID + "." + p.Cfg.Registry.Domain,
 	}
-	for _, machine := range c.Spec.Machines {
-		machineSSH, err := machine.SSH()
-		if err != nil {
-			return err
-		}
-
+	return parallel.Machines(c.Spec.Machines, func(machine *devopsv1.ClusterMachine, s ssh.Interface) error {
 		for _, one := range domains {
-			remoteHosts := &hosts.RemoteHosts{Host: one, SSH: machineSSH}
+			remoteHosts := &hosts.RemoteHosts{Host: one, SSH: s}
 			err := remoteHosts.Set(p.Cfg.Registry.IP)
 			if err != nil {
-				return errors.Wrap(err, machine.IP)
+				return err
 			}
 		}
+		return nil
+	})
+}
+
+// EnsureInfraPreHook runs the pre hook of the infrastructure before the machines are provisioned, the ips of its
+// output are set to the masters without ip.
+func (p *Provider) EnsureInfraPreHook(ctx context.Context, c *common.Cluster) error {
+	if c.Spec.InfraHooks == nil || c.Spec.InfraHooks.Pre == nil {
+		return nil
 	}
 
-	return nil
+	hook := c.Spec.InfraHooks.Pre
+	outputs, err := infrahook.Run(ctx, c, infrahook.PhasePre, hook)
+	if err != nil || hook.MachineIPsOutput == "" {
+		return err
+	}
+	ips, err := outputs.IPs(hook.MachineIPsOutput)
+	if err != nil {
+		return err
+	}
+	return infrahook.SetMachineIPs(ctx, c, ips)
+}
+
+// EnsureInfraPostHook runs the post hook of the infrastructure once the cluster is created.
+func (p *Provider) EnsureInfraPostHook(ctx context.Context, c *common.Cluster) error {
+	if c.Spec.InfraHooks == nil || c.Spec.InfraHooks.Post == nil {
+		return nil
+	}
+
+	_, err := infrahook.Run(ctx, c, infrahook.PhasePost, c.Spec.InfraHooks.Post)
+	return err
 }
 
 func (p *Provider) EnsurePreInstallHook(ctx context.Context, c *common.Cluster) error {
@@ -510,19 +499,14 @@ func (p *Provider) EnsurePreInstallHook(ctx context.Context, c *common.Cluster)
 	}
 	cmd := strings.Split(hook, " ")[0]
 
-	for _, machine := range c.Spec.Machines {
-		machineSSH, err := machine.SSH()
-		if err != nil {
-			return err
-		}
-
-		machineSSH.Execf("chmod +x %s", cmd)
-		_, stderr, exit, err := machineSSH.Exec(hook)
+	return parallel.Machines(c.Spec.Machines, func(machine *devopsv1.ClusterMachine, s ssh.Interface) error {
+		s.Execf("chmod +x %s", cmd)
+		_, stderr, exit, err := s.Exec(hook)
 		if err != nil || exit != 0 {
 			return fmt.Errorf("exec %q failed:exit %d:stderr %s:error %s", hook, exit, stderr, err)
 		}
-	}
-	return nil
+		return nil
+	})
 }
 
 func (p *Provider) EnsurePostInstallHook(ctx context.Context, c *common.Cluster) error {
@@ -536,19 +520,14 @@ func (p *Provider) EnsurePostInstallHook(ctx context.Context, c *common.Cluster)
 	}
 	cmd := strings.Split(hook, " ")[0]
 
-	for _, machine := range c.Spec.Machines {
-		machineSSH, err := machine.SSH()
-		if err != nil {
-			return err
-		}
-
-		machineSSH.Execf("chmod +x %s", cmd)
-		_, stderr, exit, err := machineSSH.Exec(hook)
+	return parallel.Machines(c.Spec.Machines, func(machine *devopsv1.ClusterMachine, s ssh.Interface) error {
+		s.Execf("chmod +x %s", cmd)
+		_, stderr, exit, err := s.Exec(hook)
 		if err != nil || exit != 0 {
 			return fmt.Errorf("exec %q failed:exit %d:stderr %s:error %s", hook, exit, stderr, err)
 		}
-	}
-	return nil
+		return nil
+	})
 }
 
 func (p *Provider) EnsureApplyEtcd(ctx context.Context, c *common.Cluster) error {
@@ -677,53 +656,47 @@ func (p *Provider) EnsureApplyControlPlane(ctx context.Context, c *common.Cluste
 }
 
 func (p *Provider) EnsureExtKubeconfig(ctx context.Context, c *common.Cluster) error {
-	if c.ClusterCredential.ExtData == nil {
-		c.ClusterCredential.ExtData = make(map[string]string)
-	}
-
 	apiserver := certs.BuildExternalApiserverEndpoint(c)
 	klog.Infof("external apiserver url: %s", apiserver)
-	cfgMaps, err := certs.CreateApiserverKubeConfigFile(c.ClusterCredential.CAKey, c.ClusterCredential.CACert,
-		apiserver, c.Cluster.Name)
-	if err != nil {
-		klog.Errorf("build apiserver kubeconfg err: %+v", err)
-		return err
-	}
-	klog.Infof("[%s/%s] start convert apiserver kubeconfig ...", c.Cluster.Namespace, c.Cluster.Name)
-	for _, v := range cfgMaps {
-		by, err := certs.BuildKubeConfigByte(v)
-		if err != nil {
-			return err
-		}
-
-		externalKubeconfig := string(by)
-		klog.Infof("cluster: %s externalKubeconfig: \n%s", c.Cluster.Name, externalKubeconfig)
-		c.ClusterCredential.ExtData[pkiutil.ExternalAdminKubeConfigFileName] = externalKubeconfig
-	}
-
-	return nil
+	return certs.EnsureExternalKubeconfig(ctx, c, apiserver)
 }
 
 func (p *Provider) EnsureMetricsServer(ctx context.Context, c *common.Cluster) error {
 	clusterCtx, err := c.ClusterManager.Get(c.Name)
 	if err != nil {
-		return nil
+		return c.SkipReconcile("EnsureMetricsServer", err)
 	}
 	objs, err := metricsserver.BuildMetricsServerAddon(c)
 	if err != nil {
 		return errors.Wrapf(err, "build metrics-server err: %v", err)
 	}
 
-	logger := ctrl.Log.WithValues("cluster", c.Name, "component", "metrics-server")
-	logger.Info("start reconcile ...")
-	for _, obj := range objs {
-		err = k8sutil.Reconcile(logger, clusterCtx.Client, obj, k8sutil.DesiredStatePresent)
-		if err != nil {
-			return errors.Wrapf(err, "Reconcile  err: %v", err)
-		}
+	return inventory.Apply(ctx, clusterCtx.Client, c.Name, inventory.MetricsServer, objs, k8sutil.DesiredStatePresent)
+}
+
+func (p *Provider) EnsureGatekeeper(ctx context.Context, c *common.Cluster) error {
+	if policy, ok := c.Cluster.Spec.Features.Hooks[devopsv1.HookPolicyInstall]; !ok || policy != gatekeeper.PolicyType {
+		return nil
 	}
 
-	return nil
+	return gatekeeper.ApplyGatekeeper(ctx, p.Cfg, c)
+}
+
+func (p *Provider) EnsurePodSecurity(ctx context.Context, c *common.Cluster) error {
+	return podsecurity.ApplyPodSecurity(ctx, c)
+}
+
+// EnsureAddonsGC deletes the objects of the addons disabled in the spec of the cluster.
+func (p *Provider) EnsureAddonsGC(ctx context.Context, c *common.Cluster) error {
+	return inventory.CollectDisabled(ctx, c)
+}
+
+func (p *Provider) EnsureMultus(ctx context.Context, c *common.Cluster) error {
+	if !c.Cluster.Spec.Features.Multus {
+		return nil
+	}
+
+	return multus.ApplyMultus(ctx, p.Cfg, c)
 }
 
 func (p *Provider) EnsureEth(ctx context.Context, c *common.Cluster) error {
@@ -738,20 +711,13 @@ func (p *Provider) EnsureEth(ctx context.Context, c *common.Cluster) error {
 		return nil
 	}
 
-	for _, machine := range c.Spec.Machines {
-		sh, err := machine.SSH()
+	return parallel.Machines(c.Spec.Machines, func(machine *devopsv1.ClusterMachine, s ssh.Interface) error {
+		err := cni.ApplyEth(s, c)
 		if err != nil {
-			return err
+			klog.Errorf("node: %s apply eth err: %v", s.HostIP(), err)
 		}
-
-		err = cni.ApplyEth(sh, c)
-		if err != nil {
-			klog.Errorf("node: %s apply eth err: %v", sh.HostIP(), err)
-			return err
-		}
-	}
-
-	return nil
+		return err
+	})
 }
 
 func (p *Provider) EnsureCni(ctx context.Context, c *common.Cluster) error {
@@ -778,20 +744,16 @@ func (p *Provider) EnsureCni(ctx context.Context, c *common.Cluster) error {
 	case "flannel":
 		clusterCtx, err := c.ClusterManager.Get(c.Name)
 		if err != nil {
-			return nil
+			return c.SkipReconcile("EnsureCni", err)
 		}
 		objs, err := flannel.BuildFlannelAddon(p.Cfg, c)
 		if err != nil {
 			return errors.Wrapf(err, "build flannel err: %v", err)
 		}
 
-		logger := ctrl.Log.WithValues("cluster", c.Name, "component", "flannel")
-		logger.Info("start reconcile ...")
-		for _, obj := range objs {
-			err = k8sutil.Reconcile(logger, clusterCtx.Client, obj, k8sutil.DesiredStatePresent)
-			if err != nil {
-				return errors.Wrapf(err, "Reconcile  err: %v", err)
-			}
+		err = inventory.Apply(ctx, clusterCtx.Client, c.Name, inventory.Flannel, objs, k8sutil.DesiredStatePresent)
+		if err != nil {
+			return err
 		}
 	default:
 		return fmt.Errorf("unknown cni type: %s", cniType)
@@ -803,7 +765,7 @@ func (p *Provider) EnsureCni(ctx context.Context, c *common.Cluster) error {
 func (p *Provider) EnsureMasterNode(ctx context.Context, c *common.Cluster) error {
 	clusterCtx, err := c.ClusterManager.Get(c.Name)
 	if err != nil {
-		return nil
+		return c.SkipReconcile("EnsureMasterNode", err)
 	}
 	node := &corev1.Node{}
 	var noReadNode *devopsv1.ClusterMachine
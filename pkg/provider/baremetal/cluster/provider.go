@@ -9,12 +9,10 @@ import (
 
 	clusterprovider "github.com/gostship/kunkka/pkg/provider/cluster"
 
-	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
-	"github.com/gostship/kunkka/pkg/constants"
 	"github.com/gostship/kunkka/pkg/controllers/common"
 	"github.com/gostship/kunkka/pkg/provider/baremetal/validation"
 	"github.com/gostship/kunkka/pkg/provider/config"
-	"github.com/gostship/kunkka/pkg/util/pointer"
+	"github.com/gostship/kunkka/pkg/timeouts"
 	"k8s.io/klog"
 )
 
@@ -45,6 +43,7 @@ func NewProvider(mgr *clusterprovider.CpManager, cfg *config.Config) (*Provider,
 	p.DelegateProvider = &clusterprovider.DelegateProvider{
 		ProviderName: "Baremetal",
 		CreateHandlers: []clusterprovider.Handler{
+			p.EnsureInfraPreHook,
 			p.EnsureCopyFiles,
 			p.EnsurePreInstallHook,
 			p.EnsureEth,
@@ -71,6 +70,7 @@ func NewProvider(mgr *clusterprovider.CpManager, cfg *config.Config) (*Provider,
 			p.EnsureCni,
 			p.EnsureApplyControlPlane,
 			p.EnsureExtKubeconfig,
+			p.EnsureInfraPostHook,
 			//p.EnsurePostInstallHook,
 		},
 		UpdateHandlers: []clusterprovider.Handler{
@@ -81,8 +81,21 @@ func NewProvider(mgr *clusterprovider.CpManager, cfg *config.Config) (*Provider,
 			p.EnsureApplyControlPlane,
 			p.EnsureRenewCerts,
 			p.EnsureAPIServerCert,
+			p.EnsureEncryption,
 			p.EnsureMetricsServer,
+			p.EnsureGatekeeper,
+			p.EnsureMultus,
+			p.EnsurePodSecurity,
 		},
+		ResyncHandlers: []clusterprovider.Handler{
+			p.EnsureAddonsResync,
+			p.EnsureAddonsGC,
+		},
+	}
+
+	err := timeouts.SetProvider(p.Name(), cfg.Waits)
+	if err != nil {
+		return nil, err
 	}
 
 	return p, nil
@@ -99,33 +112,10 @@ func (p *Provider) Validate(cluster *common.Cluster) field.ErrorList {
 }
 
 func (p *Provider) PreCreate(cluster *common.Cluster) error {
-	if cluster.Spec.Version == "" {
-		cluster.Spec.Version = constants.K8sVersions[0]
-	}
-	if cluster.Spec.ClusterCIDR == "" {
-		cluster.Spec.ClusterCIDR = "10.244.0.0/16"
-	}
-	if cluster.Spec.NetworkDevice == "" {
-		cluster.Spec.NetworkDevice = "eth0"
-	}
-
-	if cluster.Spec.Features.IPVS == nil {
-		cluster.Spec.Features.IPVS = pointer.ToBool(true)
-	}
-
-	if cluster.Spec.Properties.MaxClusterServiceNum == nil && cluster.Spec.ServiceCIDR == nil {
-		cluster.Spec.Properties.MaxClusterServiceNum = pointer.ToInt32(256)
-	}
-	if cluster.Spec.Properties.MaxNodePodNum == nil {
-		cluster.Spec.Properties.MaxNodePodNum = pointer.ToInt32(256)
-	}
+	cluster.Default()
 	if cluster.Spec.Features.SkipConditions == nil {
 		cluster.Spec.Features.SkipConditions = p.Cfg.Feature.SkipConditions
 	}
 
-	if cluster.Spec.Etcd == nil {
-		cluster.Spec.Etcd = &devopsv1.Etcd{Local: &devopsv1.LocalEtcd{}}
-	}
-
 	return nil
 }
@@ -4,18 +4,27 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
 	"github.com/gostship/kunkka/pkg/constants"
 	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/addons/flannel"
 	"github.com/gostship/kunkka/pkg/provider/phases/certs"
+	"github.com/gostship/kunkka/pkg/provider/phases/encryption"
 	"github.com/gostship/kunkka/pkg/provider/phases/kubeadm"
 	"github.com/gostship/kunkka/pkg/provider/phases/kubemisc"
 	"github.com/gostship/kunkka/pkg/util/k8sutil"
 	"github.com/pkg/errors"
 	"github.com/prometheus/common/log"
 	"github.com/thoas/go-funk"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/klog"
 )
 
 func (p *Provider) EnsureRenewCerts(ctx context.Context, c *common.Cluster) error {
@@ -40,7 +49,7 @@ func (p *Provider) EnsureRenewCerts(ctx context.Context, c *common.Cluster) erro
 		}
 
 		log.Infof("EnsureRenewCerts for %s", s.Host)
-		err = kubeadm.RenewCerts(s)
+		err = kubeadm.RenewCerts(s, c)
 		if err != nil {
 			return errors.Wrap(err, machine.IP)
 		}
@@ -87,7 +96,7 @@ func (p *Provider) EnsureAPIServerCert(ctx context.Context, c *common.Cluster) e
 		if err != nil {
 			return errors.Wrap(err, machine.IP)
 		}
-		err = kubeadm.RestartContainerByFilter(s, kubeadm.DockerFilterForControlPlane("kube-apiserver"))
+		err = kubeadm.RestartControlPlaneComponent(s, c, "kube-apiserver")
 		if err != nil {
 			return err
 		}
@@ -104,3 +113,170 @@ func (p *Provider) EnsureAPIServerCert(ctx context.Context, c *common.Cluster) e
 
 	return nil
 }
+
+// EnsureEncryption keeps the encryption config of the masters in sync with the credential and advances the keys
+// by one step once they are, the apiservers are restarted one by one after the config is written.
+func (p *Provider) EnsureEncryption(ctx context.Context, c *common.Cluster) error {
+	if !encryption.Enabled(c) {
+		return nil
+	}
+
+	pending, err := p.syncEncryptionConfig(c)
+	if err != nil || pending {
+		return err
+	}
+
+	changed, err := encryption.Rotate(ctx, c, func(ctx context.Context) error {
+		clusterCtx, err := c.ClusterManager.Get(c.Name)
+		if err != nil {
+			return err
+		}
+		return encryption.RewriteSecrets(ctx, clusterCtx.Client)
+	})
+	if err != nil || !changed {
+		return err
+	}
+	_, err = p.syncEncryptionConfig(c)
+	return err
+}
+
+// syncEncryptionConfig writes the encryption config to the masters and restarts their apiservers,
+// returns whether any master is updated or can't use the config, the keys mustn't advance then.
+func (p *Provider) syncEncryptionConfig(c *common.Cluster) (bool, error) {
+	want := c.ClusterCredential.KubeData[constants.EncryptionConfigFile]
+	pending := false
+	for _, machine := range c.Spec.Machines {
+		s, err := machine.SSH()
+		if err != nil {
+			return pending, err
+		}
+
+		manifest, err := s.ReadFile(constants.KubeAPIServerPodManifestFile)
+		if err != nil {
+			return pending, errors.Wrap(err, machine.IP)
+		}
+		if !strings.Contains(string(manifest), "--encryption-provider-config=") {
+			// the apiserver of the clusters created without the encryption isn't reconfigured in place
+			klog.Warningf("cluster: %s master: %s apiserver has no --encryption-provider-config, skip the encryption", c.Name, machine.IP)
+			return true, nil
+		}
+
+		data, err := s.ReadFile(constants.EncryptionConfigFile)
+		if err == nil && string(data) == want {
+			continue
+		}
+
+		log.Infof("EnsureEncryption write the encryption config to %s", s.Host)
+		err = s.WriteFile(strings.NewReader(want), constants.EncryptionConfigFile)
+		if err != nil {
+			return pending, errors.Wrap(err, machine.IP)
+		}
+		err = kubeadm.RestartControlPlaneComponent(s, c, "kube-apiserver")
+		if err != nil {
+			return pending, err
+		}
+		pending = true
+	}
+	return pending, nil
+}
+
+// EnsureAddonsResync runs the kubeadm addon phase again which reverts coredns and kube-proxy, and applies
+// flannel again. The kubeadm addons which were missing or changed by the phase are recorded as drifted.
+func (p *Provider) EnsureAddonsResync(ctx context.Context, c *common.Cluster) error {
+	cli, err := c.Clientset()
+	if err != nil {
+		return errors.Wrapf(err, "get cluster: %s clientset", c.Name)
+	}
+	before, err := kubeadmAddonVersions(ctx, cli)
+	if err != nil {
+		return err
+	}
+
+	machineSSH, err := c.Spec.Machines[0].SSH()
+	if err != nil {
+		return err
+	}
+	err = kubeadm.Init(machineSSH, kubeadm.GetKubeadmConfigByMaster0(c, p.Cfg), "addon all")
+	if err != nil {
+		return err
+	}
+
+	after, err := kubeadmAddonVersions(ctx, cli)
+	if err != nil {
+		return err
+	}
+	for name, version := range after {
+		if before[name] != version {
+			c.RecordDrift(name)
+		}
+	}
+
+	if c.Cluster.Spec.Features.Hooks[devopsv1.HookCniInstall] != "flannel" {
+		return nil
+	}
+	clusterCtx, err := c.ClusterManager.Get(c.Name)
+	if err != nil {
+		return errors.Wrapf(err, "get cluster: %s client", c.Name)
+	}
+	objs, err := flannel.BuildFlannelAddon(p.Cfg, c)
+	if err != nil {
+		return errors.Wrapf(err, "build flannel err: %v", err)
+	}
+	return c.ResyncObjects(clusterCtx.Client, "flannel", objs)
+}
+
+// kubeadmAddonVersions returns the versions of the objects of the kubeadm addon phase by their drift names,
+// i.e. the generation of the workloads whose status changes and the resource version of the others.
+func kubeadmAddonVersions(ctx context.Context, cli kubernetes.Interface) (map[string]string, error) {
+	ns := metav1.NamespaceSystem
+	getters := map[string]func() (string, error){
+		"Deployment/kube-system/" + constants.CoreDNSDeploymentName: func() (string, error) {
+			obj, err := cli.AppsV1().Deployments(ns).Get(ctx, constants.CoreDNSDeploymentName, metav1.GetOptions{})
+			if err != nil {
+				return "", err
+			}
+			return strconv.FormatInt(obj.Generation, 10), nil
+		},
+		"ConfigMap/kube-system/" + constants.CoreDNSConfigMap: func() (string, error) {
+			obj, err := cli.CoreV1().ConfigMaps(ns).Get(ctx, constants.CoreDNSConfigMap, metav1.GetOptions{})
+			if err != nil {
+				return "", err
+			}
+			return obj.ResourceVersion, nil
+		},
+		"Service/kube-system/kube-dns": func() (string, error) {
+			obj, err := cli.CoreV1().Services(ns).Get(ctx, "kube-dns", metav1.GetOptions{})
+			if err != nil {
+				return "", err
+			}
+			return obj.ResourceVersion, nil
+		},
+		"DaemonSet/kube-system/kube-proxy": func() (string, error) {
+			obj, err := cli.AppsV1().DaemonSets(ns).Get(ctx, "kube-proxy", metav1.GetOptions{})
+			if err != nil {
+				return "", err
+			}
+			return strconv.FormatInt(obj.Generation, 10), nil
+		},
+		"ConfigMap/kube-system/" + constants.KubeProxyConfigMap: func() (string, error) {
+			obj, err := cli.CoreV1().ConfigMaps(ns).Get(ctx, constants.KubeProxyConfigMap, metav1.GetOptions{})
+			if err != nil {
+				return "", err
+			}
+			return obj.ResourceVersion, nil
+		},
+	}
+
+	versions := make(map[string]string, len(getters))
+	for name, get := range getters {
+		version, err := get()
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "get %s", name)
+		}
+		versions[name] = version
+	}
+	return versions, nil
+}
@@ -5,11 +5,9 @@ import (
 	"fmt"
 	"math/rand"
 	"strings"
-	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/util/wait"
 
 	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
 	"github.com/gostship/kunkka/pkg/constants"
@@ -21,6 +19,7 @@ import (
 	"github.com/gostship/kunkka/pkg/provider/phases/kubemisc"
 	"github.com/gostship/kunkka/pkg/provider/phases/system"
 	"github.com/gostship/kunkka/pkg/provider/preflight"
+	"github.com/gostship/kunkka/pkg/timeouts"
 	"github.com/gostship/kunkka/pkg/util/apiclient"
 	"github.com/gostship/kunkka/pkg/util/hosts"
 	"github.com/pkg/errors"
@@ -173,7 +172,9 @@ func (p *Provider) EnsureSystem(ctx context.Context, machine *devopsv1.Machine,
 		return err
 	}
 
-	err = system.Install(sh, c)
+	err = timeouts.Deadline(ctx, c.Cluster, timeouts.SystemInstall, func(ctx context.Context) error {
+		return system.InstallMachine(sh, c, machine.Spec.Kubelet)
+	})
 	if err != nil {
 		return errors.Wrap(err, sh.HostIP())
 	}
@@ -227,7 +228,9 @@ func (p *Provider) EnsureJoinNode(ctx context.Context, machine *devopsv1.Machine
 	apiserver := certs.BuildApiserverEndpoint(c.Cluster.Spec.PublicAlternativeNames[0], kubemisc.GetBindPort(c.Cluster))
 	klog.Infof("join apiserver: %s", apiserver)
 
-	err = joinnode.JoinNodePhase(sh, p.Cfg, c, apiserver, false)
+	err = timeouts.Deadline(ctx, c.Cluster, timeouts.JoinNode, func(ctx context.Context) error {
+		return joinnode.JoinNodePhase(sh, p.Cfg, c, apiserver, false, machine.Spec.Kubelet)
+	})
 	if err != nil {
 		return err
 	}
@@ -237,10 +240,15 @@ func (p *Provider) EnsureJoinNode(ctx context.Context, machine *devopsv1.Machine
 func (p *Provider) EnsureMarkNode(ctx context.Context, machine *devopsv1.Machine, c *common.Cluster) error {
 	clusterCtx, err := c.ClusterManager.Get(c.Name)
 	if err != nil {
-		return nil
+		return c.SkipReconcile("EnsureMarkNode", err)
+	}
+
+	labels, taints, err := c.NodeMarks(ctx, machine)
+	if err != nil {
+		return err
 	}
 
-	err = apiclient.MarkNode(ctx, clusterCtx.KubeCli, machine.Spec.Machine.IP, machine.Spec.Machine.Labels, machine.Spec.Machine.Taints)
+	err = apiclient.MarkNode(ctx, clusterCtx.KubeCli, machine.Spec.Machine.IP, labels, taints)
 	if err != nil {
 		return err
 	}
@@ -301,10 +309,10 @@ func (p *Provider) EnsureCni(ctx context.Context, machine *devopsv1.Machine, c *
 func (p *Provider) EnsureNodeReady(ctx context.Context, machine *devopsv1.Machine, c *common.Cluster) error {
 	clusterCtx, err := c.ClusterManager.Get(c.Name)
 	if err != nil {
-		return nil
+		return c.SkipReconcile("EnsureNodeReady", err)
 	}
 
-	return wait.PollImmediate(5*time.Second, 5*time.Minute, func() (bool, error) {
+	return timeouts.Poll(c.Cluster, timeouts.NodeReady, func() (bool, error) {
 		node, err := clusterCtx.KubeCli.CoreV1().Nodes().Get(ctx, machine.Spec.Machine.IP, metav1.GetOptions{})
 		if err != nil {
 			return false, nil
@@ -320,6 +328,14 @@ func (p *Provider) EnsureNodeReady(ctx context.Context, machine *devopsv1.Machin
 	})
 }
 
+func (p *Provider) EnsureOSDrift(ctx context.Context, machine *devopsv1.Machine, c *common.Cluster) error {
+	return system.ApplyOSDrift(ctx, machine, c)
+}
+
+func (p *Provider) EnsureRegistryMirrors(ctx context.Context, machine *devopsv1.Machine, c *common.Cluster) error {
+	return system.ApplyRegistryMirrors(ctx, machine, c)
+}
+
 func GetMasterEndpoint(addresses []devopsv1.ClusterAddress) (string, error) {
 	var advertise, internal []*devopsv1.ClusterAddress
 	for _, one := range addresses {
@@ -345,3 +361,7 @@ func GetMasterEndpoint(addresses []devopsv1.ClusterAddress) (string, error) {
 
 	return fmt.Sprintf("https://%s:%d", address.Host, address.Port), nil
 }
+
+func (p *Provider) EnsureArtifactsCleanup(ctx context.Context, machine *devopsv1.Machine, c *common.Cluster) error {
+	return system.ApplyArtifactsCleanup(machine, c, system.WorkerArtifacts(false))
+}
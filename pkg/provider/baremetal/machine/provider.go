@@ -43,6 +43,7 @@ func NewProvider(mgr *machineprovider.MpManager, cfg *config.Config) (*Provider,
 
 			p.EnsureEth,
 			p.EnsureSystem,
+			p.EnsureRegistryMirrors,
 			p.EnsureK8sComponent,
 			p.EnsurePreflight, // wait basic setting done
 
@@ -51,13 +52,20 @@ func NewProvider(mgr *machineprovider.MpManager, cfg *config.Config) (*Provider,
 			p.EnsureMarkNode,
 			p.EnsureCni,
 			p.EnsureNodeReady,
+			p.EnsureArtifactsCleanup,
 
 			p.EnsurePostInstallHook,
 		},
+		DeleteHandlers: []machineprovider.Handler{
+			p.EnsureClean,
+		},
 		UpdateHandlers: []machineprovider.Handler{
 			p.EnsureCni,
 			p.EnsurePostInstallHook,
 			p.EnsureRegistryHosts,
+			p.EnsureRegistryMirrors,
+			p.EnsureOSDrift,
+			p.EnsureArtifactsCleanup,
 		},
 	}
 
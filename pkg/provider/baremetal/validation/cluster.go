@@ -1,20 +1,33 @@
 package validation
 
 import (
+	"crypto/x509"
 	"fmt"
 	"net"
+	"net/url"
+	"strings"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
 	"github.com/gostship/kunkka/pkg/constants"
 	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/addons/podsecurity"
+	"github.com/gostship/kunkka/pkg/provider/phases/kubemisc"
+	"github.com/gostship/kunkka/pkg/timeouts"
+	"github.com/gostship/kunkka/pkg/util/apiclient"
 	"github.com/gostship/kunkka/pkg/util/ipallocator"
 	"github.com/gostship/kunkka/pkg/util/validation"
 	utilvalidation "github.com/gostship/kunkka/pkg/util/validation"
 )
 
 var (
+	flannelBackendAvails    = []devopsv1.FlannelBackend{devopsv1.FlannelBackendVxlan, devopsv1.FlannelBackendHostGW, devopsv1.FlannelBackendWireguard}
+	containerRuntimeAvails  = []devopsv1.ContainerRuntimeType{devopsv1.ContainerRuntimeDocker, devopsv1.ContainerRuntimeContainerd, devopsv1.ContainerRuntimeCRIO}
+	taintEffectAvails       = []corev1.TaintEffect{corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute}
 	nodePodNumAvails        = []int32{16, 32, 64, 128, 256}
 	clusterServiceNumAvails = []int32{32, 64, 128, 256, 512, 1024, 2048, 4096, 8192, 16384, 32768}
 )
@@ -33,6 +46,32 @@ func ValidatClusterSpec(spec *devopsv1.ClusterSpec, fldPath *field.Path, phase d
 	allErrs = append(allErrs, ValidateClusterSpecVersion(spec.Version, fldPath.Child("version"), phase)...)
 	allErrs = append(allErrs, ValidateCIDRs(spec, fldPath)...)
 	allErrs = append(allErrs, ValidateClusterProperty(spec, fldPath.Child("properties"))...)
+	allErrs = append(allErrs, ValidateNetworkAttachments(spec, fldPath.Child("networkAttachments"))...)
+	allErrs = append(allErrs, ValidateFlannel(spec.Flannel, fldPath.Child("flannel"))...)
+	allErrs = append(allErrs, ValidateContainerRuntime(spec, fldPath.Child("containerRuntime"))...)
+	allErrs = append(allErrs, ValidatePlacements(spec.Placements, fldPath.Child("placements"))...)
+	allErrs = append(allErrs, ValidateWaits(spec.Waits, fldPath.Child("waits"))...)
+	allErrs = append(allErrs, ValidateRegistryMirrors(spec, fldPath.Child("registryMirrors"))...)
+	if spec.ExternalCA != nil {
+		for _, msg := range k8svalidation.IsDNS1123Subdomain(spec.ExternalCA.SecretName) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("externalCA", "secretName"), spec.ExternalCA.SecretName, msg))
+		}
+	}
+	allErrs = append(allErrs, ValidateVaultPKI(spec, fldPath.Child("vaultPKI"))...)
+	allErrs = append(allErrs, ValidateOIDC(spec.OIDC, fldPath.Child("oidc"))...)
+	allErrs = append(allErrs, ValidateAudit(spec.Audit, fldPath.Child("audit"))...)
+	allErrs = append(allErrs, ValidateEncryption(spec.Encryption, fldPath.Child("encryption"))...)
+	allErrs = append(allErrs, ValidatePodSecurity(spec.PodSecurity, fldPath.Child("podSecurity"))...)
+	allErrs = append(allErrs, ValidateInfraHooks(spec.InfraHooks, fldPath.Child("infraHooks"))...)
+	allErrs = append(allErrs, ValidateExtraArgs(spec.APIServerExtraArgs, fldPath.Child("apiServerExtraArgs"))...)
+	allErrs = append(allErrs, ValidateExtraArgs(spec.ControllerManagerExtraArgs, fldPath.Child("controllerManagerExtraArgs"))...)
+	allErrs = append(allErrs, ValidateExtraArgs(spec.SchedulerExtraArgs, fldPath.Child("schedulerExtraArgs"))...)
+	for i, m := range spec.Machines {
+		if m != nil {
+			allErrs = append(allErrs, ValidateSSHCredentialRef(m.CredentialRef, fldPath.Child("machines").Index(i).Child("credentialRef"))...)
+			allErrs = append(allErrs, ValidateHostKey(m.HostKey, fldPath.Child("machines").Index(i).Child("hostKey"))...)
+		}
+	}
 	// allErrs = append(allErrs, ValidateClusterMachines(spec.Machines, fldPath.Child("machines"))...)
 	// allErrs = append(allErrs, ValidateClusterFeature(&spec.Features, fldPath.Child("features"))...)
 
@@ -117,3 +156,371 @@ func ValidateClusterProperty(spec *devopsv1.ClusterSpec, propPath *field.Path) f
 
 	return allErrs
 }
+
+// ValidateNetworkAttachments validates the secondary networks served by multus.
+func ValidateNetworkAttachments(spec *devopsv1.ClusterSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if len(spec.NetworkAttachments) > 0 && !spec.Features.Multus {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "requires spec.features.multus"))
+	}
+
+	names := sets.NewString()
+	for i, na := range spec.NetworkAttachments {
+		idxPath := fldPath.Index(i)
+		for _, msg := range k8svalidation.IsDNS1123Subdomain(na.Name) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), na.Name, msg))
+		}
+
+		key := na.Namespace + "/" + na.Name
+		if names.Has(key) {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), na.Name))
+		}
+		names.Insert(key)
+
+		if na.Type == "" && na.Config == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("type"), "type or config is required"))
+		}
+	}
+
+	return allErrs
+}
+
+// ValidateFlannel validates the flannel backend and mtu.
+func ValidateFlannel(f *devopsv1.Flannel, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if f == nil {
+		return allErrs
+	}
+
+	if f.Backend != "" {
+		allErrs = append(allErrs, utilvalidation.ValidateEnum(f.Backend, fldPath.Child("backend"), flannelBackendAvails)...)
+	}
+	if f.MTU != 0 && (f.MTU < 576 || f.MTU > 9000) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("mtu"), f.MTU, "must be between 576 and 9000"))
+	}
+
+	return allErrs
+}
+
+// ValidateContainerRuntime validates the container runtime, dockershim is removed since 1.24.
+func ValidateContainerRuntime(spec *devopsv1.ClusterSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	rt := devopsv1.ContainerRuntimeDocker
+	if spec.ContainerRuntime != nil && spec.ContainerRuntime.Type != "" {
+		rt = spec.ContainerRuntime.Type
+		allErrs = append(allErrs, utilvalidation.ValidateEnum(rt, fldPath.Child("type"), containerRuntimeAvails)...)
+	}
+
+	if rt == devopsv1.ContainerRuntimeDocker {
+		if ok, err := apiclient.CheckVersion(spec.Version, ">= 1.24"); err == nil && ok {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("type"), fmt.Sprintf("docker is not supported by kubernetes %s, use containerd or cri-o", spec.Version)))
+		}
+	}
+
+	return allErrs
+}
+
+// ValidatePlacements validates the node placements, the name ends up in the label value.
+func ValidatePlacements(placements []devopsv1.NodePlacement, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	names := sets.NewString()
+	for i, p := range placements {
+		idxPath := fldPath.Index(i)
+		for _, msg := range k8svalidation.IsDNS1123Label(p.Name) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), p.Name, msg))
+		}
+		if names.Has(p.Name) {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), p.Name))
+		}
+		names.Insert(p.Name)
+
+		if p.Replicas < 0 {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("replicas"), p.Replicas, "must be greater than or equal to 0"))
+		}
+
+		for k, v := range p.Labels {
+			for _, msg := range k8svalidation.IsQualifiedName(k) {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("labels"), k, msg))
+			}
+			for _, msg := range k8svalidation.IsValidLabelValue(v) {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("labels").Key(k), v, msg))
+			}
+		}
+
+		for j, t := range p.Taints {
+			taintPath := idxPath.Child("taints").Index(j)
+			for _, msg := range k8svalidation.IsQualifiedName(t.Key) {
+				allErrs = append(allErrs, field.Invalid(taintPath.Child("key"), t.Key, msg))
+			}
+			allErrs = append(allErrs, utilvalidation.ValidateEnum(t.Effect, taintPath.Child("effect"), taintEffectAvails)...)
+		}
+	}
+
+	return allErrs
+}
+
+// ValidateWaits validates the wait overrides of the cluster.
+func ValidateWaits(waits map[string]devopsv1.WaitParam, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for name, p := range waits {
+		if !timeouts.IsValid(name) {
+			allErrs = append(allErrs, field.NotSupported(fldPath.Key(name), name, timeouts.Names()))
+			continue
+		}
+
+		if p.Interval.Duration < 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Key(name).Child("interval"), p.Interval.Duration.String(), "must be greater than or equal to 0"))
+		}
+		if p.Timeout.Duration < 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Key(name).Child("timeout"), p.Timeout.Duration.String(), "must be greater than or equal to 0"))
+		}
+		if p.Interval.Duration > 0 && p.Timeout.Duration > 0 && p.Interval.Duration > p.Timeout.Duration {
+			allErrs = append(allErrs, field.Invalid(fldPath.Key(name).Child("interval"), p.Interval.Duration.String(), "must be less than or equal to the timeout"))
+		}
+	}
+
+	return allErrs
+}
+
+// ValidateExtraArgs validates the extra flags of a control plane component, the keys are the flag names without "--".
+func ValidateExtraArgs(args map[string]string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for k := range args {
+		if k == "" || strings.HasPrefix(k, "-") || strings.ContainsAny(k, "= \t") {
+			allErrs = append(allErrs, field.Invalid(fldPath.Key(k), k, "must be a flag name without the leading \"--\""))
+		}
+	}
+
+	return allErrs
+}
+
+// ValidateRegistryMirrors validates the registry mirrors of the cluster, docker only supports the mirrors of docker.io.
+func ValidateRegistryMirrors(spec *devopsv1.ClusterSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	isDocker := spec.ContainerRuntime == nil || spec.ContainerRuntime.Type == "" || spec.ContainerRuntime.Type == devopsv1.ContainerRuntimeDocker
+	for host, m := range spec.RegistryMirrors {
+		hostPath := fldPath.Key(host)
+		u, err := url.Parse("https://" + host)
+		if host == "" || err != nil || u.Host != host {
+			allErrs = append(allErrs, field.Invalid(hostPath, host, "must be a registry host[:port] without scheme or path"))
+			continue
+		}
+
+		for i, endpoint := range m.Endpoints {
+			u, err := url.Parse(endpoint)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				allErrs = append(allErrs, field.Invalid(hostPath.Child("endpoints").Index(i), endpoint, "must be a http or https url"))
+			}
+		}
+		if isDocker && host != "docker.io" && len(m.Endpoints) > 0 {
+			allErrs = append(allErrs, field.Invalid(hostPath.Child("endpoints"), m.Endpoints, "docker only supports the mirrors of docker.io"))
+		}
+
+		if m.CA != "" {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM([]byte(m.CA)) {
+				allErrs = append(allErrs, field.Invalid(hostPath.Child("ca"), "<pem>", "must be PEM encoded certificates"))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+// ValidateVaultPKI validates the vault address, the Secret and the CAs mounted.
+func ValidateVaultPKI(spec *devopsv1.ClusterSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	v := spec.VaultPKI
+	if v == nil {
+		return allErrs
+	}
+
+	u, err := url.Parse(v.Address)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("address"), v.Address, "must be a http or https url"))
+	}
+	for _, msg := range k8svalidation.IsDNS1123Subdomain(v.SecretName) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("secretName"), v.SecretName, msg))
+	}
+
+	if len(v.Mounts) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("mounts"), "at least one CA must be mounted"))
+	}
+	cas := sets.NewString("ca", "etcd-ca", "front-proxy-ca")
+	for name, mount := range v.Mounts {
+		if !cas.Has(name) {
+			allErrs = append(allErrs, field.NotSupported(fldPath.Child("mounts"), name, cas.List()))
+			continue
+		}
+		if strings.Trim(mount, "/") == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("mounts").Key(name), "must be the path of the pki secrets engine"))
+		}
+	}
+	if v.Mounts["ca"] != "" && spec.ExternalCA != nil {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("mounts").Key("ca"), "can't be used with externalCA"))
+	}
+
+	return allErrs
+}
+
+// ValidateOIDC validates the oidc issuer and client of the apiserver.
+func ValidateOIDC(oidc *devopsv1.OIDC, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if oidc == nil {
+		return allErrs
+	}
+
+	u, err := url.Parse(oidc.IssuerURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("issuerURL"), oidc.IssuerURL, "must be a https url"))
+	}
+	if oidc.ClientID == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("clientID"), "must be specified"))
+	}
+	if len(oidc.RequiredClaims) > 1 {
+		allErrs = append(allErrs, field.TooMany(fldPath.Child("requiredClaims"), len(oidc.RequiredClaims), 1))
+	}
+	for k, v := range oidc.RequiredClaims {
+		if k == "" || v == "" || strings.Contains(k, "=") {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("requiredClaims").Key(k), v, "must be a non-empty claim and value"))
+		}
+	}
+	if oidc.CA != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(oidc.CA)) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("ca"), "<pem>", "must be PEM encoded certificates"))
+		}
+	}
+
+	return allErrs
+}
+
+// ValidateAudit validates the audit policy and the webhook of the apiserver,
+// the policy of the ConfigMap is validated once it's read.
+func ValidateAudit(audit *devopsv1.Audit, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if audit == nil {
+		return allErrs
+	}
+
+	if audit.Policy != "" {
+		if _, err := kubemisc.ParseAuditPolicy(audit.Policy); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("policy"), "<yaml>", err.Error()))
+		}
+	}
+	if audit.PolicyConfigMap != nil {
+		for _, msg := range k8svalidation.IsDNS1123Subdomain(audit.PolicyConfigMap.Name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("policyConfigMap", "name"), audit.PolicyConfigMap.Name, msg))
+		}
+	}
+	if audit.MaxAge < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxAge"), audit.MaxAge, "must be non-negative"))
+	}
+
+	webhook := audit.Webhook
+	if webhook == nil {
+		return allErrs
+	}
+	u, err := url.Parse(webhook.Server)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("webhook", "server"), webhook.Server, "must be an http or https url"))
+	}
+	if webhook.CA != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(webhook.CA)) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("webhook", "ca"), "<pem>", "must be PEM encoded certificates"))
+		}
+	}
+	if webhook.Mode != "" && webhook.Mode != "batch" && webhook.Mode != "blocking" {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("webhook", "mode"), webhook.Mode, []string{"batch", "blocking"}))
+	}
+
+	return allErrs
+}
+
+// ValidateEncryption validates the kms plugin of the encryption at rest.
+func ValidateEncryption(enc *devopsv1.Encryption, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if enc == nil || enc.KMS == nil {
+		return allErrs
+	}
+
+	kms := enc.KMS
+	for _, msg := range k8svalidation.IsDNS1123Label(kms.Name) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("kms", "name"), kms.Name, msg))
+	}
+	if !strings.HasPrefix(kms.Endpoint, "unix:///") {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("kms", "endpoint"), kms.Endpoint, "must be an absolute unix socket, e.g. unix:///var/run/kmsplugin/socket.sock"))
+	}
+	if kms.Timeout != nil && kms.Timeout.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("kms", "timeout"), kms.Timeout.Duration.String(), "must be positive"))
+	}
+
+	return allErrs
+}
+
+// ValidatePodSecurity validates the levels of the pod security.
+func ValidatePodSecurity(ps *devopsv1.PodSecurity, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if ps == nil {
+		return allErrs
+	}
+
+	levels := sets.NewString(podsecurity.Levels...)
+	if !levels.Has(ps.Enforce) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("enforce"), ps.Enforce, podsecurity.Levels))
+	}
+	if ps.Audit != "" && !levels.Has(ps.Audit) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("audit"), ps.Audit, podsecurity.Levels))
+	}
+	if ps.Warn != "" && !levels.Has(ps.Warn) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("warn"), ps.Warn, podsecurity.Levels))
+	}
+	for i, ns := range ps.ExemptNamespaces {
+		for _, msg := range k8svalidation.IsDNS1123Label(ns) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("exemptNamespaces").Index(i), ns, msg))
+		}
+	}
+
+	return allErrs
+}
+
+// ValidateInfraHooks validates the Jobs of the infrastructure hooks, only the pre hook gives the ips of the masters.
+func ValidateInfraHooks(hooks *devopsv1.InfraHooks, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if hooks == nil {
+		return allErrs
+	}
+
+	allErrs = append(allErrs, validateInfraHook(hooks.Pre, fldPath.Child("pre"))...)
+	allErrs = append(allErrs, validateInfraHook(hooks.Post, fldPath.Child("post"))...)
+	if hooks.Post != nil && hooks.Post.MachineIPsOutput != "" {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("post", "machineIPsOutput"), "only the pre hook gives the ips of the masters"))
+	}
+	return allErrs
+}
+
+func validateInfraHook(hook *devopsv1.InfraHook, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if hook == nil {
+		return allErrs
+	}
+
+	if hook.Image == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("image"), ""))
+	}
+	if len(hook.Command) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("command"), ""))
+	}
+	if hook.ConfigMapName != "" {
+		for _, msg := range k8svalidation.IsDNS1123Subdomain(hook.ConfigMapName) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("configMapName"), hook.ConfigMapName, msg))
+		}
+	}
+	if hook.SecretName != "" {
+		for _, msg := range k8svalidation.IsDNS1123Subdomain(hook.SecretName) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("secretName"), hook.SecretName, msg))
+		}
+	}
+	return allErrs
+}
@@ -1,8 +1,20 @@
 package validation
 
 import (
-	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"net/url"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/util/ssh"
+	utilvalidation "github.com/gostship/kunkka/pkg/util/validation"
+)
+
+var (
+	cgroupDriverAvails = []string{"systemd", "cgroupfs"}
 )
 
 // ValidateMachine validates a given machine.
@@ -27,5 +39,84 @@ func ValidateMachineSpec(spec *devopsv1.MachineSpec, fldPath *field.Path) field.
 	// 	}
 	// }
 
+	allErrs = append(allErrs, ValidateKubeletConfig(spec.Kubelet, fldPath.Child("kubelet"))...)
+	if spec.Machine != nil {
+		allErrs = append(allErrs, ValidateSSHCredentialRef(spec.Machine.CredentialRef, fldPath.Child("machine", "credentialRef"))...)
+		allErrs = append(allErrs, ValidateHostKey(spec.Machine.HostKey, fldPath.Child("machine", "hostKey"))...)
+	}
+
+	return allErrs
+}
+
+// ValidateSSHCredentialRef validates the ssh credential references either a Secret or a vault secret.
+func ValidateSSHCredentialRef(ref *devopsv1.SSHCredentialRef, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if ref == nil {
+		return allErrs
+	}
+
+	if (ref.SecretName == "") == (ref.Vault == nil) {
+		return append(allErrs, field.Invalid(fldPath, ref, "must reference either secretName or vault"))
+	}
+	if ref.SecretName != "" {
+		for _, msg := range k8svalidation.IsDNS1123Subdomain(ref.SecretName) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("secretName"), ref.SecretName, msg))
+		}
+		return allErrs
+	}
+
+	v := ref.Vault
+	u, err := url.Parse(v.Address)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("vault", "address"), v.Address, "must be a http or https url"))
+	}
+	if strings.Trim(v.Path, "/") == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("vault", "path"), "must be the path of the kv secret"))
+	}
+	for _, msg := range k8svalidation.IsDNS1123Subdomain(v.TokenSecretName) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("vault", "tokenSecretName"), v.TokenSecretName, msg))
+	}
+	return allErrs
+}
+
+// ValidateHostKey validates the host key is a public key in the authorized_keys format.
+func ValidateHostKey(key string, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if key == "" {
+		return allErrs
+	}
+
+	if _, err := ssh.ParseHostKey(key); err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath, key, err.Error()))
+	}
+	return allErrs
+}
+
+// ValidateKubeletConfig validates the kubelet fragment of the machine.
+func ValidateKubeletConfig(kc *devopsv1.KubeletConfig, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if kc == nil {
+		return allErrs
+	}
+
+	if kc.MaxPods != nil && *kc.MaxPods <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxPods"), *kc.MaxPods, "must be greater than 0"))
+	}
+	if kc.CgroupDriver != "" {
+		allErrs = append(allErrs, utilvalidation.ValidateEnum(kc.CgroupDriver, fldPath.Child("cgroupDriver"), cgroupDriverAvails)...)
+	}
+	for k := range kc.EvictionSoft {
+		if _, ok := kc.EvictionSoftGracePeriod[k]; !ok {
+			allErrs = append(allErrs, field.Required(fldPath.Child("evictionSoftGracePeriod").Key(k), "grace period is required by evictionSoft"))
+		}
+	}
+	for name, reserved := range map[string]map[string]string{"kubeReserved": kc.KubeReserved, "systemReserved": kc.SystemReserved} {
+		for k, v := range reserved {
+			if _, err := resource.ParseQuantity(v); err != nil {
+				allErrs = append(allErrs, field.Invalid(fldPath.Child(name).Key(k), v, err.Error()))
+			}
+		}
+	}
+
 	return allErrs
 }
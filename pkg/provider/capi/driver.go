@@ -0,0 +1,275 @@
+package capi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/credential"
+	"github.com/gostship/kunkka/pkg/provider/infra"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// kubeconfigKey the key of the kubeconfig of the CAPI management cluster in the infrastructure secret
+	kubeconfigKey = "kubeconfig"
+
+	apiserverPort = 6443
+)
+
+// Driver renders the instances as the CAPI Machines in the management cluster, the infrastructure providers of
+// CAPI provision them and report their addresses. The CAPI resources are found by name and owned by the
+// cluster of their owner annotation.
+type Driver struct {
+	mu sync.Mutex
+	// clients the clients of each kubeconfig of the management clusters
+	clients map[string]client.Client
+	// newClient returns the client of the management cluster, it's replaced by the tests.
+	newClient func(kubeconfig []byte) (client.Client, error)
+}
+
+var _ infra.EndpointDriver = &Driver{}
+
+func NewDriver() *Driver {
+	return &Driver{
+		clients: make(map[string]client.Client),
+		newClient: func(kubeconfig []byte) (client.Client, error) {
+			cfg, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+			if err != nil {
+				return nil, errors.Wrap(err, "load kubeconfig of the capi management cluster")
+			}
+			return client.New(cfg, client.Options{})
+		},
+	}
+}
+
+func (d *Driver) Validate(in *devopsv1.Infrastructure, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	capiPath := fldPath.Child("capi")
+	spec := in.CAPI
+	if spec == nil {
+		return append(allErrs, field.Required(capiPath, "must be set for the capi clusters"))
+	}
+	allErrs = append(allErrs, validateRef(spec.InfrastructureRef, capiPath.Child("infrastructureRef"))...)
+	allErrs = append(allErrs, validateRef(spec.MachineTemplate, capiPath.Child("machineTemplate"))...)
+	return allErrs
+}
+
+func validateRef(ref devopsv1.CAPIObjectReference, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if ref.APIVersion == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("apiVersion"), ""))
+	}
+	if ref.Kind == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("kind"), ""))
+	}
+	if ref.Name == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("name"), ""))
+	}
+	return allErrs
+}
+
+func (d *Driver) EnsureInstance(ctx context.Context, c *common.Cluster, spec *infra.InstanceSpec) (*infra.Instance, error) {
+	cli, ns, err := d.client(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	_, err = ensureCluster(ctx, cli, c, ns)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := get(ctx, cli, capiVersion, "Machine", ns, spec.Name)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return &infra.Instance{ID: spec.Name, Name: spec.Name}, createMachine(ctx, cli, c, ns, spec)
+	}
+	if err := checkOwner(m, c); err != nil {
+		return nil, err
+	}
+	return &infra.Instance{ID: string(m.GetUID()), Name: spec.Name, IP: machineIP(m)}, nil
+}
+
+// createMachine creates the bootstrap data and the infrastructure machine of the instance, then the Machine
+// referencing them, the ones created by the last try are kept.
+func createMachine(ctx context.Context, cli client.Client, c *common.Cluster, ns string, spec *infra.InstanceSpec) error {
+	capi := c.Spec.Infrastructure.CAPI
+	err := credential.LoadSSH(ctx, c.Client, c.Namespace, spec.Machine)
+	if err != nil {
+		return err
+	}
+	userData, err := infra.CloudConfig(spec.Name, spec.Machine)
+	if err != nil {
+		return err
+	}
+	err = create(ctx, cli, newBootstrapSecret(c, ns, spec, userData))
+	if err != nil {
+		return err
+	}
+
+	ref := capi.MachineTemplate
+	template, err := get(ctx, cli, ref.APIVersion, ref.Kind, ns, ref.Name)
+	if err != nil {
+		return err
+	}
+	if template == nil {
+		return fmt.Errorf("machine template %s: %s/%s is not found", ref.Kind, ns, ref.Name)
+	}
+	infraMachine, err := newInfraMachine(template, c, spec.Name)
+	if err != nil {
+		return err
+	}
+	err = create(ctx, cli, infraMachine)
+	if err != nil {
+		return err
+	}
+	return create(ctx, cli, newCAPIMachine(c, infraMachine, spec))
+}
+
+// DeleteInstance deletes the Machine, its infrastructure machine and its bootstrap data, CAPI deletes the
+// instance with the infrastructure machine.
+func (d *Driver) DeleteInstance(ctx context.Context, c *common.Cluster, name string) error {
+	cli, ns, err := d.client(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	// the infrastructure machine left by a failed try has no Machine
+	ref := c.Spec.Infrastructure.CAPI.MachineTemplate
+	apiVersion, kind := ref.APIVersion, strings.TrimSuffix(ref.Kind, "Template")
+	m, err := get(ctx, cli, capiVersion, "Machine", ns, name)
+	if err != nil {
+		return err
+	}
+	if m != nil {
+		if err := checkOwner(m, c); err != nil {
+			return err
+		}
+		if err := remove(ctx, cli, m); err != nil {
+			return err
+		}
+		if v, _, _ := unstructured.NestedString(m.Object, "spec", "infrastructureRef", "apiVersion"); v != "" {
+			apiVersion = v
+		}
+		if k, _, _ := unstructured.NestedString(m.Object, "spec", "infrastructureRef", "kind"); k != "" {
+			kind = k
+		}
+	}
+
+	infraMachine, err := get(ctx, cli, apiVersion, kind, ns, name)
+	if err != nil {
+		return err
+	}
+	if infraMachine != nil {
+		if err := checkOwner(infraMachine, c); err != nil {
+			return err
+		}
+		if err := remove(ctx, cli, infraMachine); err != nil {
+			return err
+		}
+	}
+	return remove(ctx, cli, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: bootstrapSecretName(name)}})
+}
+
+// EnsureEndpoint returns the control plane endpoint of the CAPI Cluster set by its infrastructure cluster, e.g.
+// the load balancer of an AWSCluster balancing the Machines of the masters. It returns nil if the
+// infrastructure cluster has none.
+func (d *Driver) EnsureEndpoint(ctx context.Context, c *common.Cluster) (*infra.Endpoint, error) {
+	cli, ns, err := d.client(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	cluster, err := ensureCluster(ctx, cli, c, ns)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := &infra.Endpoint{Port: apiserverPort}
+	if ready, _, _ := unstructured.NestedBool(cluster.Object, "status", "infrastructureReady"); !ready {
+		return pending, nil
+	}
+	host, _, _ := unstructured.NestedString(cluster.Object, "spec", "controlPlaneEndpoint", "host")
+	if host == "" {
+		return nil, nil
+	}
+	port, _, _ := unstructured.NestedInt64(cluster.Object, "spec", "controlPlaneEndpoint", "port")
+	if port == 0 {
+		port = apiserverPort
+	}
+	return &infra.Endpoint{Host: host, Port: int32(port)}, nil
+}
+
+// DeleteEndpoint deletes the CAPI Cluster after the Machines, CAPI deletes its infrastructure cluster with it.
+func (d *Driver) DeleteEndpoint(ctx context.Context, c *common.Cluster) error {
+	cli, ns, err := d.client(ctx, c)
+	if err != nil {
+		return err
+	}
+	cluster, err := get(ctx, cli, capiVersion, "Cluster", ns, c.Name)
+	if err != nil || cluster == nil {
+		return err
+	}
+	if err := checkOwner(cluster, c); err != nil {
+		return err
+	}
+	return remove(ctx, cli, cluster)
+}
+
+// ensureCluster creates the CAPI Cluster of the cluster if it doesn't exist and returns it.
+func ensureCluster(ctx context.Context, cli client.Client, c *common.Cluster, ns string) (*unstructured.Unstructured, error) {
+	cluster, err := get(ctx, cli, capiVersion, "Cluster", ns, c.Name)
+	if err != nil {
+		return nil, err
+	}
+	if cluster != nil {
+		return cluster, checkOwner(cluster, c)
+	}
+	cluster = newCAPICluster(c, ns)
+	return cluster, create(ctx, cli, cluster)
+}
+
+// client returns the client of the management cluster of the kubeconfig of the infrastructure secret and the
+// namespace of the CAPI resources.
+func (d *Driver) client(ctx context.Context, c *common.Cluster) (client.Client, string, error) {
+	capi := c.Spec.Infrastructure.CAPI
+	ns := capi.Namespace
+	if ns == "" {
+		ns = c.Namespace
+	}
+	s, err := infra.Secret(ctx, c)
+	if err != nil {
+		return nil, "", err
+	}
+	kubeconfig, err := infra.SecretValue(s, kubeconfigKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// the updated kubeconfig gets a new client
+	sum := sha256.Sum256([]byte(kubeconfig))
+	key := hex.EncodeToString(sum[:])
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cli, ok := d.clients[key]
+	if !ok {
+		cli, err = d.newClient([]byte(kubeconfig))
+		if err != nil {
+			return nil, "", err
+		}
+		d.clients[key] = cli
+	}
+	return cli, ns, nil
+}
@@ -0,0 +1,210 @@
+package capi
+
+import (
+	"context"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/infra"
+	"github.com/gostship/kunkka/pkg/provider/providertest"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const infraVersion = "infrastructure.cluster.x-k8s.io/v1alpha3"
+
+// newManagementClient returns the fake CAPI management cluster with the machine template.
+func newManagementClient() client.Client {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	for _, gvk := range []schema.GroupVersionKind{
+		schema.FromAPIVersionAndKind(capiVersion, "Cluster"),
+		schema.FromAPIVersionAndKind(capiVersion, "Machine"),
+		schema.FromAPIVersionAndKind(infraVersion, "AWSMachine"),
+		schema.FromAPIVersionAndKind(infraVersion, "AWSMachineTemplate"),
+	} {
+		scheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+	}
+
+	template := newObject(infraVersion, "AWSMachineTemplate", "capi", "workers")
+	template.Object["spec"] = map[string]interface{}{
+		"template": map[string]interface{}{
+			"spec": map[string]interface{}{"instanceType": "m5.xlarge"},
+		},
+	}
+	return fake.NewFakeClientWithScheme(scheme, template)
+}
+
+func newCluster() *common.Cluster {
+	return providertest.NewInfraCluster(ProviderName, &devopsv1.Infrastructure{
+		SecretName: "capi",
+		CAPI: &devopsv1.CAPIInfrastructure{
+			Namespace:         "capi",
+			InfrastructureRef: devopsv1.CAPIObjectReference{APIVersion: infraVersion, Kind: "AWSCluster", Name: "dke"},
+			MachineTemplate:   devopsv1.CAPIObjectReference{APIVersion: infraVersion, Kind: "AWSMachineTemplate", Name: "workers"},
+		},
+	}, map[string][]byte{kubeconfigKey: []byte("apiVersion: v1\nkind: Config\n")},
+		providertest.Secret("nodes", map[string][]byte{"password": []byte("secret")}))
+}
+
+func TestDriver(t *testing.T) {
+	ctx := context.Background()
+	mgmt := newManagementClient()
+	c := newCluster()
+	d := NewDriver()
+	clients := 0
+	d.newClient = func(kubeconfig []byte) (client.Client, error) {
+		clients++
+		return mgmt, nil
+	}
+
+	spec := &infra.InstanceSpec{
+		Name: infra.MasterName(c.Name, 0),
+		Role: infra.RoleMaster,
+		Machine: &devopsv1.ClusterMachine{
+			Username:      "root",
+			CredentialRef: &devopsv1.SSHCredentialRef{SecretName: "nodes"},
+			HostCni:       &devopsv1.ClusterCni{RackTag: "us-east-1a"},
+		},
+	}
+	instance, err := d.EnsureInstance(ctx, c, spec)
+	if err != nil {
+		t.Fatalf("EnsureInstance() error = %v", err)
+	}
+	if instance.IP != "" {
+		t.Errorf("EnsureInstance() = %+v, want booting", instance)
+	}
+
+	cluster := newObject(capiVersion, "Cluster", "capi", "dke")
+	if err := mgmt.Get(ctx, types.NamespacedName{Namespace: "capi", Name: "dke"}, cluster); err != nil {
+		t.Fatalf("get capi cluster error = %v", err)
+	}
+	if ref, _, _ := unstructured.NestedString(cluster.Object, "spec", "infrastructureRef", "kind"); ref != "AWSCluster" {
+		t.Errorf("capi cluster infrastructureRef kind = %q, want AWSCluster", ref)
+	}
+
+	key := types.NamespacedName{Namespace: "capi", Name: spec.Name}
+	machine := newObject(capiVersion, "Machine", "capi", spec.Name)
+	if err := mgmt.Get(ctx, key, machine); err != nil {
+		t.Fatalf("get machine error = %v", err)
+	}
+	if _, ok := machine.GetLabels()[controlPlaneLabel]; !ok {
+		t.Errorf("machine labels = %v, want the control plane label", machine.GetLabels())
+	}
+	if fd, _, _ := unstructured.NestedString(machine.Object, "spec", "failureDomain"); fd != "us-east-1a" {
+		t.Errorf("machine failureDomain = %q, want the rack", fd)
+	}
+	awsMachine := newObject(infraVersion, "AWSMachine", "capi", spec.Name)
+	if err := mgmt.Get(ctx, key, awsMachine); err != nil {
+		t.Fatalf("get infrastructure machine error = %v", err)
+	}
+	if it, _, _ := unstructured.NestedString(awsMachine.Object, "spec", "instanceType"); it != "m5.xlarge" {
+		t.Errorf("infrastructure machine spec = %v, want cloned from the template", awsMachine.Object["spec"])
+	}
+	secret := &corev1.Secret{}
+	if err := mgmt.Get(ctx, types.NamespacedName{Namespace: "capi", Name: spec.Name + "-bootstrap"}, secret); err != nil {
+		t.Fatalf("get bootstrap secret error = %v", err)
+	}
+	if data := string(secret.Data[bootstrapDataKey]); data == "" || secret.Type != bootstrapSecretType {
+		t.Errorf("bootstrap secret = %+v, want the cloud-config", secret)
+	}
+
+	// the infrastructure provider reports the address
+	unstructured.SetNestedField(machine.Object, true, "status", "infrastructureReady")
+	unstructured.SetNestedSlice(machine.Object, []interface{}{
+		map[string]interface{}{"type": "ExternalIP", "address": "54.0.0.1"},
+		map[string]interface{}{"type": "InternalIP", "address": "10.0.0.1"},
+	}, "status", "addresses")
+	if err := mgmt.Update(ctx, machine); err != nil {
+		t.Fatal(err)
+	}
+	instance, err = d.EnsureInstance(ctx, c, spec)
+	if err != nil {
+		t.Fatalf("EnsureInstance() error = %v", err)
+	}
+	if instance.IP != "10.0.0.1" {
+		t.Errorf("EnsureInstance() = %+v, want the internal ip", instance)
+	}
+	if clients != 1 {
+		t.Errorf("created %d clients, want the client reused", clients)
+	}
+
+	ep, err := d.EnsureEndpoint(ctx, c)
+	if err != nil {
+		t.Fatalf("EnsureEndpoint() error = %v", err)
+	}
+	if ep == nil || ep.Host != "" {
+		t.Errorf("EnsureEndpoint() = %+v, want pending", ep)
+	}
+	unstructured.SetNestedField(cluster.Object, true, "status", "infrastructureReady")
+	unstructured.SetNestedField(cluster.Object, "dke-apiserver.elb.amazonaws.com", "spec", "controlPlaneEndpoint", "host")
+	unstructured.SetNestedField(cluster.Object, int64(6443), "spec", "controlPlaneEndpoint", "port")
+	if err := mgmt.Update(ctx, cluster); err != nil {
+		t.Fatal(err)
+	}
+	ep, err = d.EnsureEndpoint(ctx, c)
+	if err != nil {
+		t.Fatalf("EnsureEndpoint() error = %v", err)
+	}
+	if ep == nil || ep.Host != "dke-apiserver.elb.amazonaws.com" || ep.Port != 6443 {
+		t.Errorf("EnsureEndpoint() = %+v, want the control plane endpoint", ep)
+	}
+
+	// the resources of the cluster created again with the same name are not touched
+	other := newCluster()
+	other.UID = "uid-2"
+	if err := d.DeleteInstance(ctx, other, spec.Name); err == nil {
+		t.Errorf("DeleteInstance() of the machine of another cluster succeeded")
+	}
+
+	if err := d.DeleteInstance(ctx, c, spec.Name); err != nil {
+		t.Fatalf("DeleteInstance() error = %v", err)
+	}
+	for _, obj := range []*unstructured.Unstructured{machine, awsMachine} {
+		if err := mgmt.Get(ctx, key, obj); err == nil {
+			t.Errorf("%s is not deleted", obj.GetKind())
+		}
+	}
+	if err := mgmt.Get(ctx, types.NamespacedName{Namespace: "capi", Name: spec.Name + "-bootstrap"}, secret); err == nil {
+		t.Errorf("bootstrap secret is not deleted")
+	}
+	if err := d.DeleteInstance(ctx, c, spec.Name); err != nil {
+		t.Errorf("DeleteInstance() of the deleted machine error = %v", err)
+	}
+	if err := d.DeleteEndpoint(ctx, c); err != nil {
+		t.Fatalf("DeleteEndpoint() error = %v", err)
+	}
+	if err := mgmt.Get(ctx, types.NamespacedName{Namespace: "capi", Name: "dke"}, cluster); err == nil {
+		t.Errorf("capi cluster is not deleted")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	d := NewDriver()
+	ref := devopsv1.CAPIObjectReference{APIVersion: infraVersion, Kind: "AWSCluster", Name: "dke"}
+	tests := []struct {
+		name string
+		capi *devopsv1.CAPIInfrastructure
+		errs int
+	}{
+		{name: "valid", capi: &devopsv1.CAPIInfrastructure{InfrastructureRef: ref, MachineTemplate: ref}},
+		{name: "no capi", errs: 1},
+		{name: "no template", capi: &devopsv1.CAPIInfrastructure{InfrastructureRef: ref}, errs: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := d.Validate(&devopsv1.Infrastructure{SecretName: "capi", CAPI: tt.capi}, field.NewPath("spec", "infrastructure"))
+			if len(errs) != tt.errs {
+				t.Errorf("Validate() = %v, want %d errors", errs, tt.errs)
+			}
+		})
+	}
+}
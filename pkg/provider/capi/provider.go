@@ -0,0 +1,36 @@
+// Package capi provides the clusters whose machines are the Machines of Cluster API provisioned by its
+// infrastructure providers, then installed and managed by kunkka like the bare metal machines.
+package capi
+
+import (
+	clusterprovider "github.com/gostship/kunkka/pkg/provider/cluster"
+	"github.com/gostship/kunkka/pkg/provider/config"
+	"github.com/gostship/kunkka/pkg/provider/infra"
+	machineprovider "github.com/gostship/kunkka/pkg/provider/machine"
+	"k8s.io/klog"
+)
+
+const ProviderName = "CAPI"
+
+// driver is shared by the cluster and machine providers
+var driver = NewDriver()
+
+func AddCluster(mgr *clusterprovider.CpManager, cfg *config.Config) error {
+	p, err := infra.NewClusterProvider(mgr, cfg, ProviderName, driver)
+	if err != nil {
+		klog.Errorf("init cluster provider error: %s", err)
+		return err
+	}
+	mgr.Register(p.Name(), p)
+	return nil
+}
+
+func AddMachine(mgr *machineprovider.MpManager, cfg *config.Config) error {
+	p, err := infra.NewMachineProvider(mgr, cfg, ProviderName, driver)
+	if err != nil {
+		klog.Errorf("init machine provider error: %s", err)
+		return err
+	}
+	mgr.Register(p.Name(), p)
+	return nil
+}
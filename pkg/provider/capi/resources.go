@@ -0,0 +1,200 @@
+package capi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/infra"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	capiVersion = "cluster.x-k8s.io/v1alpha3"
+
+	clusterNameLabel              = "cluster.x-k8s.io/cluster-name"
+	controlPlaneLabel             = "cluster.x-k8s.io/control-plane"
+	clonedFromNameAnnotation      = "cluster.x-k8s.io/cloned-from-name"
+	clonedFromGroupKindAnnotation = "cluster.x-k8s.io/cloned-from-groupkind"
+	// excludeDrainingAnnotation skips the drain of the node of the deleted Machine, the nodes are drained by kunkka
+	excludeDrainingAnnotation = "machine.cluster.x-k8s.io/exclude-node-draining"
+	// ownerAnnotation is the cluster owning the CAPI resources, value: namespace/name/uid
+	ownerAnnotation = "k8s.io/owner"
+
+	bootstrapSecretType = "cluster.x-k8s.io/secret"
+	bootstrapDataKey    = "value"
+)
+
+// object is the typed or unstructured object created and deleted by the driver.
+type object interface {
+	runtime.Object
+	metav1.Object
+}
+
+func newObject(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	return u
+}
+
+// get returns the object, it's nil if not found.
+func get(ctx context.Context, cli client.Client, apiVersion, kind, namespace, name string) (*unstructured.Unstructured, error) {
+	u := newObject(apiVersion, kind, namespace, name)
+	err := cli.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, u)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "get %s: %s/%s", kind, namespace, name)
+	}
+	return u, nil
+}
+
+// create creates the object, the one already created by the last try is kept.
+func create(ctx context.Context, cli client.Client, obj object) error {
+	err := cli.Create(ctx, obj)
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.Wrapf(err, "create %s/%s", obj.GetNamespace(), obj.GetName())
+	}
+	return nil
+}
+
+// remove deletes the object, the one not found is ignored.
+func remove(ctx context.Context, cli client.Client, obj object) error {
+	err := cli.Delete(ctx, obj)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "delete %s/%s", obj.GetNamespace(), obj.GetName())
+	}
+	return nil
+}
+
+// owner is the value of the owner annotation of the CAPI resources of the cluster, the uid tells the cluster
+// created again with the same name.
+func owner(c *common.Cluster) string {
+	return fmt.Sprintf("%s/%s/%s", c.Namespace, c.Name, c.UID)
+}
+
+func checkOwner(u *unstructured.Unstructured, c *common.Cluster) error {
+	if u.GetAnnotations()[ownerAnnotation] != owner(c) {
+		return fmt.Errorf("%s: %s/%s is not owned by cluster: %s", u.GetKind(), u.GetNamespace(), u.GetName(), c.Name)
+	}
+	return nil
+}
+
+func objectRef(ref devopsv1.CAPIObjectReference, namespace string) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": ref.APIVersion,
+		"kind":       ref.Kind,
+		"name":       ref.Name,
+		"namespace":  namespace,
+	}
+}
+
+// newCAPICluster renders the CAPI Cluster of the cluster with its infrastructure cluster.
+func newCAPICluster(c *common.Cluster, namespace string) *unstructured.Unstructured {
+	u := newObject(capiVersion, "Cluster", namespace, c.Name)
+	u.SetAnnotations(map[string]string{ownerAnnotation: owner(c)})
+	u.Object["spec"] = map[string]interface{}{
+		"infrastructureRef": objectRef(c.Spec.Infrastructure.CAPI.InfrastructureRef, namespace),
+	}
+	return u
+}
+
+// newInfraMachine clones the infrastructure machine of the instance from the spec of the machine template like
+// a MachineSet of CAPI, e.g. an AWSMachine from an AWSMachineTemplate.
+func newInfraMachine(template *unstructured.Unstructured, c *common.Cluster, name string) (*unstructured.Unstructured, error) {
+	spec, ok, err := unstructured.NestedMap(template.Object, "spec", "template", "spec")
+	if err != nil || !ok {
+		return nil, fmt.Errorf("%s: %s has no spec.template.spec", template.GetKind(), template.GetName())
+	}
+	gvk := template.GroupVersionKind()
+	u := newObject(template.GetAPIVersion(), strings.TrimSuffix(gvk.Kind, "Template"), template.GetNamespace(), name)
+	u.SetLabels(map[string]string{clusterNameLabel: c.Name})
+	u.SetAnnotations(map[string]string{
+		ownerAnnotation:               owner(c),
+		clonedFromNameAnnotation:      template.GetName(),
+		clonedFromGroupKindAnnotation: schema.GroupKind{Group: gvk.Group, Kind: gvk.Kind}.String(),
+	})
+	u.Object["spec"] = spec
+	return u, nil
+}
+
+// newBootstrapSecret returns the bootstrap data of the instance, i.e. the cloud-config of its machine.
+func newBootstrapSecret(c *common.Cluster, namespace string, spec *infra.InstanceSpec, userData []byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   namespace,
+			Name:        bootstrapSecretName(spec.Name),
+			Labels:      map[string]string{clusterNameLabel: c.Name},
+			Annotations: map[string]string{ownerAnnotation: owner(c)},
+		},
+		Type: bootstrapSecretType,
+		Data: map[string][]byte{bootstrapDataKey: userData},
+	}
+}
+
+func bootstrapSecretName(instance string) string {
+	return instance + "-bootstrap"
+}
+
+// newCAPIMachine renders the CAPI Machine of the instance with its infrastructure machine and bootstrap data, the
+// rack of the machine is its failure domain.
+func newCAPIMachine(c *common.Cluster, infraMachine *unstructured.Unstructured, spec *infra.InstanceSpec) *unstructured.Unstructured {
+	u := newObject(capiVersion, "Machine", infraMachine.GetNamespace(), spec.Name)
+	labels := map[string]string{clusterNameLabel: c.Name}
+	if spec.Role == infra.RoleMaster {
+		labels[controlPlaneLabel] = ""
+	}
+	u.SetLabels(labels)
+	u.SetAnnotations(map[string]string{
+		ownerAnnotation:           owner(c),
+		excludeDrainingAnnotation: "true",
+	})
+	ms := map[string]interface{}{
+		"clusterName": c.Name,
+		"bootstrap": map[string]interface{}{
+			"dataSecretName": bootstrapSecretName(spec.Name),
+		},
+		"infrastructureRef": map[string]interface{}{
+			"apiVersion": infraMachine.GetAPIVersion(),
+			"kind":       infraMachine.GetKind(),
+			"name":       infraMachine.GetName(),
+			"namespace":  infraMachine.GetNamespace(),
+		},
+	}
+	if m := spec.Machine; m != nil && m.HostCni != nil && m.HostCni.RackTag != "" {
+		ms["failureDomain"] = m.HostCni.RackTag
+	}
+	u.Object["spec"] = ms
+	return u
+}
+
+// machineIP returns the internal ip of the Machine once its infrastructure is ready.
+func machineIP(m *unstructured.Unstructured) string {
+	ready, _, _ := unstructured.NestedBool(m.Object, "status", "infrastructureReady")
+	if !ready {
+		return ""
+	}
+	addresses, _, _ := unstructured.NestedSlice(m.Object, "status", "addresses")
+	for _, a := range addresses {
+		addr, ok := a.(map[string]interface{})
+		if ok && addr["type"] == "InternalIP" {
+			if ip, ok := addr["address"].(string); ok && ip != "" {
+				return ip
+			}
+		}
+	}
+	return ""
+}
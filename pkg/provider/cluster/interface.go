@@ -13,11 +13,16 @@ import (
 	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
 	"github.com/gostship/kunkka/pkg/constants"
 	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/logs"
+	"github.com/gostship/kunkka/pkg/metrics"
+	"github.com/gostship/kunkka/pkg/timeouts"
+	"github.com/gostship/kunkka/pkg/tracing"
+	"github.com/gostship/kunkka/pkg/util/ssh"
 	"github.com/thoas/go-funk"
+	"go.opentelemetry.io/otel/trace"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/apiserver/pkg/server/mux"
-	"k8s.io/klog"
 )
 
 const (
@@ -25,6 +30,18 @@ const (
 	ReasonWaitingProcess    = "WaitingProcess"
 	ReasonSuccessfulProcess = "SuccessfulProcess"
 	ReasonSkipProcess       = "SkipProcess"
+	// ReasonDegraded the handler of the strict cluster failed for the cluster is unavailable
+	ReasonDegraded = "Degraded"
+	// ReasonHostKeyMismatch the host key of a machine or its bastions differs from the known one
+	ReasonHostKeyMismatch = "HostKeyMismatch"
+	// ReasonRetriesExhausted the handler failed the attempts of its budget, it's not retried until the
+	// phaseRetry annotation
+	ReasonRetriesExhausted = "RetriesExhausted"
+	// ReasonCheckpointed the handler is skipped for it completed before, see status.completedPhases
+	ReasonCheckpointed = "Checkpointed"
+	// ReasonTimeout the handler failed for a wait of the phase timed out, e.g. systemInstall or nodeReady,
+	// the timeouts are set by the waits
+	ReasonTimeout = "Timeout"
 
 	ConditionTypeDone = "EnsureDone"
 )
@@ -60,6 +77,8 @@ type DelegateProvider struct {
 	CreateHandlers []Handler
 	DeleteHandlers []Handler
 	UpdateHandlers []Handler
+	// ResyncHandlers apply the addons of the running cluster again on each addonResync interval
+	ResyncHandlers []Handler
 }
 
 func (p *DelegateProvider) Name() string {
@@ -97,6 +116,8 @@ func (p *DelegateProvider) AfterCreate(cluster *common.Cluster) error {
 }
 
 func (p *DelegateProvider) OnCreate(ctx context.Context, cluster *common.Cluster) error {
+	ctx = p.withLogger(ctx, cluster)
+	logger := logs.FromContext(ctx)
 	condition, err := p.getCreateCurrentCondition(cluster)
 	if err != nil {
 		return err
@@ -112,6 +133,15 @@ func (p *DelegateProvider) OnCreate(ctx context.Context, cluster *common.Cluster
 			LastTransitionTime: now,
 			Reason:             ReasonSkipProcess,
 		})
+	} else if cluster.PhaseCompleted(condition.Type) {
+		logger.Info("OnCreate handler completed before, skip", logs.PhaseKey, condition.Type)
+		cluster.SetCondition(devopsv1.ClusterCondition{
+			Type:               condition.Type,
+			Status:             devopsv1.ConditionTrue,
+			LastProbeTime:      now,
+			LastTransitionTime: now,
+			Reason:             ReasonCheckpointed,
+		})
 	} else {
 		f := p.getCreateHandler(condition.Type)
 		if f == nil {
@@ -119,19 +149,21 @@ func (p *DelegateProvider) OnCreate(ctx context.Context, cluster *common.Cluster
 		}
 
 		handlerName := f.Name()
-		klog.Infof("clusterName: %s OnCreate handler: %s", cluster.Name, handlerName)
-		err = f(ctx, cluster)
+		if after := retryAfter(cluster.Cluster, condition); after > 0 {
+			logger.V(1).Info("OnCreate handler backs off", logs.PhaseKey, handlerName, "retryAfter", after.String())
+			return nil
+		}
+		phaseCtx, span := p.startPhase(ctx, cluster, handlerName)
+		logs.FromContext(phaseCtx).Info("OnCreate handler")
+		err = p.run(phaseCtx, f, cluster)
+		tracing.End(span, err)
+		duration := metav1.Duration{Duration: time.Since(now.Time)}
+		metrics.ObservePhase(metrics.KindCluster, condition.Type, duration.Duration, err)
 		if err != nil {
-			klog.Errorf("cluster: %s OnCreate handler: %s err: %+v", cluster.Name, handlerName, err)
-			cluster.SetCondition(devopsv1.ClusterCondition{
-				Type:          condition.Type,
-				Status:        devopsv1.ConditionFalse,
-				LastProbeTime: now,
-				Message:       err.Error(),
-				Reason:        ReasonFailedProcess,
-			})
-			cluster.Cluster.Status.Reason = ReasonFailedProcess
-			cluster.Cluster.Status.Message = err.Error()
+			logs.FromContext(phaseCtx).Error(err, "OnCreate handler failed")
+			if setFailedCondition(cluster, condition.Type, err, duration) {
+				cluster.Cluster.Status.Phase = devopsv1.ClusterFailed
+			}
 			return nil
 		}
 
@@ -139,9 +171,11 @@ func (p *DelegateProvider) OnCreate(ctx context.Context, cluster *common.Cluster
 			Type:               condition.Type,
 			Status:             devopsv1.ConditionTrue,
 			LastProbeTime:      now,
-			LastTransitionTime: now,
+			LastTransitionTime: metav1.Now(),
 			Reason:             ReasonSuccessfulProcess,
+			Duration:           duration,
 		})
+		cluster.CompletePhase(condition.Type)
 	}
 
 	nextConditionType := p.getNextConditionType(condition.Type)
@@ -188,6 +222,9 @@ func tryFindHandler(handlerName string, handlers []string, cluster *common.Clust
 }
 
 func (p *DelegateProvider) OnUpdate(ctx context.Context, cluster *common.Cluster) error {
+	ctx = p.withLogger(ctx, cluster)
+	logger := logs.FromContext(ctx)
+	p.resync(ctx, cluster)
 	if cluster.Cluster.Annotations == nil {
 		return nil
 	}
@@ -205,20 +242,27 @@ func (p *DelegateProvider) OnUpdate(ctx context.Context, cluster *common.Cluster
 			continue
 		}
 
-		klog.Infof("clusterName: %s OnUpdate handler: %s", cluster.Name, handlerName)
+		if condition := getCondition(cluster.Cluster, handlerName); condition != nil {
+			if condition.Reason == ReasonRetriesExhausted {
+				logger.V(1).Info("OnUpdate handler retries exhausted, skip", logs.PhaseKey, handlerName)
+				continue
+			}
+			if after := retryAfter(cluster.Cluster, condition); after > 0 {
+				logger.V(1).Info("OnUpdate handler backs off", logs.PhaseKey, handlerName, "retryAfter", after.String())
+				return nil
+			}
+		}
+
+		phaseCtx, span := p.startPhase(ctx, cluster, handlerName)
+		logs.FromContext(phaseCtx).Info("OnUpdate handler")
 		now := metav1.Now()
-		err := f(ctx, cluster)
+		err := p.run(phaseCtx, f, cluster)
+		tracing.End(span, err)
+		duration := metav1.Duration{Duration: time.Since(now.Time)}
+		metrics.ObservePhase(metrics.KindCluster, handlerName, duration.Duration, err)
 		if err != nil {
-			klog.Errorf("cluster: %s OnUpdate handler: %s err: %+v", cluster.Name, handlerName, err)
-			cluster.SetCondition(devopsv1.ClusterCondition{
-				Type:          handlerName,
-				Status:        devopsv1.ConditionFalse,
-				LastProbeTime: now,
-				Message:       err.Error(),
-				Reason:        ReasonFailedProcess,
-			})
-			cluster.Cluster.Status.Reason = ReasonFailedProcess
-			cluster.Cluster.Status.Message = err.Error()
+			logs.FromContext(phaseCtx).Error(err, "OnUpdate handler failed")
+			setFailedCondition(cluster, handlerName, err, duration)
 			return nil
 		}
 
@@ -226,8 +270,9 @@ func (p *DelegateProvider) OnUpdate(ctx context.Context, cluster *common.Cluster
 			Type:               handlerName,
 			Status:             devopsv1.ConditionTrue,
 			LastProbeTime:      now,
-			LastTransitionTime: now,
+			LastTransitionTime: metav1.Now(),
 			Reason:             ReasonSuccessfulProcess,
+			Duration:           duration,
 		})
 	}
 
@@ -235,9 +280,12 @@ func (p *DelegateProvider) OnUpdate(ctx context.Context, cluster *common.Cluster
 }
 
 func (p *DelegateProvider) OnDelete(ctx context.Context, cluster *common.Cluster) error {
+	ctx = p.withLogger(ctx, cluster)
 	for _, f := range p.DeleteHandlers {
-		klog.Infof("clusterName: %s OnDelete handler: %s", cluster.Name, f.Name())
-		err := f(ctx, cluster)
+		phaseCtx, span := p.startPhase(ctx, cluster, f.Name())
+		logs.FromContext(phaseCtx).Info("OnDelete handler")
+		err := p.run(phaseCtx, f, cluster)
+		tracing.End(span, err)
 		if err != nil {
 			return err
 		}
@@ -246,6 +294,115 @@ func (p *DelegateProvider) OnDelete(ctx context.Context, cluster *common.Cluster
 	return nil
 }
 
+// withLogger returns the context whose logger has the fields of the cluster and the provider.
+func (p *DelegateProvider) withLogger(ctx context.Context, cluster *common.Cluster) context.Context {
+	return logs.WithValues(ctx, logs.ClusterKey, cluster.Name, logs.ProviderKey, p.ProviderName)
+}
+
+// startPhase starts the span of the handler in the trace of the api request creating the cluster, and labels
+// the commands run by the handler with it.
+func (p *DelegateProvider) startPhase(ctx context.Context, cluster *common.Cluster, phase string) (context.Context, trace.Span) {
+	ctx, span := tracing.Start(tracing.Extract(ctx, cluster.Cluster), "cluster "+phase,
+		tracing.ClusterKey.String(cluster.Name),
+		tracing.PhaseKey.String(phase),
+		tracing.ProviderKey.String(p.ProviderName))
+	cluster.AuditPhase(phase, tracing.TraceParent(ctx))
+	return logs.WithValues(ctx, logs.PhaseKey, phase), span
+}
+
+// run calls the handler, the handler failed for the transient ssh errors is retried by the sshRetry wait,
+// so the handlers must be idempotent.
+func (p *DelegateProvider) run(ctx context.Context, f Handler, cluster *common.Cluster) error {
+	return timeouts.Retry(cluster.Cluster, timeouts.SSHRetry, ssh.IsTransient, func() error {
+		err := f(ctx, cluster)
+		if ssh.IsTransient(err) {
+			logs.FromContext(ctx).Info("transient err of the handler, retry", "err", err.Error())
+		}
+		return err
+	})
+}
+
+// setFailedCondition marks the condition of the handler failed, the unavailable cluster is reported as degraded.
+// The probe time of a degraded condition is kept while it's unchanged, so the status is not rewritten on every retry
+// and the requeue backs off. The other failures count the attempts of the condition, it returns true once they
+// exhaust the budget.
+func setFailedCondition(cluster *common.Cluster, conditionType string, err error, duration metav1.Duration) bool {
+	now := metav1.Now()
+	message := err.Error()
+	reason := ReasonFailedProcess
+	if ssh.IsHostKeyMismatch(err) {
+		reason = ReasonHostKeyMismatch
+	} else if timeouts.IsTimeout(err) {
+		reason = ReasonTimeout
+	}
+
+	var attempts int32
+	old := getCondition(cluster.Cluster, conditionType)
+	if old != nil && old.Status == devopsv1.ConditionFalse {
+		attempts = old.Attempts
+	}
+	if common.IsClusterUnavailable(err) {
+		reason = ReasonDegraded
+		if old != nil && old.Reason == reason && old.Message == message {
+			now = old.LastProbeTime
+			duration = old.Duration
+		}
+	} else {
+		attempts++
+	}
+
+	exhausted := reason != ReasonDegraded && timeouts.PhaseExhausted(attempts)
+	if exhausted {
+		reason = ReasonRetriesExhausted
+		message = fmt.Sprintf("failed %d attempts, last err: %s", attempts, message)
+	}
+
+	cluster.SetCondition(devopsv1.ClusterCondition{
+		Type:          conditionType,
+		Status:        devopsv1.ConditionFalse,
+		LastProbeTime: now,
+		Message:       message,
+		Reason:        reason,
+		Attempts:      attempts,
+		Duration:      duration,
+	})
+	cluster.Cluster.Status.Reason = reason
+	cluster.Cluster.Status.Message = message
+	metrics.RecordFailure(metrics.KindCluster, conditionType, reason)
+	return exhausted
+}
+
+// getCondition returns the condition of the type of the cluster, nil if there's none.
+func getCondition(c *devopsv1.Cluster, conditionType string) *devopsv1.ClusterCondition {
+	for i := range c.Status.Conditions {
+		if c.Status.Conditions[i].Type == conditionType {
+			return &c.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// retryAfter returns how long the failed condition still backs off, the degraded conditions are backed off
+// by the requeue of the controller instead.
+func retryAfter(c *devopsv1.Cluster, condition *devopsv1.ClusterCondition) time.Duration {
+	if condition.Status != devopsv1.ConditionFalse || condition.Reason == ReasonDegraded ||
+		condition.Reason == ReasonRetriesExhausted {
+		return 0
+	}
+	return timeouts.PhaseRetryAfter(c, condition.Attempts, condition.LastProbeTime.Time)
+}
+
+// RetryAfter returns the shortest time the failed conditions of the cluster still back off, 0 if none does.
+func RetryAfter(c *devopsv1.Cluster) time.Duration {
+	var after time.Duration
+	for i := range c.Status.Conditions {
+		if d := retryAfter(c, &c.Status.Conditions[i]); d > 0 && (after == 0 || d < after) {
+			after = d
+		}
+	}
+	return after
+}
+
 func (h Handler) Name() string {
 	name := runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
 	i := strings.Index(name, "Ensure")
@@ -293,7 +450,15 @@ func (p *DelegateProvider) getCreateCurrentCondition(c *common.Cluster) (*devops
 		return nil, errors.New("no create handlers")
 	}
 
-	if len(c.Cluster.Status.Conditions) == 0 {
+	// the probes of the health controller are not a phase
+	conditions := []devopsv1.ClusterCondition{}
+	for _, condition := range c.Cluster.Status.Conditions {
+		if condition.Type != devopsv1.ClusterConditionReachable {
+			conditions = append(conditions, condition)
+		}
+	}
+
+	if len(conditions) == 0 {
 		return &devopsv1.ClusterCondition{
 			Type:          p.CreateHandlers[0].Name(),
 			Status:        devopsv1.ConditionUnknown,
@@ -303,15 +468,15 @@ func (p *DelegateProvider) getCreateCurrentCondition(c *common.Cluster) (*devops
 		}, nil
 	}
 
-	for _, condition := range c.Cluster.Status.Conditions {
+	for _, condition := range conditions {
 		if condition.Status == devopsv1.ConditionFalse || condition.Status == devopsv1.ConditionUnknown {
 			return &condition, nil
 		}
 	}
 
-	if len(c.Cluster.Status.Conditions) < len(p.CreateHandlers) {
+	if len(conditions) < len(p.CreateHandlers) {
 		return &devopsv1.ClusterCondition{
-			Type:          p.CreateHandlers[len(c.Cluster.Status.Conditions)].Name(),
+			Type:          p.CreateHandlers[len(conditions)].Name(),
 			Status:        devopsv1.ConditionUnknown,
 			LastProbeTime: metav1.Now(),
 			Message:       "waiting process",
@@ -0,0 +1,87 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/timeouts"
+	"github.com/thoas/go-funk"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+)
+
+const (
+	// ConditionTypeDriftDetected the condition of the resync of the addons, it's true if the last resync
+	// reverted the objects deleted or edited in the cluster
+	ConditionTypeDriftDetected = "DriftDetected"
+
+	// ReasonDriftReverted the drifted objects are applied again
+	ReasonDriftReverted = "DriftReverted"
+	// ReasonInSync the objects of the addons are in sync
+	ReasonInSync = "InSync"
+)
+
+// resync runs the resync handlers once the addonResync interval passed since the last resync, the result
+// is recorded by the DriftDetected condition. The resync is skipped by the DriftDetected skip condition.
+func (p *DelegateProvider) resync(ctx context.Context, cluster *common.Cluster) {
+	if len(p.ResyncHandlers) == 0 ||
+		funk.ContainsString(cluster.Spec.Features.SkipConditions, ConditionTypeDriftDetected) {
+		return
+	}
+	if getCondition(cluster.Cluster, ConditionTypeDriftDetected) != nil && ResyncAfter(cluster.Cluster) > 0 {
+		return
+	}
+
+	now := metav1.Now()
+	for _, f := range p.ResyncHandlers {
+		klog.V(4).Infof("cluster: %s resync handler: %s", cluster.Name, f.Name())
+		err := p.run(ctx, f, cluster)
+		if err != nil {
+			klog.Errorf("cluster: %s resync handler: %s err: %+v", cluster.Name, f.Name(), err)
+			cluster.SetCondition(devopsv1.ClusterCondition{
+				Type:          ConditionTypeDriftDetected,
+				Status:        devopsv1.ConditionUnknown,
+				LastProbeTime: now,
+				Message:       err.Error(),
+				Reason:        ReasonFailedProcess,
+				Duration:      metav1.Duration{Duration: time.Since(now.Time)},
+			})
+			return
+		}
+	}
+
+	condition := devopsv1.ClusterCondition{
+		Type:          ConditionTypeDriftDetected,
+		Status:        devopsv1.ConditionFalse,
+		LastProbeTime: now,
+		Reason:        ReasonInSync,
+		Duration:      metav1.Duration{Duration: time.Since(now.Time)},
+	}
+	if drifted := cluster.Drifted(); len(drifted) > 0 {
+		sort.Strings(drifted)
+		klog.Warningf("cluster: %s addons drifted, reverted: %v", cluster.Name, drifted)
+		condition.Status = devopsv1.ConditionTrue
+		condition.Reason = ReasonDriftReverted
+		condition.Message = fmt.Sprintf("reverted: %s", strings.Join(drifted, ","))
+	}
+	cluster.SetCondition(condition)
+}
+
+// ResyncAfter returns how long until the next resync of the addons of the cluster, 0 if it's due or the
+// cluster is never resynced.
+func ResyncAfter(c *devopsv1.Cluster) time.Duration {
+	condition := getCondition(c, ConditionTypeDriftDetected)
+	if condition == nil {
+		return 0
+	}
+	d := time.Until(condition.LastProbeTime.Add(timeouts.Get(c, timeouts.AddonResync).Interval.Duration))
+	if d < 0 {
+		return 0
+	}
+	return d
+}
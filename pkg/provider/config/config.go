@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"path"
 	"strings"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
 )
 
 type Config struct {
@@ -15,6 +17,9 @@ type Config struct {
 	CustomRegistry string
 	CustomeCert    bool
 	CustomeImages  bool
+	// Waits overrides the wait parameters of the clusters of the providers by name, e.g. systemInstall,
+	// see pkg/timeouts for the names. The spec.waits of the clusters take precedence.
+	Waits map[string]devopsv1.WaitParam
 }
 
 type Registry struct {
@@ -57,6 +62,12 @@ func (r *Config) NeedSetHosts() bool {
 }
 
 func (r *Config) ImageFullName(name, tag string) string {
+	return r.RegistryImageFullName("", name, tag)
+}
+
+// RegistryImageFullName returns the image of the registry, e.g. the spec.registry of a cluster, or else of the
+// registry of the config.
+func (r *Config) RegistryImageFullName(registry, name, tag string) string {
 	b := new(bytes.Buffer)
 	b.WriteString(name)
 	if tag != "" {
@@ -67,6 +78,9 @@ func (r *Config) ImageFullName(name, tag string) string {
 		}
 	}
 
+	if registry != "" {
+		return path.Join(registry, b.String())
+	}
 	return path.Join(r.Registry.Domain, r.Registry.Namespace, b.String())
 }
 
@@ -6,7 +6,6 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 	"net/http"
 	"strings"
-	"time"
 
 	"crypto/rand"
 	"encoding/hex"
@@ -17,32 +16,29 @@ import (
 	"github.com/gostship/kunkka/pkg/provider/addons/cni"
 	"github.com/gostship/kunkka/pkg/provider/addons/coredns"
 	"github.com/gostship/kunkka/pkg/provider/addons/flannel"
+	"github.com/gostship/kunkka/pkg/provider/addons/gatekeeper"
+	"github.com/gostship/kunkka/pkg/provider/addons/inventory"
 	"github.com/gostship/kunkka/pkg/provider/addons/kubeproxy"
 	"github.com/gostship/kunkka/pkg/provider/addons/metricsserver"
+	"github.com/gostship/kunkka/pkg/provider/addons/multus"
+	"github.com/gostship/kunkka/pkg/provider/addons/podsecurity"
+	"github.com/gostship/kunkka/pkg/provider/phases/bootstraptoken"
 	"github.com/gostship/kunkka/pkg/provider/phases/certs"
+	"github.com/gostship/kunkka/pkg/provider/phases/encryption"
 	"github.com/gostship/kunkka/pkg/provider/phases/kubeadm"
 	"github.com/gostship/kunkka/pkg/provider/phases/kubemisc"
+	"github.com/gostship/kunkka/pkg/timeouts"
 	"github.com/gostship/kunkka/pkg/util/k8sutil"
 	"github.com/gostship/kunkka/pkg/util/pkiutil"
 	"github.com/pkg/errors"
-	"github.com/segmentio/ksuid"
+	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	bootstraputil "k8s.io/cluster-bootstrap/token/util"
 	"k8s.io/klog"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
-const (
-	tokenFileTemplate = `%s,admin,admin,system:masters
-`
-	additPolicy = `
-apiVersion: audit.k8s.io/v1
-kind: Policy
-rules:
-- level: Metadata
-`
-)
-
 func completeK8sVersion(cluster *common.Cluster) error {
 	cluster.Cluster.Status.Version = cluster.Spec.Version
 	return nil
@@ -100,10 +96,9 @@ func completeAddresses(cluster *common.Cluster) error {
 	return nil
 }
 
+// completeCredential generates no static admin token, the nodes join with the bootstrap token
+// and the operator uses the client certificates of the kubeconfigs.
 func completeCredential(cluster *common.Cluster) error {
-	token := ksuid.New().String()
-	cluster.ClusterCredential.Token = &token
-
 	bootstrapToken, err := bootstraputil.GenerateBootstrapToken()
 	if err != nil {
 		return err
@@ -144,17 +139,11 @@ func (p *Provider) EnsureClusterReady(ctx context.Context, c *common.Cluster) er
 		return err
 	}
 
-	var defaultRetry = wait.Backoff{
-		Steps:    20,
-		Duration: 6 * time.Second,
-		Factor:   1.0,
-		Jitter:   0.1,
-	}
-	werr := wait.ExponentialBackoff(defaultRetry, func() (bool, error) {
+	werr := wait.ExponentialBackoff(timeouts.Backoff(c.Cluster, timeouts.ClusterHealthy), func() (bool, error) {
 		body, berr := client.Discovery().RESTClient().Get().AbsPath("/healthz").Do(context.TODO()).Raw()
 		klog.Info("apiserver_url==>", client.Discovery().RESTClient().Get().AbsPath("/healthz"))
 		if berr != nil {
-			klog.Error("Failed to do cluster health check for cluster: %s,err:%s", c.Name, berr)
+			klog.Errorf("Failed to do cluster health check for cluster: %s,err:%s", c.Name, berr)
 			return false, nil
 		}
 		if !strings.EqualFold(string(body), "ok") {
@@ -213,6 +202,14 @@ func (p *Provider) EnsureKubeMisc(ctx context.Context, c *common.Cluster) error
 	if err != nil {
 		return err
 	}
+	_, err = kubemisc.ApplyAuditMisc(ctx, c.Client, c)
+	if err != nil {
+		return err
+	}
+	_, err = encryption.InitConfig(c)
+	if err != nil {
+		return err
+	}
 	return ApplyKubeMiscConfigmap(c.Client, c, c.ClusterCredential.KubeData)
 }
 
@@ -221,6 +218,33 @@ func (p *Provider) EnsureEtcd(ctx context.Context, c *common.Cluster) error {
 }
 
 func (p *Provider) EnsureKubeMaster(ctx context.Context, c *common.Cluster) error {
+	if _, ok := c.ClusterCredential.KubeData[constants.TokenFile]; ok || c.ClusterCredential.Token != nil {
+		// revoke the static admin token of the clusters created before the bootstrap token
+		delete(c.ClusterCredential.KubeData, constants.TokenFile)
+		c.ClusterCredential.Token = nil
+		err := ApplyKubeMiscConfigmap(c.Client, c, c.ClusterCredential.KubeData)
+		if err != nil {
+			return err
+		}
+		klog.Infof("cluster: %s static admin token revoked", c.Name)
+	}
+	auditChanged, err := kubemisc.ApplyAuditMisc(ctx, c.Client, c)
+	if err != nil {
+		return err
+	}
+	if auditChanged {
+		err = ApplyKubeMiscConfigmap(c.Client, c, c.ClusterCredential.KubeData)
+		if err != nil {
+			return err
+		}
+	}
+	if kubeadm.ApplyOIDCCA(c) {
+		err := ApplyCertsConfigmap(c.Client, c, c.ClusterCredential.CertsBinaryData)
+		if err != nil {
+			return err
+		}
+	}
+
 	r := &Reconciler{
 		Obj:      c,
 		Provider: p,
@@ -244,62 +268,97 @@ func (p *Provider) EnsureKubeMaster(ctx context.Context, c *common.Cluster) erro
 	return nil
 }
 
-func (p *Provider) EnsureExtKubeconfig(ctx context.Context, c *common.Cluster) error {
-	if c.ClusterCredential.ExtData == nil {
-		c.ClusterCredential.ExtData = make(map[string]string)
+// EnsureEncryption advances the encryption keys by one step once all the apiservers run with the current config,
+// the apiservers are restarted with the new config by EnsureKubeMaster.
+func (p *Provider) EnsureEncryption(ctx context.Context, c *common.Cluster) error {
+	if !encryption.Enabled(c) {
+		return nil
+	}
+
+	deploy := &appsv1.Deployment{}
+	err := c.Client.Get(ctx, types.NamespacedName{Namespace: c.Cluster.Namespace, Name: constants.KubeApiServer}, deploy)
+	if err != nil {
+		return errors.Wrapf(err, "get apiserver deployment")
+	}
+	if deploy.Spec.Template.Annotations[constants.EncryptionChecksum] != encryption.Checksum(c) ||
+		deploy.Status.ObservedGeneration < deploy.Generation ||
+		deploy.Status.UpdatedReplicas != deploy.Status.Replicas || deploy.Status.AvailableReplicas != deploy.Status.Replicas {
+		klog.Infof("cluster: %s wait the apiservers rolled out before the next encryption step", c.Name)
+		return nil
 	}
 
+	changed, err := encryption.Rotate(ctx, c, func(ctx context.Context) error {
+		clusterCtx, err := c.ClusterManager.Get(c.Name)
+		if err != nil {
+			return err
+		}
+		return encryption.RewriteSecrets(ctx, clusterCtx.Client)
+	})
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return nil
+	}
+	return ApplyKubeMiscConfigmap(c.Client, c, c.ClusterCredential.KubeData)
+}
+
+func (p *Provider) EnsureExtKubeconfig(ctx context.Context, c *common.Cluster) error {
 	apiserver := certs.BuildApiserverEndpoint(c.Cluster.Spec.PublicAlternativeNames[0], kubemisc.GetBindPort(c.Cluster))
 	klog.Infof("external apiserver url: %s", apiserver)
-	cfgMaps, err := certs.CreateApiserverKubeConfigFile(c.ClusterCredential.CAKey, c.ClusterCredential.CACert,
-		apiserver, c.Cluster.Name)
+	return certs.EnsureExternalKubeconfig(ctx, c, apiserver)
+}
+
+// EnsureBootstrapToken applies the bootstrap token and the bindings the kubelets join the cluster with.
+func (p *Provider) EnsureBootstrapToken(ctx context.Context, c *common.Cluster) error {
+	clusterCtx, err := c.ClusterManager.Get(c.Name)
+	if err != nil {
+		return c.SkipReconcile("EnsureBootstrapToken", err)
+	}
+	if c.ClusterCredential.BootstrapToken == nil {
+		return errors.New("bootstrap token is nil")
+	}
+
+	objs, err := bootstraptoken.BuildBootstrapObjs(*c.ClusterCredential.BootstrapToken)
 	if err != nil {
-		klog.Errorf("create kubeconfg err: %+v", err)
 		return err
 	}
-	klog.Infof("[%s/%s] start build kubeconfig ...", c.Cluster.Namespace, c.Cluster.Name)
-	for _, v := range cfgMaps {
-		by, err := certs.BuildKubeConfigByte(v)
+
+	logger := ctrl.Log.WithValues("cluster", c.Name)
+	for _, obj := range objs {
+		err = k8sutil.Reconcile(logger, clusterCtx.Client, obj, k8sutil.DesiredStatePresent)
 		if err != nil {
-			return err
+			return errors.Wrapf(err, "apply bootstrap token objs")
 		}
-		c.ClusterCredential.ExtData[pkiutil.ExternalAdminKubeConfigFileName] = string(by)
 	}
-
 	return nil
 }
 
 func (p *Provider) EnsureAddons(ctx context.Context, c *common.Cluster) error {
 	clusterCtx, err := c.ClusterManager.Get(c.Name)
 	if err != nil {
-		return nil
+		return c.SkipReconcile("EnsureAddons", err)
 	}
 	kubeproxyObjs, err := kubeproxy.BuildKubeproxyAddon(p.Cfg, c)
 	if err != nil {
 		return errors.Wrapf(err, "build kube-proxy err: %+v", err)
 	}
 
-	logger := ctrl.Log.WithValues("cluster", c.Name)
-	logger.Info("start apply kube-proxy")
-	for _, obj := range kubeproxyObjs {
-		err = k8sutil.Reconcile(logger, clusterCtx.Client, obj, k8sutil.DesiredStatePresent)
-		if err != nil {
-			return errors.Wrapf(err, "Reconcile  err: %v", err)
-		}
+	err = inventory.Apply(ctx, clusterCtx.Client, c.Name, inventory.KubeProxy, kubeproxyObjs, k8sutil.DesiredStatePresent)
+	if err != nil {
+		return err
 	}
 
-	logger.Info("start apply coredns")
 	corednsObjs, err := coredns.BuildCoreDNSAddon(p.Cfg, c)
 	if err != nil {
 		return errors.Wrapf(err, "build coredns err: %+v", err)
 	}
-	for _, obj := range corednsObjs {
-		err = k8sutil.Reconcile(logger, clusterCtx.Client, obj, k8sutil.DesiredStatePresent)
-		if err != nil {
-			return errors.Wrapf(err, "Reconcile  err: %v", err)
-		}
+	err = inventory.Apply(ctx, clusterCtx.Client, c.Name, inventory.CoreDNS, corednsObjs, k8sutil.DesiredStatePresent)
+	if err != nil {
+		return err
 	}
-	return nil
+
+	return podsecurity.ApplyPodSecurity(ctx, c)
 }
 
 func (p *Provider) EnsureCni(ctx context.Context, c *common.Cluster) error {
@@ -327,20 +386,16 @@ func (p *Provider) EnsureCni(ctx context.Context, c *common.Cluster) error {
 	case "flannel":
 		clusterCtx, err := c.ClusterManager.Get(c.Name)
 		if err != nil {
-			return nil
+			return c.SkipReconcile("EnsureCni", err)
 		}
 		objs, err := flannel.BuildFlannelAddon(p.Cfg, c)
 		if err != nil {
 			return errors.Wrapf(err, "build flannel err: %v", err)
 		}
 
-		logger := ctrl.Log.WithValues("cluster", c.Name, "component", "flannel")
-		logger.Info("start reconcile ...")
-		for _, obj := range objs {
-			err = k8sutil.Reconcile(logger, clusterCtx.Client, obj, k8sutil.DesiredStatePresent)
-			if err != nil {
-				return errors.Wrapf(err, "Reconcile  err: %v", err)
-			}
+		err = inventory.Apply(ctx, clusterCtx.Client, c.Name, inventory.Flannel, objs, k8sutil.DesiredStatePresent)
+		if err != nil {
+			return err
 		}
 	default:
 		return fmt.Errorf("unknown cni type: %s", cniType)
@@ -351,24 +406,72 @@ func (p *Provider) EnsureCni(ctx context.Context, c *common.Cluster) error {
 	//return nil
 }
 
-func (p *Provider) EnsureMetricsServer(ctx context.Context, c *common.Cluster) error {
+// EnsureAddonsResync applies kube-proxy, coredns and flannel again, the objects deleted or edited in the
+// cluster are reverted and recorded as drifted.
+func (p *Provider) EnsureAddonsResync(ctx context.Context, c *common.Cluster) error {
 	clusterCtx, err := c.ClusterManager.Get(c.Name)
 	if err != nil {
+		return errors.Wrapf(err, "get cluster: %s client", c.Name)
+	}
+
+	kubeproxyObjs, err := kubeproxy.BuildKubeproxyAddon(p.Cfg, c)
+	if err != nil {
+		return errors.Wrapf(err, "build kube-proxy err: %+v", err)
+	}
+	err = c.ResyncObjects(clusterCtx.Client, "kube-proxy", kubeproxyObjs)
+	if err != nil {
+		return err
+	}
+
+	corednsObjs, err := coredns.BuildCoreDNSAddon(p.Cfg, c)
+	if err != nil {
+		return errors.Wrapf(err, "build coredns err: %+v", err)
+	}
+	err = c.ResyncObjects(clusterCtx.Client, "coredns", corednsObjs)
+	if err != nil {
+		return err
+	}
+
+	if c.Cluster.Spec.Features.Hooks[devopsv1.HookCniInstall] != "flannel" {
 		return nil
 	}
+	flannelObjs, err := flannel.BuildFlannelAddon(p.Cfg, c)
+	if err != nil {
+		return errors.Wrapf(err, "build flannel err: %v", err)
+	}
+	return c.ResyncObjects(clusterCtx.Client, "flannel", flannelObjs)
+}
+
+func (p *Provider) EnsureMetricsServer(ctx context.Context, c *common.Cluster) error {
+	clusterCtx, err := c.ClusterManager.Get(c.Name)
+	if err != nil {
+		return c.SkipReconcile("EnsureMetricsServer", err)
+	}
 	objs, err := metricsserver.BuildMetricsServerAddon(c)
 	if err != nil {
 		return errors.Wrapf(err, "build metrics-server err: %v", err)
 	}
 
-	logger := ctrl.Log.WithValues("cluster", c.Name, "component", "metrics-server")
-	logger.Info("start reconcile ...")
-	for _, obj := range objs {
-		err = k8sutil.Reconcile(logger, clusterCtx.Client, obj, k8sutil.DesiredStateAbsent)
-		if err != nil {
-			return errors.Wrapf(err, "Reconcile  err: %v", err)
-		}
+	return inventory.Apply(ctx, clusterCtx.Client, c.Name, inventory.MetricsServer, objs, k8sutil.DesiredStateAbsent)
+}
+
+func (p *Provider) EnsureGatekeeper(ctx context.Context, c *common.Cluster) error {
+	if policy, ok := c.Cluster.Spec.Features.Hooks[devopsv1.HookPolicyInstall]; !ok || policy != gatekeeper.PolicyType {
+		return nil
 	}
 
-	return nil
+	return gatekeeper.ApplyGatekeeper(ctx, p.Cfg, c)
+}
+
+// EnsureAddonsGC deletes the objects of the addons disabled in the spec of the cluster.
+func (p *Provider) EnsureAddonsGC(ctx context.Context, c *common.Cluster) error {
+	return inventory.CollectDisabled(ctx, c)
+}
+
+func (p *Provider) EnsureMultus(ctx context.Context, c *common.Cluster) error {
+	if !c.Cluster.Spec.Features.Multus {
+		return nil
+	}
+
+	return multus.ApplyMultus(ctx, p.Cfg, c)
 }
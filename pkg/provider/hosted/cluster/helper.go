@@ -2,12 +2,17 @@ package cluster
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"path"
 	"sort"
 	"strings"
 
 	"github.com/gostship/kunkka/pkg/constants"
 	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/phases/encryption"
+	"github.com/gostship/kunkka/pkg/provider/phases/kubeadm"
 	"github.com/gostship/kunkka/pkg/util/k8sutil"
 	"github.com/pkg/errors"
 	appsv1 "k8s.io/api/apps/v1"
@@ -116,6 +121,62 @@ func ApplyKubeMiscConfigmap(cli client.Client, obj *common.Cluster, pathKubeMisc
 	return nil
 }
 
+// podAnnotations returns the checksums of the audit and encryption files, the apiserver only reads them on start.
+func podAnnotations(c *common.Cluster) map[string]string {
+	annotations := map[string]string{}
+	if c.Spec.Audit != nil {
+		h := sha256.New()
+		h.Write([]byte(c.ClusterCredential.KubeData[constants.AuditPolicyConfigFile]))
+		h.Write([]byte(c.ClusterCredential.KubeData[constants.AuditWebhookConfigFile]))
+		annotations[constants.AuditChecksum] = hex.EncodeToString(h.Sum(nil))
+	}
+	if sum := encryption.Checksum(c); sum != "" {
+		annotations[constants.EncryptionChecksum] = sum
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+	return annotations
+}
+
+// SortedFlags returns the args as the sorted flags of the command.
+func SortedFlags(args map[string]string) []string {
+	flags := make([]string, 0, len(args))
+	for k, v := range args {
+		flags = append(flags, fmt.Sprintf("--%s=%s", k, v))
+	}
+	sort.Strings(flags)
+	return flags
+}
+
+// WithExtraArgs overrides the flags of the command with the extra args, the overridden flags are removed
+// and the extra args are appended in order, so the command is unchanged without extra args.
+func WithExtraArgs(cmds []string, extraArgs map[string]string) []string {
+	if len(extraArgs) == 0 {
+		return cmds
+	}
+
+	result := make([]string, 0, len(cmds)+len(extraArgs))
+	for _, cmd := range cmds {
+		name := strings.SplitN(strings.TrimPrefix(cmd, "--"), "=", 2)[0]
+		if _, ok := extraArgs[name]; ok && strings.HasPrefix(cmd, "--") {
+			continue
+		}
+		result = append(result, cmd)
+	}
+
+	keys := make([]string, 0, len(extraArgs))
+	for k := range extraArgs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		result = append(result, fmt.Sprintf("--%s=%s", k, extraArgs[k]))
+	}
+
+	return result
+}
+
 func (r *Reconciler) apiServerDeployment() runtime.Object {
 	containers := []corev1.Container{}
 	vms := []corev1.VolumeMount{
@@ -167,6 +228,23 @@ func (r *Reconciler) apiServerDeployment() runtime.Object {
 			},
 		},
 	}
+	if r.Obj.Spec.Encryption != nil && r.Obj.Spec.Encryption.KMS != nil {
+		// the socket of the kms plugin running on the nodes of the meta cluster
+		socketDir := path.Dir(strings.TrimPrefix(r.Obj.Spec.Encryption.KMS.Endpoint, "unix://"))
+		vms = append(vms, corev1.VolumeMount{
+			Name:      constants.KubeApiServerKMS,
+			MountPath: socketDir,
+		})
+		volumes = append(volumes, corev1.Volume{
+			Name: constants.KubeApiServerKMS,
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{
+					Path: socketDir,
+					Type: &hostPathType,
+				},
+			},
+		})
+	}
 
 	cmds := []string{
 		"kube-apiserver",
@@ -188,19 +266,10 @@ func (r *Reconciler) apiServerDeployment() runtime.Object {
 		"--service-account-key-file=/etc/kubernetes/pki/sa.pub",
 		"--tls-cert-file=/etc/kubernetes/pki/apiserver.crt",
 		"--tls-private-key-file=/etc/kubernetes/pki/apiserver.key",
-		"--token-auth-file=/etc/kubernetes/known_tokens.csv",
 	}
 
 	cmds = append(cmds, fmt.Sprintf("--secure-port=%d", GetPodBindPort(r.Obj)))
 	cmds = append(cmds, fmt.Sprintf("--advertise-address=%s", "0.0.0.0"))
-	if r.Obj.Cluster.Spec.APIServerExtraArgs != nil {
-		extraArgs := []string{}
-		for k, v := range r.Obj.Cluster.Spec.APIServerExtraArgs {
-			extraArgs = append(extraArgs, fmt.Sprintf("--%s=%s", k, v))
-		}
-		sort.Strings(extraArgs)
-		cmds = append(cmds, extraArgs...)
-	}
 
 	svcCidr := "10.96.0.0/16"
 	if r.Obj.Cluster.Spec.ServiceCIDR != nil {
@@ -219,6 +288,10 @@ func (r *Reconciler) apiServerDeployment() runtime.Object {
 	} else {
 		cmds = append(cmds, fmt.Sprintf("--etcd-servers=%s", "http://etcd-0.etcd:2379,http://etcd-1.etcd:2379,http://etcd-2.etcd:2379"))
 	}
+	cmds = append(cmds, SortedFlags(kubeadm.GetOIDCArgs(r.Obj))...)
+	cmds = append(cmds, SortedFlags(kubeadm.GetAuditArgs(r.Obj))...)
+	cmds = append(cmds, SortedFlags(kubeadm.GetEncryptionArgs(r.Obj))...)
+	cmds = WithExtraArgs(cmds, r.Obj.Cluster.Spec.APIServerExtraArgs)
 
 	c := corev1.Container{
 		Name:            constants.KubeApiServer,
@@ -272,7 +345,8 @@ func (r *Reconciler) apiServerDeployment() runtime.Object {
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: constants.KubeApiServerLabels,
+					Labels:      constants.KubeApiServerLabels,
+					Annotations: podAnnotations(r.Obj),
 				},
 				Spec: corev1.PodSpec{
 					Containers:  containers,
@@ -375,21 +449,13 @@ func (r *Reconciler) controllerManagerDeployment() runtime.Object {
 		"--use-service-account-credentials=true",
 	}
 
-	if r.Obj.Cluster.Spec.ControllerManagerExtraArgs != nil {
-		extraArgs := []string{}
-		for k, v := range r.Obj.Cluster.Spec.ControllerManagerExtraArgs {
-			extraArgs = append(extraArgs, fmt.Sprintf("--%s=%s", k, v))
-		}
-		sort.Strings(extraArgs)
-		cmds = append(cmds, extraArgs...)
-	}
-
 	if r.Obj.Cluster.Status.NodeCIDRMaskSize > 0 {
 		cmds = append(cmds, "--allocate-node-cidrs=true")
 		cmds = append(cmds, fmt.Sprintf("--cluster-cidr=%s", r.Obj.Cluster.Spec.ClusterCIDR))
 		cmds = append(cmds, fmt.Sprintf("--cluster-name=%s", r.Obj.Cluster.Name))
 		cmds = append(cmds, fmt.Sprintf("--node-cidr-mask-size=%d", r.Obj.Cluster.Status.NodeCIDRMaskSize))
 	}
+	cmds = WithExtraArgs(cmds, r.Obj.Cluster.Spec.ControllerManagerExtraArgs)
 
 	healthPortName := "https-healthz"
 	c := corev1.Container{
@@ -496,6 +562,7 @@ func (r *Reconciler) schedulerDeployment() runtime.Object {
 		"--kubeconfig=/etc/kubernetes/scheduler.conf",
 		"--leader-elect=true",
 	}
+	cmds = WithExtraArgs(cmds, r.Obj.Cluster.Spec.SchedulerExtraArgs)
 
 	healthPortName := "https-healthz"
 	c := corev1.Container{
@@ -9,12 +9,10 @@ import (
 
 	clusterprovider "github.com/gostship/kunkka/pkg/provider/cluster"
 
-	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
-	"github.com/gostship/kunkka/pkg/constants"
 	"github.com/gostship/kunkka/pkg/controllers/common"
 	"github.com/gostship/kunkka/pkg/provider/baremetal/validation"
 	"github.com/gostship/kunkka/pkg/provider/config"
-	"github.com/gostship/kunkka/pkg/util/pointer"
+	"github.com/gostship/kunkka/pkg/timeouts"
 	"k8s.io/klog"
 )
 
@@ -56,14 +54,28 @@ func NewProvider(mgr *clusterprovider.CpManager, cfg *config.Config) (*Provider,
 			p.EnsureExtKubeconfig,
 			p.EnsurePostInstallHook,
 			p.EnsureClusterReady, //健康检查cluster,如果未ready不能进入OnUpdate
+			p.EnsureBootstrapToken,
 		},
 		UpdateHandlers: []clusterprovider.Handler{
 			p.EnsureExtKubeconfig,
+			p.EnsureEncryption,
 			p.EnsureKubeMaster,
+			p.EnsureBootstrapToken,
 			p.EnsureAddons,
 			p.EnsureCni,
 			p.EnsureMetricsServer,
+			p.EnsureGatekeeper,
+			p.EnsureMultus,
 		},
+		ResyncHandlers: []clusterprovider.Handler{
+			p.EnsureAddonsResync,
+			p.EnsureAddonsGC,
+		},
+	}
+
+	err := timeouts.SetProvider(p.Name(), cfg.Waits)
+	if err != nil {
+		return nil, err
 	}
 
 	return p, nil
@@ -80,33 +92,10 @@ func (p *Provider) Validate(cluster *common.Cluster) field.ErrorList {
 }
 
 func (p *Provider) PreCreate(cluster *common.Cluster) error {
-	if cluster.Spec.Version == "" {
-		cluster.Spec.Version = constants.K8sVersions[0]
-	}
-	if cluster.Spec.ClusterCIDR == "" {
-		cluster.Spec.ClusterCIDR = "10.244.0.0/16"
-	}
-	if cluster.Spec.NetworkDevice == "" {
-		cluster.Spec.NetworkDevice = "eth0"
-	}
-
-	if cluster.Spec.Features.IPVS == nil {
-		cluster.Spec.Features.IPVS = pointer.ToBool(true)
-	}
-
-	if cluster.Spec.Properties.MaxClusterServiceNum == nil && cluster.Spec.ServiceCIDR == nil {
-		cluster.Spec.Properties.MaxClusterServiceNum = pointer.ToInt32(256)
-	}
-	if cluster.Spec.Properties.MaxNodePodNum == nil {
-		cluster.Spec.Properties.MaxNodePodNum = pointer.ToInt32(256)
-	}
+	cluster.Default()
 	if cluster.Spec.Features.SkipConditions == nil {
 		cluster.Spec.Features.SkipConditions = p.Cfg.Feature.SkipConditions
 	}
 
-	if cluster.Spec.Etcd == nil {
-		cluster.Spec.Etcd = &devopsv1.Etcd{Local: &devopsv1.LocalEtcd{}}
-	}
-
 	return nil
 }
@@ -43,21 +43,28 @@ func NewProvider(mgr *machineprovider.MpManager, cfg *config.Config) (*Provider,
 
 			p.EnsureEth,
 			p.EnsureSystem,
+			p.EnsureRegistryMirrors,
 			p.EnsureK8sComponent,
 			p.EnsurePreflight, // wait basic setting done
 
 			p.EnsureJoinNode,
-			p.EnsureKubeconfig,
 			p.EnsureMarkNode,
 			p.EnsureCni,
 			p.EnsureNodeReady,
+			p.EnsureArtifactsCleanup,
 
 			p.EnsurePostInstallHook,
 		},
+		DeleteHandlers: []machineprovider.Handler{
+			p.EnsureClean,
+		},
 		UpdateHandlers: []machineprovider.Handler{
 			p.EnsureCni,
 			p.EnsurePostInstallHook,
 			p.EnsureRegistryHosts,
+			p.EnsureRegistryMirrors,
+			p.EnsureOSDrift,
+			p.EnsureArtifactsCleanup,
 		},
 	}
 
@@ -0,0 +1,71 @@
+package infra
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/util/ssh"
+	"github.com/pkg/errors"
+	cryptossh "golang.org/x/crypto/ssh"
+)
+
+// CloudConfig returns the cloud-config user data setting the hostname and the user of the machine with its
+// password or the public key of its private key.
+func CloudConfig(hostname string, m *devopsv1.ClusterMachine) ([]byte, error) {
+	cred, err := m.SSHCredential()
+	if err != nil {
+		return nil, err
+	}
+	username := m.Username
+	if username == "" {
+		username = devopsv1.DefaultSSHUser
+	}
+	var authorizedKeys []string
+	if len(cred.PrivateKey) > 0 {
+		signer, err := ssh.MakePrivateKeySigner(cred.PrivateKey, cred.PassPhrase)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse private key of machine: %s", hostname)
+		}
+		authorizedKeys = append(authorizedKeys, strings.TrimSpace(string(cryptossh.MarshalAuthorizedKey(signer.PublicKey()))))
+	}
+
+	cfg := map[string]interface{}{
+		"hostname":          hostname,
+		"preserve_hostname": false,
+	}
+	if username == "root" {
+		cfg["disable_root"] = false
+		if len(authorizedKeys) > 0 {
+			cfg["write_files"] = []map[string]interface{}{{
+				"path":        "/root/.ssh/authorized_keys",
+				"permissions": "0600",
+				"content":     strings.Join(authorizedKeys, "\n") + "\n",
+			}}
+		}
+	} else {
+		user := map[string]interface{}{
+			"name":  username,
+			"sudo":  "ALL=(ALL) NOPASSWD:ALL",
+			"shell": "/bin/bash",
+		}
+		if len(authorizedKeys) > 0 {
+			user["ssh_authorized_keys"] = authorizedKeys
+		}
+		cfg["users"] = []interface{}{"default", user}
+	}
+	if cred.Password != "" {
+		cfg["ssh_pwauth"] = true
+		cfg["chpasswd"] = map[string]interface{}{
+			"expire": false,
+			"list":   fmt.Sprintf("%s:%s", username, cred.Password),
+		}
+	}
+
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte("#cloud-config\n"), data...), nil
+}
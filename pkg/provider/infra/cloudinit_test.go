@@ -0,0 +1,61 @@
+package infra
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+)
+
+func newPrivateKey(t *testing.T) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+func TestCloudConfig(t *testing.T) {
+	key := newPrivateKey(t)
+	tests := []struct {
+		name    string
+		machine *devopsv1.ClusterMachine
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "user with key",
+			machine: &devopsv1.ClusterMachine{Username: "ubuntu", PrivateKey: key},
+			want:    []string{"#cloud-config\n", "name: ubuntu", "ssh-rsa ", "NOPASSWD:ALL"},
+			notWant: []string{"chpasswd", "disable_root"},
+		},
+		{
+			name:    "root with password",
+			machine: &devopsv1.ClusterMachine{Username: "root", Password: "p'w"},
+			want:    []string{"disable_root: false", "list: root:p'w", "ssh_pwauth: true"},
+			notWant: []string{"users", "authorized_keys"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := CloudConfig("dke-master-0", tt.machine)
+			if err != nil {
+				t.Fatalf("CloudConfig() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !bytes.Contains(data, []byte(want)) {
+					t.Errorf("CloudConfig() = %s, want %q", data, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if bytes.Contains(data, []byte(notWant)) {
+					t.Errorf("CloudConfig() = %s, want no %q", data, notWant)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/logs"
+	baremetalcluster "github.com/gostship/kunkka/pkg/provider/baremetal/cluster"
+	clusterprovider "github.com/gostship/kunkka/pkg/provider/cluster"
+	"github.com/gostship/kunkka/pkg/provider/config"
+	"github.com/gostship/kunkka/pkg/timeouts"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ClusterProvider provisions the instances of the masters of spec.machines without ip, then runs the handlers
+// of the bare metal clusters on them.
+type ClusterProvider struct {
+	*clusterprovider.DelegateProvider
+	Baremetal *baremetalcluster.Provider
+	Driver    Driver
+}
+
+var _ clusterprovider.Provider = &ClusterProvider{}
+
+// NewClusterProvider returns the cluster provider of the name driven by the driver.
+func NewClusterProvider(mgr *clusterprovider.CpManager, cfg *config.Config, name string, driver Driver) (*ClusterProvider, error) {
+	bm, err := baremetalcluster.NewProvider(mgr, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &ClusterProvider{
+		Baremetal: bm,
+		Driver:    driver,
+	}
+	// the instances of the masters added by the update are provisioned before EnsureMasterNode joins them, then
+	// they are balanced by the endpoint
+	// the pre hook of the infrastructure, the first of the bare metal handlers, runs before the instances are
+	// provisioned so the masters given an ip by its output get no instance
+	create := []clusterprovider.Handler{bm.EnsureInfraPreHook, p.EnsureInstances}
+	update := append([]clusterprovider.Handler{p.EnsureInstances}, bm.UpdateHandlers...)
+	del := []clusterprovider.Handler{p.EnsureInstancesDeleted}
+	if _, ok := driver.(EndpointDriver); ok {
+		create = append(create, p.EnsureEndpoint)
+		update = append(update, p.EnsureEndpoint)
+		del = append(del, p.EnsureEndpointDeleted)
+	}
+	p.DelegateProvider = &clusterprovider.DelegateProvider{
+		ProviderName:   name,
+		CreateHandlers: append(create, bm.CreateHandlers[1:]...),
+		UpdateHandlers: update,
+		ResyncHandlers: bm.ResyncHandlers,
+		DeleteHandlers: del,
+	}
+
+	err = timeouts.SetProvider(name, cfg.Waits)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *ClusterProvider) Validate(cluster *common.Cluster) field.ErrorList {
+	allErrs := p.Baremetal.Validate(cluster)
+	return append(allErrs, ValidateInfrastructure(cluster.Spec.Infrastructure, p.Driver, field.NewPath("spec", "infrastructure"))...)
+}
+
+func (p *ClusterProvider) PreCreate(cluster *common.Cluster) error {
+	return p.Baremetal.PreCreate(cluster)
+}
+
+// EnsureInstances provisions the instances of the masters without ip and waits them to boot, the ips are
+// written to spec.machines, the ones got are kept even if the others time out.
+func (p *ClusterProvider) EnsureInstances(ctx context.Context, c *common.Cluster) error {
+	pending := make(map[int]*InstanceSpec)
+	for i, m := range c.Spec.Machines {
+		if m != nil && m.IP == "" {
+			pending[i] = &InstanceSpec{Name: MasterName(c.Name, i), Role: RoleMaster, Machine: m}
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	ips := make(map[int]string)
+	err := timeouts.Poll(c.Cluster, timeouts.InstanceReady, func() (bool, error) {
+		for i, spec := range pending {
+			if ips[i] != "" {
+				continue
+			}
+			instance, err := p.Driver.EnsureInstance(ctx, c, spec)
+			if err != nil {
+				return false, err
+			}
+			if instance.IP != "" {
+				logs.FromContext(ctx).Info("instance is ready", "instance", instance.Name, "ip", instance.IP)
+				ips[i] = instance.IP
+			}
+		}
+		return len(ips) == len(pending), nil
+	})
+	if len(ips) > 0 {
+		updateErr := updateCluster(ctx, c, func(latest *devopsv1.Cluster) error {
+			for i, ip := range ips {
+				if i >= len(latest.Spec.Machines) || latest.Spec.Machines[i] == nil {
+					return fmt.Errorf("master: %d of cluster: %s is removed", i, c.Name)
+				}
+				latest.Spec.Machines[i].IP = ip
+			}
+			return nil
+		})
+		if updateErr != nil {
+			return updateErr
+		}
+	}
+	return err
+}
+
+// EnsureEndpoint provisions the endpoint balancing the masters and waits it to be ready, it's set as the third
+// party ha of the cluster so the certificates and the kubeconfigs are of it.
+func (p *ClusterProvider) EnsureEndpoint(ctx context.Context, c *common.Cluster) error {
+	if ha := c.Spec.Features.HA; ha != nil && ha.DKEHA != nil {
+		return fmt.Errorf("features.ha.dke of cluster: %s conflicts with the endpoint of the infrastructure", c.Name)
+	}
+
+	var ep *Endpoint
+	err := timeouts.Poll(c.Cluster, timeouts.InstanceReady, func() (bool, error) {
+		var err error
+		ep, err = p.Driver.(EndpointDriver).EnsureEndpoint(ctx, c)
+		if err != nil {
+			return false, err
+		}
+		return ep == nil || ep.Host != "", nil
+	})
+	if err != nil || ep == nil {
+		return err
+	}
+	if ha := c.Spec.Features.HA; ha != nil && ha.ThirdPartyHA != nil &&
+		ha.ThirdPartyHA.VIP == ep.Host && ha.ThirdPartyHA.VPort == ep.Port {
+		return nil
+	}
+
+	logs.FromContext(ctx).Info("endpoint is ready", "host", ep.Host, "port", ep.Port)
+	return updateCluster(ctx, c, func(latest *devopsv1.Cluster) error {
+		latest.Spec.Features.HA = &devopsv1.HA{
+			ThirdPartyHA: &devopsv1.ThirdPartyHA{VIP: ep.Host, VPort: ep.Port},
+		}
+		return nil
+	})
+}
+
+// updateCluster updates the latest cluster with the mutation and copies the spec back to the cluster.
+func updateCluster(ctx context.Context, c *common.Cluster, mutate func(latest *devopsv1.Cluster) error) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &devopsv1.Cluster{}
+		if err := c.Client.Get(ctx, client.ObjectKey{Namespace: c.Namespace, Name: c.Name}, latest); err != nil {
+			return err
+		}
+		if err := mutate(latest); err != nil {
+			return err
+		}
+		if err := c.Client.Update(ctx, latest); err != nil {
+			return err
+		}
+		c.Cluster.ResourceVersion = latest.ResourceVersion
+		return mutate(c.Cluster)
+	})
+}
+
+// EnsureInstancesDeleted deletes the instances of the masters and of the Machines of the cluster, the ones of
+// the machines given an ip by hand are not found.
+func (p *ClusterProvider) EnsureInstancesDeleted(ctx context.Context, c *common.Cluster) error {
+	var names []string
+	for i := range c.Spec.Machines {
+		names = append(names, MasterName(c.Name, i))
+	}
+	ms := &devopsv1.MachineList{}
+	err := c.Client.List(ctx, ms, client.InNamespace(c.Namespace))
+	if err != nil {
+		return err
+	}
+	for i := range ms.Items {
+		if ms.Items[i].Spec.ClusterName == c.Name {
+			names = append(names, WorkerName(c.Name, ms.Items[i].Name))
+		}
+	}
+
+	var errs []error
+	for _, name := range names {
+		err := p.Driver.DeleteInstance(ctx, c, name)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// EnsureEndpointDeleted deletes the endpoint after the instances it balances.
+func (p *ClusterProvider) EnsureEndpointDeleted(ctx context.Context, c *common.Cluster) error {
+	return p.Driver.(EndpointDriver).DeleteEndpoint(ctx, c)
+}
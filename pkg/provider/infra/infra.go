@@ -0,0 +1,116 @@
+// Package infra provisions the instances of the machines of the clusters on an infrastructure, e.g. the VMs of a
+// vCenter, before they are installed like bare metal machines. The providers of an infrastructure are the bare
+// metal providers with the handlers of the instances prepended to the create handlers and appended to the delete
+// handlers, the infrastructure itself is driven by a Driver.
+package infra
+
+import (
+	"context"
+	"fmt"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Role is the role of the machine of an instance.
+type Role string
+
+const (
+	RoleMaster Role = "master"
+	RoleWorker Role = "worker"
+)
+
+// InstanceSpec is the instance of a master of spec.machines or of a Machine.
+type InstanceSpec struct {
+	// Name is unique in the infrastructure, see MasterName and WorkerName
+	Name    string
+	Role    Role
+	Machine *devopsv1.ClusterMachine
+}
+
+// Instance is an instance provisioned on the infrastructure.
+type Instance struct {
+	// ID is the id of the instance in the infrastructure, e.g. the id of the VM of the vCenter
+	ID   string
+	Name string
+	// IP is the address the instance is installed at over ssh, it's empty until the instance booted
+	IP string
+}
+
+// Driver drives the API of an infrastructure, the instances are looked up by name so the handlers are
+// idempotent.
+type Driver interface {
+	// Validate validates the section of the infrastructure of the driver.
+	Validate(infra *devopsv1.Infrastructure, fldPath *field.Path) field.ErrorList
+	// EnsureInstance creates the instance if it doesn't exist and returns it.
+	EnsureInstance(ctx context.Context, c *common.Cluster, spec *InstanceSpec) (*Instance, error)
+	// DeleteInstance deletes the instance of the name, the instance not found is ignored.
+	DeleteInstance(ctx context.Context, c *common.Cluster, name string) error
+}
+
+// Endpoint is the address balancing the apiservers of the masters.
+type Endpoint struct {
+	// Host is the ip or the dns name of the endpoint, it's empty until the endpoint is provisioned
+	Host string
+	Port int32
+}
+
+// EndpointDriver is the Driver which also provisions the endpoint of the apiservers, e.g. a load balancer. The
+// endpoint is set as the third party ha of the cluster.
+type EndpointDriver interface {
+	Driver
+	// EnsureEndpoint creates the endpoint if it doesn't exist and balances the masters with ip, it returns nil if
+	// the infrastructure of the cluster has no endpoint.
+	EnsureEndpoint(ctx context.Context, c *common.Cluster) (*Endpoint, error)
+	// DeleteEndpoint deletes the endpoint, the endpoint not found is ignored.
+	DeleteEndpoint(ctx context.Context, c *common.Cluster) error
+}
+
+// MasterName returns the name of the instance of the i-th master of spec.machines.
+func MasterName(cluster string, i int) string {
+	return fmt.Sprintf("%s-master-%d", cluster, i)
+}
+
+// WorkerName returns the name of the instance of the Machine.
+func WorkerName(cluster, machine string) string {
+	return fmt.Sprintf("%s-%s", cluster, machine)
+}
+
+// Secret returns the Secret with the credential of the infrastructure API of the cluster.
+func Secret(ctx context.Context, c *common.Cluster) (*corev1.Secret, error) {
+	if c.Spec.Infrastructure == nil {
+		return nil, fmt.Errorf("cluster: %s has no infrastructure", c.Name)
+	}
+	s := &corev1.Secret{}
+	err := c.Client.Get(ctx, types.NamespacedName{Namespace: c.Namespace, Name: c.Spec.Infrastructure.SecretName}, s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get infrastructure secret: %s", c.Spec.Infrastructure.SecretName)
+	}
+	return s, nil
+}
+
+// SecretValue returns the value of the key of the Secret, it's an error if it's empty.
+func SecretValue(s *corev1.Secret, key string) (string, error) {
+	v := string(s.Data[key])
+	if v == "" {
+		return "", fmt.Errorf("infrastructure secret: %s has no %s", s.Name, key)
+	}
+	return v, nil
+}
+
+// ValidateInfrastructure validates the infrastructure of the cluster of the driver.
+func ValidateInfrastructure(infra *devopsv1.Infrastructure, driver Driver, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if infra == nil {
+		return append(allErrs, field.Required(fldPath, "must be set for the provider of an infrastructure"))
+	}
+	for _, msg := range k8svalidation.IsDNS1123Subdomain(infra.SecretName) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("secretName"), infra.SecretName, msg))
+	}
+	return append(allErrs, driver.Validate(infra, fldPath)...)
+}
@@ -0,0 +1,140 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeDriver boots the instances at once, the ip of each is given by its order.
+type fakeDriver struct {
+	instances map[string]string
+	deleted   []string
+}
+
+func (d *fakeDriver) Validate(infra *devopsv1.Infrastructure, fldPath *field.Path) field.ErrorList {
+	return nil
+}
+
+func (d *fakeDriver) EnsureInstance(ctx context.Context, c *common.Cluster, spec *InstanceSpec) (*Instance, error) {
+	if _, ok := d.instances[spec.Name]; !ok {
+		d.instances[spec.Name] = fmt.Sprintf("10.0.0.%d", len(d.instances)+1)
+	}
+	return &Instance{ID: spec.Name, Name: spec.Name, IP: d.instances[spec.Name]}, nil
+}
+
+func (d *fakeDriver) DeleteInstance(ctx context.Context, c *common.Cluster, name string) error {
+	d.deleted = append(d.deleted, name)
+	return nil
+}
+
+func TestEnsureInstances(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	devopsv1.AddToScheme(scheme)
+
+	cluster := &devopsv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "dke", Name: "dke"},
+		Spec: devopsv1.ClusterSpec{
+			Machines: []*devopsv1.ClusterMachine{{IP: "192.168.1.1"}, {}},
+		},
+	}
+	machine := &devopsv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "dke", Name: "node1"},
+		Spec:       devopsv1.MachineSpec{ClusterName: "dke", Machine: &devopsv1.ClusterMachine{}},
+	}
+	cli := fake.NewFakeClientWithScheme(scheme, cluster.DeepCopy(), machine.DeepCopy())
+	c := &common.Cluster{Cluster: cluster, Client: cli}
+	d := &fakeDriver{instances: make(map[string]string)}
+	ctx := context.Background()
+
+	cp := &ClusterProvider{Driver: d}
+	if err := cp.EnsureInstances(ctx, c); err != nil {
+		t.Fatalf("EnsureInstances() error = %v", err)
+	}
+	latest := &devopsv1.Cluster{}
+	cli.Get(ctx, types.NamespacedName{Namespace: "dke", Name: "dke"}, latest)
+	for _, cs := range []*devopsv1.Cluster{cluster, latest} {
+		if cs.Spec.Machines[0].IP != "192.168.1.1" || cs.Spec.Machines[1].IP != "10.0.0.1" {
+			t.Errorf("machines = %v, %v, want the ip of the instance of the master 1", cs.Spec.Machines[0], cs.Spec.Machines[1])
+		}
+	}
+	if _, ok := d.instances[MasterName("dke", 0)]; ok {
+		t.Errorf("instance of the master with ip is provisioned")
+	}
+
+	mp := &MachineProvider{Driver: d}
+	if err := mp.EnsureInstance(ctx, machine, c); err != nil {
+		t.Fatalf("EnsureInstance() error = %v", err)
+	}
+	latestMachine := &devopsv1.Machine{}
+	cli.Get(ctx, types.NamespacedName{Namespace: "dke", Name: "node1"}, latestMachine)
+	if machine.Spec.Machine.IP != "10.0.0.2" || latestMachine.Spec.Machine.IP != "10.0.0.2" {
+		t.Errorf("machine ip = %s, %s, want 10.0.0.2", machine.Spec.Machine.IP, latestMachine.Spec.Machine.IP)
+	}
+
+	if err := cp.EnsureInstancesDeleted(ctx, c); err != nil {
+		t.Fatalf("EnsureInstancesDeleted() error = %v", err)
+	}
+	sort.Strings(d.deleted)
+	want := []string{"dke-master-0", "dke-master-1", "dke-node1"}
+	if fmt.Sprint(d.deleted) != fmt.Sprint(want) {
+		t.Errorf("deleted = %v, want %v", d.deleted, want)
+	}
+}
+
+// fakeEndpointDriver balances the masters at a fixed vip.
+type fakeEndpointDriver struct {
+	fakeDriver
+}
+
+func (d *fakeEndpointDriver) EnsureEndpoint(ctx context.Context, c *common.Cluster) (*Endpoint, error) {
+	return &Endpoint{Host: "10.0.0.100", Port: 6443}, nil
+}
+
+func (d *fakeEndpointDriver) DeleteEndpoint(ctx context.Context, c *common.Cluster) error {
+	return nil
+}
+
+func TestEnsureEndpoint(t *testing.T) {
+	scheme := runtime.NewScheme()
+	devopsv1.AddToScheme(scheme)
+
+	cluster := &devopsv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "dke", Name: "dke"},
+		Spec: devopsv1.ClusterSpec{
+			Machines: []*devopsv1.ClusterMachine{{IP: "10.0.0.1"}},
+		},
+	}
+	cli := fake.NewFakeClientWithScheme(scheme, cluster.DeepCopy())
+	c := &common.Cluster{Cluster: cluster, Client: cli}
+	ctx := context.Background()
+
+	cp := &ClusterProvider{Driver: &fakeEndpointDriver{}}
+	if err := cp.EnsureEndpoint(ctx, c); err != nil {
+		t.Fatalf("EnsureEndpoint() error = %v", err)
+	}
+	latest := &devopsv1.Cluster{}
+	cli.Get(ctx, types.NamespacedName{Namespace: "dke", Name: "dke"}, latest)
+	for _, cs := range []*devopsv1.Cluster{cluster, latest} {
+		ha := cs.Spec.Features.HA
+		if ha == nil || ha.ThirdPartyHA == nil || ha.ThirdPartyHA.VIP != "10.0.0.100" || ha.ThirdPartyHA.VPort != 6443 {
+			t.Errorf("ha = %+v, want the third party ha of the endpoint", ha)
+		}
+	}
+
+	cluster.Spec.Features.HA = &devopsv1.HA{DKEHA: &devopsv1.DKEHA{VIP: "10.0.0.200"}}
+	if err := cp.EnsureEndpoint(ctx, c); err == nil {
+		t.Errorf("EnsureEndpoint() of the dke ha error = nil, want the conflict")
+	}
+}
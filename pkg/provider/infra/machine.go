@@ -0,0 +1,97 @@
+package infra
+
+import (
+	"context"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/logs"
+	baremetalmachine "github.com/gostship/kunkka/pkg/provider/baremetal/machine"
+	"github.com/gostship/kunkka/pkg/provider/config"
+	machineprovider "github.com/gostship/kunkka/pkg/provider/machine"
+	"github.com/gostship/kunkka/pkg/timeouts"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// MachineProvider provisions the instance of the Machine without ip, then runs the handlers of the bare metal
+// machines on it.
+type MachineProvider struct {
+	*machineprovider.DelegateProvider
+	Baremetal *baremetalmachine.Provider
+	Driver    Driver
+}
+
+var _ machineprovider.Provider = &MachineProvider{}
+
+// NewMachineProvider returns the machine provider of the name driven by the driver.
+func NewMachineProvider(mgr *machineprovider.MpManager, cfg *config.Config, name string, driver Driver) (*MachineProvider, error) {
+	bm, err := baremetalmachine.NewProvider(mgr, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &MachineProvider{
+		Baremetal: bm,
+		Driver:    driver,
+	}
+	// the node is cleaned over ssh by the machine controller before the delete handlers
+	p.DelegateProvider = &machineprovider.DelegateProvider{
+		ProviderName:   name,
+		CreateHandlers: append([]machineprovider.Handler{p.EnsureInstance}, bm.CreateHandlers...),
+		UpdateHandlers: bm.UpdateHandlers,
+		DeleteHandlers: []machineprovider.Handler{p.EnsureInstanceDeleted},
+	}
+	return p, nil
+}
+
+func (p *MachineProvider) Validate(machine *devopsv1.Machine) field.ErrorList {
+	return p.Baremetal.Validate(machine)
+}
+
+// EnsureInstance provisions the instance of the machine without ip and waits it to boot, the ip is written to
+// the spec of the Machine.
+func (p *MachineProvider) EnsureInstance(ctx context.Context, machine *devopsv1.Machine, c *common.Cluster) error {
+	if machine.Spec.Machine.IP != "" {
+		return nil
+	}
+
+	spec := &InstanceSpec{
+		Name:    WorkerName(c.Name, machine.Name),
+		Role:    RoleWorker,
+		Machine: machine.Spec.Machine,
+	}
+	var ip string
+	err := timeouts.Poll(c.Cluster, timeouts.InstanceReady, func() (bool, error) {
+		instance, err := p.Driver.EnsureInstance(ctx, c, spec)
+		if err != nil {
+			return false, err
+		}
+		ip = instance.IP
+		return ip != "", nil
+	})
+	if err != nil {
+		return err
+	}
+	logs.FromContext(ctx).Info("instance is ready", "instance", spec.Name, "ip", ip)
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &devopsv1.Machine{}
+		if err := c.Client.Get(ctx, client.ObjectKey{Namespace: machine.Namespace, Name: machine.Name}, latest); err != nil {
+			return err
+		}
+		latest.Spec.Machine.IP = ip
+		if err := c.Client.Update(ctx, latest); err != nil {
+			return err
+		}
+		machine.ResourceVersion = latest.ResourceVersion
+		machine.Spec.Machine.IP = ip
+		return nil
+	})
+}
+
+// EnsureInstanceDeleted deletes the instance of the machine, the machine given an ip by hand has none.
+func (p *MachineProvider) EnsureInstanceDeleted(ctx context.Context, machine *devopsv1.Machine, c *common.Cluster) error {
+	return p.Driver.DeleteInstance(ctx, c, WorkerName(c.Name, machine.Name))
+}
@@ -0,0 +1,239 @@
+package libvirt
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"path"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/credential"
+	"github.com/gostship/kunkka/pkg/provider/infra"
+	"github.com/gostship/kunkka/pkg/util/ssh"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	defaultNetwork     = "default"
+	defaultStoragePath = "/var/lib/libvirt/images"
+	defaultNumCPUs     = 2
+	defaultMemoryMiB   = 4096
+	defaultDiskGiB     = 40
+)
+
+// Driver creates the instances as the VMs of libvirt on the hypervisors over ssh, the VMs are found by name
+// on all the hypervisors and owned by the cluster of their description.
+type Driver struct {
+	// dial returns the ssh client of the hypervisor, it's replaced by the tests.
+	dial func(m *devopsv1.ClusterMachine) (ssh.Interface, error)
+}
+
+var _ infra.Driver = &Driver{}
+
+func NewDriver() *Driver {
+	return &Driver{
+		dial: func(m *devopsv1.ClusterMachine) (ssh.Interface, error) {
+			return m.SSH()
+		},
+	}
+}
+
+func (d *Driver) Validate(in *devopsv1.Infrastructure, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	lvPath := fldPath.Child("libvirt")
+	lv := in.Libvirt
+	if lv == nil {
+		return append(allErrs, field.Required(lvPath, "must be set for the libvirt clusters"))
+	}
+	if len(lv.Hosts) == 0 {
+		allErrs = append(allErrs, field.Required(lvPath.Child("hosts"), ""))
+	}
+	ips := make(map[string]bool)
+	for i, h := range lv.Hosts {
+		hPath := lvPath.Child("hosts").Index(i)
+		if h.IP == "" {
+			allErrs = append(allErrs, field.Required(hPath.Child("ip"), ""))
+		}
+		if ips[h.IP] {
+			allErrs = append(allErrs, field.Duplicate(hPath.Child("ip"), h.IP))
+		}
+		ips[h.IP] = true
+		if h.Username == "" {
+			allErrs = append(allErrs, field.Required(hPath.Child("username"), ""))
+		}
+	}
+	if !path.IsAbs(lv.Image) {
+		allErrs = append(allErrs, field.Invalid(lvPath.Child("image"), lv.Image, "must be the absolute path of the image on the hosts"))
+	}
+	if lv.StoragePath != "" && !path.IsAbs(lv.StoragePath) {
+		allErrs = append(allErrs, field.Invalid(lvPath.Child("storagePath"), lv.StoragePath, "must be an absolute path"))
+	}
+	if lv.NumCPUs < 0 {
+		allErrs = append(allErrs, field.Invalid(lvPath.Child("numCPUs"), lv.NumCPUs, "must be greater than or equal to 0"))
+	}
+	if lv.MemoryMiB < 0 {
+		allErrs = append(allErrs, field.Invalid(lvPath.Child("memoryMiB"), lv.MemoryMiB, "must be greater than or equal to 0"))
+	}
+	if lv.DiskGiB < 0 {
+		allErrs = append(allErrs, field.Invalid(lvPath.Child("diskGiB"), lv.DiskGiB, "must be greater than or equal to 0"))
+	}
+	return allErrs
+}
+
+func (d *Driver) EnsureInstance(ctx context.Context, c *common.Cluster, spec *infra.InstanceSpec) (*infra.Instance, error) {
+	hosts, err := d.hosts(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	h, dom, err := findDomain(hosts, spec.Name)
+	if err != nil {
+		return nil, err
+	}
+	if dom == nil {
+		err = d.createDomain(ctx, c, hosts[hostIndex(spec.Name, len(hosts))], spec)
+		if err != nil {
+			return nil, err
+		}
+		return &infra.Instance{ID: spec.Name, Name: spec.Name}, nil
+	}
+	if dom.Owner != owner(c) {
+		return nil, fmt.Errorf("domain: %s on host: %s is not owned by cluster: %s", dom.Name, h.HostIP(), c.Name)
+	}
+
+	instance := &infra.Instance{ID: dom.Name, Name: spec.Name}
+	if dom.State == "running" {
+		instance.IP, err = h.domainIP(dom.Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return instance, nil
+}
+
+func (d *Driver) DeleteInstance(ctx context.Context, c *common.Cluster, name string) error {
+	hosts, err := d.hosts(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	h, dom, err := findDomain(hosts, name)
+	if err != nil || dom == nil {
+		return err
+	}
+	if dom.Owner != owner(c) {
+		return fmt.Errorf("domain: %s on host: %s is not owned by cluster: %s", dom.Name, h.HostIP(), c.Name)
+	}
+	return h.deleteDomain(&domainSpec{Name: name, Dir: storagePath(c.Spec.Infrastructure.Libvirt)})
+}
+
+// createDomain creates the domain of the instance on the host, the user of the machine logs in with its
+// credential once cloud-init ran.
+func (d *Driver) createDomain(ctx context.Context, c *common.Cluster, h *hypervisor, spec *infra.InstanceSpec) error {
+	lv := c.Spec.Infrastructure.Libvirt
+	err := credential.LoadSSH(ctx, c.Client, c.Namespace, spec.Machine)
+	if err != nil {
+		return err
+	}
+	userData, err := infra.CloudConfig(spec.Name, spec.Machine)
+	if err != nil {
+		return err
+	}
+
+	s := &domainSpec{
+		Name:      spec.Name,
+		Owner:     owner(c),
+		Image:     lv.Image,
+		Dir:       storagePath(lv),
+		Network:   lv.Network,
+		NumCPUs:   lv.NumCPUs,
+		MemoryMiB: lv.MemoryMiB,
+		DiskGiB:   lv.DiskGiB,
+		UserData:  userData,
+	}
+	if s.Network == "" {
+		s.Network = defaultNetwork
+	}
+	if s.NumCPUs == 0 {
+		s.NumCPUs = defaultNumCPUs
+	}
+	if s.MemoryMiB == 0 {
+		s.MemoryMiB = defaultMemoryMiB
+	}
+	if s.DiskGiB == 0 {
+		s.DiskGiB = defaultDiskGiB
+	}
+	return h.createDomain(s)
+}
+
+// hosts dials the hypervisors with the credential of the infrastructure secret through the bastions of the
+// cluster.
+func (d *Driver) hosts(ctx context.Context, c *common.Cluster) ([]*hypervisor, error) {
+	if c.Spec.Infrastructure == nil || c.Spec.Infrastructure.Libvirt == nil {
+		return nil, fmt.Errorf("cluster: %s has no libvirt infrastructure", c.Name)
+	}
+	var machines []*devopsv1.ClusterMachine
+	for _, h := range c.Spec.Infrastructure.Libvirt.Hosts {
+		m := &devopsv1.ClusterMachine{
+			IP:            h.IP,
+			Port:          h.Port,
+			Username:      h.Username,
+			HostKey:       h.HostKey,
+			CredentialRef: &devopsv1.SSHCredentialRef{SecretName: c.Spec.Infrastructure.SecretName},
+			Audit:         devopsv1.SSHAudit{Cluster: c.Name, Phase: "Libvirt"},
+		}
+		m.Default()
+		machines = append(machines, m)
+	}
+	err := credential.LoadSSH(ctx, c.Client, c.Namespace, machines...)
+	if err != nil {
+		return nil, err
+	}
+	c.DefaultBastions(machines...)
+
+	hosts := make([]*hypervisor, 0, len(machines))
+	for _, m := range machines {
+		s, err := d.dial(m)
+		if err != nil {
+			return nil, errors.Wrapf(err, "dial libvirt host: %s", m.IP)
+		}
+		hosts = append(hosts, &hypervisor{Interface: s})
+	}
+	return hosts, nil
+}
+
+// findDomain returns the domain of the name and its host, the domain is nil if no host has it.
+func findDomain(hosts []*hypervisor, name string) (*hypervisor, *domain, error) {
+	for _, h := range hosts {
+		dom, err := h.findDomain(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if dom != nil {
+			return h, dom, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// hostIndex spreads the instances over the hosts by name.
+func hostIndex(name string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return int(h.Sum32() % uint32(n))
+}
+
+// owner is the description of the domains of the cluster, the uid tells the cluster created again with the
+// same name.
+func owner(c *common.Cluster) string {
+	return fmt.Sprintf("kunkka/%s/%s/%s", c.Namespace, c.Name, c.UID)
+}
+
+func storagePath(lv *devopsv1.LibvirtInfrastructure) string {
+	if lv.StoragePath == "" {
+		return defaultStoragePath
+	}
+	return lv.StoragePath
+}
@@ -0,0 +1,243 @@
+package libvirt
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/credential"
+	"github.com/gostship/kunkka/pkg/provider/infra"
+	"github.com/gostship/kunkka/pkg/provider/providertest"
+	"github.com/gostship/kunkka/pkg/util/ssh"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var quoted = regexp.MustCompile(`'([^']*)'`)
+
+// fakeHost runs the virsh commands of the driver against the domains in memory, the domains get an ip on the
+// second read of their leases.
+type fakeHost struct {
+	ssh.Interface
+	ip      string
+	user    string
+	domains map[string]*domain
+	reads   map[string]int
+	files   map[string][]byte
+	cmds    []string
+}
+
+func newFakeHost(ip string) *fakeHost {
+	return &fakeHost{ip: ip, domains: make(map[string]*domain), reads: make(map[string]int), files: make(map[string][]byte)}
+}
+
+func (f *fakeHost) HostIP() string {
+	return f.ip
+}
+
+func (f *fakeHost) WriteFile(src io.Reader, dst string) error {
+	data, err := ioutil.ReadAll(src)
+	f.files[dst] = data
+	return err
+}
+
+func (f *fakeHost) Exec(cmd string) (string, string, int, error) {
+	f.cmds = append(f.cmds, cmd)
+	args := quoted.FindAllStringSubmatch(cmd, -1)
+	name := ""
+	if len(args) > 0 {
+		name = args[0][1]
+	}
+	switch {
+	case strings.HasPrefix(cmd, "virsh domstate "):
+		if dom, ok := f.domains[name]; ok {
+			return dom.State + "\n", "", 0, nil
+		}
+		return "", fmt.Sprintf("error: failed to get domain '%s'", name), 1, nil
+	case strings.HasPrefix(cmd, "virsh desc "):
+		return f.domains[name].Owner + "\n", "", 0, nil
+	case strings.HasPrefix(cmd, "virsh domifaddr ") && strings.HasSuffix(cmd, "--source lease"):
+		f.reads[name]++
+		out := " Name       MAC address          Protocol     Address\n---\n"
+		if f.reads[name] > 1 {
+			out += " vnet0      52:54:00:aa:bb:cc    ipv4         192.168.122.10/24\n"
+		}
+		return out, "", 0, nil
+	case strings.HasPrefix(cmd, "virsh domifaddr "):
+		return "", "error: guest agent is not connected", 1, nil
+	case strings.HasPrefix(cmd, "qemu-img create "):
+		// the user data is removed once it's in the seed
+		for _, arg := range args {
+			delete(f.files, arg[1])
+		}
+		install := cmd[strings.Index(cmd, "virt-install"):]
+		m := quoted.FindAllStringSubmatch(install, 2)
+		f.domains[m[0][1]] = &domain{Name: m[0][1], State: "running", Owner: m[1][1]}
+		return "", "", 0, nil
+	case strings.HasPrefix(cmd, "virsh destroy "):
+		delete(f.domains, name)
+		return "", "", 0, nil
+	}
+	return "", "unknown command", 127, nil
+}
+
+func (f *fakeHost) Execf(format string, a ...interface{}) (string, string, int, error) {
+	return f.Exec(fmt.Sprintf(format, a...))
+}
+
+func newPrivateKey(t *testing.T) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+func newCluster(t *testing.T, hosts ...string) *common.Cluster {
+	lv := &devopsv1.LibvirtInfrastructure{Image: "/images/focal.img", DiskGiB: 60}
+	for _, ip := range hosts {
+		lv.Hosts = append(lv.Hosts, devopsv1.LibvirtHost{IP: ip, Username: "root"})
+	}
+	c := providertest.NewInfraCluster(ProviderName, &devopsv1.Infrastructure{SecretName: "kvm", Libvirt: lv},
+		map[string][]byte{credential.SSHPasswordKey: []byte("host-secret")},
+		providertest.Secret("nodes", map[string][]byte{credential.SSHPrivateKeyKey: newPrivateKey(t)}))
+	c.Spec.Bastions = []devopsv1.SSHBastion{{IP: "10.0.0.1", Port: 22, Username: "jump"}}
+	return c
+}
+
+func TestDriver(t *testing.T) {
+	ctx := context.Background()
+	c := newCluster(t, "10.0.1.1", "10.0.1.2")
+	hosts := map[string]*fakeHost{"10.0.1.1": newFakeHost("10.0.1.1"), "10.0.1.2": newFakeHost("10.0.1.2")}
+	d := NewDriver()
+	d.dial = func(m *devopsv1.ClusterMachine) (ssh.Interface, error) {
+		cred, err := m.SSHCredential()
+		if err != nil {
+			return nil, err
+		}
+		if cred.Password != "host-secret" || m.Port != 22 || len(m.DefaultBastions) != 1 {
+			return nil, fmt.Errorf("host: %s dialed with %+v", m.IP, m)
+		}
+		return hosts[m.IP], nil
+	}
+
+	spec := &infra.InstanceSpec{
+		Name: infra.MasterName(c.Name, 0),
+		Role: infra.RoleMaster,
+		Machine: &devopsv1.ClusterMachine{
+			Username:      "ubuntu",
+			CredentialRef: &devopsv1.SSHCredentialRef{SecretName: "nodes"},
+		},
+	}
+	instance, err := d.EnsureInstance(ctx, c, spec)
+	if err != nil {
+		t.Fatalf("EnsureInstance() error = %v", err)
+	}
+	if instance.Name != spec.Name || instance.IP != "" {
+		t.Errorf("EnsureInstance() = %+v, want %s booting", instance, spec.Name)
+	}
+	h := hosts["10.0.1.1"]
+	if hostIndex(spec.Name, 2) == 1 {
+		h = hosts["10.0.1.2"]
+	}
+	if len(h.domains) != 1 || h.domains[spec.Name].Owner != "kunkka/dke/dke/uid-1" {
+		t.Fatalf("domains = %+v, want %s owned by the cluster", h.domains, spec.Name)
+	}
+	create := h.cmds[len(h.cmds)-1]
+	for _, want := range []string{"-b '/images/focal.img' '/var/lib/libvirt/images/dke-master-0.qcow2' 60G", "--memory 4096 --vcpus 2", "network='default'"} {
+		if !strings.Contains(create, want) {
+			t.Errorf("create command %q has no %q", create, want)
+		}
+	}
+	if len(h.files) != 0 {
+		t.Errorf("files = %v, want the user data removed", h.files)
+	}
+
+	instance, err = d.EnsureInstance(ctx, c, spec)
+	if err != nil {
+		t.Fatalf("EnsureInstance() error = %v", err)
+	}
+	if instance.IP != "" {
+		t.Errorf("EnsureInstance() = %+v, want no ip before the lease", instance)
+	}
+	instance, err = d.EnsureInstance(ctx, c, spec)
+	if err != nil {
+		t.Fatalf("EnsureInstance() error = %v", err)
+	}
+	if instance.IP != "192.168.122.10" {
+		t.Errorf("EnsureInstance() = %+v, want the ip of the lease", instance)
+	}
+	if len(hosts["10.0.1.1"].domains)+len(hosts["10.0.1.2"].domains) != 1 {
+		t.Errorf("domains created again, want the domain found by name")
+	}
+
+	// the domain of the cluster created again with the same name is not touched
+	other := newCluster(t, "10.0.1.1", "10.0.1.2")
+	other.UID = "uid-2"
+	if err := d.DeleteInstance(ctx, other, spec.Name); err == nil {
+		t.Errorf("DeleteInstance() of the domain of another cluster succeeded")
+	}
+
+	if err := d.DeleteInstance(ctx, c, spec.Name); err != nil {
+		t.Fatalf("DeleteInstance() error = %v", err)
+	}
+	if len(h.domains) != 0 {
+		t.Errorf("domains = %v, want deleted", h.domains)
+	}
+	if err := d.DeleteInstance(ctx, c, spec.Name); err != nil {
+		t.Errorf("DeleteInstance() of the deleted domain error = %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	d := NewDriver()
+	host := devopsv1.LibvirtHost{IP: "10.0.1.1", Username: "root"}
+	tests := []struct {
+		name string
+		lv   *devopsv1.LibvirtInfrastructure
+		errs int
+	}{
+		{name: "valid", lv: &devopsv1.LibvirtInfrastructure{Hosts: []devopsv1.LibvirtHost{host}, Image: "/images/focal.img"}},
+		{name: "no libvirt", errs: 1},
+		{name: "no hosts", lv: &devopsv1.LibvirtInfrastructure{Image: "/images/focal.img"}, errs: 1},
+		{name: "duplicate host", lv: &devopsv1.LibvirtInfrastructure{Hosts: []devopsv1.LibvirtHost{host, host}, Image: "/images/focal.img"}, errs: 1},
+		{name: "no username", lv: &devopsv1.LibvirtInfrastructure{Hosts: []devopsv1.LibvirtHost{{IP: "10.0.1.1"}}, Image: "/images/focal.img"}, errs: 1},
+		{name: "relative image", lv: &devopsv1.LibvirtInfrastructure{Hosts: []devopsv1.LibvirtHost{host}, Image: "focal.img"}, errs: 1},
+		{name: "negative", lv: &devopsv1.LibvirtInfrastructure{Hosts: []devopsv1.LibvirtHost{host}, Image: "/images/focal.img", NumCPUs: -1, DiskGiB: -1}, errs: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := d.Validate(&devopsv1.Infrastructure{SecretName: "kvm", Libvirt: tt.lv}, field.NewPath("spec", "infrastructure"))
+			if len(errs) != tt.errs {
+				t.Errorf("Validate() = %v, want %d errors", errs, tt.errs)
+			}
+		})
+	}
+}
+
+func TestParseDomIfAddr(t *testing.T) {
+	out := ` Name       MAC address          Protocol     Address
+-------------------------------------------------------------------------------
+ lo         00:00:00:00:00:00    ipv4         127.0.0.1/8
+ eth0       52:54:00:aa:bb:cc    ipv6         fe80::1/64
+ -          -                    ipv4         10.0.2.15/24
+`
+	if ip := parseDomIfAddr(out); ip != "10.0.2.15" {
+		t.Errorf("parseDomIfAddr() = %q, want 10.0.2.15", ip)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	if got := quote("it's"); got != `'it'\''s'` {
+		t.Errorf("quote() = %s", got)
+	}
+}
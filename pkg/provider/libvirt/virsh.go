@@ -0,0 +1,156 @@
+package libvirt
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/gostship/kunkka/pkg/util/ssh"
+	"github.com/pkg/errors"
+)
+
+// hypervisor runs virsh on a host over ssh.
+type hypervisor struct {
+	ssh.Interface
+}
+
+// domain is a VM of libvirt, Owner is its description.
+type domain struct {
+	Name  string
+	State string
+	Owner string
+}
+
+// quote quotes the argument for the shell of the host.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (h *hypervisor) run(cmd string) (string, error) {
+	stdout, stderr, exit, err := h.Exec(cmd)
+	if err != nil {
+		return "", err
+	}
+	if exit != 0 {
+		return "", fmt.Errorf("host: %s run %q exit: %d, stderr: %s", h.HostIP(), cmd, exit, strings.TrimSpace(stderr))
+	}
+	return stdout, nil
+}
+
+// findDomain returns the domain of the name, it's nil if not found.
+func (h *hypervisor) findDomain(name string) (*domain, error) {
+	stdout, stderr, exit, err := h.Exec("virsh domstate " + quote(name))
+	if err != nil {
+		return nil, err
+	}
+	if exit != 0 {
+		if strings.Contains(stderr, "failed to get domain") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("host: %s get domain: %s exit: %d, stderr: %s", h.HostIP(), name, exit, strings.TrimSpace(stderr))
+	}
+
+	desc, err := h.run("virsh desc " + quote(name))
+	if err != nil {
+		return nil, err
+	}
+	return &domain{Name: name, State: strings.TrimSpace(stdout), Owner: strings.TrimSpace(desc)}, nil
+}
+
+// domainIP returns the ipv4 address of the domain by the dhcp lease of its network, or else by the guest
+// agent, it's empty until the domain got one.
+func (h *hypervisor) domainIP(name string) (string, error) {
+	stdout, err := h.run("virsh domifaddr " + quote(name) + " --source lease")
+	if err != nil {
+		return "", err
+	}
+	if ip := parseDomIfAddr(stdout); ip != "" {
+		return ip, nil
+	}
+	// the bridged networks have no lease of libvirt, the agent may not be started yet
+	stdout, _, _, err = h.Exec("virsh domifaddr " + quote(name) + " --source agent")
+	if err != nil {
+		return "", err
+	}
+	return parseDomIfAddr(stdout), nil
+}
+
+// parseDomIfAddr returns the first ipv4 address of the output of virsh domifaddr but the loopback one.
+func parseDomIfAddr(out string) string {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[len(fields)-2] != "ipv4" {
+			continue
+		}
+		ip := strings.SplitN(fields[len(fields)-1], "/", 2)[0]
+		if !strings.HasPrefix(ip, "127.") {
+			return ip
+		}
+	}
+	return ""
+}
+
+// domainSpec is the VM created by createDomain.
+type domainSpec struct {
+	Name      string
+	Owner     string
+	Image     string
+	Dir       string
+	Network   string
+	NumCPUs   int32
+	MemoryMiB int64
+	DiskGiB   int32
+	UserData  []byte
+}
+
+func (s *domainSpec) disk() string {
+	return path.Join(s.Dir, s.Name+".qcow2")
+}
+
+func (s *domainSpec) seed() string {
+	return path.Join(s.Dir, s.Name+"-seed.iso")
+}
+
+// createDomain creates the disk as an overlay of the image and the NoCloud seed of cloud-init, then boots
+// the domain from them. The user data is removed once it's in the seed.
+func (h *hypervisor) createDomain(s *domainSpec) error {
+	userData := path.Join(s.Dir, s.Name+"-user-data")
+	metaData := path.Join(s.Dir, s.Name+"-meta-data")
+	err := h.WriteFile(bytes.NewReader(s.UserData), userData)
+	if err != nil {
+		return errors.Wrapf(err, "write user data of domain: %s", s.Name)
+	}
+	err = h.WriteFile(strings.NewReader(fmt.Sprintf("instance-id: %s\nlocal-hostname: %s\n", s.Name, s.Name)), metaData)
+	if err != nil {
+		return errors.Wrapf(err, "write meta data of domain: %s", s.Name)
+	}
+
+	cmd := strings.Join([]string{
+		fmt.Sprintf("qemu-img create -f qcow2 -F qcow2 -b %s %s %dG", quote(s.Image), quote(s.disk()), s.DiskGiB),
+		fmt.Sprintf("cloud-localds %s %s %s", quote(s.seed()), quote(userData), quote(metaData)),
+		fmt.Sprintf("rm -f %s %s", quote(userData), quote(metaData)),
+		fmt.Sprintf("virt-install --name %s --description %s --import --memory %d --vcpus %d "+
+			"--disk path=%s,format=qcow2 --disk path=%s,device=cdrom --network network=%s "+
+			"--os-variant generic --graphics none --noautoconsole",
+			quote(s.Name), quote(s.Owner), s.MemoryMiB, s.NumCPUs, quote(s.disk()), quote(s.seed()), quote(s.Network)),
+	}, " && ")
+	_, err = h.run(cmd)
+	if err != nil {
+		// the user data has the credential of the machine
+		h.Exec(fmt.Sprintf("rm -f %s %s", quote(userData), quote(metaData)))
+		return errors.Wrapf(err, "create domain: %s", s.Name)
+	}
+	return nil
+}
+
+// deleteDomain stops and undefines the domain, then removes its disk and seed.
+func (h *hypervisor) deleteDomain(s *domainSpec) error {
+	cmd := fmt.Sprintf("virsh destroy %s >/dev/null 2>&1; virsh undefine %s && rm -f %s %s",
+		quote(s.Name), quote(s.Name), quote(s.disk()), quote(s.seed()))
+	_, err := h.run(cmd)
+	if err != nil {
+		return errors.Wrapf(err, "delete domain: %s", s.Name)
+	}
+	return nil
+}
@@ -7,14 +7,20 @@ import (
 	"reflect"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/thoas/go-funk"
 
 	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
 	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/logs"
+	"github.com/gostship/kunkka/pkg/metrics"
+	"github.com/gostship/kunkka/pkg/timeouts"
+	"github.com/gostship/kunkka/pkg/tracing"
+	"github.com/gostship/kunkka/pkg/util/ssh"
+	"go.opentelemetry.io/otel/trace"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/validation/field"
-	"k8s.io/klog"
 )
 
 const (
@@ -23,6 +29,16 @@ const (
 	ReasonFailedInit   = "FailedInit"
 	ReasonFailedUpdate = "FailedUpdate"
 	ReasonFailedDelete = "FailedDelete"
+	// ReasonHostKeyMismatch the host key of the machine or its bastions differs from the known one
+	ReasonHostKeyMismatch = "HostKeyMismatch"
+	// ReasonRetriesExhausted the handler failed the attempts of its budget, it's not retried until the
+	// phaseRetry annotation
+	ReasonRetriesExhausted = "RetriesExhausted"
+	// ReasonCheckpointed the handler is skipped for it completed before, see status.completedPhases
+	ReasonCheckpointed = "Checkpointed"
+	// ReasonTimeout the handler failed for a wait of the phase timed out, e.g. systemInstall or nodeReady,
+	// the timeouts are set by the waits
+	ReasonTimeout = "Timeout"
 
 	ConditionTypeDone = "EnsureDone"
 )
@@ -99,6 +115,8 @@ func (p *DelegateProvider) AfterCreate(machine *devopsv1.Machine) error {
 }
 
 func (p *DelegateProvider) OnCreate(ctx context.Context, machine *devopsv1.Machine, cluster *common.Cluster) error {
+	ctx = p.withLogger(ctx, machine)
+	logger := logs.FromContext(ctx)
 	condition, err := p.getCreateCurrentCondition(machine)
 	if err != nil {
 		return err
@@ -115,24 +133,34 @@ func (p *DelegateProvider) OnCreate(ctx context.Context, machine *devopsv1.Machi
 			Reason:             ReasonSkip,
 			Message:            "Skip current condition",
 		})
+	} else if machine.PhaseCompleted(condition.Type) {
+		logger.Info("OnCreate handler completed before, skip", logs.PhaseKey, condition.Type)
+		machine.SetCondition(devopsv1.MachineCondition{
+			Type:               condition.Type,
+			Status:             devopsv1.ConditionTrue,
+			LastProbeTime:      now,
+			LastTransitionTime: now,
+			Reason:             ReasonCheckpointed,
+		})
 	} else {
 		f := p.getCreateHandler(condition.Type)
 		if f == nil {
 			return fmt.Errorf("can't get handler by %s", condition.Type)
 		}
 		handlerName := f.Name()
-		klog.Infof("machineName: %s OnCreate handler: %s", machine.Name, handlerName)
-		err = f(ctx, machine, cluster)
+		if after := retryAfter(cluster.Cluster, condition); after > 0 {
+			logger.V(1).Info("OnCreate handler backs off", logs.PhaseKey, handlerName, "retryAfter", after.String())
+			return nil
+		}
+		phaseCtx, span := p.startPhase(ctx, machine, cluster, handlerName)
+		logs.FromContext(phaseCtx).Info("OnCreate handler")
+		err = p.run(phaseCtx, f, machine, cluster)
+		tracing.End(span, err)
+		duration := metav1.Duration{Duration: time.Since(now.Time)}
+		metrics.ObservePhase(metrics.KindMachine, condition.Type, duration.Duration, err)
 		if err != nil {
-			klog.Errorf("cluster: %s OnCreate handler: %s err: %+v", cluster.Name, handlerName, err)
-			machine.SetCondition(devopsv1.MachineCondition{
-				Type:          condition.Type,
-				Status:        devopsv1.ConditionFalse,
-				LastProbeTime: now,
-				Message:       err.Error(),
-				Reason:        ReasonFailedInit,
-			})
-
+			logs.FromContext(phaseCtx).Error(err, "OnCreate handler failed")
+			setFailedCondition(machine, condition, err, duration)
 			return err
 		}
 
@@ -140,8 +168,10 @@ func (p *DelegateProvider) OnCreate(ctx context.Context, machine *devopsv1.Machi
 			Type:               condition.Type,
 			Status:             devopsv1.ConditionTrue,
 			LastProbeTime:      now,
-			LastTransitionTime: now,
+			LastTransitionTime: metav1.Now(),
+			Duration:           duration,
 		})
+		machine.CompletePhase(condition.Type)
 	}
 
 	nextConditionType := p.getNextConditionType(condition.Type)
@@ -161,10 +191,17 @@ func (p *DelegateProvider) OnCreate(ctx context.Context, machine *devopsv1.Machi
 }
 
 func (p *DelegateProvider) OnUpdate(ctx context.Context, machine *devopsv1.Machine, cluster *common.Cluster) error {
+	ctx = p.withLogger(ctx, machine)
 	for _, f := range p.UpdateHandlers {
-		klog.Infof("machineName: %s OnUpdate handler: %s", machine.Name, f.Name())
-		err := f(ctx, machine, cluster)
+		phaseCtx, span := p.startPhase(ctx, machine, cluster, f.Name())
+		logs.FromContext(phaseCtx).Info("OnUpdate handler")
+		err := p.run(phaseCtx, f, machine, cluster)
+		tracing.End(span, err)
 		if err != nil {
+			if ssh.IsHostKeyMismatch(err) {
+				machine.Status.Reason = ReasonHostKeyMismatch
+				machine.Status.Message = err.Error()
+			}
 			return err
 		}
 	}
@@ -175,9 +212,12 @@ func (p *DelegateProvider) OnUpdate(ctx context.Context, machine *devopsv1.Machi
 }
 
 func (p *DelegateProvider) OnDelete(ctx context.Context, machine *devopsv1.Machine, cluster *common.Cluster) error {
+	ctx = p.withLogger(ctx, machine)
 	for _, f := range p.DeleteHandlers {
-		klog.Infof("machineName: %s OnDelete handler: %s", machine.Name, f.Name())
-		err := f(ctx, machine, cluster)
+		phaseCtx, span := p.startPhase(ctx, machine, cluster, f.Name())
+		logs.FromContext(phaseCtx).Info("OnDelete handler")
+		err := p.run(phaseCtx, f, machine, cluster)
+		tracing.End(span, err)
 		if err != nil {
 			return err
 		}
@@ -241,3 +281,96 @@ func (p *DelegateProvider) getCreateCurrentCondition(c *devopsv1.Machine) (*devo
 
 	return nil, errors.New("no condition need process")
 }
+
+// run calls the handler, the handler failed for the transient ssh errors is retried by the sshRetry wait,
+// so the handlers must be idempotent.
+func (p *DelegateProvider) run(ctx context.Context, f Handler, machine *devopsv1.Machine, cluster *common.Cluster) error {
+	var c *devopsv1.Cluster
+	if cluster != nil {
+		c = cluster.Cluster
+	}
+	return timeouts.Retry(c, timeouts.SSHRetry, ssh.IsTransient, func() error {
+		err := f(ctx, machine, cluster)
+		if ssh.IsTransient(err) {
+			logs.FromContext(ctx).Info("transient err of the handler, retry", "err", err.Error())
+		}
+		return err
+	})
+}
+
+// setFailedCondition marks the condition of the handler failed and counts its attempts, the machine is
+// failed once they exhaust the budget.
+func setFailedCondition(machine *devopsv1.Machine, condition *devopsv1.MachineCondition, err error, duration metav1.Duration) {
+	message := err.Error()
+	reason := ReasonFailedInit
+	if ssh.IsHostKeyMismatch(err) {
+		reason = ReasonHostKeyMismatch
+	} else if timeouts.IsTimeout(err) {
+		reason = ReasonTimeout
+	}
+
+	attempts := int32(1)
+	if condition.Status == devopsv1.ConditionFalse {
+		attempts = condition.Attempts + 1
+	}
+	if timeouts.PhaseExhausted(attempts) {
+		reason = ReasonRetriesExhausted
+		message = fmt.Sprintf("failed %d attempts, last err: %s", attempts, message)
+		machine.Status.Phase = devopsv1.MachineFailed
+	}
+
+	machine.SetCondition(devopsv1.MachineCondition{
+		Type:          condition.Type,
+		Status:        devopsv1.ConditionFalse,
+		LastProbeTime: metav1.Now(),
+		Message:       message,
+		Reason:        reason,
+		Attempts:      attempts,
+		Duration:      duration,
+	})
+	metrics.RecordFailure(metrics.KindMachine, condition.Type, reason)
+}
+
+// retryAfter returns how long the failed condition still backs off.
+func retryAfter(c *devopsv1.Cluster, condition *devopsv1.MachineCondition) time.Duration {
+	if condition.Status != devopsv1.ConditionFalse || condition.Reason == ReasonRetriesExhausted {
+		return 0
+	}
+	return timeouts.PhaseRetryAfter(c, condition.Attempts, condition.LastProbeTime.Time)
+}
+
+// RetryAfter returns the shortest time the failed conditions of the machine still back off, 0 if none does.
+func RetryAfter(c *devopsv1.Cluster, machine *devopsv1.Machine) time.Duration {
+	var after time.Duration
+	for i := range machine.Status.Conditions {
+		if d := retryAfter(c, &machine.Status.Conditions[i]); d > 0 && (after == 0 || d < after) {
+			after = d
+		}
+	}
+	return after
+}
+
+// withLogger returns the context whose logger has the fields of the machine, its cluster and the provider.
+func (p *DelegateProvider) withLogger(ctx context.Context, machine *devopsv1.Machine) context.Context {
+	return logs.WithValues(ctx, logs.MachineKey, machine.Name, logs.ClusterKey, machine.Spec.ClusterName,
+		logs.ProviderKey, p.ProviderName)
+}
+
+// startPhase starts the span of the handler in the trace of the api request creating the machine, or the cluster
+// if the machine has none, and labels the commands run on the machine and the masters by the handler with it.
+func (p *DelegateProvider) startPhase(ctx context.Context, machine *devopsv1.Machine, cluster *common.Cluster, phase string) (context.Context, trace.Span) {
+	if cluster != nil && cluster.Cluster != nil {
+		ctx = tracing.Extract(ctx, cluster.Cluster)
+	}
+	ctx, span := tracing.Start(tracing.Extract(ctx, machine), "machine "+phase,
+		tracing.MachineKey.String(machine.Name),
+		tracing.ClusterKey.String(machine.Spec.ClusterName),
+		tracing.PhaseKey.String(phase),
+		tracing.ProviderKey.String(p.ProviderName))
+	traceParent := tracing.TraceParent(ctx)
+	machine.AuditPhase(phase, traceParent)
+	if cluster != nil && cluster.Cluster != nil {
+		cluster.AuditPhase(phase, traceParent)
+	}
+	return logs.WithValues(ctx, logs.PhaseKey, phase), span
+}
@@ -0,0 +1,118 @@
+package managed
+
+import (
+	"context"
+	"fmt"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/logs"
+	"github.com/gostship/kunkka/pkg/util/pkiutil"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// KubeconfigKey the key of the kubeconfig in the secret of the managed cluster
+const KubeconfigKey = "kubeconfig"
+
+func ValidateCluster(c *common.Cluster) field.ErrorList {
+	allErrs := field.ErrorList{}
+	fldPath := field.NewPath("spec")
+	m := c.Spec.Managed
+	if m == nil {
+		return append(allErrs, field.Required(fldPath.Child("managed"), "must be set for the managed clusters"))
+	}
+	found := false
+	for _, platform := range devopsv1.ManagedPlatforms {
+		found = found || m.Platform == platform
+	}
+	if !found {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("managed", "platform"), m.Platform, []string{"EKS", "GKE", "AKS", "TKE"}))
+	}
+	if m.SecretName == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("managed", "secretName"), ""))
+	}
+	if len(c.Spec.Machines) > 0 {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("machines"), "the nodes of the managed clusters are run by the cloud"))
+	}
+	return allErrs
+}
+
+// EnsureManagedKubeconfig keeps the kubeconfig of the secret as the admin kubeconfig of the cluster, the
+// client of the cluster is rebuilt once it changed, e.g. the token is rotated.
+func (p *Provider) EnsureManagedKubeconfig(ctx context.Context, c *common.Cluster) error {
+	if errs := ValidateCluster(c); len(errs) > 0 {
+		return errs.ToAggregate()
+	}
+
+	secret := &corev1.Secret{}
+	err := c.Client.Get(ctx, types.NamespacedName{Namespace: c.Namespace, Name: c.Spec.Managed.SecretName}, secret)
+	if err != nil {
+		return errors.Wrapf(err, "get secret: %s", c.Spec.Managed.SecretName)
+	}
+	kubeconfig := secret.Data[KubeconfigKey]
+	if len(kubeconfig) == 0 {
+		return fmt.Errorf("secret: %s has no %s", c.Spec.Managed.SecretName, KubeconfigKey)
+	}
+	err = validateKubeconfig(kubeconfig)
+	if err != nil {
+		return errors.Wrapf(err, "secret: %s", c.Spec.Managed.SecretName)
+	}
+
+	if c.ClusterCredential.ExtData == nil {
+		c.ClusterCredential.ExtData = make(map[string]string)
+	}
+	c.ClusterCredential.ExtData[pkiutil.ExternalAdminKubeConfigFileName] = string(kubeconfig)
+	_, err = c.ClusterManager.AddNewClusters(c.Name, string(kubeconfig))
+	return err
+}
+
+// validateKubeconfig checks the kubeconfig authenticates by itself, the plugins run commands the controller
+// doesn't have, e.g. aws-iam-authenticator or gcloud.
+func validateKubeconfig(data []byte) error {
+	cfg, err := clientcmd.Load(data)
+	if err != nil {
+		return errors.Wrap(err, "load kubeconfig")
+	}
+	kctx, ok := cfg.Contexts[cfg.CurrentContext]
+	if !ok {
+		return fmt.Errorf("kubeconfig has no current context: %q", cfg.CurrentContext)
+	}
+	auth, ok := cfg.AuthInfos[kctx.AuthInfo]
+	if !ok {
+		return fmt.Errorf("kubeconfig has no user: %q", kctx.AuthInfo)
+	}
+	if auth.Exec != nil || auth.AuthProvider != nil {
+		return fmt.Errorf("user: %q of kubeconfig authenticates by a plugin, use a token or a client certificate", kctx.AuthInfo)
+	}
+	return nil
+}
+
+// EnsureManagedReady checks the apiserver is reachable and records its version.
+func (p *Provider) EnsureManagedReady(ctx context.Context, c *common.Cluster) error {
+	clusterCtx, err := c.ClusterManager.Get(c.Name)
+	if err != nil {
+		return c.SkipReconcile("EnsureManagedReady", err)
+	}
+	version, err := clusterCtx.KubeCli.Discovery().ServerVersion()
+	if err != nil {
+		return errors.Wrapf(err, "get version of cluster: %s", c.Name)
+	}
+	if c.Cluster.Status.Version != version.GitVersion {
+		logs.FromContext(ctx).Info("managed cluster version", "platform", c.Spec.Managed.Platform, "version", version.GitVersion)
+		c.Cluster.Status.Version = version.GitVersion
+	}
+	return nil
+}
+
+// EnsureMetricsServer applies metrics-server to the platforms which don't run it themselves.
+func (p *Provider) EnsureMetricsServer(ctx context.Context, c *common.Cluster) error {
+	switch c.Spec.Managed.Platform {
+	case devopsv1.ManagedGKE, devopsv1.ManagedAKS:
+		return nil
+	}
+	return p.Baremetal.EnsureMetricsServer(ctx, c)
+}
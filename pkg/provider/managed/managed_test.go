@@ -0,0 +1,110 @@
+package managed
+
+import (
+	"testing"
+	"time"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateCluster(t *testing.T) {
+	tests := []struct {
+		name string
+		spec devopsv1.ClusterSpec
+		errs int
+	}{
+		{name: "valid", spec: devopsv1.ClusterSpec{Managed: &devopsv1.ManagedCluster{Platform: devopsv1.ManagedEKS, SecretName: "eks"}}},
+		{name: "no managed", errs: 1},
+		{name: "unknown platform", spec: devopsv1.ClusterSpec{Managed: &devopsv1.ManagedCluster{Platform: "ACK"}}, errs: 2},
+		{name: "machines", spec: devopsv1.ClusterSpec{
+			Managed:  &devopsv1.ManagedCluster{Platform: devopsv1.ManagedGKE, SecretName: "gke"},
+			Machines: []*devopsv1.ClusterMachine{{IP: "10.0.0.1"}},
+		}, errs: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateCluster(&common.Cluster{Cluster: &devopsv1.Cluster{Spec: tt.spec}})
+			if len(errs) != tt.errs {
+				t.Errorf("ValidateCluster() = %v, want %d errors", errs, tt.errs)
+			}
+		})
+	}
+}
+
+const kubeconfigTemplate = `apiVersion: v1
+kind: Config
+clusters:
+- name: eks
+  cluster:
+    server: https://eks.example.com
+contexts:
+- name: eks
+  context:
+    cluster: eks
+    user: admin
+current-context: eks
+users:
+- name: admin
+  user:
+`
+
+func TestValidateKubeconfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    string
+		wantErr bool
+	}{
+		{name: "token", user: "    token: abc\n"},
+		{name: "exec", user: "    exec:\n      apiVersion: client.authentication.k8s.io/v1alpha1\n      command: aws-iam-authenticator\n", wantErr: true},
+		{name: "auth provider", user: "    auth-provider:\n      name: gcp\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateKubeconfig([]byte(kubeconfigTemplate + tt.user))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateKubeconfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	if err := validateKubeconfig([]byte("apiVersion: v1\nkind: Config\n")); err == nil {
+		t.Errorf("validateKubeconfig() of no current context error = nil")
+	}
+}
+
+func TestNodePlacements(t *testing.T) {
+	now := time.Now()
+	node := func(name string, age time.Duration) corev1.Node {
+		return corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(now.Add(-age))}}
+	}
+	nodes := []corev1.Node{node("c", time.Minute), node("a", time.Hour), node("b", time.Hour), node("d", time.Second)}
+	deleting := node("e", 2*time.Hour)
+	deleting.DeletionTimestamp = &metav1.Time{Time: now}
+	nodes = append(nodes, deleting)
+
+	placements := []devopsv1.NodePlacement{
+		{Name: "ingress", Replicas: 2, Labels: map[string]string{"role": "ingress"}},
+		{Name: "system", Replicas: 1, Taints: []corev1.Taint{{Key: "system", Value: "true", Effect: corev1.TaintEffectNoSchedule}}},
+	}
+	got := nodePlacements(nodes, placements)
+	want := map[string]string{"a": "ingress", "b": "ingress", "c": "system"}
+	if len(got) != len(want) {
+		t.Fatalf("nodePlacements() = %v, want %v", got, want)
+	}
+	for name, p := range want {
+		if got[name] == nil || got[name].Name != p {
+			t.Errorf("nodePlacements() of node: %s = %v, want %s", name, got[name], p)
+		}
+	}
+
+	n := &nodes[0]
+	if !markNode(n, got["c"]) || n.Labels[constants.NodePlacementLabel] != "system" || len(n.Spec.Taints) != 1 {
+		t.Errorf("markNode() = %+v, want the label and the taint of the placement", n)
+	}
+	if markNode(n, got["c"]) {
+		t.Errorf("markNode() of the marked node changed")
+	}
+}
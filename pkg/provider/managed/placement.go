@@ -0,0 +1,110 @@
+package managed
+
+import (
+	"context"
+	"sort"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/logs"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EnsureNodePlacements applies the labels and taints of the placements to the nodes of the cluster, the
+// placements take the nodes in order of creation like the Machines of the other clusters. The nodes are
+// created by the cloud, e.g. the node groups, so they are marked once joined instead.
+func (p *Provider) EnsureNodePlacements(ctx context.Context, c *common.Cluster) error {
+	if len(c.Spec.Placements) == 0 {
+		return nil
+	}
+	clusterCtx, err := c.ClusterManager.Get(c.Name)
+	if err != nil {
+		return c.SkipReconcile("EnsureNodePlacements", err)
+	}
+
+	nodes, err := clusterCtx.KubeCli.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "list nodes")
+	}
+	placements := nodePlacements(nodes.Items, c.Spec.Placements)
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		placement, ok := placements[node.Name]
+		if !ok || !markNode(node, placement) {
+			continue
+		}
+		logs.FromContext(ctx).Info("node is dedicated to placement", "node", node.Name, "placement", placement.Name)
+		_, err = clusterCtx.KubeCli.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "update node: %s", node.Name)
+		}
+	}
+	return nil
+}
+
+// nodePlacements returns the placements of the nodes by name, the nodes beyond all the placements are left out.
+func nodePlacements(nodes []corev1.Node, placements []devopsv1.NodePlacement) map[string]*devopsv1.NodePlacement {
+	ns := make([]*corev1.Node, 0, len(nodes))
+	for i := range nodes {
+		if nodes[i].DeletionTimestamp == nil {
+			ns = append(ns, &nodes[i])
+		}
+	}
+	sort.SliceStable(ns, func(i, j int) bool {
+		if !ns[i].CreationTimestamp.Equal(&ns[j].CreationTimestamp) {
+			return ns[i].CreationTimestamp.Before(&ns[j].CreationTimestamp)
+		}
+		return ns[i].Name < ns[j].Name
+	})
+
+	result := make(map[string]*devopsv1.NodePlacement)
+	idx := 0
+	for i := range placements {
+		for r := int32(0); r < placements[i].Replicas && idx < len(ns); r++ {
+			result[ns[idx].Name] = &placements[i]
+			idx++
+		}
+	}
+	return result
+}
+
+// markNode sets the labels and the taints of the placement on the node, it returns whether the node changed.
+func markNode(node *corev1.Node, placement *devopsv1.NodePlacement) bool {
+	changed := false
+	labels := map[string]string{constants.NodePlacementLabel: placement.Name}
+	for k, v := range placement.Labels {
+		labels[k] = v
+	}
+	for k, v := range labels {
+		if node.Labels[k] != v {
+			if node.Labels == nil {
+				node.Labels = make(map[string]string)
+			}
+			node.Labels[k] = v
+			changed = true
+		}
+	}
+
+	for i := range placement.Taints {
+		t := placement.Taints[i]
+		existed := false
+		for j := range node.Spec.Taints {
+			if node.Spec.Taints[j].MatchTaint(&t) {
+				existed = true
+				if node.Spec.Taints[j].Value != t.Value {
+					node.Spec.Taints[j].Value = t.Value
+					changed = true
+				}
+				break
+			}
+		}
+		if !existed {
+			node.Spec.Taints = append(node.Spec.Taints, t)
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,73 @@
+// Package managed attaches the clusters whose control plane is run by a cloud, e.g. EKS. The control plane
+// phases are skipped, the addons and the node placements are applied with the kubeconfig of the cluster.
+package managed
+
+import (
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	baremetalcluster "github.com/gostship/kunkka/pkg/provider/baremetal/cluster"
+	clusterprovider "github.com/gostship/kunkka/pkg/provider/cluster"
+	"github.com/gostship/kunkka/pkg/provider/config"
+	"github.com/gostship/kunkka/pkg/timeouts"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog"
+)
+
+const ProviderName = "Managed"
+
+func AddCluster(mgr *clusterprovider.CpManager, cfg *config.Config) error {
+	p, err := NewProvider(mgr, cfg)
+	if err != nil {
+		klog.Errorf("init cluster provider error: %s", err)
+		return err
+	}
+	mgr.Register(p.Name(), p)
+	return nil
+}
+
+// Provider runs the addon handlers of the bare metal clusters on the managed clusters, the ones of the
+// control plane and the nodes are left out.
+type Provider struct {
+	*clusterprovider.DelegateProvider
+	Baremetal *baremetalcluster.Provider
+}
+
+var _ clusterprovider.Provider = &Provider{}
+
+func NewProvider(mgr *clusterprovider.CpManager, cfg *config.Config) (*Provider, error) {
+	bm, err := baremetalcluster.NewProvider(mgr, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Provider{Baremetal: bm}
+	handlers := []clusterprovider.Handler{
+		p.EnsureManagedKubeconfig,
+		p.EnsureManagedReady,
+		p.EnsureNodePlacements,
+		p.EnsureMetricsServer,
+		bm.EnsureGatekeeper,
+		bm.EnsureMultus,
+		bm.EnsurePodSecurity,
+	}
+	p.DelegateProvider = &clusterprovider.DelegateProvider{
+		ProviderName:   ProviderName,
+		CreateHandlers: handlers,
+		UpdateHandlers: handlers,
+		// the rotated kubeconfig is taken and the nodes added by the cloud, e.g. scaled up, are marked
+		ResyncHandlers: []clusterprovider.Handler{
+			p.EnsureManagedKubeconfig,
+			p.EnsureNodePlacements,
+			bm.EnsureAddonsGC,
+		},
+	}
+
+	err = timeouts.SetProvider(p.Name(), cfg.Waits)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Provider) Validate(cluster *common.Cluster) field.ErrorList {
+	return ValidateCluster(cluster)
+}
@@ -0,0 +1,106 @@
+package openstack
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/pkg/errors"
+)
+
+const requestTimeout = 30 * time.Second
+
+func isNotFound(err error) bool {
+	_, ok := errors.Cause(err).(gophercloud.ErrDefault404)
+	return ok
+}
+
+type credential struct {
+	username  string
+	password  string
+	projectID string
+	domain    string
+}
+
+// client is the gophercloud client of a keystone, it's authenticated on the first call and again by gophercloud
+// once the token expires or is revoked, the clients of the services are of the endpoints of its catalog.
+type client struct {
+	authURL  string
+	region   string
+	insecure bool
+	cred     credential
+
+	mu       sync.Mutex
+	provider *gophercloud.ProviderClient
+}
+
+func newClient(authURL, region string, insecure bool, cred credential) *client {
+	return &client{
+		authURL:  authURL,
+		region:   region,
+		insecure: insecure,
+		cred:     cred,
+	}
+}
+
+func (c *client) connect() (*gophercloud.ProviderClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.provider != nil {
+		return c.provider, nil
+	}
+
+	provider, err := openstack.NewClient(c.authURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse keystone url: %s", c.authURL)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if c.insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	provider.HTTPClient = http.Client{Transport: transport, Timeout: requestTimeout}
+
+	err = openstack.AuthenticateV3(provider, &gophercloud.AuthOptions{
+		IdentityEndpoint: c.authURL,
+		Username:         c.cred.username,
+		Password:         c.cred.password,
+		DomainName:       c.cred.domain,
+		Scope:            &gophercloud.AuthScope{ProjectID: c.cred.projectID},
+		AllowReauth:      true,
+	}, gophercloud.EndpointOpts{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "authenticate with keystone: %s", c.authURL)
+	}
+	c.provider = provider
+	return provider, nil
+}
+
+type serviceFunc func(*gophercloud.ProviderClient, gophercloud.EndpointOpts) (*gophercloud.ServiceClient, error)
+
+// service returns the client of the service of the endpoint of the region in the catalog.
+func (c *client) service(newService serviceFunc, name string) (*gophercloud.ServiceClient, error) {
+	provider, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	sc, err := newService(provider, gophercloud.EndpointOpts{Region: c.region})
+	if err != nil {
+		return nil, errors.Wrapf(err, "find %s endpoint of region: %q", name, c.region)
+	}
+	return sc, nil
+}
+
+func (c *client) compute() (*gophercloud.ServiceClient, error) {
+	return c.service(openstack.NewComputeV2, "compute")
+}
+
+func (c *client) network() (*gophercloud.ServiceClient, error) {
+	return c.service(openstack.NewNetworkV2, "network")
+}
+
+func (c *client) loadBalancer() (*gophercloud.ServiceClient, error) {
+	return c.service(openstack.NewLoadBalancerV2, "load-balancer")
+}
@@ -0,0 +1,276 @@
+package openstack
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/infra"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// the keys of the infrastructure secret
+	usernameKey   = "username"
+	passwordKey   = "password"
+	projectIDKey  = "projectID"
+	domainNameKey = "domainName"
+
+	defaultDomain = "Default"
+
+	apiserverPort = 6443
+	// clusterMetadata the metadata of the servers with the name of their cluster
+	clusterMetadata = "kunkka-cluster"
+)
+
+// Driver boots the servers with gophercloud on the ports in the subnets of the racks of the machines, the servers, the ports and
+// the load balancers are found by name.
+type Driver struct {
+	mu sync.Mutex
+	// clients the clients of each keystone and credential, their tokens are reused by the clusters
+	clients map[string]*client
+}
+
+var _ infra.EndpointDriver = &Driver{}
+
+func NewDriver() *Driver {
+	return &Driver{clients: make(map[string]*client)}
+}
+
+func (d *Driver) Validate(in *devopsv1.Infrastructure, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	opsPath := fldPath.Child("openstack")
+	ops := in.OpenStack
+	if ops == nil {
+		return append(allErrs, field.Required(opsPath, "must be set for the openstack clusters"))
+	}
+	if u, err := url.Parse(ops.AuthURL); err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		allErrs = append(allErrs, field.Invalid(opsPath.Child("authURL"), ops.AuthURL, "must be the http(s) url of the keystone v3 api"))
+	}
+	if ops.Flavor == "" {
+		allErrs = append(allErrs, field.Required(opsPath.Child("flavor"), ""))
+	}
+	if ops.Image == "" {
+		allErrs = append(allErrs, field.Required(opsPath.Child("image"), ""))
+	}
+	if len(ops.Subnets) == 0 {
+		allErrs = append(allErrs, field.Required(opsPath.Child("subnets"), ""))
+	}
+	racks := make(map[string]bool)
+	for i, s := range ops.Subnets {
+		sPath := opsPath.Child("subnets").Index(i)
+		if s.NetworkID == "" {
+			allErrs = append(allErrs, field.Required(sPath.Child("networkID"), ""))
+		}
+		if s.SubnetID == "" {
+			allErrs = append(allErrs, field.Required(sPath.Child("subnetID"), ""))
+		}
+		if racks[s.RackTag] {
+			allErrs = append(allErrs, field.Duplicate(sPath.Child("rackTag"), s.RackTag))
+		}
+		racks[s.RackTag] = true
+	}
+	return allErrs
+}
+
+func (d *Driver) EnsureInstance(ctx context.Context, c *common.Cluster, spec *infra.InstanceSpec) (*infra.Instance, error) {
+	ops := c.Spec.Infrastructure.OpenStack
+	cli, err := d.client(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	// the port is created first so the ip is allocated from the subnet of the rack
+	p, err := cli.findPort(spec.Name)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		subnet := subnetOf(ops, spec.Machine)
+		opts := ports.CreateOpts{
+			Name:      spec.Name,
+			NetworkID: subnet.NetworkID,
+			FixedIPs:  []ports.IP{{SubnetID: subnet.SubnetID}},
+		}
+		if len(ops.SecurityGroups) > 0 {
+			opts.SecurityGroups = &ops.SecurityGroups
+		}
+		p, err = cli.createPort(opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(p.FixedIPs) == 0 {
+		return nil, fmt.Errorf("port: %s has no fixed ip", spec.Name)
+	}
+
+	s, err := cli.findServer(spec.Name)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		s, err = cli.createServer(servers.CreateOpts{
+			Name:             spec.Name,
+			FlavorRef:        ops.Flavor,
+			ImageRef:         ops.Image,
+			AvailabilityZone: ops.AvailabilityZone,
+			Networks:         []servers.Network{{Port: p.ID}},
+			Metadata:         map[string]string{clusterMetadata: c.Name},
+		}, ops.KeyName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := serverFailed(s); err != nil {
+		return nil, err
+	}
+
+	instance := &infra.Instance{ID: s.ID, Name: spec.Name}
+	if s.Status == "ACTIVE" {
+		instance.IP = p.FixedIPs[0].IPAddress
+	}
+	return instance, nil
+}
+
+func (d *Driver) DeleteInstance(ctx context.Context, c *common.Cluster, name string) error {
+	cli, err := d.client(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	s, err := cli.findServer(name)
+	if err != nil {
+		return err
+	}
+	if s != nil {
+		if err := cli.deleteServer(s.ID); err != nil {
+			return err
+		}
+	}
+	p, err := cli.findPort(name)
+	if err != nil || p == nil {
+		return err
+	}
+	return cli.deletePort(p.ID)
+}
+
+// EnsureEndpoint creates the load balancer of the apiservers of the masters with ip, its listener, pool and
+// health monitor are created once it's active, and then the members are replaced if the masters changed.
+func (d *Driver) EnsureEndpoint(ctx context.Context, c *common.Cluster) (*infra.Endpoint, error) {
+	ops := c.Spec.Infrastructure.OpenStack
+	if ops.LoadBalancer == nil {
+		return nil, nil
+	}
+	cli, err := d.client(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	var members []member
+	for _, m := range c.Spec.Machines {
+		if m != nil && m.IP != "" {
+			members = append(members, member{Address: m.IP, ProtocolPort: apiserverPort, SubnetID: subnetOf(ops, m).SubnetID})
+		}
+	}
+
+	name := loadBalancerName(c.Name)
+	lb, err := cli.findLoadBalancer(name)
+	if err != nil {
+		return nil, err
+	}
+	pending := &infra.Endpoint{Port: apiserverPort}
+	if lb == nil {
+		subnetID := ops.LoadBalancer.SubnetID
+		if subnetID == "" {
+			subnetID = ops.Subnets[0].SubnetID
+		}
+		return pending, cli.createLoadBalancer(name, subnetID)
+	}
+	switch lb.ProvisioningStatus {
+	case lbActive:
+	case lbError:
+		return nil, fmt.Errorf("load balancer: %s is in error", name)
+	default:
+		return pending, nil
+	}
+
+	pool, err := cli.ensurePool(lb, apiserverPort)
+	if err != nil || pool == "" {
+		return pending, err
+	}
+	current, err := cli.listMembers(pool)
+	if err != nil {
+		return nil, err
+	}
+	if !sameMembers(current, members) {
+		return pending, cli.replaceMembers(pool, members)
+	}
+	return &infra.Endpoint{Host: lb.VipAddress, Port: apiserverPort}, nil
+}
+
+func (d *Driver) DeleteEndpoint(ctx context.Context, c *common.Cluster) error {
+	if c.Spec.Infrastructure.OpenStack.LoadBalancer == nil {
+		return nil
+	}
+	cli, err := d.client(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	lb, err := cli.findLoadBalancer(loadBalancerName(c.Name))
+	if err != nil || lb == nil {
+		return err
+	}
+	return cli.deleteLoadBalancer(lb.ID)
+}
+
+// client returns the client of the keystone of the cluster with the credential of the infrastructure secret.
+func (d *Driver) client(ctx context.Context, c *common.Cluster) (*client, error) {
+	ops := c.Spec.Infrastructure.OpenStack
+	s, err := infra.Secret(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	cred := credential{domain: string(s.Data[domainNameKey])}
+	if cred.domain == "" {
+		cred.domain = defaultDomain
+	}
+	for key, value := range map[string]*string{usernameKey: &cred.username, passwordKey: &cred.password, projectIDKey: &cred.projectID} {
+		*value, err = infra.SecretValue(s, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the updated credential gets a new client
+	key := fmt.Sprintf("%s\x00%s\x00%t\x00%+v", ops.AuthURL, ops.Region, ops.Insecure, cred)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cli, ok := d.clients[key]
+	if !ok {
+		cli = newClient(ops.AuthURL, ops.Region, ops.Insecure, cred)
+		d.clients[key] = cli
+	}
+	return cli, nil
+}
+
+// subnetOf returns the subnet of the rack of the machine, or else the first subnet.
+func subnetOf(ops *devopsv1.OpenStackInfrastructure, m *devopsv1.ClusterMachine) devopsv1.OpenStackSubnet {
+	if m != nil && m.HostCni != nil && m.HostCni.RackTag != "" {
+		for _, s := range ops.Subnets {
+			if s.RackTag == m.HostCni.RackTag {
+				return s
+			}
+		}
+	}
+	return ops.Subnets[0]
+}
+
+func loadBalancerName(cluster string) string {
+	return cluster + "-apiserver"
+}
@@ -0,0 +1,347 @@
+package openstack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/infra"
+	"github.com/gostship/kunkka/pkg/provider/providertest"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// fakeOpenStack serves the subset of the keystone, nova, neutron and octavia APIs used by gophercloud, the
+// servers are active on their first read and the load balancers on their second one.
+type fakeOpenStack struct {
+	url string
+
+	mu       sync.Mutex
+	tokens   int
+	ports    map[string]*ports.Port
+	servers  map[string]map[string]string
+	keyNames []string
+	reads    map[string]int
+	lbs      map[string]*fakeLoadBalancer
+	members  []map[string]interface{}
+}
+
+type fakeLoadBalancer struct {
+	id        string
+	name      string
+	status    string
+	listeners int
+	pools     int
+	monitors  int
+}
+
+func (f *fakeOpenStack) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	reply := func(status int, body interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if body != nil {
+			json.NewEncoder(w).Encode(body)
+		}
+	}
+
+	if r.URL.Path == "/identity/v3/auth/tokens" {
+		f.tokens++
+		w.Header().Set("X-Subject-Token", fmt.Sprintf("token-%d", f.tokens))
+		catalog := []map[string]interface{}{}
+		for service, path := range map[string]string{"compute": "/compute/v2.1", "network": "/network", "load-balancer": "/lb"} {
+			catalog = append(catalog, map[string]interface{}{
+				"type": service,
+				"endpoints": []map[string]string{
+					{"id": "1", "interface": "internal", "region": "RegionOne", "region_id": "RegionOne", "url": "http://internal"},
+					{"id": "2", "interface": "public", "region": "RegionTwo", "region_id": "RegionTwo", "url": "http://other"},
+					{"id": "3", "interface": "public", "region": "RegionOne", "region_id": "RegionOne", "url": f.url + path},
+				},
+			})
+		}
+		reply(http.StatusCreated, map[string]interface{}{
+			"token": map[string]interface{}{"expires_at": time.Now().Add(time.Hour), "catalog": catalog},
+		})
+		return
+	}
+	// the tokens before the last one are revoked
+	if r.Header.Get("X-Auth-Token") != fmt.Sprintf("token-%d", f.tokens) {
+		reply(http.StatusUnauthorized, nil)
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/network/v2.0/ports":
+		found := []map[string]interface{}{}
+		if p, ok := f.ports[name]; ok {
+			found = append(found, portJSON(p))
+		}
+		reply(http.StatusOK, map[string]interface{}{"ports": found})
+	case r.Method == http.MethodPost && r.URL.Path == "/network/v2.0/ports":
+		in := struct{ Port *ports.Port }{}
+		json.NewDecoder(r.Body).Decode(&in)
+		p := in.Port
+		p.ID = "port-" + p.Name
+		p.FixedIPs[0].IPAddress = fmt.Sprintf("10.0.%s.%d", strings.TrimPrefix(p.FixedIPs[0].SubnetID, "subnet-"), len(f.ports)+1)
+		f.ports[p.Name] = p
+		reply(http.StatusCreated, map[string]interface{}{"port": portJSON(p)})
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/network/v2.0/ports/"):
+		delete(f.ports, strings.TrimPrefix(r.URL.Path, "/network/v2.0/ports/port-"))
+		reply(http.StatusNoContent, nil)
+	case r.Method == http.MethodGet && r.URL.Path == "/compute/v2.1/servers/detail":
+		found := []map[string]string{}
+		for n, s := range f.servers {
+			if "^"+n+"$" == name {
+				s["status"] = "ACTIVE"
+				found = append(found, s)
+			}
+		}
+		reply(http.StatusOK, map[string]interface{}{"servers": found})
+	case r.Method == http.MethodPost && r.URL.Path == "/compute/v2.1/servers":
+		in := struct {
+			Server struct {
+				Name     string `json:"name"`
+				KeyName  string `json:"key_name"`
+				Networks []struct {
+					Port string `json:"port"`
+				} `json:"networks"`
+			} `json:"server"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&in)
+		if len(in.Server.Networks) != 1 || in.Server.Networks[0].Port != "port-"+in.Server.Name {
+			reply(http.StatusBadRequest, nil)
+			return
+		}
+		f.servers[in.Server.Name] = map[string]string{"id": "server-" + in.Server.Name, "name": in.Server.Name, "status": "BUILD"}
+		f.keyNames = append(f.keyNames, in.Server.KeyName)
+		reply(http.StatusAccepted, map[string]interface{}{"server": map[string]string{"id": "server-" + in.Server.Name}})
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/compute/v2.1/servers/"):
+		delete(f.servers, strings.TrimPrefix(r.URL.Path, "/compute/v2.1/servers/server-"))
+		reply(http.StatusNoContent, nil)
+	case r.Method == http.MethodGet && r.URL.Path == "/lb/v2.0/lbaas/loadbalancers":
+		found := []map[string]interface{}{}
+		if lb, ok := f.lbs[name]; ok {
+			f.reads[name]++
+			if f.reads[name] > 1 {
+				lb.status = lbActive
+			}
+			listeners, pools := []map[string]string{}, []map[string]string{}
+			if lb.listeners > 0 {
+				listeners = append(listeners, map[string]string{"id": "listener-1"})
+			}
+			if lb.pools > 0 {
+				pools = append(pools, map[string]string{"id": "pool-1"})
+			}
+			found = append(found, map[string]interface{}{
+				"id": lb.id, "name": lb.name, "vip_address": "10.0.1.100", "provisioning_status": lb.status,
+				"listeners": listeners, "pools": pools,
+			})
+		}
+		reply(http.StatusOK, map[string]interface{}{"loadbalancers": found})
+	case r.Method == http.MethodPost && r.URL.Path == "/lb/v2.0/lbaas/loadbalancers":
+		in := struct {
+			LoadBalancer struct {
+				Name        string `json:"name"`
+				VipSubnetID string `json:"vip_subnet_id"`
+			} `json:"loadbalancer"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&in)
+		if in.LoadBalancer.VipSubnetID != "subnet-1" {
+			reply(http.StatusBadRequest, nil)
+			return
+		}
+		f.lbs[in.LoadBalancer.Name] = &fakeLoadBalancer{id: "lb-1", name: in.LoadBalancer.Name, status: "PENDING_CREATE"}
+		reply(http.StatusCreated, map[string]interface{}{"loadbalancer": map[string]string{"id": "lb-1"}})
+	case r.Method == http.MethodPost && r.URL.Path == "/lb/v2.0/lbaas/listeners":
+		f.lbs["dke-apiserver"].listeners++
+		reply(http.StatusCreated, map[string]interface{}{"listener": map[string]string{"id": "listener-1"}})
+	case r.Method == http.MethodPost && r.URL.Path == "/lb/v2.0/lbaas/pools":
+		f.lbs["dke-apiserver"].pools++
+		reply(http.StatusCreated, map[string]interface{}{"pool": map[string]string{"id": "pool-1"}})
+	case r.Method == http.MethodGet && r.URL.Path == "/lb/v2.0/lbaas/pools/pool-1":
+		monitor := ""
+		if f.lbs["dke-apiserver"].monitors > 0 {
+			monitor = "monitor-1"
+		}
+		reply(http.StatusOK, map[string]interface{}{"pool": map[string]string{"id": "pool-1", "healthmonitor_id": monitor}})
+	case r.Method == http.MethodPost && r.URL.Path == "/lb/v2.0/lbaas/healthmonitors":
+		f.lbs["dke-apiserver"].monitors++
+		reply(http.StatusCreated, map[string]interface{}{"healthmonitor": map[string]string{"id": "monitor-1"}})
+	case r.URL.Path == "/lb/v2.0/lbaas/pools/pool-1/members":
+		if r.Method == http.MethodPut {
+			in := struct {
+				Members []map[string]interface{} `json:"members"`
+			}{}
+			json.NewDecoder(r.Body).Decode(&in)
+			f.members = in.Members
+			reply(http.StatusAccepted, nil)
+			return
+		}
+		reply(http.StatusOK, map[string]interface{}{"members": f.members})
+	case r.Method == http.MethodDelete && r.URL.Path == "/lb/v2.0/lbaas/loadbalancers/lb-1":
+		if r.URL.Query().Get("cascade") != "true" {
+			reply(http.StatusConflict, nil)
+			return
+		}
+		f.lbs = map[string]*fakeLoadBalancer{}
+		reply(http.StatusNoContent, nil)
+	default:
+		reply(http.StatusNotFound, nil)
+	}
+}
+
+func portJSON(p *ports.Port) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         p.ID,
+		"name":       p.Name,
+		"network_id": p.NetworkID,
+		"fixed_ips":  []map[string]string{{"subnet_id": p.FixedIPs[0].SubnetID, "ip_address": p.FixedIPs[0].IPAddress}},
+	}
+}
+
+func newCluster(authURL string) *common.Cluster {
+	return providertest.NewInfraCluster(ProviderName, &devopsv1.Infrastructure{
+		SecretName: "openstack",
+		OpenStack: &devopsv1.OpenStackInfrastructure{
+			AuthURL: authURL,
+			Region:  "RegionOne",
+			Flavor:  "m1.large",
+			Image:   "centos",
+			KeyName: "ops",
+			Subnets: []devopsv1.OpenStackSubnet{
+				{NetworkID: "net", SubnetID: "subnet-1"},
+				{RackTag: "rack-b", NetworkID: "net", SubnetID: "subnet-2"},
+			},
+			LoadBalancer: &devopsv1.OpenStackLoadBalancer{},
+		},
+	}, map[string][]byte{
+		usernameKey:  []byte("admin"),
+		passwordKey:  []byte("secret"),
+		projectIDKey: []byte("project"),
+	})
+}
+
+func TestDriver(t *testing.T) {
+	fos := &fakeOpenStack{
+		ports:   make(map[string]*ports.Port),
+		servers: make(map[string]map[string]string),
+		reads:   make(map[string]int),
+		lbs:     make(map[string]*fakeLoadBalancer),
+	}
+	server := httptest.NewServer(fos)
+	defer server.Close()
+	fos.url = server.URL
+
+	ctx := context.Background()
+	c := newCluster(server.URL + "/identity/v3")
+	d := NewDriver()
+	master := &devopsv1.ClusterMachine{HostCni: &devopsv1.ClusterCni{RackTag: "rack-b"}}
+	spec := &infra.InstanceSpec{Name: infra.MasterName(c.Name, 0), Role: infra.RoleMaster, Machine: master}
+
+	instance, err := d.EnsureInstance(ctx, c, spec)
+	if err != nil {
+		t.Fatalf("EnsureInstance() error = %v", err)
+	}
+	if instance.ID != "server-dke-master-0" || instance.IP != "" {
+		t.Errorf("EnsureInstance() = %+v, want server-dke-master-0 building", instance)
+	}
+	if len(fos.keyNames) != 1 || fos.keyNames[0] != "ops" {
+		t.Errorf("key names = %v, want ops", fos.keyNames)
+	}
+
+	// the revoked token is issued again
+	fos.tokens++
+	instance, err = d.EnsureInstance(ctx, c, spec)
+	if err != nil {
+		t.Fatalf("EnsureInstance() error = %v", err)
+	}
+	if instance.IP != "10.0.2.1" {
+		t.Errorf("EnsureInstance() ip = %s, want 10.0.2.1 of the subnet of the rack", instance.IP)
+	}
+	if len(fos.servers) != 1 || len(fos.ports) != 1 {
+		t.Errorf("servers = %v, ports = %v, want the ones found by name", fos.servers, fos.ports)
+	}
+
+	// the load balancer, the listener, the pool, the health monitor and the members take a reconcile each
+	master.IP = instance.IP
+	c.Spec.Machines = []*devopsv1.ClusterMachine{master}
+	var ep *infra.Endpoint
+	for i := 0; i < 10 && (ep == nil || ep.Host == ""); i++ {
+		ep, err = d.EnsureEndpoint(ctx, c)
+		if err != nil || ep == nil {
+			t.Fatalf("EnsureEndpoint() = %+v, %v", ep, err)
+		}
+	}
+	if ep.Host != "10.0.1.100" || ep.Port != apiserverPort {
+		t.Fatalf("EnsureEndpoint() = %+v, want the vip", ep)
+	}
+	lb := fos.lbs["dke-apiserver"]
+	if lb.listeners != 1 || lb.pools != 1 || lb.monitors != 1 {
+		t.Errorf("load balancer = %+v, want one listener, pool and health monitor", lb)
+	}
+	if len(fos.members) != 1 || fos.members[0]["address"] != "10.0.2.1" || fos.members[0]["subnet_id"] != "subnet-2" {
+		t.Errorf("members = %+v", fos.members)
+	}
+
+	// the members are replaced once the masters change
+	c.Spec.Machines = append(c.Spec.Machines, &devopsv1.ClusterMachine{IP: "10.0.1.9"})
+	ep, err = d.EnsureEndpoint(ctx, c)
+	if err != nil || ep == nil || ep.Host != "" {
+		t.Fatalf("EnsureEndpoint() = %+v, %v, want pending", ep, err)
+	}
+	if len(fos.members) != 2 || fos.members[1]["subnet_id"] != "subnet-1" {
+		t.Errorf("members = %+v, want the new master in the first subnet", fos.members)
+	}
+	ep, err = d.EnsureEndpoint(ctx, c)
+	if err != nil || ep == nil || ep.Host != "10.0.1.100" {
+		t.Fatalf("EnsureEndpoint() = %+v, %v, want the vip", ep, err)
+	}
+
+	if err := d.DeleteInstance(ctx, c, spec.Name); err != nil {
+		t.Fatalf("DeleteInstance() error = %v", err)
+	}
+	if err := d.DeleteEndpoint(ctx, c); err != nil {
+		t.Fatalf("DeleteEndpoint() error = %v", err)
+	}
+	if len(fos.servers) != 0 || len(fos.ports) != 0 || len(fos.lbs) != 0 {
+		t.Errorf("servers = %v, ports = %v, lbs = %v, want deleted", fos.servers, fos.ports, fos.lbs)
+	}
+	if err := d.DeleteInstance(ctx, c, spec.Name); err != nil {
+		t.Errorf("DeleteInstance() of the deleted server error = %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	d := NewDriver()
+	subnets := []devopsv1.OpenStackSubnet{{NetworkID: "net", SubnetID: "subnet"}}
+	tests := []struct {
+		name string
+		ops  *devopsv1.OpenStackInfrastructure
+		errs int
+	}{
+		{name: "valid", ops: &devopsv1.OpenStackInfrastructure{AuthURL: "https://keystone/v3", Flavor: "f", Image: "i", Subnets: subnets}},
+		{name: "no openstack", errs: 1},
+		{name: "missing", ops: &devopsv1.OpenStackInfrastructure{AuthURL: "keystone"}, errs: 4},
+		{name: "duplicate rack", ops: &devopsv1.OpenStackInfrastructure{AuthURL: "https://keystone/v3", Flavor: "f", Image: "i",
+			Subnets: append(subnets, devopsv1.OpenStackSubnet{SubnetID: "subnet"})}, errs: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := d.Validate(&devopsv1.Infrastructure{SecretName: "openstack", OpenStack: tt.ops}, field.NewPath("spec", "infrastructure"))
+			if len(errs) != tt.errs {
+				t.Errorf("Validate() = %v, want %d errors", errs, tt.errs)
+			}
+		})
+	}
+}
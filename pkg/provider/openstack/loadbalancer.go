@@ -0,0 +1,180 @@
+package openstack
+
+import (
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/gophercloud/gophercloud/openstack/loadbalancer/v2/listeners"
+	"github.com/gophercloud/gophercloud/openstack/loadbalancer/v2/loadbalancers"
+	"github.com/gophercloud/gophercloud/openstack/loadbalancer/v2/monitors"
+	"github.com/gophercloud/gophercloud/openstack/loadbalancer/v2/pools"
+	"github.com/pkg/errors"
+)
+
+const (
+	lbActive = "ACTIVE"
+	lbError  = "ERROR"
+)
+
+type member struct {
+	Address      string
+	ProtocolPort int
+	SubnetID     string
+}
+
+// findLoadBalancer returns the load balancer of the name, it's nil if not found.
+func (c *client) findLoadBalancer(name string) (*loadbalancers.LoadBalancer, error) {
+	sc, err := c.loadBalancer()
+	if err != nil {
+		return nil, err
+	}
+	pages, err := loadbalancers.List(sc, loadbalancers.ListOpts{Name: name}).AllPages()
+	if err != nil {
+		return nil, errors.Wrapf(err, "list load balancer: %s", name)
+	}
+	all, err := loadbalancers.ExtractLoadBalancers(pages)
+	if err != nil {
+		return nil, errors.Wrapf(err, "list load balancer: %s", name)
+	}
+	for i := range all {
+		if all[i].Name == name {
+			return &all[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *client) createLoadBalancer(name, subnetID string) error {
+	sc, err := c.loadBalancer()
+	if err != nil {
+		return err
+	}
+	_, err = loadbalancers.Create(sc, loadbalancers.CreateOpts{Name: name, VipSubnetID: subnetID}).Extract()
+	if err != nil {
+		return errors.Wrapf(err, "create load balancer: %s", name)
+	}
+	return nil
+}
+
+// ensurePool creates the tcp listener, its pool and the health monitor of the pool of the active load balancer
+// one at a time, as the load balancer is immutable until each of them is provisioned. The id of the pool is
+// returned once they're all created.
+func (c *client) ensurePool(lb *loadbalancers.LoadBalancer, port int) (string, error) {
+	sc, err := c.loadBalancer()
+	if err != nil {
+		return "", err
+	}
+
+	if len(lb.Listeners) == 0 {
+		_, err := listeners.Create(sc, listeners.CreateOpts{
+			Name:           lb.Name,
+			LoadbalancerID: lb.ID,
+			Protocol:       listeners.ProtocolTCP,
+			ProtocolPort:   port,
+		}).Extract()
+		return "", errors.Wrapf(err, "create listener of load balancer: %s", lb.Name)
+	}
+	if len(lb.Pools) == 0 {
+		_, err := pools.Create(sc, pools.CreateOpts{
+			Name:       lb.Name,
+			ListenerID: lb.Listeners[0].ID,
+			Protocol:   pools.ProtocolTCP,
+			LBMethod:   pools.LBMethodRoundRobin,
+		}).Extract()
+		return "", errors.Wrapf(err, "create pool of load balancer: %s", lb.Name)
+	}
+
+	pool, err := pools.Get(sc, lb.Pools[0].ID).Extract()
+	if err != nil {
+		return "", errors.Wrapf(err, "get pool: %s", lb.Pools[0].ID)
+	}
+	if pool.MonitorID == "" {
+		_, err := monitors.Create(sc, monitors.CreateOpts{
+			Name:       lb.Name,
+			PoolID:     pool.ID,
+			Type:       monitors.TypeTCP,
+			Delay:      5,
+			Timeout:    5,
+			MaxRetries: 3,
+		}).Extract()
+		return "", errors.Wrapf(err, "create health monitor of pool: %s", pool.ID)
+	}
+	return pool.ID, nil
+}
+
+func (c *client) listMembers(pool string) ([]member, error) {
+	sc, err := c.loadBalancer()
+	if err != nil {
+		return nil, err
+	}
+	pages, err := pools.ListMembers(sc, pool, pools.ListMembersOpts{}).AllPages()
+	if err != nil {
+		return nil, errors.Wrapf(err, "list members of pool: %s", pool)
+	}
+	all, err := pools.ExtractMembers(pages)
+	if err != nil {
+		return nil, errors.Wrapf(err, "list members of pool: %s", pool)
+	}
+	members := make([]member, 0, len(all))
+	for _, m := range all {
+		members = append(members, member{Address: m.Address, ProtocolPort: m.ProtocolPort, SubnetID: m.SubnetID})
+	}
+	return members, nil
+}
+
+// replaceMembers replaces the members of the pool with the batch update.
+func (c *client) replaceMembers(pool string, members []member) error {
+	sc, err := c.loadBalancer()
+	if err != nil {
+		return err
+	}
+	opts := make([]pools.BatchUpdateMemberOpts, 0, len(members))
+	for i := range members {
+		m := pools.BatchUpdateMemberOpts{Address: members[i].Address, ProtocolPort: members[i].ProtocolPort}
+		if members[i].SubnetID != "" {
+			m.SubnetID = &members[i].SubnetID
+		}
+		opts = append(opts, m)
+	}
+	err = pools.BatchUpdateMembers(sc, pool, opts).ExtractErr()
+	if err != nil {
+		return errors.Wrapf(err, "update members of pool: %s", pool)
+	}
+	return nil
+}
+
+// deleteLoadBalancer deletes the load balancer with its listeners and pools.
+func (c *client) deleteLoadBalancer(id string) error {
+	sc, err := c.loadBalancer()
+	if err != nil {
+		return err
+	}
+	err = loadbalancers.Delete(sc, id, loadbalancers.DeleteOpts{Cascade: true}).ExtractErr()
+	if err != nil && !isNotFound(err) {
+		return errors.Wrapf(err, "delete load balancer: %s", id)
+	}
+	return nil
+}
+
+// sameMembers returns true if the members have the same addresses and ports.
+func sameMembers(a, b []member) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	key := func(ms []member) []string {
+		keys := make([]string, 0, len(ms))
+		for _, m := range ms {
+			keys = append(keys, net.JoinHostPort(m.Address, strconv.Itoa(m.ProtocolPort)))
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	ka, kb := key(a), key(b)
+	for i := range ka {
+		if ka[i] != kb[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,36 @@
+// Package openstack provides the clusters whose machines are the Nova servers on the Neutron ports of the subnets
+// of their racks, the apiservers are balanced by an optional Octavia load balancer.
+package openstack
+
+import (
+	clusterprovider "github.com/gostship/kunkka/pkg/provider/cluster"
+	"github.com/gostship/kunkka/pkg/provider/config"
+	"github.com/gostship/kunkka/pkg/provider/infra"
+	machineprovider "github.com/gostship/kunkka/pkg/provider/machine"
+	"k8s.io/klog"
+)
+
+const ProviderName = "OpenStack"
+
+// driver is shared by the cluster and machine providers, so are the tokens of the keystones
+var driver = NewDriver()
+
+func AddCluster(mgr *clusterprovider.CpManager, cfg *config.Config) error {
+	p, err := infra.NewClusterProvider(mgr, cfg, ProviderName, driver)
+	if err != nil {
+		klog.Errorf("init cluster provider error: %s", err)
+		return err
+	}
+	mgr.Register(p.Name(), p)
+	return nil
+}
+
+func AddMachine(mgr *machineprovider.MpManager, cfg *config.Config) error {
+	p, err := infra.NewMachineProvider(mgr, cfg, ProviderName, driver)
+	if err != nil {
+		klog.Errorf("init machine provider error: %s", err)
+		return err
+	}
+	mgr.Register(p.Name(), p)
+	return nil
+}
@@ -0,0 +1,113 @@
+package openstack
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/keypairs"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/pkg/errors"
+)
+
+// findPort returns the port of the name, it's nil if not found.
+func (c *client) findPort(name string) (*ports.Port, error) {
+	network, err := c.network()
+	if err != nil {
+		return nil, err
+	}
+	pages, err := ports.List(network, ports.ListOpts{Name: name}).AllPages()
+	if err != nil {
+		return nil, errors.Wrapf(err, "list port: %s", name)
+	}
+	all, err := ports.ExtractPorts(pages)
+	if err != nil {
+		return nil, errors.Wrapf(err, "list port: %s", name)
+	}
+	for i := range all {
+		if all[i].Name == name {
+			return &all[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// createPort creates the port with an ip allocated from the subnet.
+func (c *client) createPort(opts ports.CreateOpts) (*ports.Port, error) {
+	network, err := c.network()
+	if err != nil {
+		return nil, err
+	}
+	p, err := ports.Create(network, opts).Extract()
+	if err != nil {
+		return nil, errors.Wrapf(err, "create port: %s", opts.Name)
+	}
+	return p, nil
+}
+
+func (c *client) deletePort(id string) error {
+	network, err := c.network()
+	if err != nil {
+		return err
+	}
+	err = ports.Delete(network, id).ExtractErr()
+	if err != nil && !isNotFound(err) {
+		return errors.Wrapf(err, "delete port: %s", id)
+	}
+	return nil
+}
+
+// findServer returns the server of the name, it's nil if not found.
+func (c *client) findServer(name string) (*servers.Server, error) {
+	compute, err := c.compute()
+	if err != nil {
+		return nil, err
+	}
+	// the name filter of nova is a regular expression
+	pages, err := servers.List(compute, servers.ListOpts{Name: "^" + regexp.QuoteMeta(name) + "$"}).AllPages()
+	if err != nil {
+		return nil, errors.Wrapf(err, "list server: %s", name)
+	}
+	all, err := servers.ExtractServers(pages)
+	if err != nil {
+		return nil, errors.Wrapf(err, "list server: %s", name)
+	}
+	for i := range all {
+		if all[i].Name == name {
+			return &all[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *client) createServer(opts servers.CreateOpts, keyName string) (*servers.Server, error) {
+	compute, err := c.compute()
+	if err != nil {
+		return nil, err
+	}
+	s, err := servers.Create(compute, keypairs.CreateOptsExt{CreateOptsBuilder: opts, KeyName: keyName}).Extract()
+	if err != nil {
+		return nil, errors.Wrapf(err, "create server: %s", opts.Name)
+	}
+	s.Name = opts.Name
+	return s, nil
+}
+
+func (c *client) deleteServer(id string) error {
+	compute, err := c.compute()
+	if err != nil {
+		return err
+	}
+	err = servers.Delete(compute, id).ExtractErr()
+	if err != nil && !isNotFound(err) {
+		return errors.Wrapf(err, "delete server: %s", id)
+	}
+	return nil
+}
+
+func serverFailed(s *servers.Server) error {
+	if s.Status != "ERROR" {
+		return nil
+	}
+	return fmt.Errorf("server: %s is in error: %s", s.Name, s.Fault.Message)
+}
@@ -0,0 +1,89 @@
+package bootstraptoken
+
+import (
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	bootstrapapi "k8s.io/cluster-bootstrap/token/api"
+	bootstraputil "k8s.io/cluster-bootstrap/token/util"
+)
+
+const (
+	// NodeKubeletBootstrap the binding allowing the bootstrap token to request the client certificates
+	NodeKubeletBootstrap = "kubeadm:kubelet-bootstrap"
+	// NodeAutoApproveBootstrap the binding approving the client certificates requested with the bootstrap token
+	NodeAutoApproveBootstrap = "kubeadm:node-autoapprove-bootstrap"
+	// NodeAutoApproveCertificateRotation the binding approving the renewals of the node client certificates
+	NodeAutoApproveCertificateRotation = "kubeadm:node-autoapprove-certificate-rotation"
+
+	nodeBootstrapperClusterRole = "system:node-bootstrapper"
+	nodeClientClusterRole       = "system:certificates.k8s.io:certificatesigningrequests:nodeclient"
+	nodeSelfClientClusterRole   = "system:certificates.k8s.io:certificatesigningrequests:selfnodeclient"
+	nodesGroup                  = "system:nodes"
+	bootstrapTokenDescription   = "the bootstrap token of the nodes joined by kunkka"
+)
+
+// BuildBootstrapObjs returns the bootstrap token secret and the bindings which let the kubelets join the cluster
+// with the token: the kubelet requests its client certificate, the CSR is approved by the controller-manager,
+// and the Node authorizer and the NodeRestriction admission limit the node to its own objects.
+func BuildBootstrapObjs(token string) ([]runtime.Object, error) {
+	subs := bootstraputil.BootstrapTokenRegexp.FindStringSubmatch(token)
+	if len(subs) != 3 {
+		return nil, errors.Errorf("invalid bootstrap token")
+	}
+
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      bootstraputil.BootstrapTokenSecretName(subs[1]),
+			Namespace: metav1.NamespaceSystem,
+		},
+		Type: bootstrapapi.SecretTypeBootstrapToken,
+		Data: map[string][]byte{
+			bootstrapapi.BootstrapTokenDescriptionKey:      []byte(bootstrapTokenDescription),
+			bootstrapapi.BootstrapTokenIDKey:               []byte(subs[1]),
+			bootstrapapi.BootstrapTokenSecretKey:           []byte(subs[2]),
+			bootstrapapi.BootstrapTokenUsageAuthentication: []byte("true"),
+			bootstrapapi.BootstrapTokenUsageSigningKey:     []byte("true"),
+			bootstrapapi.BootstrapTokenExtraGroupsKey:      []byte(constants.NodeBootstrapTokenAuthGroup),
+		},
+	}
+
+	objs := []runtime.Object{
+		secret,
+		buildGroupBinding(NodeKubeletBootstrap, nodeBootstrapperClusterRole, constants.NodeBootstrapTokenAuthGroup),
+		buildGroupBinding(NodeAutoApproveBootstrap, nodeClientClusterRole, constants.NodeBootstrapTokenAuthGroup),
+		buildGroupBinding(NodeAutoApproveCertificateRotation, nodeSelfClientClusterRole, nodesGroup),
+	}
+	return objs, nil
+}
+
+func buildGroupBinding(name, role, group string) *rbacv1.ClusterRoleBinding {
+	return &rbacv1.ClusterRoleBinding{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "rbac.authorization.k8s.io/v1",
+			Kind:       "ClusterRoleBinding",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     role,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				APIGroup: rbacv1.GroupName,
+				Kind:     rbacv1.GroupKind,
+				Name:     group,
+			},
+		},
+	}
+}
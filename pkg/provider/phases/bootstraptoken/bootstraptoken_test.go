@@ -0,0 +1,59 @@
+package bootstraptoken
+
+import (
+	"testing"
+
+	"github.com/gostship/kunkka/pkg/constants"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	bootstrapapi "k8s.io/cluster-bootstrap/token/api"
+)
+
+func TestBuildBootstrapObjs(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "valid", token: "abcdef.0123456789abcdef"},
+		{name: "invalid", token: "admin-token", wantErr: true},
+		{name: "empty", token: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs, err := BuildBootstrapObjs(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BuildBootstrapObjs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			secret := objs[0].(*corev1.Secret)
+			if secret.Name != "bootstrap-token-abcdef" || secret.Type != bootstrapapi.SecretTypeBootstrapToken {
+				t.Errorf("unexpected secret: %s type: %s", secret.Name, secret.Type)
+			}
+			if string(secret.Data[bootstrapapi.BootstrapTokenSecretKey]) != "0123456789abcdef" ||
+				string(secret.Data[bootstrapapi.BootstrapTokenExtraGroupsKey]) != constants.NodeBootstrapTokenAuthGroup {
+				t.Errorf("unexpected secret data: %v", secret.Data)
+			}
+
+			bindings := map[string]string{}
+			for _, obj := range objs[1:] {
+				b := obj.(*rbacv1.ClusterRoleBinding)
+				bindings[b.Name] = b.Subjects[0].Name
+			}
+			want := map[string]string{
+				NodeKubeletBootstrap:               constants.NodeBootstrapTokenAuthGroup,
+				NodeAutoApproveBootstrap:           constants.NodeBootstrapTokenAuthGroup,
+				NodeAutoApproveCertificateRotation: nodesGroup,
+			}
+			for name, group := range want {
+				if bindings[name] != group {
+					t.Errorf("binding: %s subject: %q, want: %q", name, bindings[name], group)
+				}
+			}
+		})
+	}
+}
@@ -2,17 +2,21 @@ package certs
 
 import (
 	"crypto"
+	"crypto/tls"
 	"crypto/x509"
 
 	kubeadmv1beta2 "github.com/gostship/kunkka/pkg/apis/kubeadm/v1beta2"
 	"github.com/gostship/kunkka/pkg/util/pkiutil"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/klog"
 )
 
 type CaAll struct {
 	CaCert *x509.Certificate
 	CaKey  crypto.Signer
+	// Issuer issues the certs of the CA instead of CaKey, e.g. the CA kept in vault
+	Issuer Issuer
 	Cfg    *KubeadmCert
 }
 
@@ -62,7 +66,11 @@ func CreateCertAndKeyFilesWithCA(certSpec *KubeadmCert, ca *CaAll, cfg *kubeadmv
 		return errors.Wrapf(err, "couldn't create %q certificate", certSpec.Name)
 	}
 
-	cert, key, err := pkiutil.NewCertAndKey(ca.CaCert, ca.CaKey, certConfig)
+	issuer := ca.Issuer
+	if issuer == nil {
+		issuer = NewLocalIssuer(ca.CaCert, ca.CaKey)
+	}
+	cert, key, err := issuer.Issue(certSpec.Name, certConfig)
 	if err != nil {
 		return err
 	}
@@ -107,3 +115,55 @@ func CreateServiceAccountKeyAndPublicKeyFiles(certsDir string, keyType x509.Publ
 	certsMaps[publicPath] = publicByte
 	return nil
 }
+
+// LoadExternalCA loads the CA of the tls Secret, tls.crt is the CA optionally followed by its chain.
+// It returns the CA, its key and the PEM bundle of the chain.
+func LoadExternalCA(s *corev1.Secret) (*x509.Certificate, crypto.Signer, []byte, error) {
+	bundle := s.Data[corev1.TLSCertKey]
+	keyPEM := s.Data[corev1.TLSPrivateKeyKey]
+	if len(bundle) == 0 || len(keyPEM) == 0 {
+		return nil, nil, nil, errors.Errorf("secret: %s/%s must have %s and %s", s.Namespace, s.Name, corev1.TLSCertKey, corev1.TLSPrivateKeyKey)
+	}
+
+	// the key must match the first cert of the bundle
+	_, err := tls.X509KeyPair(bundle, keyPEM)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "secret: %s/%s", s.Namespace, s.Name)
+	}
+
+	caCert, caKey, err := LoadCertAndKeyFromByte(keyPEM, bundle)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "secret: %s/%s", s.Namespace, s.Name)
+	}
+	if !caCert.IsCA {
+		return nil, nil, nil, errors.Errorf("secret: %s/%s the certificate %q is not a CA", s.Namespace, s.Name, caCert.Subject.String())
+	}
+
+	return caCert, caKey, bundle, nil
+}
+
+// CreateCACertAndKeyFilesWithExternalCA writes out the external CA instead of generating one, the cert file holds the whole chain.
+func CreateCACertAndKeyFilesWithExternalCA(certSpec *KubeadmCert, caCert *x509.Certificate, caKey crypto.Signer, bundle []byte,
+	cfg *kubeadmv1beta2.WarpperConfiguration, cfgMaps map[string][]byte) (*CaAll, error) {
+	if certSpec.CAName != "" {
+		return nil, errors.Errorf("this function should only be used for CAs, but cert %s has CA %s", certSpec.Name, certSpec.CAName)
+	}
+	klog.V(1).Infof("using the external certificate authority %q for %s", caCert.Subject.String(), certSpec.Name)
+
+	keyPath, keyByte, err := pkiutil.BuildKeyByte(cfg.CertificatesDir, certSpec.BaseName, caKey)
+	if err != nil {
+		return nil, err
+	}
+	cfgMaps[keyPath] = keyByte
+
+	certPath, _, err := pkiutil.BuildCertByte(cfg.CertificatesDir, certSpec.BaseName, caCert)
+	if err != nil {
+		return nil, err
+	}
+	cfgMaps[certPath] = bundle
+
+	return &CaAll{
+		CaCert: caCert,
+		CaKey:  caKey,
+		Cfg:    certSpec}, nil
+}
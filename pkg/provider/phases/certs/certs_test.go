@@ -0,0 +1,79 @@
+package certs
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"github.com/gostship/kunkka/pkg/util/pkiutil"
+	corev1 "k8s.io/api/core/v1"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+)
+
+func TestLoadExternalCA(t *testing.T) {
+	newCA := func(name string) ([]byte, []byte, []byte, []byte) {
+		ca, key, err := pkiutil.NewCertificateAuthority(&pkiutil.CertConfig{Config: certutil.Config{CommonName: name}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyPEM, err := keyutil.MarshalPrivateKeyToPEM(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, leafKey, err := pkiutil.NewCertAndKey(ca, key, &pkiutil.CertConfig{
+			Config: certutil.Config{CommonName: "leaf", Usages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		leafKeyPEM, err := keyutil.MarshalPrivateKeyToPEM(leafKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pkiutil.EncodeCertPEM(ca), keyPEM, pkiutil.EncodeCertPEM(leaf), leafKeyPEM
+	}
+	corpCert, corpKey, leafCert, leafKey := newCA("corp-ca")
+	_, otherKey, _, _ := newCA("other-ca")
+
+	tests := []struct {
+		name    string
+		data    map[string][]byte
+		wantErr bool
+	}{
+		{
+			name: "ca",
+			data: map[string][]byte{corev1.TLSCertKey: corpCert, corev1.TLSPrivateKeyKey: corpKey},
+		},
+		{
+			name:    "missing key",
+			data:    map[string][]byte{corev1.TLSCertKey: corpCert},
+			wantErr: true,
+		},
+		{
+			name:    "key mismatch",
+			data:    map[string][]byte{corev1.TLSCertKey: corpCert, corev1.TLSPrivateKeyKey: otherKey},
+			wantErr: true,
+		},
+		{
+			name:    "not a ca",
+			data:    map[string][]byte{corev1.TLSCertKey: leafCert, corev1.TLSPrivateKeyKey: leafKey},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &corev1.Secret{Data: tt.data}
+			s.Name = "corp-ca"
+			caCert, _, bundle, err := LoadExternalCA(s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadExternalCA() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if caCert.Subject.CommonName != "corp-ca" || string(bundle) != string(corpCert) {
+				t.Errorf("LoadExternalCA() = %s, bundle %d bytes", caCert.Subject.CommonName, len(bundle))
+			}
+		})
+	}
+}
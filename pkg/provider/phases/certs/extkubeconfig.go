@@ -0,0 +1,199 @@
+package certs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/util/k8sutil"
+	"github.com/gostship/kunkka/pkg/util/pkiutil"
+	"github.com/pkg/errors"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/klog"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// ExternalAdminUser the user of the regenerated external admin kubeconfigs
+	ExternalAdminUser = "kunkka-admin"
+	// ExternalAdminBinding the ClusterRoleBinding granting cluster-admin to the current external admin group only
+	ExternalAdminBinding = "kunkka:external-admin"
+)
+
+// ExternalAdminGroup returns the group of the external admin kubeconfig of the generation.
+func ExternalAdminGroup(generation int) string {
+	return fmt.Sprintf("%s:%d", ExternalAdminBinding, generation)
+}
+
+// KubeconfigGeneration returns the generation of the external admin kubeconfig requested by the cluster,
+// 0 is the original system:masters kubeconfig which can't be invalidated without a new CA.
+func KubeconfigGeneration(c *common.Cluster) int {
+	gen, err := strconv.Atoi(constants.GetAnnotationKey(c.Cluster.Annotations, constants.ClusterKubeconfigGeneration))
+	if err != nil || gen < 0 {
+		return 0
+	}
+	return gen
+}
+
+// ExternalKubeconfigGeneration returns the generation of the external admin kubeconfig from the group of its client certificate.
+func ExternalKubeconfigGeneration(kubeconfig string) int {
+	cfg, err := clientcmd.Load([]byte(kubeconfig))
+	if err != nil {
+		return 0
+	}
+	return configGeneration(cfg)
+}
+
+func configGeneration(cfg *clientcmdapi.Config) int {
+	for _, auth := range cfg.AuthInfos {
+		certs, err := certutil.ParseCertsPEM(auth.ClientCertificateData)
+		if err != nil {
+			continue
+		}
+		for _, org := range certs[0].Subject.Organization {
+			if !strings.HasPrefix(org, ExternalAdminBinding+":") {
+				continue
+			}
+			if gen, err := strconv.Atoi(strings.TrimPrefix(org, ExternalAdminBinding+":")); err == nil {
+				return gen
+			}
+		}
+	}
+	return 0
+}
+
+// BuildExternalAdminBinding returns the binding of the external admin group of the generation,
+// the groups of the other generations are revoked once it's applied.
+func BuildExternalAdminBinding(generation int) *rbacv1.ClusterRoleBinding {
+	return &rbacv1.ClusterRoleBinding{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "rbac.authorization.k8s.io/v1",
+			Kind:       "ClusterRoleBinding",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: ExternalAdminBinding,
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     "cluster-admin",
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				APIGroup: rbacv1.GroupName,
+				Kind:     rbacv1.GroupKind,
+				Name:     ExternalAdminGroup(generation),
+			},
+		},
+	}
+}
+
+// CreateExternalKubeConfigFile creates the external admin kubeconfig of the generation,
+// the generation 0 is the system:masters kubeconfig as before.
+func CreateExternalKubeConfigFile(issuer Issuer, CACert []byte, apiserver string, clusterName string, generation int) ([]byte, error) {
+	cfg, err := createExternalKubeConfig(issuer, CACert, apiserver, clusterName, generation)
+	if err != nil {
+		return nil, err
+	}
+	return BuildKubeConfigByte(cfg)
+}
+
+func createExternalKubeConfig(issuer Issuer, CACert []byte, apiserver string, clusterName string, generation int) (*clientcmdapi.Config, error) {
+	if generation == 0 {
+		cfgMaps, err := CreateApiserverKubeConfigFile(issuer, CACert, apiserver, clusterName)
+		if err != nil {
+			return nil, err
+		}
+		return cfgMaps[pkiutil.AdminKubeConfigFileName], nil
+	}
+
+	caCerts, err := certutil.ParseCertsPEM(CACert)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't create a kubeconfig; the CA files couldn't be loaded")
+	}
+	spec := &kubeConfigSpec{
+		CACert:     caCerts[0],
+		CABundle:   CACert,
+		APIServer:  apiserver,
+		ClientName: ExternalAdminUser,
+		ClientCertAuth: &clientCertAuth{
+			Issuer:        issuer,
+			Organizations: []string{ExternalAdminGroup(generation)},
+		},
+	}
+	return buildKubeConfigFromSpec(spec, strings.TrimSuffix(pkiutil.AdminKubeConfigFileName, ".conf"), clusterName)
+}
+
+// CreateExpiringExternalKubeConfig creates an external admin kubeconfig of the generation with a client certificate
+// expiring after the ttl, it's revoked along with the generation as the one of the credential.
+func CreateExpiringExternalKubeConfig(issuer Issuer, CACert []byte, apiserver string, clusterName string, generation int, ttl time.Duration) (*clientcmdapi.Config, error) {
+	if ttl <= 0 {
+		return nil, errors.Errorf("invalid ttl: %s", ttl)
+	}
+	caCerts, err := certutil.ParseCertsPEM(CACert)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't create a kubeconfig; the CA files couldn't be loaded")
+	}
+
+	org := pkiutil.SystemPrivilegedGroup
+	if generation > 0 {
+		org = ExternalAdminGroup(generation)
+	}
+	spec := &kubeConfigSpec{
+		CACert:     caCerts[0],
+		CABundle:   CACert,
+		APIServer:  apiserver,
+		ClientName: ExternalAdminUser,
+		ClientCertAuth: &clientCertAuth{
+			Issuer:        issuer,
+			Organizations: []string{org},
+			Validity:      ttl,
+		},
+	}
+	return buildKubeConfigFromSpec(spec, strings.TrimSuffix(pkiutil.AdminKubeConfigFileName, ".conf"), clusterName)
+}
+
+// EnsureExternalKubeconfig writes the external admin kubeconfig to the ExtData of the credential,
+// a new generation binds cluster-admin to its group first with the current client, which revokes the previous one.
+func EnsureExternalKubeconfig(ctx context.Context, c *common.Cluster, apiserver string) error {
+	if c.ClusterCredential.ExtData == nil {
+		c.ClusterCredential.ExtData = make(map[string]string)
+	}
+
+	gen := KubeconfigGeneration(c)
+	current := ExternalKubeconfigGeneration(c.ClusterCredential.ExtData[pkiutil.ExternalAdminKubeConfigFileName])
+	if gen > 0 && gen != current {
+		clusterCtx, err := c.ClusterManager.Get(c.Name)
+		if err != nil {
+			return c.SkipReconcile("EnsureExtKubeconfig", err)
+		}
+
+		logger := ctrl.Log.WithValues("cluster", c.Name)
+		err = k8sutil.Reconcile(logger, clusterCtx.Client, BuildExternalAdminBinding(gen), k8sutil.DesiredStatePresent)
+		if err != nil {
+			return errors.Wrapf(err, "apply external admin binding")
+		}
+		klog.Infof("cluster: %s external admin kubeconfig generation %d -> %d, the previous one is revoked", c.Name, current, gen)
+	}
+
+	issuer, err := ClusterIssuer(c)
+	if err != nil {
+		return err
+	}
+	by, err := CreateExternalKubeConfigFile(issuer, c.ClusterCredential.CACert, apiserver, c.Cluster.Name, gen)
+	if err != nil {
+		klog.Errorf("create external kubeconfg err: %+v", err)
+		return err
+	}
+
+	c.ClusterCredential.ExtData[pkiutil.ExternalAdminKubeConfigFileName] = string(by)
+	return nil
+}
@@ -0,0 +1,62 @@
+package certs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gostship/kunkka/pkg/util/pkiutil"
+	certutil "k8s.io/client-go/util/cert"
+)
+
+func TestCreateExternalKubeConfig(t *testing.T) {
+	ca, caKey, err := pkiutil.NewCertificateAuthority(&pkiutil.CertConfig{Config: certutil.Config{CommonName: "kubernetes"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := NewLocalIssuer(ca, caKey)
+
+	for _, gen := range []int{0, 1, 2} {
+		cfg, err := createExternalKubeConfig(issuer, pkiutil.EncodeCertPEM(ca), "https://10.0.0.1:6443", "c1", gen)
+		if err != nil {
+			t.Fatalf("createExternalKubeConfig(%d) error = %v", gen, err)
+		}
+		if got := configGeneration(cfg); got != gen {
+			t.Errorf("configGeneration() = %d, want %d", got, gen)
+		}
+	}
+
+	tests := []struct {
+		gen int
+		org string
+	}{
+		{gen: 0, org: pkiutil.SystemPrivilegedGroup},
+		{gen: 2, org: ExternalAdminGroup(2)},
+	}
+	for _, tt := range tests {
+		cfg, err := CreateExpiringExternalKubeConfig(issuer, pkiutil.EncodeCertPEM(ca), "https://10.0.0.1:6443", "c1", tt.gen, time.Hour)
+		if err != nil {
+			t.Fatalf("CreateExpiringExternalKubeConfig(%d) error = %v", tt.gen, err)
+		}
+		auth := cfg.AuthInfos[ExternalAdminUser]
+		if auth == nil {
+			t.Fatalf("CreateExpiringExternalKubeConfig(%d) has no user: %s", tt.gen, ExternalAdminUser)
+		}
+		certs, err := certutil.ParseCertsPEM(auth.ClientCertificateData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if orgs := certs[0].Subject.Organization; len(orgs) != 1 || orgs[0] != tt.org {
+			t.Errorf("CreateExpiringExternalKubeConfig(%d) organizations = %v, want %s", tt.gen, orgs, tt.org)
+		}
+		if ttl := time.Until(certs[0].NotAfter); ttl > time.Hour || ttl < 59*time.Minute {
+			t.Errorf("CreateExpiringExternalKubeConfig(%d) certificate expires in %s, want 1h", tt.gen, ttl)
+		}
+	}
+	if _, err := CreateExpiringExternalKubeConfig(issuer, pkiutil.EncodeCertPEM(ca), "https://10.0.0.1:6443", "c1", 1, 0); err == nil {
+		t.Errorf("CreateExpiringExternalKubeConfig() with zero ttl should fail")
+	}
+
+	if subjects := BuildExternalAdminBinding(2).Subjects; len(subjects) != 1 || subjects[0].Name != ExternalAdminGroup(2) {
+		t.Errorf("BuildExternalAdminBinding() subjects = %v", subjects)
+	}
+}
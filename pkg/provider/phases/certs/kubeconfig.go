@@ -13,6 +13,7 @@ import (
 	"net"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/gostship/kunkka/pkg/constants"
 	"github.com/gostship/kunkka/pkg/controllers/common"
@@ -29,8 +30,10 @@ import (
 
 // clientCertAuth struct holds info required to build a client certificate to provide authentication info in a kubeconfig object
 type clientCertAuth struct {
-	CAKey         crypto.Signer
+	Issuer        Issuer
 	Organizations []string
+	// Validity the lifetime of the client certificate, the default of the issuer if zero
+	Validity time.Duration
 }
 
 // tokenAuth struct holds info required to use a token to provide authentication info in a kubeconfig object
@@ -40,7 +43,9 @@ type tokenAuth struct {
 
 // kubeConfigSpec struct holds info required to build a KubeConfig object
 type kubeConfigSpec struct {
-	CACert         *x509.Certificate
+	CACert *x509.Certificate
+	// CABundle is the PEM of CACert and its chain, the chain of an external intermediate CA is kept
+	CABundle       []byte
 	APIServer      string
 	ClientName     string
 	TokenAuth      *tokenAuth
@@ -86,12 +91,12 @@ func LoadCertAndKeyFromByte(CAKey, CACert []byte) (*x509.Certificate, crypto.Sig
 
 // getKubeConfigSpecs returns all KubeConfigSpecs actualized to the context of the current InitConfiguration
 // NB. this methods holds the information about how kubeadm creates kubeconfig files.
-func getKubeConfigSpecs(CAKey, CACert []byte, apiserver string, kubeletNodeAddr string) (map[string]*kubeConfigSpec, error) {
-
-	caCert, caKey, err := LoadCertAndKeyFromByte(CAKey, CACert)
+func getKubeConfigSpecs(issuer Issuer, CACert []byte, apiserver string, kubeletNodeAddr string) (map[string]*kubeConfigSpec, error) {
+	caCerts, err := certutil.ParseCertsPEM(CACert)
 	if err != nil {
 		return nil, errors.Wrap(err, "couldn't create a kubeconfig; the CA files couldn't be loaded")
 	}
+	caCert := caCerts[0]
 
 	var kubeConfigSpec = map[string]*kubeConfigSpec{
 		pkiutil.AdminKubeConfigFileName: {
@@ -99,7 +104,7 @@ func getKubeConfigSpecs(CAKey, CACert []byte, apiserver string, kubeletNodeAddr
 			APIServer:  apiserver,
 			ClientName: "kubernetes-admin",
 			ClientCertAuth: &clientCertAuth{
-				CAKey:         caKey,
+				Issuer:        issuer,
 				Organizations: []string{pkiutil.SystemPrivilegedGroup},
 			},
 		},
@@ -108,7 +113,7 @@ func getKubeConfigSpecs(CAKey, CACert []byte, apiserver string, kubeletNodeAddr
 			APIServer:  apiserver,
 			ClientName: fmt.Sprintf("%s%s", pkiutil.NodesUserPrefix, kubeletNodeAddr),
 			ClientCertAuth: &clientCertAuth{
-				CAKey:         caKey,
+				Issuer:        issuer,
 				Organizations: []string{pkiutil.NodesGroup},
 			},
 		},
@@ -117,7 +122,7 @@ func getKubeConfigSpecs(CAKey, CACert []byte, apiserver string, kubeletNodeAddr
 			APIServer:  apiserver,
 			ClientName: pkiutil.ControllerManagerUser,
 			ClientCertAuth: &clientCertAuth{
-				CAKey: caKey,
+				Issuer: issuer,
 			},
 		},
 		pkiutil.SchedulerKubeConfigFileName: {
@@ -125,16 +130,25 @@ func getKubeConfigSpecs(CAKey, CACert []byte, apiserver string, kubeletNodeAddr
 			APIServer:  apiserver,
 			ClientName: pkiutil.SchedulerUser,
 			ClientCertAuth: &clientCertAuth{
-				CAKey: caKey,
+				Issuer: issuer,
 			},
 		},
 	}
 
+	for _, spec := range kubeConfigSpec {
+		spec.CABundle = CACert
+	}
+
 	return kubeConfigSpec, nil
 }
 
 // buildKubeConfigFromSpec creates a kubeconfig object for the given kubeConfigSpec
-func buildKubeConfigFromSpec(spec *kubeConfigSpec, clustername string) (*clientcmdapi.Config, error) {
+// name is the kubeconfig file name without .conf, e.g. admin, kubelet.
+func buildKubeConfigFromSpec(spec *kubeConfigSpec, name string, clustername string) (*clientcmdapi.Config, error) {
+	caData := spec.CABundle
+	if len(caData) == 0 {
+		caData = pkiutil.EncodeCertPEM(spec.CACert)
+	}
 
 	// If this kubeconfig should use token
 	if spec.TokenAuth != nil {
@@ -143,7 +157,7 @@ func buildKubeConfigFromSpec(spec *kubeConfigSpec, clustername string) (*clientc
 			spec.APIServer,
 			clustername,
 			spec.ClientName,
-			pkiutil.EncodeCertPEM(spec.CACert),
+			caData,
 			spec.TokenAuth.Token,
 		), nil
 	}
@@ -155,8 +169,9 @@ func buildKubeConfigFromSpec(spec *kubeConfigSpec, clustername string) (*clientc
 			Organization: spec.ClientCertAuth.Organizations,
 			Usages:       []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
 		},
+		Validity: spec.ClientCertAuth.Validity,
 	}
-	clientCert, clientKey, err := pkiutil.NewCertAndKey(spec.CACert, spec.ClientCertAuth.CAKey, &clientCertConfig)
+	clientCert, clientKey, err := spec.ClientCertAuth.Issuer.Issue(name, &clientCertConfig)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failure while creating %s client certificate", spec.ClientName)
 	}
@@ -170,7 +185,7 @@ func buildKubeConfigFromSpec(spec *kubeConfigSpec, clustername string) (*clientc
 		spec.APIServer,
 		clustername,
 		spec.ClientName,
-		pkiutil.EncodeCertPEM(spec.CACert),
+		caData,
 		encodedClientKey,
 		pkiutil.EncodeCertPEM(clientCert),
 	), nil
@@ -186,10 +201,10 @@ func DecodeKubeConfigByte(data []byte, config *clientcmdapi.Config) error {
 
 // createKubeConfigFiles creates all the requested kubeconfig files.
 // If kubeconfig files already exists, they are used only if evaluated equal; otherwise an error is returned.
-func CreateKubeConfigFiles(CAKey, CACert []byte, apiserver string, kubeletNodeAddr string, clusterName string, kubeConfigFileNames ...string) (map[string]*clientcmdapi.Config, error) {
+func CreateKubeConfigFiles(issuer Issuer, CACert []byte, apiserver string, kubeletNodeAddr string, clusterName string, kubeConfigFileNames ...string) (map[string]*clientcmdapi.Config, error) {
 	cfgMaps := make(map[string]*clientcmdapi.Config)
 	// gets the KubeConfigSpecs, actualized for the current InitConfiguration
-	specs, err := getKubeConfigSpecs(CAKey, CACert, apiserver, kubeletNodeAddr)
+	specs, err := getKubeConfigSpecs(issuer, CACert, apiserver, kubeletNodeAddr)
 	if err != nil {
 		return nil, err
 	}
@@ -203,7 +218,7 @@ func CreateKubeConfigFiles(CAKey, CACert []byte, apiserver string, kubeletNodeAd
 		}
 
 		// builds the KubeConfig object
-		config, err := buildKubeConfigFromSpec(spec, clusterName)
+		config, err := buildKubeConfigFromSpec(spec, strings.TrimSuffix(kubeConfigFileName, ".conf"), clusterName)
 		if err != nil {
 			return cfgMaps, err
 		}
@@ -251,16 +266,16 @@ func BuildApiserverEndpoint(ipOrDns string, bindPort int) string {
 	return controlPlaneURL.String()
 }
 
-func CreateKubeletKubeConfigFile(CAKey, CACert []byte, apiserver string, kubeletNodeAddr string, clusterName string) (map[string]*clientcmdapi.Config, error) {
-	return CreateKubeConfigFiles(CAKey, CACert, apiserver, kubeletNodeAddr, clusterName, GetKubeletKubeconfigList()...)
+func CreateKubeletKubeConfigFile(issuer Issuer, CACert []byte, apiserver string, kubeletNodeAddr string, clusterName string) (map[string]*clientcmdapi.Config, error) {
+	return CreateKubeConfigFiles(issuer, CACert, apiserver, kubeletNodeAddr, clusterName, GetKubeletKubeconfigList()...)
 }
 
-func CreateMasterKubeConfigFile(CAKey, CACert []byte, apiserver string, clusterName string) (map[string]*clientcmdapi.Config, error) {
-	return CreateKubeConfigFiles(CAKey, CACert, apiserver, "", clusterName, GetMasterKubeConfigList()...)
+func CreateMasterKubeConfigFile(issuer Issuer, CACert []byte, apiserver string, clusterName string) (map[string]*clientcmdapi.Config, error) {
+	return CreateKubeConfigFiles(issuer, CACert, apiserver, "", clusterName, GetMasterKubeConfigList()...)
 }
 
-func CreateApiserverKubeConfigFile(CAKey, CACert []byte, apiserver string, clusterName string) (map[string]*clientcmdapi.Config, error) {
-	return CreateKubeConfigFiles(CAKey, CACert, apiserver, "", clusterName, GetApiserverKubeconfigList()...)
+func CreateApiserverKubeConfigFile(issuer Issuer, CACert []byte, apiserver string, clusterName string) (map[string]*clientcmdapi.Config, error) {
+	return CreateKubeConfigFiles(issuer, CACert, apiserver, "", clusterName, GetApiserverKubeconfigList()...)
 }
 
 func GetKubeletKubeconfigList() []string {
@@ -0,0 +1,75 @@
+package certs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	certutil "k8s.io/client-go/util/cert"
+)
+
+// UserPrefix the prefix of the users of the scoped kubeconfigs, so they never collide with the system users
+const UserPrefix = "kunkka:user:"
+
+// ScopedUser returns the user of the scoped kubeconfigs issued to the platform user.
+func ScopedUser(user string) string {
+	return UserPrefix + user
+}
+
+// ScopedBindingName returns the name of the ClusterRoleBinding granting the ClusterRole to the platform user.
+func ScopedBindingName(user, clusterRole string) string {
+	return fmt.Sprintf("%s%s:%s", UserPrefix, user, clusterRole)
+}
+
+// BuildScopedBinding returns the binding granting the ClusterRole to the user of the scoped kubeconfigs,
+// the user is bound instead of a group so it works with the vault roles which don't take the organizations.
+func BuildScopedBinding(user, clusterRole string) *rbacv1.ClusterRoleBinding {
+	return &rbacv1.ClusterRoleBinding{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "rbac.authorization.k8s.io/v1",
+			Kind:       "ClusterRoleBinding",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: ScopedBindingName(user, clusterRole),
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     clusterRole,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				APIGroup: rbacv1.GroupName,
+				Kind:     rbacv1.UserKind,
+				Name:     ScopedUser(user),
+			},
+		},
+	}
+}
+
+// CreateScopedKubeConfig creates the kubeconfig of the platform user with a client certificate expiring after the ttl,
+// it has no permission but the ClusterRoleBindings of the user.
+func CreateScopedKubeConfig(issuer Issuer, CACert []byte, apiserver string, clusterName string, user string, ttl time.Duration) (*clientcmdapi.Config, error) {
+	if ttl <= 0 {
+		return nil, errors.Errorf("invalid ttl: %s", ttl)
+	}
+	caCerts, err := certutil.ParseCertsPEM(CACert)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't create a kubeconfig; the CA files couldn't be loaded")
+	}
+
+	spec := &kubeConfigSpec{
+		CACert:     caCerts[0],
+		CABundle:   CACert,
+		APIServer:  apiserver,
+		ClientName: ScopedUser(user),
+		ClientCertAuth: &clientCertAuth{
+			Issuer:   issuer,
+			Validity: ttl,
+		},
+	}
+	return buildKubeConfigFromSpec(spec, "scoped", clusterName)
+}
@@ -0,0 +1,46 @@
+package certs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gostship/kunkka/pkg/util/pkiutil"
+	certutil "k8s.io/client-go/util/cert"
+)
+
+func TestCreateScopedKubeConfig(t *testing.T) {
+	ca, caKey, err := pkiutil.NewCertificateAuthority(&pkiutil.CertConfig{Config: certutil.Config{CommonName: "kubernetes"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := NewLocalIssuer(ca, caKey)
+
+	_, err = CreateScopedKubeConfig(issuer, pkiutil.EncodeCertPEM(ca), "https://10.0.0.1:6443", "c1", "alice", 0)
+	if err == nil {
+		t.Errorf("CreateScopedKubeConfig() with zero ttl should fail")
+	}
+
+	cfg, err := CreateScopedKubeConfig(issuer, pkiutil.EncodeCertPEM(ca), "https://10.0.0.1:6443", "c1", "alice", time.Hour)
+	if err != nil {
+		t.Fatalf("CreateScopedKubeConfig() error = %v", err)
+	}
+	auth := cfg.AuthInfos[ScopedUser("alice")]
+	if auth == nil {
+		t.Fatalf("CreateScopedKubeConfig() has no user: %s", ScopedUser("alice"))
+	}
+	certs, err := certutil.ParseCertsPEM(auth.ClientCertificateData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if certs[0].Subject.CommonName != "kunkka:user:alice" || len(certs[0].Subject.Organization) != 0 {
+		t.Errorf("unexpected subject: %v", certs[0].Subject)
+	}
+	if ttl := time.Until(certs[0].NotAfter); ttl > time.Hour || ttl < 59*time.Minute {
+		t.Errorf("certificate expires in %s, want 1h", ttl)
+	}
+
+	b := BuildScopedBinding("alice", "view")
+	if b.Name != "kunkka:user:alice:view" || b.RoleRef.Name != "view" || b.Subjects[0].Name != ScopedUser("alice") {
+		t.Errorf("unexpected binding: %+v", b)
+	}
+}
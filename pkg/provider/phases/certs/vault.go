@@ -0,0 +1,282 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	kubeadmv1beta2 "github.com/gostship/kunkka/pkg/apis/kubeadm/v1beta2"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/util/pkiutil"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+	"k8s.io/klog"
+)
+
+const (
+	// DefaultVaultRole the pki role of the certificates not in the roles of the cluster
+	DefaultVaultRole = "kunkka"
+	// VaultTokenKey the key of the vault token in the Secret
+	VaultTokenKey = "token"
+	// VaultCAKey the key of the CA of vault in the Secret
+	VaultCAKey = "ca.crt"
+
+	vaultTimeout = 30 * time.Second
+)
+
+// Issuer issues a certificate and its key, name is the certificate name or the kubeconfig user.
+type Issuer interface {
+	Issue(name string, cfg *pkiutil.CertConfig) (*x509.Certificate, crypto.Signer, error)
+}
+
+type localIssuer struct {
+	caCert *x509.Certificate
+	caKey  crypto.Signer
+}
+
+// NewLocalIssuer returns the issuer signing with the CA key held by kunkka.
+func NewLocalIssuer(caCert *x509.Certificate, caKey crypto.Signer) Issuer {
+	return &localIssuer{caCert: caCert, caKey: caKey}
+}
+
+func (i *localIssuer) Issue(name string, cfg *pkiutil.CertConfig) (*x509.Certificate, crypto.Signer, error) {
+	return pkiutil.NewCertAndKey(i.caCert, i.caKey, cfg)
+}
+
+// VaultIssuer issues the certificates by the issue api of a vault pki secrets engine,
+// the key is generated by vault and the key of the CA never leaves vault.
+type VaultIssuer struct {
+	Address     string
+	Mount       string
+	Token       string
+	Roles       map[string]string
+	DefaultRole string
+	Client      *http.Client
+}
+
+// NewVaultIssuer returns the issuer of the mount, the token and the CA of vault are read from the Secret.
+func NewVaultIssuer(spec *devopsv1.VaultPKI, mount string, s *corev1.Secret) (*VaultIssuer, error) {
+	token := strings.TrimSpace(string(s.Data[VaultTokenKey]))
+	if token == "" {
+		return nil, errors.Errorf("secret: %s/%s must have %s", s.Namespace, s.Name, VaultTokenKey)
+	}
+
+	tlsConfig := &tls.Config{}
+	if ca := s.Data[VaultCAKey]; len(ca) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.Errorf("secret: %s/%s invalid %s", s.Namespace, s.Name, VaultCAKey)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	defaultRole := spec.DefaultRole
+	if defaultRole == "" {
+		defaultRole = DefaultVaultRole
+	}
+
+	return &VaultIssuer{
+		Address:     strings.TrimSuffix(spec.Address, "/"),
+		Mount:       strings.Trim(mount, "/"),
+		Token:       token,
+		Roles:       spec.Roles,
+		DefaultRole: defaultRole,
+		Client: &http.Client{
+			Timeout: vaultTimeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+		},
+	}, nil
+}
+
+// Role returns the pki role of the certificate.
+func (v *VaultIssuer) Role(name string) string {
+	if role, ok := v.Roles[name]; ok && role != "" {
+		return role
+	}
+	return v.DefaultRole
+}
+
+// CACert returns the issuing CA of the mount and its PEM.
+func (v *VaultIssuer) CACert() (*x509.Certificate, []byte, error) {
+	data, err := v.do(http.MethodGet, "ca/pem", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certs, err := certutil.ParseCertsPEM(data)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "vault mount: %s parse ca", v.Mount)
+	}
+	return certs[0], data, nil
+}
+
+type vaultIssueResponse struct {
+	Data struct {
+		Certificate string `json:"certificate"`
+		PrivateKey  string `json:"private_key"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+// Issue issues the certificate by the role of the name, the usages, organization and ttl are decided by the role.
+func (v *VaultIssuer) Issue(name string, cfg *pkiutil.CertConfig) (*x509.Certificate, crypto.Signer, error) {
+	ips := make([]string, 0, len(cfg.AltNames.IPs))
+	for _, ip := range cfg.AltNames.IPs {
+		ips = append(ips, ip.String())
+	}
+	req := map[string]interface{}{
+		"common_name":          cfg.CommonName,
+		"alt_names":            strings.Join(cfg.AltNames.DNSNames, ","),
+		"ip_sans":              strings.Join(ips, ","),
+		"format":               "pem",
+		"exclude_cn_from_sans": true,
+	}
+	if cfg.Validity > 0 {
+		req["ttl"] = cfg.Validity.String()
+	}
+
+	role := v.Role(name)
+	klog.V(1).Infof("issuing %s by vault mount: %s role: %s", name, v.Mount, role)
+	data, err := v.do(http.MethodPost, "issue/"+role, req)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "issue %s", name)
+	}
+
+	resp := &vaultIssueResponse{}
+	err = json.Unmarshal(data, resp)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "issue %s decode response", name)
+	}
+
+	certs, err := certutil.ParseCertsPEM([]byte(resp.Data.Certificate))
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "issue %s parse certificate", name)
+	}
+
+	privKey, err := keyutil.ParsePrivateKeyPEM([]byte(resp.Data.PrivateKey))
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "issue %s parse private key", name)
+	}
+	key, ok := privKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, errors.Errorf("issue %s the private key is not a signer", name)
+	}
+
+	return certs[0], key, nil
+}
+
+func (v *VaultIssuer) do(method, path string, body interface{}) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/%s", v.Address, v.Mount, path)
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		vaultErr := &vaultIssueResponse{}
+		if json.Unmarshal(data, vaultErr) == nil && len(vaultErr.Errors) > 0 {
+			return nil, errors.Errorf("vault %s %s: %d %s", method, path, resp.StatusCode, strings.Join(vaultErr.Errors, "; "))
+		}
+		return nil, errors.Errorf("vault %s %s: %d", method, path, resp.StatusCode)
+	}
+
+	return data, nil
+}
+
+// ClusterVaultIssuer returns the vault issuer of the CA of the cluster, nil if the CA is not mounted.
+func ClusterVaultIssuer(c *common.Cluster, caName string) (*VaultIssuer, error) {
+	spec := c.Spec.VaultPKI
+	if spec == nil || spec.Mounts[caName] == "" {
+		return nil, nil
+	}
+
+	s := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: c.Cluster.Namespace, Name: spec.SecretName}
+	err := c.Client.Get(context.TODO(), key, s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get vault secret: %s", key.String())
+	}
+
+	return NewVaultIssuer(spec, spec.Mounts[caName], s)
+}
+
+// ClusterIssuer returns the issuer of the client certificates of the kubeconfigs,
+// i.e. vault if the ca is mounted, otherwise the CA key of the credential.
+func ClusterIssuer(c *common.Cluster) (Issuer, error) {
+	vault, err := ClusterVaultIssuer(c, KubeadmCertRootCA.Name)
+	if err != nil {
+		return nil, err
+	}
+	if vault != nil {
+		return vault, nil
+	}
+
+	caCert, caKey, err := LoadCertAndKeyFromByte(c.ClusterCredential.CAKey, c.ClusterCredential.CACert)
+	if err != nil {
+		return nil, errors.Wrap(err, "the CA files couldn't be loaded")
+	}
+	return NewLocalIssuer(caCert, caKey), nil
+}
+
+// CreateCACertFilesWithVault writes out the issuing CA of the vault mount, there is no key to write.
+func CreateCACertFilesWithVault(certSpec *KubeadmCert, v *VaultIssuer, cfg *kubeadmv1beta2.WarpperConfiguration, cfgMaps map[string][]byte) (*CaAll, error) {
+	if certSpec.CAName != "" {
+		return nil, errors.Errorf("this function should only be used for CAs, but cert %s has CA %s", certSpec.Name, certSpec.CAName)
+	}
+
+	caCert, caPEM, err := v.CACert()
+	if err != nil {
+		return nil, err
+	}
+	klog.V(1).Infof("using the vault mount %s %q for %s", v.Mount, caCert.Subject.String(), certSpec.Name)
+
+	certPath, _, err := pkiutil.BuildCertByte(cfg.CertificatesDir, certSpec.BaseName, caCert)
+	if err != nil {
+		return nil, err
+	}
+	cfgMaps[certPath] = caPEM
+
+	return &CaAll{
+		CaCert: caCert,
+		Issuer: v,
+		Cfg:    certSpec}, nil
+}
@@ -0,0 +1,102 @@
+package certs
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/util/pkiutil"
+	corev1 "k8s.io/api/core/v1"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+)
+
+func TestVaultIssuer(t *testing.T) {
+	ca, caKey, err := pkiutil.NewCertificateAuthority(&pkiutil.CertConfig{Config: certutil.Config{CommonName: "vault-ca"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var roles []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/pki/k8s/ca/pem":
+			w.Write(pkiutil.EncodeCertPEM(ca))
+		case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/v1/pki/k8s/issue/"):
+			roles = append(roles, strings.TrimPrefix(r.URL.Path, "/v1/pki/k8s/issue/"))
+			req := map[string]interface{}{}
+			json.NewDecoder(r.Body).Decode(&req)
+			cfg := &pkiutil.CertConfig{Config: certutil.Config{
+				CommonName: req["common_name"].(string),
+				Usages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			}}
+			for _, ip := range strings.Split(req["ip_sans"].(string), ",") {
+				cfg.AltNames.IPs = append(cfg.AltNames.IPs, net.ParseIP(ip))
+			}
+			cert, key, err := pkiutil.NewCertAndKey(ca, caKey, cfg)
+			if err != nil {
+				t.Error(err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			keyPEM, _ := keyutil.MarshalPrivateKeyToPEM(key)
+			resp := vaultIssueResponse{}
+			resp.Data.Certificate = string(pkiutil.EncodeCertPEM(cert))
+			resp.Data.PrivateKey = string(keyPEM)
+			json.NewEncoder(w).Encode(resp)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	spec := &devopsv1.VaultPKI{
+		Address: srv.URL + "/",
+		Roles:   map[string]string{"apiserver": "apiserver"},
+	}
+	s := &corev1.Secret{Data: map[string][]byte{VaultTokenKey: []byte("s.token\n")}}
+	v, err := NewVaultIssuer(spec, "/pki/k8s/", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caCert, caPEM, err := v.CACert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caCert.Subject.CommonName != "vault-ca" || len(caPEM) == 0 {
+		t.Errorf("CACert() = %s", caCert.Subject.CommonName)
+	}
+
+	for _, name := range []string{"apiserver", "etcd-server"} {
+		cfg := &pkiutil.CertConfig{Config: certutil.Config{CommonName: name}}
+		cfg.AltNames.IPs = []net.IP{net.ParseIP("10.0.0.1")}
+		cert, key, err := v.Issue(name, cfg)
+		if err != nil {
+			t.Fatalf("Issue(%s) error = %v", name, err)
+		}
+		if cert.Subject.CommonName != name || len(cert.IPAddresses) != 1 || key == nil {
+			t.Errorf("Issue(%s) = %s %v", name, cert.Subject.CommonName, cert.IPAddresses)
+		}
+	}
+	if strings.Join(roles, ",") != "apiserver,"+DefaultVaultRole {
+		t.Errorf("roles = %v", roles)
+	}
+
+	v.Token = "bad"
+	_, _, err = v.CACert()
+	if err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("CACert() error = %v, want permission denied", err)
+	}
+}
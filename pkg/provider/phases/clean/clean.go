@@ -23,8 +23,8 @@ func DleNode(s ssh.Interface, name string) error {
 	cmd := fmt.Sprintf("kubectl delete node %s", name) //kubectl delete node 10.248.224.171
 	exit, err := s.ExecStream(cmd, os.Stdout, os.Stderr)
 	if err != nil {
-		klog.Error("cmd: %s exit: %q err: %+v", cmd, exit, err)
-		return errors.Wrapf(err, "node delete exec: \n%s", s.HostIP(), cmd)
+		klog.Errorf("cmd: %s exit: %q err: %+v", cmd, exit, err)
+		return errors.Wrapf(err, "node %s delete exec: \n%s", s.HostIP(), cmd)
 	}
 	return nil
 }
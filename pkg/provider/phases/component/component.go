@@ -2,12 +2,15 @@ package component
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
 	"github.com/gostship/kunkka/pkg/constants"
 	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/phases/system"
 	"github.com/gostship/kunkka/pkg/util/ssh"
+	"github.com/pkg/errors"
 	"k8s.io/klog"
 )
 
@@ -51,6 +54,14 @@ func Install(s ssh.Interface, c *common.Cluster) error {
 		otherDir = "/k8s/bin/"
 	}
 
+	// the binaries of the other architectures are in the sub directories of the arch
+	_, arch, err := system.GetArch(s)
+	if err != nil {
+		return err
+	}
+	k8sDir = system.ArchDir(k8sDir, arch)
+	otherDir = system.ArchDir(otherDir, arch)
+
 	var CopyList = []devopsv1.File{
 		{
 			Src: k8sDir + "kubectl",
@@ -69,12 +80,22 @@ func Install(s ssh.Interface, c *common.Cluster) error {
 			Dst: "/opt/cni.tgz",
 		},
 	}
+	if c.ContainerRuntimeType() != devopsv1.ContainerRuntimeDocker {
+		CopyList = append(CopyList, devopsv1.File{
+			Src: otherDir + "crictl",
+			Dst: "/usr/local/bin/crictl",
+		})
+	}
 
 	for _, ls := range CopyList {
 		//if ok, err := s.Exist(ls.Dst); err == nil && ok {
 		//	klog.Info("dst file: %s is exis!", ls.Dst)
 		//}
 
+		if _, err := os.Stat(ls.Src); err != nil {
+			return errors.Wrapf(err, "node: %s arch: %s binary", s.HostIP(), arch)
+		}
+
 		err := s.CopyFile(ls.Src, ls.Dst)
 		if err != nil {
 			klog.Errorf("node: %s copy %s err: %v", s.HostIP(), ls.Src, err)
@@ -100,7 +121,7 @@ func Install(s ssh.Interface, c *common.Cluster) error {
 	}
 
 	klog.Infof("node: %s start write %s ... ", s.HostIP(), constants.KubeletSystemdUnitFilePath)
-	err := s.WriteFile(strings.NewReader(kubeletService), constants.KubeletSystemdUnitFilePath)
+	err = s.WriteFile(strings.NewReader(kubeletService), constants.KubeletSystemdUnitFilePath)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,240 @@
+// Package encryption renders the EncryptionConfiguration of the apiservers and rotates its keys,
+// the keys are kept in the ClusterCredential in order, the first one encrypts and all of them decrypt.
+//
+// A rotation takes several reconciles, the apiservers must be restarted with the config of each step before the next:
+//  1. the new key is appended, so every apiserver can decrypt with it
+//  2. the new key is moved first, so the writes are encrypted with it
+//  3. all the secrets are rewritten with the new key and the other keys are dropped
+package encryption
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"github.com/ghodss/yaml"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	configv1 "k8s.io/apiserver/pkg/apis/config/v1"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// IdentityKey the name of the identity provider, the secrets are decrypted once it's the first
+	IdentityKey = "identity"
+
+	keySize = 32
+)
+
+// Enabled returns whether the apiservers of the cluster are configured with the encryption,
+// it's kept once enabled so the encrypted secrets can be read after the encryption is removed.
+func Enabled(c *common.Cluster) bool {
+	return c.Spec.Encryption != nil || len(c.ClusterCredential.EncryptionKeys) > 0
+}
+
+// KeyGeneration returns the generation of the aescbc key requested by the cluster.
+func KeyGeneration(c *common.Cluster) int {
+	gen, err := strconv.Atoi(constants.GetAnnotationKey(c.Cluster.Annotations, constants.ClusterEncryptionKeyGeneration))
+	if err != nil || gen < 0 {
+		return 0
+	}
+	return gen
+}
+
+// desiredKey returns the provider the secrets should be encrypted with, the secret of a new aescbc key is generated later.
+func desiredKey(c *common.Cluster) devopsv1.EncryptionKey {
+	switch {
+	case c.Spec.Encryption == nil:
+		return devopsv1.EncryptionKey{Name: IdentityKey}
+	case c.Spec.Encryption.KMS != nil:
+		return devopsv1.EncryptionKey{Name: "kms-" + c.Spec.Encryption.KMS.Name, KMS: c.Spec.Encryption.KMS.DeepCopy()}
+	default:
+		return devopsv1.EncryptionKey{Name: fmt.Sprintf("key%d", KeyGeneration(c))}
+	}
+}
+
+func newKey(key devopsv1.EncryptionKey) (devopsv1.EncryptionKey, error) {
+	if key.KMS != nil || key.Name == IdentityKey {
+		return key, nil
+	}
+
+	key.Secret = make([]byte, keySize)
+	if _, err := rand.Read(key.Secret); err != nil {
+		return key, errors.Wrap(err, "generate encryption key")
+	}
+	return key, nil
+}
+
+func indexOf(keys []devopsv1.EncryptionKey, name string) int {
+	for i := range keys {
+		if keys[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// InitConfig generates the first key of a new cluster without any secret to rewrite and renders the config.
+func InitConfig(c *common.Cluster) (bool, error) {
+	if !Enabled(c) {
+		return false, nil
+	}
+	if len(c.ClusterCredential.EncryptionKeys) == 0 {
+		key, err := newKey(desiredKey(c))
+		if err != nil {
+			return false, err
+		}
+		key.Rewritten = true
+		c.ClusterCredential.EncryptionKeys = []devopsv1.EncryptionKey{key}
+	}
+	return ApplyConfig(c)
+}
+
+// Rotate advances the keys of the cluster by one step towards the desired one and renders the config,
+// the apiservers must run with the config of the previous step. rewrite rewrites all the secrets
+// with the current key before the other keys are dropped. It returns whether the config is changed.
+func Rotate(ctx context.Context, c *common.Cluster, rewrite func(ctx context.Context) error) (bool, error) {
+	if !Enabled(c) {
+		return false, nil
+	}
+
+	want := desiredKey(c)
+	keys := c.ClusterCredential.EncryptionKeys
+	idx := indexOf(keys, want.Name)
+	switch {
+	case len(keys) == 0:
+		// the existing secrets are plaintext, they're rewritten by the next step
+		key, err := newKey(want)
+		if err != nil {
+			return false, err
+		}
+		keys = []devopsv1.EncryptionKey{key}
+		klog.Infof("cluster: %s encryption enabled with %s", c.Name, key.Name)
+	case idx < 0:
+		key, err := newKey(want)
+		if err != nil {
+			return false, err
+		}
+		keys = append(keys, key)
+		klog.Infof("cluster: %s encryption key %s staged", c.Name, key.Name)
+	case idx > 0:
+		key := keys[idx]
+		key.Rewritten = false
+		rest := append(append([]devopsv1.EncryptionKey{}, keys[:idx]...), keys[idx+1:]...)
+		keys = append([]devopsv1.EncryptionKey{key}, rest...)
+		klog.Infof("cluster: %s encryption key %s promoted", c.Name, key.Name)
+	case !keys[0].Rewritten:
+		err := rewrite(ctx)
+		if err != nil {
+			return false, err
+		}
+		keys[0].Rewritten = true
+		keys = keys[:1]
+		klog.Infof("cluster: %s secrets rewritten with encryption key %s, the other keys are dropped", c.Name, keys[0].Name)
+	}
+
+	// the plugin may be reconfigured in place
+	if want.KMS != nil {
+		keys[indexOf(keys, want.Name)].KMS = want.KMS
+	}
+	c.ClusterCredential.EncryptionKeys = keys
+	return ApplyConfig(c)
+}
+
+// BuildConfig renders the EncryptionConfiguration of the keys, the identity is always kept last to read the plaintext secrets.
+func BuildConfig(keys []devopsv1.EncryptionKey) ([]byte, error) {
+	providers := []configv1.ProviderConfiguration{}
+	identity := false
+	for _, key := range keys {
+		switch {
+		case key.KMS != nil:
+			providers = append(providers, configv1.ProviderConfiguration{
+				KMS: &configv1.KMSConfiguration{
+					Name:      key.KMS.Name,
+					Endpoint:  key.KMS.Endpoint,
+					CacheSize: key.KMS.CacheSize,
+					Timeout:   key.KMS.Timeout,
+				},
+			})
+		case key.Name == IdentityKey:
+			identity = true
+			providers = append(providers, configv1.ProviderConfiguration{Identity: &configv1.IdentityConfiguration{}})
+		default:
+			providers = append(providers, configv1.ProviderConfiguration{
+				AESCBC: &configv1.AESConfiguration{
+					Keys: []configv1.Key{{Name: key.Name, Secret: base64.StdEncoding.EncodeToString(key.Secret)}},
+				},
+			})
+		}
+	}
+	if !identity {
+		providers = append(providers, configv1.ProviderConfiguration{Identity: &configv1.IdentityConfiguration{}})
+	}
+
+	cfg := &configv1.EncryptionConfiguration{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: configv1.SchemeGroupVersion.String(),
+			Kind:       "EncryptionConfiguration",
+		},
+		Resources: []configv1.ResourceConfiguration{
+			{
+				Resources: []string{"secrets"},
+				Providers: providers,
+			},
+		},
+	}
+	return yaml.Marshal(cfg)
+}
+
+// ApplyConfig renders the config of the keys to the KubeData, returns whether it's changed.
+func ApplyConfig(c *common.Cluster) (bool, error) {
+	data, err := BuildConfig(c.ClusterCredential.EncryptionKeys)
+	if err != nil {
+		return false, err
+	}
+
+	if c.ClusterCredential.KubeData == nil {
+		c.ClusterCredential.KubeData = make(map[string]string)
+	}
+	changed := c.ClusterCredential.KubeData[constants.EncryptionConfigFile] != string(data)
+	c.ClusterCredential.KubeData[constants.EncryptionConfigFile] = string(data)
+	return changed, nil
+}
+
+// Checksum returns the sha256 of the rendered config, empty if the encryption is disabled.
+func Checksum(c *common.Cluster) string {
+	data, ok := c.ClusterCredential.KubeData[constants.EncryptionConfigFile]
+	if !ok {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// RewriteSecrets updates all the secrets of the cluster unchanged, which stores them with the current key.
+func RewriteSecrets(ctx context.Context, cli client.Client) error {
+	secrets := &corev1.SecretList{}
+	err := cli.List(ctx, secrets)
+	if err != nil {
+		return errors.Wrap(err, "list secrets")
+	}
+
+	for i := range secrets.Items {
+		err = cli.Update(ctx, &secrets.Items[i])
+		if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+			return errors.Wrapf(err, "rewrite secret %s/%s", secrets.Items[i].Namespace, secrets.Items[i].Name)
+		}
+	}
+	klog.Infof("%d secrets rewritten", len(secrets.Items))
+	return nil
+}
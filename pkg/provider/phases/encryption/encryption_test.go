@@ -0,0 +1,107 @@
+package encryption
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func keyNames(c *common.Cluster) []string {
+	names := []string{}
+	for _, key := range c.ClusterCredential.EncryptionKeys {
+		names = append(names, key.Name)
+	}
+	return names
+}
+
+func TestRotate(t *testing.T) {
+	c := &common.Cluster{
+		Cluster: &devopsv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "c1"},
+			Spec:       devopsv1.ClusterSpec{Encryption: &devopsv1.Encryption{}},
+		},
+		ClusterCredential: &devopsv1.ClusterCredential{},
+	}
+	rewrites := 0
+	rewrite := func(ctx context.Context) error {
+		rewrites++
+		return nil
+	}
+	rotate := func() bool {
+		changed, err := Rotate(context.TODO(), c, rewrite)
+		if err != nil {
+			t.Fatalf("Rotate() error = %v", err)
+		}
+		return changed
+	}
+
+	// enabled on an existing cluster: the plaintext secrets are rewritten with the new key
+	steps := []struct {
+		generation string
+		kms        *devopsv1.KMSPlugin
+		keys       []string
+		changed    bool
+		rewrites   int
+	}{
+		{keys: []string{"key0"}, changed: true},
+		{keys: []string{"key0"}, changed: false, rewrites: 1},
+		{keys: []string{"key0"}, changed: false, rewrites: 1},
+		{generation: "1", keys: []string{"key0", "key1"}, changed: true, rewrites: 1},
+		{generation: "1", keys: []string{"key1", "key0"}, changed: true, rewrites: 1},
+		{generation: "1", keys: []string{"key1"}, changed: true, rewrites: 2},
+		{kms: &devopsv1.KMSPlugin{Name: "vault", Endpoint: "unix:///var/run/kmsplugin/socket.sock"}, keys: []string{"key1", "kms-vault"}, changed: true, rewrites: 2},
+		{kms: &devopsv1.KMSPlugin{Name: "vault", Endpoint: "unix:///var/run/kmsplugin/socket.sock"}, keys: []string{"kms-vault", "key1"}, changed: true, rewrites: 2},
+		{kms: &devopsv1.KMSPlugin{Name: "vault", Endpoint: "unix:///var/run/kmsplugin/socket.sock"}, keys: []string{"kms-vault"}, changed: true, rewrites: 3},
+	}
+	for i, step := range steps {
+		c.Cluster.Annotations = map[string]string{constants.ClusterEncryptionKeyGeneration: step.generation}
+		c.Spec.Encryption.KMS = step.kms
+		if changed := rotate(); changed != step.changed {
+			t.Errorf("step %d: Rotate() changed = %v, want %v", i, changed, step.changed)
+		}
+		if got := keyNames(c); !reflect.DeepEqual(got, step.keys) {
+			t.Errorf("step %d: keys = %v, want %v", i, got, step.keys)
+		}
+		if rewrites != step.rewrites {
+			t.Errorf("step %d: rewrites = %d, want %d", i, rewrites, step.rewrites)
+		}
+	}
+
+	// removed: the secrets are decrypted and the identity is kept
+	c.Spec.Encryption = nil
+	for i := 0; i < 3; i++ {
+		rotate()
+	}
+	if got := keyNames(c); !reflect.DeepEqual(got, []string{IdentityKey}) || !Enabled(c) {
+		t.Errorf("disabled keys = %v, want [identity]", got)
+	}
+	if cfg := c.ClusterCredential.KubeData[constants.EncryptionConfigFile]; strings.Contains(cfg, "kms") || !strings.Contains(cfg, "identity") {
+		t.Errorf("disabled config = %s", cfg)
+	}
+}
+
+func TestBuildConfig(t *testing.T) {
+	data, err := BuildConfig([]devopsv1.EncryptionKey{
+		{Name: "key1", Secret: []byte("0123456789abcdef0123456789abcdef")},
+		{Name: "key0", Secret: []byte("fedcba9876543210fedcba9876543210")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := string(data)
+	for _, want := range []string{"kind: EncryptionConfiguration", "apiVersion: apiserver.config.k8s.io/v1", "- secrets", "name: key1", "identity: {}"} {
+		if !strings.Contains(cfg, want) {
+			t.Errorf("BuildConfig() = %s, missing %q", cfg, want)
+		}
+	}
+	if strings.Index(cfg, "key1") > strings.Index(cfg, "key0") || strings.Index(cfg, "key0") > strings.Index(cfg, "identity") {
+		t.Errorf("BuildConfig() providers out of order: %s", cfg)
+	}
+}
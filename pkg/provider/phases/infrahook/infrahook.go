@@ -0,0 +1,269 @@
+// Package infrahook runs the infrastructure hooks of the clusters as the Jobs in their namespaces, the outputs
+// of a hook are read from the termination message of its Job, e.g. the ips of the masters.
+package infrahook
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/logs"
+	"github.com/gostship/kunkka/pkg/timeouts"
+	"github.com/gostship/kunkka/pkg/util/k8sutil"
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// the phases of the hooks, the suffixes of the names of their Jobs
+	PhasePre  = "pre"
+	PhasePost = "post"
+
+	// Workspace is the working directory of the Jobs with the files of the ConfigMap of the hook
+	Workspace = "/workspace"
+	// configDir is where the ConfigMap of the hook is mounted in the init container
+	configDir = "/config"
+
+	// maxJobName the max length of the names of the Jobs, they are the values of the job-name label of the pods
+	maxJobName = 63
+)
+
+// Outputs are the outputs of a hook by name.
+type Outputs map[string]json.RawMessage
+
+// Run runs the Job of the hook of the phase and waits it to complete, then returns its outputs. The Job
+// completed before is not run again, the failed one is deleted so the retry of the phase runs it again.
+func Run(ctx context.Context, c *common.Cluster, phase string, hook *devopsv1.InfraHook) (Outputs, error) {
+	name := JobName(c.Name, phase)
+	key := client.ObjectKey{Namespace: c.Namespace, Name: name}
+	var outputs Outputs
+	err := timeouts.Poll(c.Cluster, timeouts.InfraHook, func() (bool, error) {
+		job := &batchv1.Job{}
+		err := c.Client.Get(ctx, key, job)
+		if apierrors.IsNotFound(err) {
+			logs.FromContext(ctx).Info("run infra hook", "job", name)
+			err = c.Client.Create(ctx, newJob(c, name, hook))
+			if err != nil && !apierrors.IsAlreadyExists(err) {
+				return false, errors.Wrapf(err, "create infra hook job: %s", name)
+			}
+			return false, nil
+		}
+		if err != nil {
+			return false, errors.Wrapf(err, "get infra hook job: %s", name)
+		}
+		if !metav1.IsControlledBy(job, c.Cluster) {
+			return false, fmt.Errorf("infra hook job: %s is not owned by cluster: %s", name, c.Name)
+		}
+
+		switch {
+		case job.Status.Succeeded > 0:
+			msg, err := terminationMessage(ctx, c, job)
+			if err != nil {
+				return false, err
+			}
+			outputs, err = parseOutputs(msg)
+			if err != nil {
+				return false, errors.Wrapf(err, "outputs of infra hook job: %s", name)
+			}
+			return true, nil
+		case job.Status.Failed > 0:
+			msg, _ := terminationMessage(ctx, c, job)
+			// the pods are deleted with the Job, their messages are kept in the error
+			policy := metav1.DeletePropagationBackground
+			err := c.Client.Delete(ctx, job, &client.DeleteOptions{PropagationPolicy: &policy})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return false, errors.Wrapf(err, "delete failed infra hook job: %s", name)
+			}
+			return false, fmt.Errorf("infra hook job: %s failed: %s", name, msg)
+		}
+		return false, nil
+	})
+	return outputs, err
+}
+
+// JobName returns the name of the Job of the hook of the phase of the cluster, the long names are truncated with
+// a hash suffix.
+func JobName(cluster, phase string) string {
+	name := fmt.Sprintf("%s-infra-%s", cluster, phase)
+	if len(name) <= maxJobName {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	suffix := hex.EncodeToString(sum[:])[:8]
+	return name[:maxJobName-len(suffix)-1] + "-" + suffix
+}
+
+// newJob renders the Job of the hook owned by the cluster, it's run once and its container falls back to the
+// tail of its log as the termination message on failure. The files of the ConfigMap are copied to the writable
+// workspace by an init container, e.g. terraform writes its plugins there.
+func newJob(c *common.Cluster, name string, hook *devopsv1.InfraHook) *batchv1.Job {
+	var ips []string
+	for _, m := range c.Spec.Machines {
+		if m != nil && m.IP != "" {
+			ips = append(ips, m.IP)
+		}
+	}
+	workspace := corev1.VolumeMount{Name: "workspace", MountPath: Workspace}
+	container := corev1.Container{
+		Name:       "hook",
+		Image:      hook.Image,
+		Command:    hook.Command,
+		WorkingDir: Workspace,
+		Env: []corev1.EnvVar{
+			{Name: "KUNKKA_CLUSTER", Value: c.Name},
+			{Name: "KUNKKA_NAMESPACE", Value: c.Namespace},
+			{Name: "KUNKKA_MASTER_IPS", Value: strings.Join(ips, ",")},
+		},
+		VolumeMounts:             []corev1.VolumeMount{workspace},
+		TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+	}
+	if hook.SecretName != "" {
+		container.EnvFrom = []corev1.EnvFromSource{{
+			SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: hook.SecretName}},
+		}}
+	}
+	pod := corev1.PodSpec{
+		RestartPolicy: corev1.RestartPolicyNever,
+		Containers:    []corev1.Container{container},
+		Volumes: []corev1.Volume{{
+			Name:         workspace.Name,
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		}},
+	}
+	if hook.ConfigMapName != "" {
+		config := corev1.VolumeMount{Name: "config", MountPath: configDir, ReadOnly: true}
+		pod.Volumes = append(pod.Volumes, corev1.Volume{
+			Name: config.Name,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: hook.ConfigMapName},
+				},
+			},
+		})
+		pod.InitContainers = []corev1.Container{{
+			Name:         "config",
+			Image:        hook.Image,
+			Command:      []string{"sh", "-c", fmt.Sprintf("cp %s/* %s/", configDir, Workspace)},
+			VolumeMounts: []corev1.VolumeMount{workspace, config},
+		}}
+	}
+
+	var backoffLimit int32
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: c.Namespace,
+			Name:      name,
+			Labels:    map[string]string{constants.CreatedByLabel: constants.CreatedBy},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion:         devopsv1.GroupVersion.String(),
+				Kind:               "Cluster",
+				Name:               c.Name,
+				UID:                c.UID,
+				Controller:         k8sutil.BoolPointer(true),
+				BlockOwnerDeletion: k8sutil.BoolPointer(true),
+			}},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				Spec: pod,
+			},
+		},
+	}
+}
+
+// terminationMessage returns the termination message of the completed pod of the Job.
+func terminationMessage(ctx context.Context, c *common.Cluster, job *batchv1.Job) (string, error) {
+	pods := &corev1.PodList{}
+	err := c.Client.List(ctx, pods, client.InNamespace(job.Namespace), client.MatchingLabels{"job-name": job.Name})
+	if err != nil {
+		return "", errors.Wrapf(err, "list pods of infra hook job: %s", job.Name)
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			continue
+		}
+		for _, s := range pod.Status.ContainerStatuses {
+			if s.State.Terminated != nil {
+				return strings.TrimSpace(s.State.Terminated.Message), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("infra hook job: %s has no completed pod", job.Name)
+}
+
+// parseOutputs parses the JSON object of the outputs, the hook without output has an empty message.
+func parseOutputs(msg string) (Outputs, error) {
+	outputs := Outputs{}
+	if msg == "" {
+		return outputs, nil
+	}
+	err := json.Unmarshal([]byte(msg), &outputs)
+	if err != nil {
+		return nil, err
+	}
+	return outputs, nil
+}
+
+// IPs returns the ips of the output of the name, it's a list of ips or an output of terraform whose value is.
+func (o Outputs) IPs(name string) ([]string, error) {
+	raw, ok := o[name]
+	if !ok {
+		return nil, fmt.Errorf("output: %s is not found", name)
+	}
+	tf := struct {
+		Value json.RawMessage `json:"value"`
+	}{}
+	if json.Unmarshal(raw, &tf) == nil && len(tf.Value) > 0 {
+		raw = tf.Value
+	}
+
+	var ips []string
+	err := json.Unmarshal(raw, &ips)
+	if err != nil {
+		return nil, errors.Wrapf(err, "output: %s is not a list of ips", name)
+	}
+	for _, ip := range ips {
+		if net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("output: %s has an invalid ip: %q", name, ip)
+		}
+	}
+	return ips, nil
+}
+
+// SetMachineIPs sets the ips in order to the masters without ip and writes them to spec.machines, the masters
+// given an ip by hand keep it.
+func SetMachineIPs(ctx context.Context, c *common.Cluster, ips []string) error {
+	set := func(cluster *devopsv1.Cluster) {
+		for i, m := range cluster.Spec.Machines {
+			if m != nil && m.IP == "" && i < len(ips) {
+				m.IP = ips[i]
+			}
+		}
+	}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &devopsv1.Cluster{}
+		if err := c.Client.Get(ctx, client.ObjectKey{Namespace: c.Namespace, Name: c.Name}, latest); err != nil {
+			return err
+		}
+		set(latest)
+		if err := c.Client.Update(ctx, latest); err != nil {
+			return err
+		}
+		c.Cluster.ResourceVersion = latest.ResourceVersion
+		set(c.Cluster)
+		return nil
+	})
+}
@@ -0,0 +1,158 @@
+package infrahook
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/providertest"
+	"github.com/gostship/kunkka/pkg/timeouts"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var hook = &devopsv1.InfraHook{
+	Image:            "hashicorp/terraform:0.13.5",
+	Command:          []string{"sh", "-c", "terraform apply -auto-approve && terraform output -json > /dev/termination-log"},
+	ConfigMapName:    "tf",
+	SecretName:       "cloud",
+	MachineIPsOutput: "master_ips",
+}
+
+func newCluster(objs ...runtime.Object) *common.Cluster {
+	return providertest.NewCluster(devopsv1.ClusterSpec{
+		Machines: []*devopsv1.ClusterMachine{{IP: "10.0.0.9"}, {}, {}},
+		Waits: map[string]devopsv1.WaitParam{
+			string(timeouts.InfraHook): {
+				Interval: metav1.Duration{Duration: 10 * time.Millisecond},
+				Timeout:  metav1.Duration{Duration: 50 * time.Millisecond},
+			},
+		},
+	}, objs...)
+}
+
+// completed returns the Job of the pre hook completed by the pod with the termination message.
+func completed(c *common.Cluster, failed bool, msg string) []runtime.Object {
+	job := newJob(c, JobName(c.Name, PhasePre), hook)
+	phase := corev1.PodSucceeded
+	job.Status.Succeeded = 1
+	if failed {
+		phase = corev1.PodFailed
+		job.Status = batchv1.JobStatus{Failed: 1}
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: c.Namespace, Name: job.Name + "-x1", Labels: map[string]string{"job-name": job.Name}},
+		Status: corev1.PodStatus{
+			Phase: phase,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: msg}},
+			}},
+		},
+	}
+	return []runtime.Object{job, pod}
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+
+	// the Job is created and waited
+	c := newCluster()
+	_, err := Run(ctx, c, PhasePre, hook)
+	if !timeouts.IsTimeout(err) {
+		t.Errorf("Run() error = %v, want timed out", err)
+	}
+	job := &batchv1.Job{}
+	if err := c.Client.Get(ctx, client.ObjectKey{Namespace: "dke", Name: "dke-infra-pre"}, job); err != nil {
+		t.Fatalf("get job error = %v", err)
+	}
+	pod := job.Spec.Template.Spec
+	if !metav1.IsControlledBy(job, c.Cluster) || len(pod.InitContainers) != 1 || pod.Containers[0].EnvFrom[0].SecretRef.Name != "cloud" {
+		t.Errorf("job = %+v, want owned by the cluster with the config and the secret", job)
+	}
+	if env := pod.Containers[0].Env[2]; env.Name != "KUNKKA_MASTER_IPS" || env.Value != "10.0.0.9" {
+		t.Errorf("env = %+v, want the ips of the masters", env)
+	}
+
+	// the outputs of the completed Job
+	c = newCluster()
+	c = newCluster(completed(c, false, `{"master_ips": {"sensitive": false, "type": ["list", "string"], "value": ["10.0.0.1", "10.0.0.2"]}}`)...)
+	outputs, err := Run(ctx, c, PhasePre, hook)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	ips, err := outputs.IPs(hook.MachineIPsOutput)
+	if err != nil || !reflect.DeepEqual(ips, []string{"10.0.0.1", "10.0.0.2"}) {
+		t.Errorf("IPs() = %v, %v, want the value of the terraform output", ips, err)
+	}
+
+	// the failed Job is deleted to run again
+	c = newCluster()
+	c = newCluster(completed(c, true, "Error: creating VLAN 100")...)
+	_, err = Run(ctx, c, PhasePre, hook)
+	if err == nil || !strings.Contains(err.Error(), "creating VLAN 100") {
+		t.Errorf("Run() error = %v, want the message of the failed job", err)
+	}
+	if err := c.Client.Get(ctx, client.ObjectKey{Namespace: "dke", Name: "dke-infra-pre"}, job); err == nil {
+		t.Errorf("failed job is not deleted")
+	}
+}
+
+func TestIPs(t *testing.T) {
+	outputs, err := parseOutputs(`{"plain": ["10.0.0.1"], "bad": ["10.0.0"], "name": "x"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "plain", want: []string{"10.0.0.1"}},
+		{name: "bad", wantErr: true},
+		{name: "name", wantErr: true},
+		{name: "missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := outputs.IPs(tt.name)
+			if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("IPs() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetMachineIPs(t *testing.T) {
+	ctx := context.Background()
+	c := newCluster()
+	if err := SetMachineIPs(ctx, c, []string{"10.0.0.1", "10.0.0.2"}); err != nil {
+		t.Fatalf("SetMachineIPs() error = %v", err)
+	}
+	latest := &devopsv1.Cluster{}
+	if err := c.Client.Get(ctx, client.ObjectKey{Namespace: "dke", Name: "dke"}, latest); err != nil {
+		t.Fatal(err)
+	}
+	// the master given an ip by hand keeps it, the ips are by the index of the masters
+	for _, got := range [][]*devopsv1.ClusterMachine{latest.Spec.Machines, c.Spec.Machines} {
+		if got[0].IP != "10.0.0.9" || got[1].IP != "10.0.0.2" || got[2].IP != "" {
+			t.Errorf("machines = %v %v %v", got[0].IP, got[1].IP, got[2].IP)
+		}
+	}
+}
+
+func TestJobName(t *testing.T) {
+	if got := JobName("dke", PhasePost); got != "dke-infra-post" {
+		t.Errorf("JobName() = %s", got)
+	}
+	long := JobName(strings.Repeat("a", 60), PhasePre)
+	if len(long) != maxJobName || long == JobName(strings.Repeat("a", 61), PhasePre) {
+		t.Errorf("JobName() = %s, want truncated with the hash", long)
+	}
+}
@@ -6,12 +6,14 @@ import (
 
 	"strings"
 
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
 	kubeadmv1beta2 "github.com/gostship/kunkka/pkg/apis/kubeadm/v1beta2"
 	"github.com/gostship/kunkka/pkg/constants"
 	"github.com/gostship/kunkka/pkg/controllers/common"
 	"github.com/gostship/kunkka/pkg/provider/config"
 	"github.com/gostship/kunkka/pkg/provider/phases/certs"
 	"github.com/gostship/kunkka/pkg/provider/phases/kubeadm"
+	"github.com/gostship/kunkka/pkg/provider/phases/kubemisc"
 	"github.com/gostship/kunkka/pkg/util/pkiutil"
 	"github.com/gostship/kunkka/pkg/util/ssh"
 	"github.com/gostship/kunkka/pkg/util/template"
@@ -37,7 +39,11 @@ func ApplyPodManifest(hostIP string, c *common.Cluster, cfg *config.Config, path
 }
 
 func BuildKubeletKubeconfig(hostIP string, c *common.Cluster, apiserver string, fileMaps map[string]string) error {
-	cfgMaps, err := certs.CreateKubeConfigFiles(c.ClusterCredential.CAKey, c.ClusterCredential.CACert,
+	issuer, err := certs.ClusterIssuer(c)
+	if err != nil {
+		return err
+	}
+	cfgMaps, err := certs.CreateKubeConfigFiles(issuer, c.ClusterCredential.CACert,
 		apiserver, hostIP, c.Cluster.Name, pkiutil.KubeletKubeConfigFileName)
 	if err != nil {
 		klog.Errorf("create node: %s kubelet kubeconfg err: %+v", hostIP, err)
@@ -64,6 +70,23 @@ func BuildKubeletKubeconfig(hostIP string, c *common.Cluster, apiserver string,
 	return nil
 }
 
+// BuildKubeletBootstrapKubeconfig builds the kubeconfig with the bootstrap token of the cluster,
+// the kubelet requests its own client certificate with it instead of a certificate signed by the operator.
+func BuildKubeletBootstrapKubeconfig(c *common.Cluster, apiserver string, fileMaps map[string]string) error {
+	if c.ClusterCredential.BootstrapToken == nil {
+		return fmt.Errorf("cluster: %s bootstrap token is nil", c.Cluster.Name)
+	}
+
+	cfg := kubemisc.CreateWithToken(apiserver, c.Cluster.Name, "kubelet-bootstrap", c.ClusterCredential.CACert, *c.ClusterCredential.BootstrapToken)
+	data, err := certs.BuildKubeConfigByte(cfg)
+	if err != nil {
+		return err
+	}
+
+	fileMaps[constants.KubeletBootstrapKubeConfigFileName] = string(data)
+	return nil
+}
+
 func JoinMasterNode(hostIP string, c *common.Cluster, cfg *config.Config, isMaster bool, fileMaps map[string]string) error {
 	if !isMaster {
 		fileMaps[constants.CACertName] = string(c.ClusterCredential.CACert)
@@ -85,7 +108,18 @@ func JoinMasterNode(hostIP string, c *common.Cluster, cfg *config.Config, isMast
 	return nil
 }
 
-func JoinNodePhase(s ssh.Interface, cfg *config.Config, c *common.Cluster, apiserver string, isMaster bool) error {
+// JoinNodePhase writes the kubelet files and starts the kubelet, kubelet is the fragment of the machine, nil uses the cluster defaults.
+func JoinNodePhase(s ssh.Interface, cfg *config.Config, c *common.Cluster, apiserver string, isMaster bool, kubelet *devopsv1.KubeletConfig) error {
+	return joinNode(s, cfg, c, apiserver, isMaster, false, kubelet)
+}
+
+// JoinNodeWithBootstrapToken joins the worker node like JoinNodePhase, but the kubelet authenticates with
+// the bootstrap token and gets its client certificate through the CSR approved by the cluster.
+func JoinNodeWithBootstrapToken(s ssh.Interface, cfg *config.Config, c *common.Cluster, apiserver string, kubelet *devopsv1.KubeletConfig) error {
+	return joinNode(s, cfg, c, apiserver, false, true, kubelet)
+}
+
+func joinNode(s ssh.Interface, cfg *config.Config, c *common.Cluster, apiserver string, isMaster bool, bootstrap bool, kubelet *devopsv1.KubeletConfig) error {
 	hostIP := s.HostIP()
 	fileMaps := make(map[string]string)
 	err := JoinMasterNode(hostIP, c, cfg, isMaster, fileMaps)
@@ -93,25 +127,40 @@ func JoinNodePhase(s ssh.Interface, cfg *config.Config, c *common.Cluster, apise
 		return errors.Wrapf(err, "node: %s failed build misc file", hostIP)
 	}
 
-	err = BuildKubeletKubeconfig(hostIP, c, apiserver, fileMaps)
+	serviceConfig := kubeletEnvironmentTemplate
+	if bootstrap {
+		serviceConfig = kubeletBootstrapEnvironmentTemplate
+		err = BuildKubeletBootstrapKubeconfig(c, apiserver, fileMaps)
+	} else {
+		err = BuildKubeletKubeconfig(hostIP, c, apiserver, fileMaps)
+	}
 	if err != nil {
 		return errors.Wrapf(err, "node: %s failed build kubelet file", hostIP)
 	}
 
 	nodeOpt := &kubeadmv1beta2.NodeRegistrationOptions{
-		Name: hostIP,
+		Name:      hostIP,
+		CRISocket: c.CRISocket(),
+	}
+	if kubelet != nil && kubelet.CgroupDriver != "" {
+		// the flag takes precedence over the config file
+		nodeOpt.KubeletExtraArgs = map[string]string{"cgroup-driver": kubelet.CgroupDriver}
 	}
 	flagsEnv := BuildKubeletDynamicEnvFile(cfg.Registry.Prefix, nodeOpt)
 	fileMaps[constants.KubeletEnvFileName] = flagsEnv
 
 	kubeletCfg := kubeadm.GetFullKubeletConfiguration(c)
+	if bootstrap {
+		kubeletCfg.RotateCertificates = true
+	}
+	kubeadm.ApplyKubeletConfig(kubeletCfg, kubelet)
 	cfgYaml, err := KubeletMarshal(kubeletCfg)
 	if err != nil {
 		return errors.Wrapf(err, "node: %s failed marshal kubelet file", hostIP)
 	}
 
 	fileMaps[constants.KubeletConfigurationFileName] = string(cfgYaml)
-	fileMaps[constants.KubeletServiceRunConfig] = kubeletEnvironmentTemplate
+	fileMaps[constants.KubeletServiceRunConfig] = serviceConfig
 
 	for pathName, va := range fileMaps {
 		klog.V(4).Infof("node: %s start write [%s] ...", hostIP, pathName)
@@ -25,6 +25,16 @@ EnvironmentFile=-/var/lib/kubelet/kubeadm-flags.env
 EnvironmentFile=-/etc/sysconfig/kubelet
 ExecStart=
 ExecStart=/usr/bin/kubelet $KUBELET_KUBECONFIG_ARGS $KUBELET_CONFIG_ARGS $KUBELET_KUBEADM_ARGS $KUBELET_EXTRA_ARGS
+`
+
+	kubeletBootstrapEnvironmentTemplate = `
+[Service]
+Environment="KUBELET_KUBECONFIG_ARGS=--bootstrap-kubeconfig=/etc/kubernetes/bootstrap-kubelet.conf --kubeconfig=/etc/kubernetes/kubelet.conf"
+Environment="KUBELET_CONFIG_ARGS=--config=/var/lib/kubelet/config.yaml"
+EnvironmentFile=-/var/lib/kubelet/kubeadm-flags.env
+EnvironmentFile=-/etc/sysconfig/kubelet
+ExecStart=
+ExecStart=/usr/bin/kubelet $KUBELET_KUBECONFIG_ARGS $KUBELET_CONFIG_ARGS $KUBELET_KUBEADM_ARGS $KUBELET_EXTRA_ARGS
 `
 )
 
@@ -112,7 +122,13 @@ func BuildKubeletDynamicEnvFile(imageRepository string, nodeReg *kubeadmv1beta2.
 	kubeletFlags := map[string]string{}
 
 	kubeletFlags["cgroup-driver"] = "systemd"
-	kubeletFlags["network-plugin"] = "cni"
+	if nodeReg.CRISocket == "" || nodeReg.CRISocket == constants.DefaultDockerCRISocket {
+		kubeletFlags["network-plugin"] = "cni"
+	} else {
+		// network-plugin only works with dockershim, the remote runtime sets up the pod network itself
+		kubeletFlags["container-runtime"] = "remote"
+		kubeletFlags["container-runtime-endpoint"] = "unix://" + nodeReg.CRISocket
+	}
 	// Pass the "--hostname-override" flag to the kubelet only if it's different from the hostname
 	nodeName, hostname, err := GetNodeNameAndHostname(nodeReg)
 	if err != nil {
@@ -2,11 +2,13 @@ package kubeadm
 
 import (
 	"bytes"
+	"context"
+	"crypto"
 	"fmt"
-	"time"
 
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdlatest "k8s.io/client-go/tools/clientcmd/api/latest"
@@ -15,6 +17,7 @@ import (
 
 	"os"
 
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
 	kubeadmv1beta2 "github.com/gostship/kunkka/pkg/apis/kubeadm/v1beta2"
 	"github.com/gostship/kunkka/pkg/constants"
 	"github.com/gostship/kunkka/pkg/controllers/common"
@@ -23,6 +26,7 @@ import (
 
 	"github.com/gostship/kunkka/pkg/provider/config"
 	"github.com/gostship/kunkka/pkg/provider/phases/certs"
+	"github.com/gostship/kunkka/pkg/timeouts"
 	"github.com/gostship/kunkka/pkg/util/k8sutil"
 	"github.com/gostship/kunkka/pkg/util/ssh"
 	"github.com/gostship/kunkka/pkg/util/template"
@@ -37,6 +41,7 @@ const (
 	joinControlPlaneCmd = `kubeadm join {{.ControlPlaneEndpoint}} \
 --node-name={{.NodeName}} --token={{.BootstrapToken}} \
 --control-plane --certificate-key={{.CertificateKey}} \
+--cri-socket={{.CRISocket}} \
 --skip-phases=control-plane-join/mark-control-plane \
 --discovery-token-unsafe-skip-ca-verification \
 --ignore-preflight-errors=ImagePull \
@@ -49,6 +54,9 @@ const (
 	joinNodeCmd = `kubeadm join {{.ControlPlaneEndpoint}} \
 --node-name={{.NodeName}} \
 --token={{.BootstrapToken}} \
+{{ if .CRISocket -}}
+--cri-socket={{.CRISocket}} \
+{{ end -}}
 --discovery-token-unsafe-skip-ca-verification \
 --ignore-preflight-errors=ImagePull \
 --ignore-preflight-errors=Port-10250 \
@@ -124,9 +132,26 @@ func InitCerts(cfg *Config, c *common.Cluster, isHosted bool) error {
 		certList = certs.GetCertsWithoutEtcd()
 	}
 
+	externalCA, err := loadExternalCA(c)
+	if err != nil {
+		return err
+	}
+
 	for _, cert := range certList {
 		if cert.CAName == "" {
-			ret, err := certs.CreateCACertAndKeyFiles(cert, warp, cfgMaps)
+			var ret *certs.CaAll
+			vault, err := certs.ClusterVaultIssuer(c, cert.Name)
+			if err != nil {
+				return err
+			}
+			switch {
+			case vault != nil:
+				ret, err = certs.CreateCACertFilesWithVault(cert, vault, warp, cfgMaps)
+			case externalCA != nil && cert.Name == certs.KubeadmCertRootCA.Name:
+				ret, err = certs.CreateCACertAndKeyFilesWithExternalCA(cert, externalCA.caCert, externalCA.caKey, externalCA.bundle, warp, cfgMaps)
+			default:
+				ret, err = certs.CreateCACertAndKeyFiles(cert, warp, cfgMaps)
+			}
 			if err != nil {
 				return err
 			}
@@ -142,7 +167,7 @@ func InitCerts(cfg *Config, c *common.Cluster, isHosted bool) error {
 		}
 	}
 
-	err := certs.CreateServiceAccountKeyAndPublicKeyFiles(cfg.ClusterConfiguration.CertificatesDir, x509.RSA, cfgMaps)
+	err = certs.CreateServiceAccountKeyAndPublicKeyFiles(cfg.ClusterConfiguration.CertificatesDir, x509.RSA, cfgMaps)
 	if err != nil {
 		return errors.Wrapf(err, "create sa public key")
 	}
@@ -182,15 +207,44 @@ func InitCerts(cfg *Config, c *common.Cluster, isHosted bool) error {
 
 		c.ClusterCredential.CertsBinaryData[pathFile] = v
 	}
+	ApplyOIDCCA(c)
 
 	return nil
 }
 
+type externalCA struct {
+	caCert *x509.Certificate
+	caKey  crypto.Signer
+	bundle []byte
+}
+
+// loadExternalCA returns the CA referenced by spec.externalCA, nil when the cluster generates its own CA.
+func loadExternalCA(c *common.Cluster) (*externalCA, error) {
+	if c.Spec.ExternalCA == nil {
+		return nil, nil
+	}
+
+	s := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: c.Cluster.Namespace, Name: c.Spec.ExternalCA.SecretName}
+	err := c.Client.Get(context.TODO(), key, s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get external ca secret: %s", key.String())
+	}
+
+	caCert, caKey, bundle, err := certs.LoadExternalCA(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &externalCA{caCert: caCert, caKey: caKey, bundle: bundle}, nil
+}
+
 type JoinControlPlaneOption struct {
 	NodeName             string
 	BootstrapToken       string
 	CertificateKey       string
 	ControlPlaneEndpoint string
+	CRISocket            string
 }
 
 func JoinControlPlane(s ssh.Interface, c *common.Cluster) error {
@@ -199,6 +253,7 @@ func JoinControlPlane(s ssh.Interface, c *common.Cluster) error {
 		CertificateKey:       *c.ClusterCredential.CertificateKey,
 		ControlPlaneEndpoint: fmt.Sprintf("%s:6443", c.Spec.Machines[0].IP),
 		NodeName:             s.HostIP(),
+		CRISocket:            c.CRISocket(),
 	}
 
 	cmd, err := template.ParseString(joinControlPlaneCmd, option)
@@ -218,6 +273,7 @@ type JoinNodeOption struct {
 	NodeName             string
 	BootstrapToken       string
 	ControlPlaneEndpoint string
+	CRISocket            string
 }
 
 func JoinNode(s ssh.Interface, option *JoinNodeOption) error {
@@ -234,7 +290,7 @@ func JoinNode(s ssh.Interface, option *JoinNodeOption) error {
 	return nil
 }
 
-func RenewCerts(s ssh.Interface) error {
+func RenewCerts(s ssh.Interface, c *common.Cluster) error {
 	err := fixKubeadmBug1753(s)
 	if err != nil {
 		return fmt.Errorf("fixKubeadmBug1753(https://github.com/kubernetes/kubeadm/issues/1753) error: %w", err)
@@ -246,7 +302,7 @@ func RenewCerts(s ssh.Interface) error {
 		return err
 	}
 
-	err = RestartControlPlane(s)
+	err = RestartControlPlane(s, c)
 	if err != nil {
 		return err
 	}
@@ -293,10 +349,10 @@ func fixKubeadmBug1753(s ssh.Interface) error {
 	return nil
 }
 
-func RestartControlPlane(s ssh.Interface) error {
+func RestartControlPlane(s ssh.Interface, c *common.Cluster) error {
 	targets := []string{"kube-apiserver", "kube-controller-manager", "kube-scheduler"}
 	for _, one := range targets {
-		err := RestartContainerByFilter(s, DockerFilterForControlPlane(one))
+		err := RestartControlPlaneComponent(s, c, one)
 		if err != nil {
 			return err
 		}
@@ -305,11 +361,21 @@ func RestartControlPlane(s ssh.Interface) error {
 	return nil
 }
 
+// RestartControlPlaneComponent removes the static pod container of the component and waits it recreated by kubelet.
+func RestartControlPlaneComponent(s ssh.Interface, c *common.Cluster, name string) error {
+	p := timeouts.Get(c.Cluster, timeouts.ContainerRestart)
+	if c.ContainerRuntimeType() == devopsv1.ContainerRuntimeDocker {
+		return RestartContainerByFilter(s, DockerFilterForControlPlane(name), p)
+	}
+
+	return RestartCRIContainerByLabel(s, CRILabelForControlPlane(name), p)
+}
+
 func DockerFilterForControlPlane(name string) string {
 	return fmt.Sprintf("label=io.kubernetes.container.name=%s", name)
 }
 
-func RestartContainerByFilter(s ssh.Interface, filter string) error {
+func RestartContainerByFilter(s ssh.Interface, filter string, p devopsv1.WaitParam) error {
 	cmd := fmt.Sprintf("docker rm -f $(docker ps -q -f '%s')", filter)
 	klog.V(4).Infof("node: %s, cmd: %s", s.HostIP(), cmd)
 	_, err := s.CombinedOutput(cmd)
@@ -317,7 +383,7 @@ func RestartContainerByFilter(s ssh.Interface, filter string) error {
 		return err
 	}
 
-	err = wait.PollImmediate(5*time.Second, 5*time.Minute, func() (bool, error) {
+	err = wait.PollImmediate(p.Interval.Duration, p.Timeout.Duration, func() (bool, error) {
 		cmd = fmt.Sprintf("docker ps -q -f '%s'", filter)
 		klog.V(4).Infof("wait node: %s, cmd: %s", s.HostIP(), cmd)
 		output, err := s.CombinedOutput(cmd)
@@ -336,6 +402,38 @@ func RestartContainerByFilter(s ssh.Interface, filter string) error {
 	return nil
 }
 
+func CRILabelForControlPlane(name string) string {
+	return fmt.Sprintf("io.kubernetes.container.name=%s", name)
+}
+
+// RestartCRIContainerByLabel is RestartContainerByFilter for the runtimes without docker cli, e.g. containerd.
+func RestartCRIContainerByLabel(s ssh.Interface, label string, p devopsv1.WaitParam) error {
+	cmd := fmt.Sprintf("crictl rm -f $(crictl ps -q --label '%s')", label)
+	klog.V(4).Infof("node: %s, cmd: %s", s.HostIP(), cmd)
+	_, err := s.CombinedOutput(cmd)
+	if err != nil {
+		return err
+	}
+
+	err = wait.PollImmediate(p.Interval.Duration, p.Timeout.Duration, func() (bool, error) {
+		cmd = fmt.Sprintf("crictl ps -q --label '%s'", label)
+		klog.V(4).Infof("wait node: %s, cmd: %s", s.HostIP(), cmd)
+		output, err := s.CombinedOutput(cmd)
+		if err != nil {
+			return false, nil
+		}
+		if len(output) == 0 {
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("restart container(%s) error: %w", label, err)
+	}
+
+	return nil
+}
+
 type Option struct {
 	HostIP           string
 	Images           string
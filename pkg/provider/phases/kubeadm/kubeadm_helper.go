@@ -2,17 +2,22 @@ package kubeadm
 
 import (
 	"bytes"
+	"path"
 	"reflect"
+	"strconv"
+	"strings"
 
 	"fmt"
 
 	"github.com/gostship/kunkka/pkg/apis"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
 	kubeadmv1beta2 "github.com/gostship/kunkka/pkg/apis/kubeadm/v1beta2"
 	kubeletv1beta1 "github.com/gostship/kunkka/pkg/apis/kubelet/config/v1beta1"
 	kubeproxyv1alpha1 "github.com/gostship/kunkka/pkg/apis/kubeproxy/config/v1alpha1"
 	"github.com/gostship/kunkka/pkg/constants"
 	"github.com/gostship/kunkka/pkg/controllers/common"
 	"github.com/gostship/kunkka/pkg/provider/config"
+	"github.com/gostship/kunkka/pkg/provider/phases/encryption"
 	"github.com/gostship/kunkka/pkg/util/json"
 	"github.com/gostship/kunkka/pkg/util/k8sutil"
 	corev1 "k8s.io/api/core/v1"
@@ -87,7 +92,8 @@ func GetInitConfiguration(c *common.Cluster) *kubeadmv1beta2.InitConfiguration {
 
 	if len(c.Cluster.Spec.Machines) > 0 {
 		initCfg.NodeRegistration = kubeadmv1beta2.NodeRegistrationOptions{
-			Name: c.Spec.Machines[0].IP,
+			Name:      c.Spec.Machines[0].IP,
+			CRISocket: c.CRISocket(),
 		}
 
 		initCfg.LocalAPIEndpoint = kubeadmv1beta2.APIEndpoint{
@@ -143,6 +149,17 @@ func GetClusterConfiguration(c *common.Cluster, cfg *config.Config, controlPlane
 		ClusterName:     c.Name,
 	}
 
+	if c.Spec.Encryption != nil && c.Spec.Encryption.KMS != nil {
+		// the socket of the kms plugin running on the masters
+		socketDir := path.Dir(strings.TrimPrefix(c.Spec.Encryption.KMS.Endpoint, "unix://"))
+		kubeadmCfg.APIServer.ExtraVolumes = append(kubeadmCfg.APIServer.ExtraVolumes, kubeadmv1beta2.HostPathMount{
+			Name:      "kms-dir-0",
+			HostPath:  socketDir,
+			MountPath: socketDir,
+			PathType:  corev1.HostPathDirectoryOrCreate,
+		})
+	}
+
 	utilruntime.Must(json.Merge(&kubeadmCfg.Etcd, &c.Spec.Etcd))
 
 	return kubeadmCfg
@@ -206,11 +223,55 @@ func GetFullKubeletConfiguration(c *common.Cluster) *kubeletv1beta1.KubeletConfi
 	}
 }
 
+// ApplyKubeletConfig overrides the kubelet configuration with the fragment of the machine.
+func ApplyKubeletConfig(cfg *kubeletv1beta1.KubeletConfiguration, kc *devopsv1.KubeletConfig) {
+	if kc == nil {
+		return
+	}
+
+	if kc.MaxPods != nil {
+		cfg.MaxPods = *kc.MaxPods
+	}
+	if kc.CgroupDriver != "" {
+		cfg.CgroupDriver = kc.CgroupDriver
+	}
+	if len(kc.EvictionHard) > 0 {
+		cfg.EvictionHard = kc.EvictionHard
+	}
+	if len(kc.EvictionSoft) > 0 {
+		cfg.EvictionSoft = kc.EvictionSoft
+		cfg.EvictionSoftGracePeriod = kc.EvictionSoftGracePeriod
+	}
+	if len(kc.KubeReserved) > 0 {
+		cfg.KubeReserved = kc.KubeReserved
+	}
+	if len(kc.SystemReserved) > 0 {
+		cfg.SystemReserved = kc.SystemReserved
+	}
+	if len(kc.FeatureGates) > 0 {
+		if cfg.FeatureGates == nil {
+			cfg.FeatureGates = make(map[string]bool)
+		}
+		for k, v := range kc.FeatureGates {
+			cfg.FeatureGates[k] = v
+		}
+	}
+}
+
 func GetAPIServerExtraArgs(c *common.Cluster) map[string]string {
 	args := map[string]string{
 		"token-auth-file": constants.TokenFile,
 	}
 
+	for k, v := range GetOIDCArgs(c) {
+		args[k] = v
+	}
+	for k, v := range GetAuditArgs(c) {
+		args[k] = v
+	}
+	for k, v := range GetEncryptionArgs(c) {
+		args[k] = v
+	}
 	for k, v := range c.Spec.APIServerExtraArgs {
 		args[k] = v
 	}
@@ -218,6 +279,100 @@ func GetAPIServerExtraArgs(c *common.Cluster) map[string]string {
 	return args
 }
 
+// GetOIDCArgs returns the oidc flags of the apiserver without "--", the extra args of the cluster take precedence.
+func GetOIDCArgs(c *common.Cluster) map[string]string {
+	oidc := c.Spec.OIDC
+	if oidc == nil {
+		return nil
+	}
+
+	args := map[string]string{
+		"oidc-issuer-url": oidc.IssuerURL,
+		"oidc-client-id":  oidc.ClientID,
+	}
+	if oidc.UsernameClaim != "" {
+		args["oidc-username-claim"] = oidc.UsernameClaim
+	}
+	if oidc.UsernamePrefix != "" {
+		args["oidc-username-prefix"] = oidc.UsernamePrefix
+	}
+	if oidc.GroupsClaim != "" {
+		args["oidc-groups-claim"] = oidc.GroupsClaim
+	}
+	if oidc.GroupsPrefix != "" {
+		args["oidc-groups-prefix"] = oidc.GroupsPrefix
+	}
+	// the flag is repeated for each claim, which the map of the extra args can't hold
+	for k, v := range oidc.RequiredClaims {
+		args["oidc-required-claim"] = k + "=" + v
+	}
+	if oidc.CA != "" {
+		args["oidc-ca-file"] = constants.OIDCCACertName
+	}
+	return args
+}
+
+// GetAuditArgs returns the audit flags of the apiserver without "--", the audit is disabled if the cluster doesn't set it.
+func GetAuditArgs(c *common.Cluster) map[string]string {
+	audit := c.Spec.Audit
+	if audit == nil {
+		return nil
+	}
+
+	maxAge := audit.MaxAge
+	if maxAge == 0 {
+		maxAge = 7
+	}
+	args := map[string]string{
+		"audit-policy-file":   constants.AuditPolicyConfigFile,
+		"audit-log-path":      constants.AuditLogFile,
+		"audit-log-maxage":    strconv.Itoa(int(maxAge)),
+		"audit-log-maxbackup": "10",
+		"audit-log-maxsize":   "100",
+	}
+	if audit.Webhook != nil {
+		mode := audit.Webhook.Mode
+		if mode == "" {
+			mode = "batch"
+		}
+		args["audit-webhook-config-file"] = constants.AuditWebhookConfigFile
+		args["audit-webhook-mode"] = mode
+	}
+	return args
+}
+
+// GetEncryptionArgs returns the encryption flags of the apiserver without "--".
+func GetEncryptionArgs(c *common.Cluster) map[string]string {
+	if !encryption.Enabled(c) {
+		return nil
+	}
+	return map[string]string{
+		"encryption-provider-config": constants.EncryptionConfigFile,
+	}
+}
+
+// ApplyOIDCCA keeps the CA of the oidc issuer in the certs of the cluster written to the masters,
+// it returns whether the certs are changed.
+func ApplyOIDCCA(c *common.Cluster) bool {
+	current, ok := c.ClusterCredential.CertsBinaryData[constants.OIDCCACertName]
+	if c.Spec.OIDC == nil || c.Spec.OIDC.CA == "" {
+		if !ok {
+			return false
+		}
+		delete(c.ClusterCredential.CertsBinaryData, constants.OIDCCACertName)
+		return true
+	}
+
+	if ok && string(current) == c.Spec.OIDC.CA {
+		return false
+	}
+	if c.ClusterCredential.CertsBinaryData == nil {
+		c.ClusterCredential.CertsBinaryData = make(map[string][]byte)
+	}
+	c.ClusterCredential.CertsBinaryData[constants.OIDCCACertName] = []byte(c.Spec.OIDC.CA)
+	return true
+}
+
 func GetControllerManagerExtraArgs(c *common.Cluster) map[string]string {
 	args := map[string]string{}
 
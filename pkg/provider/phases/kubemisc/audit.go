@@ -0,0 +1,145 @@
+package kubemisc
+
+import (
+	"context"
+
+	"github.com/ghodss/yaml"
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/phases/certs"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	auditv1 "k8s.io/apiserver/pkg/apis/audit/v1"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultAuditPolicy logs the metadata of all the requests, used when the cluster sets no policy.
+	DefaultAuditPolicy = `
+apiVersion: audit.k8s.io/v1
+kind: Policy
+rules:
+- level: Metadata
+`
+
+	// AuditPolicyKey the key of the policy in the referenced ConfigMap if not set
+	AuditPolicyKey = "policy.yaml"
+
+	auditWebhookName = "audit-webhook"
+)
+
+// ParseAuditPolicy validates the audit policy yaml.
+func ParseAuditPolicy(data string) (*auditv1.Policy, error) {
+	policy := &auditv1.Policy{}
+	err := yaml.Unmarshal([]byte(data), policy)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unmarshal audit policy")
+	}
+	if policy.APIVersion != auditv1.SchemeGroupVersion.String() || policy.Kind != "Policy" {
+		return nil, errors.Errorf("audit policy must be %s Policy, got %s %s", auditv1.SchemeGroupVersion, policy.APIVersion, policy.Kind)
+	}
+	if len(policy.Rules) == 0 {
+		return nil, errors.New("audit policy has no rules")
+	}
+	for i, rule := range policy.Rules {
+		switch rule.Level {
+		case auditv1.LevelNone, auditv1.LevelMetadata, auditv1.LevelRequest, auditv1.LevelRequestResponse:
+		default:
+			return nil, errors.Errorf("audit policy rule %d unsupported level: %q", i, rule.Level)
+		}
+	}
+	return policy, nil
+}
+
+// AuditPolicy returns the audit policy yaml of the cluster, the inline policy takes precedence over the ConfigMap.
+func AuditPolicy(ctx context.Context, cli client.Client, c *common.Cluster) (string, error) {
+	audit := c.Spec.Audit
+	if audit == nil || (audit.Policy == "" && audit.PolicyConfigMap == nil) {
+		return DefaultAuditPolicy, nil
+	}
+
+	policy := audit.Policy
+	if policy == "" {
+		ref := audit.PolicyConfigMap
+		key := ref.Key
+		if key == "" {
+			key = AuditPolicyKey
+		}
+
+		cm := &corev1.ConfigMap{}
+		err := cli.Get(ctx, client.ObjectKey{Namespace: c.Cluster.Namespace, Name: ref.Name}, cm)
+		if err != nil {
+			return "", errors.Wrapf(err, "get audit policy configmap: %s", ref.Name)
+		}
+		var ok bool
+		if policy, ok = cm.Data[key]; !ok {
+			return "", errors.Errorf("audit policy configmap: %s has no key: %s", ref.Name, key)
+		}
+	}
+
+	_, err := ParseAuditPolicy(policy)
+	if err != nil {
+		return "", err
+	}
+	return policy, nil
+}
+
+// BuildAuditWebhookKubeconfig returns the kubeconfig the apiserver posts the audit events with.
+func BuildAuditWebhookKubeconfig(webhook *devopsv1.AuditWebhook) ([]byte, error) {
+	cluster := &clientcmdapi.Cluster{
+		Server: webhook.Server,
+	}
+	if webhook.CA != "" {
+		cluster.CertificateAuthorityData = []byte(webhook.CA)
+	}
+
+	cfg := &clientcmdapi.Config{
+		Clusters: map[string]*clientcmdapi.Cluster{
+			auditWebhookName: cluster,
+		},
+		Contexts: map[string]*clientcmdapi.Context{
+			auditWebhookName: {
+				Cluster:  auditWebhookName,
+				AuthInfo: auditWebhookName,
+			},
+		},
+		AuthInfos: map[string]*clientcmdapi.AuthInfo{
+			auditWebhookName: {},
+		},
+		CurrentContext: auditWebhookName,
+	}
+	return certs.BuildKubeConfigByte(cfg)
+}
+
+// ApplyAuditMisc writes the audit policy and the webhook kubeconfig of the cluster to the KubeData,
+// the webhook kubeconfig is removed once the webhook is unset, returns whether the KubeData is changed.
+func ApplyAuditMisc(ctx context.Context, cli client.Client, c *common.Cluster) (bool, error) {
+	policy, err := AuditPolicy(ctx, cli, c)
+	if err != nil {
+		return false, err
+	}
+
+	webhook := ""
+	if c.Spec.Audit != nil && c.Spec.Audit.Webhook != nil {
+		by, err := BuildAuditWebhookKubeconfig(c.Spec.Audit.Webhook)
+		if err != nil {
+			return false, err
+		}
+		webhook = string(by)
+	}
+
+	if c.ClusterCredential.KubeData == nil {
+		c.ClusterCredential.KubeData = make(map[string]string)
+	}
+	changed := c.ClusterCredential.KubeData[constants.AuditPolicyConfigFile] != policy ||
+		c.ClusterCredential.KubeData[constants.AuditWebhookConfigFile] != webhook
+	c.ClusterCredential.KubeData[constants.AuditPolicyConfigFile] = policy
+	if webhook == "" {
+		delete(c.ClusterCredential.KubeData, constants.AuditWebhookConfigFile)
+	} else {
+		c.ClusterCredential.KubeData[constants.AuditWebhookConfigFile] = webhook
+	}
+	return changed, nil
+}
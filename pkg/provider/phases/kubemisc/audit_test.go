@@ -0,0 +1,61 @@
+package kubemisc
+
+import (
+	"testing"
+)
+
+func TestParseAuditPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		wantErr bool
+	}{
+		{
+			name:   "default",
+			policy: DefaultAuditPolicy,
+		},
+		{
+			name: "request level for secrets",
+			policy: `
+apiVersion: audit.k8s.io/v1
+kind: Policy
+omitStages: ["RequestReceived"]
+rules:
+- level: Request
+  resources:
+  - group: ""
+    resources: ["secrets"]
+- level: Metadata
+`,
+		},
+		{
+			name:    "no rules",
+			policy:  "apiVersion: audit.k8s.io/v1\nkind: Policy\n",
+			wantErr: true,
+		},
+		{
+			name:    "wrong kind",
+			policy:  "apiVersion: audit.k8s.io/v1\nkind: ConfigMap\nrules:\n- level: Metadata\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown level",
+			policy:  "apiVersion: audit.k8s.io/v1\nkind: Policy\nrules:\n- level: Everything\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid yaml",
+			policy:  "rules: [",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseAuditPolicy(tt.policy)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseAuditPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -18,15 +18,6 @@ import (
 	"k8s.io/klog"
 )
 
-const (
-	additPolicy = `
-apiVersion: audit.k8s.io/v1
-kind: Policy
-rules:
-- level: Metadata
-`
-)
-
 const (
 	tokenFileTemplate = `
 %s,admin,admin,system:masters
@@ -84,7 +75,11 @@ func ApplyKubeletKubeconfig(c *common.Cluster, apiserver string, kubeletNodeAddr
 		return fmt.Errorf("ca is nil")
 	}
 
-	cfgMaps, err := certs.CreateKubeletKubeConfigFile(c.ClusterCredential.CAKey, c.ClusterCredential.CACert,
+	issuer, err := certs.ClusterIssuer(c)
+	if err != nil {
+		return err
+	}
+	cfgMaps, err := certs.CreateKubeletKubeConfigFile(issuer, c.ClusterCredential.CACert,
 		apiserver, kubeletNodeAddr, c.Cluster.Name)
 	if err != nil {
 		klog.Errorf("create kubeconfg err: %+v", err)
@@ -109,7 +104,11 @@ func ApplyMasterMisc(c *common.Cluster, apiserver string) error {
 		return fmt.Errorf("ca is nil")
 	}
 
-	cfgMaps, err := certs.CreateMasterKubeConfigFile(c.ClusterCredential.CAKey, c.ClusterCredential.CACert,
+	issuer, err := certs.ClusterIssuer(c)
+	if err != nil {
+		return err
+	}
+	cfgMaps, err := certs.CreateMasterKubeConfigFile(issuer, c.ClusterCredential.CACert,
 		apiserver, c.Cluster.Name)
 	if err != nil {
 		klog.Errorf("create kubeconfg err: %+v", err)
@@ -131,11 +130,10 @@ func ApplyMasterMisc(c *common.Cluster, apiserver string) error {
 		c.ClusterCredential.KubeData[key] = string(by)
 	}
 
-	key := filepath.Join(constants.KubernetesDir, "audit-policy.yaml")
-	c.ClusterCredential.KubeData[key] = additPolicy
-
-	tokenData := fmt.Sprintf(tokenFileTemplate, *c.ClusterCredential.Token)
-	c.ClusterCredential.KubeData[constants.TokenFile] = tokenData
+	if c.ClusterCredential.Token != nil {
+		tokenData := fmt.Sprintf(tokenFileTemplate, *c.ClusterCredential.Token)
+		c.ClusterCredential.KubeData[constants.TokenFile] = tokenData
+	}
 	return nil
 }
 
@@ -0,0 +1,160 @@
+package system
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/util/ssh"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+)
+
+const (
+	// ArtifactRemove removes the artifact from the node
+	ArtifactRemove = "Remove"
+	// ArtifactRestrict restricts the artifact to be readable by root only
+	ArtifactRestrict = "Restrict"
+
+	restrictedMode = "600"
+
+	kubeletClientCert = constants.KubeletRunDirectory + "pki/kubelet-client-current.pem"
+	adminKubeconfig   = "/root/.kube/config"
+)
+
+// Artifact a secret left on the node by the provisioning.
+type Artifact struct {
+	// Path the file or the shell glob of the files
+	Path   string
+	Action string
+	// After the file which must exist before the artifact is removed, e.g. the kubelet client certificate
+	// requested with the bootstrap kubeconfig
+	After string
+	// Match only the files containing the string are cleaned, all the files if empty
+	Match string
+}
+
+// WorkerArtifacts returns the artifacts of the worker nodes, the admin kubeconfig is removed
+// if the static admin token it's written with is revoked, otherwise it's restricted.
+func WorkerArtifacts(tokenRevoked bool) []Artifact {
+	artifacts := []Artifact{
+		{Path: constants.KubeletBootstrapKubeConfigFileName, Action: ArtifactRemove, After: kubeletClientCert},
+		{Path: constants.TokenFile, Action: ArtifactRemove},
+		{Path: constants.KubeadmConfigFileName, Action: ArtifactRemove},
+		{Path: constants.CertificatesDir + "*.key", Action: ArtifactRemove},
+		{Path: constants.CertificatesDir + "etcd/*.key", Action: ArtifactRemove},
+		{Path: constants.KubeletKubeConfigFileName, Action: ArtifactRestrict},
+	}
+	if tokenRevoked {
+		artifacts = append(artifacts, Artifact{Path: adminKubeconfig, Action: ArtifactRemove, Match: "kubernetes-admin"})
+	} else {
+		artifacts = append(artifacts, Artifact{Path: adminKubeconfig, Action: ArtifactRestrict})
+	}
+	return artifacts
+}
+
+// CleanArtifacts removes or restricts the artifacts on the node, it returns what was cleaned and what is left behind.
+func CleanArtifacts(s ssh.Interface, artifacts []Artifact) (cleaned []string, leftover []string) {
+	for _, a := range artifacts {
+		out, err := s.CombinedOutput(fmt.Sprintf(`for f in %s; do [ -f "$f" ] && echo "$f"; done; true`, a.Path))
+		if err != nil {
+			leftover = append(leftover, fmt.Sprintf("%s: %v", a.Path, err))
+			continue
+		}
+
+		for _, file := range strings.Fields(string(out)) {
+			msg, done, err := cleanArtifact(s, a, file)
+			if err != nil {
+				leftover = append(leftover, fmt.Sprintf("%s: %v", file, err))
+			} else if done {
+				cleaned = append(cleaned, fmt.Sprintf("%s: %s", file, msg))
+			} else if msg != "" {
+				leftover = append(leftover, fmt.Sprintf("%s: %s", file, msg))
+			}
+		}
+	}
+	return cleaned, leftover
+}
+
+func cleanArtifact(s ssh.Interface, a Artifact, file string) (string, bool, error) {
+	if a.Match != "" {
+		_, _, exit, err := s.Execf("grep -qF %q %s", a.Match, file)
+		if err != nil {
+			return "", false, err
+		}
+		if exit != 0 {
+			return "", false, nil
+		}
+	}
+
+	switch a.Action {
+	case ArtifactRemove:
+		if a.After != "" {
+			ok, err := s.Exist(a.After)
+			if err != nil {
+				return "", false, err
+			}
+			if !ok {
+				return fmt.Sprintf("waiting for %s", a.After), false, nil
+			}
+		}
+		_, stderr, exit, err := s.Execf("rm -f %s", file)
+		if err != nil || exit != 0 {
+			return "", false, fmt.Errorf("remove exit: %d, stderr: %s, err: %v", exit, stderr, err)
+		}
+		return "removed", true, nil
+	case ArtifactRestrict:
+		out, err := s.CombinedOutput(fmt.Sprintf("stat -c %%a %s", file))
+		if err != nil {
+			return "", false, err
+		}
+		mode := strings.TrimSpace(string(out))
+		if mode == restrictedMode {
+			return "", false, nil
+		}
+		_, stderr, exit, err := s.Execf("chown root:root %s && chmod %s %s", file, restrictedMode, file)
+		if err != nil || exit != 0 {
+			return "", false, fmt.Errorf("restrict exit: %d, stderr: %s, err: %v", exit, stderr, err)
+		}
+		return fmt.Sprintf("mode %s -> %s", mode, restrictedMode), true, nil
+	}
+	return "", false, fmt.Errorf("unsupported action: %s", a.Action)
+}
+
+// ApplyArtifactsCleanup cleans the artifacts of the worker machine at most once per constants.ArtifactsCheckInterval
+// and reports the result in machine status, the masters of the cluster keep their keys.
+func ApplyArtifactsCleanup(machine *devopsv1.Machine, c *common.Cluster, artifacts []Artifact) error {
+	for _, m := range c.Spec.Machines {
+		if m.IP == machine.Spec.Machine.IP {
+			return nil
+		}
+	}
+
+	report := machine.Status.Artifacts
+	if report != nil && time.Since(report.LastCheckTime.Time) < constants.ArtifactsCheckInterval {
+		return nil
+	}
+
+	sh, err := machine.Spec.SSH()
+	if err != nil {
+		return err
+	}
+
+	cleaned, leftover := CleanArtifacts(sh, artifacts)
+	for _, item := range cleaned {
+		klog.Infof("node: %s artifact cleaned, %s", sh.HostIP(), item)
+	}
+	if len(leftover) > 0 {
+		klog.Warningf("node: %s artifacts left behind: %s", sh.HostIP(), strings.Join(leftover, "; "))
+	}
+
+	machine.Status.Artifacts = &devopsv1.MachineArtifacts{
+		Cleaned:       cleaned,
+		Leftover:      leftover,
+		LastCheckTime: metav1.Now(),
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/util/template"
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+)
+
+// DockerHub the registry of the images without registry host
+const DockerHub = "docker.io"
+
+// Registry is the pull configuration of a registry rendered into the container runtime config.
+type Registry struct {
+	Host      string
+	Endpoints []string
+	CA        string
+	Insecure  bool
+}
+
+// Server returns the upstream url of the registry.
+func (r Registry) Server() string {
+	if r.Host == DockerHub {
+		return "https://registry-1.docker.io"
+	}
+	return "https://" + r.Host
+}
+
+// Registries merges spec.registryMirrors with the mirrors and insecure registries of the container runtime,
+// the default mirrors are used for docker.io when none is configured. The result is sorted by host.
+func Registries(c *devopsv1.Cluster) []Registry {
+	mirrors := map[string]devopsv1.RegistryMirror{
+		DockerHub: {Endpoints: DefaultRegistryMirrors},
+	}
+	if rt := c.Spec.ContainerRuntime; rt != nil {
+		if len(rt.RegistryMirrors) > 0 {
+			mirrors[DockerHub] = devopsv1.RegistryMirror{Endpoints: rt.RegistryMirrors}
+		}
+		for _, host := range rt.InsecureRegistries {
+			m := mirrors[host]
+			m.Insecure = true
+			mirrors[host] = m
+		}
+	}
+	for host, m := range c.Spec.RegistryMirrors {
+		mirrors[host] = m
+	}
+
+	registries := make([]Registry, 0, len(mirrors))
+	for host, m := range mirrors {
+		registries = append(registries, Registry{
+			Host:      host,
+			Endpoints: m.Endpoints,
+			CA:        m.CA,
+			Insecure:  m.Insecure,
+		})
+	}
+	sort.Slice(registries, func(i, j int) bool {
+		return registries[i].Host < registries[j].Host
+	})
+
+	return registries
+}
+
+// setRegistryOption fills the registries and renders the config of the container runtime.
+func setRegistryOption(c *devopsv1.Cluster, option *Option) error {
+	option.Registries = Registries(c)
+
+	option.RegistryMirrors = nil
+	option.InsecureRegistryList = nil
+	quoted := []string{}
+	for _, r := range option.Registries {
+		if r.Host == DockerHub {
+			option.RegistryMirrors = r.Endpoints
+		}
+		if r.Insecure {
+			option.InsecureRegistryList = append(option.InsecureRegistryList, r.Host)
+			quoted = append(quoted, strconv.Quote(r.Host))
+		}
+	}
+	option.InsecureRegistries = strings.Join(quoted, ", ")
+
+	var err error
+	var data []byte
+	switch option.ContainerRuntime {
+	case devopsv1.ContainerRuntimeContainerd:
+		data, err = template.ParseString(containerdConfigTemplate, option)
+		option.ContainerdConfig = string(data)
+	case devopsv1.ContainerRuntimeCRIO:
+		data, err = template.ParseString(crioRegistriesTemplate, option)
+		option.CrioRegistries = string(data)
+	default:
+		data, err = template.ParseString(dockerDaemonTemplate, option)
+		option.DockerDaemon = string(data)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "render %s config", option.ContainerRuntime)
+	}
+
+	return nil
+}
+
+// RegistryFiles returns the registry config files of the container runtime by path.
+func RegistryFiles(option *Option) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	certsDir := constants.DockerCertsDir
+	switch option.ContainerRuntime {
+	case devopsv1.ContainerRuntimeContainerd:
+		certsDir = constants.ContainerdCertsDir
+		files[constants.ContainerdConfigFile] = []byte(option.ContainerdConfig)
+		for _, r := range option.Registries {
+			data, err := template.ParseString(containerdHostsTemplate, r)
+			if err != nil {
+				return nil, errors.Wrapf(err, "render hosts.toml of registry: %s", r.Host)
+			}
+			files[path.Join(certsDir, r.Host, "hosts.toml")] = data
+		}
+	case devopsv1.ContainerRuntimeCRIO:
+		certsDir = constants.CrioCertsDir
+		files[constants.CrioRegistriesFile] = []byte(option.CrioRegistries)
+	default:
+		files[constants.DockerDaemonFile] = []byte(option.DockerDaemon)
+	}
+
+	for _, r := range option.Registries {
+		if r.CA != "" {
+			files[path.Join(certsDir, r.Host, "ca.crt")] = []byte(r.CA)
+		}
+	}
+
+	return files, nil
+}
+
+func registryFilesChecksum(files map[string][]byte) string {
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	h := sha256.New()
+	for _, p := range paths {
+		fmt.Fprintf(h, "%s\n%d\n", p, len(files[p]))
+		h.Write(files[p])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ApplyRegistryMirrors renders the registry config of the cluster onto the machine and reloads the container runtime,
+// the machine is skipped when the config is unchanged since the last apply.
+func ApplyRegistryMirrors(ctx context.Context, machine *devopsv1.Machine, c *common.Cluster) error {
+	sh, err := machine.Spec.SSH()
+	if err != nil {
+		return err
+	}
+
+	option, err := NewOption(sh, c, machine.Spec.Kubelet)
+	if err != nil {
+		return err
+	}
+
+	files, err := RegistryFiles(option)
+	if err != nil {
+		return err
+	}
+
+	checksum := registryFilesChecksum(files)
+	if constants.GetAnnotationKey(machine.Annotations, constants.RegistryMirrorsChecksum) == checksum {
+		return nil
+	}
+
+	if option.ContainerRuntime == devopsv1.ContainerRuntimeContainerd {
+		// certs.d is owned by kunkka, the removed registries must not be left behind
+		_, err = sh.CombinedOutput(fmt.Sprintf("rm -rf %s", constants.ContainerdCertsDir))
+		if err != nil {
+			return errors.Wrapf(err, "node: %s clean %s", sh.HostIP(), constants.ContainerdCertsDir)
+		}
+	}
+
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		err = sh.WriteFile(bytes.NewReader(files[p]), p)
+		if err != nil {
+			return errors.Wrapf(err, "node: %s write %s", sh.HostIP(), p)
+		}
+	}
+
+	var cmd string
+	switch option.ContainerRuntime {
+	case devopsv1.ContainerRuntimeContainerd:
+		cmd = "systemctl restart containerd"
+	case devopsv1.ContainerRuntimeCRIO:
+		cmd = "systemctl reload crio"
+	default:
+		// registry-mirrors and insecure-registries are reloaded without restarting the containers
+		cmd = "systemctl reload docker"
+	}
+	_, err = sh.CombinedOutput(cmd)
+	if err != nil {
+		return errors.Wrapf(err, "node: %s exec %q", sh.HostIP(), cmd)
+	}
+
+	klog.Infof("cluster: %s node: %s registry mirrors applied, registries: %d", c.Cluster.Name, sh.HostIP(), len(option.Registries))
+	if machine.Annotations == nil {
+		machine.Annotations = map[string]string{}
+	}
+	machine.Annotations[constants.RegistryMirrorsChecksum] = checksum
+	return c.Client.Update(ctx, machine)
+}
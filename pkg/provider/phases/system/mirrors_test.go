@@ -0,0 +1,78 @@
+package system
+
+import (
+	"strings"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+)
+
+func TestRegistryFiles(t *testing.T) {
+	cluster := &devopsv1.Cluster{
+		Spec: devopsv1.ClusterSpec{
+			ContainerRuntime: &devopsv1.ContainerRuntime{
+				Type:               devopsv1.ContainerRuntimeContainerd,
+				InsecureRegistries: []string{"harbor.local"},
+			},
+			RegistryMirrors: map[string]devopsv1.RegistryMirror{
+				"quay.io": {
+					Endpoints: []string{"https://quay.mirror.example.com"},
+					CA:        "-----BEGIN CERTIFICATE-----",
+				},
+			},
+		},
+	}
+
+	option := &Option{ContainerRuntime: devopsv1.ContainerRuntimeContainerd, SandboxImage: "pause:3.2"}
+	if err := setRegistryOption(cluster, option); err != nil {
+		t.Fatal(err)
+	}
+	files, err := RegistryFiles(option)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{
+			name: "config path",
+			path: constants.ContainerdConfigFile,
+			want: `config_path = "/etc/containerd/certs.d"`,
+		},
+		{
+			name: "default docker.io mirrors",
+			path: "/etc/containerd/certs.d/docker.io/hosts.toml",
+			want: `[host."` + DefaultRegistryMirrors[0] + `"]`,
+		},
+		{
+			name: "insecure registry",
+			path: "/etc/containerd/certs.d/harbor.local/hosts.toml",
+			want: "skip_verify = true",
+		},
+		{
+			name: "mirror with ca",
+			path: "/etc/containerd/certs.d/quay.io/hosts.toml",
+			want: `ca = "/etc/containerd/certs.d/quay.io/ca.crt"`,
+		},
+		{
+			name: "ca file",
+			path: "/etc/containerd/certs.d/quay.io/ca.crt",
+			want: "BEGIN CERTIFICATE",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, ok := files[tt.path]
+			if !ok {
+				t.Fatalf("missing file: %s", tt.path)
+			}
+			if !strings.Contains(string(data), tt.want) {
+				t.Errorf("%s = %s, want contains %s", tt.path, data, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/util/ssh"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetOSInfo reads the os image and kernel of the node.
+func GetOSInfo(s ssh.Interface) (*devopsv1.MachineSystemInfo, error) {
+	kernel, err := s.CombinedOutput("uname -r")
+	if err != nil {
+		return nil, errors.Wrapf(err, "node: %s get kernel version", s.HostIP())
+	}
+
+	arch, err := s.CombinedOutput("uname -m")
+	if err != nil {
+		return nil, errors.Wrapf(err, "node: %s get architecture", s.HostIP())
+	}
+
+	osImage, err := s.CombinedOutput(". /etc/os-release && echo $PRETTY_NAME")
+	if err != nil {
+		return nil, errors.Wrapf(err, "node: %s get os image", s.HostIP())
+	}
+
+	return &devopsv1.MachineSystemInfo{
+		KernelVersion:   strings.TrimSpace(string(kernel)),
+		OSImage:         strings.TrimSpace(string(osImage)),
+		OperatingSystem: "linux",
+		Architecture:    strings.TrimSpace(string(arch)),
+	}, nil
+}
+
+// GetArch returns the hardware name of the node, i.e. the output of "uname -m", and its architecture,
+// the nodes of the unsupported architectures are errors.
+func GetArch(s ssh.Interface) (machine string, arch string, err error) {
+	out, err := s.CombinedOutput("uname -m")
+	if err != nil {
+		return "", "", errors.Wrapf(err, "node: %s get architecture", s.HostIP())
+	}
+
+	machine = strings.TrimSpace(string(out))
+	arch, ok := constants.ArchOf(machine)
+	if !ok {
+		return "", "", errors.Errorf("node: %s architecture: %s is not supported, supported: %v", s.HostIP(), machine, constants.Archs)
+	}
+	return machine, arch, nil
+}
+
+// ArchDir returns the directory of the binaries of the arch in the directory of the binaries, the amd64
+// binaries are in the directory itself and the others in the sub directory of the arch, e.g. /k8s/bin/arm64/.
+func ArchDir(dir, arch string) string {
+	if arch == constants.ArchAMD64 {
+		return dir
+	}
+	return path.Join(dir, arch) + "/"
+}
+
+// CheckOSDrift compares the current os/kernel with the provisioned one and the cluster baseline,
+// it returns the drifted items, empty means no drift.
+func CheckOSDrift(provisioned, current *devopsv1.MachineSystemInfo, baseline *devopsv1.OSBaseline) []string {
+	var reasons []string
+	if provisioned != nil {
+		if provisioned.KernelVersion != current.KernelVersion {
+			reasons = append(reasons, fmt.Sprintf("kernelVersion: %s -> %s", provisioned.KernelVersion, current.KernelVersion))
+		}
+		if provisioned.OSImage != current.OSImage {
+			reasons = append(reasons, fmt.Sprintf("osImage: %s -> %s", provisioned.OSImage, current.OSImage))
+		}
+	}
+
+	if baseline != nil {
+		if baseline.KernelVersion != "" && baseline.KernelVersion != current.KernelVersion {
+			reasons = append(reasons, fmt.Sprintf("kernelVersion: %s, baseline: %s", current.KernelVersion, baseline.KernelVersion))
+		}
+		if baseline.OSImage != "" && baseline.OSImage != current.OSImage {
+			reasons = append(reasons, fmt.Sprintf("osImage: %s, baseline: %s", current.OSImage, baseline.OSImage))
+		}
+	}
+
+	return reasons
+}
+
+// ApplyOSDrift refreshes the os info of the machine at most once per constants.OSDriftCheckInterval,
+// records the drift in machine status and requests the maintenance of the node when the cluster baseline is enforced.
+func ApplyOSDrift(ctx context.Context, machine *devopsv1.Machine, c *common.Cluster) error {
+	drift := machine.Status.OSDrift
+	if drift != nil && time.Since(drift.LastCheckTime.Time) < constants.OSDriftCheckInterval {
+		return nil
+	}
+
+	sh, err := machine.Spec.SSH()
+	if err != nil {
+		return err
+	}
+
+	current, err := GetOSInfo(sh)
+	if err != nil {
+		return err
+	}
+
+	machine.Status.MachineInfo.KernelVersion = current.KernelVersion
+	machine.Status.MachineInfo.OSImage = current.OSImage
+	machine.Status.MachineInfo.OperatingSystem = current.OperatingSystem
+	machine.Status.MachineInfo.Architecture = current.Architecture
+	if machine.Status.ProvisionedInfo == nil {
+		machine.Status.ProvisionedInfo = current.DeepCopy()
+	}
+
+	baseline := c.Cluster.Spec.Features.OSBaseline
+	reasons := CheckOSDrift(machine.Status.ProvisionedInfo, current, baseline)
+	newDrift := &devopsv1.MachineOSDrift{
+		Drifted:       len(reasons) > 0,
+		Reasons:       reasons,
+		LastCheckTime: metav1.Now(),
+	}
+	if newDrift.Drifted {
+		klog.Warningf("cluster: %s node: %s os drifted: %s", c.Cluster.Name, sh.HostIP(), strings.Join(reasons, "; "))
+	}
+
+	wasEnforced := drift != nil && drift.Enforced
+	enforce := newDrift.Drifted && baseline != nil && baseline.Enforce
+	if enforce != wasEnforced {
+		err = requestMaintenance(ctx, c, machine, enforce)
+		if err != nil {
+			return err
+		}
+	}
+	newDrift.Enforced = enforce
+
+	machine.Status.OSDrift = newDrift
+	return nil
+}
+
+// requestMaintenance sets or removes the os drift maintenance request of the machine, the machine controller
+// cordons the node while it's requested. The requests of other reasons are left alone.
+func requestMaintenance(ctx context.Context, c *common.Cluster, machine *devopsv1.Machine, request bool) error {
+	value := "null"
+	if request {
+		value = strconv.Quote(constants.MaintenanceOSDrift)
+	} else if machine.Annotations[constants.MachineMaintenance] != constants.MaintenanceOSDrift {
+		return nil
+	}
+
+	klog.Infof("cluster: %s machine: %s request maintenance: %t", c.Cluster.Name, machine.Name, request)
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%s}}}`, constants.MachineMaintenance, value)
+	obj := &devopsv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: machine.Name, Namespace: machine.Namespace}}
+	err := c.Client.Patch(ctx, obj, client.RawPatch(types.MergePatchType, []byte(patch)))
+	if err != nil {
+		return errors.Wrapf(err, "cluster: %s patch machine: %s", c.Cluster.Name, machine.Name)
+	}
+
+	// the status of the machine is written after the handlers, on top of the patched annotations
+	machine.Annotations = obj.Annotations
+	machine.ResourceVersion = obj.ResourceVersion
+	return nil
+}
@@ -0,0 +1,145 @@
+package system
+
+import (
+	"context"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCheckOSDrift(t *testing.T) {
+	current := &devopsv1.MachineSystemInfo{
+		KernelVersion: "4.19.113-300.el7.x86_64",
+		OSImage:       "CentOS Linux 7 (Core)",
+	}
+	type args struct {
+		provisioned *devopsv1.MachineSystemInfo
+		baseline    *devopsv1.OSBaseline
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{
+			name: "first check",
+			args: args{},
+			want: 0,
+		},
+		{
+			name: "same as provisioned",
+			args: args{
+				provisioned: current.DeepCopy(),
+			},
+			want: 0,
+		},
+		{
+			name: "kernel updated",
+			args: args{
+				provisioned: &devopsv1.MachineSystemInfo{
+					KernelVersion: "4.19.112-300.el7.x86_64",
+					OSImage:       "CentOS Linux 7 (Core)",
+				},
+			},
+			want: 1,
+		},
+		{
+			name: "match baseline",
+			args: args{
+				provisioned: current.DeepCopy(),
+				baseline: &devopsv1.OSBaseline{
+					KernelVersion: "4.19.113-300.el7.x86_64",
+				},
+			},
+			want: 0,
+		},
+		{
+			name: "kernel updated and not match baseline",
+			args: args{
+				provisioned: &devopsv1.MachineSystemInfo{
+					KernelVersion: "4.19.112-300.el7.x86_64",
+					OSImage:       "CentOS Linux 7 (Core)",
+				},
+				baseline: &devopsv1.OSBaseline{
+					KernelVersion: "4.19.112-300.el7.x86_64",
+					OSImage:       "CentOS Linux 8 (Core)",
+				},
+			},
+			want: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CheckOSDrift(tt.args.provisioned, current, tt.args.baseline)
+			if len(got) != tt.want {
+				t.Errorf("CheckOSDrift() = %v, want %d items", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestMaintenance(t *testing.T) {
+	drifted := &devopsv1.Machine{ObjectMeta: metav1.ObjectMeta{Namespace: "c1", Name: "10.0.0.1"}}
+	// the maintenance requested by the admin is not removed by the drift
+	manual := &devopsv1.Machine{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "c1",
+		Name:        "10.0.0.2",
+		Annotations: map[string]string{constants.MachineMaintenance: "kernel-upgrade"},
+	}}
+
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	devopsv1.AddToScheme(scheme)
+	cli := fake.NewFakeClientWithScheme(scheme, drifted, manual)
+	c := &common.Cluster{Cluster: &devopsv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "c1", Name: "c1"}}, Client: cli}
+	ctx := context.Background()
+
+	reason := func(m *devopsv1.Machine) string {
+		latest := &devopsv1.Machine{}
+		if err := cli.Get(ctx, types.NamespacedName{Namespace: m.Namespace, Name: m.Name}, latest); err != nil {
+			t.Fatal(err)
+		}
+		return latest.Annotations[constants.MachineMaintenance]
+	}
+
+	if err := requestMaintenance(ctx, c, drifted, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := reason(drifted); got != constants.MaintenanceOSDrift {
+		t.Errorf("maintenance = %q, want %q", got, constants.MaintenanceOSDrift)
+	}
+	if err := requestMaintenance(ctx, c, drifted, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := reason(drifted); got != "" {
+		t.Errorf("maintenance = %q, want removed", got)
+	}
+
+	if err := requestMaintenance(ctx, c, manual, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := reason(manual); got != "kernel-upgrade" {
+		t.Errorf("maintenance = %q, want kernel-upgrade", got)
+	}
+}
+
+func TestArchDir(t *testing.T) {
+	if got := ArchDir("/k8s-v1.18.5/bin/", constants.ArchAMD64); got != "/k8s-v1.18.5/bin/" {
+		t.Errorf("ArchDir() = %s, want the directory itself", got)
+	}
+	if got := ArchDir("/k8s/bin/", constants.ArchARM64); got != "/k8s/bin/arm64/" {
+		t.Errorf("ArchDir() = %s, want the sub directory of the arch", got)
+	}
+	for machine, want := range map[string]string{"x86_64": constants.ArchAMD64, "aarch64": constants.ArchARM64, "ppc64le": ""} {
+		if got, _ := constants.ArchOf(machine); got != want {
+			t.Errorf("ArchOf(%s) = %s, want %s", machine, got, want)
+		}
+	}
+}
@@ -4,44 +4,66 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 
+	"github.com/Masterminds/semver"
 	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
 	"github.com/gostship/kunkka/pkg/constants"
 	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/config"
 	"github.com/gostship/kunkka/pkg/util/ssh"
 	"github.com/gostship/kunkka/pkg/util/template"
 	"github.com/pkg/errors"
 	"k8s.io/klog"
 )
 
+var (
+	// DefaultRegistryMirrors the mirrors of docker.io used when the cluster has none
+	DefaultRegistryMirrors = []string{
+		"https://mirror.ccs.tencentyun.com",
+		"https://4xr1qpsp.mirror.aliyuncs.com",
+	}
+)
+
 type Option struct {
-	InsecureRegistries string
-	RegistryDomain     string
-	Options            string
-	K8sVersion         string
-	DockerVersion      string
-	Cgroupdriver       string
-	HostIP             string
-	KernelRepo         string
-	ResolvConf         string
-	CentosVersion      string
-	ExtraArgs          map[string]string
+	InsecureRegistries   string
+	InsecureRegistryList []string
+	RegistryMirrors      []string
+	Registries           []Registry
+	DockerDaemon         string
+	ContainerdConfig     string
+	CrioRegistries       string
+	RegistryDomain       string
+	Options              string
+	K8sVersion           string
+	DockerVersion        string
+	ContainerRuntime     devopsv1.ContainerRuntimeType
+	ContainerdVersion    string
+	CrioVersion          string
+	SandboxImage         string
+	CRISocket            string
+	Cgroupdriver         string
+	HostIP               string
+	// Arch the architecture of the node, e.g. amd64, and Machine its hardware name, e.g. x86_64
+	Arch          string
+	Machine       string
+	KernelRepo    string
+	ResolvConf    string
+	CentosVersion string
+	ExtraArgs     map[string]string
 }
 
 func Install(s ssh.Interface, c *common.Cluster) error {
-	dockerVersion := "19.03.9"
-	if v, ok := c.Spec.DockerExtraArgs["version"]; ok {
-		dockerVersion = v
-	}
-	option := &Option{
-		K8sVersion:    c.Spec.Version,
-		DockerVersion: dockerVersion,
-		Cgroupdriver:  "systemd", // cgroupfs or systemd
-		ExtraArgs:     c.Spec.KubeletExtraArgs,
-		HostIP:        s.HostIP(),
-		KernelRepo:    "yum-mirrors.example.com",
+	return InstallMachine(s, c, nil)
+}
+
+// InstallMachine is Install with the kubelet fragment of the machine, the container runtime uses its cgroup driver.
+func InstallMachine(s ssh.Interface, c *common.Cluster, kubelet *devopsv1.KubeletConfig) error {
+	option, err := NewOption(s, c, kubelet)
+	if err != nil {
+		return err
 	}
 
 	initData, err := template.ParseString(initShellTemplate, option)
@@ -87,12 +109,101 @@ func Install(s ssh.Interface, c *common.Cluster) error {
 	return nil
 }
 
+// NewOption returns the option of the init script of the machine, kubelet may be nil.
+func NewOption(s ssh.Interface, c *common.Cluster, kubelet *devopsv1.KubeletConfig) (*Option, error) {
+	dockerVersion := "19.03.9"
+	if v, ok := c.Spec.DockerExtraArgs["version"]; ok {
+		dockerVersion = v
+	}
+	option := &Option{
+		K8sVersion:       c.Spec.Version,
+		DockerVersion:    dockerVersion,
+		ContainerRuntime: c.ContainerRuntimeType(),
+		CRISocket:        c.CRISocket(),
+		Cgroupdriver:     "systemd", // cgroupfs or systemd
+		ExtraArgs:        c.Spec.KubeletExtraArgs,
+		HostIP:           s.HostIP(),
+		KernelRepo:       "yum-mirrors.example.com",
+	}
+
+	if kubelet != nil && kubelet.CgroupDriver != "" {
+		option.Cgroupdriver = kubelet.CgroupDriver
+	}
+
+	var err error
+	option.Machine, option.Arch, err = GetArch(s)
+	if err != nil {
+		return nil, err
+	}
+
+	err = setContainerRuntimeOption(c, option)
+	if err != nil {
+		return nil, err
+	}
+
+	err = setRegistryOption(c.Cluster, option)
+	if err != nil {
+		return nil, err
+	}
+
+	return option, nil
+}
+
+// setContainerRuntimeOption fills the version and sandbox options of the container runtime.
+func setContainerRuntimeOption(c *common.Cluster, option *Option) error {
+	var version string
+	rt := c.Spec.ContainerRuntime
+	if rt != nil {
+		version = rt.Version
+		option.SandboxImage = rt.SandboxImage
+	}
+
+	switch c.ContainerRuntimeType() {
+	case devopsv1.ContainerRuntimeContainerd:
+		option.ContainerdVersion = version
+	case devopsv1.ContainerRuntimeCRIO:
+		// cri-o versions follow the kubernetes minor versions
+		if version == "" {
+			v, err := semver.NewVersion(c.Spec.Version)
+			if err != nil {
+				return errors.Wrapf(err, "parse version: %s", c.Spec.Version)
+			}
+			version = fmt.Sprintf("%d.%d", v.Major(), v.Minor())
+		}
+		option.CrioVersion = version
+	}
+
+	if option.SandboxImage == "" {
+		cfg, err := config.NewDefaultConfig()
+		if err != nil {
+			return err
+		}
+		option.SandboxImage = cfg.RegistryImageFullName(c.Spec.Registry, "pause", constants.PauseVersion)
+	}
+
+	return nil
+}
+
+// CopyFile copies the file to the node, the file of the arch is copied from the directory of the arch of the
+// node, see ArchDir.
 func CopyFile(s ssh.Interface, file *devopsv1.File) error {
 	if ok, err := s.Exist(file.Dst); err == nil && ok {
 		return nil
 	}
 
-	err := s.CopyFile(file.Src, file.Dst)
+	src := file.Src
+	if file.Arch {
+		_, arch, err := GetArch(s)
+		if err != nil {
+			return err
+		}
+		src = path.Join(ArchDir(path.Dir(src), arch), path.Base(src))
+		if _, err := os.Stat(src); err != nil {
+			return errors.Wrapf(err, "node: %s arch: %s file", s.HostIP(), arch)
+		}
+	}
+
+	err := s.CopyFile(src, file.Dst)
 	if err != nil {
 		return err
 	}
@@ -11,11 +11,11 @@ function Update_yumrepo() {
     mv /etc/yum.repos.d/*.repo /etc/yum.repos.d/repoBakDir/
 	rm -rvf /etc/yum.repos.d/*.repo
     curl https://mirrors.aliyun.com/repo/epel-7.repo -o /etc/yum.repos.d/epel-7.repo
-    curl https://mirrors.aliyun.com/repo/Centos-{{ default "7" .CentosVersion }}.repo -o /etc/yum.repos.d/Centos-Base.repo
+    curl https://mirrors.aliyun.com/repo/Centos-{{ if ne .Arch "amd64" }}altarch-{{ end }}{{ default "7" .CentosVersion }}.repo -o /etc/yum.repos.d/Centos-Base.repo
     cat << EOF | tee /etc/yum.repos.d/Custom.repo
 [kernel]
 name=Linux Kernel Repository
-baseurl=http://{{ .KernelRepo }}/centos/{{ default "7" .CentosVersion }}/kernel/el7/x86_64/RPMS
+baseurl=http://{{ .KernelRepo }}/centos/{{ default "7" .CentosVersion }}/kernel/el7/{{ .Machine }}/RPMS
 enabled=1
 gpgcheck=0
 EOF
@@ -89,10 +89,9 @@ function Install_depend_environment(){
     echo -e "\033[32;32m 开始优化 k8s 内核参数 \033[0m \n"
 
     modprobe br_netfilter
-    echo "* soft nofile 1024000" >> /etc/security/limits.conf
-    echo "* hard nofile 1024000" >> /etc/security/limits.conf
-    echo "* soft nproc 1024000" >> /etc/security/limits.conf
-    echo "* hard nproc 1024000" >> /etc/security/limits.conf
+    for limit in "* soft nofile 1024000" "* hard nofile 1024000" "* soft nproc 1024000" "* hard nproc 1024000"; do
+      grep -qxF "$limit" /etc/security/limits.conf || echo "$limit" >> /etc/security/limits.conf
+    done
 
     echo "* soft nproc 1024000" > /etc/security/limits.d/90-nproc.conf
     echo "root soft nproc unlimited" >> /etc/security/limits.d/90-nproc.conf
@@ -168,8 +167,110 @@ function Install_docker(){
 
     echo -e "\033[32;32m 开始写 docker daemon.json\033[0m \n"
     mkdir -p /etc/docker
-    cat > /etc/docker/daemon.json <<EOF 
-{
+    cat > /etc/docker/daemon.json <<EOF
+{{ .DockerDaemon | trim }}
+EOF
+    systemctl enable docker && systemctl daemon-reload && systemctl restart docker
+}
+
+function Install_containerd(){
+    if [ -f /etc/containerd/config.toml ] && grep -q "kunkka" /etc/containerd/config.toml; then
+      echo -e "\033[32;32m 已完成containerd安装 \033[0m \n"
+      return
+    fi
+
+    echo -e "\033[32;32m 开始安装containerd \033[0m \n"
+    cat <<EOF | tee /etc/modules-load.d/containerd.conf
+overlay
+br_netfilter
+EOF
+    modprobe overlay
+    yum-config-manager --add-repo http://mirrors.aliyun.com/docker-ce/linux/centos/docker-ce.repo
+    yum makecache fast
+    yum install -y containerd.io{{ if .ContainerdVersion }}-{{ .ContainerdVersion }}{{ end }}
+
+    echo -e "\033[32;32m 开始写 containerd config.toml\033[0m \n"
+    mkdir -p /etc/containerd
+    cat > /etc/containerd/config.toml <<EOF
+{{ .ContainerdConfig | trim }}
+EOF
+    systemctl enable containerd && systemctl daemon-reload && systemctl restart containerd
+}
+
+function Install_crio(){
+    if [ -f /etc/crio/crio.conf.d/01-kunkka.conf ]; then
+      echo -e "\033[32;32m 已完成cri-o安装 \033[0m \n"
+      return
+    fi
+
+    echo -e "\033[32;32m 开始安装cri-o \033[0m \n"
+    cat <<EOF | tee /etc/modules-load.d/crio.conf
+overlay
+br_netfilter
+EOF
+    modprobe overlay
+    OS=CentOS_{{ default "7" .CentosVersion }}
+    curl -L -o /etc/yum.repos.d/devel:kubic:libcontainers:stable.repo \
+      https://download.opensuse.org/repositories/devel:/kubic:/libcontainers:/stable/${OS}/devel:kubic:libcontainers:stable.repo
+    curl -L -o /etc/yum.repos.d/devel:kubic:libcontainers:stable:cri-o:{{ .CrioVersion }}.repo \
+      https://download.opensuse.org/repositories/devel:kubic:libcontainers:stable:cri-o:{{ .CrioVersion }}/${OS}/devel:kubic:libcontainers:stable:cri-o:{{ .CrioVersion }}.repo
+    yum makecache fast
+    yum install -y "cri-o-{{ .CrioVersion }}*"
+
+    echo -e "\033[32;32m 开始写 crio.conf\033[0m \n"
+    # the pod network is set up by the cluster cni
+    rm -f /etc/cni/net.d/100-crio-bridge.conf /etc/cni/net.d/87-podman-bridge.conflist
+    mkdir -p /etc/crio/crio.conf.d
+    cat > /etc/crio/crio.conf.d/01-kunkka.conf <<EOF
+[crio.runtime]
+cgroup_manager = "{{ default "systemd" .Cgroupdriver }}"
+conmon_cgroup = "{{ if eq (default "systemd" .Cgroupdriver) "systemd" }}system.slice{{ else }}pod{{ end }}"
+
+[crio.image]
+pause_image = "{{ .SandboxImage }}"
+
+[crio.network]
+network_dir = "/etc/cni/net.d/"
+plugin_dirs = ["/opt/cni/bin/"]
+EOF
+
+    echo -e "\033[32;32m 开始写 registries.conf\033[0m \n"
+    mkdir -p /etc/containers
+    cat > /etc/containers/registries.conf <<EOF
+{{ .CrioRegistries | trim }}
+EOF
+    systemctl enable crio && systemctl daemon-reload && systemctl restart crio
+}
+
+function Config_crictl(){
+    cat > /etc/crictl.yaml <<EOF
+runtime-endpoint: unix://{{ .CRISocket }}
+image-endpoint: unix://{{ .CRISocket }}
+timeout: 10
+EOF
+}
+
+# 初始化顺序
+echo -e "\033[32;32m 开始初始化结点 @{{ .HostIP }}@ \033[0m \n"
+Update_yumrepo && \
+Firewalld_process && \
+Install_depend_software && \
+Install_ipvs && \
+Install_depend_environment && \
+{{ if eq .ContainerRuntime "containerd" -}}
+Install_containerd && \
+Config_crictl && \
+{{ else if eq .ContainerRuntime "cri-o" -}}
+Install_crio && \
+Config_crictl && \
+{{ else -}}
+Install_docker && \
+{{ end -}}
+Update_kernel
+`
+
+	// dockerDaemonTemplate is /etc/docker/daemon.json, docker only supports the mirrors of docker.io
+	dockerDaemonTemplate = `{
   "exec-opts": [
     "native.cgroupdriver={{ default "systemd" .Cgroupdriver }}"
   ],
@@ -186,8 +287,9 @@ function Install_docker(){
     "max-size": "100m"
   },
   "registry-mirrors": [
-    "https://mirror.ccs.tencentyun.com",
-    "https://4xr1qpsp.mirror.aliyuncs.com"
+    {{- range $i, $m := .RegistryMirrors }}{{ if $i }},{{ end }}
+    "{{ $m }}"
+    {{- end }}
   ],
 {{- if .InsecureRegistries }}
   "insecure-registries": [
@@ -201,18 +303,71 @@ function Install_docker(){
     "overlay2.override_kernel_check=true"
   ]
 }
-EOF
-    systemctl enable docker && systemctl daemon-reload && systemctl restart docker
-}
+`
 
-# 初始化顺序
-echo -e "\033[32;32m 开始初始化结点 @{{ .HostIP }}@ \033[0m \n"
-Update_yumrepo && \
-Firewalld_process && \
-Install_depend_software && \
-Install_ipvs && \
-Install_depend_environment && \
-Install_docker && \
-Update_kernel
+	// containerdConfigTemplate is /etc/containerd/config.toml, the registries are configured in certs.d
+	containerdConfigTemplate = `# generated by kunkka
+version = 2
+root = "/var/lib/containerd"
+state = "/run/containerd"
+
+[plugins."io.containerd.grpc.v1.cri"]
+  sandbox_image = "{{ .SandboxImage }}"
+  [plugins."io.containerd.grpc.v1.cri".containerd]
+    snapshotter = "overlayfs"
+    default_runtime_name = "runc"
+    [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc]
+      runtime_type = "io.containerd.runc.v2"
+      [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc.options]
+        SystemdCgroup = {{ eq (default "systemd" .Cgroupdriver) "systemd" }}
+  [plugins."io.containerd.grpc.v1.cri".registry]
+    config_path = "/etc/containerd/certs.d"
+`
+
+	// containerdHostsTemplate is /etc/containerd/certs.d/<host>/hosts.toml of a Registry
+	containerdHostsTemplate = `server = "{{ .Server }}"
+{{- if .CA }}
+ca = "/etc/containerd/certs.d/{{ .Host }}/ca.crt"
+{{- end }}
+{{- if .Insecure }}
+skip_verify = true
+{{- end }}
+{{- range .Endpoints }}
+
+[host."{{ . }}"]
+  capabilities = ["pull", "resolve"]
+{{- if $.CA }}
+  ca = "/etc/containerd/certs.d/{{ $.Host }}/ca.crt"
+{{- end }}
+{{- if $.Insecure }}
+  skip_verify = true
+{{- end }}
+{{- end }}
+{{- if .Insecure }}
+
+[host."http://{{ .Host }}"]
+  capabilities = ["pull", "resolve"]
+{{- end }}
+`
+
+	// crioRegistriesTemplate is /etc/containers/registries.conf
+	crioRegistriesTemplate = `unqualified-search-registries = ["docker.io"]
+{{- range $r := .Registries }}
+
+[[registry]]
+prefix = "{{ $r.Host }}"
+location = "{{ $r.Host }}"
+{{- if $r.Insecure }}
+insecure = true
+{{- end }}
+{{- range $r.Endpoints }}
+
+[[registry.mirror]]
+location = "{{ . | trimPrefix "https://" | trimPrefix "http://" }}"
+{{- if or $r.Insecure (hasPrefix "http://" .) }}
+insecure = true
+{{- end }}
+{{- end }}
+{{- end }}
 `
 )
@@ -0,0 +1,79 @@
+package system
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/util/ssh"
+)
+
+// fakeNode a node of the machine, the copied files are recorded by the dst.
+type fakeNode struct {
+	ssh.Interface
+	machine string
+	copied  map[string]string
+}
+
+func (f *fakeNode) HostIP() string {
+	return "10.0.0.1"
+}
+
+func (f *fakeNode) Exist(filename string) (bool, error) {
+	_, ok := f.copied[filename]
+	return ok, nil
+}
+
+func (f *fakeNode) CombinedOutput(cmd string) ([]byte, error) {
+	return []byte(f.machine + "\n"), nil
+}
+
+func (f *fakeNode) CopyFile(src, dst string) error {
+	f.copied[dst] = src
+	return nil
+}
+
+func (f *fakeNode) Execf(format string, a ...interface{}) (string, string, int, error) {
+	return "", "", 0, nil
+}
+
+func TestCopyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "copyfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"helm", "arm64/helm", "config.yaml"} {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name    string
+		machine string
+		file    devopsv1.File
+		want    string
+		wantErr bool
+	}{
+		{"amd64 binary", "x86_64", devopsv1.File{Src: dir + "/helm", Dst: "/usr/local/bin/helm", Arch: true}, dir + "/helm", false},
+		{"arm64 binary", "aarch64", devopsv1.File{Src: dir + "/helm", Dst: "/usr/local/bin/helm", Arch: true}, dir + "/arm64/helm", false},
+		{"arm64 binary missing", "aarch64", devopsv1.File{Src: dir + "/config.yaml", Dst: "/opt/bin/config.yaml", Arch: true}, "", true},
+		{"file not of the arch in bin", "aarch64", devopsv1.File{Src: dir + "/config.yaml", Dst: "/opt/bin/config.yaml"}, dir + "/config.yaml", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			node := &fakeNode{machine: c.machine, copied: map[string]string{}}
+			err := CopyFile(node, &c.file)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("CopyFile() error = %v, wantErr %v", err, c.wantErr)
+			}
+			if got := node.copied[c.file.Dst]; got != c.want {
+				t.Errorf("CopyFile() copied %q, want %q", got, c.want)
+			}
+		})
+	}
+}
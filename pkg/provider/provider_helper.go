@@ -1,15 +1,21 @@
 package provider
 
 import (
+	"github.com/gostship/kunkka/pkg/provider/aws"
 	baremetalcluster "github.com/gostship/kunkka/pkg/provider/baremetal/cluster"
 	baremetalmachine "github.com/gostship/kunkka/pkg/provider/baremetal/machine"
+	"github.com/gostship/kunkka/pkg/provider/capi"
 	"github.com/gostship/kunkka/pkg/provider/cluster"
 	clusterprovider "github.com/gostship/kunkka/pkg/provider/cluster"
 	"github.com/gostship/kunkka/pkg/provider/config"
 	hostedcluster "github.com/gostship/kunkka/pkg/provider/hosted/cluster"
 	hostedmachine "github.com/gostship/kunkka/pkg/provider/hosted/machine"
+	"github.com/gostship/kunkka/pkg/provider/libvirt"
 	"github.com/gostship/kunkka/pkg/provider/machine"
 	machineprovider "github.com/gostship/kunkka/pkg/provider/machine"
+	"github.com/gostship/kunkka/pkg/provider/managed"
+	"github.com/gostship/kunkka/pkg/provider/openstack"
+	"github.com/gostship/kunkka/pkg/provider/vsphere"
 )
 
 type ProviderManager struct {
@@ -24,9 +30,20 @@ var AddToMpManagerFuncs []func(*machineprovider.MpManager, *config.Config) error
 func NewProvider() (*ProviderManager, error) {
 	AddToCpManagerFuncs = append(AddToCpManagerFuncs, baremetalcluster.Add)
 	AddToCpManagerFuncs = append(AddToCpManagerFuncs, hostedcluster.Add)
+	AddToCpManagerFuncs = append(AddToCpManagerFuncs, vsphere.AddCluster)
+	AddToCpManagerFuncs = append(AddToCpManagerFuncs, openstack.AddCluster)
+	AddToCpManagerFuncs = append(AddToCpManagerFuncs, aws.AddCluster)
+	AddToCpManagerFuncs = append(AddToCpManagerFuncs, managed.AddCluster)
+	AddToCpManagerFuncs = append(AddToCpManagerFuncs, libvirt.AddCluster)
+	AddToCpManagerFuncs = append(AddToCpManagerFuncs, capi.AddCluster)
 
 	AddToMpManagerFuncs = append(AddToMpManagerFuncs, baremetalmachine.Add)
 	AddToMpManagerFuncs = append(AddToMpManagerFuncs, hostedmachine.Add)
+	AddToMpManagerFuncs = append(AddToMpManagerFuncs, vsphere.AddMachine)
+	AddToMpManagerFuncs = append(AddToMpManagerFuncs, openstack.AddMachine)
+	AddToMpManagerFuncs = append(AddToMpManagerFuncs, aws.AddMachine)
+	AddToMpManagerFuncs = append(AddToMpManagerFuncs, libvirt.AddMachine)
+	AddToMpManagerFuncs = append(AddToMpManagerFuncs, capi.AddMachine)
 
 	cfg, _ := config.NewDefaultConfig()
 	mgr := &ProviderManager{
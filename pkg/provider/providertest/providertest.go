@@ -0,0 +1,48 @@
+// Package providertest provides the clusters of the tests of the providers, the clusters and their objects are
+// served by a fake client.
+package providertest
+
+import (
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	Namespace = "dke"
+	Name      = "dke"
+	UID       = "uid-1"
+)
+
+// NewCluster returns the cluster of the spec, the fake client has the cluster and the objects.
+func NewCluster(spec devopsv1.ClusterSpec, objs ...runtime.Object) *common.Cluster {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	devopsv1.AddToScheme(scheme)
+
+	c := &devopsv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: Namespace, Name: Name, UID: UID},
+		Spec:       spec,
+	}
+	objs = append(objs, c.DeepCopy())
+	return &common.Cluster{Cluster: c, Client: fake.NewFakeClientWithScheme(scheme, objs...)}
+}
+
+// NewInfraCluster returns the cluster of the provider on the infrastructure, the secret of the infrastructure
+// has the data.
+func NewInfraCluster(provider string, in *devopsv1.Infrastructure, data map[string][]byte, objs ...runtime.Object) *common.Cluster {
+	spec := devopsv1.ClusterSpec{Type: provider, Infrastructure: in}
+	return NewCluster(spec, append(objs, Secret(in.SecretName, data))...)
+}
+
+// Secret returns the secret of the name in the namespace of the cluster.
+func Secret(name string, data map[string][]byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: Namespace, Name: name},
+		Data:       data,
+	}
+}
@@ -0,0 +1,224 @@
+package vsphere
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// ipWaitTimeout bounds the wait for the guest ip in a reconcile, the instance is polled again until it's set
+var ipWaitTimeout = 10 * time.Second
+
+// client is the govmomi client of a vCenter, it's logged in on the first call and again once the session expires.
+type client struct {
+	url      *url.URL
+	insecure bool
+
+	mu sync.Mutex
+	vc *govmomi.Client
+}
+
+func newClient(server string, insecure bool, username, password string) (*client, error) {
+	u, err := soap.ParseURL(server)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse vcenter url: %s", server)
+	}
+	u.User = url.UserPassword(username, password)
+	return &client{url: u, insecure: insecure}, nil
+}
+
+func (c *client) connect(ctx context.Context) (*govmomi.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.vc == nil {
+		vc, err := govmomi.NewClient(ctx, c.url, c.insecure)
+		if err != nil {
+			return nil, errors.Wrapf(err, "login vcenter: %s", c.url.Host)
+		}
+		c.vc = vc
+		return vc, nil
+	}
+
+	active, err := c.vc.SessionManager.SessionIsActive(ctx)
+	if err != nil || !active {
+		if err := c.vc.Login(ctx, c.url.User); err != nil {
+			return nil, errors.Wrapf(err, "login vcenter: %s", c.url.Host)
+		}
+	}
+	return c.vc, nil
+}
+
+// findVM returns the VM of the name in the whole inventory, it's nil if not found.
+func (c *client) findVM(ctx context.Context, name string) (*object.VirtualMachine, error) {
+	vc, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := view.NewManager(vc.Client).CreateContainerView(ctx, vc.ServiceContent.RootFolder, []string{"VirtualMachine"}, true)
+	if err != nil {
+		return nil, errors.Wrapf(err, "list vm: %s", name)
+	}
+	defer v.Destroy(ctx)
+
+	refs, err := v.Find(ctx, []string{"VirtualMachine"}, property.Filter{"name": name})
+	if err != nil {
+		return nil, errors.Wrapf(err, "list vm: %s", name)
+	}
+	if len(refs) == 0 {
+		return nil, nil
+	}
+	return object.NewVirtualMachine(vc.Client, refs[0]), nil
+}
+
+// clone clones the VM of the name from the template powered off and reconfigures its hardware, the fields of
+// the placement not set are those of the template.
+func (c *client) clone(ctx context.Context, vs *devopsv1.VSphereInfrastructure, name string) (*object.VirtualMachine, error) {
+	vc, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	finder := find.NewFinder(vc.Client, true)
+	dc, err := finder.DatacenterOrDefault(ctx, vs.Datacenter)
+	if err != nil {
+		return nil, errors.Wrapf(err, "find datacenter: %s", vs.Datacenter)
+	}
+	finder.SetDatacenter(dc)
+
+	template, err := finder.VirtualMachine(ctx, vs.Template)
+	if err != nil {
+		return nil, errors.Wrapf(err, "find template: %s", vs.Template)
+	}
+	folder, err := finder.FolderOrDefault(ctx, vs.Folder)
+	if err != nil {
+		return nil, errors.Wrapf(err, "find folder: %s", vs.Folder)
+	}
+
+	var pool *object.ResourcePool
+	switch {
+	case vs.ResourcePool != "":
+		pool, err = finder.ResourcePool(ctx, vs.ResourcePool)
+	case vs.Cluster != "":
+		var cluster *object.ClusterComputeResource
+		cluster, err = finder.ClusterComputeResource(ctx, vs.Cluster)
+		if err == nil {
+			pool, err = cluster.ResourcePool(ctx)
+		}
+	default:
+		pool, err = template.ResourcePool(ctx)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "find resource pool of vm: %s", name)
+	}
+	poolRef := pool.Reference()
+
+	spec := types.VirtualMachineCloneSpec{
+		Location: types.VirtualMachineRelocateSpec{Pool: &poolRef},
+	}
+	if vs.Datastore != "" {
+		ds, err := finder.Datastore(ctx, vs.Datastore)
+		if err != nil {
+			return nil, errors.Wrapf(err, "find datastore: %s", vs.Datastore)
+		}
+		dsRef := ds.Reference()
+		spec.Location.Datastore = &dsRef
+	}
+
+	task, err := template.Clone(ctx, folder, name, spec)
+	if err != nil {
+		return nil, errors.Wrapf(err, "clone vm: %s from template: %s", name, vs.Template)
+	}
+	info, err := task.WaitForResult(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "clone vm: %s from template: %s", name, vs.Template)
+	}
+	vm := object.NewVirtualMachine(vc.Client, info.Result.(types.ManagedObjectReference))
+
+	// the config of the clone spec is not applied by every vCenter, the hardware is reconfigured after cloned
+	if vs.NumCPUs > 0 || vs.MemoryMiB > 0 {
+		task, err := vm.Reconfigure(ctx, types.VirtualMachineConfigSpec{
+			NumCPUs:  vs.NumCPUs,
+			MemoryMB: vs.MemoryMiB,
+		})
+		if err == nil {
+			err = task.Wait(ctx)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "reconfigure vm: %s", name)
+		}
+	}
+	return vm, nil
+}
+
+// powerOn powers on the VM if it's not, e.g. cloned in the last reconcile which failed before powering it on.
+func (c *client) powerOn(ctx context.Context, vm *object.VirtualMachine) error {
+	state, err := vm.PowerState(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "get power state of vm: %s", vm.Reference().Value)
+	}
+	if state == types.VirtualMachinePowerStatePoweredOn {
+		return nil
+	}
+	task, err := vm.PowerOn(ctx)
+	if err == nil {
+		err = task.Wait(ctx)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "power on vm: %s", vm.Reference().Value)
+	}
+	return nil
+}
+
+// guestIP returns the ip of the guest OS of the VM, it's empty until the VMware Tools of the guest report it.
+func (c *client) guestIP(ctx context.Context, vm *object.VirtualMachine) (string, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, ipWaitTimeout)
+	defer cancel()
+
+	ip, err := vm.WaitForIP(waitCtx)
+	if err != nil {
+		if waitCtx.Err() != nil && ctx.Err() == nil {
+			return "", nil
+		}
+		return "", errors.Wrapf(err, "wait for ip of vm: %s", vm.Reference().Value)
+	}
+	return ip, nil
+}
+
+// deleteVM powers off and destroys the VM.
+func (c *client) deleteVM(ctx context.Context, vm *object.VirtualMachine) error {
+	state, err := vm.PowerState(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "get power state of vm: %s", vm.Reference().Value)
+	}
+	if state == types.VirtualMachinePowerStatePoweredOn {
+		task, err := vm.PowerOff(ctx)
+		if err == nil {
+			err = task.Wait(ctx)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "power off vm: %s", vm.Reference().Value)
+		}
+	}
+
+	task, err := vm.Destroy(ctx)
+	if err == nil {
+		err = task.Wait(ctx)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "destroy vm: %s", vm.Reference().Value)
+	}
+	return nil
+}
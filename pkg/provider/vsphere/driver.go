@@ -0,0 +1,125 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/infra"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// the keys of the infrastructure secret
+	usernameKey = "username"
+	passwordKey = "password"
+)
+
+// Driver clones the instances from the VM templates of the vCenters with govmomi, the VMs are found by name.
+type Driver struct {
+	mu sync.Mutex
+	// clients the clients of each vCenter and credential, their sessions are reused by the clusters
+	clients map[string]*client
+}
+
+var _ infra.Driver = &Driver{}
+
+func NewDriver() *Driver {
+	return &Driver{clients: make(map[string]*client)}
+}
+
+func (d *Driver) Validate(in *devopsv1.Infrastructure, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	vsPath := fldPath.Child("vsphere")
+	vs := in.VSphere
+	if vs == nil {
+		return append(allErrs, field.Required(vsPath, "must be set for the vsphere clusters"))
+	}
+	if u, err := url.Parse(vs.Server); err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		allErrs = append(allErrs, field.Invalid(vsPath.Child("server"), vs.Server, "must be the http(s) url of the vcenter"))
+	}
+	if vs.Template == "" {
+		allErrs = append(allErrs, field.Required(vsPath.Child("template"), ""))
+	}
+	if vs.NumCPUs < 0 {
+		allErrs = append(allErrs, field.Invalid(vsPath.Child("numCPUs"), vs.NumCPUs, "must be greater than or equal to 0"))
+	}
+	if vs.MemoryMiB < 0 {
+		allErrs = append(allErrs, field.Invalid(vsPath.Child("memoryMiB"), vs.MemoryMiB, "must be greater than or equal to 0"))
+	}
+	return allErrs
+}
+
+func (d *Driver) EnsureInstance(ctx context.Context, c *common.Cluster, spec *infra.InstanceSpec) (*infra.Instance, error) {
+	cli, err := d.client(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	vm, err := cli.findVM(ctx, spec.Name)
+	if err != nil {
+		return nil, err
+	}
+	if vm == nil {
+		vm, err = cli.clone(ctx, c.Spec.Infrastructure.VSphere, spec.Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cli.powerOn(ctx, vm); err != nil {
+		return nil, err
+	}
+	ip, err := cli.guestIP(ctx, vm)
+	if err != nil {
+		return nil, err
+	}
+	return &infra.Instance{ID: vm.Reference().Value, Name: spec.Name, IP: ip}, nil
+}
+
+func (d *Driver) DeleteInstance(ctx context.Context, c *common.Cluster, name string) error {
+	cli, err := d.client(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	vm, err := cli.findVM(ctx, name)
+	if err != nil || vm == nil {
+		return err
+	}
+	return cli.deleteVM(ctx, vm)
+}
+
+// client returns the client of the vCenter of the cluster with the credential of the infrastructure secret.
+func (d *Driver) client(ctx context.Context, c *common.Cluster) (*client, error) {
+	vs := c.Spec.Infrastructure.VSphere
+	s, err := infra.Secret(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	username, err := infra.SecretValue(s, usernameKey)
+	if err != nil {
+		return nil, err
+	}
+	password, err := infra.SecretValue(s, passwordKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// the updated credential gets a new client
+	key := fmt.Sprintf("%s\x00%t\x00%s\x00%s", vs.Server, vs.Insecure, username, password)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cli, ok := d.clients[key]
+	if !ok {
+		cli, err = newClient(vs.Server, vs.Insecure, username, password)
+		if err != nil {
+			return nil, err
+		}
+		d.clients[key] = cli
+	}
+	return cli, nil
+}
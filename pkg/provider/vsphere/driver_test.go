@@ -0,0 +1,116 @@
+package vsphere
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers/common"
+	"github.com/gostship/kunkka/pkg/provider/infra"
+	"github.com/gostship/kunkka/pkg/provider/providertest"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func newCluster(server string) *common.Cluster {
+	return providertest.NewInfraCluster(ProviderName, &devopsv1.Infrastructure{
+		SecretName: "vcenter",
+		VSphere: &devopsv1.VSphereInfrastructure{
+			Server:    server,
+			Insecure:  true,
+			Template:  "DC0_H0_VM0",
+			Datastore: "LocalDS_0",
+			NumCPUs:   4,
+		},
+	}, map[string][]byte{usernameKey: []byte("admin"), passwordKey: []byte("secret")})
+}
+
+func TestDriver(t *testing.T) {
+	model := simulator.VPX()
+	defer model.Remove()
+	if err := model.Create(); err != nil {
+		t.Fatal(err)
+	}
+	s := model.Service.NewServer()
+	defer s.Close()
+	// the guest ips are not reported by the simulator
+	ipWaitTimeout = 100 * time.Millisecond
+
+	ctx := context.Background()
+	c := newCluster((&url.URL{Scheme: s.URL.Scheme, Host: s.URL.Host, Path: s.URL.Path}).String())
+	d := NewDriver()
+	spec := &infra.InstanceSpec{Name: infra.MasterName(c.Name, 0), Role: infra.RoleMaster}
+
+	instance, err := d.EnsureInstance(ctx, c, spec)
+	if err != nil {
+		t.Fatalf("EnsureInstance() error = %v", err)
+	}
+	if instance.ID == "" || instance.Name != spec.Name || instance.IP != "" {
+		t.Errorf("EnsureInstance() = %+v, want %s booting", instance, spec.Name)
+	}
+
+	cli, err := d.client(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm, err := cli.findVM(ctx, spec.Name)
+	if err != nil || vm == nil {
+		t.Fatalf("findVM() = %v, %v, want the cloned vm", vm, err)
+	}
+	var mvm mo.VirtualMachine
+	if err := vm.Properties(ctx, vm.Reference(), []string{"config.hardware", "runtime.powerState"}, &mvm); err != nil {
+		t.Fatal(err)
+	}
+	if mvm.Config.Hardware.NumCPU != 4 || mvm.Runtime.PowerState != types.VirtualMachinePowerStatePoweredOn {
+		t.Errorf("vm has %d cpus and is %s, want 4 cpus powered on", mvm.Config.Hardware.NumCPU, mvm.Runtime.PowerState)
+	}
+
+	// the expired session is logged in again and the vm is found by name
+	if err := cli.vc.SessionManager.Logout(ctx); err != nil {
+		t.Fatal(err)
+	}
+	again, err := d.EnsureInstance(ctx, c, spec)
+	if err != nil {
+		t.Fatalf("EnsureInstance() error = %v", err)
+	}
+	if again.ID != instance.ID {
+		t.Errorf("EnsureInstance() = %+v, want the vm %s found by name", again, instance.ID)
+	}
+
+	if err := d.DeleteInstance(ctx, c, spec.Name); err != nil {
+		t.Fatalf("DeleteInstance() error = %v", err)
+	}
+	if vm, err := cli.findVM(ctx, spec.Name); err != nil || vm != nil {
+		t.Errorf("findVM() = %v, %v, want deleted", vm, err)
+	}
+	if err := d.DeleteInstance(ctx, c, spec.Name); err != nil {
+		t.Errorf("DeleteInstance() of the deleted vm error = %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	d := NewDriver()
+	tests := []struct {
+		name string
+		vs   *devopsv1.VSphereInfrastructure
+		errs int
+	}{
+		{name: "valid", vs: &devopsv1.VSphereInfrastructure{Server: "https://vcenter", Template: "tpl"}},
+		{name: "no vsphere", errs: 1},
+		{name: "no scheme", vs: &devopsv1.VSphereInfrastructure{Server: "vcenter", Template: "tpl"}, errs: 1},
+		{name: "no template", vs: &devopsv1.VSphereInfrastructure{Server: "https://vcenter"}, errs: 1},
+		{name: "negative", vs: &devopsv1.VSphereInfrastructure{Server: "https://vcenter", Template: "tpl", NumCPUs: -1, MemoryMiB: -1}, errs: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := d.Validate(&devopsv1.Infrastructure{SecretName: "vcenter", VSphere: tt.vs}, field.NewPath("spec", "infrastructure"))
+			if len(errs) != tt.errs {
+				t.Errorf("Validate() = %v, want %d errors", errs, tt.errs)
+			}
+		})
+	}
+}
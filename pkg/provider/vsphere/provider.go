@@ -0,0 +1,36 @@
+// Package vsphere provides the clusters whose machines are the VMs cloned from the VM templates of a vCenter
+// with govmomi.
+package vsphere
+
+import (
+	clusterprovider "github.com/gostship/kunkka/pkg/provider/cluster"
+	"github.com/gostship/kunkka/pkg/provider/config"
+	"github.com/gostship/kunkka/pkg/provider/infra"
+	machineprovider "github.com/gostship/kunkka/pkg/provider/machine"
+	"k8s.io/klog"
+)
+
+const ProviderName = "VSphere"
+
+// driver is shared by the cluster and machine providers, so are the sessions of the vCenters
+var driver = NewDriver()
+
+func AddCluster(mgr *clusterprovider.CpManager, cfg *config.Config) error {
+	p, err := infra.NewClusterProvider(mgr, cfg, ProviderName, driver)
+	if err != nil {
+		klog.Errorf("init cluster provider error: %s", err)
+		return err
+	}
+	mgr.Register(p.Name(), p)
+	return nil
+}
+
+func AddMachine(mgr *machineprovider.MpManager, cfg *config.Config) error {
+	p, err := infra.NewMachineProvider(mgr, cfg, ProviderName, driver)
+	if err != nil {
+		klog.Errorf("init machine provider error: %s", err)
+		return err
+	}
+	mgr.Register(p.Name(), p)
+	return nil
+}
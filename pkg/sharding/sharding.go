@@ -0,0 +1,157 @@
+// Package sharding assigns the Clusters to the shards of the controller replicas, each shard reconciles and
+// connects to its own Clusters and their Machines only, so that the member cluster clients and their caches
+// are spread over the replicas.
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// Options the shard of the replica, the sharding is disabled with less than 2 shards.
+type Options struct {
+	Shards int
+	Shard  int
+}
+
+func DefaultOptions() *Options {
+	return &Options{Shards: 1}
+}
+
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.IntVar(&o.Shards, "shards", o.Shards, "The number of the shards the Clusters are assigned to by the hash of their names or their k8s.io/shard label, less than 2 disables the sharding")
+	fs.IntVar(&o.Shard, "shard", o.Shard, "The index of the shard of the replica, from 0 to --shards - 1")
+}
+
+// Enabled returns whether the Clusters are sharded.
+func (o *Options) Enabled() bool {
+	return o.Shards > 1
+}
+
+func (o *Options) Validate() error {
+	if o.Enabled() && (o.Shard < 0 || o.Shard >= o.Shards) {
+		return errors.Errorf("shard %d out of [0, %d)", o.Shard, o.Shards)
+	}
+	return nil
+}
+
+// Of returns the shard of the cluster, the index of the k8s.io/shard label if valid, the hash of the name
+// otherwise.
+func Of(shards int, name string, labels map[string]string) int {
+	if shards <= 1 {
+		return 0
+	}
+	if v, ok := labels[constants.ShardLabel]; ok {
+		i, err := strconv.Atoi(v)
+		if err == nil && i >= 0 && i < shards {
+			return i
+		}
+		klog.V(4).Infof("cluster: %s invalid %s label: %q, assigned by hash", name, constants.ShardLabel, v)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// Sharder filters the Clusters and the Machines of the shard, a nil Sharder owns all of them.
+type Sharder struct {
+	opt    *Options
+	reader client.Reader
+}
+
+// New returns the Sharder of the shard, the clusters of the Machines are read by reader.
+func New(opt *Options, reader client.Reader) *Sharder {
+	return &Sharder{opt: opt, reader: reader}
+}
+
+func (s *Sharder) enabled() bool {
+	return s != nil && s.opt.Enabled()
+}
+
+// Owns returns whether the cluster is of the shard.
+func (s *Sharder) Owns(cluster metav1.Object) bool {
+	if !s.enabled() {
+		return true
+	}
+	return Of(s.opt.Shards, cluster.GetName(), cluster.GetLabels()) == s.opt.Shard
+}
+
+// OwnsMachine returns whether the cluster of the machine is of the shard, the machines of the missing
+// clusters are assigned by the hash of the cluster names.
+func (s *Sharder) OwnsMachine(m metav1.Object, clusterName string) bool {
+	if !s.enabled() {
+		return true
+	}
+	cluster := &devopsv1.Cluster{}
+	err := s.reader.Get(context.Background(), types.NamespacedName{Namespace: m.GetNamespace(), Name: clusterName}, cluster)
+	if err != nil {
+		cluster.Name = clusterName
+	}
+	return s.Owns(cluster)
+}
+
+// Finalizer returns the finalizer of the shard on the objects shared by the shards, e.g. the image pull secrets
+// distributed to the clusters of each shard. The shard 0 keeps the finalizer of the unsharded replica.
+func (s *Sharder) Finalizer(finalizer string) string {
+	if !s.enabled() || s.opt.Shard == 0 {
+		return finalizer
+	}
+	return fmt.Sprintf("%s-%d", finalizer, s.opt.Shard)
+}
+
+// ClusterPredicate filters the events of the Clusters of the shard, the updates moving a cluster out of the
+// shard pass so that its client is released.
+func (s *Sharder) ClusterPredicate() predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return s.Owns(e.Meta)
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return s.Owns(e.MetaOld) || s.Owns(e.MetaNew)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return s.Owns(e.Meta)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return s.Owns(e.Meta)
+		},
+	}
+}
+
+// MachinePredicate filters the events of the Machines of the Clusters of the shard.
+func (s *Sharder) MachinePredicate() predicate.Funcs {
+	owns := func(obj interface{}, meta metav1.Object) bool {
+		m, ok := obj.(*devopsv1.Machine)
+		if !ok {
+			return true
+		}
+		return s.OwnsMachine(meta, m.Spec.ClusterName)
+	}
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return owns(e.Object, e.Meta)
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return owns(e.ObjectNew, e.MetaNew)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return owns(e.Object, e.Meta)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return owns(e.Object, e.Meta)
+		},
+	}
+}
@@ -0,0 +1,114 @@
+package sharding
+
+import (
+	"context"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// clusterReader serves the clusters by name.
+type clusterReader map[string]*devopsv1.Cluster
+
+func (r clusterReader) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	c, ok := r[key.Name]
+	if !ok {
+		return apierrors.NewNotFound(schema.GroupResource{Resource: "clusters"}, key.Name)
+	}
+	c.DeepCopyInto(obj.(*devopsv1.Cluster))
+	return nil
+}
+
+func (r clusterReader) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	return nil
+}
+
+func TestOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		shards int
+		labels map[string]string
+		want   int
+	}{
+		{
+			name:   "unsharded",
+			shards: 1,
+			labels: map[string]string{constants.ShardLabel: "2"},
+			want:   0,
+		},
+		{
+			name:   "label",
+			shards: 3,
+			labels: map[string]string{constants.ShardLabel: "2"},
+			want:   2,
+		},
+		{
+			name:   "label out of range",
+			shards: 3,
+			labels: map[string]string{constants.ShardLabel: "3"},
+			want:   Of(3, "label out of range", nil),
+		},
+		{
+			name:   "invalid label",
+			shards: 3,
+			labels: map[string]string{constants.ShardLabel: "a"},
+			want:   Of(3, "invalid label", nil),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Of(tt.shards, tt.name, tt.labels); got != tt.want {
+				t.Errorf("Of() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	counts := make([]int, 4)
+	for i := 0; i < 400; i++ {
+		shard := Of(4, "cluster-"+string(rune('a'+i%26))+string(rune('a'+i/26)), nil)
+		if shard < 0 || shard >= 4 {
+			t.Fatalf("Of() = %d out of range", shard)
+		}
+		counts[shard]++
+	}
+	for shard, n := range counts {
+		if n == 0 {
+			t.Errorf("no cluster hashed to shard %d of %v", shard, counts)
+		}
+	}
+}
+
+func TestSharder(t *testing.T) {
+	var unsharded *Sharder
+	if !unsharded.Owns(&metav1.ObjectMeta{Name: "c1"}) || unsharded.Finalizer("f") != "f" {
+		t.Errorf("nil Sharder doesn't own all the clusters")
+	}
+
+	reader := clusterReader{
+		"c1": {ObjectMeta: metav1.ObjectMeta{Name: "c1", Labels: map[string]string{constants.ShardLabel: "1"}}},
+	}
+	s := New(&Options{Shards: 2, Shard: 1}, reader)
+	if !s.Owns(reader["c1"]) {
+		t.Errorf("Owns() = false for the cluster labeled with the shard")
+	}
+	m := &devopsv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "m1"}}
+	if !s.OwnsMachine(m, "c1") {
+		t.Errorf("OwnsMachine() = false for the machine of the cluster of the shard")
+	}
+	if got, want := s.OwnsMachine(m, "c2"), Of(2, "c2", nil) == 1; got != want {
+		t.Errorf("OwnsMachine() of the missing cluster = %v, want %v", got, want)
+	}
+	if got := s.Finalizer("f"); got != "f-1" {
+		t.Errorf("Finalizer() = %v, want f-1", got)
+	}
+	if got := New(&Options{Shards: 2}, reader).Finalizer("f"); got != "f" {
+		t.Errorf("Finalizer() of shard 0 = %v, want f", got)
+	}
+}
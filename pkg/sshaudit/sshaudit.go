@@ -0,0 +1,181 @@
+// Package sshaudit keeps the latest ssh commands run on the machines of each cluster
+// in a ring of bounded size stored in a ConfigMap next to the Cluster.
+package sshaudit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/pkg/util/ssh"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// DataKey the key of the records in the ConfigMap
+	DataKey = "records.json"
+
+	// DefaultRetention the default number of records kept per cluster, the ConfigMaps are limited to 1MiB
+	DefaultRetention = 100
+	// FlushInterval the period the buffered records are written to the ConfigMaps
+	FlushInterval = 10 * time.Second
+)
+
+// ConfigMapName returns the name of the ConfigMap holding the records of the cluster.
+func ConfigMapName(cluster string) string {
+	return fmt.Sprintf("sshaudit-%s", cluster)
+}
+
+// Append appends the records and drops the oldest ones beyond the retention.
+func Append(records []*ssh.Record, rs []*ssh.Record, retention int) []*ssh.Record {
+	records = append(records, rs...)
+	if retention > 0 && len(records) > retention {
+		records = append([]*ssh.Record{}, records[len(records)-retention:]...)
+	}
+	return records
+}
+
+// Decode returns the records of the ConfigMap.
+func Decode(cm *corev1.ConfigMap) ([]*ssh.Record, error) {
+	if cm == nil || cm.Data[DataKey] == "" {
+		return nil, nil
+	}
+
+	var records []*ssh.Record
+	err := json.Unmarshal([]byte(cm.Data[DataKey]), &records)
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Encode stores the records in the ConfigMap.
+func Encode(cm *corev1.ConfigMap, records []*ssh.Record) error {
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[DataKey] = string(data)
+	return nil
+}
+
+// Recorder buffers the records of the clusters and writes them to the ConfigMaps every FlushInterval,
+// so the commands don't wait for the apiserver. The ConfigMaps are garbage collected with the Clusters.
+type Recorder struct {
+	cli       client.Client
+	scheme    *runtime.Scheme
+	retention int
+
+	mu      sync.Mutex
+	pending map[string][]*ssh.Record
+}
+
+// NewRecorder ...
+func NewRecorder(cli client.Client, scheme *runtime.Scheme, retention int) *Recorder {
+	return &Recorder{
+		cli:       cli,
+		scheme:    scheme,
+		retention: retention,
+		pending:   make(map[string][]*ssh.Record),
+	}
+}
+
+// Audit buffers the record of a cluster, the records without cluster are only logged.
+func (r *Recorder) Audit(rec *ssh.Record) {
+	if rec.Cluster == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending[rec.Cluster] = Append(r.pending[rec.Cluster], []*ssh.Record{rec}, r.retention)
+}
+
+// Start flushes the records every FlushInterval till stop is closed, and once more on stop.
+func (r *Recorder) Start(stop <-chan struct{}) error {
+	wait.Until(r.Flush, FlushInterval, stop)
+	r.Flush()
+	return nil
+}
+
+// Flush writes the buffered records, the records of a cluster failed to write are dropped.
+func (r *Recorder) Flush() {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = make(map[string][]*ssh.Record)
+	r.mu.Unlock()
+
+	for cluster, records := range pending {
+		if err := r.save(context.Background(), cluster, records); err != nil {
+			klog.Warningf("cluster: %s save %d ssh audit records err: %v", cluster, len(records), err)
+		}
+	}
+}
+
+func (r *Recorder) save(ctx context.Context, cluster string, records []*ssh.Record) error {
+	key := types.NamespacedName{Namespace: cluster, Name: ConfigMapName(cluster)}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm := &corev1.ConfigMap{}
+		err := r.cli.Get(ctx, key, cm)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		exist := err == nil
+
+		if !exist {
+			c := &devopsv1.Cluster{}
+			err = r.cli.Get(ctx, types.NamespacedName{Namespace: cluster, Name: cluster}, c)
+			if err != nil {
+				return errors.Wrapf(err, "get cluster")
+			}
+			cm = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      key.Name,
+					Namespace: key.Namespace,
+					Labels: map[string]string{
+						constants.ClusterSSHAuditLabel: cluster,
+						constants.CreatedByLabel:       constants.CreatedBy,
+					},
+				},
+			}
+			err = controllerutil.SetControllerReference(c, cm, r.scheme)
+			if err != nil {
+				return err
+			}
+		}
+
+		saved, err := Decode(cm)
+		if err != nil {
+			// start over instead of getting stuck on a broken ring
+			klog.Warningf("cluster: %s decode ssh audit records err: %v, drop them", cluster, err)
+			saved = nil
+		}
+		err = Encode(cm, Append(saved, records, r.retention))
+		if err != nil {
+			return err
+		}
+
+		if exist {
+			return r.cli.Update(ctx, cm)
+		}
+		return r.cli.Create(ctx, cm)
+	})
+}
@@ -0,0 +1,67 @@
+package sshaudit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/util/ssh"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRecorder(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	devopsv1.AddToScheme(scheme)
+	cluster := &devopsv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "c1", Name: "c1", UID: "uid-c1"}}
+	cli := fake.NewFakeClientWithScheme(scheme, cluster)
+	r := NewRecorder(cli, scheme, 3)
+
+	audit := func(cluster string, n int) {
+		for i := 0; i < n; i++ {
+			r.Audit(&ssh.Record{Cluster: cluster, Host: "10.0.0.1", Command: fmt.Sprintf("cmd-%d", i)})
+		}
+	}
+	get := func() (*corev1.ConfigMap, []*ssh.Record) {
+		cm := &corev1.ConfigMap{}
+		if err := cli.Get(context.TODO(), types.NamespacedName{Namespace: "c1", Name: ConfigMapName("c1")}, cm); err != nil {
+			t.Fatal(err)
+		}
+		records, err := Decode(cm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cm, records
+	}
+
+	audit("c1", 2)
+	audit("", 1)
+	// the records of a deleted cluster are dropped
+	audit("c2", 1)
+	r.Flush()
+
+	cm, records := get()
+	if len(records) != 2 || records[0].Command != "cmd-0" {
+		t.Errorf("records: %v", records)
+	}
+	if refs := cm.OwnerReferences; len(refs) != 1 || refs[0].UID != "uid-c1" {
+		t.Errorf("owner references: %v", refs)
+	}
+
+	// the oldest records are dropped beyond the retention
+	audit("c1", 2)
+	r.Flush()
+	_, records = get()
+	if len(records) != 3 || records[0].Command != "cmd-1" || records[2].Command != "cmd-1" {
+		t.Errorf("records: %v", records)
+	}
+	if len(r.pending) != 0 {
+		t.Errorf("pending: %v", r.pending)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"io"
+	"os"
 	"strings"
 
 	"k8s.io/apimachinery/pkg/runtime/serializer/json"
@@ -94,3 +95,14 @@ func LoadCRDs() ([]*extensionsobj.CustomResourceDefinition, error) {
 
 	return crds, nil
 }
+
+// LoadCRDsFromFile loads the crds from a yaml bundle, e.g. manifests/crds/crd.yaml.
+func LoadCRDsFromFile(name string) ([]*extensionsobj.CustomResourceDefinition, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return load(f)
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// configMapDataKey the key of the object in the ConfigMap
+	configMapDataKey = "data"
+	// maxConfigMapSize leaves room for the metadata within the 1MiB limit of etcd
+	maxConfigMapSize = 900 * 1024
+)
+
+// ConfigMapStore keeps each object in a ConfigMap labeled with its kind,
+// the name is derived from the hash of the key which may not be a valid name.
+type ConfigMapStore struct {
+	cli       client.Client
+	namespace string
+}
+
+// NewConfigMapStore returns the store keeping the objects in the namespace of the meta cluster.
+func NewConfigMapStore(cli client.Client, namespace string) *ConfigMapStore {
+	return &ConfigMapStore{cli: cli, namespace: namespace}
+}
+
+func configMapName(kind, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("%s-%s", kind, hex.EncodeToString(sum[:10]))
+}
+
+func (s *ConfigMapStore) Put(ctx context.Context, kind, key string, data []byte) error {
+	if err := validate(kind, key); err != nil {
+		return err
+	}
+	if len(data) > maxConfigMapSize {
+		return errors.Errorf("object %s/%s is %d bytes, exceeds %d bytes of the configmap backend", kind, key, len(data), maxConfigMapSize)
+	}
+
+	cm := &corev1.ConfigMap{}
+	err := s.cli.Get(ctx, client.ObjectKey{Namespace: s.namespace, Name: configMapName(kind, key)}, cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "get object %s/%s", kind, key)
+	}
+	exists := err == nil
+
+	cm.ObjectMeta = metav1.ObjectMeta{
+		Name:            configMapName(kind, key),
+		Namespace:       s.namespace,
+		Labels:          map[string]string{constants.StorageKindLabel: kind},
+		Annotations:     map[string]string{constants.StorageKeyAnnotation: key},
+		ResourceVersion: cm.ResourceVersion,
+	}
+	cm.Data = nil
+	cm.BinaryData = map[string][]byte{configMapDataKey: data}
+	if exists {
+		return s.cli.Update(ctx, cm)
+	}
+
+	err = s.cli.Create(ctx, cm)
+	if apierrors.IsNotFound(err) {
+		// the namespace is created on the first write
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: s.namespace}}
+		if err := s.cli.Create(ctx, ns); err != nil && !apierrors.IsAlreadyExists(err) {
+			return errors.Wrapf(err, "create storage namespace: %s", s.namespace)
+		}
+		err = s.cli.Create(ctx, cm)
+	}
+	return err
+}
+
+func (s *ConfigMapStore) Get(ctx context.Context, kind, key string) ([]byte, error) {
+	if err := validate(kind, key); err != nil {
+		return nil, err
+	}
+
+	cm := &corev1.ConfigMap{}
+	err := s.cli.Get(ctx, client.ObjectKey{Namespace: s.namespace, Name: configMapName(kind, key)}, cm)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Wrapf(err, "get object %s/%s", kind, key)
+	}
+	return cm.BinaryData[configMapDataKey], nil
+}
+
+func (s *ConfigMapStore) List(ctx context.Context, kind, prefix string) ([]string, error) {
+	if err := validate(kind, "-"); err != nil {
+		return nil, err
+	}
+
+	cms := &corev1.ConfigMapList{}
+	err := s.cli.List(ctx, cms, client.InNamespace(s.namespace), client.MatchingLabels{constants.StorageKindLabel: kind})
+	if err != nil {
+		return nil, errors.Wrapf(err, "list objects %s", kind)
+	}
+
+	keys := []string{}
+	for i := range cms.Items {
+		key := cms.Items[i].Annotations[constants.StorageKeyAnnotation]
+		if key != "" && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *ConfigMapStore) Delete(ctx context.Context, kind, key string) error {
+	if err := validate(kind, key); err != nil {
+		return err
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: s.namespace, Name: configMapName(kind, key)},
+	}
+	err := s.cli.Delete(ctx, cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "delete object %s/%s", kind, key)
+	}
+	return nil
+}
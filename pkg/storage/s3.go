@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gostship/kunkka/pkg/util/sigv4"
+	"github.com/pkg/errors"
+)
+
+const (
+	s3Service     = "s3"
+	s3HTTPTimeout = 30 * time.Second
+)
+
+// S3Store keeps the objects as <prefix>/<kind>/<key> in a bucket of an S3 compatible
+// object storage, e.g. AWS S3, minio or ceph rgw, the requests are signed by AWS signature v4
+// with the path style urls.
+type S3Store struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	prefix    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+	now       func() time.Time
+}
+
+// NewS3Store returns the store of the bucket, the credentials default to the AWS environment variables.
+func NewS3Store(o *Options) (*S3Store, error) {
+	u, err := url.Parse(o.S3Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.Errorf("s3 endpoint: %q must be an http or https url", o.S3Endpoint)
+	}
+	if o.S3Bucket == "" {
+		return nil, errors.New("s3 bucket must be specified")
+	}
+
+	s := &S3Store{
+		endpoint:  u,
+		region:    o.S3Region,
+		bucket:    o.S3Bucket,
+		prefix:    strings.Trim(o.S3Prefix, "/"),
+		accessKey: o.S3AccessKey,
+		secretKey: o.S3SecretKey,
+		client:    &http.Client{Timeout: s3HTTPTimeout},
+		now:       time.Now,
+	}
+	if s.accessKey == "" {
+		s.accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if s.secretKey == "" {
+		s.secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if s.accessKey == "" || s.secretKey == "" {
+		return nil, errors.New("s3 credentials must be specified by AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return s, nil
+}
+
+func (s *S3Store) objectName(kind, key string) string {
+	if s.prefix == "" {
+		return kind + "/" + key
+	}
+	return s.prefix + "/" + kind + "/" + key
+}
+
+func (s *S3Store) Put(ctx context.Context, kind, key string, data []byte) error {
+	if err := validate(kind, key); err != nil {
+		return err
+	}
+
+	resp, body, err := s.do(ctx, http.MethodPut, s.objectName(kind, key), nil, data)
+	if err != nil {
+		return errors.Wrapf(err, "put object %s/%s", kind, key)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("put object %s/%s status: %d body: %s", kind, key, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, kind, key string) ([]byte, error) {
+	if err := validate(kind, key); err != nil {
+		return nil, err
+	}
+
+	resp, body, err := s.do(ctx, http.MethodGet, s.objectName(kind, key), nil, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get object %s/%s", kind, key)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, errors.Errorf("get object %s/%s status: %d body: %s", kind, key, resp.StatusCode, string(body))
+	}
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3Store) List(ctx context.Context, kind, prefix string) ([]string, error) {
+	if err := validate(kind, "-"); err != nil {
+		return nil, err
+	}
+
+	base := s.objectName(kind, "")
+	keys := []string{}
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", base+prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, body, err := s.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "list objects %s", kind)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, errors.Errorf("list objects %s status: %d body: %s", kind, resp.StatusCode, string(body))
+		}
+
+		result := &listBucketResult{}
+		if err := xml.Unmarshal(body, result); err != nil {
+			return nil, errors.Wrapf(err, "decode list objects %s", kind)
+		}
+		for _, c := range result.Contents {
+			keys = append(keys, strings.TrimPrefix(c.Key, base))
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, kind, key string) error {
+	if err := validate(kind, key); err != nil {
+		return err
+	}
+
+	resp, body, err := s.do(ctx, http.MethodDelete, s.objectName(kind, key), nil, nil)
+	if err != nil {
+		return errors.Wrapf(err, "delete object %s/%s", kind, key)
+	}
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return errors.Errorf("delete object %s/%s status: %d body: %s", kind, key, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// do sends the signed request of the object, empty object is the bucket itself.
+func (s *S3Store) do(ctx context.Context, method, object string, query url.Values, data []byte) (*http.Response, []byte, error) {
+	path := "/" + s.bucket
+	if object != "" {
+		path += "/" + object
+	}
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawPath = strings.TrimSuffix(u.EscapedPath(), "/") + sigv4.URIEncode(path, false)
+	u.RawQuery = sigv4.CanonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	req = req.WithContext(ctx)
+	s.sign(req, data)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, body, nil
+}
+
+// sign adds the AWS signature v4 of the request.
+func (s *S3Store) sign(req *http.Request, payload []byte) {
+	signer := &sigv4.Signer{
+		Credentials:   sigv4.Credentials{AccessKeyID: s.accessKey, SecretAccessKey: s.secretKey},
+		Region:        s.region,
+		Service:       s3Service,
+		ContentSHA256: true,
+	}
+	signer.Sign(req, payload, s.now())
+}
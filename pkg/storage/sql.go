@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var tableRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// SQLStore keeps the objects in a table of a database/sql database. The statements are
+// understood by both sqlite3 (3.24+) and postgres (9.5+), the drivers of both are linked
+// into admin-api by the blank imports of github.com/mattn/go-sqlite3 and github.com/lib/pq.
+type SQLStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSQLStore opens the database and creates the table if not exists.
+func NewSQLStore(driver, dsn, table string) (*SQLStore, error) {
+	if !driverRegistered(driver) {
+		return nil, errors.Errorf("sql driver: %q is not registered, available: %v", driver, sql.Drivers())
+	}
+	if !tableRegexp.MatchString(table) {
+		return nil, errors.Errorf("invalid sql table: %q", table)
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open %s database", driver)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err = db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	kind VARCHAR(32) NOT NULL,
+	key VARCHAR(1024) NOT NULL,
+	data BYTEA NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (kind, key)
+)`, table))
+	if err != nil {
+		db.Close()
+		return nil, errors.Wrapf(err, "create table: %s", table)
+	}
+	return &SQLStore{db: db, table: table}, nil
+}
+
+func driverRegistered(driver string) bool {
+	for _, d := range sql.Drivers() {
+		if d == driver {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SQLStore) Put(ctx context.Context, kind, key string, data []byte) error {
+	if err := validate(kind, key); err != nil {
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s (kind, key, data, updated_at) VALUES ($1, $2, $3, $4)
+ON CONFLICT (kind, key) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at`, s.table),
+		kind, key, data, time.Now().UTC())
+	if err != nil {
+		return errors.Wrapf(err, "put object %s/%s", kind, key)
+	}
+	return nil
+}
+
+func (s *SQLStore) Get(ctx context.Context, kind, key string) ([]byte, error) {
+	if err := validate(kind, key); err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	err := s.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT data FROM %s WHERE kind = $1 AND key = $2`, s.table), kind, key).Scan(&data)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Wrapf(err, "get object %s/%s", kind, key)
+	}
+	return data, nil
+}
+
+func (s *SQLStore) List(ctx context.Context, kind, prefix string) ([]string, error) {
+	if err := validate(kind, "-"); err != nil {
+		return nil, err
+	}
+
+	// the prefix is matched and sorted in go, LIKE treats % and _ as wildcards and ORDER BY follows the collation
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`SELECT key FROM %s WHERE kind = $1`, s.table), kind)
+	if err != nil {
+		return nil, errors.Wrapf(err, "list objects %s", kind)
+	}
+	defer rows.Close()
+
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, errors.Wrapf(err, "list objects %s", kind)
+		}
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrapf(err, "list objects %s", kind)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *SQLStore) Delete(ctx context.Context, kind, key string) error {
+	if err := validate(kind, key); err != nil {
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE kind = $1 AND key = $2`, s.table), kind, key)
+	if err != nil {
+		return errors.Wrapf(err, "delete object %s/%s", kind, key)
+	}
+	return nil
+}
+
+// Close closes the database.
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
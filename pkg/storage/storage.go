@@ -0,0 +1,131 @@
+/*
+Copyright 2020 dke.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package storage keeps the operational data of the apimanager which doesn't fit into the CRDs,
+// e.g. the audit events, provisioning logs, session recordings and metering data, in the backend
+// selected by the --storage-* flags, so the large installs don't store it in the etcd of the meta cluster.
+package storage
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// BackendConfigMap keeps each object in a ConfigMap of the meta cluster, for the small installs
+	BackendConfigMap = "configmap"
+	// BackendSQL keeps the objects in a table of a database/sql database, e.g. sqlite3 or postgres
+	BackendSQL = "sql"
+	// BackendS3 keeps the objects in a bucket of an S3 compatible object storage
+	BackendS3 = "s3"
+)
+
+// ErrNotFound the object doesn't exist.
+var ErrNotFound = errors.New("object not found")
+
+var kindRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// Store keeps the opaque objects grouped by kind, the kind is a lower case DNS label
+// and the key is any non-empty string, e.g. "<cluster>/<time>".
+type Store interface {
+	// Put creates or overwrites the object of the kind under the key.
+	Put(ctx context.Context, kind, key string, data []byte) error
+	// Get returns the object, ErrNotFound if it doesn't exist.
+	Get(ctx context.Context, kind, key string) ([]byte, error)
+	// List returns the sorted keys of the kind with the prefix.
+	List(ctx context.Context, kind, prefix string) ([]string, error)
+	// Delete removes the object, it's not an error if it doesn't exist.
+	Delete(ctx context.Context, kind, key string) error
+}
+
+// IsNotFound returns whether the object doesn't exist.
+func IsNotFound(err error) bool {
+	return errors.Cause(err) == ErrNotFound
+}
+
+// Options selects and configures the backend.
+type Options struct {
+	Backend string
+
+	// Namespace of the ConfigMaps of the configmap backend
+	Namespace string
+
+	// SQLDriver the registered database/sql driver, e.g. sqlite3 or postgres
+	SQLDriver string
+	SQLDSN    string
+	SQLTable  string
+
+	S3Endpoint string
+	S3Region   string
+	S3Bucket   string
+	S3Prefix   string
+	// S3AccessKey and S3SecretKey default to AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+	S3AccessKey string
+	S3SecretKey string
+}
+
+// DefaultOptions keeps the objects in the ConfigMaps of the storage namespace.
+func DefaultOptions() *Options {
+	return &Options{
+		Backend:   BackendConfigMap,
+		Namespace: constants.StorageNamespace,
+		SQLTable:  "kunkka_objects",
+		S3Region:  "us-east-1",
+	}
+}
+
+// AddFlags adds the flags of the storage to the flagset.
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Backend, "storage-backend", o.Backend, "the backend of the operational data: configmap, sql or s3.")
+	fs.StringVar(&o.Namespace, "storage-namespace", o.Namespace, "the namespace of the ConfigMaps of the configmap storage backend.")
+	fs.StringVar(&o.SQLDriver, "storage-sql-driver", o.SQLDriver, "the database/sql driver of the sql storage backend, sqlite3 or postgres.")
+	fs.StringVar(&o.SQLDSN, "storage-sql-dsn", o.SQLDSN, "the data source name of the sql storage backend.")
+	fs.StringVar(&o.SQLTable, "storage-sql-table", o.SQLTable, "the table of the sql storage backend, created if not exists.")
+	fs.StringVar(&o.S3Endpoint, "storage-s3-endpoint", o.S3Endpoint, "the url of the S3 compatible endpoint, e.g. https://s3.us-east-1.amazonaws.com.")
+	fs.StringVar(&o.S3Region, "storage-s3-region", o.S3Region, "the region of the S3 bucket.")
+	fs.StringVar(&o.S3Bucket, "storage-s3-bucket", o.S3Bucket, "the bucket of the S3 storage backend.")
+	fs.StringVar(&o.S3Prefix, "storage-s3-prefix", o.S3Prefix, "the prefix of the objects in the S3 bucket.")
+}
+
+// New returns the store of the backend, cli is the client of the meta cluster used by the configmap backend.
+func New(o *Options, cli client.Client) (Store, error) {
+	switch o.Backend {
+	case BackendConfigMap, "":
+		return NewConfigMapStore(cli, o.Namespace), nil
+	case BackendSQL:
+		return NewSQLStore(o.SQLDriver, o.SQLDSN, o.SQLTable)
+	case BackendS3:
+		return NewS3Store(o)
+	default:
+		return nil, errors.Errorf("unsupported storage backend: %s", o.Backend)
+	}
+}
+
+func validate(kind, key string) error {
+	if !kindRegexp.MatchString(kind) || len(kind) > 32 {
+		return errors.Errorf("invalid storage kind: %q", kind)
+	}
+	if key == "" || strings.ContainsRune(key, 0) {
+		return errors.Errorf("invalid storage key: %q", key)
+	}
+	return nil
+}
@@ -0,0 +1,429 @@
+/*
+Copyright 2020 dke.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package timeouts is the registry of the wait/poll parameters of the provisioning phases, the built-in
+// defaults are overridden globally by the --waits flag, per provider by its config and per cluster by spec.waits.
+package timeouts
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+// Name is the name of a wait.
+type Name string
+
+const (
+	// NodeReady waits the node of the machine to be ready
+	NodeReady Name = "nodeReady"
+	// ControlPlaneReady waits the apiserver of the bare metal cluster to be healthy
+	ControlPlaneReady Name = "controlPlaneReady"
+	// ClusterHealthy waits the apiserver of the hosted cluster to be healthy
+	ClusterHealthy Name = "clusterHealthy"
+	// ContainerRestart waits the removed static pod container to be recreated by kubelet
+	ContainerRestart Name = "containerRestart"
+	// NodeDrain waits the pods of the node of the deleted machine to be evicted, only the timeout applies
+	NodeDrain Name = "nodeDrain"
+	// SSHRetry retries the phases failed for the transient ssh errors, the interval doubles on each retry
+	SSHRetry Name = "sshRetry"
+	// PhaseRetry backs off the failed phases, the delay before the next attempt doubles from the interval
+	// up to the timeout
+	PhaseRetry Name = "phaseRetry"
+	// AddonResync applies the addons of the running cluster again on each interval, the deleted or edited
+	// objects are reverted, only the interval applies
+	AddonResync Name = "addonResync"
+	// SystemInstall bounds the installation of the system on a machine, only the timeout applies
+	SystemInstall Name = "systemInstall"
+	// JoinNode bounds the join of a node or of a master to the cluster, only the timeout applies
+	JoinNode Name = "joinNode"
+	// InstanceReady waits the instance provisioned for a machine, e.g. a VM of a vCenter, to boot and get an ip
+	InstanceReady Name = "instanceReady"
+	// InfraHook waits the Job of the infrastructure hook of the cluster, e.g. a terraform apply, to complete
+	InfraHook Name = "infraHook"
+)
+
+// DefaultPhaseMaxAttempts the default number of the attempts of a phase before it's failed
+const DefaultPhaseMaxAttempts = 10
+
+var (
+	defaults = map[Name]devopsv1.WaitParam{
+		NodeReady:         param(5*time.Second, 5*time.Minute),
+		ControlPlaneReady: param(5*time.Second, 5*time.Minute),
+		ClusterHealthy:    param(6*time.Second, 2*time.Minute),
+		ContainerRestart:  param(5*time.Second, 5*time.Minute),
+		NodeDrain:         param(2*time.Second, 5*time.Minute),
+		SSHRetry:          param(2*time.Second, 1*time.Minute),
+		PhaseRetry:        param(30*time.Second, 10*time.Minute),
+		AddonResync:       param(10*time.Minute, 10*time.Minute),
+		SystemInstall:     param(10*time.Second, 30*time.Minute),
+		JoinNode:          param(10*time.Second, 10*time.Minute),
+		InstanceReady:     param(10*time.Second, 10*time.Minute),
+		InfraHook:         param(10*time.Second, 30*time.Minute),
+	}
+
+	phaseMaxAttempts int32 = DefaultPhaseMaxAttempts
+
+	lock      sync.RWMutex
+	global    = Defaults()
+	providers = make(map[string]map[Name]devopsv1.WaitParam)
+)
+
+// TimeoutError is returned by the waits which timed out.
+type TimeoutError struct {
+	Name    Name
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s", e.Name, e.Timeout)
+}
+
+// IsTimeout returns whether the err is caused by a wait timed out, the aggregated errors of the machines
+// are timed out if any of them is.
+func IsTimeout(err error) bool {
+	cause := errors.Cause(err)
+	if cause == wait.ErrWaitTimeout {
+		return true
+	}
+	if agg, ok := cause.(utilerrors.Aggregate); ok {
+		for _, e := range agg.Errors() {
+			if IsTimeout(e) {
+				return true
+			}
+		}
+		return false
+	}
+	_, ok := cause.(*TimeoutError)
+	return ok
+}
+
+func param(interval, timeout time.Duration) devopsv1.WaitParam {
+	return devopsv1.WaitParam{
+		Interval: metav1.Duration{Duration: interval},
+		Timeout:  metav1.Duration{Duration: timeout},
+	}
+}
+
+// merge returns p overridden by the non zero fields of o.
+func merge(p, o devopsv1.WaitParam) devopsv1.WaitParam {
+	if o.Interval.Duration > 0 {
+		p.Interval = o.Interval
+	}
+	if o.Timeout.Duration > 0 {
+		p.Timeout = o.Timeout
+	}
+	return p
+}
+
+// Names returns the sorted names of all the waits.
+func Names() []string {
+	names := make([]string, 0, len(defaults))
+	for name := range defaults {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsValid returns whether the name is a known wait.
+func IsValid(name string) bool {
+	_, ok := defaults[Name(name)]
+	return ok
+}
+
+// Defaults returns the built-in values.
+func Defaults() map[Name]devopsv1.WaitParam {
+	result := make(map[Name]devopsv1.WaitParam, len(defaults))
+	for name, p := range defaults {
+		result[name] = p
+	}
+	return result
+}
+
+// Global returns the values of the process, i.e. the defaults overridden by the --waits flag.
+func Global() map[Name]devopsv1.WaitParam {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	result := make(map[Name]devopsv1.WaitParam, len(global))
+	for name, p := range global {
+		result[name] = p
+	}
+	return result
+}
+
+// SetGlobal overrides the global value of the wait, the zero fields keep the current value.
+func SetGlobal(name Name, p devopsv1.WaitParam) error {
+	if !IsValid(string(name)) {
+		return fmt.Errorf("unknown wait: %s, valid values: %v", name, Names())
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	global[name] = merge(global[name], p)
+	return nil
+}
+
+// SetProvider sets the values of the waits of the clusters of the provider, they override the global values
+// and are overridden by the spec of the cluster.
+func SetProvider(provider string, waits map[string]devopsv1.WaitParam) error {
+	overrides := make(map[Name]devopsv1.WaitParam, len(waits))
+	for name, p := range waits {
+		if !IsValid(name) {
+			return fmt.Errorf("unknown wait: %s of provider: %s, valid values: %v", name, provider, Names())
+		}
+		overrides[Name(name)] = p
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	providers[provider] = overrides
+	return nil
+}
+
+// Get returns the effective value of the wait for the cluster, c may be nil.
+func Get(c *devopsv1.Cluster, name Name) devopsv1.WaitParam {
+	lock.RLock()
+	p := global[name]
+	if c != nil {
+		if o, ok := providers[c.Spec.Type][name]; ok {
+			p = merge(p, o)
+		}
+	}
+	lock.RUnlock()
+
+	if c != nil {
+		if o, ok := c.Spec.Waits[string(name)]; ok {
+			p = merge(p, o)
+		}
+	}
+	return p
+}
+
+// Effective returns the effective values of all the waits for the cluster, c may be nil.
+func Effective(c *devopsv1.Cluster) map[Name]devopsv1.WaitParam {
+	result := make(map[Name]devopsv1.WaitParam, len(defaults))
+	for name := range defaults {
+		result[name] = Get(c, name)
+	}
+	return result
+}
+
+// Poll is wait.PollImmediate with the effective value of the wait, it returns a TimeoutError on timeout.
+func Poll(c *devopsv1.Cluster, name Name, condition wait.ConditionFunc) error {
+	p := Get(c, name)
+	err := wait.PollImmediate(p.Interval.Duration, p.Timeout.Duration, condition)
+	if err == wait.ErrWaitTimeout {
+		return &TimeoutError{Name: name, Timeout: p.Timeout.Duration}
+	}
+	return err
+}
+
+// Deadline calls fn with the context cancelled after the timeout of the wait, it returns a TimeoutError once
+// the timeout passed without waiting fn. The ssh commands can't be cancelled, the commands of fn left running
+// finish in the background and the phase is retried after the phaseRetry backoff.
+func Deadline(ctx context.Context, c *devopsv1.Cluster, name Name, fn func(ctx context.Context) error) error {
+	p := Get(c, name)
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout.Duration)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+		if err == nil {
+			return nil
+		}
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return &TimeoutError{Name: name, Timeout: p.Timeout.Duration}
+	}
+	return err
+}
+
+// Backoff returns the jittered constant backoff of the wait, which retries until the timeout.
+func Backoff(c *devopsv1.Cluster, name Name) wait.Backoff {
+	p := Get(c, name)
+	steps := 1
+	if p.Interval.Duration > 0 {
+		steps = int(p.Timeout.Duration / p.Interval.Duration)
+	}
+	if steps < 1 {
+		steps = 1
+	}
+
+	return wait.Backoff{
+		Steps:    steps,
+		Duration: p.Interval.Duration,
+		Factor:   1.0,
+		Jitter:   0.1,
+	}
+}
+
+// ExponentialBackoff returns the jittered backoff of the wait which doubles from the interval,
+// the delays sum up to the timeout at most.
+func ExponentialBackoff(c *devopsv1.Cluster, name Name) wait.Backoff {
+	p := Get(c, name)
+	steps := 1
+	var sum time.Duration
+	for d := p.Interval.Duration; d > 0 && sum+d <= p.Timeout.Duration; d *= 2 {
+		sum += d
+		steps++
+	}
+
+	return wait.Backoff{
+		Steps:    steps,
+		Duration: p.Interval.Duration,
+		Factor:   2.0,
+		Jitter:   0.1,
+	}
+}
+
+// Retry calls fn until it succeeds, fails with an error not retriable or the backoff of the wait is exhausted.
+func Retry(c *devopsv1.Cluster, name Name, retriable func(error) bool, fn func() error) error {
+	return retry.OnError(ExponentialBackoff(c, name), retriable, fn)
+}
+
+// PhaseMaxAttempts returns the number of the attempts of a phase before it's failed, 0 means no limit.
+func PhaseMaxAttempts() int32 {
+	return phaseMaxAttempts
+}
+
+// PhaseExhausted returns whether the phase failed for the attempts used up its budget.
+func PhaseExhausted(attempts int32) bool {
+	return phaseMaxAttempts > 0 && attempts >= phaseMaxAttempts
+}
+
+// PhaseBackoff returns the delay after the phase failed for the attempts, it doubles from the interval of
+// the phaseRetry wait on each attempt up to its timeout.
+func PhaseBackoff(c *devopsv1.Cluster, attempts int32) time.Duration {
+	p := Get(c, PhaseRetry)
+	d := p.Interval.Duration
+	for i := int32(1); i < attempts && d < p.Timeout.Duration; i++ {
+		d *= 2
+	}
+	if d > p.Timeout.Duration {
+		d = p.Timeout.Duration
+	}
+	return d
+}
+
+// PhaseRetryAfter returns how long the phase failed for the attempts at the time still backs off, 0 if
+// it's due or never failed.
+func PhaseRetryAfter(c *devopsv1.Cluster, attempts int32, failed time.Time) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+	d := time.Until(failed.Add(PhaseBackoff(c, attempts)))
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// Parse parses "name=interval/timeout" overrides separated by comma, e.g. "nodeReady=10s/15m,containerRestart=/10m".
+func Parse(value string) (map[Name]devopsv1.WaitParam, error) {
+	result := make(map[Name]devopsv1.WaitParam)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || !IsValid(kv[0]) {
+			return nil, fmt.Errorf("invalid wait: %q, expected name=interval/timeout with name in %v", item, Names())
+		}
+
+		durations := strings.SplitN(kv[1], "/", 2)
+		if len(durations) != 2 {
+			return nil, fmt.Errorf("invalid wait: %q, expected name=interval/timeout", item)
+		}
+
+		p := devopsv1.WaitParam{}
+		for i, d := range durations {
+			if d == "" {
+				continue
+			}
+			v, err := time.ParseDuration(d)
+			if err != nil {
+				return nil, fmt.Errorf("invalid wait: %q, %v", item, err)
+			}
+			if i == 0 {
+				p.Interval.Duration = v
+			} else {
+				p.Timeout.Duration = v
+			}
+		}
+		result[Name(kv[0])] = p
+	}
+
+	return result, nil
+}
+
+type flagValue struct{}
+
+func (flagValue) String() string {
+	g := Global()
+	items := make([]string, 0, len(g))
+	for _, name := range Names() {
+		p := g[Name(name)]
+		items = append(items, fmt.Sprintf("%s=%s/%s", name, p.Interval.Duration, p.Timeout.Duration))
+	}
+	return strings.Join(items, ",")
+}
+
+func (flagValue) Set(value string) error {
+	overrides, err := Parse(value)
+	if err != nil {
+		return err
+	}
+
+	for name, p := range overrides {
+		if err := SetGlobal(name, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (flagValue) Type() string {
+	return "waits"
+}
+
+// AddFlags adds the --waits flag which overrides the global values and the --phase-max-attempts flag.
+func AddFlags(fs *pflag.FlagSet) {
+	fs.Var(flagValue{}, "waits", "Overrides the wait/poll parameters, name=interval/timeout separated by comma, e.g. nodeReady=10s/15m")
+	fs.Int32Var(&phaseMaxAttempts, "phase-max-attempts", phaseMaxAttempts, "The number of the attempts of a phase before the cluster or the machine is failed, 0 means no limit")
+}
@@ -0,0 +1,239 @@
+package timeouts
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[Name]devopsv1.WaitParam
+		wantErr bool
+	}{
+		{
+			name:  "empty",
+			value: "",
+			want:  map[Name]devopsv1.WaitParam{},
+		},
+		{
+			name:  "interval and timeout",
+			value: "nodeReady=10s/15m, containerRestart=/10m",
+			want: map[Name]devopsv1.WaitParam{
+				NodeReady:        param(10*time.Second, 15*time.Minute),
+				ContainerRestart: param(0, 10*time.Minute),
+			},
+		},
+		{
+			name:    "unknown wait",
+			value:   "foo=1s/1m",
+			wantErr: true,
+		},
+		{
+			name:    "missing timeout",
+			value:   "nodeReady=10s",
+			wantErr: true,
+		},
+		{
+			name:    "invalid duration",
+			value:   "nodeReady=10x/1m",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Parse() = %v, want %v", got, tt.want)
+			}
+			for name, p := range tt.want {
+				if got[name] != p {
+					t.Errorf("Parse()[%s] = %v, want %v", name, got[name], p)
+				}
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	c := &devopsv1.Cluster{}
+	c.Spec.Waits = map[string]devopsv1.WaitParam{
+		string(NodeReady): param(0, 20*time.Minute),
+	}
+
+	got := Get(c, NodeReady)
+	want := param(defaults[NodeReady].Interval.Duration, 20*time.Minute)
+	if got != want {
+		t.Errorf("Get() = %v, want %v", got, want)
+	}
+
+	if got := Get(nil, NodeReady); got != defaults[NodeReady] {
+		t.Errorf("Get(nil) = %v, want %v", got, defaults[NodeReady])
+	}
+
+	defer delete(providers, "Baremetal")
+	err := SetProvider("Baremetal", map[string]devopsv1.WaitParam{
+		string(NodeReady):     param(time.Second, 10*time.Minute),
+		string(SystemInstall): param(0, time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("SetProvider() error = %v", err)
+	}
+	c.Spec.Type = "Baremetal"
+	if got, want := Get(c, NodeReady), param(time.Second, 20*time.Minute); got != want {
+		t.Errorf("Get() with provider = %v, want %v", got, want)
+	}
+	if got, want := Get(c, SystemInstall), param(defaults[SystemInstall].Interval.Duration, time.Hour); got != want {
+		t.Errorf("Get() with provider = %v, want %v", got, want)
+	}
+	if err := SetProvider("Hosted", map[string]devopsv1.WaitParam{"foo": {}}); err == nil {
+		t.Error("SetProvider() with unknown wait succeeded")
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	c := &devopsv1.Cluster{}
+	c.Spec.Waits = map[string]devopsv1.WaitParam{
+		string(JoinNode): param(0, 20*time.Millisecond),
+	}
+	failed := errors.New("failed")
+
+	tests := []struct {
+		name        string
+		fn          func(ctx context.Context) error
+		wantErr     error
+		wantTimeout bool
+	}{
+		{
+			name: "done",
+			fn:   func(ctx context.Context) error { return nil },
+		},
+		{
+			name:    "failed",
+			fn:      func(ctx context.Context) error { return failed },
+			wantErr: failed,
+		},
+		{
+			name: "timed out",
+			fn: func(ctx context.Context) error {
+				time.Sleep(time.Second)
+				return nil
+			},
+			wantTimeout: true,
+		},
+		{
+			name: "cancelled by the deadline",
+			fn: func(ctx context.Context) error {
+				<-ctx.Done()
+				time.Sleep(10 * time.Millisecond)
+				return ctx.Err()
+			},
+			wantTimeout: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Deadline(context.Background(), c, JoinNode, tt.fn)
+			if tt.wantTimeout {
+				if !IsTimeout(err) || err.Error() != "joinNode timed out after 20ms" {
+					t.Errorf("Deadline() = %v, want timeout", err)
+				}
+				return
+			}
+			if err != tt.wantErr || IsTimeout(err) {
+				t.Errorf("Deadline() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	c := &devopsv1.Cluster{}
+	c.Spec.Waits = map[string]devopsv1.WaitParam{
+		string(SSHRetry): param(time.Millisecond, 7*time.Millisecond),
+	}
+	// 1ms + 2ms + 4ms
+	if b := ExponentialBackoff(c, SSHRetry); b.Steps != 4 || b.Duration != time.Millisecond {
+		t.Errorf("ExponentialBackoff() = %+v", b)
+	}
+
+	transient := errors.New("transient")
+	calls := 0
+	err := Retry(c, SSHRetry, func(err error) bool { return err == transient }, func() error {
+		calls++
+		if calls < 3 {
+			return transient
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Retry() = %v after %d calls, want success after 3", err, calls)
+	}
+
+	calls = 0
+	err = Retry(c, SSHRetry, func(err error) bool { return err == transient }, func() error {
+		calls++
+		return transient
+	})
+	if err != transient || calls != 4 {
+		t.Errorf("Retry() = %v after %d calls, want transient after 4", err, calls)
+	}
+
+	calls = 0
+	failed := errors.New("failed")
+	err = Retry(c, SSHRetry, func(err error) bool { return err == transient }, func() error {
+		calls++
+		return failed
+	})
+	if err != failed || calls != 1 {
+		t.Errorf("Retry() = %v after %d calls, want failed after 1", err, calls)
+	}
+}
+
+func TestPhaseBackoff(t *testing.T) {
+	c := &devopsv1.Cluster{}
+	c.Spec.Waits = map[string]devopsv1.WaitParam{
+		string(PhaseRetry): param(10*time.Second, 1*time.Minute),
+	}
+	tests := []struct {
+		attempts int32
+		want     time.Duration
+	}{
+		{attempts: 1, want: 10 * time.Second},
+		{attempts: 2, want: 20 * time.Second},
+		{attempts: 3, want: 40 * time.Second},
+		{attempts: 4, want: 1 * time.Minute},
+		{attempts: 100, want: 1 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := PhaseBackoff(c, tt.attempts); got != tt.want {
+			t.Errorf("PhaseBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+
+	if got := PhaseRetryAfter(c, 0, time.Now()); got != 0 {
+		t.Errorf("PhaseRetryAfter() without attempts = %v, want 0", got)
+	}
+	if got := PhaseRetryAfter(c, 2, time.Now().Add(-30*time.Second)); got != 0 {
+		t.Errorf("PhaseRetryAfter() due = %v, want 0", got)
+	}
+	if got := PhaseRetryAfter(c, 2, time.Now()); got <= 10*time.Second || got > 20*time.Second {
+		t.Errorf("PhaseRetryAfter() = %v, want about 20s", got)
+	}
+
+	if PhaseExhausted(DefaultPhaseMaxAttempts-1) || !PhaseExhausted(DefaultPhaseMaxAttempts) {
+		t.Errorf("PhaseExhausted() with the budget of %d is wrong", DefaultPhaseMaxAttempts)
+	}
+}
@@ -0,0 +1,190 @@
+// Package tracing exports the opentelemetry spans of the provisioning of the clusters to an OTLP collector: the api
+// requests, the creation of the Cluster CRs, the provider phases, the ssh commands and the addon applies. The trace
+// context of the api request is kept in the k8s.io/traceparent annotation of the CRs, so the spans of the controller
+// join the trace of the request creating the cluster.
+package tracing
+
+import (
+	"context"
+
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+)
+
+// instrumentation the name of the tracer of the spans
+const instrumentation = "github.com/gostship/kunkka"
+
+// the attributes of the spans
+const (
+	ClusterKey  = attribute.Key("kunkka.cluster")
+	MachineKey  = attribute.Key("kunkka.machine")
+	PhaseKey    = attribute.Key("kunkka.phase")
+	ProviderKey = attribute.Key("kunkka.provider")
+	AddonKey    = attribute.Key("kunkka.addon")
+	HostKey     = attribute.Key("net.peer.name")
+	CommandKey  = attribute.Key("kunkka.ssh.command")
+	ExitCodeKey = attribute.Key("kunkka.ssh.exit_code")
+)
+
+// traceContext propagates the w3c traceparent, it's set even if the tracing is disabled so the annotations of the
+// CRs are kept.
+var traceContext = propagation.TraceContext{}
+
+// carrier carries the traceparent of the annotations.
+type carrier map[string]string
+
+func (c carrier) Get(key string) string {
+	return c[key]
+}
+
+func (c carrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c carrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Options the OTLP collector the spans are exported to, an empty Endpoint disables the tracing.
+type Options struct {
+	Endpoint    string
+	Insecure    bool
+	SampleRatio float64
+	ServiceName string
+}
+
+// DefaultOptions returns the options of the service, disabled.
+func DefaultOptions(service string) *Options {
+	return &Options{
+		Insecure:    true,
+		SampleRatio: 1,
+		ServiceName: service,
+	}
+}
+
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Endpoint, "tracing-endpoint", o.Endpoint, "The host:port of the OTLP grpc collector the spans are exported to, empty disables the tracing.")
+	fs.BoolVar(&o.Insecure, "tracing-insecure", o.Insecure, "Exports the spans to the collector without TLS.")
+	fs.Float64Var(&o.SampleRatio, "tracing-sample-ratio", o.SampleRatio, "The ratio of the traces sampled, the spans with a parent follow the sampling of the parent.")
+	fs.StringVar(&o.ServiceName, "tracing-service-name", o.ServiceName, "The service.name of the exported spans.")
+}
+
+// Enabled returns whether the spans are exported.
+func (o *Options) Enabled() bool {
+	return o.Endpoint != ""
+}
+
+func (o *Options) Validate() error {
+	if o.SampleRatio < 0 || o.SampleRatio > 1 {
+		return errors.Errorf("tracing sample ratio %v out of [0, 1]", o.SampleRatio)
+	}
+	return nil
+}
+
+// Setup installs the global tracer provider exporting the spans to the collector, the returned func flushes
+// and stops the exporter. Only the propagator is installed if the tracing is disabled.
+func Setup(ctx context.Context, opt *Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(traceContext)
+	if !opt.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporterOpts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(opt.Endpoint)}
+	if opt.Insecure {
+		exporterOpts = append(exporterOpts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "create otlp exporter of %s", opt.Endpoint)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opt.SampleRatio))),
+		sdktrace.WithResource(sdkresource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(opt.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		klog.Warningf("tracing err: %v", err)
+	}))
+	klog.Infof("export the spans of %s to %s, sample ratio: %v", opt.ServiceName, opt.Endpoint, opt.SampleRatio)
+	return provider.Shutdown, nil
+}
+
+// Start starts the span, the child of the span of ctx if any.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartChild starts the span only if ctx has a span, e.g. the ssh commands run out of the provider phases are
+// not traced, the returned span is a no-op otherwise.
+func StartChild(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return Start(ctx, name, attrs...)
+}
+
+// StartAddon starts the span of the apply of the addon in the span of the phase of ctx.
+func StartAddon(ctx context.Context, addon string) (context.Context, trace.Span) {
+	return StartChild(ctx, "addon "+addon, AddonKey.String(addon))
+}
+
+// End records the err on the span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceParent returns the w3c traceparent of the span of ctx, empty if it has none.
+func TraceParent(ctx context.Context) string {
+	c := carrier{}
+	traceContext.Inject(ctx, c)
+	return c.Get("traceparent")
+}
+
+// WithTraceParent returns the ctx with the remote span of the traceparent as the parent of its spans.
+func WithTraceParent(ctx context.Context, traceParent string) context.Context {
+	if traceParent == "" {
+		return ctx
+	}
+	return traceContext.Extract(ctx, carrier{"traceparent": traceParent})
+}
+
+// Inject keeps the trace context of ctx in the annotation of the object.
+func Inject(ctx context.Context, obj metav1.Object) {
+	traceParent := TraceParent(ctx)
+	if traceParent == "" {
+		return
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[constants.TraceParent] = traceParent
+	obj.SetAnnotations(annotations)
+}
+
+// Extract returns the ctx with the trace context of the annotation of the object as the parent of its spans.
+func Extract(ctx context.Context, obj metav1.Object) context.Context {
+	return WithTraceParent(ctx, obj.GetAnnotations()[constants.TraceParent])
+}
@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gostship/kunkka/pkg/constants"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		ratio   float64
+		wantErr bool
+	}{
+		{name: "default", ratio: 1},
+		{name: "never", ratio: 0},
+		{name: "negative", ratio: -0.1, wantErr: true},
+		{name: "too large", ratio: 1.5, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := DefaultOptions("kunkka")
+			o.SampleRatio = tt.ratio
+			if err := o.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	if _, err := Setup(context.Background(), DefaultOptions("kunkka")); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, request := Start(context.Background(), "POST /apis/cluster")
+	cluster := &metav1.ObjectMeta{Name: "c1"}
+	Inject(ctx, cluster)
+	request.End()
+	if cluster.Annotations[constants.TraceParent] == "" {
+		t.Fatalf("Inject() set no %s annotation", constants.TraceParent)
+	}
+
+	ctx, phase := Start(Extract(context.Background(), cluster), "EnsureSystem", PhaseKey.String("EnsureSystem"))
+	_, command := StartChild(WithTraceParent(context.Background(), TraceParent(ctx)), "ssh exec")
+	End(command, errors.New("connection reset"))
+	End(phase, nil)
+
+	_, orphan := StartChild(context.Background(), "ssh exec")
+	End(orphan, nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("ended %d spans, want 3", len(spans))
+	}
+	traceID := spans[0].SpanContext().TraceID()
+	for _, s := range spans {
+		if s.SpanContext().TraceID() != traceID {
+			t.Errorf("span %s of trace %s, want %s", s.Name(), s.SpanContext().TraceID(), traceID)
+		}
+	}
+	if got, want := spans[1].Parent().SpanID(), spans[2].SpanContext().SpanID(); got != want {
+		t.Errorf("parent of the ssh span = %s, want the phase span %s", got, want)
+	}
+	if spans[1].Status().Code != codes.Error {
+		t.Errorf("status of the failed ssh span = %v, want Error", spans[1].Status().Code)
+	}
+}
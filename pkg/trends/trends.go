@@ -0,0 +1,97 @@
+/*
+Copyright 2020 dke.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package trends keeps the periodic samples of the node count and phase of each cluster
+// in a ring of bounded size stored in a ConfigMap next to the Cluster.
+package trends
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// DataKey the key of the samples in the ConfigMap
+	DataKey = "samples.json"
+
+	// DefaultInterval the default period between two samples
+	DefaultInterval = 5 * time.Minute
+	// DefaultRetention the default number of samples kept, i.e. 7 days of DefaultInterval
+	DefaultRetention = 7 * 24 * 12
+)
+
+// Sample is the state of the cluster at a time, Nodes is -1 when the cluster is unreachable.
+type Sample struct {
+	Time       time.Time `json:"time"`
+	Phase      string    `json:"phase"`
+	Nodes      int       `json:"nodes"`
+	ReadyNodes int       `json:"readyNodes"`
+}
+
+// ConfigMapName returns the name of the ConfigMap holding the samples of the cluster.
+func ConfigMapName(cluster string) string {
+	return fmt.Sprintf("trends-%s", cluster)
+}
+
+// Append appends the sample and drops the oldest ones beyond the retention.
+func Append(samples []Sample, s Sample, retention int) []Sample {
+	samples = append(samples, s)
+	if retention > 0 && len(samples) > retention {
+		samples = append([]Sample{}, samples[len(samples)-retention:]...)
+	}
+	return samples
+}
+
+// Since returns the samples taken at or after the time, the samples are in time order.
+func Since(samples []Sample, t time.Time) []Sample {
+	for i := range samples {
+		if !samples[i].Time.Before(t) {
+			return samples[i:]
+		}
+	}
+	return []Sample{}
+}
+
+// Decode returns the samples of the ConfigMap, nil ConfigMap returns no samples.
+func Decode(cm *corev1.ConfigMap) ([]Sample, error) {
+	if cm == nil || cm.Data[DataKey] == "" {
+		return nil, nil
+	}
+
+	var samples []Sample
+	err := json.Unmarshal([]byte(cm.Data[DataKey]), &samples)
+	if err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+// Encode stores the samples in the ConfigMap.
+func Encode(cm *corev1.ConfigMap, samples []Sample) error {
+	data, err := json.Marshal(samples)
+	if err != nil {
+		return err
+	}
+
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[DataKey] = string(data)
+	return nil
+}
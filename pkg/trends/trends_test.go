@@ -0,0 +1,54 @@
+package trends
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAppend(t *testing.T) {
+	start := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		count     int
+		retention int
+		want      int
+	}{
+		{
+			name:      "below retention",
+			count:     3,
+			retention: 5,
+			want:      3,
+		},
+		{
+			name:      "ring is full",
+			count:     8,
+			retention: 5,
+			want:      5,
+		},
+		{
+			name:      "unlimited",
+			count:     8,
+			retention: 0,
+			want:      8,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var samples []Sample
+			for i := 0; i < tt.count; i++ {
+				samples = Append(samples, Sample{Time: start.Add(time.Duration(i) * time.Minute), Nodes: i}, tt.retention)
+			}
+			if len(samples) != tt.want {
+				t.Fatalf("Append() got %d samples, want %d", len(samples), tt.want)
+			}
+			if samples[len(samples)-1].Nodes != tt.count-1 {
+				t.Errorf("Append() dropped the newest sample: %+v", samples[len(samples)-1])
+			}
+
+			since := Since(samples, start.Add(time.Duration(tt.count-2)*time.Minute))
+			if len(since) != 2 {
+				t.Errorf("Since() got %d samples, want 2", len(since))
+			}
+		})
+	}
+}
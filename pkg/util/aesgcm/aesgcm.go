@@ -0,0 +1,52 @@
+// Package aesgcm encrypts with AES-GCM, the key is of 16, 24 or 32 bytes for AES-128, AES-192 or AES-256.
+package aesgcm
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+
+	"github.com/pkg/errors"
+)
+
+// New returns the AES-GCM AEAD of the key.
+func New(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Seal encrypts and authenticates the plaintext and the additional data, the random nonce is prepended to the
+// ciphertext.
+func Seal(key, plaintext, ad []byte) ([]byte, error) {
+	gcm, err := New(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "generate nonce")
+	}
+	return gcm.Seal(nonce, nonce, plaintext, ad), nil
+}
+
+// Open decrypts the ciphertext of Seal and authenticates it with the additional data.
+func Open(key, ciphertext, ad []byte) ([]byte, error) {
+	gcm, err := New(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	plaintext, err := gcm.Open(nil, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():], ad)
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypt")
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,31 @@
+package aesgcm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	ciphertext, err := Seal(key, []byte("secret"), []byte("ns/name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(ciphertext, []byte("secret")) {
+		t.Errorf("Seal() = %q, keeps the plaintext", ciphertext)
+	}
+
+	got, err := Open(key, ciphertext, []byte("ns/name"))
+	if err != nil || string(got) != "secret" {
+		t.Errorf("Open() = %q, %v, want secret", got, err)
+	}
+	if _, err := Open(key, ciphertext, []byte("ns/other")); err == nil {
+		t.Errorf("Open() with other additional data want error")
+	}
+	if _, err := Open([]byte("fedcba9876543210fedcba9876543210"), ciphertext, []byte("ns/name")); err == nil {
+		t.Errorf("Open() with other key want error")
+	}
+	if _, err := Open(key, ciphertext[:4], nil); err == nil {
+		t.Errorf("Open() of short ciphertext want error")
+	}
+}
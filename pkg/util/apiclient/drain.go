@@ -0,0 +1,111 @@
+package apiclient
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DrainRetryInterval specifies how long should wait before retrying the evictions and checking the pods are gone
+	DrainRetryInterval = 2 * time.Second
+	// mirrorPodAnnotation the annotation of the static pods mirrored by the kubelet
+	mirrorPodAnnotation = "kubernetes.io/config.mirror"
+)
+
+// DrainTimeoutError the pods of the node were not evicted before the timeout, e.g. blocked by their disruption budgets.
+type DrainTimeoutError struct {
+	Node string
+	Pods []string
+}
+
+func (e *DrainTimeoutError) Error() string {
+	return fmt.Sprintf("drain node: %s timed out, pods not evicted: %s", e.Node, strings.Join(e.Pods, ", "))
+}
+
+// CordonNode marks the node unschedulable.
+func CordonNode(ctx context.Context, client clientset.Interface, nodeName string) error {
+	_, err := client.CoreV1().Nodes().Patch(ctx, nodeName, types.StrategicMergePatchType,
+		[]byte(`{"spec":{"unschedulable":true}}`), metav1.PatchOptions{})
+	return errors.Wrapf(err, "cordon node: %s", nodeName)
+}
+
+// DrainNode evicts the pods of the node but the DaemonSet and the mirror pods, honoring their disruption budgets,
+// and waits for them to be gone. It returns the pods evicted, as namespace/name, and a *DrainTimeoutError with
+// the pods left if they are not gone before the timeout.
+func DrainNode(ctx context.Context, client clientset.Interface, nodeName string, timeout time.Duration) ([]string, error) {
+	pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", nodeName).String(),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "list pods of node: %s", nodeName)
+	}
+
+	pending := map[types.UID]*corev1.Pod{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.NodeName == nodeName && drainable(pod) {
+			pending[pod.UID] = pod
+		}
+	}
+
+	evicted := []string{}
+	err = wait.PollImmediate(DrainRetryInterval, timeout, func() (bool, error) {
+		for uid, pod := range pending {
+			cur, err := client.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) || (err == nil && cur.UID != uid) {
+				delete(pending, uid)
+				evicted = append(evicted, pod.Namespace+"/"+pod.Name)
+				continue
+			}
+			if err != nil {
+				return false, errors.Wrapf(err, "get pod: %s/%s", pod.Namespace, pod.Name)
+			}
+			if cur.DeletionTimestamp != nil {
+				continue
+			}
+
+			err = client.CoreV1().Pods(pod.Namespace).Evict(ctx, &policyv1beta1.Eviction{
+				ObjectMeta: metav1.ObjectMeta{Namespace: pod.Namespace, Name: pod.Name},
+			})
+			// too many requests: the eviction is refused by the disruption budget for now
+			if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsTooManyRequests(err) {
+				return false, errors.Wrapf(err, "evict pod: %s/%s", pod.Namespace, pod.Name)
+			}
+		}
+		return len(pending) == 0, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		left := make([]string, 0, len(pending))
+		for _, pod := range pending {
+			left = append(left, pod.Namespace+"/"+pod.Name)
+		}
+		sort.Strings(left)
+		return evicted, &DrainTimeoutError{Node: nodeName, Pods: left}
+	}
+	return evicted, err
+}
+
+// drainable returns whether the pod is evicted by the drain, the DaemonSet pods are recreated on the node
+// and the mirror pods can't be evicted.
+func drainable(pod *corev1.Pod) bool {
+	if _, ok := pod.Annotations[mirrorPodAnnotation]; ok {
+		return false
+	}
+	if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == "DaemonSet" {
+		return false
+	}
+	return true
+}
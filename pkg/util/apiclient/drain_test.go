@@ -0,0 +1,126 @@
+package apiclient
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newPod(ns, name, node string, mutate func(*corev1.Pod)) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name, UID: types.UID("uid-" + name)},
+		Spec:       corev1.PodSpec{NodeName: node},
+	}
+	if mutate != nil {
+		mutate(pod)
+	}
+	return pod
+}
+
+func TestDrainNode(t *testing.T) {
+	daemon := func(pod *corev1.Pod) {
+		controller := true
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "ds", Controller: &controller}}
+	}
+	mirror := func(pod *corev1.Pod) {
+		pod.Annotations = map[string]string{mirrorPodAnnotation: "hash"}
+	}
+
+	tests := []struct {
+		name        string
+		pods        []runtime.Object
+		budgets     map[string]bool
+		wantEvicted []string
+		wantLeft    []string
+	}{
+		{
+			name: "evicts the pods of the node",
+			pods: []runtime.Object{
+				newPod("default", "web", "10.0.0.1", nil),
+				newPod("app", "api", "10.0.0.1", nil),
+				newPod("default", "other", "10.0.0.2", nil),
+			},
+			wantEvicted: []string{"app/api", "default/web"},
+		},
+		{
+			name: "skips the daemonset and the mirror pods",
+			pods: []runtime.Object{
+				newPod("default", "web", "10.0.0.1", nil),
+				newPod("kube-system", "calico", "10.0.0.1", daemon),
+				newPod("kube-system", "etcd", "10.0.0.1", mirror),
+			},
+			wantEvicted: []string{"default/web"},
+		},
+		{
+			name: "times out on the pods blocked by their budgets",
+			pods: []runtime.Object{
+				newPod("default", "web", "10.0.0.1", nil),
+				newPod("default", "db", "10.0.0.1", nil),
+			},
+			budgets:     map[string]bool{"db": true},
+			wantEvicted: []string{"default/web"},
+			wantLeft:    []string{"default/db"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := fake.NewSimpleClientset(tt.pods...)
+			cli.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if action.GetSubresource() != "eviction" {
+					return false, nil, nil
+				}
+				name := action.(k8stesting.CreateAction).GetObject().(metav1.Object).GetName()
+				if tt.budgets[name] {
+					return true, nil, apierrors.NewTooManyRequests("disruption budget", 1)
+				}
+				gvr := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+				return true, nil, cli.Tracker().Delete(gvr, action.GetNamespace(), name)
+			})
+
+			evicted, err := DrainNode(context.Background(), cli, "10.0.0.1", 3*time.Second)
+			sort.Strings(evicted)
+			if !reflect.DeepEqual(evicted, tt.wantEvicted) {
+				t.Errorf("evicted: got %v, want %v", evicted, tt.wantEvicted)
+			}
+			if tt.wantLeft == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			timeout, ok := err.(*DrainTimeoutError)
+			if !ok {
+				t.Fatalf("got error %v, want a drain timeout", err)
+			}
+			if !reflect.DeepEqual(timeout.Pods, tt.wantLeft) {
+				t.Errorf("left: got %v, want %v", timeout.Pods, tt.wantLeft)
+			}
+		})
+	}
+}
+
+func TestCordonNode(t *testing.T) {
+	cli := fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "10.0.0.1"}})
+	if err := CordonNode(context.Background(), cli, "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	node, err := cli.CoreV1().Nodes().Get(context.Background(), "10.0.0.1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !node.Spec.Unschedulable {
+		t.Errorf("node not cordoned")
+	}
+}
@@ -0,0 +1,222 @@
+package authutil
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// userKey the key of the authenticated user in the gin context
+const userKey = "kunkka.user"
+
+// User the identity of the authenticated request.
+type User struct {
+	Name   string
+	Groups []string
+	// Issuer the issuer of the token, DefaultIssuerName for the tokens issued by IssueTo
+	Issuer string
+	// Extra the additional information of the token, e.g. the scopes of the api tokens
+	Extra map[string][]string
+}
+
+// Claimed returns whether the name of the user is only claimed by the client, the password login issues the tokens
+// of any user name to whoever knows the shared password.
+func (u *User) Claimed() bool {
+	return u.Issuer == DefaultIssuerName
+}
+
+// Authenticator verifies the bearer token of the request.
+type Authenticator interface {
+	Authenticate(token string) (*User, error)
+}
+
+// Options configures the authentication of the apimanager.
+type Options struct {
+	// Enabled rejects the requests without a valid bearer token except the public paths
+	Enabled bool
+	// JWTSecretFile the file of the HS256 secret of the issued tokens, shared by all the replicas
+	JWTSecretFile string
+	// PasswordFile the file of the shared password of the /oauth/authorize login, the login is disabled if not set
+	PasswordFile string
+
+	OIDCIssuerURL     string
+	OIDCClientID      string
+	OIDCCAFile        string
+	OIDCUsernameClaim string
+	OIDCGroupsClaim   string
+}
+
+// DefaultOptions ...
+func DefaultOptions() *Options {
+	return &Options{
+		Enabled:           true,
+		OIDCUsernameClaim: "sub",
+		OIDCGroupsClaim:   "groups",
+	}
+}
+
+// AddFlags adds the flags of the authentication to fs.
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&o.Enabled, "enable-auth", o.Enabled, "Enabled rejects the api requests without a valid bearer token.")
+	fs.StringVar(&o.JWTSecretFile, "jwt-secret-file", o.JWTSecretFile, "the file of the raw or base64 encoded secret (>= 32 bytes) the issued tokens are signed with, random if not set.")
+	fs.StringVar(&o.PasswordFile, "login-password-file", o.PasswordFile, "the file of the password of the /oauth/authorize login, the login is disabled if not set.")
+	fs.StringVar(&o.OIDCIssuerURL, "oidc-issuer-url", o.OIDCIssuerURL, "the url of the OIDC issuer, its id tokens are accepted as the bearer tokens if set.")
+	fs.StringVar(&o.OIDCClientID, "oidc-client-id", o.OIDCClientID, "the client id the OIDC id tokens must be issued for.")
+	fs.StringVar(&o.OIDCCAFile, "oidc-ca-file", o.OIDCCAFile, "the CA file of the OIDC issuer, the system CAs if not set.")
+	fs.StringVar(&o.OIDCUsernameClaim, "oidc-username-claim", o.OIDCUsernameClaim, "the claim of the OIDC id token used as the user name.")
+	fs.StringVar(&o.OIDCGroupsClaim, "oidc-groups-claim", o.OIDCGroupsClaim, "the claim of the OIDC id token used as the user groups.")
+}
+
+// New sets the secret of the issued tokens and returns the authenticator accepting them,
+// and the OIDC id tokens as well if the issuer is set.
+func New(o *Options) (Authenticator, error) {
+	if o.JWTSecretFile != "" {
+		data, err := ioutil.ReadFile(o.JWTSecretFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read jwt secret: %s", o.JWTSecretFile)
+		}
+		secret := []byte(strings.TrimSpace(string(data)))
+		if decoded, err := base64.StdEncoding.DecodeString(string(secret)); err == nil && len(decoded) >= 32 {
+			secret = decoded
+		}
+		err = SetSecret(secret)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if o.PasswordFile != "" {
+		data, err := ioutil.ReadFile(o.PasswordFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read login password: %s", o.PasswordFile)
+		}
+		err = SetPassword(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, errors.Wrapf(err, "login password: %s", o.PasswordFile)
+		}
+	}
+
+	authns := unionAuthenticator{tokenAuthenticator{}}
+	if o.OIDCIssuerURL != "" {
+		oidc, err := NewOIDCAuthenticator(o)
+		if err != nil {
+			return nil, err
+		}
+		authns = append(authns, oidc)
+	}
+	return authns, nil
+}
+
+// Union returns the authenticator trying the authns in order.
+func Union(authns ...Authenticator) Authenticator {
+	return unionAuthenticator(authns)
+}
+
+// tokenAuthenticator accepts the tokens issued by IssueTo.
+type tokenAuthenticator struct{}
+
+func (tokenAuthenticator) Authenticate(token string) (*User, error) {
+	clm, err := ParseToken(token)
+	if err != nil {
+		return nil, err
+	}
+	return &User{Name: clm.Username, Issuer: clm.Issuer}, nil
+}
+
+// unionAuthenticator tries the authenticators in order, the errors of all of them are returned if none succeeds.
+type unionAuthenticator []Authenticator
+
+func (u unionAuthenticator) Authenticate(token string) (*User, error) {
+	var errs []string
+	for _, a := range u {
+		user, err := a.Authenticate(token)
+		if err == nil {
+			return user, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil, errors.New(strings.Join(errs, "; "))
+}
+
+// BearerToken returns the token of the Authorization header, the "Bearer " prefix is optional.
+func BearerToken(header string) string {
+	return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(header), "Bearer "))
+}
+
+// WebSocketTokenProtocolPrefix the prefix of the websocket subprotocol carrying the base64url encoded bearer token
+// as kube-apiserver does, the browsers can't set the Authorization header of the websockets.
+const WebSocketTokenProtocolPrefix = "base64url.bearer.authorization.k8s.io."
+
+// RequestAuthorization returns the Authorization header of the request, or else the bearer token
+// of the websocket subprotocol.
+func RequestAuthorization(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		return header
+	}
+	for _, protocol := range websocketProtocols(r) {
+		if !strings.HasPrefix(protocol, WebSocketTokenProtocolPrefix) {
+			continue
+		}
+		token, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(strings.TrimPrefix(protocol, WebSocketTokenProtocolPrefix), "="))
+		if err == nil && len(token) > 0 {
+			return "Bearer " + string(token)
+		}
+	}
+	return ""
+}
+
+// WebSocketProtocols returns the websocket subprotocols requested besides the bearer token.
+func WebSocketProtocols(r *http.Request) []string {
+	var protocols []string
+	for _, protocol := range websocketProtocols(r) {
+		if !strings.HasPrefix(protocol, WebSocketTokenProtocolPrefix) {
+			protocols = append(protocols, protocol)
+		}
+	}
+	return protocols
+}
+
+func websocketProtocols(r *http.Request) []string {
+	var protocols []string
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(header, ",") {
+			if protocol = strings.TrimSpace(protocol); protocol != "" {
+				protocols = append(protocols, protocol)
+			}
+		}
+	}
+	return protocols
+}
+
+// SetUser injects the authenticated user into the context of the request.
+func SetUser(c *gin.Context, user *User) {
+	c.Set(userKey, user)
+}
+
+// RequestUser returns the user injected by the authentication middleware,
+// the token issued by IssueTo or the client certificate is verified if the middleware is disabled.
+func RequestUser(c *gin.Context) (*User, error) {
+	if v, ok := c.Get(userKey); ok {
+		if user, ok := v.(*User); ok {
+			return user, nil
+		}
+	}
+	authorization := RequestAuthorization(c.Request)
+	if authorization == "" {
+		if user, err := CertUser(c.Request.TLS); err == nil {
+			return user, nil
+		}
+	}
+
+	user, err := tokenAuthenticator{}.Authenticate(authorization)
+	if err != nil {
+		return nil, fmt.Errorf("unauthenticated: %v", err)
+	}
+	return user, nil
+}
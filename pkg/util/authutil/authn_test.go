@@ -0,0 +1,242 @@
+package authutil
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestIssuedToken(t *testing.T) {
+	if err := SetSecret([]byte("0123456789abcdef0123456789abcdef")); err != nil {
+		t.Fatal(err)
+	}
+	authn, err := New(DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := IssueTo("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := authn.Authenticate("Bearer " + token.AccessToken)
+	if err != nil || user.Name != "alice" {
+		t.Errorf("Authenticate() = %v, %v, want alice", user, err)
+	}
+
+	expired := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+		Username: "alice",
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    DefaultIssuerName,
+			ExpiresAt: time.Now().Add(-time.Minute).Unix(),
+		},
+	})
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+		Username:       "alice",
+		StandardClaims: jwt.StandardClaims{Issuer: DefaultIssuerName},
+	})
+	for name, tc := range map[string]struct {
+		token  *jwt.Token
+		secret string
+	}{
+		"expired": {expired, "0123456789abcdef0123456789abcdef"},
+		"forged":  {forged, DefaultIssuerName},
+	} {
+		s, err := tc.token.SignedString([]byte(tc.secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user, err := authn.Authenticate(s); err == nil {
+			t.Errorf("%s: Authenticate() = %v, want error", name, user)
+		}
+	}
+}
+
+func TestPasswordFile(t *testing.T) {
+	defer func() { password = "" }()
+
+	dir, err := ioutil.TempDir("", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if Authenticate("") || PasswordLoginEnabled() {
+		t.Fatal("the login must be disabled without a password")
+	}
+	for name, tc := range map[string]struct {
+		content string
+		wantErr bool
+	}{
+		"empty":   {"\n", true},
+		"default": {insecurePassword + "\n", true},
+		"valid":   {"s3cret-of-our-own\n", false},
+	} {
+		file := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(file, []byte(tc.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := New(&Options{PasswordFile: file})
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: New() error = %v, wantErr %v", name, err, tc.wantErr)
+		}
+	}
+	if !Authenticate("s3cret-of-our-own") || Authenticate(insecurePassword) {
+		t.Error("Authenticate() must accept only the password of the file")
+	}
+}
+
+func TestOIDCAuthenticator(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var issuer string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{"issuer": issuer, "jwks_uri": issuer + "/keys"})
+		case "/keys":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"keys": []map[string]string{{
+					"kty": "RSA",
+					"kid": "k1",
+					"use": "sig",
+					"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				}},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	issuer = srv.URL
+
+	dir, err := ioutil.TempDir("", "oidc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.crt")
+	err = ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := DefaultOptions()
+	o.OIDCIssuerURL = issuer
+	o.OIDCClientID = "kunkka"
+	o.OIDCCAFile = caFile
+	authn, err := New(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(clm jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, clm)
+		token.Header["kid"] = "k1"
+		s, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			claims: jwt.MapClaims{"iss": issuer, "aud": []string{"other", "kunkka"}, "sub": "1234", "exp": exp, "groups": []string{"ops"}},
+			want:   OIDCUserPrefix + "1234",
+		},
+		{
+			name:    "wrong audience",
+			claims:  jwt.MapClaims{"iss": issuer, "aud": "other", "sub": "1234", "exp": exp},
+			wantErr: true,
+		},
+		{
+			name:    "wrong issuer",
+			claims:  jwt.MapClaims{"iss": "https://evil.example.com", "aud": "kunkka", "sub": "1234", "exp": exp},
+			wantErr: true,
+		},
+		{
+			name:    "expired",
+			claims:  jwt.MapClaims{"iss": issuer, "aud": "kunkka", "sub": "1234", "exp": time.Now().Add(-time.Hour).Unix()},
+			wantErr: true,
+		},
+		{
+			name:    "no expiry",
+			claims:  jwt.MapClaims{"iss": issuer, "aud": "kunkka", "sub": "1234"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := authn.Authenticate("Bearer " + sign(tt.claims))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (user.Name != tt.want || len(user.Groups) != 1 || user.Groups[0] != "ops") {
+				t.Errorf("Authenticate() = %+v, want %s in group ops", user, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestAuthorization(t *testing.T) {
+	token := base64.RawURLEncoding.EncodeToString([]byte("abc.def"))
+	tests := []struct {
+		name          string
+		authorization string
+		protocols     []string
+		want          string
+		wantProtocols []string
+	}{
+		{name: "none"},
+		{name: "header", authorization: "Bearer xyz", protocols: []string{WebSocketTokenProtocolPrefix + token}, want: "Bearer xyz"},
+		{
+			name:          "websocket protocol",
+			protocols:     []string{"kunkka.terminal, " + WebSocketTokenProtocolPrefix + token},
+			want:          "Bearer abc.def",
+			wantProtocols: []string{"kunkka.terminal"},
+		},
+		{name: "invalid encoding", protocols: []string{WebSocketTokenProtocolPrefix + "!!"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			for _, p := range tt.protocols {
+				r.Header.Add("Sec-WebSocket-Protocol", p)
+			}
+			if got := RequestAuthorization(r); got != tt.want {
+				t.Errorf("RequestAuthorization() = %q, want %q", got, tt.want)
+			}
+			got := WebSocketProtocols(r)
+			if len(got) != len(tt.wantProtocols) || (len(got) > 0 && got[0] != tt.wantProtocols[0]) {
+				t.Errorf("WebSocketProtocols() = %v, want %v", got, tt.wantProtocols)
+			}
+		})
+	}
+}
@@ -1,18 +1,52 @@
 package authutil
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gostship/kunkka/pkg/apimanager/model/auth"
 	"k8s.io/klog"
 	"time"
 )
 
-const DefaultIssuerName = "kunkka"
+const (
+	DefaultIssuerName = "kunkka"
+	// TokenMaxAge the lifetime of the tokens issued by IssueTo
+	TokenMaxAge = 6 * time.Hour
+
+	// insecurePassword the well-known password the login used to ship with, refused by SetPassword
+	insecurePassword = "P@88w0rd"
+)
 
 var (
-	Password = "P@88w0rd"
+	// password the shared password of the /oauth/authorize login, the login is disabled if empty
+	password string
+
+	// jwtSecret the HS256 secret of the issued tokens, random unless set by SetSecret
+	jwtSecret = randomSecret()
 )
 
+func randomSecret() []byte {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// SetSecret sets the HS256 secret the tokens are issued and verified with, it must be shared by all the replicas,
+// the random secret by default invalidates the tokens on restart.
+func SetSecret(secret []byte) error {
+	if len(secret) < 32 {
+		return fmt.Errorf("jwt secret must be at least 32 bytes, got %d", len(secret))
+	}
+	jwtSecret = secret
+	return nil
+}
+
 type Claims struct {
 	Username string `json:"username"`
 	UID      string `json:"uid"`
@@ -28,14 +62,13 @@ func IssueTo(username string) (*auth.Token, error) {
 			IssuedAt:  time.Now().Unix(),
 			Issuer:    DefaultIssuerName,
 			NotBefore: time.Now().Unix(),
+			ExpiresAt: time.Now().Add(TokenMaxAge).Unix(),
 		},
 	}
-	JwtSecret := DefaultIssuerName
-	ExpiresAt := clm.IssuedAt + int64(6*time.Hour)
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, clm)
 
-	tokenString, err := token.SignedString([]byte(JwtSecret))
+	tokenString, err := token.SignedString(jwtSecret)
 	if err != nil {
 		klog.Error(err)
 		return nil, err
@@ -44,15 +77,58 @@ func IssueTo(username string) (*auth.Token, error) {
 	result := &auth.Token{
 		AccessToken: tokenString,
 		TokenType:   "Bearer",
-		ExpiresIn:   int(ExpiresAt),
+		ExpiresIn:   int(TokenMaxAge.Seconds()),
 	}
 
 	return result, nil
 }
 
-func Authenticate(password string) bool {
-	if password == Password {
-		return true
+// ParseToken verifies the token issued by IssueTo, the "Bearer " prefix is optional.
+func ParseToken(token string) (*Claims, error) {
+	token = strings.TrimSpace(strings.TrimPrefix(token, "Bearer "))
+	if token == "" {
+		return nil, fmt.Errorf("empty token")
+	}
+
+	clm := &Claims{}
+	_, err := jwt.ParseWithClaims(token, clm, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return jwtSecret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if clm.Issuer != DefaultIssuerName || clm.Username == "" {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+
+	return clm, nil
+}
+
+// SetPassword sets the shared password of the /oauth/authorize login, the well-known default is refused.
+func SetPassword(p string) error {
+	if p == "" {
+		return fmt.Errorf("empty login password")
+	}
+	if p == insecurePassword {
+		return fmt.Errorf("refuse the well-known default login password, set a password of your own")
+	}
+	password = p
+	return nil
+}
+
+// PasswordLoginEnabled returns whether the login password is set.
+func PasswordLoginEnabled() bool {
+	return password != ""
+}
+
+// Authenticate verifies the password of the login, always false if the login password is not set.
+func Authenticate(p string) bool {
+	if password == "" {
+		return false
 	}
-	return false
+	return subtle.ConstantTimeCompare([]byte(p), []byte(password)) == 1
 }
@@ -0,0 +1,295 @@
+package authutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+)
+
+// keysMinRefresh the min interval between two fetches of the keys of the issuer, a token with an unknown kid
+// refetches them so the rotated keys are picked up.
+const keysMinRefresh = time.Minute
+
+// OIDCUserPrefix the prefix of the users named by the sub claim
+const OIDCUserPrefix = "oidc:"
+
+// OIDCAuthenticator accepts the id tokens of the OIDC issuer signed with the keys of its jwks_uri,
+// the issuer is discovered on the first request so the apimanager starts without it.
+type OIDCAuthenticator struct {
+	issuer        string
+	clientID      string
+	usernameClaim string
+	groupsClaim   string
+	client        *http.Client
+
+	mu        sync.Mutex
+	jwksURI   string
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+// NewOIDCAuthenticator ...
+func NewOIDCAuthenticator(o *Options) (*OIDCAuthenticator, error) {
+	if !strings.HasPrefix(o.OIDCIssuerURL, "https://") {
+		return nil, errors.Errorf("oidc issuer url must be https, got: %s", o.OIDCIssuerURL)
+	}
+	if o.OIDCClientID == "" {
+		return nil, errors.New("oidc client id is required with the oidc issuer url")
+	}
+
+	tlsConfig := &tls.Config{}
+	if o.OIDCCAFile != "" {
+		data, err := ioutil.ReadFile(o.OIDCCAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read oidc ca: %s", o.OIDCCAFile)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.Errorf("oidc ca: %s has no certificates", o.OIDCCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &OIDCAuthenticator{
+		issuer:        strings.TrimSuffix(o.OIDCIssuerURL, "/"),
+		clientID:      o.OIDCClientID,
+		usernameClaim: o.OIDCUsernameClaim,
+		groupsClaim:   o.OIDCGroupsClaim,
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+		},
+	}, nil
+}
+
+// Authenticate verifies the signature, issuer, audience and expiry of the id token.
+func (a *OIDCAuthenticator) Authenticate(token string) (*User, error) {
+	token = BearerToken(token)
+	if token == "" {
+		return nil, errors.New("empty token")
+	}
+
+	parser := &jwt.Parser{ValidMethods: []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}}
+	clm := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(token, clm, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return a.key(kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if iss, _ := clm["iss"].(string); iss != a.issuer {
+		return nil, errors.Errorf("unexpected issuer: %q", iss)
+	}
+	if !hasAudience(clm["aud"], a.clientID) {
+		return nil, errors.Errorf("token is not issued for client: %s", a.clientID)
+	}
+	if _, ok := clm["exp"]; !ok {
+		return nil, errors.New("token has no expiry")
+	}
+
+	name, _ := clm[a.usernameClaim].(string)
+	if name == "" {
+		return nil, errors.Errorf("token has no claim: %s", a.usernameClaim)
+	}
+	switch a.usernameClaim {
+	case "sub":
+		// the subjects are opaque and may collide with the names of the issued tokens
+		name = OIDCUserPrefix + name
+	case "email":
+		if verified, ok := clm["email_verified"].(bool); ok && !verified {
+			return nil, errors.Errorf("email: %s is not verified", name)
+		}
+	}
+
+	user := &User{Name: name, Issuer: a.issuer}
+	switch groups := clm[a.groupsClaim].(type) {
+	case string:
+		user.Groups = []string{groups}
+	case []interface{}:
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				user.Groups = append(user.Groups, s)
+			}
+		}
+	}
+	return user, nil
+}
+
+func hasAudience(aud interface{}, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// key returns the key of the kid, the keys are refetched if the kid is unknown.
+func (a *OIDCAuthenticator) key(kid string) (interface{}, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if key, ok := a.lookup(kid); ok {
+		return key, nil
+	}
+	if time.Since(a.fetchedAt) < keysMinRefresh {
+		return nil, errors.Errorf("unknown key id: %q", kid)
+	}
+
+	a.fetchedAt = time.Now()
+	err := a.fetchKeys()
+	if err != nil {
+		klog.Warningf("oidc: fetch the keys of %s err: %v", a.issuer, err)
+		return nil, err
+	}
+	if key, ok := a.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, errors.Errorf("unknown key id: %q", kid)
+}
+
+func (a *OIDCAuthenticator) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(a.keys) == 1 {
+		for _, key := range a.keys {
+			return key, true
+		}
+	}
+	key, ok := a.keys[kid]
+	return key, ok
+}
+
+func (a *OIDCAuthenticator) fetchKeys() error {
+	if a.jwksURI == "" {
+		discovery := &struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}{}
+		err := a.getJSON(a.issuer+"/.well-known/openid-configuration", discovery)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSuffix(discovery.Issuer, "/") != a.issuer {
+			return errors.Errorf("discovered issuer: %s doesn't match: %s", discovery.Issuer, a.issuer)
+		}
+		if discovery.JWKSURI == "" {
+			return errors.New("discovery has no jwks_uri")
+		}
+		a.jwksURI = discovery.JWKSURI
+	}
+
+	jwks := &jsonWebKeySet{}
+	err := a.getJSON(a.jwksURI, jwks)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]interface{}, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			klog.Warningf("oidc: skip key %q of %s: %v", k.Kid, a.issuer, err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	a.keys = keys
+	return nil
+}
+
+func (a *OIDCAuthenticator) getJSON(url string, obj interface{}) error {
+	resp, err := a.client.Get(url)
+	if err != nil {
+		return errors.Wrapf(err, "get %s", url)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("get %s: %s", url, resp.Status)
+	}
+	return errors.Wrapf(json.NewDecoder(resp.Body).Decode(obj), "decode %s", url)
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k *jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
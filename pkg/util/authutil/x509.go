@@ -0,0 +1,27 @@
+package authutil
+
+import (
+	"crypto/tls"
+
+	"github.com/pkg/errors"
+)
+
+// X509Issuer the issuer of the users of the client certificates
+const X509Issuer = "x509"
+
+// CertUser returns the user of the client certificate verified by the TLS server,
+// the common name is the user name and the organizations are the groups, as the kube-apiserver does.
+func CertUser(state *tls.ConnectionState) (*User, error) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil, errors.New("no verified client certificate")
+	}
+	cert := state.VerifiedChains[0][0]
+	if cert.Subject.CommonName == "" {
+		return nil, errors.New("client certificate has no common name")
+	}
+	return &User{
+		Name:   cert.Subject.CommonName,
+		Groups: cert.Subject.Organization,
+		Issuer: X509Issuer,
+	}, nil
+}
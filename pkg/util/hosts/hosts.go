@@ -2,7 +2,7 @@ package hosts
 
 import (
 	"fmt"
-	"regexp"
+	"strings"
 )
 
 const (
@@ -19,15 +19,43 @@ func hostFile() string {
 	return linuxHostfile
 }
 
+// setHosts maps the host to the ip, the other entries of the host are removed, so setting it again
+// leaves the data unchanged.
 func setHosts(data []byte, host, ip string) ([]byte, error) {
 	item := fmt.Sprintf("%s %s", ip, host)
-	var re = regexp.MustCompile(fmt.Sprintf(".* %s", host))
-	var newData string
-	if re.Match(data) {
-		newData = re.ReplaceAllString(string(data), item)
-	} else {
-		newData = fmt.Sprintf("%s\n%s\n", data, item)
+	var lines []string
+	if content := strings.TrimRight(string(data), "\n"); content != "" {
+		lines = strings.Split(content, "\n")
 	}
+	result := make([]string, 0, len(lines)+1)
+	found := false
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			result = append(result, line)
+			continue
+		}
 
-	return []byte(newData), nil
+		names := make([]string, 0, len(fields)-1)
+		for _, name := range fields[1:] {
+			if name != host {
+				names = append(names, name)
+			}
+		}
+		switch {
+		case len(names) == len(fields)-1:
+			result = append(result, line)
+		case !found && len(fields) == 2 && fields[0] == ip:
+			// keep the line as it is
+			result = append(result, line)
+			found = true
+		case len(names) > 0:
+			result = append(result, fields[0]+" "+strings.Join(names, " "))
+		}
+	}
+	if !found {
+		result = append(result, item)
+	}
+
+	return []byte(strings.Join(result, "\n") + "\n"), nil
 }
@@ -0,0 +1,59 @@
+package hosts
+
+import "testing"
+
+func TestSetHosts(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{
+			name: "empty",
+			data: "",
+			want: "10.0.0.1 registry.com\n",
+		},
+		{
+			name: "append",
+			data: "127.0.0.1 localhost\n",
+			want: "127.0.0.1 localhost\n10.0.0.1 registry.com\n",
+		},
+		{
+			name: "unchanged",
+			data: "127.0.0.1 localhost\n10.0.0.1 registry.com\n",
+			want: "127.0.0.1 localhost\n10.0.0.1 registry.com\n",
+		},
+		{
+			name: "replace the ip",
+			data: "127.0.0.1 localhost\n10.0.0.2 registry.com\n",
+			want: "127.0.0.1 localhost\n10.0.0.1 registry.com\n",
+		},
+		{
+			name: "remove the duplicates",
+			data: "10.0.0.1 registry.com\n10.0.0.1 registry.com\n10.0.0.2 registry.com\n",
+			want: "10.0.0.1 registry.com\n",
+		},
+		{
+			name: "keep the similar hosts",
+			data: "10.0.0.3 registry.com.cn t1.registry.com\n",
+			want: "10.0.0.3 registry.com.cn t1.registry.com\n10.0.0.1 registry.com\n",
+		},
+		{
+			name: "keep the aliases",
+			data: "10.0.0.2 registry.com registry\n# 10.0.0.2 registry.com\n",
+			want: "10.0.0.2 registry\n# 10.0.0.2 registry.com\n10.0.0.1 registry.com\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := setHosts([]byte(tt.data), "registry.com", "10.0.0.1")
+			if string(got) != tt.want {
+				t.Errorf("setHosts() = %q, want %q", got, tt.want)
+			}
+			again, _ := setHosts(got, "registry.com", "10.0.0.1")
+			if string(again) != string(got) {
+				t.Errorf("setHosts() again = %q, want %q", again, got)
+			}
+		})
+	}
+}
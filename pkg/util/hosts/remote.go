@@ -23,10 +23,13 @@ func (h *RemoteHosts) Set(ip string) error {
 	if err != nil {
 		return err
 	}
-	data, err = setHosts(data, h.Host, ip)
+	newData, err := setHosts(data, h.Host, ip)
 	if err != nil {
 		return err
 	}
+	if bytes.Equal(data, newData) {
+		return nil
+	}
 
-	return h.SSH.WriteFile(bytes.NewReader(data), linuxHostfile)
+	return h.SSH.WriteFile(bytes.NewReader(newData), linuxHostfile)
 }
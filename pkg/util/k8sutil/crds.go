@@ -2,6 +2,7 @@ package k8sutil
 
 import (
 	"context"
+	"time"
 
 	"github.com/goph/emperror"
 	extensionsobj "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
@@ -10,8 +11,12 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 func ReconcileCrds(cfg *rest.Config, crds []*extensionsobj.CustomResourceDefinition) error {
@@ -46,3 +51,35 @@ func ReconcileCrds(cfg *rest.Config, crds []*extensionsobj.CustomResourceDefinit
 	}
 	return nil
 }
+
+// WaitCrdEstablished waits until the apiserver serves the kind of the crd, the objects of the kind applied in
+// the same pass as the crd fail with no match until then.
+func WaitCrdEstablished(ctx context.Context, cli client.Client, name string, interval, timeout time.Duration) error {
+	return wait.PollImmediate(interval, timeout, func() (bool, error) {
+		crd := &unstructured.Unstructured{}
+		crd.SetGroupVersionKind(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1beta1", Kind: "CustomResourceDefinition"})
+		err := cli.Get(ctx, client.ObjectKey{Name: name}, crd)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return CrdEstablished(crd), nil
+	})
+}
+
+// CrdEstablished returns whether the Established condition of the crd is true.
+func CrdEstablished(crd *unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if cond["type"] == string(extensionsobj.Established) && cond["status"] == string(extensionsobj.ConditionTrue) {
+			return true
+		}
+	}
+	return false
+}
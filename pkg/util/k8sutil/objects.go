@@ -10,10 +10,12 @@ import (
 	"strings"
 
 	"github.com/gostship/kunkka/pkg/k8sclient"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
 	clientsetscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/klog"
 )
@@ -79,7 +81,7 @@ func RemoveNonYAMLLines(yms string) string {
 	return strings.TrimSpace(out)
 }
 
-func LoadObjs(f io.Reader) ([]runtime.Object, error) {
+func splitYamls(f io.Reader) ([]string, error) {
 	var b bytes.Buffer
 
 	var yamls []string
@@ -103,6 +105,15 @@ func LoadObjs(f io.Reader) ([]runtime.Object, error) {
 		yamls = append(yamls, s)
 	}
 
+	return yamls, nil
+}
+
+func LoadObjs(f io.Reader) ([]runtime.Object, error) {
+	yamls, err := splitYamls(f)
+	if err != nil {
+		return nil, err
+	}
+
 	objs := make([]runtime.Object, 0)
 	for _, yaml := range yamls {
 		yaml = RemoveNonYAMLLines(yaml)
@@ -125,3 +136,35 @@ func LoadObjs(f io.Reader) ([]runtime.Object, error) {
 
 	return objs, nil
 }
+
+// LoadUnstructuredObjs decodes yaml documents into unstructured objects, it is used for
+// custom resources which are not registered in the scheme (e.g. gatekeeper constraints).
+func LoadUnstructuredObjs(f io.Reader) ([]*unstructured.Unstructured, error) {
+	yamls, err := splitYamls(f)
+	if err != nil {
+		return nil, err
+	}
+
+	objs := make([]*unstructured.Unstructured, 0)
+	for _, yaml := range yamls {
+		yaml = RemoveNonYAMLLines(yaml)
+		if yaml == "" {
+			continue
+		}
+
+		data, err := yamlutil.ToJSON([]byte(yaml))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to convert YAML to json, yaml: \n %s", yaml)
+		}
+
+		obj := &unstructured.Unstructured{}
+		_, _, err = unstructured.UnstructuredJSONScheme.Decode(data, nil, obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse YAML to unstructured object, yaml: \n %s", yaml)
+		}
+
+		objs = append(objs, obj)
+	}
+
+	return objs, nil
+}
@@ -23,6 +23,18 @@ const (
 )
 
 func Reconcile(log logr.Logger, cli client.Client, desired runtime.Object, desiredState DesiredState) error {
+	_, err := reconcile(log, cli, desired, desiredState)
+	return err
+}
+
+// ReconcileDrift applies the desired object like Reconcile, it returns true if the current object was missing
+// or differed from the desired one, i.e. it drifted and is reverted.
+func ReconcileDrift(log logr.Logger, cli client.Client, desired runtime.Object) (bool, error) {
+	return reconcile(log, cli, desired, DesiredStatePresent)
+}
+
+// reconcile returns whether the object is created, updated or deleted.
+func reconcile(log logr.Logger, cli client.Client, desired runtime.Object, desiredState DesiredState) (bool, error) {
 	if desiredState == "" {
 		desiredState = DesiredStatePresent
 	}
@@ -32,13 +44,13 @@ func Reconcile(log logr.Logger, cli client.Client, desired runtime.Object, desir
 	var desiredCopy = desired.DeepCopyObject()
 	key, err := client.ObjectKeyFromObject(current)
 	if err != nil {
-		return emperror.With(err, "kind", desiredType)
+		return false, emperror.With(err, "kind", desiredType)
 	}
 	log = log.WithValues("kind", desiredType, "name", key.Name)
 
 	err = cli.Get(context.TODO(), key, current)
 	if err != nil && !apierrors.IsNotFound(err) {
-		return emperror.WrapWith(err, "getting resource failed", "kind", desiredType, "name", key.Name)
+		return false, emperror.WrapWith(err, "getting resource failed", "kind", desiredType, "name", key.Name)
 	}
 	if apierrors.IsNotFound(err) {
 		if desiredState == DesiredStatePresent {
@@ -46,9 +58,10 @@ func Reconcile(log logr.Logger, cli client.Client, desired runtime.Object, desir
 				log.Error(err, "Failed to set last applied annotation", "desired", desired)
 			}
 			if err := cli.Create(context.TODO(), desired); err != nil {
-				return emperror.WrapWith(err, "creating resource failed", "kind", desiredType, "name", key.Name)
+				return false, emperror.WrapWith(err, "creating resource failed", "kind", desiredType, "name", key.Name)
 			}
 			log.Info("resource created")
+			return true, nil
 		}
 	} else {
 		if desiredState == DesiredStatePresent {
@@ -57,7 +70,7 @@ func Reconcile(log logr.Logger, cli client.Client, desired runtime.Object, desir
 				log.Error(err, "could not match objects", "kind", desiredType, "name", key.Name)
 			} else if patchResult.IsEmpty() {
 				log.V(1).Info("resource is in sync")
-				return nil
+				return false, nil
 			} else {
 				log.V(1).Info("resource diffs",
 					"patch", string(patchResult.Patch),
@@ -74,7 +87,7 @@ func Reconcile(log logr.Logger, cli client.Client, desired runtime.Object, desir
 			metaAccessor := meta.NewAccessor()
 			currentResourceVersion, err := metaAccessor.ResourceVersion(current)
 			if err != nil {
-				return err
+				return false, err
 			}
 
 			metaAccessor.SetResourceVersion(desired, currentResourceVersion)
@@ -85,27 +98,29 @@ func Reconcile(log logr.Logger, cli client.Client, desired runtime.Object, desir
 					log.Info("resource needs to be re-created", "error", err)
 					err := cli.Delete(context.TODO(), current)
 					if err != nil {
-						return emperror.WrapWith(err, "could not delete resource", "kind", desiredType, "name", key.Name)
+						return false, emperror.WrapWith(err, "could not delete resource", "kind", desiredType, "name", key.Name)
 					}
 					log.Info("resource deleted")
 					if err := cli.Create(context.TODO(), desiredCopy); err != nil {
-						return emperror.WrapWith(err, "creating resource failed", "kind", desiredType, "name", key.Name)
+						return false, emperror.WrapWith(err, "creating resource failed", "kind", desiredType, "name", key.Name)
 					}
 					log.Info("resource created")
-					return nil
+					return true, nil
 				}
 
-				return emperror.WrapWith(err, "updating resource failed", "kind", desiredType, "name", key.Name)
+				return false, emperror.WrapWith(err, "updating resource failed", "kind", desiredType, "name", key.Name)
 			}
 			log.Info("resource updated")
+			return true, nil
 		} else if desiredState == DesiredStateAbsent {
 			if err := cli.Delete(context.TODO(), current); err != nil {
-				return emperror.WrapWith(err, "deleting resource failed", "kind", desiredType, "name", key.Name)
+				return false, emperror.WrapWith(err, "deleting resource failed", "kind", desiredType, "name", key.Name)
 			}
 			log.Info("resource deleted")
+			return true, nil
 		}
 	}
-	return nil
+	return false, nil
 }
 
 func prepareResourceForUpdate(current, desired runtime.Object) {
@@ -0,0 +1,138 @@
+package leader
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Elector runs the added runnables on the leader only, for the managers serving on all the replicas, e.g. the
+// api whose manager runs without leader election. The runnables are stopped when the leadership is lost and
+// started again once it's regained. It runs them directly if the election is disabled.
+type Elector struct {
+	opt       *Options
+	cfg       *rest.Config
+	runnables []manager.Runnable
+	// running is held while the runnables run, the runnables of the lost leadership are stopped before
+	// they're started again
+	running sync.Mutex
+}
+
+// NewElector returns an Elector of the options with the client config of the lock.
+func NewElector(cfg *rest.Config, opt *Options) *Elector {
+	return &Elector{opt: opt, cfg: cfg}
+}
+
+// Add adds the runnable run on the leader, it must be called before the Elector is started.
+func (e *Elector) Add(r manager.Runnable) {
+	e.runnables = append(e.runnables, r)
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, the Elector campaigns on all the replicas.
+func (e *Elector) NeedLeaderElection() bool {
+	return false
+}
+
+// Start campaigns until stop is closed.
+func (e *Elector) Start(stop <-chan struct{}) error {
+	if !e.opt.Enabled {
+		e.run(stop)
+		return nil
+	}
+
+	le, err := e.newLeaderElector()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	// Run returns once the leadership is lost, the replica campaigns again as a candidate
+	for ctx.Err() == nil {
+		le.Run(ctx)
+	}
+	return nil
+}
+
+func (e *Elector) newLeaderElector() (*leaderelection.LeaderElector, error) {
+	kubeCli, err := kubernetes.NewForConfig(e.cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "new leader election client")
+	}
+
+	id, err := identity()
+	if err != nil {
+		return nil, err
+	}
+	lock, err := resourcelock.New(resourcelock.ConfigMapsResourceLock, e.opt.Namespace, e.opt.ID,
+		kubeCli.CoreV1(), kubeCli.CoordinationV1(), resourcelock.ResourceLockConfig{Identity: id})
+	if err != nil {
+		return nil, errors.Wrapf(err, "new leader election lock %s/%s", e.opt.Namespace, e.opt.ID)
+	}
+
+	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   e.opt.LeaseDuration,
+		RenewDeadline:   e.opt.RenewDeadline,
+		RetryPeriod:     e.opt.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            e.opt.ID,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				klog.Infof("%s became the leader of %s/%s", id, e.opt.Namespace, e.opt.ID)
+				e.run(ctx.Done())
+			},
+			OnStoppedLeading: func() {
+				klog.Infof("%s stopped leading %s/%s", id, e.opt.Namespace, e.opt.ID)
+			},
+			OnNewLeader: func(leader string) {
+				klog.V(2).Infof("the leader of %s/%s is %s", e.opt.Namespace, e.opt.ID, leader)
+			},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "new leader elector %s/%s", e.opt.Namespace, e.opt.ID)
+	}
+	return le, nil
+}
+
+// run runs the runnables until stop is closed and they return.
+func (e *Elector) run(stop <-chan struct{}) {
+	e.running.Lock()
+	defer e.running.Unlock()
+
+	var wg sync.WaitGroup
+	for _, r := range e.runnables {
+		wg.Add(1)
+		go func(r manager.Runnable) {
+			defer wg.Done()
+			if err := r.Start(stop); err != nil {
+				klog.Errorf("leader runnable %T err: %v", r, err)
+			}
+		}(r)
+	}
+	wg.Wait()
+}
+
+// identity returns the identity of the replica in the lock, the hostname is the pod name.
+func identity() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", errors.Wrap(err, "get hostname")
+	}
+	return hostname + "_" + string(uuid.NewUUID()), nil
+}
@@ -0,0 +1,90 @@
+package leader
+
+import (
+	"testing"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(o *Options)
+		wantErr bool
+	}{
+		{
+			name:   "disabled",
+			modify: func(o *Options) { o.LeaseDuration = 0 },
+		},
+		{
+			name:   "defaults",
+			modify: func(o *Options) { o.Enabled = true },
+		},
+		{
+			name: "empty id",
+			modify: func(o *Options) {
+				o.Enabled = true
+				o.ID = ""
+			},
+			wantErr: true,
+		},
+		{
+			name: "lease shorter than renew deadline",
+			modify: func(o *Options) {
+				o.Enabled = true
+				o.LeaseDuration = 5 * time.Second
+			},
+			wantErr: true,
+		},
+		{
+			name: "renew deadline shorter than retry period",
+			modify: func(o *Options) {
+				o.Enabled = true
+				o.RetryPeriod = 10 * time.Second
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := DefaultOptions("kunkka-test")
+			tt.modify(o)
+			if err := o.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestElectorDisabled(t *testing.T) {
+	e := NewElector(nil, DefaultOptions("kunkka-test"))
+	started := make(chan struct{})
+	e.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		close(started)
+		<-stop
+		return nil
+	}))
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- e.Start(stop)
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatalf("the runnable is not started without the election")
+	}
+	close(stop)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Start() err = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Start() doesn't return once stopped")
+	}
+}
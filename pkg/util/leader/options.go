@@ -0,0 +1,71 @@
+// Package leader elects a leader among the replicas of the controller or the api, so that they can run with
+// multiple replicas behind a Service while the loops which must not run twice at a time run on the leader only.
+package leader
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Options the leader election of the replicas, the lock is the configmap named by ID in Namespace.
+type Options struct {
+	Enabled       bool
+	Namespace     string
+	ID            string
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// DefaultOptions returns the options of the lock id with the defaults of client-go, disabled.
+func DefaultOptions(id string) *Options {
+	return &Options{
+		Namespace:     "kunkka-system",
+		ID:            id,
+		LeaseDuration: 15 * time.Second,
+		RenewDeadline: 10 * time.Second,
+		RetryPeriod:   2 * time.Second,
+	}
+}
+
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&o.Enabled, "enable-leader-election", o.Enabled, "Enables the leader election of the replicas, only the leader runs the controllers.")
+	fs.StringVar(&o.Namespace, "leader-election-namespace", o.Namespace, "The namespace of the leader election configmap.")
+	fs.StringVar(&o.ID, "leader-election-id", o.ID, "The name of the leader election configmap.")
+	fs.DurationVar(&o.LeaseDuration, "leader-election-lease-duration", o.LeaseDuration, "The duration the other replicas wait before taking over the leadership of a leader not renewing it.")
+	fs.DurationVar(&o.RenewDeadline, "leader-election-renew-deadline", o.RenewDeadline, "The duration the leader retries renewing the leadership before giving it up.")
+	fs.DurationVar(&o.RetryPeriod, "leader-election-retry-period", o.RetryPeriod, "The duration between the tries of acquiring or renewing the leadership.")
+}
+
+// Validate checks the durations, the lease must outlast the renew deadline which must outlast the retry period.
+func (o *Options) Validate() error {
+	if !o.Enabled {
+		return nil
+	}
+	if o.ID == "" {
+		return errors.New("leader election id is empty")
+	}
+	if o.LeaseDuration <= o.RenewDeadline {
+		return errors.Errorf("leader election lease duration %s must be greater than the renew deadline %s", o.LeaseDuration, o.RenewDeadline)
+	}
+	if o.RenewDeadline <= o.RetryPeriod {
+		return errors.Errorf("leader election renew deadline %s must be greater than the retry period %s", o.RenewDeadline, o.RetryPeriod)
+	}
+	if o.RetryPeriod <= 0 {
+		return errors.Errorf("leader election retry period %s must be positive", o.RetryPeriod)
+	}
+	return nil
+}
+
+// Apply sets the leader election of the manager, the controllers run on the leader only.
+func (o *Options) Apply(mo *manager.Options) {
+	mo.LeaderElection = o.Enabled
+	mo.LeaderElectionNamespace = o.Namespace
+	mo.LeaderElectionID = o.ID
+	mo.LeaseDuration = &o.LeaseDuration
+	mo.RenewDeadline = &o.RenewDeadline
+	mo.RetryPeriod = &o.RetryPeriod
+}
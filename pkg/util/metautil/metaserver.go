@@ -588,7 +588,7 @@ func BuildExtendObj(cli client.Client) ([]devopsv1.Cluster, error) {
 	for _, obj := range cms.Items {
 		ob, err := k8sutil.LoadObjs(bytes.NewReader([]byte(obj.Data["List"])))
 		if err != nil {
-			klog.Error("load extend objs err: %v", err)
+			klog.Errorf("load extend objs err: %v", err)
 			return nil, err
 		}
 		for _, cls := range ob {
@@ -600,21 +600,33 @@ func BuildExtendObj(cli client.Client) ([]devopsv1.Cluster, error) {
 	return clsList, nil
 }
 
+// ClusterConditionOfContains fills the step with the status, the diagnostics and the timing of its condition.
 func ClusterConditionOfContains(cond1 []devopsv1.ClusterCondition, cond2 *model.RuntimeCondition) *model.RuntimeCondition {
 	for _, con := range cond1 {
 		if con.Type == cond2.Type {
 			cond2.Status = con.Status
 			cond2.Time = con.LastProbeTime
+			cond2.LastTransitionTime = con.LastTransitionTime
+			cond2.Reason = con.Reason
+			cond2.Message = con.Message
+			cond2.Attempts = con.Attempts
+			cond2.Duration = con.Duration
 		}
 	}
 	return cond2
 }
 
+// MachineConditionOfContains fills the step with the status, the diagnostics and the timing of its condition.
 func MachineConditionOfContains(cond1 []devopsv1.MachineCondition, cond2 *model.RuntimeCondition) *model.RuntimeCondition {
 	for _, con := range cond1 {
 		if con.Type == cond2.Type {
 			cond2.Status = con.Status
 			cond2.Time = con.LastProbeTime
+			cond2.LastTransitionTime = con.LastTransitionTime
+			cond2.Reason = con.Reason
+			cond2.Message = con.Message
+			cond2.Attempts = con.Attempts
+			cond2.Duration = con.Duration
 		}
 	}
 	return cond2
@@ -0,0 +1,61 @@
+package parallel
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Limiter bounds the number of the ids holding a slot of each key at a time, e.g. the machines of a cluster
+// provisioned at a time. The slots are held across the reconciles until released.
+type Limiter struct {
+	mu    sync.Mutex
+	slots map[string]sets.String
+}
+
+// NewLimiter returns an empty Limiter.
+func NewLimiter() *Limiter {
+	return &Limiter{slots: make(map[string]sets.String)}
+}
+
+// Acquire takes a slot of the key for the id if less than limit ids hold one, the id holding a slot already
+// keeps it. The limits less than 1 are treated as 1.
+func (l *Limiter) Acquire(key, id string, limit int) bool {
+	if limit < 1 {
+		limit = 1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	held := l.slots[key]
+	if held.Has(id) {
+		return true
+	}
+	if held.Len() >= limit {
+		return false
+	}
+	if held == nil {
+		held = sets.NewString()
+		l.slots[key] = held
+	}
+	held.Insert(id)
+	return true
+}
+
+// Release frees the slot of the key held by the id, if any.
+func (l *Limiter) Release(key, id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	held := l.slots[key]
+	held.Delete(id)
+	if held.Len() == 0 {
+		delete(l.slots, key)
+	}
+}
+
+// Holding returns the ids holding a slot of the key, sorted.
+func (l *Limiter) Holding(key string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.slots[key].List()
+}
@@ -0,0 +1,71 @@
+package parallel
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLimiter(t *testing.T) {
+	type step struct {
+		release bool
+		key     string
+		id      string
+		limit   int
+		want    bool
+	}
+	tests := []struct {
+		name    string
+		steps   []step
+		holding map[string][]string
+	}{
+		{
+			name: "bounded per key",
+			steps: []step{
+				{key: "c1", id: "m1", limit: 2, want: true},
+				{key: "c1", id: "m2", limit: 2, want: true},
+				{key: "c1", id: "m3", limit: 2, want: false},
+				{key: "c2", id: "m3", limit: 2, want: true},
+			},
+			holding: map[string][]string{"c1": {"m1", "m2"}, "c2": {"m3"}},
+		},
+		{
+			name: "held slot kept",
+			steps: []step{
+				{key: "c1", id: "m1", limit: 1, want: true},
+				{key: "c1", id: "m1", limit: 1, want: true},
+				{key: "c1", id: "m2", limit: 1, want: false},
+			},
+			holding: map[string][]string{"c1": {"m1"}},
+		},
+		{
+			name: "released slot reused",
+			steps: []step{
+				{key: "c1", id: "m1", limit: 0, want: true},
+				{key: "c1", id: "m2", limit: 0, want: false},
+				{release: true, key: "c1", id: "m1"},
+				{release: true, key: "c1", id: "m3"},
+				{key: "c1", id: "m2", limit: 0, want: true},
+			},
+			holding: map[string][]string{"c1": {"m2"}, "c2": {}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLimiter()
+			for i, s := range tt.steps {
+				if s.release {
+					l.Release(s.key, s.id)
+					continue
+				}
+				if got := l.Acquire(s.key, s.id, s.limit); got != s.want {
+					t.Errorf("step %d Acquire(%s, %s, %d) = %v, want %v", i, s.key, s.id, s.limit, got, s.want)
+				}
+			}
+			for key, want := range tt.holding {
+				if got := l.Holding(key); !reflect.DeepEqual(got, want) {
+					t.Errorf("Holding(%s) = %v, want %v", key, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+// Package parallel runs the per machine steps of the phases across the machines with bounded concurrency,
+// the limit is set globally by the --ssh-concurrency flag.
+package parallel
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/util/ssh"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// DefaultConcurrency the default number of machines a phase runs on at a time
+const DefaultConcurrency = 10
+
+var concurrency int32 = DefaultConcurrency
+
+// Concurrency returns the number of machines a phase runs on at a time.
+func Concurrency() int {
+	return int(atomic.LoadInt32(&concurrency))
+}
+
+// SetConcurrency sets the number of machines a phase runs on at a time, the values less than 1 run serially.
+func SetConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	atomic.StoreInt32(&concurrency, int32(n))
+}
+
+// Run calls fn for 0 to n-1 with at most limit calls at a time, it doesn't stop on the errors
+// but returns all of them aggregated in the order of the indexes.
+func Run(n, limit int, fn func(i int) error) error {
+	if limit < 1 {
+		limit = 1
+	}
+
+	errs := make([]error, n)
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = fn(i)
+		}(i)
+	}
+	wg.Wait()
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// Machines calls fn with the ssh of each machine with the global concurrency,
+// the errors are prefixed by the ips of the machines.
+func Machines(machines []*devopsv1.ClusterMachine, fn func(m *devopsv1.ClusterMachine, s ssh.Interface) error) error {
+	return Run(len(machines), Concurrency(), func(i int) error {
+		m := machines[i]
+		s, err := m.SSH()
+		if err != nil {
+			return errors.Wrap(err, m.IP)
+		}
+		return errors.Wrap(fn(m, s), m.IP)
+	})
+}
+
+type flagValue struct{}
+
+func (flagValue) String() string {
+	return strconv.Itoa(Concurrency())
+}
+
+func (flagValue) Set(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	SetConcurrency(n)
+	return nil
+}
+
+func (flagValue) Type() string {
+	return "int"
+}
+
+// AddFlags adds the --ssh-concurrency flag which sets the global concurrency.
+func AddFlags(fs *pflag.FlagSet) {
+	fs.Var(flagValue{}, "ssh-concurrency", "The number of machines the phases run the commands on at a time, e.g. installing the system and the components")
+}
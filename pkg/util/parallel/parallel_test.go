@@ -0,0 +1,85 @@
+package parallel
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		n        int
+		limit    int
+		failed   map[int]bool
+		wantErrs int
+	}{
+		{name: "none", n: 0, limit: 3},
+		{name: "serial", n: 5, limit: 0},
+		{name: "bounded", n: 20, limit: 4},
+		{name: "errors aggregated", n: 10, limit: 3, failed: map[int]bool{2: true, 7: true}, wantErrs: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var running, max, calls int32
+			err := Run(tt.n, tt.limit, func(i int) error {
+				atomic.AddInt32(&calls, 1)
+				cur := atomic.AddInt32(&running, 1)
+				defer atomic.AddInt32(&running, -1)
+				for {
+					m := atomic.LoadInt32(&max)
+					if cur <= m || atomic.CompareAndSwapInt32(&max, m, cur) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				if tt.failed[i] {
+					return fmt.Errorf("machine %d", i)
+				}
+				return nil
+			})
+
+			if int(calls) != tt.n {
+				t.Errorf("calls = %d, want %d", calls, tt.n)
+			}
+			limit := tt.limit
+			if limit < 1 {
+				limit = 1
+			}
+			if int(max) > limit {
+				t.Errorf("concurrency = %d, want at most %d", max, limit)
+			}
+			if tt.wantErrs == 0 {
+				if err != nil {
+					t.Fatalf("Run() error = %v", err)
+				}
+				return
+			}
+			agg, ok := err.(utilerrors.Aggregate)
+			if !ok || len(agg.Errors()) != tt.wantErrs {
+				t.Fatalf("Run() error = %v, want %d errors", err, tt.wantErrs)
+			}
+			if agg.Errors()[0].Error() != "machine 2" {
+				t.Errorf("errors not in order: %v", agg)
+			}
+		})
+	}
+}
+
+func TestSetConcurrency(t *testing.T) {
+	defer SetConcurrency(DefaultConcurrency)
+
+	v := flagValue{}
+	if err := v.Set("32"); err != nil || Concurrency() != 32 {
+		t.Errorf("Set(32) = %v, concurrency %d", err, Concurrency())
+	}
+	if err := v.Set("0"); err != nil || Concurrency() != 1 {
+		t.Errorf("Set(0) = %v, concurrency %d", err, Concurrency())
+	}
+	if err := v.Set("x"); err == nil {
+		t.Error("Set(x) succeeded")
+	}
+}
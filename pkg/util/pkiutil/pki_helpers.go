@@ -46,6 +46,8 @@ const NotAfter = duration365d * 100
 type CertConfig struct {
 	certutil.Config
 	PublicKeyAlgorithm x509.PublicKeyAlgorithm
+	// Validity the lifetime of the signed certificate, NotAfter if zero
+	Validity time.Duration
 }
 
 // NewCertificateAuthority creates new certificate and private key for the certificate authority
@@ -487,6 +489,10 @@ func NewSignedCert(cfg *CertConfig, key crypto.Signer, caCert *x509.Certificate,
 	if len(cfg.Usages) == 0 {
 		return nil, errors.New("must specify at least one ExtKeyUsage")
 	}
+	validity := NotAfter
+	if cfg.Validity > 0 {
+		validity = cfg.Validity
+	}
 
 	certTmpl := x509.Certificate{
 		Subject: pkix.Name{
@@ -497,7 +503,7 @@ func NewSignedCert(cfg *CertConfig, key crypto.Signer, caCert *x509.Certificate,
 		IPAddresses:  cfg.AltNames.IPs,
 		SerialNumber: serial,
 		NotBefore:    caCert.NotBefore,
-		NotAfter:     time.Now().Add(NotAfter).UTC(),
+		NotAfter:     time.Now().Add(validity).UTC(),
 		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
 		ExtKeyUsage:  cfg.Usages,
 	}
@@ -0,0 +1,62 @@
+package responseutil
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Error an error with the http status and the custom code of its response, the handlers return it
+// by Gin.RespErr.
+type Error struct {
+	Status int
+	Code   int
+	Detail string
+	// Details the optional object of the error for the clients, e.g. the pods left on a node
+	Details interface{}
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+// NewError returns an error of the http status and the custom code, the detail is formatted.
+func NewError(status int, code int, format string, args ...interface{}) *Error {
+	return &Error{Status: status, Code: code, Detail: fmt.Sprintf(format, args...)}
+}
+
+// WithDetails sets the details object of the error.
+func (e *Error) WithDetails(details interface{}) *Error {
+	e.Details = details
+	return e
+}
+
+// BadRequest returns an error of the invalid params.
+func BadRequest(format string, args ...interface{}) *Error {
+	return NewError(http.StatusBadRequest, HTTP_INVALID_PARAMS, format, args...)
+}
+
+// NotFound returns an error of the resource not found.
+func NotFound(format string, args ...interface{}) *Error {
+	return NewError(http.StatusNotFound, HTTP_NOT_FOUND, format, args...)
+}
+
+// Conflict returns an error of the request conflicting with the state of the resource.
+func Conflict(format string, args ...interface{}) *Error {
+	return NewError(http.StatusConflict, HTTP_CONFLICT, format, args...)
+}
+
+// ClusterUnavailable returns an error of the member cluster not connected or offline.
+func ClusterUnavailable(format string, args ...interface{}) *Error {
+	return NewError(http.StatusServiceUnavailable, HTTP_CLUSTER_UNAVAILABLE, format, args...)
+}
+
+// NewTypedError returns the body of the error response of the http status and the custom code.
+func NewTypedError(status int, code int, detail string, details interface{}) *TypedError {
+	return &TypedError{
+		Code:    code,
+		Reason:  Reason(status),
+		Message: GetRequestMsg(code),
+		Detail:  detail,
+		Details: details,
+	}
+}
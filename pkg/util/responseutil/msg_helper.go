@@ -9,6 +9,14 @@ const (
 	HTTP_REQUEST_BIND_ERROR     = 20002
 	HTTP_GET_ARGS_ERRPR         = 20003
 	SQL_EXEC_ERROR              = 20004
+	HTTP_BODY_TOO_LARGE         = 20005
+	HTTP_CONTENT_TYPE_ERROR     = 20006
+	HTTP_INVALID_PARAMS         = 20007
+	HTTP_FORBIDDEN              = 20008
+	HTTP_TOO_MANY_REQUESTS      = 20009
+	HTTP_NOT_FOUND              = 20010
+	HTTP_CONFLICT               = 20011
+	HTTP_CLUSTER_UNAVAILABLE    = 20012
 )
 
 // definition map of custom message
@@ -20,6 +28,14 @@ var MsgFlags = map[int]string{
 	HTTP_REQUEST_BIND_ERROR:     "绑定参数失败",
 	HTTP_GET_ARGS_ERRPR:         "获取参数失败",
 	SQL_EXEC_ERROR:              "SQL执行失败",
+	HTTP_BODY_TOO_LARGE:         "请求体过大",
+	HTTP_CONTENT_TYPE_ERROR:     "不支持的Content-Type",
+	HTTP_INVALID_PARAMS:         "参数不合法",
+	HTTP_FORBIDDEN:              "权限不足",
+	HTTP_TOO_MANY_REQUESTS:      "请求过于频繁",
+	HTTP_NOT_FOUND:              "资源不存在",
+	HTTP_CONFLICT:               "资源状态冲突",
+	HTTP_CLUSTER_UNAVAILABLE:    "集群不可用",
 }
 
 // GetRequestMsg  return custom message
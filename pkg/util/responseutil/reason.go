@@ -0,0 +1,60 @@
+package responseutil
+
+import "net/http"
+
+// the typed reasons of the errors of the versioned apis, the same as the reasons of the kubernetes Status
+const (
+	ReasonBadRequest            = "BadRequest"
+	ReasonUnauthorized          = "Unauthorized"
+	ReasonForbidden             = "Forbidden"
+	ReasonNotFound              = "NotFound"
+	ReasonMethodNotAllowed      = "MethodNotAllowed"
+	ReasonConflict              = "Conflict"
+	ReasonRequestEntityTooLarge = "RequestEntityTooLarge"
+	ReasonUnsupportedMediaType  = "UnsupportedMediaType"
+	ReasonTooManyRequests       = "TooManyRequests"
+	ReasonInternalError         = "InternalError"
+	ReasonBadGateway            = "BadGateway"
+	ReasonServiceUnavailable    = "ServiceUnavailable"
+	ReasonTimeout               = "Timeout"
+	ReasonUnknown               = "Unknown"
+)
+
+var statusReasons = map[int]string{
+	http.StatusBadRequest:            ReasonBadRequest,
+	http.StatusUnauthorized:          ReasonUnauthorized,
+	http.StatusForbidden:             ReasonForbidden,
+	http.StatusNotFound:              ReasonNotFound,
+	http.StatusMethodNotAllowed:      ReasonMethodNotAllowed,
+	http.StatusConflict:              ReasonConflict,
+	http.StatusRequestEntityTooLarge: ReasonRequestEntityTooLarge,
+	http.StatusUnsupportedMediaType:  ReasonUnsupportedMediaType,
+	http.StatusTooManyRequests:       ReasonTooManyRequests,
+	http.StatusInternalServerError:   ReasonInternalError,
+	http.StatusBadGateway:            ReasonBadGateway,
+	http.StatusServiceUnavailable:    ReasonServiceUnavailable,
+	http.StatusGatewayTimeout:        ReasonTimeout,
+}
+
+// TypedError the body of the errors: the custom code, the typed reason of the http status, the message
+// of the code, the detail of the error and the optional details object, e.g. the machines missing.
+type TypedError struct {
+	Success bool        `json:"success"`
+	Code    int         `json:"code"`
+	Reason  string      `json:"reason"`
+	Message string      `json:"message"`
+	Detail  string      `json:"detail"`
+	Details interface{} `json:"details,omitempty"`
+	Data    interface{} `json:"data"`
+}
+
+// Reason returns the typed reason of the http status of an error.
+func Reason(status int) string {
+	if reason, ok := statusReasons[status]; ok {
+		return reason
+	}
+	if status >= http.StatusInternalServerError {
+		return ReasonInternalError
+	}
+	return ReasonUnknown
+}
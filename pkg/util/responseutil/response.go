@@ -1,6 +1,8 @@
 package responseutil
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 )
@@ -17,14 +19,26 @@ func (g *Gin) Bind(s interface{}) (interface{}, error) {
 	return s, nil
 }
 
-// http bind Params error response
+// RespError aborts with an internal error, the str is the detail of the error. The handlers of the errors
+// the clients can act on use RespErrorCode or RespErr.
 func (g *Gin) RespError(str string) {
-	g.Ctx.AbortWithStatusJSON(400, gin.H{
-		"success": false,
-		"message": str,
-		"data":    nil,
-	})
-	return
+	g.RespErrorCode(http.StatusInternalServerError, HTTP_ERROR, str)
+}
+
+// RespErrorCode aborts with the http status and a structured error:
+// the custom code, its message and the detail of the error.
+func (g *Gin) RespErrorCode(status int, code int, detail string) {
+	g.Ctx.AbortWithStatusJSON(status, NewTypedError(status, code, detail, nil))
+}
+
+// RespErr aborts with the status, the code and the details of an Error, the other errors are internal errors.
+func (g *Gin) RespErr(err error) {
+	e, ok := err.(*Error)
+	if !ok {
+		g.RespError(err.Error())
+		return
+	}
+	g.Ctx.AbortWithStatusJSON(e.Status, NewTypedError(e.Status, e.Code, e.Detail, e.Details))
 }
 
 // http success response
@@ -0,0 +1,73 @@
+package responseutil
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespErr(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		resp   func(g *Gin)
+		status int
+		want   *TypedError
+	}{
+		{
+			name:   "error",
+			resp:   func(g *Gin) { g.RespError("yamlToJson error") },
+			status: http.StatusInternalServerError,
+			want:   &TypedError{Code: HTTP_ERROR, Reason: ReasonInternalError, Message: "请求失败", Detail: "yamlToJson error"},
+		},
+		{
+			name: "code",
+			resp: func(g *Gin) {
+				g.RespErrorCode(http.StatusBadRequest, HTTP_INVALID_PARAMS, "count: must be between 1 and 50")
+			},
+			status: http.StatusBadRequest,
+			want:   &TypedError{Code: HTTP_INVALID_PARAMS, Reason: ReasonBadRequest, Message: "参数不合法", Detail: "count: must be between 1 and 50"},
+		},
+		{
+			name: "typed error with details",
+			resp: func(g *Gin) {
+				g.RespErr(Conflict("%d free machines, %d requested", 1, 3).WithDetails(map[string]int{"free": 1, "requested": 3}))
+			},
+			status: http.StatusConflict,
+			want: &TypedError{Code: HTTP_CONFLICT, Reason: ReasonConflict, Message: "资源状态冲突", Detail: "1 free machines, 3 requested",
+				Details: map[string]interface{}{"free": float64(1), "requested": float64(3)}},
+		},
+		{
+			name:   "cluster unavailable",
+			resp:   func(g *Gin) { g.RespErr(ClusterUnavailable("cluster: %s found, but offline", "c1")) },
+			status: http.StatusServiceUnavailable,
+			want:   &TypedError{Code: HTTP_CLUSTER_UNAVAILABLE, Reason: ReasonServiceUnavailable, Message: "集群不可用", Detail: "cluster: c1 found, but offline"},
+		},
+		{
+			name:   "untyped error",
+			resp:   func(g *Gin) { g.RespErr(errors.New("connection refused")) },
+			status: http.StatusInternalServerError,
+			want:   &TypedError{Code: HTTP_ERROR, Reason: ReasonInternalError, Message: "请求失败", Detail: "connection refused"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			tt.resp(&Gin{Ctx: c})
+
+			got := &TypedError{}
+			if err := json.Unmarshal(w.Body.Bytes(), got); err != nil {
+				t.Fatalf("invalid error body %s: %v", w.Body.String(), err)
+			}
+			if w.Code != tt.status || !c.IsAborted() || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %d %+v, want %d %+v", w.Code, got, tt.status, tt.want)
+			}
+		})
+	}
+}
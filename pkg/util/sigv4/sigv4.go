@@ -0,0 +1,136 @@
+// Package sigv4 signs the requests of the AWS APIs and of the S3 compatible object storages by the AWS
+// signature version 4.
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Algorithm the signing algorithm of the Authorization header
+	Algorithm = "AWS4-HMAC-SHA256"
+
+	timeFormat = "20060102T150405Z"
+	dateFormat = "20060102"
+)
+
+// Credentials the access key signing the requests, SessionToken is set for the temporary credentials.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// Signer signs the requests of the service in the region.
+type Signer struct {
+	Credentials
+	Region  string
+	Service string
+	// ContentSHA256 sets the X-Amz-Content-Sha256 header of the payload hash, required by S3
+	ContentSHA256 bool
+}
+
+// Sign signs the request with the body at the time, the host and the headers set on the request are signed.
+func (s *Signer) Sign(req *http.Request, body []byte, now time.Time) {
+	now = now.UTC()
+	date := now.Format(dateFormat)
+	payloadHash := HashHex(body)
+	req.Header.Set("X-Amz-Date", now.Format(timeFormat))
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	if s.ContentSHA256 {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.Region, s.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{Algorithm, now.Format(timeFormat), scope, HashHex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	for _, v := range []string{s.Region, s.Service, "aws4_request"} {
+		key = hmacSHA256(key, v)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		Algorithm, s.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// CanonicalQuery returns the sorted query string encoded as required by the signature.
+func CanonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(query))
+	for k, vs := range query {
+		for _, v := range vs {
+			pairs = append(pairs, URIEncode(k, true)+"="+URIEncode(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// URIEncode encodes all the bytes but the unreserved characters, the slashes of the paths are kept.
+func URIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// HashHex returns the hex encoded sha256 of the data.
+func HashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,115 @@
+package ssh
+
+import (
+	"encoding/json"
+	"regexp"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	// MaxAuditStderr the stderr of the commands is truncated to its last bytes in the audit records
+	MaxAuditStderr = 1024
+	// MaxAuditCommand the commands are truncated to the bytes in the audit records, e.g. the written files
+	MaxAuditCommand = 4096
+)
+
+// Record is the audit of a command run on a machine.
+type Record struct {
+	Time     time.Time `json:"time"`
+	Cluster  string    `json:"cluster,omitempty"`
+	Phase    string    `json:"phase,omitempty"`
+	Host     string    `json:"host"`
+	User     string    `json:"user"`
+	Command  string    `json:"command"`
+	ExitCode int       `json:"exitCode"`
+	// Duration in milliseconds
+	Duration int64  `json:"duration"`
+	Stderr   string `json:"stderr,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Auditor receives the audit records of all the commands, it must not block.
+type Auditor interface {
+	Audit(r *Record)
+}
+
+var (
+	auditorMu sync.RWMutex
+	auditor   Auditor
+)
+
+// SetAuditor sets the auditor the records are sent to besides the logs, nil only logs them.
+func SetAuditor(a Auditor) {
+	auditorMu.Lock()
+	defer auditorMu.Unlock()
+	auditor = a
+}
+
+// secretArgs the values of the flags are masked in the audit records, e.g. the kubeadm join tokens
+var secretArgs = regexp.MustCompile(`(--(?:[a-z-]*token[a-z-]*|certificate-key|password|discovery-token-ca-cert-hash)[= ])('[^']*'|"[^"]*"|\S+)`)
+
+// Redact masks the secrets of the command.
+func Redact(cmd string) string {
+	return secretArgs.ReplaceAllString(cmd, "${1}***")
+}
+
+// auditCommand returns the redacted command truncated to MaxAuditCommand.
+func auditCommand(cmd string) string {
+	cmd = Redact(cmd)
+	if len(cmd) > MaxAuditCommand {
+		cmd = cmd[:MaxAuditCommand] + "..."
+	}
+	return cmd
+}
+
+// audit logs the command as a json line and sends it to the auditor.
+func (s *SSH) audit(cmd string, start time.Time, exit int, stderr string, err error) {
+	if len(stderr) > MaxAuditStderr {
+		stderr = "..." + stderr[len(stderr)-MaxAuditStderr:]
+	}
+	cmd = auditCommand(cmd)
+	r := &Record{
+		Time:     start.UTC(),
+		Cluster:  s.cluster,
+		Phase:    s.phase,
+		Host:     s.Host,
+		User:     s.User,
+		Command:  cmd,
+		ExitCode: exit,
+		Duration: time.Since(start).Milliseconds(),
+		Stderr:   stderr,
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	data, _ := json.Marshal(r)
+	klog.Infof("ssh audit: %s", data)
+
+	auditorMu.RLock()
+	a := auditor
+	auditorMu.RUnlock()
+	if a != nil {
+		a.Audit(r)
+	}
+}
+
+// tailBuffer keeps the last MaxAuditStderr bytes written.
+type tailBuffer struct {
+	data []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	if len(b.data) > 2*MaxAuditStderr {
+		b.data = append([]byte{}, b.data[len(b.data)-MaxAuditStderr-1:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.data)
+}
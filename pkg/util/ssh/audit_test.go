@@ -0,0 +1,81 @@
+package ssh
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+type testAuditor struct {
+	mu      sync.Mutex
+	records []*Record
+}
+
+func (a *testAuditor) Audit(r *Record) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.records = append(a.records, r)
+}
+
+func TestAudit(t *testing.T) {
+	a := &testAuditor{}
+	SetAuditor(a)
+	defer SetAuditor(nil)
+
+	target := newTestServer(t, "target", "t")
+	c := target.config("t")
+	c.Cluster, c.Phase = "c1", "EnsureKubeadmInit"
+	s, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := s.Exec("kubeadm join 10.0.0.1:6443 --token abcdef.0123456789abcdef --v=2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ExecStream("hostname", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(a.records) != 2 {
+		t.Fatalf("got %d records, want 2", len(a.records))
+	}
+	r := a.records[0]
+	if r.Cluster != "c1" || r.Phase != "EnsureKubeadmInit" || r.Host != c.Host || r.User != "root" || r.ExitCode != 0 || r.Error != "" {
+		t.Errorf("record: %+v", r)
+	}
+	if r.Command != "kubeadm join 10.0.0.1:6443 --token *** --v=2" {
+		t.Errorf("command: %q", r.Command)
+	}
+	if a.records[1].Command != "hostname" {
+		t.Errorf("command: %q", a.records[1].Command)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{cmd: "systemctl restart kubelet", want: "systemctl restart kubelet"},
+		{cmd: "kubeadm join --token=abc --discovery-token-ca-cert-hash sha256:123",
+			want: "kubeadm join --token=*** --discovery-token-ca-cert-hash ***"},
+		{cmd: "kubeadm join --control-plane --certificate-key 'abc def'", want: "kubeadm join --control-plane --certificate-key ***"},
+		{cmd: "login --password \"p w\" --user root", want: "login --password *** --user root"},
+	}
+	for _, tt := range tests {
+		if got := Redact(tt.cmd); got != tt.want {
+			t.Errorf("Redact(%q) = %q, want %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	b := &tailBuffer{}
+	for i := 0; i < 10; i++ {
+		b.Write([]byte(strings.Repeat("x", MaxAuditStderr/2)))
+	}
+	b.Write([]byte("end"))
+	if s := b.String(); len(s) > 2*MaxAuditStderr || !strings.HasSuffix(s, "end") || len(s) <= MaxAuditStderr {
+		t.Errorf("tail of %d bytes", len(s))
+	}
+}
@@ -0,0 +1,95 @@
+package ssh
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// authorizedKeysFile the authorized keys of the login user
+const authorizedKeysFile = "$HOME/.ssh/authorized_keys"
+
+// GenerateKey returns a new pem encoded rsa private key and its authorized key line.
+func GenerateKey(bits int, comment string) ([]byte, string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, "", fmt.Errorf("generate rsa key: %v", err)
+	}
+	pub, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, "", err
+	}
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return privateKey, authorizedKey(pub, comment), nil
+}
+
+// AuthorizedKey returns the authorized key line of the private key.
+func AuthorizedKey(privateKey, passPhrase []byte, comment string) (string, error) {
+	signer, err := MakePrivateKeySigner(privateKey, passPhrase)
+	if err != nil {
+		return "", err
+	}
+	return authorizedKey(signer.PublicKey(), comment), nil
+}
+
+// Fingerprint returns the SHA256 fingerprint of the authorized key line.
+func Fingerprint(key string) (string, error) {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key))
+	if err != nil {
+		return "", err
+	}
+	return ssh.FingerprintSHA256(pub), nil
+}
+
+func authorizedKey(pub ssh.PublicKey, comment string) string {
+	line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub)))
+	if comment != "" {
+		line += " " + comment
+	}
+	return line
+}
+
+// keyBlob returns the "<type> <base64>" of the authorized key line, which identifies the key whatever the options and comment.
+func keyBlob(key string) (string, error) {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key))
+	if err != nil {
+		return "", err
+	}
+	return authorizedKey(pub, ""), nil
+}
+
+// AddAuthorizedKey appends the key to the authorized keys of the login user if it's not there.
+func AddAuthorizedKey(s Interface, key string) error {
+	blob, err := keyBlob(key)
+	if err != nil {
+		return err
+	}
+	cmd := fmt.Sprintf(`mkdir -p $HOME/.ssh && chmod 700 $HOME/.ssh && touch %[1]s && chmod 600 %[1]s && `+
+		`(grep -qF %[2]q %[1]s || echo %[3]q >> %[1]s)`, authorizedKeysFile, blob, key)
+	_, err = s.CombinedOutput(cmd)
+	if err != nil {
+		return fmt.Errorf("add authorized key: %v", err)
+	}
+	return nil
+}
+
+// RemoveAuthorizedKey removes the key from the authorized keys of the login user,
+// the file is replaced only if the key is there.
+func RemoveAuthorizedKey(s Interface, key string) error {
+	blob, err := keyBlob(key)
+	if err != nil {
+		return err
+	}
+	cmd := fmt.Sprintf(`[ ! -f %[1]s ] || ! grep -qF %[2]q %[1]s || `+
+		`(grep -vF %[2]q %[1]s > %[1]s.rotate; chmod 600 %[1]s.rotate && mv -f %[1]s.rotate %[1]s)`, authorizedKeysFile, blob)
+	_, err = s.CombinedOutput(cmd)
+	if err != nil {
+		return fmt.Errorf("remove authorized key: %v", err)
+	}
+	return nil
+}
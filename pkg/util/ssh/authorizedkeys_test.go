@@ -0,0 +1,38 @@
+package ssh
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateKey(t *testing.T) {
+	privateKey, key, err := GenerateKey(1024, "kunkka-c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(key, "ssh-rsa ") || !strings.HasSuffix(key, " kunkka-c1") {
+		t.Errorf("authorized key: %q", key)
+	}
+
+	// the unencrypted keys are parsed without passphrase
+	parsed, err := AuthorizedKey(privateKey, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, err := keyBlob(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != blob {
+		t.Errorf("AuthorizedKey() = %q, want %q", parsed, blob)
+	}
+
+	f1, err := Fingerprint(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f2, _ := Fingerprint(parsed)
+	if f1 != f2 || !strings.HasPrefix(f1, "SHA256:") {
+		t.Errorf("fingerprints: %s, %s", f1, f2)
+	}
+}
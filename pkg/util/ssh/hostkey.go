@@ -0,0 +1,138 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"k8s.io/klog"
+)
+
+// HostKeyStore keeps the known host keys of the machines per cluster, the hosts are the normalized
+// addresses of known_hosts, e.g. 10.0.0.1 or [10.0.0.1]:2222.
+type HostKeyStore interface {
+	// Lookup returns the pinned key of the host, nil if the host is not known yet.
+	Lookup(cluster, host string) (ssh.PublicKey, error)
+	// Pin records the key of the host on the first contact, it fails with a HostKeyMismatchError
+	// if another key was pinned meanwhile.
+	Pin(cluster, host string, key ssh.PublicKey) error
+}
+
+var (
+	hostKeyStoreMu sync.RWMutex
+	hostKeyStore   HostKeyStore
+)
+
+// SetHostKeyStore sets the store the host keys are pinned in, nil trusts the hosts without a key in the spec.
+func SetHostKeyStore(store HostKeyStore) {
+	hostKeyStoreMu.Lock()
+	defer hostKeyStoreMu.Unlock()
+	hostKeyStore = store
+}
+
+func getHostKeyStore() HostKeyStore {
+	hostKeyStoreMu.RLock()
+	defer hostKeyStoreMu.RUnlock()
+	return hostKeyStore
+}
+
+// HostKeyMismatchError is the key presented by the host differs from the known one,
+// e.g. a man in the middle or a reinstalled machine.
+type HostKeyMismatchError struct {
+	Host string
+	Want ssh.PublicKey
+	Got  ssh.PublicKey
+}
+
+func (e *HostKeyMismatchError) Error() string {
+	return fmt.Sprintf("host key mismatch for %s: want %s %s, got %s %s", e.Host,
+		e.Want.Type(), ssh.FingerprintSHA256(e.Want), e.Got.Type(), ssh.FingerprintSHA256(e.Got))
+}
+
+// IsHostKeyMismatch returns whether the error is a HostKeyMismatchError or wraps one by pkg/errors or %w.
+func IsHostKeyMismatch(err error) bool {
+	for err != nil {
+		switch e := err.(type) {
+		case *HostKeyMismatchError:
+			return true
+		case interface{ Cause() error }:
+			err = e.Cause()
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// ParseHostKey parses the key in the authorized_keys format, e.g. the content of /etc/ssh/ssh_host_ed25519_key.pub.
+func ParseHostKey(key string) (ssh.PublicKey, error) {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("invalid host key: %v", err)
+	}
+	return pub, nil
+}
+
+// hostKeyChecker verifies the keys of the hosts of a connection, i.e. the bastions and the target,
+// by the keys of the spec, or else the ones of the store, the unknown keys are pinned on the first contact.
+type hostKeyChecker struct {
+	cluster string
+	keys    map[string]ssh.PublicKey
+
+	mu       sync.Mutex
+	mismatch error
+}
+
+func (c *hostKeyChecker) check(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	host := knownhosts.Normalize(hostname)
+	want := c.keys[host]
+	if want == nil {
+		store := getHostKeyStore()
+		if store == nil {
+			return nil
+		}
+		if c.cluster == "" {
+			klog.Warningf("host: %s is not verified without cluster", host)
+			return nil
+		}
+
+		var err error
+		want, err = store.Lookup(c.cluster, host)
+		if err != nil {
+			return fmt.Errorf("lookup host key of %s: %v", host, err)
+		}
+		if want == nil {
+			err = store.Pin(c.cluster, host, key)
+			if IsHostKeyMismatch(err) {
+				c.setMismatch(err)
+			}
+			return err
+		}
+	}
+
+	if !bytes.Equal(want.Marshal(), key.Marshal()) {
+		err := &HostKeyMismatchError{Host: host, Want: want, Got: key}
+		c.setMismatch(err)
+		return err
+	}
+	return nil
+}
+
+func (c *hostKeyChecker) setMismatch(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mismatch = err
+}
+
+// mismatchError returns the mismatch found, the handshake of x/crypto/ssh doesn't keep
+// the type of the error of the callback.
+func (c *hostKeyChecker) mismatchError() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.mismatch
+}
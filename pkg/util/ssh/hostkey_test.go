@@ -0,0 +1,123 @@
+package ssh
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+type testHostKeyStore struct {
+	mu   sync.Mutex
+	keys map[string]ssh.PublicKey
+}
+
+func (s *testHostKeyStore) Lookup(cluster, host string) (ssh.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys[cluster+"/"+host], nil
+}
+
+func (s *testHostKeyStore) Pin(cluster, host string, key ssh.PublicKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if want := s.keys[cluster+"/"+host]; want != nil && !bytes.Equal(want.Marshal(), key.Marshal()) {
+		return &HostKeyMismatchError{Host: host, Want: want, Got: key}
+	}
+	s.keys[cluster+"/"+host] = key
+	return nil
+}
+
+func TestHostKeyVerification(t *testing.T) {
+	target := newTestServer(t, "target", "t")
+	jump := newTestServer(t, "jump", "j")
+	other := newTestServer(t, "other", "o")
+
+	store := &testHostKeyStore{keys: map[string]ssh.PublicKey{}}
+	SetHostKeyStore(store)
+	defer SetHostKeyStore(nil)
+
+	host := func(s *testServer) string {
+		return knownhosts.Normalize(s.listener.Addr().String())
+	}
+	exec := func(c *Config) error {
+		s, err := New(c)
+		if err != nil {
+			return err
+		}
+		_, _, _, err = s.Exec("hostname")
+		return err
+	}
+
+	// pinned on the first contact, the target and the bastion
+	c := target.config("t")
+	c.Cluster = "c1"
+	c.Bastions = []*Config{jump.config("j")}
+	if err := exec(c); err != nil {
+		t.Fatal(err)
+	}
+	if k := store.keys["c1/"+host(target)]; k == nil || !bytes.Equal(k.Marshal(), target.hostKey.Marshal()) {
+		t.Errorf("target host key not pinned: %v", store.keys)
+	}
+	if store.keys["c1/"+host(jump)] == nil {
+		t.Errorf("bastion host key not pinned: %v", store.keys)
+	}
+	if err := exec(c); err != nil {
+		t.Errorf("Exec() with the pinned keys: %v", err)
+	}
+
+	// the host presents another key, e.g. a man in the middle
+	store.keys["c1/"+host(target)] = other.hostKey
+	err := exec(c)
+	if !IsHostKeyMismatch(err) || IsTransient(err) {
+		t.Errorf("Exec() with mismatched pinned key = %v, want mismatch", err)
+	}
+
+	// the key of the spec is verified instead of the pinned one
+	c.HostKey = authorizedKey(target.hostKey, "")
+	if err := exec(c); err != nil {
+		t.Errorf("Exec() with the spec key: %v", err)
+	}
+	c.HostKey = authorizedKey(other.hostKey, "")
+	if err := exec(c); !IsHostKeyMismatch(err) {
+		t.Errorf("Exec() with mismatched spec key = %v, want mismatch", err)
+	}
+	c.HostKey = "invalid"
+	if _, err := New(c); err == nil {
+		t.Error("New() with invalid host key succeeded")
+	}
+
+	// the bastion key of the spec
+	c = target.config("t")
+	c.Cluster = "c2"
+	b := jump.config("j")
+	b.HostKey = authorizedKey(other.hostKey, "")
+	c.Bastions = []*Config{b}
+	if err := exec(c); !IsHostKeyMismatch(err) {
+		t.Errorf("Exec() with mismatched bastion key = %v, want mismatch", err)
+	}
+
+	// not verified without the cluster
+	c = target.config("t")
+	if err := exec(c); err != nil {
+		t.Errorf("Exec() without cluster: %v", err)
+	}
+	if _, ok := store.keys["/"+host(target)]; ok {
+		t.Error("host key pinned without cluster")
+	}
+}
+
+func TestHostKeyCheckerNormalize(t *testing.T) {
+	target := newTestServer(t, "target", "t")
+	addr := target.listener.Addr().(*net.TCPAddr)
+	checker := &hostKeyChecker{keys: map[string]ssh.PublicKey{knownhosts.Normalize(addr.String()): target.hostKey}}
+	if err := checker.check(addr.String(), addr, target.hostKey); err != nil {
+		t.Errorf("check() = %v", err)
+	}
+	if err := checker.check(addr.String(), addr, newTestServer(t, "other", "o").hostKey); err == nil || checker.mismatchError() == nil {
+		t.Errorf("check() = %v, want mismatch", err)
+	}
+}
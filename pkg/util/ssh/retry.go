@@ -0,0 +1,88 @@
+package ssh
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// TransientError is a failure of the connection to the host, e.g. the host is unreachable or the
+// connection is reset, which may succeed when retried, unlike the authentication and command failures.
+type TransientError struct {
+	Err error
+}
+
+func (e *TransientError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransientError) Unwrap() error {
+	return e.Err
+}
+
+// IsTransient returns whether the error is a TransientError or wraps one by pkg/errors or %w,
+// the aggregated errors are transient when all of them are.
+func IsTransient(err error) bool {
+	for err != nil {
+		switch e := err.(type) {
+		case *TransientError:
+			return true
+		case interface{ Errors() []error }:
+			errs := e.Errors()
+			for _, one := range errs {
+				if !IsTransient(one) {
+					return false
+				}
+			}
+			return len(errs) > 0
+		case interface{ Cause() error }:
+			err = e.Cause()
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// transient marks the error as transient unless the host rejected the credential.
+func transient(err error) error {
+	if err == nil || isAuthError(err) {
+		return err
+	}
+	return &TransientError{Err: err}
+}
+
+func isAuthError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "unable to authenticate") || strings.Contains(msg, "no supported methods remain")
+}
+
+// dial connects to the host, the failures other than authentication and host key mismatch are retried Retry times.
+func (s *SSH) dial() (*ssh.Client, error) {
+	checker := &hostKeyChecker{cluster: s.cluster, keys: s.hostKeys}
+	config := &ssh.ClientConfig{
+		User:            s.User,
+		Auth:            s.authMethods,
+		HostKeyCallback: checker.check,
+	}
+	client, err := s.dialer.Dial("tcp", s.addr, config)
+	if err != nil && s.Retry > 0 && !isAuthError(err) && checker.mismatchError() == nil {
+		backoff := wait.Backoff{Duration: 5 * time.Second, Factor: 1, Jitter: 0.1, Steps: s.Retry}
+		_ = wait.ExponentialBackoff(backoff, func() (bool, error) {
+			client, err = s.dialer.Dial("tcp", s.addr, config)
+			return err == nil || isAuthError(err) || checker.mismatchError() != nil, nil
+		})
+	}
+	if mismatch := checker.mismatchError(); err != nil && mismatch != nil {
+		return nil, fmt.Errorf("error getting SSH client to %s@%s: %w", s.User, s.addr, mismatch)
+	}
+	if err != nil {
+		return nil, transient(fmt.Errorf("error getting SSH client to %s@%s: '%v'", s.User, s.addr, err))
+	}
+	return client, nil
+}
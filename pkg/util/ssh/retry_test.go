@@ -0,0 +1,57 @@
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/pkg/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+func TestIsTransient(t *testing.T) {
+	target := newTestServer(t, "target", "t")
+
+	// a closed port
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := l.Addr().(*net.TCPAddr)
+	l.Close()
+
+	unreachable, err := New(&Config{User: "root", Host: closed.IP.String(), Port: closed.Port, Password: "t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, _, unreachableErr := unreachable.Exec("hostname")
+
+	denied, err := New(target.config("wrong"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, _, deniedErr := denied.Exec("hostname")
+
+	transientErr := &TransientError{Err: errors.New("connection reset by peer")}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "unreachable", err: unreachableErr, want: true},
+		{name: "authentication failed", err: deniedErr, want: false},
+		{name: "wrapped", err: errors.Wrap(transientErr, "10.0.0.1"), want: true},
+		{name: "wrapped by %w", err: fmt.Errorf("read file: %w", transientErr), want: true},
+		{name: "command failed", err: errors.New("exit error 1: no such file"), want: false},
+		{name: "all aggregated transient", err: utilerrors.NewAggregate([]error{transientErr, errors.Wrap(transientErr, "10.0.0.2")}), want: true},
+		{name: "aggregated with failure", err: utilerrors.NewAggregate([]error{transientErr, errors.New("failed")}), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransient(tt.err); got != tt.want {
+				t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
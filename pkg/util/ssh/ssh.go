@@ -2,6 +2,7 @@ package ssh
 
 import (
 	"bytes"
+	"context"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
@@ -12,12 +13,17 @@ import (
 	"net"
 	"os"
 	"path"
+	"sync"
 	"time"
 
+	"github.com/gostship/kunkka/pkg/metrics"
+	"github.com/gostship/kunkka/pkg/tracing"
 	"github.com/gostship/kunkka/pkg/util/hash"
 	"github.com/pkg/sftp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/crypto/ssh"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"golang.org/x/crypto/ssh/knownhosts"
 	"k8s.io/klog"
 )
 
@@ -29,6 +35,11 @@ type SSH struct {
 	authMethods []ssh.AuthMethod
 	dialer      sshDialer
 	Retry       int
+	cluster     string
+	phase       string
+	traceParent string
+	// hostKeys are the keys of the spec by the normalized addresses of the hosts
+	hostKeys map[string]ssh.PublicKey
 }
 
 type Config struct {
@@ -42,6 +53,17 @@ type Config struct {
 	// seconds). This timeout is only intended to catch otherwise uncaught hangs.
 	DialTimeOut time.Duration
 	Retry       int
+	// Bastions are the jump hosts to reach the host through, the first one is dialed directly,
+	// like the ProxyJump of ssh. The port of a bastion defaults to 22.
+	Bastions []*Config
+	// Cluster and Phase label the commands in the audit records, the host keys are pinned per Cluster.
+	Cluster string
+	Phase   string
+	// TraceParent is the w3c traceparent of the span of the phase, the commands are traced as its children.
+	TraceParent string
+	// HostKey is the public key of the host in the authorized_keys format, it's verified instead of
+	// the key pinned in the HostKeyStore.
+	HostKey string
 }
 
 type Interface interface {
@@ -62,25 +84,53 @@ type Interface interface {
 }
 
 func New(c *Config) (*SSH, error) {
-	if c.Password == "" && c.PrivateKey == nil {
-		return nil, errors.New("password or privateKey at least one")
+	authMethods, err := makeAuthMethods(c)
+	if err != nil {
+		return nil, err
 	}
+	addr := fmt.Sprintf("%s:%d", c.Host, c.Port)
 
-	authMethods := make([]ssh.AuthMethod, 0)
-	if c.Password != "" {
-		authMethods = append(authMethods, ssh.Password(c.Password))
+	if c.DialTimeOut == 0 {
+		c.DialTimeOut = 5 * time.Second
 	}
-	if len(c.PrivateKey) != 0 {
-		signer, err := MakePrivateKeySigner(c.PrivateKey, c.PassPhrase)
+
+	hostKeys := map[string]ssh.PublicKey{}
+	if c.HostKey != "" {
+		key, err := ParseHostKey(c.HostKey)
 		if err != nil {
 			return nil, err
 		}
-		authMethods = append(authMethods, ssh.PublicKeys(signer))
+		hostKeys[knownhosts.Normalize(addr)] = key
 	}
-	addr := fmt.Sprintf("%s:%d", c.Host, c.Port)
 
-	if c.DialTimeOut == 0 {
-		c.DialTimeOut = 5 * time.Second
+	var dialer sshDialer = &realSSHDialer{}
+	if len(c.Bastions) != 0 {
+		jump := &jumpDialer{}
+		for _, b := range c.Bastions {
+			methods, err := makeAuthMethods(b)
+			if err != nil {
+				return nil, fmt.Errorf("bastion %s: %v", b.Host, err)
+			}
+			port := b.Port
+			if port == 0 {
+				port = 22
+			}
+			if b.HostKey != "" {
+				key, err := ParseHostKey(b.HostKey)
+				if err != nil {
+					return nil, fmt.Errorf("bastion %s: %v", b.Host, err)
+				}
+				hostKeys[knownhosts.Normalize(fmt.Sprintf("%s:%d", b.Host, port))] = key
+			}
+			jump.hops = append(jump.hops, &jumpHop{
+				addr: fmt.Sprintf("%s:%d", b.Host, port),
+				config: &ssh.ClientConfig{
+					User: b.User,
+					Auth: methods,
+				},
+			})
+		}
+		dialer = jump
 	}
 
 	return &SSH{
@@ -89,11 +139,34 @@ func New(c *Config) (*SSH, error) {
 		Port:        c.Port,
 		addr:        addr,
 		authMethods: authMethods,
-		dialer:      &timeoutDialer{&realSSHDialer{}, c.DialTimeOut},
+		dialer:      &timeoutDialer{dialer, c.DialTimeOut},
 		Retry:       c.Retry,
+		cluster:     c.Cluster,
+		phase:       c.Phase,
+		traceParent: c.TraceParent,
+		hostKeys:    hostKeys,
 	}, nil
 }
 
+func makeAuthMethods(c *Config) ([]ssh.AuthMethod, error) {
+	if c.Password == "" && c.PrivateKey == nil {
+		return nil, errors.New("password or privateKey at least one")
+	}
+
+	authMethods := make([]ssh.AuthMethod, 0)
+	if c.Password != "" {
+		authMethods = append(authMethods, ssh.Password(c.Password))
+	}
+	if len(c.PrivateKey) != 0 {
+		signer, err := MakePrivateKeySigner(c.PrivateKey, c.PassPhrase)
+		if err != nil {
+			return nil, err
+		}
+		authMethods = append(authMethods, ssh.PublicKeys(signer))
+	}
+	return authMethods, nil
+}
+
 func (s *SSH) Ping() error {
 	_, _, _, err := s.Exec("pwd")
 
@@ -115,34 +188,38 @@ func (s *SSH) CombinedOutput(cmd string) ([]byte, error) {
 	return []byte(stdout), nil
 }
 
+// span starts the span of the operation on the host as a child of the span of the phase, it's a no-op out of
+// the phases.
+func (s *SSH) span(operation string, attrs ...attribute.KeyValue) trace.Span {
+	attrs = append(attrs, tracing.HostKey.String(s.Host))
+	_, span := tracing.StartChild(tracing.WithTraceParent(context.Background(), s.traceParent), "ssh "+operation, attrs...)
+	return span
+}
+
 func (s *SSH) Execf(format string, a ...interface{}) (stdout string, stderr string, exit int, err error) {
 	return s.Exec(fmt.Sprintf(format, a...))
 }
 
 func (s *SSH) Exec(cmd string) (stdout string, stderr string, exit int, err error) {
-	// Setup the config, dial the server, and open a session.
-	config := &ssh.ClientConfig{
-		User:            s.User,
-		Auth:            s.authMethods,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-	client, err := s.dialer.Dial("tcp", s.addr, config)
-	if err != nil && s.Retry > 0 {
-		err = wait.Poll(5*time.Second, time.Duration(s.Retry)*5*time.Second, func() (bool, error) {
-			if client, err = s.dialer.Dial("tcp", s.addr, config); err != nil {
-				return false, err
-			}
-			return true, nil
-		})
-	}
+	start := time.Now()
+	span := s.span("exec", tracing.CommandKey.String(auditCommand(cmd)))
+	defer func() {
+		s.audit(cmd, start, exit, stderr, err)
+		metrics.ObserveSSH("exec", start, err)
+		span.SetAttributes(tracing.ExitCodeKey.Int(exit))
+		tracing.End(span, err)
+	}()
+
+	// Dial the server, and open a session.
+	client, err := s.dial()
 	if err != nil {
-		return "", "", 0, fmt.Errorf("error getting SSH client to %s@%s: '%v'", s.User, s.addr, err)
+		return "", "", 0, err
 	}
 	defer client.Close()
 
 	session, err := client.NewSession()
 	if err != nil {
-		return "", "", 0, fmt.Errorf("error creating session to %s@%s: '%v'", s.User, s.addr, err)
+		return "", "", 0, transient(fmt.Errorf("error creating session to %s@%s: '%v'", s.User, s.addr, err))
 	}
 	defer session.Close()
 
@@ -162,36 +239,38 @@ func (s *SSH) Exec(cmd string) (stdout string, stderr string, exit int, err erro
 		} else {
 			// Some other kind of error happened (e.g. an IOError); consider the
 			// SSH unsuccessful.
-			err = fmt.Errorf("failed running `%s` on %s@%s: '%v'", cmd, s.User, s.addr, err)
+			err = transient(fmt.Errorf("failed running `%s` on %s@%s: '%v'", cmd, s.User, s.addr, err))
 		}
 	}
 	return bout.String(), berr.String(), code, err
 }
 
 func (s *SSH) ExecStream(cmd string, stdout, stderr io.Writer) (exit int, err error) {
-	// Setup the config, dial the server, and open a session.
-	config := &ssh.ClientConfig{
-		User:            s.User,
-		Auth:            s.authMethods,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-	client, err := s.dialer.Dial("tcp", s.addr, config)
-	if err != nil && s.Retry > 0 {
-		err = wait.Poll(5*time.Second, time.Duration(s.Retry)*5*time.Second, func() (bool, error) {
-			if client, err = s.dialer.Dial("tcp", s.addr, config); err != nil {
-				return false, err
-			}
-			return true, nil
-		})
-	}
+	start := time.Now()
+	tail := &tailBuffer{}
+	if stderr != nil {
+		stderr = io.MultiWriter(stderr, tail)
+	} else {
+		stderr = tail
+	}
+	span := s.span("exec", tracing.CommandKey.String(auditCommand(cmd)))
+	defer func() {
+		s.audit(cmd, start, exit, tail.String(), err)
+		metrics.ObserveSSH("exec", start, err)
+		span.SetAttributes(tracing.ExitCodeKey.Int(exit))
+		tracing.End(span, err)
+	}()
+
+	// Dial the server, and open a session.
+	client, err := s.dial()
 	if err != nil {
-		return 0, fmt.Errorf("error getting SSH client to %s@%s: '%v'", s.User, s.addr, err)
+		return 0, err
 	}
 	defer client.Close()
 
 	session, err := client.NewSession()
 	if err != nil {
-		return 0, fmt.Errorf("error creating session to %s@%s: '%v'", s.User, s.addr, err)
+		return 0, transient(fmt.Errorf("error creating session to %s@%s: '%v'", s.User, s.addr, err))
 	}
 	defer session.Close()
 
@@ -210,13 +289,20 @@ func (s *SSH) ExecStream(cmd string, stdout, stderr io.Writer) (exit int, err er
 		} else {
 			// Some other kind of error happened (e.g. an IOError); consider the
 			// SSH unsuccessful.
-			err = fmt.Errorf("failed running `%s` on %s@%s: '%v'", cmd, s.User, s.addr, err)
+			err = transient(fmt.Errorf("failed running `%s` on %s@%s: '%v'", cmd, s.User, s.addr, err))
 		}
 	}
 	return code, err
 }
 
-func (s *SSH) CopyFile(src, dst string) error {
+func (s *SSH) CopyFile(src, dst string) (err error) {
+	start := time.Now()
+	span := s.span("copy", attribute.String("kunkka.ssh.dst", dst))
+	defer func() {
+		metrics.ObserveSSH("copy", start, err)
+		tracing.End(span, err)
+	}()
+
 	srcHash, err := hash.Sha256WithFile(src)
 	if err != nil {
 		return err
@@ -232,20 +318,7 @@ func (s *SSH) CopyFile(src, dst string) error {
 	}
 	klog.Infof("[%s] copy `%s` to %q", s.addr, src, dst)
 
-	config := &ssh.ClientConfig{
-		User:            s.User,
-		Auth:            s.authMethods,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-	client, err := s.dialer.Dial("tcp", s.addr, config)
-	if err != nil {
-		err = wait.Poll(5*time.Second, time.Duration(s.Retry)*5*time.Second, func() (bool, error) {
-			if client, err = s.dialer.Dial("tcp", s.addr, config); err != nil {
-				return false, err
-			}
-			return true, nil
-		})
-	}
+	client, err := s.dial()
 	if err != nil {
 		return err
 	}
@@ -253,7 +326,7 @@ func (s *SSH) CopyFile(src, dst string) error {
 
 	sftpClient, err := sftp.NewClient(client)
 	if err != nil {
-		return err
+		return transient(err)
 	}
 	defer sftpClient.Close()
 
@@ -275,23 +348,17 @@ func (s *SSH) CopyFile(src, dst string) error {
 	return err
 }
 
-func (s *SSH) WriteFile(src io.Reader, dst string) error {
+func (s *SSH) WriteFile(src io.Reader, dst string) (err error) {
+	start := time.Now()
+	span := s.span("write", attribute.String("kunkka.ssh.dst", dst))
+	defer func() {
+		metrics.ObserveSSH("write", start, err)
+		tracing.End(span, err)
+	}()
+
 	klog.Infof("[%s] Write data to %q", s.addr, dst)
 
-	config := &ssh.ClientConfig{
-		User:            s.User,
-		Auth:            s.authMethods,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-	client, err := s.dialer.Dial("tcp", s.addr, config)
-	if err != nil {
-		err = wait.Poll(5*time.Second, time.Duration(s.Retry)*5*time.Second, func() (bool, error) {
-			if client, err = s.dialer.Dial("tcp", s.addr, config); err != nil {
-				return false, err
-			}
-			return true, nil
-		})
-	}
+	client, err := s.dial()
 	if err != nil {
 		return err
 	}
@@ -299,7 +366,7 @@ func (s *SSH) WriteFile(src io.Reader, dst string) error {
 
 	sftpClient, err := sftp.NewClient(client)
 	if err != nil {
-		return err
+		return transient(err)
 	}
 	defer sftpClient.Close()
 
@@ -317,21 +384,13 @@ func (s *SSH) WriteFile(src io.Reader, dst string) error {
 	return err
 }
 
-func (s *SSH) Stat(p string) (os.FileInfo, error) {
-	config := &ssh.ClientConfig{
-		User:            s.User,
-		Auth:            s.authMethods,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-	client, err := s.dialer.Dial("tcp", s.addr, config)
-	if err != nil {
-		err = wait.Poll(5*time.Second, time.Duration(s.Retry)*5*time.Second, func() (bool, error) {
-			if client, err = s.dialer.Dial("tcp", s.addr, config); err != nil {
-				return false, err
-			}
-			return true, nil
-		})
-	}
+func (s *SSH) Stat(p string) (fi os.FileInfo, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveSSH("stat", start, err)
+	}()
+
+	client, err := s.dial()
 	if err != nil {
 		return nil, err
 	}
@@ -339,7 +398,7 @@ func (s *SSH) Stat(p string) (os.FileInfo, error) {
 
 	sftpClient, err := sftp.NewClient(client)
 	if err != nil {
-		return nil, err
+		return nil, transient(err)
 	}
 	defer sftpClient.Close()
 
@@ -355,21 +414,13 @@ func (s *SSH) Exist(filename string) (bool, error) {
 	return exit == 0, nil
 }
 
-func (s *SSH) ReadFile(filename string) ([]byte, error) {
-	config := &ssh.ClientConfig{
-		User:            s.User,
-		Auth:            s.authMethods,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-	client, err := s.dialer.Dial("tcp", s.addr, config)
-	if err != nil {
-		err = wait.Poll(5*time.Second, time.Duration(s.Retry)*5*time.Second, func() (bool, error) {
-			if client, err = s.dialer.Dial("tcp", s.addr, config); err != nil {
-				return false, fmt.Errorf("read file %s error: %w", filename, err)
-			}
-			return true, nil
-		})
-	}
+func (s *SSH) ReadFile(filename string) (_ []byte, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveSSH("read", start, err)
+	}()
+
+	client, err := s.dial()
 	if err != nil {
 		return nil, fmt.Errorf("read file %s error: %w", filename, err)
 	}
@@ -377,7 +428,7 @@ func (s *SSH) ReadFile(filename string) ([]byte, error) {
 
 	sftpClient, err := sftp.NewClient(client)
 	if err != nil {
-		return nil, fmt.Errorf("read file %s error: %w", filename, err)
+		return nil, fmt.Errorf("read file %s error: %w", filename, transient(err))
 	}
 	defer sftpClient.Close()
 
@@ -437,6 +488,107 @@ func (d *timeoutDialer) Dial(network, addr string, config *ssh.ClientConfig) (*s
 	return d.dialer.Dial(network, addr, config)
 }
 
+type jumpHop struct {
+	addr   string
+	config *ssh.ClientConfig
+}
+
+// jumpDialer dials the host through the chain of the bastions, each hop is tunneled
+// through the ssh connection of the previous one.
+type jumpDialer struct {
+	hops []*jumpHop
+}
+
+var _ sshDialer = &jumpDialer{}
+
+func (d *jumpDialer) Dial(network, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	// the bastions are verified with the target
+	first := *d.hops[0].config
+	first.Timeout = config.Timeout
+	first.HostKeyCallback = config.HostKeyCallback
+	client, err := (&realSSHDialer{}).Dial(network, d.hops[0].addr, &first)
+	if err != nil {
+		return nil, fmt.Errorf("dial bastion %s: %v", d.hops[0].addr, err)
+	}
+
+	bastions := []*ssh.Client{client}
+	closeBastions := func() {
+		for i := len(bastions) - 1; i >= 0; i-- {
+			bastions[i].Close()
+		}
+	}
+	next := make([]*jumpHop, 0, len(d.hops))
+	for _, h := range d.hops[1:] {
+		c := *h.config
+		c.HostKeyCallback = config.HostKeyCallback
+		next = append(next, &jumpHop{addr: h.addr, config: &c})
+	}
+	next = append(next, &jumpHop{addr: addr, config: config})
+	for i, h := range next {
+		conn, err := client.Dial(network, h.addr)
+		if err != nil {
+			closeBastions()
+			return nil, fmt.Errorf("dial %s through bastion %s: %v", h.addr, client.RemoteAddr(), err)
+		}
+		// the target connection closes the bastions it is tunneled through
+		if i == len(next)-1 {
+			conn = &jumpConn{Conn: conn, bastions: bastions}
+		}
+		c, chans, reqs, err := handshake(conn, h.addr, h.config)
+		if err != nil {
+			conn.Close()
+			closeBastions()
+			return nil, err
+		}
+		client = ssh.NewClient(c, chans, reqs)
+		if i < len(next)-1 {
+			bastions = append(bastions, client)
+		}
+	}
+	return client, nil
+}
+
+// handshake runs the ssh handshake over the tunneled conn, which has no deadline support,
+// so the conn is closed to abort a hanging handshake.
+func handshake(conn net.Conn, addr string, config *ssh.ClientConfig) (ssh.Conn, <-chan ssh.NewChannel, <-chan *ssh.Request, error) {
+	type result struct {
+		c     ssh.Conn
+		chans <-chan ssh.NewChannel
+		reqs  <-chan *ssh.Request
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+		done <- result{c, chans, reqs, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.c, r.chans, r.reqs, r.err
+	case <-time.After(30 * time.Second):
+		conn.Close()
+		return nil, nil, nil, fmt.Errorf("ssh handshake with %s timed out", addr)
+	}
+}
+
+// jumpConn closes the bastion clients after the tunneled conn.
+type jumpConn struct {
+	net.Conn
+	bastions []*ssh.Client
+	once     sync.Once
+}
+
+func (c *jumpConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		for i := len(c.bastions) - 1; i >= 0; i-- {
+			c.bastions[i].Close()
+		}
+	})
+	return err
+}
+
 func MakePrivateKeySignerFromFile(key string) (ssh.Signer, error) {
 	// Create an actual signer.
 	buffer, err := ioutil.ReadFile(key)
@@ -447,7 +599,14 @@ func MakePrivateKeySignerFromFile(key string) (ssh.Signer, error) {
 }
 
 func MakePrivateKeySigner(privateKey []byte, passPhrase []byte) (ssh.Signer, error) {
-	signer, err := ssh.ParsePrivateKeyWithPassphrase(privateKey, passPhrase)
+	parse := ssh.ParsePrivateKey
+	if len(passPhrase) != 0 {
+		// the passphrase parser rejects the unencrypted pem keys
+		parse = func(key []byte) (ssh.Signer, error) {
+			return ssh.ParsePrivateKeyWithPassphrase(key, passPhrase)
+		}
+	}
+	signer, err := parse(privateKey)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing SSH key: '%v'", err)
 	}
@@ -0,0 +1,210 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer is a minimal sshd serving the exec sessions and the direct-tcpip forwarding.
+type testServer struct {
+	name     string
+	listener net.Listener
+	hostKey  ssh.PublicKey
+	active   int32
+
+	mu        sync.Mutex
+	forwarded []string
+}
+
+func newTestServer(t *testing.T, name, password string) *testServer {
+	priv, _, err := GenerateKey(1024, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := MakePrivateKeySigner(priv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == "root" && string(pass) == password {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %s", c.User())
+		},
+	}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testServer{name: name, listener: l, hostKey: signer.PublicKey()}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, config)
+		}
+	}()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *testServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	sc, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	atomic.AddInt32(&s.active, 1)
+	defer atomic.AddInt32(&s.active, -1)
+	defer sc.Close()
+	go ssh.DiscardRequests(reqs)
+
+	for nc := range chans {
+		switch nc.ChannelType() {
+		case "session":
+			go s.session(nc)
+		case "direct-tcpip":
+			go s.forward(nc)
+		default:
+			nc.Reject(ssh.UnknownChannelType, nc.ChannelType())
+		}
+	}
+}
+
+func (s *testServer) session(nc ssh.NewChannel) {
+	ch, reqs, err := nc.Accept()
+	if err != nil {
+		return
+	}
+	defer ch.Close()
+	for req := range reqs {
+		if req.Type != "exec" {
+			req.Reply(false, nil)
+			continue
+		}
+		req.Reply(true, nil)
+		io.WriteString(ch, s.name)
+		ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+		return
+	}
+}
+
+func (s *testServer) forward(nc ssh.NewChannel) {
+	var target struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(nc.ExtraData(), &target); err != nil {
+		nc.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	addr := net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port)))
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		nc.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	s.mu.Lock()
+	s.forwarded = append(s.forwarded, addr)
+	s.mu.Unlock()
+
+	ch, reqs, err := nc.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	go func() {
+		io.Copy(conn, ch)
+		conn.Close()
+	}()
+	io.Copy(ch, conn)
+	ch.Close()
+}
+
+func (s *testServer) config(password string) *Config {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return &Config{User: "root", Host: addr.IP.String(), Port: addr.Port, Password: password}
+}
+
+func TestExecThroughBastions(t *testing.T) {
+	target := newTestServer(t, "target", "t")
+	jump1 := newTestServer(t, "jump1", "j1")
+	jump2 := newTestServer(t, "jump2", "j2")
+
+	tests := []struct {
+		name     string
+		bastions []*Config
+		wantErr  bool
+	}{
+		{name: "direct"},
+		{name: "one bastion", bastions: []*Config{jump1.config("j1")}},
+		{name: "proxy jump chain", bastions: []*Config{jump1.config("j1"), jump2.config("j2")}},
+		{name: "bastion auth failed", bastions: []*Config{jump1.config("wrong")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := target.config("t")
+			c.Bastions = tt.bastions
+			s, err := New(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stdout, _, exit, err := s.Exec("hostname")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Exec() succeeded, want error")
+				}
+				return
+			}
+			if err != nil || exit != 0 || stdout != "target" {
+				t.Fatalf("Exec() = %q, %d, %v", stdout, exit, err)
+			}
+		})
+	}
+
+	// each hop forwards to the next one
+	want := map[*testServer]string{jump1: jump2.listener.Addr().String(), jump2: target.listener.Addr().String()}
+	for s, addr := range want {
+		s.mu.Lock()
+		forwarded := s.forwarded
+		s.mu.Unlock()
+		if len(forwarded) == 0 || forwarded[len(forwarded)-1] != addr {
+			t.Errorf("%s forwarded to %v, want %s", s.name, forwarded, addr)
+		}
+	}
+
+	// the bastion connections are closed with the target one
+	err := waitFor(func() bool {
+		return atomic.LoadInt32(&jump1.active) == 0 && atomic.LoadInt32(&jump2.active) == 0
+	})
+	if err != nil {
+		t.Errorf("bastion connections left open: %d, %d", atomic.LoadInt32(&jump1.active), atomic.LoadInt32(&jump2.active))
+	}
+}
+
+func waitFor(cond func() bool) error {
+	for i := 0; i < 50; i++ {
+		if cond() {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("timed out")
+}
@@ -3,6 +3,9 @@ package validation
 import (
 	"fmt"
 	"regexp"
+	"strings"
+
+	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
 )
 
 const dns1123NameMaxLength int = 32
@@ -70,3 +73,26 @@ func IsPhoneNumber(value string) error {
 
 	return nil
 }
+
+// SanitizeName trims and lower cases the value which ends up in an object name,
+// e.g. cluster name, and tests it conforms to a DNS (RFC 1123) label.
+func SanitizeName(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return "", fmt.Errorf("must be specified")
+	}
+	if errs := k8svalidation.IsDNS1123Label(value); len(errs) > 0 {
+		return "", fmt.Errorf("%q %s", value, strings.Join(errs, ", "))
+	}
+	return value, nil
+}
+
+// SanitizeLabelValue trims the value which ends up in a label value, e.g. rack tag,
+// and tests it is a valid label value.
+func SanitizeLabelValue(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if errs := k8svalidation.IsValidLabelValue(value); len(errs) > 0 {
+		return "", fmt.Errorf("%q %s", value, strings.Join(errs, ", "))
+	}
+	return value, nil
+}
@@ -11,12 +11,15 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/remotecommand"
 	"k8s.io/klog"
+	"sync"
 	"time"
 )
 
 const (
 	// Time allowed to write a message to the peer.
 	writeWait = 10 * time.Second
+	// Period of the pings keeping the idle sessions through the proxies.
+	pingPeriod = 30 * time.Second
 )
 
 // PtyHandler is what remotecommand expects from a pty
@@ -30,6 +33,19 @@ type PtyHandler interface {
 type TerminalSession struct {
 	conn     *websocket.Conn
 	sizeChan chan remotecommand.TerminalSize
+	done     chan struct{}
+	once     sync.Once
+	// the websocket supports one concurrent writer
+	writeMu sync.Mutex
+}
+
+// NewTerminalSession ...
+func NewTerminalSession(conn *websocket.Conn) *TerminalSession {
+	return &TerminalSession{
+		conn:     conn,
+		sizeChan: make(chan remotecommand.TerminalSize),
+		done:     make(chan struct{}),
+	}
 }
 
 // TerminalMessage is the messaging protocol between ShellController and TerminalSession.
@@ -46,17 +62,19 @@ type TerminalMessage struct {
 }
 
 // TerminalSize handles pty->process resize events
-// Called in a loop from remotecommand as long as the process is running
-func (t TerminalSession) Next() *remotecommand.TerminalSize {
+// Called in a loop from remotecommand as long as the process is running, nil once the session is closed
+func (t *TerminalSession) Next() *remotecommand.TerminalSize {
 	select {
 	case size := <-t.sizeChan:
 		return &size
+	case <-t.done:
+		return nil
 	}
 }
 
 // Read handles pty->process messages (stdin, resize)
 // Called in a loop from remotecommand as long as the process is running
-func (t TerminalSession) Read(p []byte) (int, error) {
+func (t *TerminalSession) Read(p []byte) (int, error) {
 
 	var msg TerminalMessage
 	err := t.conn.ReadJSON(&msg)
@@ -68,7 +86,10 @@ func (t TerminalSession) Read(p []byte) (int, error) {
 	case "stdin":
 		return copy(p, msg.Data), nil
 	case "resize":
-		t.sizeChan <- remotecommand.TerminalSize{Width: msg.Cols, Height: msg.Rows}
+		select {
+		case t.sizeChan <- remotecommand.TerminalSize{Width: msg.Cols, Height: msg.Rows}:
+		case <-t.done:
+		}
 		return 0, nil
 	default:
 		return 0, fmt.Errorf("unknown message type '%s'", msg.Op)
@@ -77,7 +98,7 @@ func (t TerminalSession) Read(p []byte) (int, error) {
 
 // Write handles process->pty stdout
 // Called from remotecommand whenever there is any output
-func (t TerminalSession) Write(p []byte) (int, error) {
+func (t *TerminalSession) Write(p []byte) (int, error) {
 	msg, err := json.Marshal(TerminalMessage{
 		Op:   "stdout",
 		Data: string(p),
@@ -85,8 +106,7 @@ func (t TerminalSession) Write(p []byte) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	t.conn.SetWriteDeadline(time.Now().Add(writeWait))
-	if err = t.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+	if err = t.writeMessage(msg); err != nil {
 		return 0, err
 	}
 	return len(p), nil
@@ -94,7 +114,7 @@ func (t TerminalSession) Write(p []byte) (int, error) {
 
 // Toast can be used to send the user any OOB messages
 // hterm puts these in the center of the terminal
-func (t TerminalSession) Toast(p string) error {
+func (t *TerminalSession) Toast(p string) error {
 	msg, err := json.Marshal(TerminalMessage{
 		Op:   "toast",
 		Data: p,
@@ -102,8 +122,7 @@ func (t TerminalSession) Toast(p string) error {
 	if err != nil {
 		return err
 	}
-	t.conn.SetWriteDeadline(time.Now().Add(writeWait))
-	if err = t.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+	if err = t.writeMessage(msg); err != nil {
 		return err
 	}
 	return nil
@@ -112,9 +131,39 @@ func (t TerminalSession) Toast(p string) error {
 // Close shuts down the SockJS connection and sends the status code and reason to the client
 // Can happen if the process exits or if there is an error starting up the process
 // For now the status code is unused and reason is shown to the user (unless "")
-func (t TerminalSession) Close(status uint32, reason string) {
-	klog.Warning(status, reason)
-	t.conn.Close()
+func (t *TerminalSession) Close(status uint32, reason string) {
+	t.once.Do(func() {
+		klog.V(2).Infof("close terminal session, status: %d, reason: %s", status, reason)
+		close(t.done)
+		if reason != "" {
+			_ = t.Toast(reason)
+		}
+		_ = t.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait))
+		t.conn.Close()
+	})
+}
+
+func (t *TerminalSession) writeMessage(msg []byte) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	t.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return t.conn.WriteMessage(websocket.TextMessage, msg)
+}
+
+// keepalive pings the peer until the session is closed.
+func (t *TerminalSession) keepalive() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := t.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+		case <-t.done:
+			return
+		}
+	}
 }
 
 type Interface interface {
@@ -180,7 +229,8 @@ func (t *terminaler) HandleSession(shell, namespace, podName, containerName stri
 	var err error
 	validShells := []string{"sh", "bash"}
 
-	session := &TerminalSession{conn: conn, sizeChan: make(chan remotecommand.TerminalSize)}
+	session := NewTerminalSession(conn)
+	go session.keepalive()
 
 	if isValidShell(validShells, shell) {
 		cmd := []string{shell}
@@ -0,0 +1,49 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gostship/kunkka/pkg/constants"
+	"github.com/gostship/kunkka/test/e2e/framework"
+	"github.com/gostship/kunkka/test/e2e/framework/fakemachine"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestBaremetalClusterSystemInit(t *testing.T) {
+	ctx := context.Background()
+	ns, err := f.CreateNamespace(ctx, "baremetal")
+	if err != nil {
+		t.Fatalf("create namespace err: %v", err)
+	}
+
+	m, err := f.NewMachine()
+	if err != nil {
+		t.Fatalf("new machine err: %v", err)
+	}
+	m.Handle(`^uname -r$`, fakemachine.Result{Stdout: "4.19.113-300.el7.x86_64\n"})
+
+	c := framework.NewBaremetalCluster(ns, "e2e-baremetal", m)
+	err = f.Client.Create(ctx, c)
+	if err != nil {
+		t.Fatalf("create cluster err: %v", err)
+	}
+	defer f.Client.Delete(ctx, c)
+
+	key := types.NamespacedName{Namespace: ns, Name: c.Name}
+	_, err = f.WaitForClusterCondition(ctx, key, "EnsureSystem")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	data, err := m.ReadFile(constants.SystemInitFile)
+	if err != nil || len(data) == 0 {
+		t.Errorf("init script not written, err: %v", err)
+	}
+	if !m.Executed(constants.SystemInitFile) {
+		t.Errorf("init script not executed, commands: %v", m.Commands())
+	}
+}
@@ -0,0 +1,32 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gostship/kunkka/test/e2e/framework"
+	"k8s.io/klog"
+)
+
+var f *framework.Framework
+
+func TestMain(m *testing.M) {
+	var err error
+	f, err = framework.New()
+	if err != nil {
+		klog.Errorf("setup e2e framework err: %+v", err)
+		if f != nil {
+			f.Teardown()
+		}
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	if err := f.Teardown(); err != nil {
+		klog.Errorf("teardown e2e framework err: %v", err)
+	}
+	os.Exit(code)
+}
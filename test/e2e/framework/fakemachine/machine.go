@@ -0,0 +1,260 @@
+package fakemachine
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"k8s.io/klog"
+)
+
+const (
+	Username = "root"
+	Password = "fake"
+)
+
+// Result is the scripted output of a command.
+type Result struct {
+	Stdout string
+	Stderr string
+	Exit   int
+}
+
+type handler struct {
+	pattern *regexp.Regexp
+	result  Result
+}
+
+// Machine is an in-process ssh server which plays a node for the provider phases,
+// every command is recorded and answered by the scripted handlers (exit 0 by default),
+// files are written to an in memory sftp filesystem.
+type Machine struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+	fs       sftp.Handlers
+
+	mu       sync.Mutex
+	handlers []handler
+	commands []string
+	wg       sync.WaitGroup
+}
+
+// New starts a fake machine listening on a random local port.
+func New() (*Machine, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, errors.Wrap(err, "generate host key")
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "new host key signer")
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, errors.Wrap(err, "listen")
+	}
+
+	m := &Machine{
+		listener: l,
+		config:   config,
+		fs:       sftp.InMemHandler(),
+	}
+	m.Handle(`^uname -r$`, Result{Stdout: "4.19.113-300.el7.x86_64\n"})
+	m.Handle(`^uname -m$`, Result{Stdout: "x86_64\n"})
+	m.Handle(`os-release`, Result{Stdout: "CentOS Linux 7 (Core)\n"})
+
+	m.wg.Add(1)
+	go m.serve()
+	return m, nil
+}
+
+// Handle scripts the result of the commands matching pattern,
+// later handlers take precedence over the earlier ones.
+func (m *Machine) Handle(pattern string, result Result) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.handlers = append([]handler{{pattern: regexp.MustCompile(pattern), result: result}}, m.handlers...)
+}
+
+// Commands returns the executed commands in order.
+func (m *Machine) Commands() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cmds := make([]string, len(m.commands))
+	copy(cmds, m.commands)
+	return cmds
+}
+
+// Executed reports whether a command containing substr has been executed.
+func (m *Machine) Executed(substr string) bool {
+	for _, cmd := range m.Commands() {
+		if strings.Contains(cmd, substr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ReadFile returns the content of a file written to the machine.
+func (m *Machine) ReadFile(path string) ([]byte, error) {
+	r, err := m.fs.FileGet.Fileread(sftp.NewRequest("Get", path))
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(io.NewSectionReader(r, 0, 1<<30))
+}
+
+// Exist reports whether the path exists on the machine.
+func (m *Machine) Exist(path string) bool {
+	_, err := m.fs.FileList.Filelist(sftp.NewRequest("Stat", path))
+	return err == nil
+}
+
+// Port returns the listening port of the ssh server.
+func (m *Machine) Port() int32 {
+	return int32(m.listener.Addr().(*net.TCPAddr).Port)
+}
+
+// ClusterMachine returns a cluster machine which connects to the fake machine.
+func (m *Machine) ClusterMachine() *devopsv1.ClusterMachine {
+	return &devopsv1.ClusterMachine{
+		IP:       "127.0.0.1",
+		Port:     m.Port(),
+		Username: Username,
+		Password: Password,
+	}
+}
+
+// Close stops the ssh server.
+func (m *Machine) Close() error {
+	err := m.listener.Close()
+	m.wg.Wait()
+	return err
+}
+
+func (m *Machine) serve() {
+	defer m.wg.Done()
+	for {
+		conn, err := m.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go m.handleConn(conn)
+	}
+}
+
+func (m *Machine) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	_, chans, reqs, err := ssh.NewServerConn(conn, m.config)
+	if err != nil {
+		klog.V(4).Infof("fake machine handshake err: %v", err)
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			klog.V(4).Infof("fake machine accept channel err: %v", err)
+			continue
+		}
+
+		go m.handleSession(channel, requests)
+	}
+}
+
+func (m *Machine) handleSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+
+	for req := range requests {
+		switch req.Type {
+		case "exec":
+			var payload struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+
+			result := m.exec(payload.Command)
+			io.WriteString(channel, result.Stdout)
+			io.WriteString(channel.Stderr(), result.Stderr)
+			status := make([]byte, 4)
+			binary.BigEndian.PutUint32(status, uint32(result.Exit))
+			channel.SendRequest("exit-status", false, status)
+			return
+		case "subsystem":
+			var payload struct{ Name string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil || payload.Name != "sftp" {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+
+			server := sftp.NewRequestServer(channel, m.fs)
+			if err := server.Serve(); err != nil && err != io.EOF {
+				klog.V(4).Infof("fake machine sftp err: %v", err)
+			}
+			server.Close()
+			return
+		default:
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+}
+
+func (m *Machine) exec(cmd string) Result {
+	m.mu.Lock()
+	m.commands = append(m.commands, cmd)
+	handlers := m.handlers
+	m.mu.Unlock()
+
+	for _, h := range handlers {
+		if h.pattern.MatchString(cmd) {
+			return h.result
+		}
+	}
+
+	if strings.HasPrefix(cmd, "ls ") {
+		if !m.Exist(strings.TrimSpace(strings.TrimPrefix(cmd, "ls "))) {
+			return Result{Stderr: fmt.Sprintf("ls: cannot access %s: No such file or directory", cmd[3:]), Exit: 2}
+		}
+	}
+
+	return Result{}
+}
@@ -0,0 +1,55 @@
+package fakemachine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMachine(t *testing.T) {
+	m, err := New()
+	if err != nil {
+		t.Fatalf("New() err: %v", err)
+	}
+	defer m.Close()
+
+	m.Handle(`^systemctl is-active docker$`, Result{Stdout: "inactive\n", Exit: 3})
+
+	s, err := m.ClusterMachine().SSH()
+	if err != nil {
+		t.Fatalf("SSH() err: %v", err)
+	}
+
+	out, err := s.CombinedOutput("uname -r")
+	if err != nil || !strings.HasPrefix(string(out), "4.19") {
+		t.Errorf("uname -r = %q, %v", out, err)
+	}
+
+	_, _, exit, err := s.Exec("systemctl is-active docker")
+	if err != nil || exit != 3 {
+		t.Errorf("systemctl exit = %d, %v, want 3", exit, err)
+	}
+
+	ok, err := s.Exist("/opt/k8s/init.sh")
+	if err != nil || ok {
+		t.Errorf("Exist() before write = %t, %v", ok, err)
+	}
+
+	err = s.WriteFile(strings.NewReader("echo init"), "/opt/k8s/init.sh")
+	if err != nil {
+		t.Fatalf("WriteFile() err: %v", err)
+	}
+
+	ok, err = s.Exist("/opt/k8s/init.sh")
+	if err != nil || !ok {
+		t.Errorf("Exist() after write = %t, %v", ok, err)
+	}
+
+	data, err := m.ReadFile("/opt/k8s/init.sh")
+	if err != nil || string(data) != "echo init" {
+		t.Errorf("ReadFile() = %q, %v", data, err)
+	}
+
+	if !m.Executed("systemctl is-active docker") {
+		t.Errorf("Commands() = %v, missing systemctl", m.Commands())
+	}
+}
@@ -0,0 +1,252 @@
+package framework
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	devopsv1 "github.com/gostship/kunkka/pkg/apis/devops/v1"
+	"github.com/gostship/kunkka/pkg/controllers"
+	"github.com/gostship/kunkka/pkg/k8sclient"
+	"github.com/gostship/kunkka/pkg/option"
+	"github.com/gostship/kunkka/pkg/static"
+	"github.com/gostship/kunkka/pkg/util/k8sutil"
+	"github.com/gostship/kunkka/test/e2e/framework/fakemachine"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlmanager "sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	DefaultTimeout  = 5 * time.Minute
+	DefaultInterval = 2 * time.Second
+)
+
+// Framework runs the operator in process against a kind meta cluster,
+// the scenario tests create clusters and machines which connect to fake machines.
+type Framework struct {
+	Kind   *KindCluster
+	Config *rest.Config
+	Client client.Client
+
+	dir      string
+	stopCh   chan struct{}
+	machines []*fakemachine.Machine
+}
+
+// New creates the kind meta cluster, installs the crds and starts the controllers.
+func New() (*Framework, error) {
+	dir, err := ioutil.TempDir("", "kunkka-e2e")
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Framework{
+		Kind:   NewKindCluster(dir),
+		dir:    dir,
+		stopCh: make(chan struct{}),
+	}
+
+	err = f.Kind.Create()
+	if err != nil {
+		return nil, err
+	}
+
+	f.Config, err = clientcmd.BuildConfigFromFlags("", f.Kind.Kubeconfig)
+	if err != nil {
+		return nil, errors.Wrapf(err, "load kubeconfig: %s", f.Kind.Kubeconfig)
+	}
+
+	crds, err := static.LoadCRDsFromFile(filepath.Join(RepoRoot(), "manifests/crds/crd.yaml"))
+	if err != nil {
+		return nil, errors.Wrap(err, "load crds")
+	}
+
+	err = k8sutil.ReconcileCrds(f.Config, crds)
+	if err != nil {
+		return nil, errors.Wrap(err, "reconcile crds")
+	}
+
+	f.Client, err = client.New(f.Config, client.Options{Scheme: k8sclient.GetScheme()})
+	if err != nil {
+		return nil, errors.Wrap(err, "new client")
+	}
+
+	err = f.startController()
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *Framework) startController() error {
+	mgr, err := ctrlmanager.New(f.Config, ctrlmanager.Options{
+		Scheme:             k8sclient.GetScheme(),
+		MetricsBindAddress: "0",
+	})
+	if err != nil {
+		return errors.Wrap(err, "new manager")
+	}
+
+	err = controllers.AddToManager(mgr, option.DefaultControllersManagerOption())
+	if err != nil {
+		return errors.Wrap(err, "add controllers")
+	}
+
+	go func() {
+		if err := mgr.Start(f.stopCh); err != nil {
+			klog.Errorf("manager exited err: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// Teardown stops the controllers and fake machines and deletes the kind cluster.
+func (f *Framework) Teardown() error {
+	close(f.stopCh)
+	for _, m := range f.machines {
+		m.Close()
+	}
+
+	err := f.Kind.Delete()
+	os.RemoveAll(f.dir)
+	return err
+}
+
+// NewMachine starts a fake machine which is closed on teardown.
+func (f *Framework) NewMachine() (*fakemachine.Machine, error) {
+	m, err := fakemachine.New()
+	if err != nil {
+		return nil, err
+	}
+
+	f.machines = append(f.machines, m)
+	return m, nil
+}
+
+// CreateNamespace creates a namespace with the given prefix for a scenario.
+func (f *Framework) CreateNamespace(ctx context.Context, prefix string) (string, error) {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: prefix + "-",
+		},
+	}
+	err := f.Client.Create(ctx, ns)
+	if err != nil {
+		return "", err
+	}
+
+	return ns.Name, nil
+}
+
+// NewBaremetalCluster returns a baremetal cluster using the machines.
+func NewBaremetalCluster(namespace, name string, machines ...*fakemachine.Machine) *devopsv1.Cluster {
+	c := &devopsv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: devopsv1.ClusterSpec{
+			DisplayName:   name,
+			Type:          "Baremetal",
+			Version:       "v1.18.8",
+			NetworkDevice: "eth0",
+			ClusterCIDR:   "10.244.0.0/16",
+		},
+	}
+	for _, m := range machines {
+		c.Spec.Machines = append(c.Spec.Machines, m.ClusterMachine())
+	}
+
+	return c
+}
+
+// WaitForCluster waits until the cluster satisfies the cond.
+func (f *Framework) WaitForCluster(ctx context.Context, key types.NamespacedName, cond func(c *devopsv1.Cluster) (bool, error)) (*devopsv1.Cluster, error) {
+	c := &devopsv1.Cluster{}
+	err := wait.PollImmediate(DefaultInterval, DefaultTimeout, func() (bool, error) {
+		err := f.Client.Get(ctx, key, c)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+
+		return cond(c)
+	})
+	if err != nil {
+		return c, errors.Wrapf(err, "wait cluster: %s, phase: %s, conditions: %s", key, c.Status.Phase, formatConditions(c))
+	}
+
+	return c, nil
+}
+
+// WaitForClusterCondition waits until the cluster condition is true,
+// it fails fast when the condition is false.
+func (f *Framework) WaitForClusterCondition(ctx context.Context, key types.NamespacedName, conditionType string) (*devopsv1.Cluster, error) {
+	return f.WaitForCluster(ctx, key, func(c *devopsv1.Cluster) (bool, error) {
+		for _, cond := range c.Status.Conditions {
+			if cond.Type != conditionType {
+				continue
+			}
+			switch cond.Status {
+			case devopsv1.ConditionTrue:
+				return true, nil
+			case devopsv1.ConditionFalse:
+				return false, fmt.Errorf("condition: %s failed: %s", cond.Type, cond.Message)
+			}
+		}
+
+		return false, nil
+	})
+}
+
+// WaitForClusterPhase waits until the cluster is in the phase.
+func (f *Framework) WaitForClusterPhase(ctx context.Context, key types.NamespacedName, phase devopsv1.ClusterPhase) (*devopsv1.Cluster, error) {
+	return f.WaitForCluster(ctx, key, func(c *devopsv1.Cluster) (bool, error) {
+		return c.Status.Phase == phase, nil
+	})
+}
+
+func formatConditions(c *devopsv1.Cluster) string {
+	conds := make([]string, 0, len(c.Status.Conditions))
+	for _, cond := range c.Status.Conditions {
+		conds = append(conds, fmt.Sprintf("%s=%s", cond.Type, cond.Status))
+	}
+
+	return strings.Join(conds, ",")
+}
+
+func splitLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+// RepoRoot returns the full path to the root of the repo.
+func RepoRoot() string {
+	_, filename, _, _ := runtime.Caller(0)
+
+	return filepath.Join(filepath.Dir(filename), "../../..")
+}
@@ -0,0 +1,90 @@
+package framework
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+)
+
+const (
+	// the crds are still apiextensions.k8s.io/v1beta1, so the meta cluster must be older than 1.22
+	defaultKindNodeImage = "kindest/node:v1.18.8"
+	defaultKindCluster   = "kunkka-e2e"
+)
+
+// KindCluster is a kind cluster used as the meta cluster.
+type KindCluster struct {
+	Name       string
+	Image      string
+	Kubeconfig string
+	// Reuse keeps an existing cluster with the same name and does not delete it when done
+	Reuse bool
+}
+
+// NewKindCluster returns the kind cluster configured by the env
+// E2E_KIND_CLUSTER, E2E_KIND_IMAGE and E2E_KIND_REUSE.
+func NewKindCluster(dir string) *KindCluster {
+	k := &KindCluster{
+		Name:  os.Getenv("E2E_KIND_CLUSTER"),
+		Image: os.Getenv("E2E_KIND_IMAGE"),
+		Reuse: os.Getenv("E2E_KIND_REUSE") == "true",
+	}
+	if k.Name == "" {
+		k.Name = defaultKindCluster
+	}
+	if k.Image == "" {
+		k.Image = defaultKindNodeImage
+	}
+	k.Kubeconfig = filepath.Join(dir, k.Name+".kubeconfig")
+	return k
+}
+
+func (k *KindCluster) exists() bool {
+	out, err := exec.Command("kind", "get", "clusters").Output()
+	if err != nil {
+		return false
+	}
+
+	for _, name := range splitLines(string(out)) {
+		if name == k.Name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Create creates the kind cluster and writes its kubeconfig.
+func (k *KindCluster) Create() error {
+	if k.Reuse && k.exists() {
+		klog.Infof("reuse kind cluster: %s", k.Name)
+		return k.run("export", "kubeconfig", "--name", k.Name, "--kubeconfig", k.Kubeconfig)
+	}
+
+	klog.Infof("create kind cluster: %s image: %s", k.Name, k.Image)
+	return k.run("create", "cluster", "--name", k.Name, "--image", k.Image, "--kubeconfig", k.Kubeconfig, "--wait", "2m")
+}
+
+// Delete deletes the kind cluster unless it is reused.
+func (k *KindCluster) Delete() error {
+	if k.Reuse {
+		return nil
+	}
+
+	klog.Infof("delete kind cluster: %s", k.Name)
+	return k.run("delete", "cluster", "--name", k.Name, "--kubeconfig", k.Kubeconfig)
+}
+
+func (k *KindCluster) run(args ...string) error {
+	cmd := exec.Command("kind", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "kind %v", args)
+	}
+
+	return nil
+}